@@ -0,0 +1,243 @@
+package asynqmon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - alertRuleStore which manages alert rules, and the alerts they raise, in redis
+// ****************************************************************************
+
+const (
+	// Hash of rule name to JSON encoded alertRuleRecord.
+	alertRulesKey = "asynqmon:alert_rules"
+	// Lease of the asynqmon instance which evaluates the rules, holding the ID of the instance.
+	alertLeaseKey = "asynqmon:alert_lease"
+	// JSON encoded list of alertRecord saved by the instance which evaluates the rules.
+	alertsKey = "asynqmon:alerts"
+)
+
+var (
+	errAlertRuleNotFound = errors.New("alert rule not found")
+	errAlertRuleExists   = errors.New("alert rule already exists")
+)
+
+// alertRuleRecord is an alert rule as stored in redis.
+type alertRuleRecord struct {
+	Name      string        `json:"name"`
+	Type      string        `json:"type"`
+	Queue     string        `json:"queue"`
+	For       time.Duration `json:"for"`
+	Severity  string        `json:"severity"`
+	Threshold float64       `json:"threshold"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+func (rec *alertRuleRecord) rule() *AlertRule {
+	return &AlertRule{
+		Name:      rec.Name,
+		Type:      rec.Type,
+		Queue:     rec.Queue,
+		For:       rec.For,
+		Severity:  rec.Severity,
+		Threshold: rec.Threshold,
+	}
+}
+
+// alertRuleStore manages alert rules created through the API.
+// Rules are stored in redis so that they are shared by every asynqmon instance
+// and survive restarts.
+type alertRuleStore struct {
+	rc redis.UniversalClient
+}
+
+func newAlertRuleStore(rc redis.UniversalClient) *alertRuleStore {
+	return &alertRuleStore{rc: rc}
+}
+
+// list returns the rules sorted by creation time.
+func (s *alertRuleStore) list(ctx context.Context) ([]*alertRuleRecord, error) {
+	vals, err := s.rc.HGetAll(ctx, alertRulesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	recs := make([]*alertRuleRecord, 0, len(vals))
+	for name, data := range vals {
+		var rec alertRuleRecord
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			return nil, fmt.Errorf("invalid alert rule %q in redis: %v", name, err)
+		}
+		recs = append(recs, &rec)
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].CreatedAt.Equal(recs[j].CreatedAt) {
+			return recs[i].Name < recs[j].Name
+		}
+		return recs[i].CreatedAt.Before(recs[j].CreatedAt)
+	})
+	return recs, nil
+}
+
+// get returns the rule with the name. It returns errAlertRuleNotFound if the rule does not exist.
+func (s *alertRuleStore) get(ctx context.Context, name string) (*alertRuleRecord, error) {
+	data, err := s.rc.HGet(ctx, alertRulesKey, name).Result()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("%w: %q", errAlertRuleNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec alertRuleRecord
+	if err := json.Unmarshal([]byte(data), &rec); err != nil {
+		return nil, fmt.Errorf("invalid alert rule %q in redis: %v", name, err)
+	}
+	return &rec, nil
+}
+
+// create stores a new rule. It returns errAlertRuleExists if a rule with the same name exists.
+func (s *alertRuleStore) create(ctx context.Context, rec *alertRuleRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	ok, err := s.rc.HSetNX(ctx, alertRulesKey, rec.Name, data).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %q", errAlertRuleExists, rec.Name)
+	}
+	return nil
+}
+
+// put creates or replaces the rule with the same name.
+func (s *alertRuleStore) put(ctx context.Context, rec *alertRuleRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.rc.HSet(ctx, alertRulesKey, rec.Name, data).Err()
+}
+
+// update replaces the rule with the same name, keeping its creation time.
+// It returns errAlertRuleNotFound if the rule does not exist.
+func (s *alertRuleStore) update(ctx context.Context, rec *alertRuleRecord) error {
+	return s.rc.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.HGet(ctx, alertRulesKey, rec.Name).Result()
+		if err == redis.Nil {
+			return fmt.Errorf("%w: %q", errAlertRuleNotFound, rec.Name)
+		}
+		if err != nil {
+			return err
+		}
+		var old alertRuleRecord
+		if err := json.Unmarshal([]byte(data), &old); err == nil {
+			rec.CreatedAt = old.CreatedAt
+		}
+		newData, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, alertRulesKey, rec.Name, newData)
+			return nil
+		})
+		return err
+	}, alertRulesKey)
+}
+
+// delete deletes the rule. It returns errAlertRuleNotFound if the rule does not exist.
+func (s *alertRuleStore) delete(ctx context.Context, name string) error {
+	n, err := s.rc.HDel(ctx, alertRulesKey, name).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %q", errAlertRuleNotFound, name)
+	}
+	return nil
+}
+
+// alertRecord is an alert which is pending or firing as stored in redis.
+type alertRecord struct {
+	alertInfo
+	// Queue the alert is about, or empty if the alert is not about a queue.
+	Queue string `json:"queue"`
+}
+
+// acquireLeaseCmd acquires the lease, or renews it if the instance holds it.
+//
+// KEYS[1] -> asynqmon:alert_lease
+// ARGV[1] -> ID of the instance
+// ARGV[2] -> duration of the lease in milliseconds
+//
+// Returns 1 if the instance holds the lease, and 0 otherwise.
+var acquireLeaseCmd = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0`)
+
+// releaseLeaseCmd releases the lease if the instance holds it.
+//
+// KEYS[1] -> asynqmon:alert_lease
+// ARGV[1] -> ID of the instance
+var releaseLeaseCmd = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// acquireLease reports whether the instance with the given ID holds the lease to evaluate
+// the rules for d, acquiring it if no instance holds it.
+func (s *alertRuleStore) acquireLease(ctx context.Context, id string, d time.Duration) (bool, error) {
+	n, err := acquireLeaseCmd.Run(ctx, s.rc, []string{alertLeaseKey}, id, d.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// releaseLease releases the lease if the instance with the given ID holds it,
+// so that another instance takes over without waiting for the lease to expire.
+func (s *alertRuleStore) releaseLease(ctx context.Context, id string) error {
+	return releaseLeaseCmd.Run(ctx, s.rc, []string{alertLeaseKey}, id).Err()
+}
+
+// putAlerts saves the alerts which are pending or firing, which are kept for d
+// unless saved again.
+func (s *alertRuleStore) putAlerts(ctx context.Context, alerts []*alertRecord, d time.Duration) error {
+	data, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	return s.rc.Set(ctx, alertsKey, data, d).Err()
+}
+
+// listAlerts returns the saved alerts which are pending or firing.
+func (s *alertRuleStore) listAlerts(ctx context.Context) ([]*alertRecord, error) {
+	data, err := s.rc.Get(ctx, alertsKey).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var alerts []*alertRecord
+	if err := json.Unmarshal(data, &alerts); err != nil {
+		return nil, fmt.Errorf("invalid alerts in redis: %v", err)
+	}
+	return alerts, nil
+}
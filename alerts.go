@@ -0,0 +1,1144 @@
+package asynqmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - AlertRule and alertEvaluator which sends notifications when rules fire
+//   - http.Handler(s) for alert related endpoints
+// ****************************************************************************
+
+// Types of alert rules.
+const (
+	// AlertProcessingSilence fires when a queue has pending tasks but no tasks
+	// have been processed, and no workers are busy with tasks from the queue
+	// (or no servers are processing the queue at all), for the duration of the rule.
+	// This catches workers which died silently, which queue size alerts miss.
+	AlertProcessingSilence = "processing_silence"
+
+	// AlertServerDisappeared fires when a server which was heartbeating stops
+	// heartbeating and is not replaced by a new server on the same host or
+	// processing the same queues within the duration of the rule.
+	// Notifications include the queues which lost capacity as a result.
+	AlertServerDisappeared = "server_disappeared"
+
+	// AlertAnomaly fires when the size, latency, or error rate of a queue is
+	// unusually high compared to its recent history, without a static threshold.
+	// A value is unusual if it is more than Threshold standard deviations above
+	// the exponentially weighted moving average of the metric.
+	AlertAnomaly = "anomaly"
+
+	// AlertQueueSize fires when the number of pending tasks in a queue exceeds Threshold.
+	AlertQueueSize = "queue_size"
+
+	// AlertArchivedTasks fires when the number of archived (dead-letter) tasks in a queue
+	// exceeds Threshold. With the default threshold of zero, it fires as soon as a task
+	// is archived, and resolves once the archived tasks are deleted or run.
+	AlertArchivedTasks = "archived_tasks"
+
+	// AlertLatency fires when the latency of a queue, the time the oldest pending task
+	// has been waiting, exceeds Threshold seconds.
+	AlertLatency = "latency"
+
+	// AlertSchedulerEnqueueFailures fires when a scheduler entry missed at least Threshold
+	// of its most recent enqueues in a row, according to its cron spec and enqueue history.
+	// Queue restricts the rule to the entries which enqueue tasks to the queue.
+	AlertSchedulerEnqueueFailures = "scheduler_enqueue_failures"
+)
+
+// AlertRule specifies a condition to send notifications about through Options.Notifiers.
+// A notification is sent when the condition has held for the duration of the rule,
+// and another one when the condition no longer holds.
+type AlertRule struct {
+	// Name identifies the rule in notifications and the API.
+	//
+	// This field is optional. Default is the type followed by the queue name, if any.
+	Name string
+
+	// Type is the type of the rule (e.g. AlertProcessingSilence).
+	Type string
+
+	// Queue is the name of the queue the rule applies to.
+	//
+	// This field is optional. Default is all queues.
+	Queue string
+
+	// For is how long the condition must hold before the alert fires.
+	//
+	// This field is optional. If zero, the alert fires as soon as the condition holds.
+	For time.Duration
+
+	// Severity of the notifications (e.g. SeverityWarning).
+	//
+	// This field is optional. Default is SeverityCritical.
+	Severity string
+
+	// Threshold of the rule:
+	//   - AlertAnomaly: the number of standard deviations above the moving average at which a value is unusual
+	//   - AlertQueueSize: the number of pending tasks
+	//   - AlertArchivedTasks: the number of archived tasks
+	//   - AlertLatency: the latency in seconds
+	//   - AlertSchedulerEnqueueFailures: the number of enqueues missed in a row
+	//
+	// This field is optional for AlertAnomaly (default 3), AlertArchivedTasks (default 0),
+	// and AlertSchedulerEnqueueFailures (default 2), and required for AlertQueueSize and AlertLatency.
+	Threshold float64
+}
+
+// Interval between evaluations of alert rules.
+const alertEvaluationInterval = 30 * time.Second
+
+// How long a server which stopped heartbeating is remembered if not replaced.
+const lostServerRetention = 24 * time.Hour
+
+const (
+	// Default threshold of AlertAnomaly rules.
+	defaultAnomalyThreshold = 3
+
+	// Default threshold of AlertSchedulerEnqueueFailures rules.
+	// A single miss may be a blip of redis, two in a row are not.
+	defaultSchedulerEnqueueFailuresThreshold = 2
+
+	// Weight of the latest value in the moving averages of queue metrics.
+	// With an evaluation every 30 seconds, the averages span about 10 minutes.
+	anomalyEWMAAlpha = 0.05
+
+	// Number of values a moving average needs before values are compared against it.
+	anomalyWarmup = 20
+)
+
+// Queue metrics watched by AlertAnomaly rules.
+const (
+	anomalyMetricSize      = "size"
+	anomalyMetricLatency   = "latency"
+	anomalyMetricErrorRate = "error_rate"
+)
+
+// Minimum standard deviations of queue metrics so that small changes of
+// metrics which have been flat are not considered unusual.
+var anomalyMinStdDev = map[string]float64{
+	anomalyMetricSize:      10,
+	anomalyMetricLatency:   1, // seconds
+	anomalyMetricErrorRate: 0.02,
+}
+
+// validateAlertRules sets the default values of the rules and returns an error
+// if any of the rules is invalid.
+func validateAlertRules(rules []*AlertRule) error {
+	names := make(map[string]bool)
+	for _, rule := range rules {
+		if err := validateAlertRule(rule); err != nil {
+			return err
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate alert rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return nil
+}
+
+// validateAlertRule sets the default values of the rule and returns an error if the rule is invalid.
+func validateAlertRule(rule *AlertRule) error {
+	if rule.Name == "" {
+		rule.Name = rule.Type
+		if rule.Queue != "" {
+			rule.Name += ":" + rule.Queue
+		}
+	}
+	switch rule.Type {
+	case AlertProcessingSilence, AlertServerDisappeared:
+	case AlertAnomaly, AlertArchivedTasks:
+		if rule.Threshold < 0 {
+			return fmt.Errorf("alert rule %q has negative threshold", rule.Name)
+		}
+		if rule.Type == AlertAnomaly && rule.Threshold == 0 {
+			rule.Threshold = defaultAnomalyThreshold
+		}
+	case AlertSchedulerEnqueueFailures:
+		if rule.Threshold < 0 {
+			return fmt.Errorf("alert rule %q has negative threshold", rule.Name)
+		}
+		if rule.Threshold == 0 {
+			rule.Threshold = defaultSchedulerEnqueueFailuresThreshold
+		}
+	case AlertQueueSize, AlertLatency:
+		if rule.Threshold <= 0 {
+			return fmt.Errorf("alert rule %q must have positive threshold", rule.Name)
+		}
+	default:
+		return fmt.Errorf("alert rule has unknown type %q", rule.Type)
+	}
+	if rule.For < 0 {
+		return fmt.Errorf("alert rule %q has negative duration", rule.Name)
+	}
+	if rule.Severity == "" {
+		rule.Severity = SeverityCritical
+	}
+	return nil
+}
+
+// alertSnapshot is the state of the queues and servers the rules are evaluated against.
+type alertSnapshot struct {
+	time    time.Time
+	queues  []*asynq.QueueInfo
+	servers []*asynq.ServerInfo
+
+	// Number of tasks processed in each queue at the previous evaluation.
+	prevProcessed map[string]int
+
+	// Servers which stopped heartbeating and have not been replaced.
+	lostServers []*lostServer
+
+	// Unusual queue metrics keyed by queue name and metric.
+	anomalies map[string]map[string]*anomaly
+
+	// Enqueue failures of the scheduler entries.
+	// Only set if there are AlertSchedulerEnqueueFailures rules.
+	schedulerFailures []*schedulerEnqueueFailure
+}
+
+// ewma is an exponentially weighted moving average and variance of a metric.
+type ewma struct {
+	mean     float64
+	variance float64
+	n        int
+}
+
+func (e *ewma) add(x float64) {
+	if e.n == 0 {
+		e.mean = x
+	} else {
+		diff := x - e.mean
+		incr := anomalyEWMAAlpha * diff
+		e.mean += incr
+		e.variance = (1 - anomalyEWMAAlpha) * (e.variance + diff*incr)
+	}
+	e.n++
+}
+
+// anomaly is a value of a queue metric compared to its moving average.
+type anomaly struct {
+	value  float64
+	mean   float64
+	zscore float64
+}
+
+// queueMetrics returns the metrics of the queue watched for anomalies.
+// The error rate is omitted if no tasks were processed since the previous evaluation.
+func queueMetrics(q *asynq.QueueInfo, prevProcessed, prevFailed map[string]int) map[string]float64 {
+	m := map[string]float64{
+		anomalyMetricSize:    float64(q.Size),
+		anomalyMetricLatency: q.Latency.Seconds(),
+	}
+	if prev, ok := prevProcessed[q.Queue]; ok {
+		processed, failed := q.Processed-prev, q.Failed-prevFailed[q.Queue]
+		if processed < 0 {
+			// Daily counters were reset at midnight (UTC).
+			processed, failed = q.Processed, q.Failed
+		}
+		if processed > 0 {
+			m[anomalyMetricErrorRate] = float64(failed) / float64(processed)
+		}
+	}
+	return m
+}
+
+// lostServer is a server which stopped heartbeating.
+type lostServer struct {
+	info   *asynq.ServerInfo
+	lostAt time.Time
+}
+
+// replacedBy reports whether srv, a server which started after s was lost,
+// replaces s. That is the case if srv runs on the same host (e.g. restarted
+// process) or processes the same queues (e.g. rescheduled container).
+func (s *lostServer) replacedBy(srv *asynq.ServerInfo) bool {
+	if srv.Host == s.info.Host {
+		return true
+	}
+	if len(srv.Queues) != len(s.info.Queues) {
+		return false
+	}
+	for qname := range s.info.Queues {
+		if _, ok := srv.Queues[qname]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// serversProcessing returns the number of servers processing tasks from the queue.
+func (s *alertSnapshot) serversProcessing(qname string) int {
+	n := 0
+	for _, srv := range s.servers {
+		if _, ok := srv.Queues[qname]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+// capacityLoss describes the queues processed by srv and the servers left processing them.
+func (s *alertSnapshot) capacityLoss(srv *asynq.ServerInfo) string {
+	qnames := make([]string, 0, len(srv.Queues))
+	for qname := range srv.Queues {
+		qnames = append(qnames, qname)
+	}
+	sort.Strings(qnames)
+	var b strings.Builder
+	for i, qname := range qnames {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if n := s.serversProcessing(qname); n == 0 {
+			fmt.Fprintf(&b, "%s (no servers left)", qname)
+		} else {
+			fmt.Fprintf(&b, "%s (servers left: %d)", qname, n)
+		}
+	}
+	return b.String()
+}
+
+// alertCondition is a condition of a rule which currently holds.
+type alertCondition struct {
+	// Subject of the condition (e.g. the queue name).
+	subject string
+	// Queue the condition is about, or empty if it is not about a queue.
+	queue   string
+	message string
+}
+
+// conditions returns the conditions of the rule which currently hold.
+func (rule *AlertRule) conditions(s *alertSnapshot) []*alertCondition {
+	var conds []*alertCondition
+	switch rule.Type {
+	case AlertProcessingSilence:
+		for _, q := range s.queues {
+			if rule.Queue != "" && q.Queue != rule.Queue {
+				continue
+			}
+			prev, ok := s.prevProcessed[q.Queue]
+			if !ok || q.Paused || q.Pending == 0 || q.Processed != prev {
+				continue
+			}
+			servers := s.serversProcessing(q.Queue)
+			if q.Active > 0 && servers > 0 {
+				continue // workers are busy with long running tasks
+			}
+			conds = append(conds, &alertCondition{
+				subject: q.Queue,
+				queue:   q.Queue,
+				message: fmt.Sprintf("Queue %q has %d pending tasks but no tasks are being processed (active tasks: %d, servers processing the queue: %d).",
+					q.Queue, q.Pending, q.Active, servers),
+			})
+		}
+	case AlertServerDisappeared:
+		for _, lost := range s.lostServers {
+			if _, ok := lost.info.Queues[rule.Queue]; rule.Queue != "" && !ok {
+				continue
+			}
+			conds = append(conds, &alertCondition{
+				subject: fmt.Sprintf("%s:%d", lost.info.Host, lost.info.PID),
+				message: fmt.Sprintf("Server %s (host: %s, pid: %d, concurrency: %d) stopped heartbeating at %s. Queues losing capacity: %s.",
+					lost.info.ID, lost.info.Host, lost.info.PID, lost.info.Concurrency,
+					lost.lostAt.Format(time.RFC3339), s.capacityLoss(lost.info)),
+			})
+		}
+	case AlertQueueSize, AlertArchivedTasks, AlertLatency:
+		for _, q := range s.queues {
+			if rule.Queue != "" && q.Queue != rule.Queue {
+				continue
+			}
+			var msg string
+			switch {
+			case rule.Type == AlertQueueSize && float64(q.Pending) > rule.Threshold:
+				msg = fmt.Sprintf("Queue %q has %d pending tasks, more than the threshold of %g.", q.Queue, q.Pending, rule.Threshold)
+			case rule.Type == AlertArchivedTasks && float64(q.Archived) > rule.Threshold:
+				msg = fmt.Sprintf("Queue %q has %d archived tasks.", q.Queue, q.Archived)
+				if rule.Threshold > 0 {
+					msg = fmt.Sprintf("Queue %q has %d archived tasks, more than the threshold of %g.", q.Queue, q.Archived, rule.Threshold)
+				}
+			case rule.Type == AlertLatency && q.Latency.Seconds() > rule.Threshold:
+				msg = fmt.Sprintf("The oldest pending task in queue %q has been waiting for %v, longer than the threshold of %v.",
+					q.Queue, q.Latency.Round(time.Second), time.Duration(rule.Threshold*float64(time.Second)))
+			default:
+				continue
+			}
+			conds = append(conds, &alertCondition{subject: q.Queue, queue: q.Queue, message: msg})
+		}
+	case AlertAnomaly:
+		for _, q := range s.queues {
+			if rule.Queue != "" && q.Queue != rule.Queue {
+				continue
+			}
+			for _, metric := range []string{anomalyMetricSize, anomalyMetricLatency, anomalyMetricErrorRate} {
+				a, ok := s.anomalies[q.Queue][metric]
+				if !ok || a.zscore < rule.Threshold {
+					continue
+				}
+				conds = append(conds, &alertCondition{
+					subject: q.Queue + ":" + metric,
+					queue:   q.Queue,
+					message: fmt.Sprintf("The %s of queue %q is unusually high: %.4g compared to a recent average of %.4g (%.1f standard deviations above).",
+						strings.Replace(metric, "_", " ", -1), q.Queue, a.value, a.mean, a.zscore),
+				})
+			}
+		}
+	case AlertSchedulerEnqueueFailures:
+		for _, f := range s.schedulerFailures {
+			if rule.Queue != "" && f.queue != rule.Queue {
+				continue
+			}
+			if float64(f.ConsecutiveMissed) < rule.Threshold {
+				continue
+			}
+			conds = append(conds, &alertCondition{
+				subject: f.EntryID,
+				queue:   f.queue,
+				message: fmt.Sprintf("Scheduler entry %s (spec: %q, task type: %q) missed its last %d enqueues; the last one was expected at %s.",
+					f.EntryID, f.Spec, f.TaskType, f.ConsecutiveMissed, f.LastMissedAt),
+			})
+		}
+	}
+	return conds
+}
+
+// alertState is the state of a condition of a rule which holds.
+type alertState struct {
+	rule    *AlertRule
+	cluster string // name of the cluster, empty unless multiple clusters are monitored
+	subject string
+	queue   string
+	message string
+	since   time.Time // when the condition started to hold
+	firing  bool
+	firedAt time.Time
+}
+
+// alertEvaluator periodically evaluates alert rules and sends notifications
+// when alerts fire and resolve.
+//
+// Only the asynqmon instance holding the lease in redis evaluates the rules, so that
+// each notification is sent once however many instances are running. It saves the alerts
+// in redis for the other instances to list, and for the instance which takes over the lease
+// to carry on from.
+type alertEvaluator struct {
+	id        string // random ID of the instance
+	cluster   string // name of the cluster, empty unless multiple clusters are monitored
+	inspector *asynq.Inspector
+	loc       *time.Location  // time zone of the schedulers
+	store     *alertRuleStore // rules managed with the API
+	notifiers []Notifier
+	interval  time.Duration
+	rules     []*AlertRule // rules configured with Options.AlertRules
+
+	mu            sync.Mutex
+	states        map[string]*alertState // keyed by rule name and subject
+	prevProcessed map[string]int
+	prevFailed    map[string]int
+	servers       map[string]*asynq.ServerInfo // servers seen at the previous evaluation keyed by ID
+	lostServers   map[string]*lostServer       // keyed by ID
+	baselines     map[string]map[string]*ewma  // moving averages of queue metrics keyed by queue name and metric
+
+	// Whether the instance held the lease at the previous evaluation.
+	// Only used by the goroutine evaluating the rules.
+	leading bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// cluster is the name of the cluster to include in notifications, or empty unless
+// multiple clusters are monitored.
+func newAlertEvaluator(inspector *asynq.Inspector, loc *time.Location, store *alertRuleStore, rules []*AlertRule, notifiers []Notifier, interval time.Duration, cluster string) *alertEvaluator {
+	id, _ := randomHex(16)
+	return &alertEvaluator{
+		id:          id,
+		cluster:     cluster,
+		inspector:   inspector,
+		loc:         loc,
+		store:       store,
+		notifiers:   notifiers,
+		interval:    interval,
+		rules:       rules,
+		states:      make(map[string]*alertState),
+		lostServers: make(map[string]*lostServer),
+		baselines:   make(map[string]map[string]*ewma),
+		done:        make(chan struct{}),
+	}
+}
+
+func (e *alertEvaluator) start() {
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.evaluate()
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.done:
+				return
+			case <-ticker.C:
+				e.evaluate()
+			}
+		}
+	}()
+}
+
+func (e *alertEvaluator) stop() error {
+	close(e.done)
+	e.wg.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRedisTimeout)
+	defer cancel()
+	return e.store.releaseLease(ctx, e.id)
+}
+
+func (e *alertEvaluator) snapshot(rules []*AlertRule) (*alertSnapshot, error) {
+	qnames, err := e.inspector.Queues()
+	if err != nil {
+		return nil, err
+	}
+	s := &alertSnapshot{time: time.Now()}
+	for _, qname := range qnames {
+		info, err := e.inspector.GetQueueInfo(qname)
+		if err != nil {
+			return nil, err
+		}
+		s.queues = append(s.queues, info)
+	}
+	if s.servers, err = e.inspector.Servers(); err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		// The enqueue history of every entry is read, so only if needed.
+		if rule.Type != AlertSchedulerEnqueueFailures {
+			continue
+		}
+		all := func(qname string) bool { return true }
+		if s.schedulerFailures, err = listSchedulerEnqueueFailures(e.inspector, e.loc, s.time.Add(-24*time.Hour), all); err != nil {
+			return nil, err
+		}
+		break
+	}
+	return s, nil
+}
+
+// allRules returns the rules configured with Options.AlertRules followed by the rules managed with the API.
+func (e *alertEvaluator) allRules(ctx context.Context) ([]*AlertRule, error) {
+	recs, err := e.store.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]*AlertRule, 0, len(e.rules)+len(recs))
+	rules = append(rules, e.rules...)
+	for _, rec := range recs {
+		rules = append(rules, rec.rule())
+	}
+	return rules, nil
+}
+
+// isConfigured reports whether the rule with the given name is configured with Options.AlertRules.
+func (e *alertEvaluator) isConfigured(name string) bool {
+	for _, rule := range e.rules {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *alertEvaluator) evaluate() {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRedisTimeout)
+	rules, err := e.allRules(ctx)
+	var leading bool
+	if err == nil {
+		leading, err = e.lead(ctx, rules)
+	}
+	cancel()
+	if err != nil {
+		log.Printf("error: could not evaluate alert rules: %v", err)
+		return
+	}
+	if !leading {
+		return
+	}
+	if len(rules) == 0 {
+		e.saveAlerts()
+		return
+	}
+	s, err := e.snapshot(rules)
+	if err != nil {
+		log.Printf("error: could not evaluate alert rules: %v", err)
+		return
+	}
+	notifs := e.update(rules, s)
+	e.saveAlerts()
+	for _, n := range notifs {
+		e.notify(n)
+	}
+}
+
+// lead reports whether the instance evaluates the rules, acquiring or renewing the lease.
+// An instance which acquires the lease carries on from the alerts saved by the previous holder.
+func (e *alertEvaluator) lead(ctx context.Context, rules []*AlertRule) (bool, error) {
+	leading, err := e.store.acquireLease(ctx, e.id, 3*e.interval)
+	if err != nil {
+		return false, err
+	}
+	if !leading || len(rules) == 0 {
+		// Do not poll redis until the instance evaluates a rule, and start over then.
+		e.reset()
+	} else if !e.leading {
+		if err := e.restore(ctx, rules); err != nil {
+			return false, err
+		}
+	}
+	e.leading = leading && len(rules) > 0
+	return leading, nil
+}
+
+func (e *alertEvaluator) reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.states = make(map[string]*alertState)
+	e.prevProcessed, e.prevFailed, e.servers = nil, nil, nil
+	e.lostServers = make(map[string]*lostServer)
+	e.baselines = make(map[string]map[string]*ewma)
+}
+
+// restore restores the alerts of the rules saved in redis, so that alerts which fired
+// before the instance acquired the lease do not fire again, and resolve.
+func (e *alertEvaluator) restore(ctx context.Context, rules []*AlertRule) error {
+	recs, err := e.store.listAlerts(ctx)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]*AlertRule, len(rules))
+	for _, rule := range rules {
+		byName[rule.Name] = rule
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, rec := range recs {
+		rule, ok := byName[rec.Rule]
+		if !ok || rule.Type != rec.Type {
+			continue
+		}
+		st := &alertState{rule: rule, cluster: e.cluster, subject: rec.Subject, queue: rec.Queue, message: rec.Message}
+		if st.since, err = time.Parse(time.RFC3339, rec.Since); err != nil {
+			continue
+		}
+		if rec.State == "firing" {
+			st.firing = true
+			if st.firedAt, err = time.Parse(time.RFC3339, rec.FiredAt); err != nil {
+				continue
+			}
+		}
+		e.states[rule.Name+"/"+rec.Subject] = st
+	}
+	return nil
+}
+
+// saveAlerts saves the alerts which are pending or firing for the other instances to list.
+// They expire unless the instance saves them again before the lease expires.
+func (e *alertEvaluator) saveAlerts() {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRedisTimeout)
+	defer cancel()
+	if err := e.store.putAlerts(ctx, e.alerts(), 3*e.interval); err != nil {
+		log.Printf("error: could not save alerts: %v", err)
+	}
+}
+
+// alerts returns the alerts which are pending or firing.
+func (e *alertEvaluator) alerts() []*alertRecord {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	alerts := make([]*alertRecord, 0, len(e.states))
+	for _, st := range e.states {
+		a := &alertRecord{
+			alertInfo: alertInfo{
+				Rule:    st.rule.Name,
+				Type:    st.rule.Type,
+				Subject: st.subject,
+				Message: st.message,
+				State:   "pending",
+				Since:   st.since.Format(time.RFC3339),
+			},
+			Queue: st.queue,
+		}
+		if st.firing {
+			a.State = "firing"
+			a.FiredAt = st.firedAt.Format(time.RFC3339)
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts
+}
+
+// update evaluates the rules against the snapshot and returns the notifications
+// of the alerts which fired or resolved since the previous snapshot.
+func (e *alertEvaluator) update(rules []*AlertRule, s *alertSnapshot) []*Notification {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s.prevProcessed = e.prevProcessed
+	e.detectAnomalies(s)
+	e.prevProcessed = make(map[string]int, len(s.queues))
+	e.prevFailed = make(map[string]int, len(s.queues))
+	for _, q := range s.queues {
+		e.prevProcessed[q.Queue] = q.Processed
+		e.prevFailed[q.Queue] = q.Failed
+	}
+	e.trackServers(s)
+	var notifs []*Notification
+	names := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		names[rule.Name] = true
+		holding := make(map[string]bool)
+		for _, c := range rule.conditions(s) {
+			key := rule.Name + "/" + c.subject
+			holding[key] = true
+			st, ok := e.states[key]
+			if !ok {
+				st = &alertState{cluster: e.cluster, subject: c.subject, queue: c.queue, since: s.time}
+				e.states[key] = st
+			}
+			// Managed rules are loaded on each evaluation and may have been updated.
+			st.rule = rule
+			st.message = c.message
+			if !st.firing && s.time.Sub(st.since) >= rule.For {
+				st.firing = true
+				st.firedAt = s.time
+				notifs = append(notifs, st.notification(s.time))
+			}
+		}
+		for key, st := range e.states {
+			if st.rule.Name != rule.Name || holding[key] {
+				continue
+			}
+			if s.prevProcessed == nil {
+				// Alerts restored from redis are kept until conditions which compare
+				// snapshots can be checked.
+				continue
+			}
+			delete(e.states, key)
+			if st.firing {
+				notifs = append(notifs, st.resolvedNotification(s.time))
+			}
+		}
+	}
+	// Forget about alerts of deleted rules.
+	for key, st := range e.states {
+		if !names[st.rule.Name] {
+			delete(e.states, key)
+		}
+	}
+	return notifs
+}
+
+// detectAnomalies compares the metrics of each queue to their moving averages
+// and then adds the metrics to the averages. Must be called with e.mu held.
+func (e *alertEvaluator) detectAnomalies(s *alertSnapshot) {
+	s.anomalies = make(map[string]map[string]*anomaly)
+	baselines := make(map[string]map[string]*ewma, len(s.queues))
+	for _, q := range s.queues {
+		b, ok := e.baselines[q.Queue]
+		if !ok {
+			b = make(map[string]*ewma)
+		}
+		baselines[q.Queue] = b
+		for metric, x := range queueMetrics(q, e.prevProcessed, e.prevFailed) {
+			avg, ok := b[metric]
+			if !ok {
+				avg = &ewma{}
+				b[metric] = avg
+			}
+			if avg.n >= anomalyWarmup {
+				stddev := math.Max(math.Sqrt(avg.variance), anomalyMinStdDev[metric])
+				if s.anomalies[q.Queue] == nil {
+					s.anomalies[q.Queue] = make(map[string]*anomaly)
+				}
+				s.anomalies[q.Queue][metric] = &anomaly{value: x, mean: avg.mean, zscore: (x - avg.mean) / stddev}
+			}
+			avg.add(x)
+		}
+	}
+	// Forget about queues which no longer exist.
+	e.baselines = baselines
+}
+
+// trackServers records the servers which stopped heartbeating since the previous
+// evaluation and forgets the ones which have been replaced. Must be called with e.mu held.
+func (e *alertEvaluator) trackServers(s *alertSnapshot) {
+	servers := make(map[string]*asynq.ServerInfo, len(s.servers))
+	for _, srv := range s.servers {
+		servers[srv.ID] = srv
+		if _, ok := e.servers[srv.ID]; ok || e.servers == nil {
+			continue
+		}
+		for id, lost := range e.lostServers {
+			if lost.replacedBy(srv) {
+				delete(e.lostServers, id)
+			}
+		}
+	}
+	for id, srv := range e.servers {
+		if _, ok := servers[id]; !ok {
+			e.lostServers[id] = &lostServer{info: srv, lostAt: s.time}
+		}
+	}
+	e.servers = servers
+	for id, lost := range e.lostServers {
+		if s.time.Sub(lost.lostAt) > lostServerRetention {
+			delete(e.lostServers, id)
+		} else {
+			s.lostServers = append(s.lostServers, lost)
+		}
+	}
+	sort.Slice(s.lostServers, func(i, j int) bool { return s.lostServers[i].info.ID < s.lostServers[j].info.ID })
+}
+
+// key returns the key of the notifications about the alert (see Notification.Key).
+func (st *alertState) key() string {
+	if st.cluster != "" {
+		return "asynqmon/" + st.cluster + "/" + st.rule.Name + "/" + st.subject
+	}
+	return "asynqmon/" + st.rule.Name + "/" + st.subject
+}
+
+// title returns the title of the notifications about the alert, which tells the
+// cluster apart if multiple clusters are monitored.
+func (st *alertState) title() string {
+	if st.cluster != "" {
+		return fmt.Sprintf("[%s] %s (cluster: %s)", st.rule.Name, st.subject, st.cluster)
+	}
+	return fmt.Sprintf("[%s] %s", st.rule.Name, st.subject)
+}
+
+// inCluster returns " in cluster <name>" if multiple clusters are monitored, or an empty string.
+func (st *alertState) inCluster() string {
+	if st.cluster != "" {
+		return fmt.Sprintf(" in cluster %q", st.cluster)
+	}
+	return ""
+}
+
+func (st *alertState) notification(now time.Time) *Notification {
+	return &Notification{
+		Title:    st.title(),
+		Message:  fmt.Sprintf("%s The condition has held%s for %v.", st.message, st.inCluster(), now.Sub(st.since).Round(time.Second)),
+		Severity: st.rule.Severity,
+		Time:     now,
+		Key:      st.key(),
+	}
+}
+
+func (st *alertState) resolvedNotification(now time.Time) *Notification {
+	return &Notification{
+		Title:    "Resolved: " + st.title(),
+		Message:  fmt.Sprintf("The alert fired%s at %s has resolved.", st.inCluster(), st.firedAt.Format(time.RFC3339)),
+		Severity: SeverityInfo,
+		Time:     now,
+		Key:      st.key(),
+		Resolved: true,
+	}
+}
+
+func (e *alertEvaluator) notify(n *Notification) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultNotifyTimeout)
+	defer cancel()
+	for _, nt := range e.notifiers {
+		if err := nt.Notify(ctx, n); err != nil {
+			log.Printf("error: could not send alert notification through %s: %v", nt.Name(), err)
+		}
+	}
+}
+
+type alertRuleInfo struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Queue      string  `json:"queue"`
+	ForSeconds int64   `json:"for_seconds"`
+	Severity   string  `json:"severity"`
+	Threshold  float64 `json:"threshold"`
+	// True if the rule is managed with the API, false if it is configured with the options of the deployment.
+	Managed bool `json:"managed"`
+}
+
+func toAlertRuleInfo(rule *AlertRule, managed bool) *alertRuleInfo {
+	return &alertRuleInfo{
+		Name:       rule.Name,
+		Type:       rule.Type,
+		Queue:      rule.Queue,
+		ForSeconds: int64(rule.For.Seconds()),
+		Severity:   rule.Severity,
+		Threshold:  rule.Threshold,
+		Managed:    managed,
+	}
+}
+
+type alertInfo struct {
+	Rule    string `json:"rule"`
+	Type    string `json:"type"`
+	Subject string `json:"subject"`
+	Message string `json:"message"`
+	// "pending" until the condition has held for the duration of the rule, then "firing".
+	State string `json:"state"`
+	// Time the condition started to hold in RFC3339 format.
+	Since string `json:"since"`
+	// Time the alert fired in RFC3339 format, or empty string if not firing.
+	FiredAt string `json:"fired_at"`
+}
+
+type listAlertsResponse struct {
+	Rules  []*alertRuleInfo `json:"rules"`
+	Alerts []*alertInfo     `json:"alerts"`
+}
+
+// listRules returns the rules configured with Options.AlertRules followed by the rules managed with the API.
+func (e *alertEvaluator) listRules(ctx context.Context) ([]*alertRuleInfo, error) {
+	recs, err := e.store.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]*alertRuleInfo, 0, len(e.rules)+len(recs))
+	for _, rule := range e.rules {
+		rules = append(rules, toAlertRuleInfo(rule, false))
+	}
+	for _, rec := range recs {
+		rules = append(rules, toAlertRuleInfo(rec.rule(), true))
+	}
+	return rules, nil
+}
+
+// filterAlertRules returns the rules which apply to every queue or to a queue for which visible returns true.
+func filterAlertRules(rules []*alertRuleInfo, visible func(qname string) bool) []*alertRuleInfo {
+	out := make([]*alertRuleInfo, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Queue == "" || visible(rule.Queue) {
+			out = append(out, rule)
+		}
+	}
+	return out
+}
+
+// list returns the rules and the alerts which are pending or firing,
+// limited to the queues for which visible returns true.
+func (e *alertEvaluator) list(ctx context.Context, visible func(qname string) bool) (*listAlertsResponse, error) {
+	rules, err := e.listRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	recs, err := e.store.listAlerts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &listAlertsResponse{
+		Rules:  filterAlertRules(rules, visible),
+		Alerts: make([]*alertInfo, 0, len(recs)),
+	}
+	for _, rec := range recs {
+		if rec.Queue != "" && !visible(rec.Queue) {
+			continue
+		}
+		a := rec.alertInfo
+		resp.Alerts = append(resp.Alerts, &a)
+	}
+	sort.Slice(resp.Alerts, func(i, j int) bool {
+		if resp.Alerts[i].Rule == resp.Alerts[j].Rule {
+			return resp.Alerts[i].Subject < resp.Alerts[j].Subject
+		}
+		return resp.Alerts[i].Rule < resp.Alerts[j].Rule
+	})
+	return resp, nil
+}
+
+// newListAlertsHandlerFunc returns a handler which lists the alert rules and
+// the alerts which are pending or firing.
+func newListAlertsHandlerFunc(alerts *alertEvaluator, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := alerts.list(r.Context(), func(qname string) bool { return qa.canView(r, qname) })
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+// Maximum length of the names of alert rules managed with the API.
+const maxAlertRuleNameLen = 128
+
+// Severities allowed for alert rules managed with the API.
+var alertSeverities = map[string]bool{
+	SeverityInfo:     true,
+	SeverityWarning:  true,
+	SeverityCritical: true,
+}
+
+type alertRuleRequest struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Queue      string  `json:"queue"`
+	ForSeconds int64   `json:"for_seconds"`
+	Severity   string  `json:"severity"`
+	Threshold  float64 `json:"threshold"`
+}
+
+// record validates the request and returns the rule to store.
+func (req *alertRuleRequest) record() (*alertRuleRecord, error) {
+	rule := &AlertRule{
+		Name:      strings.TrimSpace(req.Name),
+		Type:      req.Type,
+		Queue:     req.Queue,
+		For:       time.Duration(req.ForSeconds) * time.Second,
+		Severity:  req.Severity,
+		Threshold: req.Threshold,
+	}
+	if err := validateAlertRule(rule); err != nil {
+		return nil, fmt.Errorf("invalid request body: %v", err)
+	}
+	if len(rule.Name) > maxAlertRuleNameLen {
+		return nil, fmt.Errorf("invalid request body: name must not be longer than %d bytes", maxAlertRuleNameLen)
+	}
+	if err := validateIdentifier("alert rule name", rule.Name); err != nil {
+		return nil, fmt.Errorf("invalid request body: %v", err)
+	}
+	if !alertSeverities[rule.Severity] {
+		return nil, fmt.Errorf("invalid request body: severity must be one of info, warning, or critical, got %q", rule.Severity)
+	}
+	return &alertRuleRecord{
+		Name:      rule.Name,
+		Type:      rule.Type,
+		Queue:     rule.Queue,
+		For:       rule.For,
+		Severity:  rule.Severity,
+		Threshold: rule.Threshold,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+type listAlertRulesResponse struct {
+	Rules []*alertRuleInfo `json:"rules"`
+}
+
+func newListAlertRulesHandlerFunc(alerts *alertEvaluator, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules, err := alerts.listRules(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		rules = filterAlertRules(rules, func(qname string) bool { return qa.canView(r, qname) })
+		writeResponseJSON(w, listAlertRulesResponse{Rules: rules})
+	}
+}
+
+// newCreateAlertRuleHandlerFunc returns a handler which creates an alert rule.
+// The rule is evaluated from the next evaluation on.
+func newCreateAlertRuleHandlerFunc(alerts *alertEvaluator, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req alertRuleRequest
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		rec, err := req.record()
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if !qa.checkMutate(w, r, rec.Queue) {
+			return
+		}
+		if alerts.isConfigured(rec.Name) {
+			writeError(w, r, fmt.Errorf("%w: %q is configured with the options of the deployment", errAlertRuleExists, rec.Name))
+			return
+		}
+		if err := alerts.store.create(r.Context(), rec); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		writeResponseJSON(w, toAlertRuleInfo(rec.rule(), true))
+	}
+}
+
+// newUpdateAlertRuleHandlerFunc returns a handler which replaces an alert rule managed with the API.
+// The name in the request body, if any, must match the name in the path.
+func newUpdateAlertRuleHandlerFunc(alerts *alertEvaluator, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["rule_name"]
+		var req alertRuleRequest
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if req.Name == "" {
+			req.Name = name
+		}
+		if req.Name != name {
+			writeBadRequestError(w, r, fmt.Sprintf("invalid request body: name %q does not match the rule %q; rules cannot be renamed", req.Name, name))
+			return
+		}
+		rec, err := req.record()
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if alerts.isConfigured(name) {
+			writeBadRequestError(w, r, fmt.Sprintf("alert rule %q is configured with the options of the deployment and cannot be changed with the API", name))
+			return
+		}
+		old, err := alerts.store.get(r.Context(), name)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if !qa.checkMutate(w, r, old.Queue) || !qa.checkMutate(w, r, rec.Queue) {
+			return
+		}
+		if err := alerts.store.update(r.Context(), rec); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, toAlertRuleInfo(rec.rule(), true))
+	}
+}
+
+func newDeleteAlertRuleHandlerFunc(alerts *alertEvaluator, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["rule_name"]
+		if alerts.isConfigured(name) {
+			writeBadRequestError(w, r, fmt.Sprintf("alert rule %q is configured with the options of the deployment and cannot be deleted with the API", name))
+			return
+		}
+		rec, err := alerts.store.get(r.Context(), name)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if !qa.checkMutate(w, r, rec.Queue) {
+			return
+		}
+		if err := alerts.store.delete(r.Context(), name); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,619 @@
+package asynqmon
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// alertSubjects returns the subjects of the conditions of the rule which hold in the snapshot.
+func alertSubjects(rule *AlertRule, s *alertSnapshot) []string {
+	var subjects []string
+	for _, c := range rule.conditions(s) {
+		subjects = append(subjects, c.subject)
+	}
+	return subjects
+}
+
+// notificationTitles returns the titles of the notifications.
+func notificationTitles(notifs []*Notification) []string {
+	var titles []string
+	for _, n := range notifs {
+		titles = append(titles, n.Title)
+	}
+	return titles
+}
+
+func TestProcessingSilenceConditions(t *testing.T) {
+	worker := &asynq.ServerInfo{ID: "srv1", Host: "host1", Queues: map[string]int{"default": 1}}
+	tests := []struct {
+		desc          string
+		queue         string // queue of the rule
+		q             *asynq.QueueInfo
+		prevProcessed map[string]int
+		servers       []*asynq.ServerInfo
+		want          []string
+	}{
+		{
+			desc:          "pending tasks are not processed",
+			q:             &asynq.QueueInfo{Queue: "default", Pending: 10, Processed: 5},
+			prevProcessed: map[string]int{"default": 5},
+			servers:       []*asynq.ServerInfo{worker},
+			want:          []string{"default"},
+		},
+		{
+			desc: "first evaluation",
+			q:    &asynq.QueueInfo{Queue: "default", Pending: 10, Processed: 5},
+		},
+		{
+			desc:          "tasks are processed",
+			q:             &asynq.QueueInfo{Queue: "default", Pending: 10, Processed: 6},
+			prevProcessed: map[string]int{"default": 5},
+			servers:       []*asynq.ServerInfo{worker},
+		},
+		{
+			desc:          "no pending tasks",
+			q:             &asynq.QueueInfo{Queue: "default", Processed: 5},
+			prevProcessed: map[string]int{"default": 5},
+		},
+		{
+			desc:          "paused queue",
+			q:             &asynq.QueueInfo{Queue: "default", Pending: 10, Processed: 5, Paused: true},
+			prevProcessed: map[string]int{"default": 5},
+		},
+		{
+			desc:          "workers busy with long running tasks",
+			q:             &asynq.QueueInfo{Queue: "default", Pending: 10, Active: 3, Processed: 5},
+			prevProcessed: map[string]int{"default": 5},
+			servers:       []*asynq.ServerInfo{worker},
+		},
+		{
+			desc:          "active tasks without servers",
+			q:             &asynq.QueueInfo{Queue: "default", Pending: 10, Active: 3, Processed: 5},
+			prevProcessed: map[string]int{"default": 5},
+			want:          []string{"default"},
+		},
+		{
+			desc:          "servers processing other queues",
+			q:             &asynq.QueueInfo{Queue: "critical", Pending: 10, Active: 3, Processed: 5},
+			prevProcessed: map[string]int{"critical": 5},
+			servers:       []*asynq.ServerInfo{worker},
+			want:          []string{"critical"},
+		},
+		{
+			desc:          "daily counter reset",
+			q:             &asynq.QueueInfo{Queue: "default", Pending: 10, Processed: 0},
+			prevProcessed: map[string]int{"default": 5000},
+			servers:       []*asynq.ServerInfo{worker},
+		},
+		{
+			desc:          "rule of another queue",
+			queue:         "critical",
+			q:             &asynq.QueueInfo{Queue: "default", Pending: 10, Processed: 5},
+			prevProcessed: map[string]int{"default": 5},
+		},
+	}
+	for _, tc := range tests {
+		rule := &AlertRule{Type: AlertProcessingSilence, Queue: tc.queue}
+		s := &alertSnapshot{queues: []*asynq.QueueInfo{tc.q}, servers: tc.servers, prevProcessed: tc.prevProcessed}
+		if diff := cmp.Diff(tc.want, alertSubjects(rule, s)); diff != "" {
+			t.Errorf("%s: conditions returned diff (-want,+got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+func TestAlertFiresAfterDuration(t *testing.T) {
+	rule := &AlertRule{Name: "silence", Type: AlertProcessingSilence, For: 2 * time.Minute, Severity: SeverityCritical}
+	e := newAlertEvaluator(nil, time.UTC, nil, []*AlertRule{rule}, nil, 30*time.Second, "")
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		desc      string
+		elapsed   time.Duration
+		processed int
+		want      []string // titles of the notifications
+	}{
+		{desc: "first evaluation", elapsed: 0, processed: 5},
+		{desc: "condition starts to hold", elapsed: 30 * time.Second, processed: 5},
+		{desc: "condition holds for less than the duration", elapsed: 2 * time.Minute, processed: 5},
+		{desc: "condition holds for the duration", elapsed: 2*time.Minute + 30*time.Second, processed: 5, want: []string{"[silence] default"}},
+		{desc: "alert keeps firing", elapsed: 3 * time.Minute, processed: 5},
+		{desc: "condition no longer holds", elapsed: 3*time.Minute + 30*time.Second, processed: 6, want: []string{"Resolved: [silence] default"}},
+		{desc: "condition holds again", elapsed: 4 * time.Minute, processed: 6},
+		{desc: "duration starts over", elapsed: 5 * time.Minute, processed: 6},
+		{desc: "condition holds for the duration again", elapsed: 6 * time.Minute, processed: 6, want: []string{"[silence] default"}},
+	}
+	for _, step := range steps {
+		s := &alertSnapshot{
+			time:   start.Add(step.elapsed),
+			queues: []*asynq.QueueInfo{{Queue: "default", Pending: 10, Processed: step.processed}},
+		}
+		if diff := cmp.Diff(step.want, notificationTitles(e.update([]*AlertRule{rule}, s))); diff != "" {
+			t.Errorf("%s: update returned notifications diff (-want,+got):\n%s", step.desc, diff)
+		}
+	}
+}
+
+func TestAlertsOfDeletedRules(t *testing.T) {
+	rule := &AlertRule{Name: "silence", Type: AlertProcessingSilence, Severity: SeverityCritical}
+	e := newAlertEvaluator(nil, time.UTC, nil, nil, nil, 30*time.Second, "")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	snapshot := func() *alertSnapshot {
+		now = now.Add(30 * time.Second)
+		return &alertSnapshot{time: now, queues: []*asynq.QueueInfo{{Queue: "default", Pending: 10, Processed: 5}}}
+	}
+
+	e.update([]*AlertRule{rule}, snapshot())
+	if got := notificationTitles(e.update([]*AlertRule{rule}, snapshot())); len(got) != 1 {
+		t.Fatalf("update returned notifications %v, want the alert to fire", got)
+	}
+	// No resolved notification is sent for the alerts of a deleted rule.
+	if got := notificationTitles(e.update(nil, snapshot())); len(got) != 0 {
+		t.Errorf("update returned notifications %v after the rule was deleted, want none", got)
+	}
+	if len(e.states) != 0 {
+		t.Errorf("evaluator keeps %d alerts of deleted rules", len(e.states))
+	}
+}
+
+func TestServerDisappeared(t *testing.T) {
+	server := func(id, host string, qnames ...string) *asynq.ServerInfo {
+		queues := make(map[string]int)
+		for _, qname := range qnames {
+			queues[qname] = 1
+		}
+		return &asynq.ServerInfo{ID: id, Host: host, PID: 100, Concurrency: 10, Queues: queues}
+	}
+	a := server("a", "host-a", "default")
+	b := server("b", "host-b", "critical", "default")
+	bRestarted := server("b2", "host-b", "critical", "default")
+	bRescheduled := server("b3", "host-c", "default", "critical")
+	other := server("c", "host-d", "low")
+
+	tests := []struct {
+		desc  string
+		queue string // queue of the rule
+		// Servers heartbeating at each evaluation, one hour apart.
+		evaluations [][]*asynq.ServerInfo
+		want        []string
+	}{
+		{
+			desc:        "server stops heartbeating",
+			evaluations: [][]*asynq.ServerInfo{{a, b}, {a}},
+			want:        []string{"host-b:100"},
+		},
+		{
+			desc:        "servers running at the first evaluation",
+			evaluations: [][]*asynq.ServerInfo{{a}},
+		},
+		{
+			desc:        "server restarted on the same host",
+			evaluations: [][]*asynq.ServerInfo{{a, b}, {a}, {a, bRestarted}},
+		},
+		{
+			desc:        "server replaced on another host with the same queues",
+			evaluations: [][]*asynq.ServerInfo{{a, b}, {a}, {a, bRescheduled}},
+		},
+		{
+			desc:        "server started processing other queues",
+			evaluations: [][]*asynq.ServerInfo{{a, b}, {a}, {a, other}},
+			want:        []string{"host-b:100"},
+		},
+		{
+			desc:        "server replaced by a server running before it was lost",
+			evaluations: [][]*asynq.ServerInfo{{a, b, bRescheduled}, {a, bRescheduled}},
+			want:        []string{"host-b:100"},
+		},
+		{
+			// The server is lost at the second evaluation and the last one is more than the retention later.
+			desc:        "lost server is forgotten after the retention",
+			evaluations: append([][]*asynq.ServerInfo{{a, b}}, repeatServers([]*asynq.ServerInfo{a}, int(lostServerRetention/time.Hour)+2)...),
+		},
+		{
+			desc:        "rule of a queue processed by the lost server",
+			queue:       "critical",
+			evaluations: [][]*asynq.ServerInfo{{a, b}, {a}},
+			want:        []string{"host-b:100"},
+		},
+		{
+			desc:        "rule of a queue not processed by the lost server",
+			queue:       "low",
+			evaluations: [][]*asynq.ServerInfo{{a, b}, {a}},
+		},
+	}
+	for _, tc := range tests {
+		rule := &AlertRule{Name: "lost", Type: AlertServerDisappeared, Queue: tc.queue, Severity: SeverityCritical}
+		e := newAlertEvaluator(nil, time.UTC, nil, []*AlertRule{rule}, nil, 30*time.Second, "")
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		var s *alertSnapshot
+		for _, servers := range tc.evaluations {
+			s = &alertSnapshot{time: now, servers: servers}
+			e.update([]*AlertRule{rule}, s)
+			now = now.Add(time.Hour)
+		}
+		if diff := cmp.Diff(tc.want, alertSubjects(rule, s)); diff != "" {
+			t.Errorf("%s: conditions returned diff (-want,+got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+func repeatServers(servers []*asynq.ServerInfo, n int) [][]*asynq.ServerInfo {
+	evaluations := make([][]*asynq.ServerInfo, n)
+	for i := range evaluations {
+		evaluations[i] = servers
+	}
+	return evaluations
+}
+
+func TestServerDisappearedCapacityLoss(t *testing.T) {
+	rule := &AlertRule{Name: "lost", Type: AlertServerDisappeared, Severity: SeverityCritical}
+	e := newAlertEvaluator(nil, time.UTC, nil, []*AlertRule{rule}, nil, 30*time.Second, "")
+	a := &asynq.ServerInfo{ID: "a", Host: "host-a", PID: 1, Queues: map[string]int{"default": 1}}
+	b := &asynq.ServerInfo{ID: "b", Host: "host-b", PID: 2, Concurrency: 10, Queues: map[string]int{"default": 1, "critical": 6}}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	e.update([]*AlertRule{rule}, &alertSnapshot{time: now, servers: []*asynq.ServerInfo{a, b}})
+	s := &alertSnapshot{time: now.Add(time.Minute), servers: []*asynq.ServerInfo{a}}
+	notifs := e.update([]*AlertRule{rule}, s)
+	if len(notifs) != 1 {
+		t.Fatalf("update returned %d notifications, want 1", len(notifs))
+	}
+	want := "Server b (host: host-b, pid: 2, concurrency: 10) stopped heartbeating at 2024-01-01T00:01:00Z. " +
+		"Queues losing capacity: critical (no servers left), default (servers left: 1). The condition has held for 0s."
+	if diff := cmp.Diff(want, notifs[0].Message); diff != "" {
+		t.Errorf("notification message diff (-want,+got):\n%s", diff)
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	var flat ewma
+	for i := 0; i < 50; i++ {
+		flat.add(100)
+	}
+	if flat.mean != 100 || flat.variance != 0 || flat.n != 50 {
+		t.Errorf("moving average of a constant is mean %v, variance %v, n %d; want 100, 0, 50", flat.mean, flat.variance, flat.n)
+	}
+
+	var noisy ewma
+	for i := 0; i < 1000; i++ {
+		noisy.add(float64(100 + 20*(i%2*2-1))) // 80, 120, 80, ...
+	}
+	if math.Abs(noisy.mean-100) > 1 || math.Abs(math.Sqrt(noisy.variance)-20) > 1 {
+		t.Errorf("moving average of values alternating between 80 and 120 is mean %v, stddev %v; want about 100 and 20",
+			noisy.mean, math.Sqrt(noisy.variance))
+	}
+}
+
+func TestQueueMetrics(t *testing.T) {
+	tests := []struct {
+		desc          string
+		q             *asynq.QueueInfo
+		prevProcessed map[string]int
+		prevFailed    map[string]int
+		want          map[string]float64
+	}{
+		{
+			desc: "first evaluation",
+			q:    &asynq.QueueInfo{Queue: "default", Size: 10, Latency: 2 * time.Second, Processed: 100, Failed: 10},
+			want: map[string]float64{anomalyMetricSize: 10, anomalyMetricLatency: 2},
+		},
+		{
+			desc:          "tasks processed since the previous evaluation",
+			q:             &asynq.QueueInfo{Queue: "default", Size: 10, Processed: 150, Failed: 20},
+			prevProcessed: map[string]int{"default": 100},
+			prevFailed:    map[string]int{"default": 10},
+			want:          map[string]float64{anomalyMetricSize: 10, anomalyMetricLatency: 0, anomalyMetricErrorRate: 0.2},
+		},
+		{
+			desc:          "no tasks processed since the previous evaluation",
+			q:             &asynq.QueueInfo{Queue: "default", Size: 10, Processed: 100, Failed: 10},
+			prevProcessed: map[string]int{"default": 100},
+			prevFailed:    map[string]int{"default": 10},
+			want:          map[string]float64{anomalyMetricSize: 10, anomalyMetricLatency: 0},
+		},
+		{
+			desc:          "daily counter reset",
+			q:             &asynq.QueueInfo{Queue: "default", Size: 10, Processed: 40, Failed: 10},
+			prevProcessed: map[string]int{"default": 5000},
+			prevFailed:    map[string]int{"default": 100},
+			want:          map[string]float64{anomalyMetricSize: 10, anomalyMetricLatency: 0, anomalyMetricErrorRate: 0.25},
+		},
+	}
+	for _, tc := range tests {
+		got := queueMetrics(tc.q, tc.prevProcessed, tc.prevFailed)
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%s: queueMetrics returned diff (-want,+got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+func TestAnomalyAlerts(t *testing.T) {
+	if anomalyMinStdDev[anomalyMetricSize] != 10 {
+		t.Fatalf("test cases assume a minimum standard deviation of 10 for the size")
+	}
+	tests := []struct {
+		desc      string
+		threshold float64 // threshold of the rule, default if zero
+		history   []int   // sizes of the queue at the previous evaluations
+		size      int
+		want      []string // titles of the notifications
+	}{
+		{
+			desc:    "unusual size",
+			history: repeatInt(100, anomalyWarmup),
+			size:    10000,
+			want:    []string{"[anomaly] default:size"},
+		},
+		{
+			desc:    "before the moving average is warmed up",
+			history: repeatInt(100, anomalyWarmup-1),
+			size:    10000,
+		},
+		{
+			desc:    "below the threshold of the minimum standard deviation",
+			history: repeatInt(100, anomalyWarmup),
+			size:    120,
+		},
+		{
+			desc:    "at the threshold of the minimum standard deviation",
+			history: repeatInt(100, anomalyWarmup),
+			size:    130,
+			want:    []string{"[anomaly] default:size"},
+		},
+		{
+			desc:      "below a custom threshold",
+			threshold: 5,
+			history:   repeatInt(100, anomalyWarmup),
+			size:      140,
+		},
+		{
+			desc:      "above a custom threshold",
+			threshold: 5,
+			history:   repeatInt(100, anomalyWarmup),
+			size:      160,
+			want:      []string{"[anomaly] default:size"},
+		},
+		{
+			desc:    "usual value of a noisy metric",
+			history: alternateInt(0, 1000, 200),
+			size:    1000,
+		},
+		{
+			desc:    "drop below the average",
+			history: repeatInt(1000, anomalyWarmup),
+			size:    0,
+		},
+	}
+	for _, tc := range tests {
+		rule := &AlertRule{Name: "anomaly", Type: AlertAnomaly, Threshold: tc.threshold}
+		if err := validateAlertRule(rule); err != nil {
+			t.Fatal(err)
+		}
+		e := newAlertEvaluator(nil, time.UTC, nil, []*AlertRule{rule}, nil, 30*time.Second, "")
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		snapshot := func(size int) *alertSnapshot {
+			now = now.Add(30 * time.Second)
+			return &alertSnapshot{time: now, queues: []*asynq.QueueInfo{{Queue: "default", Size: size}}}
+		}
+		for _, size := range tc.history {
+			if got := e.update([]*AlertRule{rule}, snapshot(size)); len(got) != 0 {
+				t.Fatalf("%s: update returned notifications %v for the history", tc.desc, notificationTitles(got))
+			}
+		}
+		if diff := cmp.Diff(tc.want, notificationTitles(e.update([]*AlertRule{rule}, snapshot(tc.size)))); diff != "" {
+			t.Errorf("%s: update returned notifications diff (-want,+got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+func repeatInt(x, n int) []int {
+	xs := make([]int, n)
+	for i := range xs {
+		xs[i] = x
+	}
+	return xs
+}
+
+// alternateInt returns n values alternating between x and y.
+func alternateInt(x, y, n int) []int {
+	xs := make([]int, n)
+	for i := range xs {
+		xs[i] = x
+		if i%2 == 1 {
+			xs[i] = y
+		}
+	}
+	return xs
+}
+
+func TestAlertNotificationsOfCluster(t *testing.T) {
+	rule := &AlertRule{Name: "backlog", Type: AlertQueueSize, Threshold: 100, Severity: SeverityCritical}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	// resolved returns the notification of the alert resolving in the cluster.
+	resolved := func(cluster string) *Notification {
+		e := newAlertEvaluator(nil, time.UTC, nil, []*AlertRule{rule}, nil, 30*time.Second, cluster)
+		e.update([]*AlertRule{rule}, &alertSnapshot{time: now, queues: []*asynq.QueueInfo{{Queue: "default", Pending: 200}}})
+		notifs := e.update([]*AlertRule{rule}, &alertSnapshot{time: now.Add(time.Minute), queues: []*asynq.QueueInfo{{Queue: "default"}}})
+		if len(notifs) != 1 {
+			t.Fatalf("cluster %q: update returned %d notifications, want 1", cluster, len(notifs))
+		}
+		return notifs[0]
+	}
+
+	tests := []struct {
+		desc    string
+		cluster string
+		want    *Notification
+	}{
+		{
+			desc: "single cluster",
+			want: &Notification{
+				Title:    "Resolved: [backlog] default",
+				Message:  "The alert fired at 2024-01-01T12:00:00Z has resolved.",
+				Severity: SeverityInfo,
+				Time:     now.Add(time.Minute),
+				Key:      "asynqmon/backlog/default",
+				Resolved: true,
+			},
+		},
+		{
+			desc:    "alert of a cluster resolved",
+			cluster: "prod-eu",
+			want: &Notification{
+				Title:    "Resolved: [backlog] default (cluster: prod-eu)",
+				Message:  `The alert fired in cluster "prod-eu" at 2024-01-01T12:00:00Z has resolved.`,
+				Severity: SeverityInfo,
+				Time:     now.Add(time.Minute),
+				Key:      "asynqmon/prod-eu/backlog/default",
+				Resolved: true,
+			},
+		},
+	}
+	for _, tc := range tests {
+		if diff := cmp.Diff(tc.want, resolved(tc.cluster)); diff != "" {
+			t.Errorf("%s: notification diff (-want,+got):\n%s", tc.desc, diff)
+		}
+	}
+
+	// The same alert in two clusters has different titles and keys.
+	e := newAlertEvaluator(nil, time.UTC, nil, []*AlertRule{rule}, nil, 30*time.Second, "prod-us")
+	notifs := e.update([]*AlertRule{rule}, &alertSnapshot{time: now, queues: []*asynq.QueueInfo{{Queue: "default", Pending: 200}}})
+	want := []*Notification{{
+		Title:    "[backlog] default (cluster: prod-us)",
+		Message:  `Queue "default" has 200 pending tasks, more than the threshold of 100. The condition has held in cluster "prod-us" for 0s.`,
+		Severity: SeverityCritical,
+		Time:     now,
+		Key:      "asynqmon/prod-us/backlog/default",
+	}}
+	if diff := cmp.Diff(want, notifs); diff != "" {
+		t.Errorf("fired notification diff (-want,+got):\n%s", diff)
+	}
+}
+
+func TestAlertLease(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rc.Close()
+	store := newAlertRuleStore(rc)
+	ctx := context.Background()
+
+	steps := []struct {
+		desc    string
+		elapsed time.Duration // time elapsed since the previous step
+		id      string
+		release bool
+		want    bool // whether the instance holds the lease after acquiring it
+	}{
+		{desc: "a acquires the lease", id: "a", want: true},
+		{desc: "b cannot acquire the lease held by a", id: "b", want: false},
+		{desc: "a renews the lease", elapsed: time.Minute, id: "a", want: true},
+		{desc: "renewed lease has not expired", elapsed: time.Minute, id: "b", want: false},
+		{desc: "b acquires the expired lease", elapsed: 31 * time.Second, id: "b", want: true},
+		{desc: "a lost the lease", id: "a", want: false},
+		{desc: "a cannot release the lease held by b", id: "a", release: true},
+		{desc: "lease is still held by b", id: "a", want: false},
+		{desc: "b releases the lease", id: "b", release: true},
+		{desc: "a acquires the released lease", id: "a", want: true},
+	}
+	for _, step := range steps {
+		mr.FastForward(step.elapsed)
+		if step.release {
+			if err := store.releaseLease(ctx, step.id); err != nil {
+				t.Fatalf("%s: releaseLease returned error: %v", step.desc, err)
+			}
+			continue
+		}
+		got, err := store.acquireLease(ctx, step.id, 90*time.Second)
+		if err != nil {
+			t.Fatalf("%s: acquireLease returned error: %v", step.desc, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: acquireLease(%q) = %t, want %t", step.desc, step.id, got, step.want)
+		}
+	}
+}
+
+func TestAlertEvaluatorTakeover(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rc.Close()
+	store := newAlertRuleStore(rc)
+	ctx := context.Background()
+	rule := &AlertRule{Name: "silence", Type: AlertProcessingSilence, Severity: SeverityCritical}
+	rules := []*AlertRule{rule}
+	a := newAlertEvaluator(nil, time.UTC, store, rules, nil, 30*time.Second, "")
+	b := newAlertEvaluator(nil, time.UTC, store, rules, nil, 30*time.Second, "")
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	snapshot := func(processed int) *alertSnapshot {
+		now = now.Add(30 * time.Second)
+		return &alertSnapshot{time: now, queues: []*asynq.QueueInfo{{Queue: "default", Pending: 10, Processed: processed}}}
+	}
+	lead := func(e *alertEvaluator, want bool, desc string) {
+		t.Helper()
+		got, err := e.lead(ctx, rules)
+		if err != nil {
+			t.Fatalf("%s: lead returned error: %v", desc, err)
+		}
+		if got != want {
+			t.Fatalf("%s: lead = %t, want %t", desc, got, want)
+		}
+	}
+
+	// Only a evaluates the rules and sends the notification.
+	lead(a, true, "a acquires the lease")
+	a.update(rules, snapshot(5))
+	lead(a, true, "a renews the lease")
+	lead(b, false, "b does not evaluate the rules")
+	if diff := cmp.Diff([]string{"[silence] default"}, notificationTitles(a.update(rules, snapshot(5)))); diff != "" {
+		t.Errorf("a returned notifications diff (-want,+got):\n%s", diff)
+	}
+	a.saveAlerts()
+
+	// Every instance lists the alerts of the instance evaluating the rules.
+	resp, err := b.list(ctx, func(string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*alertInfo{{
+		Rule:    "silence",
+		Type:    AlertProcessingSilence,
+		Subject: "default",
+		Message: `Queue "default" has 10 pending tasks but no tasks are being processed (active tasks: 0, servers processing the queue: 0).`,
+		State:   "firing",
+		Since:   "2024-01-01T12:01:00Z",
+		FiredAt: "2024-01-01T12:01:00Z",
+	}}
+	if diff := cmp.Diff(want, resp.Alerts); diff != "" {
+		t.Errorf("b listed alerts diff (-want,+got):\n%s", diff)
+	}
+	if resp, err := b.list(ctx, func(qname string) bool { return qname != "default" }); err != nil || len(resp.Alerts) != 0 {
+		t.Errorf("b listed alerts %v, %v of a queue which is not visible", resp, err)
+	}
+
+	// b takes over when a stops, and carries on with the firing alert.
+	if err := a.stop(); err != nil {
+		t.Fatal(err)
+	}
+	lead(b, true, "b acquires the lease")
+	steps := []struct {
+		desc      string
+		processed int
+		want      []string
+	}{
+		{desc: "first evaluation of b", processed: 5},
+		{desc: "alert does not fire again", processed: 5},
+		{desc: "alert resolves", processed: 6, want: []string{"Resolved: [silence] default"}},
+	}
+	for _, step := range steps {
+		if diff := cmp.Diff(step.want, notificationTitles(b.update(rules, snapshot(step.processed)))); diff != "" {
+			t.Errorf("%s: b returned notifications diff (-want,+got):\n%s", step.desc, diff)
+		}
+	}
+}
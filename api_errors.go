@@ -0,0 +1,224 @@
+package asynqmon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - error codes returned by the API
+//   - helpers to write API error responses
+// ****************************************************************************
+
+// Error codes returned in the "code" field of API error responses.
+// The codes are stable so that clients can branch on them instead of matching
+// error messages.
+const (
+	errCodeBadRequest               = "bad_request"
+	errCodeNotFound                 = "not_found"
+	errCodeMethodNotAllowed         = "method_not_allowed"
+	errCodeQueueNotFound            = "queue_not_found"
+	errCodeQueueNotEmpty            = "queue_not_empty"
+	errCodeTaskNotFound             = "task_not_found"
+	errCodeTaskAlreadyActive        = "task_already_active"
+	errCodeTaskAlreadyPending       = "task_already_pending"
+	errCodeTaskAlreadyArchived      = "task_already_archived"
+	errCodeTaskStateConflict        = "task_state_conflict"
+	errCodeDuplicateTask            = "duplicate_task"
+	errCodeTaskIDConflict           = "task_id_conflict"
+	errCodeSchedulerEntryNotFound   = "scheduler_entry_not_found"
+	errCodeAPITokenNotFound         = "api_token_not_found"
+	errCodeAlertRuleNotFound        = "alert_rule_not_found"
+	errCodeAlertRuleExists          = "alert_rule_exists"
+	errCodeRedisUnavailable         = "redis_unavailable"
+	errCodeRedisTimeout             = "redis_timeout"
+	errCodeRedisReadOnly            = "redis_read_only"
+	errCodePrometheusUnavailable    = "prometheus_unavailable"
+	errCodeBulkThresholdExceeded    = "bulk_threshold_exceeded"
+	errCodeIdempotencyKeyInProgress = "idempotency_key_in_progress"
+	errCodeIdempotencyKeyReused     = "idempotency_key_reused"
+	errCodeUnauthorized             = "unauthorized"
+	errCodeForbidden                = "forbidden"
+	errCodeReadOnly                 = "read_only"
+	errCodeMaintenanceMode          = "maintenance_mode"
+	errCodeInternal                 = "internal"
+)
+
+// Titles of the problem types, keyed by error code.
+var errorTitles = map[string]string{
+	errCodeBadRequest:               "Invalid request",
+	errCodeNotFound:                 "Endpoint not found",
+	errCodeMethodNotAllowed:         "Method not allowed",
+	errCodeQueueNotFound:            "Queue not found",
+	errCodeQueueNotEmpty:            "Queue is not empty",
+	errCodeTaskNotFound:             "Task not found",
+	errCodeTaskAlreadyActive:        "Task is already active",
+	errCodeTaskAlreadyPending:       "Task is already pending",
+	errCodeTaskAlreadyArchived:      "Task is already archived",
+	errCodeTaskStateConflict:        "Task is not in the required state",
+	errCodeDuplicateTask:            "Duplicate task",
+	errCodeTaskIDConflict:           "Task ID already exists",
+	errCodeSchedulerEntryNotFound:   "Scheduler entry not found",
+	errCodeAPITokenNotFound:         "API token not found",
+	errCodeAlertRuleNotFound:        "Alert rule not found",
+	errCodeAlertRuleExists:          "Alert rule already exists",
+	errCodeRedisUnavailable:         "Redis is unavailable",
+	errCodeRedisTimeout:             "Redis timed out",
+	errCodeRedisReadOnly:            "Redis is read-only",
+	errCodePrometheusUnavailable:    "Prometheus is unavailable",
+	errCodeBulkThresholdExceeded:    "Operation affects too many tasks",
+	errCodeIdempotencyKeyInProgress: "Request with the idempotency key is in progress",
+	errCodeIdempotencyKeyReused:     "Idempotency key was used for a different request",
+	errCodeUnauthorized:             "Authentication required",
+	errCodeForbidden:                "Insufficient scope",
+	errCodeReadOnly:                 "Read-only mode",
+	errCodeMaintenanceMode:          "Maintenance mode",
+	errCodeInternal:                 "Internal error",
+}
+
+// Base URI of the problem types. The error code is appended as the fragment.
+const problemTypeBaseURI = "https://github.com/hibiken/asynqmon/blob/master/docs/api-errors.md"
+
+// problemDetails is the body of an API error response.
+// See RFC 7807 (https://www.rfc-editor.org/rfc/rfc7807).
+type problemDetails struct {
+	// URI reference that identifies the problem type.
+	Type string `json:"type"`
+	// Short summary of the problem type.
+	Title string `json:"title"`
+	// HTTP status code.
+	Status int `json:"status"`
+	// Human readable explanation specific to this occurrence of the problem.
+	Detail string `json:"detail"`
+	// URI reference that identifies the specific occurrence of the problem (i.e. the request path).
+	Instance string `json:"instance"`
+
+	// Extension members.
+
+	// Code is one of the error codes defined above.
+	Code string `json:"code"`
+	// RequestID is the ID of the request, also sent in the X-Request-ID header.
+	RequestID string `json:"request_id"`
+	// AffectedCount is the number of tasks the rejected operation would affect.
+	// Only set for bulk_threshold_exceeded errors.
+	AffectedCount *int `json:"affected_count,omitempty"`
+}
+
+// writeErrorResponse writes a problem+json error response with the given status, error code and message.
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, status int, code, msg string) {
+	writeProblem(w, r, newProblemDetails(r, status, code, msg))
+}
+
+func newProblemDetails(r *http.Request, status int, code, msg string) *problemDetails {
+	title, ok := errorTitles[code]
+	if !ok {
+		title = http.StatusText(status)
+	}
+	return &problemDetails{
+		Type:      problemTypeBaseURI + "#" + code,
+		Title:     title,
+		Status:    status,
+		Detail:    msg,
+		Instance:  r.URL.RequestURI(),
+		Code:      code,
+		RequestID: requestIDFromContext(r.Context()),
+	}
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, p *problemDetails) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// writeBulkThresholdError writes an error response for a bulk operation which
+// would affect n tasks, more than the threshold.
+func writeBulkThresholdError(w http.ResponseWriter, r *http.Request, n, threshold int) {
+	p := newProblemDetails(r, http.StatusConflict, errCodeBulkThresholdExceeded,
+		fmt.Sprintf("operation would affect %d tasks which exceeds the threshold of %d: use force=true query parameter to proceed", n, threshold))
+	p.AffectedCount = &n
+	writeProblem(w, r, p)
+}
+
+// writeError writes an error response for err returned from asynq.Inspector or redis.
+// The status and error code are chosen based on the error.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := classifyError(err)
+	writeErrorResponse(w, r, status, code, err.Error())
+}
+
+// writeBadRequestError writes an error response for an invalid request.
+func writeBadRequestError(w http.ResponseWriter, r *http.Request, msg string) {
+	writeErrorResponse(w, r, http.StatusBadRequest, errCodeBadRequest, msg)
+}
+
+// classifyError returns the HTTP status and error code for the given error.
+func classifyError(err error) (status int, code string) {
+	switch {
+	case errors.Is(err, asynq.ErrQueueNotFound):
+		return http.StatusNotFound, errCodeQueueNotFound
+	case errors.Is(err, asynq.ErrTaskNotFound):
+		return http.StatusNotFound, errCodeTaskNotFound
+	case errors.Is(err, asynq.ErrQueueNotEmpty):
+		return http.StatusBadRequest, errCodeQueueNotEmpty
+	case errors.Is(err, asynq.ErrDuplicateTask):
+		return http.StatusConflict, errCodeDuplicateTask
+	case errors.Is(err, asynq.ErrTaskIDConflict):
+		return http.StatusConflict, errCodeTaskIDConflict
+	case errors.Is(err, errSchedulerEntryNotFound):
+		return http.StatusNotFound, errCodeSchedulerEntryNotFound
+	case errors.Is(err, errAPITokenNotFound):
+		return http.StatusNotFound, errCodeAPITokenNotFound
+	case errors.Is(err, errAlertRuleNotFound):
+		return http.StatusNotFound, errCodeAlertRuleNotFound
+	case errors.Is(err, errAlertRuleExists):
+		return http.StatusConflict, errCodeAlertRuleExists
+	case errors.As(err, new(*taskStateError)):
+		return http.StatusConflict, errCodeTaskStateConflict
+	case isRedisReadOnly(err):
+		return http.StatusServiceUnavailable, errCodeRedisReadOnly
+	case isRedisTimeout(err):
+		return http.StatusGatewayTimeout, errCodeRedisTimeout
+	case isRedisUnavailable(err):
+		return http.StatusServiceUnavailable, errCodeRedisUnavailable
+	}
+	// asynq does not export errors for failed preconditions on task state, and
+	// wraps them with %v, so the message is the only thing to go by.
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "task is already running"), strings.Contains(msg, "in active state"):
+		return http.StatusConflict, errCodeTaskAlreadyActive
+	case strings.Contains(msg, "task is already in pending state"):
+		return http.StatusConflict, errCodeTaskAlreadyPending
+	case strings.Contains(msg, "already archived"):
+		return http.StatusConflict, errCodeTaskAlreadyArchived
+	}
+	return http.StatusInternalServerError, errCodeInternal
+}
+
+// isRedisUnavailable reports whether err indicates that the redis server cannot be reached.
+func isRedisUnavailable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, redis.ErrClosed) ||
+		errors.Is(err, io.EOF) {
+		return true
+	}
+	// Some errors are wrapped with %v by asynq.
+	msg := err.Error()
+	for _, s := range []string{"connection refused", "no such host", "connection reset by peer", "redis: client is closed"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package asynqmon
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - http.Handler(s) for API token related endpoints
+// ****************************************************************************
+
+// Maximum length of the name of an API token.
+const maxAPITokenNameLen = 128
+
+type apiToken struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Time the token was created in RFC3339 format.
+	CreatedAt string `json:"created_at"`
+	// Time the token expires in RFC3339 format.
+	// Empty string if the token does not expire.
+	ExpiresAt string `json:"expires_at"`
+	// Time the token was last used in RFC3339 format.
+	// Empty string if the token has never been used.
+	LastUsedAt string `json:"last_used_at"`
+	Expired    bool   `json:"expired"`
+	// Secret value of the token.
+	// Only returned when the token is created or rotated.
+	Token string `json:"token,omitempty"`
+}
+
+func toAPIToken(rec *apiTokenRecord, lastUsed time.Time, now time.Time) *apiToken {
+	t := &apiToken{
+		ID:        rec.ID,
+		Name:      rec.Name,
+		Scopes:    rec.Scopes,
+		CreatedAt: rec.CreatedAt.Format(time.RFC3339),
+		Expired:   rec.expired(now),
+	}
+	if !rec.ExpiresAt.IsZero() {
+		t.ExpiresAt = rec.ExpiresAt.Format(time.RFC3339)
+	}
+	if !lastUsed.IsZero() {
+		t.LastUsedAt = lastUsed.Format(time.RFC3339)
+	}
+	return t
+}
+
+type listAPITokensResponse struct {
+	Tokens []*apiToken `json:"tokens"`
+}
+
+func newListAPITokensHandlerFunc(store *apiTokenStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		recs, lastUsed, err := store.list(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		sort.Slice(recs, func(i, j int) bool { return recs[i].CreatedAt.Before(recs[j].CreatedAt) })
+		now := time.Now()
+		resp := listAPITokensResponse{Tokens: make([]*apiToken, 0, len(recs))}
+		for _, rec := range recs {
+			resp.Tokens = append(resp.Tokens, toAPIToken(rec, lastUsed[rec.ID], now))
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+type createAPITokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Time the token expires in RFC3339 format.
+	// The token does not expire if empty.
+	ExpiresAt string `json:"expires_at"`
+}
+
+func (req *createAPITokenRequest) validate(caller *apiTokenInfo) (expiresAt time.Time, err error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return time.Time{}, fmt.Errorf("invalid request body: name must not be empty")
+	}
+	if len(req.Name) > maxAPITokenNameLen {
+		return time.Time{}, fmt.Errorf("invalid request body: name must not be longer than %d characters", maxAPITokenNameLen)
+	}
+	if len(req.Scopes) == 0 {
+		return time.Time{}, fmt.Errorf("invalid request body: scopes must not be empty")
+	}
+	for _, s := range req.Scopes {
+		sc, err := parseScope(s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid request body: %v", err)
+		}
+		if caller != nil && !caller.allows(sc) {
+			// Prevent tokens from granting more access than they have.
+			return time.Time{}, fmt.Errorf("invalid request body: scope %q exceeds the scopes of %s", s, caller)
+		}
+	}
+	if req.ExpiresAt != "" {
+		expiresAt, err = time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid request body: expires_at must be in RFC3339 format")
+		}
+		if !expiresAt.After(time.Now()) {
+			return time.Time{}, fmt.Errorf("invalid request body: expires_at must be in the future")
+		}
+	}
+	return expiresAt.UTC(), nil
+}
+
+func newCreateAPITokenHandlerFunc(store *apiTokenStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req createAPITokenRequest
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		expiresAt, err := req.validate(apiTokenFromContext(r.Context()))
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		rec, secret, err := store.create(r.Context(), req.Name, req.Scopes, expiresAt)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		resp := toAPIToken(rec, time.Time{}, time.Now())
+		resp.Token = secret
+		w.WriteHeader(http.StatusCreated)
+		writeResponseJSON(w, resp)
+	}
+}
+
+func newRotateAPITokenHandlerFunc(store *apiTokenStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec, secret, err := store.rotate(r.Context(), mux.Vars(r)["token_id"])
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		resp := toAPIToken(rec, time.Time{}, time.Now())
+		resp.Token = secret
+		writeResponseJSON(w, resp)
+	}
+}
+
+func newRevokeAPITokenHandlerFunc(store *apiTokenStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := store.revoke(r.Context(), mux.Vars(r)["token_id"]); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
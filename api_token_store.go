@@ -0,0 +1,329 @@
+package asynqmon
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - apiTokenStore which manages API tokens in redis
+// ****************************************************************************
+
+// Redis keys used by apiTokenStore. They share a hash tag so that they can be
+// updated in a transaction on redis cluster.
+const (
+	// Hash of token ID to JSON encoded apiTokenRecord.
+	apiTokensKey = "asynqmon:{api_tokens}"
+	// Hash of token hash to token ID.
+	apiTokensByHashKey = "asynqmon:{api_tokens}:by_hash"
+	// Hash of token ID to unix time the token was last used.
+	apiTokensLastUsedKey = "asynqmon:{api_tokens}:last_used"
+)
+
+const (
+	// Prefix of the secret values of managed tokens to make them recognizable (e.g. by secret scanners).
+	apiTokenSecretPrefix = "aqm_"
+
+	// Duration for which token lookups are cached in memory.
+	// Revoked tokens may be accepted by other asynqmon instances for this long.
+	apiTokenCacheTTL = 10 * time.Second
+
+	// Minimum interval between updates of the last used time of a token.
+	apiTokenLastUsedInterval = time.Minute
+)
+
+var errAPITokenNotFound = errors.New("API token not found")
+
+// apiTokenRecord is a managed token as stored in redis.
+// Only the hash of the secret is stored.
+type apiTokenRecord struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	// Zero if the token does not expire.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (rec *apiTokenRecord) expired(now time.Time) bool {
+	return !rec.ExpiresAt.IsZero() && !now.Before(rec.ExpiresAt)
+}
+
+// apiTokenStore manages API tokens created through the API.
+// Tokens are stored in redis so that they are shared by every asynqmon instance.
+type apiTokenStore struct {
+	rc redis.UniversalClient
+
+	mu       sync.Mutex
+	cache    map[string]*apiTokenCacheEntry // keyed by token hash
+	lastUsed map[string]time.Time           // keyed by token ID; last time recorded in redis
+}
+
+type apiTokenCacheEntry struct {
+	rec       *apiTokenRecord // nil if the token does not exist
+	fetchedAt time.Time
+}
+
+func newAPITokenStore(rc redis.UniversalClient) *apiTokenStore {
+	return &apiTokenStore{
+		rc:       rc,
+		cache:    make(map[string]*apiTokenCacheEntry),
+		lastUsed: make(map[string]time.Time),
+	}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func newAPITokenSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiTokenSecretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// create stores a new token and returns it along with its secret value.
+func (s *apiTokenStore) create(ctx context.Context, name string, scopes []string, expiresAt time.Time) (*apiTokenRecord, string, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := newAPITokenSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	rec := &apiTokenRecord{
+		ID:        id,
+		Name:      name,
+		Hash:      hashToken(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = s.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, apiTokensKey, rec.ID, data)
+		pipe.HSet(ctx, apiTokensByHashKey, rec.Hash, rec.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return rec, secret, nil
+}
+
+func (s *apiTokenStore) get(ctx context.Context, id string) (*apiTokenRecord, error) {
+	data, err := s.rc.HGet(ctx, apiTokensKey, id).Bytes()
+	if err == redis.Nil {
+		return nil, errAPITokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec apiTokenRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// list returns every token along with the time it was last used.
+func (s *apiTokenStore) list(ctx context.Context) ([]*apiTokenRecord, map[string]time.Time, error) {
+	res, err := s.rc.HGetAll(ctx, apiTokensKey).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	recs := make([]*apiTokenRecord, 0, len(res))
+	for _, data := range res {
+		var rec apiTokenRecord
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			return nil, nil, err
+		}
+		recs = append(recs, &rec)
+	}
+	used, err := s.rc.HGetAll(ctx, apiTokensLastUsedKey).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	lastUsed := make(map[string]time.Time, len(used))
+	for id, v := range used {
+		if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+			lastUsed[id] = time.Unix(sec, 0).UTC()
+		}
+	}
+	return recs, lastUsed, nil
+}
+
+// rotate replaces the secret value of the token and returns the new secret.
+// The old secret stops working immediately on this instance.
+func (s *apiTokenStore) rotate(ctx context.Context, id string) (*apiTokenRecord, string, error) {
+	rec, err := s.get(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := newAPITokenSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	oldHash := rec.Hash
+	rec.Hash = hashToken(secret)
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = s.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, apiTokensKey, rec.ID, data)
+		pipe.HDel(ctx, apiTokensByHashKey, oldHash)
+		pipe.HSet(ctx, apiTokensByHashKey, rec.Hash, rec.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	s.forget(oldHash)
+	return rec, secret, nil
+}
+
+// revoke deletes the token.
+func (s *apiTokenStore) revoke(ctx context.Context, id string) error {
+	rec, err := s.get(ctx, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, apiTokensKey, rec.ID)
+		pipe.HDel(ctx, apiTokensByHashKey, rec.Hash)
+		pipe.HDel(ctx, apiTokensLastUsedKey, rec.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.forget(rec.Hash)
+	return nil
+}
+
+// put stores the token as is, replacing the token with the same ID if any.
+// It is used to import tokens exported from another asynqmon deployment.
+func (s *apiTokenStore) put(ctx context.Context, rec *apiTokenRecord) error {
+	old, err := s.get(ctx, rec.ID)
+	if err != nil && err != errAPITokenNotFound {
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = s.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if old != nil && old.Hash != rec.Hash {
+			pipe.HDel(ctx, apiTokensByHashKey, old.Hash)
+		}
+		pipe.HSet(ctx, apiTokensKey, rec.ID, data)
+		pipe.HSet(ctx, apiTokensByHashKey, rec.Hash, rec.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if old != nil {
+		s.forget(old.Hash)
+	}
+	s.forget(rec.Hash)
+	return nil
+}
+
+func (s *apiTokenStore) forget(hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cache, hash)
+}
+
+// lookup returns the token with the given hash, or nil if there is no such token.
+func (s *apiTokenStore) lookup(ctx context.Context, hash string) (*apiTokenRecord, error) {
+	s.mu.Lock()
+	e, ok := s.cache[hash]
+	s.mu.Unlock()
+	if ok && time.Since(e.fetchedAt) < apiTokenCacheTTL {
+		return e.rec, nil
+	}
+	var rec *apiTokenRecord
+	id, err := s.rc.HGet(ctx, apiTokensByHashKey, hash).Result()
+	switch {
+	case err == redis.Nil:
+	case err != nil:
+		return nil, err
+	default:
+		rec, err = s.get(ctx, id)
+		if err == errAPITokenNotFound {
+			rec = nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.cache) >= maxAPITokenCacheEntries {
+		// Invalid tokens are cached too, so bound the memory usage.
+		s.cache = make(map[string]*apiTokenCacheEntry)
+	}
+	s.cache[hash] = &apiTokenCacheEntry{rec: rec, fetchedAt: time.Now()}
+	return rec, nil
+}
+
+// Maximum number of token lookups cached in memory.
+const maxAPITokenCacheEntries = 1024
+
+// touch records that the token was used. Redis is updated at most once per
+// apiTokenLastUsedInterval for each token.
+func (s *apiTokenStore) touch(id string) {
+	now := time.Now()
+	s.mu.Lock()
+	if now.Sub(s.lastUsed[id]) < apiTokenLastUsedInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastUsed[id] = now
+	s.mu.Unlock()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.rc.HSet(ctx, apiTokensLastUsedKey, id, now.Unix()).Err(); err != nil {
+			log.Printf("error: could not record last used time of API token %s: %v", id, err)
+		}
+	}()
+}
+
+// apiTokenInfo returns the parsed token used by apiAuth.
+func (rec *apiTokenRecord) apiTokenInfo() (*apiTokenInfo, error) {
+	info := &apiTokenInfo{name: rec.Name, id: rec.ID}
+	for _, s := range rec.Scopes {
+		sc, err := parseScope(s)
+		if err != nil {
+			return nil, fmt.Errorf("API token %q: %v", rec.Name, err)
+		}
+		info.scopes = append(info.scopes, sc)
+	}
+	return info, nil
+}
@@ -0,0 +1,365 @@
+package asynqmon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - AuditEntry and AuditSink to record API requests which make changes
+//   - auditLog middleware which records the requests
+//   - http.Handler(s) for audit related endpoints
+// ****************************************************************************
+
+// AuditEntry records an API request which makes changes (e.g. deleting, archiving, or running tasks).
+type AuditEntry struct {
+	// Time when the request was received.
+	Time time.Time `json:"time"`
+
+	// Actor who made the request: "user:<username>" or "token:<name>" if the request is authenticated,
+	// the value of Options.AuditActorHeader if set, or "anonymous" otherwise.
+	Actor string `json:"actor"`
+
+	// Action identifies the operation (e.g. "queue:pause", "pending_tasks:archive_all", "retry_tasks:batch_run").
+	Action string `json:"action"`
+
+	// Cluster is the name of the cluster if the handler monitors multiple clusters.
+	Cluster string `json:"cluster,omitempty"`
+
+	// Queue is the name of the queue the request operates on, if any.
+	Queue string `json:"queue,omitempty"`
+
+	// TaskIDs are the IDs of the tasks the request operates on. It is empty for operations
+	// on all tasks in a state (e.g. "archived_tasks:delete_all").
+	TaskIDs []string `json:"task_ids,omitempty"`
+
+	// Method and Path of the request.
+	Method string `json:"method"`
+	Path   string `json:"path"`
+
+	// Status is the HTTP status code of the response.
+	Status int `json:"status"`
+
+	// RequestID is the ID of the request, also sent in the X-Request-Id response header.
+	RequestID string `json:"request_id,omitempty"`
+
+	// RemoteAddr is the network address of the client.
+	RemoteAddr string `json:"remote_addr"`
+}
+
+// AuditSink records audit entries (e.g. to a log file or a SIEM).
+type AuditSink interface {
+	// Record records the given entry.
+	Record(ctx context.Context, e *AuditEntry) error
+}
+
+// AuditSinkFunc is an adapter to allow the use of ordinary functions as an AuditSink.
+// If f is a function with the appropriate signature, AuditSinkFunc(f) is an AuditSink that calls f.
+type AuditSinkFunc func(context.Context, *AuditEntry) error
+
+// Record calls fn(ctx, e)
+func (fn AuditSinkFunc) Record(ctx context.Context, e *AuditEntry) error {
+	return fn(ctx, e)
+}
+
+// NewJSONAuditSink returns an AuditSink which writes each entry to w as a line of JSON,
+// which can be used to record entries to a structured log or a file.
+func NewJSONAuditSink(w io.Writer) AuditSink {
+	var mu sync.Mutex
+	return AuditSinkFunc(func(ctx context.Context, e *AuditEntry) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, err = w.Write(append(data, '\n'))
+		return err
+	})
+}
+
+const (
+	// Key of the redis stream holding audit entries if Options.AuditRedisStream is set.
+	auditStreamKey = "asynqmon:audit"
+
+	// Approximate maximum number of entries kept in the redis stream.
+	auditStreamMaxLen = 100000
+
+	// Number of recent entries kept in memory if Options.AuditRedisStream is not set.
+	maxAuditEntriesInMemory = 1000
+
+	// Maximum number of entries scanned to respond to a query.
+	maxAuditQueryScan = 10000
+
+	// Default and maximum number of entries in a response of /api/audit.
+	defaultAuditQueryLimit = 100
+	maxAuditQueryLimit     = 1000
+
+	// Timeout for recording an entry to redis and the sinks.
+	auditRecordTimeout = 5 * time.Second
+)
+
+// auditLog records every API request which makes changes to the sinks, and keeps
+// recent entries in a redis stream or in memory so that they can be queried.
+type auditLog struct {
+	sinks       []AuditSink
+	rc          redis.UniversalClient // nil unless entries are kept in a redis stream
+	actorHeader string
+	cluster     string
+
+	mu     sync.Mutex
+	recent []*AuditEntry // ring buffer of the entries kept in memory
+	next   int           // index in recent to write the next entry to
+}
+
+func newAuditLog(sinks []AuditSink, rc redis.UniversalClient, actorHeader, cluster string) *auditLog {
+	return &auditLog{sinks: sinks, rc: rc, actorHeader: actorHeader, cluster: cluster}
+}
+
+func (a *auditLog) middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if r.Method == "GET" || r.Method == "" || route == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		vars := mux.Vars(r)
+		e := &AuditEntry{
+			Time:       time.Now().UTC(),
+			Actor:      a.actor(r),
+			Action:     auditAction(tmpl, r.Method),
+			Cluster:    a.cluster,
+			Queue:      vars["qname"],
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			RequestID:  requestIDFromContext(r.Context()),
+			RemoteAddr: r.RemoteAddr,
+		}
+		if id, ok := vars["task_id"]; ok {
+			e.TaskIDs = []string{id}
+		}
+		if strings.Contains(tmpl, ":batch_") {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+			if err != nil {
+				writeBadRequestError(w, r, fmt.Sprintf("invalid request body: %v", err))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			var req struct {
+				TaskIDs []string `json:"task_ids"`
+			}
+			// Let the handler report invalid request bodies.
+			json.Unmarshal(body, &req)
+			e.TaskIDs = req.TaskIDs
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		e.Status = rec.status
+
+		// Record the entry even if the client has gone away.
+		ctx, cancel := context.WithTimeout(context.Background(), auditRecordTimeout)
+		defer cancel()
+		a.record(ctx, e)
+	})
+}
+
+// actor returns the actor who made the request.
+func (a *auditLog) actor(r *http.Request) string {
+	if info := apiTokenFromContext(r.Context()); info != nil {
+		if info.role != "" {
+			return "user:" + info.name
+		}
+		return "token:" + info.name
+	}
+	if a.actorHeader != "" {
+		if v := r.Header.Get(a.actorHeader); v != "" {
+			return v
+		}
+	}
+	return "anonymous"
+}
+
+func (a *auditLog) record(ctx context.Context, e *AuditEntry) {
+	if a.rc != nil {
+		data, err := json.Marshal(e)
+		if err == nil {
+			err = a.rc.XAdd(ctx, &redis.XAddArgs{
+				Stream: auditStreamKey,
+				MaxLen: auditStreamMaxLen,
+				Approx: true,
+				Values: map[string]interface{}{"entry": data},
+			}).Err()
+		}
+		if err != nil {
+			log.Printf("error: could not record audit entry of request %s: %v", e.RequestID, err)
+		}
+	} else {
+		a.mu.Lock()
+		if len(a.recent) < maxAuditEntriesInMemory {
+			a.recent = append(a.recent, e)
+		} else {
+			a.recent[a.next] = e
+		}
+		a.next = (a.next + 1) % maxAuditEntriesInMemory
+		a.mu.Unlock()
+	}
+	for _, s := range a.sinks {
+		if err := s.Record(ctx, e); err != nil {
+			log.Printf("error: could not record audit entry of request %s: %v", e.RequestID, err)
+		}
+	}
+}
+
+// auditFilter selects entries returned by query. Empty fields match every entry.
+type auditFilter struct {
+	actor  string
+	action string
+	queue  string
+	// If set, entries of queues for which visible returns false are left out.
+	visible func(qname string) bool
+}
+
+func (f *auditFilter) match(e *AuditEntry) bool {
+	return (f.actor == "" || e.Actor == f.actor) &&
+		(f.action == "" || e.Action == f.action) &&
+		(f.queue == "" || e.Queue == f.queue) &&
+		(f.visible == nil || e.Queue == "" || f.visible(e.Queue))
+}
+
+// query returns at most limit entries matching the filter, the most recent first.
+func (a *auditLog) query(ctx context.Context, f *auditFilter, limit int) ([]*AuditEntry, error) {
+	entries := make([]*AuditEntry, 0)
+	if a.rc == nil {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		for i := 1; i <= len(a.recent) && len(entries) < limit; i++ {
+			e := a.recent[(a.next-i+len(a.recent))%len(a.recent)]
+			if f.match(e) {
+				entries = append(entries, e)
+			}
+		}
+		return entries, nil
+	}
+	msgs, err := a.rc.XRevRangeN(ctx, auditStreamKey, "+", "-", maxAuditQueryScan).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range msgs {
+		s, ok := msg.Values["entry"].(string)
+		if !ok {
+			continue
+		}
+		var e AuditEntry
+		if err := json.Unmarshal([]byte(s), &e); err != nil {
+			continue
+		}
+		if f.match(&e) {
+			entries = append(entries, &e)
+			if len(entries) == limit {
+				break
+			}
+		}
+	}
+	return entries, nil
+}
+
+// auditAction returns the action of the endpoint with the given path template and method.
+// The action is the last resource in the path followed by the verb after the colon, or
+// a verb for the method (e.g. "DELETE /api/queues/{qname}/pending_tasks/{task_id}" is "pending_tasks:delete").
+func auditAction(tmpl, method string) string {
+	if i := strings.Index(tmpl, "/api/"); i >= 0 {
+		tmpl = tmpl[i+len("/api"):]
+	}
+	var verb string
+	if i := strings.LastIndex(tmpl, ":"); i > strings.LastIndex(tmpl, "/") {
+		tmpl, verb = tmpl[:i], tmpl[i+1:]
+	}
+	if verb == "" {
+		switch method {
+		case "POST":
+			verb = "create"
+		case "DELETE":
+			verb = "delete"
+		default:
+			verb = "update"
+		}
+	}
+	resource := "queue"
+	for _, seg := range strings.Split(strings.TrimPrefix(tmpl, "/queues/{qname}"), "/") {
+		if seg != "" && !strings.HasPrefix(seg, "{") {
+			resource = seg
+		}
+	}
+	return resource + ":" + verb
+}
+
+// statusRecorder records the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher so that streaming responses are not buffered.
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+type listAuditEntriesResponse struct {
+	Entries []*AuditEntry `json:"entries"`
+}
+
+// newListAuditEntriesHandlerFunc returns a handler which responds with recent audit entries,
+// optionally filtered by the actor, action, and queue query parameters.
+func newListAuditEntriesHandlerFunc(audit *auditLog, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limit := defaultAuditQueryLimit
+		if s := q.Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > maxAuditQueryLimit {
+				writeBadRequestError(w, r, fmt.Sprintf("invalid query parameter: limit must be an integer between 1 and %d, got %q", maxAuditQueryLimit, s))
+				return
+			}
+			limit = n
+		}
+		f := &auditFilter{actor: q.Get("actor"), action: q.Get("action"), queue: q.Get("queue")}
+		if qa != nil {
+			f.visible = func(qname string) bool { return qa.canView(r, qname) }
+		}
+		entries, err := audit.query(r.Context(), f, limit)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, listAuditEntriesResponse{Entries: entries})
+	}
+}
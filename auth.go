@@ -0,0 +1,380 @@
+package asynqmon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - APIToken and scopes which restrict access to the API
+//   - apiAuth middleware which authenticates API requests and the Web UI
+// ****************************************************************************
+
+// APIToken is a bearer token which grants access to the API.
+// Clients send the token in the "Authorization: Bearer <token>" header.
+type APIToken struct {
+	// Name identifies the token (e.g. the name of the integration using it).
+	Name string
+
+	// Token is the secret value of the token.
+	Token string
+
+	// Scopes restrict the endpoints the token can access.
+	// Each scope is in "<group>:<access>" format (e.g. "queues:read", "tasks:write").
+	//
+	// Groups are "queues", "tasks", "servers", "schedulers", "redis", "metrics", "plugins", and "system"
+	// (maintenance mode, notifiers, alerts, API tokens, and settings), or "*" for all groups.
+	// Access is one of "read", "write", or "admin"; each access level includes the lower ones.
+	// Destructive operations such as deleting a queue or deleting all tasks require "admin".
+	Scopes []string
+}
+
+// Groups of API endpoints used in scopes.
+const (
+	scopeGroupQueues     = "queues"
+	scopeGroupTasks      = "tasks"
+	scopeGroupServers    = "servers"
+	scopeGroupSchedulers = "schedulers"
+	scopeGroupRedis      = "redis"
+	scopeGroupMetrics    = "metrics"
+	scopeGroupPlugins    = "plugins"
+	scopeGroupSystem     = "system"
+	scopeGroupAll        = "*"
+)
+
+// Access levels used in scopes, in increasing order.
+var scopeAccessLevels = map[string]int{
+	"read":  1,
+	"write": 2,
+	"admin": 3,
+}
+
+var scopeGroups = map[string]bool{
+	scopeGroupQueues:     true,
+	scopeGroupTasks:      true,
+	scopeGroupServers:    true,
+	scopeGroupSchedulers: true,
+	scopeGroupRedis:      true,
+	scopeGroupMetrics:    true,
+	scopeGroupPlugins:    true,
+	scopeGroupSystem:     true,
+	scopeGroupAll:        true,
+}
+
+// scope is a parsed "<group>:<access>" scope.
+type scope struct {
+	group string
+	level int
+}
+
+func (s scope) String() string {
+	for name, level := range scopeAccessLevels {
+		if level == s.level {
+			return s.group + ":" + name
+		}
+	}
+	return s.group
+}
+
+func parseScope(s string) (scope, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return scope{}, fmt.Errorf("scope %q must be in \"<group>:<access>\" format", s)
+	}
+	if !scopeGroups[parts[0]] {
+		return scope{}, fmt.Errorf("scope %q has unknown group %q", s, parts[0])
+	}
+	level, ok := scopeAccessLevels[parts[1]]
+	if !ok {
+		return scope{}, fmt.Errorf("scope %q has unknown access %q; must be one of read, write, or admin", s, parts[1])
+	}
+	return scope{group: parts[0], level: level}, nil
+}
+
+// apiTokenInfo is a token whose scopes have been parsed, or a signed in user.
+type apiTokenInfo struct {
+	name   string
+	id     string // ID of the token in apiTokenStore; empty for static tokens
+	role   string // Role of the user; empty for tokens
+	scopes []scope
+}
+
+func (t *apiTokenInfo) String() string {
+	if t.role != "" {
+		return fmt.Sprintf("user %q", t.name)
+	}
+	return fmt.Sprintf("API token %q", t.name)
+}
+
+// allows reports whether the token has the required scope.
+func (t *apiTokenInfo) allows(required scope) bool {
+	for _, s := range t.scopes {
+		if (s.group == scopeGroupAll || s.group == required.group) && s.level >= required.level {
+			return true
+		}
+	}
+	return false
+}
+
+// hashToken returns the key used to look up a token.
+// Tokens are only compared by hash so that lookups do not leak the secret through timing.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiAuth authenticates API requests with bearer tokens, basic authentication,
+// or session cookies, and checks that the token or user has the scope required by the endpoint.
+type apiAuth struct {
+	tokens map[string]*apiTokenInfo // keyed by hashToken
+	// Tokens managed with the API; used if the token is not one of the static tokens.
+	store *apiTokenStore
+	// Users who sign in with basic authentication; nil if not configured.
+	users *userAuth
+	// Provider users sign in with; nil if not configured.
+	oidc *oidcProvider
+}
+
+func newAPIAuth(tokens []APIToken) (*apiAuth, error) {
+	a := &apiAuth{tokens: make(map[string]*apiTokenInfo, len(tokens))}
+	for _, t := range tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("API token %q has empty value", t.Name)
+		}
+		info := &apiTokenInfo{name: t.Name}
+		for _, s := range t.Scopes {
+			sc, err := parseScope(s)
+			if err != nil {
+				return nil, fmt.Errorf("API token %q: %v", t.Name, err)
+			}
+			info.scopes = append(info.scopes, sc)
+		}
+		a.tokens[hashToken(t.Token)] = info
+	}
+	return a, nil
+}
+
+// lookup returns the token with the given hash, or nil if the token does not exist or has expired.
+func (a *apiAuth) lookup(ctx context.Context, hash string) (*apiTokenInfo, error) {
+	if info, ok := a.tokens[hash]; ok {
+		return info, nil
+	}
+	if a.store == nil {
+		return nil, nil
+	}
+	rec, err := a.store.lookup(ctx, hash)
+	if err != nil || rec == nil || rec.expired(time.Now()) {
+		return nil, err
+	}
+	info, err := rec.apiTokenInfo()
+	if err != nil {
+		return nil, err
+	}
+	a.store.touch(rec.ID)
+	return info, nil
+}
+
+// bearerToken returns the token in the Authorization header of r.
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) <= len(prefix) || !strings.EqualFold(h[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(h[len(prefix):]), true
+}
+
+type apiTokenKey struct{}
+
+// apiTokenFromContext returns the token or user which authenticated the request, or nil if none.
+func apiTokenFromContext(ctx context.Context) *apiTokenInfo {
+	info, _ := ctx.Value(apiTokenKey{}).(*apiTokenInfo)
+	return info
+}
+
+// authenticate returns the token or user which made the request.
+// If the request is not authenticated, it returns nil and the reason.
+func (a *apiAuth) authenticate(r *http.Request) (info *apiTokenInfo, reason string, err error) {
+	if token, ok := bearerToken(r); ok {
+		info, err := a.lookup(r.Context(), hashToken(token))
+		if err != nil || info != nil {
+			return info, "", err
+		}
+		return nil, "API token is invalid or expired", nil
+	}
+	if a.users != nil {
+		if _, _, ok := r.BasicAuth(); ok {
+			if info := a.users.authenticate(r); info != nil {
+				return info, "", nil
+			}
+			return nil, "username or password is invalid", nil
+		}
+	}
+	if a.oidc != nil {
+		if info := a.oidc.session(r); info != nil {
+			return info, "", nil
+		}
+	}
+	if a.users == nil && a.oidc == nil {
+		return nil, "API token is required in Authorization header", nil
+	}
+	return nil, "authentication is required", nil
+}
+
+// challenge sets the WWW-Authenticate headers of a response to an unauthenticated request.
+func (a *apiAuth) challenge(w http.ResponseWriter, r *http.Request) {
+	if _, ok := bearerToken(r); ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="asynqmon", error="invalid_token"`)
+	} else {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="asynqmon"`)
+	}
+	if a.users != nil {
+		w.Header().Add("WWW-Authenticate", `Basic realm="asynqmon", charset="UTF-8"`)
+	}
+}
+
+// uiMiddleware requires users to sign in to view the Web UI if users or an
+// OIDC provider are configured. Users who are not signed in are redirected to
+// the provider, or asked for their username and password.
+func (a *apiAuth) uiMiddleware(h http.Handler) http.Handler {
+	if a == nil || (a.users == nil && a.oidc == nil) {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, _, err := a.authenticate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if info == nil {
+			if a.oidc != nil && (a.users == nil || r.Header.Get("Authorization") == "") {
+				http.Redirect(w, r, a.oidc.loginURL(r), http.StatusFound)
+				return
+			}
+			a.challenge(w, r)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiTokenKey{}, info)))
+	})
+}
+
+func (a *apiAuth) middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		tmpl, _ := route.GetPathTemplate()
+		// Health endpoint is public so that it can be used by probes.
+		if strings.HasSuffix(tmpl, "/api/health") {
+			h.ServeHTTP(w, r)
+			return
+		}
+		info, reason, err := a.authenticate(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if info == nil {
+			a.challenge(w, r)
+			writeErrorResponse(w, r, http.StatusUnauthorized, errCodeUnauthorized, reason)
+			return
+		}
+		if _, bearer := bearerToken(r); !bearer && !isSafeMethod(r.Method) && !isSameOriginRequest(r) {
+			// Browsers send basic auth credentials and session cookies along with
+			// requests forged by other sites, but API tokens have to be set by the page.
+			writeErrorResponse(w, r, http.StatusForbidden, errCodeForbidden,
+				fmt.Sprintf("cross-site request rejected: requests signed in with a password or session must come from the same origin or have the %s header", csrfHeader))
+			return
+		}
+		required := requiredScope(tmpl, r.Method)
+		if !info.allows(required) {
+			msg := fmt.Sprintf("%s does not have the scope %q required for %s %s", info, required, r.Method, r.URL.Path)
+			if info.role != "" {
+				msg = fmt.Sprintf("%s with role %q is not allowed to %s %s", info, info.role, r.Method, r.URL.Path)
+			}
+			writeErrorResponse(w, r, http.StatusForbidden, errCodeForbidden, msg)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiTokenKey{}, info)))
+	})
+}
+
+// Header sent by the Web UI with API requests. Other sites cannot send custom
+// headers along with the credentials of the user without a CORS policy allowing them.
+const csrfHeader = "X-Requested-With"
+
+// isSafeMethod reports whether requests with the method do not make changes.
+func isSafeMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == ""
+}
+
+// isSameOriginRequest reports whether the request has the header sent by the Web UI,
+// or was sent by a page of the same origin according to the Sec-Fetch-Site or Origin header.
+func isSameOriginRequest(r *http.Request) bool {
+	if r.Header.Get(csrfHeader) != "" {
+		return true
+	}
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin"
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
+	}
+	return false
+}
+
+// requiredScope returns the scope required to call the endpoint with the
+// given path template (e.g. "/api/queues/{qname}") and method.
+func requiredScope(tmpl, method string) scope {
+	if i := strings.Index(tmpl, "/api/"); i >= 0 {
+		tmpl = tmpl[i+len("/api"):]
+	}
+	var group string
+	switch {
+	case strings.HasPrefix(tmpl, "/queues/{qname}/"), strings.HasPrefix(tmpl, "/task_policies"),
+		strings.HasPrefix(tmpl, liveUpdatesPath): // the stream includes active tasks
+		group = scopeGroupTasks
+	case strings.HasPrefix(tmpl, "/queue"):
+		group = scopeGroupQueues
+	case strings.HasPrefix(tmpl, "/servers"):
+		group = scopeGroupServers
+	case strings.HasPrefix(tmpl, "/scheduler"):
+		group = scopeGroupSchedulers
+	case strings.HasPrefix(tmpl, "/redis_"):
+		group = scopeGroupRedis
+	case strings.HasPrefix(tmpl, "/metrics"):
+		group = scopeGroupMetrics
+	case strings.HasPrefix(tmpl, "/plugins"):
+		group = scopeGroupPlugins
+	default:
+		group = scopeGroupSystem
+	}
+	level := scopeAccessLevels["read"]
+	switch {
+	case strings.HasPrefix(tmpl, "/tokens"), strings.HasPrefix(tmpl, "/settings"):
+		// Tokens grant access to everything else, so managing them (including
+		// exporting and importing them with settings) always requires admin.
+		level = scopeAccessLevels["admin"]
+	case isSafeMethod(method):
+	case group == scopeGroupSystem,
+		method == "DELETE" && tmpl == "/queues/{qname}",
+		strings.HasSuffix(tmpl, ":delete_all"), strings.HasSuffix(tmpl, ":delete_matching"):
+		level = scopeAccessLevels["admin"]
+	default:
+		level = scopeAccessLevels["write"]
+	}
+	return scope{group: group, level: level}
+}
@@ -0,0 +1,250 @@
+package asynqmon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+)
+
+// Scopes required by the API endpoints. Every endpoint must be listed here
+// so that a new endpoint does not silently fall into a group or level it was not meant for.
+var requiredScopeTests = []struct {
+	method string
+	tmpl   string
+	want   string
+}{
+	{"GET", "/api/queues", "queues:read"},
+	{"GET", "/api/queues/{qname}", "queues:read"},
+	{"DELETE", "/api/queues/{qname}", "queues:admin"},
+	{"POST", "/api/queues/{qname}:pause", "queues:write"},
+	{"POST", "/api/queues/{qname}:resume", "queues:write"},
+	{"GET", "/api/queue_stats", "queues:read"},
+	{"GET", "/api/queues/{qname}/payload_stats", "tasks:read"},
+	{"GET", "/api/queue_growth", "queues:read"},
+	{"GET", "/api/queue_drain", "queues:read"},
+	{"GET", "/api/queue_capacity_report", "queues:read"},
+	{"GET", "/api/queues/{qname}/active_tasks", "tasks:read"},
+	{"POST", "/api/queues/{qname}/active_tasks/{task_id}:cancel", "tasks:write"},
+	{"POST", "/api/queues/{qname}/active_tasks:cancel_all", "tasks:write"},
+	{"POST", "/api/queues/{qname}/active_tasks:batch_cancel", "tasks:write"},
+	{"GET", "/api/queues/{qname}/pending_tasks", "tasks:read"},
+	{"DELETE", "/api/queues/{qname}/pending_tasks/{task_id}", "tasks:write"},
+	{"DELETE", "/api/queues/{qname}/pending_tasks:delete_all", "tasks:admin"},
+	{"POST", "/api/queues/{qname}/pending_tasks:batch_delete", "tasks:write"},
+	{"POST", "/api/queues/{qname}/pending_tasks/{task_id}:archive", "tasks:write"},
+	{"POST", "/api/queues/{qname}/pending_tasks/{task_id}:prioritize", "tasks:write"},
+	{"POST", "/api/queues/{qname}/pending_tasks:archive_all", "tasks:write"},
+	{"POST", "/api/queues/{qname}/pending_tasks:batch_archive", "tasks:write"},
+	{"POST", "/api/queues/{qname}/pending_tasks:schedule_all", "tasks:write"},
+	{"POST", "/api/queues/{qname}/pending_tasks:batch_schedule", "tasks:write"},
+	{"POST", "/api/queues/{qname}/pending_tasks:delete_matching", "tasks:admin"},
+	{"POST", "/api/queues/{qname}/pending_tasks:archive_matching", "tasks:write"},
+	{"GET", "/api/queues/{qname}/scheduled_tasks", "tasks:read"},
+	{"DELETE", "/api/queues/{qname}/scheduled_tasks/{task_id}", "tasks:write"},
+	{"DELETE", "/api/queues/{qname}/scheduled_tasks:delete_all", "tasks:admin"},
+	{"POST", "/api/queues/{qname}/scheduled_tasks:batch_delete", "tasks:write"},
+	{"POST", "/api/queues/{qname}/scheduled_tasks/{task_id}:run", "tasks:write"},
+	{"POST", "/api/queues/{qname}/scheduled_tasks/{task_id}:reschedule", "tasks:write"},
+	{"POST", "/api/queues/{qname}/scheduled_tasks:run_all", "tasks:write"},
+	{"POST", "/api/queues/{qname}/scheduled_tasks:batch_run", "tasks:write"},
+	{"POST", "/api/queues/{qname}/scheduled_tasks/{task_id}:archive", "tasks:write"},
+	{"POST", "/api/queues/{qname}/scheduled_tasks:archive_all", "tasks:write"},
+	{"POST", "/api/queues/{qname}/scheduled_tasks:batch_archive", "tasks:write"},
+	{"POST", "/api/queues/{qname}/scheduled_tasks:delete_matching", "tasks:admin"},
+	{"POST", "/api/queues/{qname}/scheduled_tasks:run_matching", "tasks:write"},
+	{"POST", "/api/queues/{qname}/scheduled_tasks:archive_matching", "tasks:write"},
+	{"GET", "/api/queues/{qname}/retry_tasks", "tasks:read"},
+	{"DELETE", "/api/queues/{qname}/retry_tasks/{task_id}", "tasks:write"},
+	{"DELETE", "/api/queues/{qname}/retry_tasks:delete_all", "tasks:admin"},
+	{"POST", "/api/queues/{qname}/retry_tasks:batch_delete", "tasks:write"},
+	{"POST", "/api/queues/{qname}/retry_tasks/{task_id}:run", "tasks:write"},
+	{"POST", "/api/queues/{qname}/retry_tasks/{task_id}:reschedule", "tasks:write"},
+	{"POST", "/api/queues/{qname}/retry_tasks:run_all", "tasks:write"},
+	{"POST", "/api/queues/{qname}/retry_tasks:batch_run", "tasks:write"},
+	{"POST", "/api/queues/{qname}/retry_tasks/{task_id}:archive", "tasks:write"},
+	{"POST", "/api/queues/{qname}/retry_tasks:archive_all", "tasks:write"},
+	{"POST", "/api/queues/{qname}/retry_tasks:batch_archive", "tasks:write"},
+	{"POST", "/api/queues/{qname}/retry_tasks:delete_matching", "tasks:admin"},
+	{"POST", "/api/queues/{qname}/retry_tasks:run_matching", "tasks:write"},
+	{"POST", "/api/queues/{qname}/retry_tasks:archive_matching", "tasks:write"},
+	{"GET", "/api/queues/{qname}/archived_tasks", "tasks:read"},
+	{"DELETE", "/api/queues/{qname}/archived_tasks/{task_id}", "tasks:write"},
+	{"DELETE", "/api/queues/{qname}/archived_tasks:delete_all", "tasks:admin"},
+	{"POST", "/api/queues/{qname}/archived_tasks:batch_delete", "tasks:write"},
+	{"POST", "/api/queues/{qname}/archived_tasks/{task_id}:run", "tasks:write"},
+	{"POST", "/api/queues/{qname}/archived_tasks:run_all", "tasks:write"},
+	{"POST", "/api/queues/{qname}/archived_tasks:batch_run", "tasks:write"},
+	{"POST", "/api/queues/{qname}/archived_tasks:delete_matching", "tasks:admin"},
+	{"POST", "/api/queues/{qname}/archived_tasks:run_matching", "tasks:write"},
+	{"GET", "/api/queues/{qname}/completed_tasks", "tasks:read"},
+	{"DELETE", "/api/queues/{qname}/completed_tasks/{task_id}", "tasks:write"},
+	{"DELETE", "/api/queues/{qname}/completed_tasks:delete_all", "tasks:admin"},
+	{"POST", "/api/queues/{qname}/completed_tasks:batch_delete", "tasks:write"},
+	{"POST", "/api/queues/{qname}/completed_tasks:delete_matching", "tasks:admin"},
+	{"GET", "/api/queues/{qname}/groups/{gname}/aggregating_tasks", "tasks:read"},
+	{"DELETE", "/api/queues/{qname}/groups/{gname}/aggregating_tasks/{task_id}", "tasks:write"},
+	{"DELETE", "/api/queues/{qname}/groups/{gname}/aggregating_tasks:delete_all", "tasks:admin"},
+	{"POST", "/api/queues/{qname}/groups/{gname}/aggregating_tasks:batch_delete", "tasks:write"},
+	{"POST", "/api/queues/{qname}/groups/{gname}/aggregating_tasks/{task_id}:run", "tasks:write"},
+	{"POST", "/api/queues/{qname}/groups/{gname}/aggregating_tasks:run_all", "tasks:write"},
+	{"POST", "/api/queues/{qname}/groups/{gname}/aggregating_tasks:batch_run", "tasks:write"},
+	{"POST", "/api/queues/{qname}/groups/{gname}/aggregating_tasks/{task_id}:archive", "tasks:write"},
+	{"POST", "/api/queues/{qname}/groups/{gname}/aggregating_tasks:archive_all", "tasks:write"},
+	{"POST", "/api/queues/{qname}/groups/{gname}/aggregating_tasks:batch_archive", "tasks:write"},
+	{"POST", "/api/queues/{qname}/tasks", "tasks:write"},
+	{"GET", "/api/queues/{qname}/tasks/{task_id}", "tasks:read"},
+	{"POST", "/api/queues/{qname}/tasks/{task_id}:move", "tasks:write"},
+	{"POST", "/api/queues/{qname}/tasks:batch_move", "tasks:write"},
+	{"GET", "/api/queues/{qname}/{state:pending|active|scheduled|retry|archived|completed}/export", "tasks:read"},
+	{"POST", "/api/queues/{qname}/tasks:import", "tasks:write"},
+	{"GET", "/api/task_policies", "tasks:read"},
+	{"GET", "/api/queues/{qname}/groups", "tasks:read"},
+	{"GET", "/api/servers", "servers:read"},
+	{"GET", "/api/servers/utilization", "servers:read"},
+	{"GET", "/api/servers/history", "servers:read"},
+	{"GET", "/api/scheduler_entries", "schedulers:read"},
+	{"GET", "/api/scheduler_entries/{entry_id}/enqueue_events", "schedulers:read"},
+	{"POST", "/api/scheduler_entries/{entry_id}:run", "schedulers:write"},
+	{"GET", "/api/scheduler_enqueue_failures", "schedulers:read"},
+	{"GET", "/api/redis_info", "redis:read"},
+	{"GET", "/api/redis_latency", "redis:read"},
+	{"GET", "/api/redis_failovers", "redis:read"},
+	{"GET", "/api/notifiers", "system:read"},
+	{"POST", "/api/notifiers:test", "system:admin"},
+	{"GET", "/api/alerts", "system:read"},
+	{"GET", "/api/alert_rules", "system:read"},
+	{"POST", "/api/alert_rules", "system:admin"},
+	{"POST", "/api/alert_rules/{rule_name}:update", "system:admin"},
+	{"DELETE", "/api/alert_rules/{rule_name}", "system:admin"},
+	{"GET", "/api/maintenance", "system:read"},
+	{"POST", "/api/maintenance:enable", "system:admin"},
+	{"POST", "/api/maintenance:disable", "system:admin"},
+	{"GET", "/api/plugins", "plugins:read"},
+	{"GET", "/api/plugins/{name}", "plugins:read"},
+	{"GET", "/api/settings:export", "system:admin"},
+	{"POST", "/api/settings:import", "system:admin"},
+	{"GET", "/api/tokens", "system:admin"},
+	{"POST", "/api/tokens", "system:admin"},
+	{"POST", "/api/tokens/{token_id}:rotate", "system:admin"},
+	{"DELETE", "/api/tokens/{token_id}", "system:admin"},
+	{"GET", "/api/live", "tasks:read"},
+	{"GET", "/api/audit", "system:read"},
+	{"GET", "/api/health", "system:read"},
+	{"GET", "/api/metrics", "metrics:read"},
+	{"GET", "/api/metrics/history", "metrics:read"},
+	{"GET", "/api/metrics/history/{qname}", "metrics:read"},
+}
+
+func TestRequiredScope(t *testing.T) {
+	for _, tc := range requiredScopeTests {
+		if got := requiredScope(tc.tmpl, tc.method).String(); got != tc.want {
+			t.Errorf("requiredScope(%q, %q) = %q, want %q", tc.tmpl, tc.method, got, tc.want)
+		}
+	}
+
+	// Templates are matched regardless of the root path, and HEAD requires the same scope as GET.
+	if got := requiredScope("/monitoring/api/queues/{qname}", "DELETE").String(); got != "queues:admin" {
+		t.Errorf("requiredScope with root path = %q, want %q", got, "queues:admin")
+	}
+	if got := requiredScope("/api/queues", "HEAD").String(); got != "queues:read" {
+		t.Errorf("requiredScope with HEAD = %q, want %q", got, "queues:read")
+	}
+}
+
+func TestRequiredScopeCoversAllRoutes(t *testing.T) {
+	h := New(Options{
+		RedisConnOpt:           asynq.RedisClientOpt{Addr: "127.0.0.1:0"},
+		Users:                  []User{{Username: "admin", Password: "s3cr3t", Role: RoleAdmin}},
+		MetricsHistoryInterval: time.Minute,
+	})
+	defer h.Close()
+
+	listed := make(map[string]bool)
+	for _, tc := range requiredScopeTests {
+		listed[tc.method+" "+tc.tmpl] = true
+	}
+	err := h.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(tmpl, "/api/") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			if !listed[method+" "+tmpl] {
+				t.Errorf("%s %s is not listed in requiredScopeTests", method, tmpl)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsSameOriginRequest(t *testing.T) {
+	tests := []struct {
+		desc   string
+		header http.Header
+		want   bool
+	}{
+		{"no headers", http.Header{}, false},
+		{"header sent by Web UI", http.Header{"X-Requested-With": {"XMLHttpRequest"}}, true},
+		{"same-origin fetch", http.Header{"Sec-Fetch-Site": {"same-origin"}}, true},
+		{"cross-site fetch", http.Header{"Sec-Fetch-Site": {"cross-site"}}, false},
+		{"same-site fetch", http.Header{"Sec-Fetch-Site": {"same-site"}}, false},
+		{"cross-site fetch with same origin", http.Header{"Sec-Fetch-Site": {"cross-site"}, "Origin": {"http://asynqmon.example.com"}}, false},
+		{"same origin", http.Header{"Origin": {"http://asynqmon.example.com"}}, true},
+		{"other origin", http.Header{"Origin": {"https://evil.example.com"}}, false},
+		{"opaque origin", http.Header{"Origin": {"null"}}, false},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest("POST", "http://asynqmon.example.com/api/queues/default:pause", nil)
+		r.Header = tc.header
+		if got := isSameOriginRequest(r); got != tc.want {
+			t.Errorf("%s: isSameOriginRequest = %t, want %t", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestAPIAuthRejectsCrossSiteRequests(t *testing.T) {
+	h := New(Options{
+		RedisConnOpt: asynq.RedisClientOpt{Addr: "127.0.0.1:0"},
+		Users:        []User{{Username: "admin", Password: "s3cr3t", Role: RoleAdmin}},
+	})
+	defer h.Close()
+
+	tests := []struct {
+		desc      string
+		method    string
+		header    http.Header
+		forbidden bool
+	}{
+		{"form post from other site", "POST", http.Header{"Origin": {"https://evil.example.com"}}, true},
+		{"post without origin", "POST", http.Header{}, true},
+		{"post from Web UI", "POST", http.Header{"X-Requested-With": {"XMLHttpRequest"}}, false},
+		{"get from other site", "GET", http.Header{"Origin": {"https://evil.example.com"}}, false},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(tc.method, "/api/maintenance:enable", nil)
+		if tc.method == "GET" {
+			r = httptest.NewRequest(tc.method, "/api/maintenance", nil)
+		}
+		for k, vs := range tc.header {
+			r.Header[k] = vs
+		}
+		r.SetBasicAuth("admin", "s3cr3t")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Code == http.StatusForbidden; got != tc.forbidden {
+			t.Errorf("%s: status = %d, want forbidden %t", tc.desc, w.Code, tc.forbidden)
+		}
+	}
+}
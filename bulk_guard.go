@@ -0,0 +1,168 @@
+package asynqmon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - bulkGuard which rejects bulk operations affecting too many tasks
+// ****************************************************************************
+
+// bulkGuard rejects bulk operations (e.g. delete_all, batch_run) which would affect
+// more tasks than the threshold, unless the request has the force=true query parameter.
+type bulkGuard struct {
+	inspector *asynq.Inspector
+	rc        redis.UniversalClient
+	pf        *taskPayloadFormatter // decodes payloads of tasks checked against filters
+	threshold int                   // zero means no limit
+}
+
+// Suffixes of the route path templates of bulk operations.
+var (
+	allTasksOpSuffixes    = []string{":delete_all", ":run_all", ":archive_all", ":cancel_all", ":schedule_all"}
+	batchTasksOpSuffixes  = []string{":batch_delete", ":batch_run", ":batch_archive", ":batch_cancel", ":batch_schedule", ":batch_move"}
+	filterTasksOpSuffixes = []string{":delete_matching", ":run_matching", ":archive_matching"}
+)
+
+func hasAnySuffix(s string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *bulkGuard) middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.threshold <= 0 || r.URL.Query().Get("force") == "true" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		var n int
+		switch {
+		case hasAnySuffix(tmpl, allTasksOpSuffixes):
+			n, err = g.countTasks(r, tmpl)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+		case hasAnySuffix(tmpl, batchTasksOpSuffixes), hasAnySuffix(tmpl, filterTasksOpSuffixes):
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+			if err != nil {
+				writeBadRequestError(w, r, fmt.Sprintf("invalid request body: %v", err))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			if hasAnySuffix(tmpl, batchTasksOpSuffixes) {
+				var req struct {
+					TaskIDs []string `json:"task_ids"`
+				}
+				// Let the handler report invalid request bodies.
+				json.Unmarshal(body, &req)
+				n = len(req.TaskIDs)
+				break
+			}
+			var req tasksByFilterRequest
+			if json.Unmarshal(body, &req) != nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+			f, err := req.filter(g.pf)
+			if err != nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+			// Operations by filter are processed one batch per request, and the cursor is
+			// chosen by the client, so every request counts the tasks which still match the filter.
+			var complete bool
+			if n, complete, err = g.countMatchingTasks(r, tmpl, f); err != nil {
+				writeError(w, r, err)
+				return
+			}
+			if !complete {
+				writeErrorResponse(w, r, http.StatusConflict, errCodeBulkThresholdExceeded,
+					fmt.Sprintf("operation may affect more tasks than the threshold of %d since only the first %d tasks were checked against the filter: use force=true query parameter to proceed",
+						g.threshold, maxTaskSearchScan))
+				return
+			}
+		default:
+			h.ServeHTTP(w, r)
+			return
+		}
+		if n > g.threshold {
+			writeBulkThresholdError(w, r, n, g.threshold)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// countTasks returns the number of tasks affected by the "all tasks" operation
+// of the route with the given path template.
+func (g *bulkGuard) countTasks(r *http.Request, tmpl string) (int, error) {
+	vars := mux.Vars(r)
+	qname := vars["qname"]
+	if strings.Contains(tmpl, "/aggregating_tasks:") {
+		groups, err := g.inspector.Groups(qname)
+		if err != nil {
+			return 0, err
+		}
+		for _, grp := range groups {
+			if grp.Group == vars["gname"] {
+				return grp.Size, nil
+			}
+		}
+		return 0, nil
+	}
+	info, err := g.inspector.GetQueueInfo(qname)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case strings.Contains(tmpl, "/active_tasks:"):
+		return info.Active, nil
+	case strings.Contains(tmpl, "/pending_tasks:"):
+		return info.Pending, nil
+	case strings.Contains(tmpl, "/scheduled_tasks:"):
+		return info.Scheduled, nil
+	case strings.Contains(tmpl, "/retry_tasks:"):
+		return info.Retry, nil
+	case strings.Contains(tmpl, "/archived_tasks:"):
+		return info.Archived, nil
+	case strings.Contains(tmpl, "/completed_tasks:"):
+		return info.Completed, nil
+	}
+	return 0, nil
+}
+
+// countMatchingTasks returns the number of tasks matching the filter in the state of the
+// "matching" operation of the route with the given path template. complete is false if
+// there are too many tasks in the state to check all of them.
+func (g *bulkGuard) countMatchingTasks(r *http.Request, tmpl string, f *taskFilter) (n int, complete bool, err error) {
+	qname := mux.Vars(r)["qname"]
+	// e.g. "/api/queues/{qname}/pending_tasks:delete_matching"
+	state := strings.TrimSuffix(path.Base(tmpl[:strings.LastIndex(tmpl, ":")]), "_tasks")
+	return countMatchingTasks(r.Context(), g.rc, qname, state, f, exportTaskLister(g.inspector, qname, state))
+}
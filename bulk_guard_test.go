@@ -0,0 +1,160 @@
+package asynqmon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+)
+
+func TestBulkGuard(t *testing.T) {
+	e := newTaskMoveEnv(t)
+	// 5 email tasks and 2 report tasks in the pending state, and a report task in the archived state.
+	for i := 0; i < 8; i++ {
+		typename := "email:send"
+		if i >= 5 {
+			typename = "report:generate"
+		}
+		if _, err := e.client.Enqueue(asynq.NewTask(typename, []byte(fmt.Sprintf(`{"n":%d}`, i))), asynq.TaskID(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.setState("default", "7", "pending", "archived")
+
+	g := &bulkGuard{inspector: e.inspector, rc: e.rc, pf: &taskPayloadFormatter{}, threshold: 3}
+	var called bool
+	router := mux.NewRouter()
+	router.Use(g.middleware)
+	for _, path := range []string{
+		"/api/queues/{qname}/pending_tasks:delete_all",
+		"/api/queues/{qname}/pending_tasks:batch_delete",
+		"/api/queues/{qname}/pending_tasks:delete_matching",
+		"/api/queues/{qname}/archived_tasks:run_matching",
+		"/api/queues/{qname}/pending_tasks",
+	} {
+		router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		})
+	}
+
+	tests := []struct {
+		desc         string
+		path         string
+		body         string
+		wantCalled   bool
+		wantStatus   int
+		wantAffected *int
+	}{
+		// Operations on all tasks are only tested with force=true since asynq counts
+		// the tasks in a state with redis commands which miniredis does not support.
+		{
+			desc:       "delete all with force",
+			path:       "/api/queues/default/pending_tasks:delete_all?force=true",
+			wantStatus: 200, wantCalled: true,
+		},
+		{
+			desc:       "batch within the threshold",
+			path:       "/api/queues/default/pending_tasks:batch_delete",
+			body:       `{"task_ids":["0","1","2"]}`,
+			wantStatus: 200, wantCalled: true,
+		},
+		{
+			desc:       "batch over the threshold",
+			path:       "/api/queues/default/pending_tasks:batch_delete",
+			body:       `{"task_ids":["0","1","2","3"]}`,
+			wantStatus: 409, wantAffected: intPtr(4),
+		},
+		{
+			desc:       "filter matching tasks within the threshold in a larger state",
+			path:       "/api/queues/default/pending_tasks:delete_matching",
+			body:       `{"task_type":"report:*"}`,
+			wantStatus: 200, wantCalled: true,
+		},
+		{
+			desc:       "filter matching tasks over the threshold",
+			path:       "/api/queues/default/pending_tasks:delete_matching",
+			body:       `{"task_type":"email:*"}`,
+			wantStatus: 409, wantAffected: intPtr(5),
+		},
+		{
+			desc:       "filter matching by payload",
+			path:       "/api/queues/default/pending_tasks:delete_matching",
+			body:       `{"payload_regexp":"\"n\":[0-2]\\b"}`,
+			wantStatus: 200, wantCalled: true,
+		},
+		{
+			desc:       "cursor of a later batch",
+			path:       "/api/queues/default/pending_tasks:delete_matching",
+			body:       `{"task_type":"email:*","cursor":"1"}`,
+			wantStatus: 409, wantAffected: intPtr(5),
+		},
+		{
+			desc:       "zero cursor with leading zero",
+			path:       "/api/queues/default/pending_tasks:delete_matching",
+			body:       `{"task_type":"email:*","cursor":"00"}`,
+			wantStatus: 409, wantAffected: intPtr(5),
+		},
+		{
+			desc:       "cursor past the end",
+			path:       "/api/queues/default/pending_tasks:delete_matching",
+			body:       `{"task_type":"email:*","cursor":"1000000"}`,
+			wantStatus: 409, wantAffected: intPtr(5),
+		},
+		{
+			desc:       "filter matching tasks over the threshold with force",
+			path:       "/api/queues/default/pending_tasks:delete_matching?force=true",
+			body:       `{"task_type":"email:*"}`,
+			wantStatus: 200, wantCalled: true,
+		},
+		{
+			desc:       "filter counts the tasks in the state of the route",
+			path:       "/api/queues/default/archived_tasks:run_matching",
+			body:       `{"task_type":"*"}`,
+			wantStatus: 200, wantCalled: true,
+		},
+		{
+			desc:       "invalid filter is reported by the handler",
+			path:       "/api/queues/default/pending_tasks:delete_matching",
+			body:       `{"task_type":"["}`,
+			wantStatus: 200, wantCalled: true,
+		},
+		{
+			desc:       "filter of a queue which does not exist",
+			path:       "/api/queues/nosuchqueue/pending_tasks:delete_matching",
+			body:       `{"task_type":"email:*"}`,
+			wantStatus: 404,
+		},
+		{
+			desc:       "other requests are not checked",
+			path:       "/api/queues/default/pending_tasks",
+			wantStatus: 200, wantCalled: true,
+		},
+	}
+	for _, tc := range tests {
+		called = false
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", tc.path, strings.NewReader(tc.body)))
+		if w.Code != tc.wantStatus {
+			t.Errorf("%s: responded with %d, want %d: %s", tc.desc, w.Code, tc.wantStatus, w.Body)
+		}
+		if called != tc.wantCalled {
+			t.Errorf("%s: handler called = %t, want %t", tc.desc, called, tc.wantCalled)
+		}
+		if tc.wantAffected == nil {
+			continue
+		}
+		var p problemDetails
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if p.Code != errCodeBulkThresholdExceeded || p.AffectedCount == nil || *p.AffectedCount != *tc.wantAffected {
+			t.Errorf("%s: responded with code %q and affected count %v, want %q and %d",
+				tc.desc, p.Code, p.AffectedCount, errCodeBulkThresholdExceeded, *tc.wantAffected)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package asynqmon
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - capacity report which compares peak and available throughput of queues
+//   - http.Handler(s) for capacity report related endpoints
+// ****************************************************************************
+
+const (
+	// Default and maximum number of days of daily stats used for the report.
+	// Daily stats are kept for 90 days.
+	defaultCapacityReportDays = 30
+	maxCapacityReportDays     = 90
+
+	// Window of queue stats samples used to measure throughput per worker.
+	capacityThroughputWindow = 15 * time.Minute
+)
+
+type queueCapacity struct {
+	Queue string `json:"queue"`
+	// Number of servers processing the queue.
+	Servers int `json:"servers"`
+	// Number of workers available to the queue, which is the concurrency of each
+	// server processing the queue weighted by the priority of the queue on the server.
+	// With strict priority, all workers of the server are counted.
+	WorkerShare float64 `json:"worker_share"`
+	// Number of days of daily stats used for the report.
+	Days int `json:"days"`
+	// Date (YYYY-MM-DD) with the most processed tasks and the number of tasks processed on that date.
+	PeakDate           string  `json:"peak_date"`
+	PeakDailyProcessed int     `json:"peak_daily_processed"`
+	AvgDailyProcessed  float64 `json:"avg_daily_processed"`
+	// Tasks per minute processed on average during the peak date.
+	PeakPerMinute float64 `json:"peak_per_minute"`
+	// Tasks per minute processed by a busy worker, measured over recent samples.
+	// Null if no tasks were processed recently.
+	PerWorkerPerMinute *float64 `json:"per_worker_per_minute"`
+	// Tasks per minute and per day the queue can process with all of its worker share busy.
+	// Null if the throughput per worker is not known.
+	AvailablePerMinute *float64 `json:"available_per_minute"`
+	AvailableDaily     *float64 `json:"available_daily"`
+	// Ratio of available to peak daily throughput; below 1 means the peak exceeds capacity.
+	// Null if not known or there were no processed tasks.
+	Headroom *float64 `json:"headroom"`
+}
+
+type capacityReport struct {
+	// Time the report was generated in RFC3339 format.
+	GeneratedAt string `json:"generated_at"`
+	// Total concurrency of all servers.
+	TotalConcurrency int              `json:"total_concurrency"`
+	Queues           []*queueCapacity `json:"queues"`
+}
+
+// workerShares returns the number of workers available to each queue.
+func workerShares(srvs []*asynq.ServerInfo) (shares map[string]float64, servers map[string]int) {
+	shares, servers = make(map[string]float64), make(map[string]int)
+	for _, srv := range srvs {
+		total := 0
+		for _, p := range srv.Queues {
+			total += p
+		}
+		for qname, p := range srv.Queues {
+			servers[qname]++
+			switch {
+			case srv.StrictPriority:
+				shares[qname] += float64(srv.Concurrency)
+			case total > 0:
+				shares[qname] += float64(srv.Concurrency) * float64(p) / float64(total)
+			}
+		}
+	}
+	return shares, servers
+}
+
+// throughputPerWorker returns the number of tasks processed per minute by a busy
+// worker within the window, or nil if no tasks were processed. samples must be ordered by time.
+func throughputPerWorker(samples []*queueSample, window time.Duration) *float64 {
+	if len(samples) < 2 {
+		return nil
+	}
+	latest := samples[len(samples)-1]
+	var processed int
+	var activeMins float64
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		if latest.Time.Sub(prev.Time) > window {
+			continue
+		}
+		d := cur.Time.Sub(prev.Time).Minutes()
+		n := cur.Processed - prev.Processed
+		if n < 0 {
+			// Daily counter was reset at midnight (UTC).
+			n = cur.Processed
+		}
+		processed += n
+		activeMins += d * float64(prev.Active+cur.Active) / 2
+	}
+	if processed == 0 || activeMins <= 0 {
+		return nil
+	}
+	v := float64(processed) / activeMins
+	return &v
+}
+
+// generateCapacityReport generates a capacity report of the given queues.
+func generateCapacityReport(inspector *asynq.Inspector, sampler *queueStatsSampler, qnames []string, days int) (*capacityReport, error) {
+	srvs, err := inspector.Servers()
+	if err != nil {
+		return nil, err
+	}
+	shares, servers := workerShares(srvs)
+	report := &capacityReport{
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Queues:      make([]*queueCapacity, 0, len(qnames)),
+	}
+	for _, srv := range srvs {
+		report.TotalConcurrency += srv.Concurrency
+	}
+	sort.Strings(qnames)
+	for _, qname := range qnames {
+		stats, err := inspector.History(qname, days)
+		if err != nil {
+			return nil, err
+		}
+		c := &queueCapacity{
+			Queue:       qname,
+			Servers:     servers[qname],
+			WorkerShare: shares[qname],
+			Days:        len(stats),
+		}
+		total := 0
+		for _, s := range stats {
+			total += s.Processed
+			if c.PeakDate == "" || s.Processed > c.PeakDailyProcessed {
+				c.PeakDate = s.Date.Format("2006-01-02")
+				c.PeakDailyProcessed = s.Processed
+			}
+		}
+		if len(stats) > 0 {
+			c.AvgDailyProcessed = float64(total) / float64(len(stats))
+		}
+		c.PeakPerMinute = float64(c.PeakDailyProcessed) / (24 * 60)
+		c.PerWorkerPerMinute = throughputPerWorker(sampler.recentSamples(qname), capacityThroughputWindow)
+		if c.PerWorkerPerMinute != nil {
+			perMin := *c.PerWorkerPerMinute * c.WorkerShare
+			daily := perMin * 24 * 60
+			c.AvailablePerMinute, c.AvailableDaily = &perMin, &daily
+			if c.PeakDailyProcessed > 0 {
+				headroom := daily / float64(c.PeakDailyProcessed)
+				c.Headroom = &headroom
+			}
+		}
+		report.Queues = append(report.Queues, c)
+	}
+	return report, nil
+}
+
+// writeCSV writes the report as CSV with a row per queue.
+func (report *capacityReport) writeCSV(w io.Writer) error {
+	optional := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', 2, 64)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"queue", "servers", "worker_share", "days", "peak_date", "peak_daily_processed", "avg_daily_processed",
+		"peak_per_minute", "per_worker_per_minute", "available_per_minute", "available_daily", "headroom"})
+	for _, c := range report.Queues {
+		cw.Write([]string{
+			c.Queue,
+			strconv.Itoa(c.Servers),
+			strconv.FormatFloat(c.WorkerShare, 'f', 2, 64),
+			strconv.Itoa(c.Days),
+			c.PeakDate,
+			strconv.Itoa(c.PeakDailyProcessed),
+			strconv.FormatFloat(c.AvgDailyProcessed, 'f', 2, 64),
+			strconv.FormatFloat(c.PeakPerMinute, 'f', 2, 64),
+			optional(c.PerWorkerPerMinute),
+			optional(c.AvailablePerMinute),
+			optional(c.AvailableDaily),
+			optional(c.Headroom),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// newGetCapacityReportHandlerFunc returns a handler which generates a capacity report
+// from the daily stats of the number of days given in the days parameter.
+// The report is written as CSV if the format parameter is "csv", and JSON otherwise.
+func newGetCapacityReportHandlerFunc(inspector *asynq.Inspector, sampler *queueStatsSampler, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		days := defaultCapacityReportDays
+		if v := q.Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > maxCapacityReportDays {
+				writeBadRequestError(w, r, fmt.Sprintf("days must be a number between 1 and %d", maxCapacityReportDays))
+				return
+			}
+			days = n
+		}
+		format := q.Get("format")
+		if format != "" && format != "json" && format != "csv" {
+			writeBadRequestError(w, r, `format must be either "json" or "csv"`)
+			return
+		}
+		qnames, err := qa.queues(r, inspector)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		report, err := generateCapacityReport(inspector, sampler, qnames, days)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if format != "csv" {
+			writeResponseJSON(w, report)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"asynq-capacity-%s.csv\"", time.Now().Format("2006-01-02")))
+		if err := report.writeCSV(w); err != nil {
+			// The status and part of the report have already been written.
+			log.Printf("error: could not write capacity report: %v", err)
+		}
+	}
+}
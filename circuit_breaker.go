@@ -0,0 +1,284 @@
+package asynqmon
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - circuitBreaker which fails fast while redis is failing
+//   - staleResponseCache which keeps responses to serve while the breaker is open
+//   - http.Handler(s) for health related endpoints
+// ****************************************************************************
+
+const (
+	// Default value for Options.CircuitBreakerThreshold.
+	defaultCircuitBreakerThreshold = 5
+
+	// Default value for Options.CircuitBreakerCooldown.
+	defaultCircuitBreakerCooldown = 30 * time.Second
+)
+
+// Values used for circuitBreakerState.State.
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// circuitBreaker trips after a number of consecutive redis failures.
+// While open, API requests fail fast (or get a stale response) instead of
+// waiting on redis. After the cooldown, a single request is let through to
+// probe redis, and the breaker closes if it succeeds.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	cache     *staleResponseCache // nil if stale responses are not served
+
+	mu        sync.Mutex
+	state     string
+	failures  int // consecutive failures
+	openedAt  time.Time
+	lastError string
+	probing   bool // true while a request is probing redis in half-open state
+}
+
+// newCircuitBreaker returns a circuitBreaker which serves stale responses while open
+// if serveStale is true.
+func newCircuitBreaker(threshold int, cooldown time.Duration, serveStale bool) *circuitBreaker {
+	b := &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     breakerClosed,
+	}
+	if serveStale {
+		b.cache = newStaleResponseCache()
+	}
+	return b
+}
+
+// staleResponseKey returns the key of the response to the request in the stale cache.
+// Responses are kept per requester since they depend on what the requester has access to.
+func staleResponseKey(r *http.Request) string {
+	return requestIdentity(r) + "\x00" + r.URL.RequestURI()
+}
+
+// allow reports whether a request should be sent to redis.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record updates the breaker with the outcome of a request which was allowed.
+// errMsg is empty if redis responded.
+func (b *circuitBreaker) record(errMsg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if errMsg == "" {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	b.lastError = errMsg
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+type circuitBreakerState struct {
+	// State is one of "closed", "open", or "half-open".
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	// Time when the breaker was last opened in RFC3339 format.
+	// Empty string if the breaker is closed.
+	OpenedAt string `json:"opened_at"`
+	// Error message of the last failed request.
+	LastError string `json:"last_error"`
+}
+
+func (b *circuitBreaker) snapshot() *circuitBreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &circuitBreakerState{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if b.state != breakerClosed {
+		s.OpenedAt = b.openedAt.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+func (b *circuitBreaker) middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); b.threshold <= 0 || (route != nil && (route.GetName() == nonRedisRouteName || route.GetName() == maintenanceRouteName)) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if !b.allow() {
+			if r.Method == "GET" && b.cache != nil {
+				if e, ok := b.cache.get(staleResponseKey(r)); ok {
+					w.Header().Set("Content-Type", e.contentType)
+					w.Header().Set("X-Asynqmon-Stale", "true")
+					w.Header().Set("X-Asynqmon-Fetched-At", e.fetchedAt.UTC().Format(time.RFC3339))
+					w.Write(e.body)
+					return
+				}
+			}
+			writeErrorResponse(w, r, http.StatusServiceUnavailable, errCodeRedisUnavailable,
+				fmt.Sprintf("redis is failing, requests are rejected until it recovers: %s", b.snapshot().LastError))
+			return
+		}
+		rec := &breakerRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		// Errors from redis are responded with these codes (see classifyError).
+		if rec.status == http.StatusServiceUnavailable || rec.status == http.StatusGatewayTimeout {
+			b.record(fmt.Sprintf("%s %s responded with %d", r.Method, r.URL.Path, rec.status))
+			return
+		}
+		b.record("")
+		if r.Method == "GET" && b.cache != nil && rec.status == http.StatusOK && !rec.overflow {
+			b.cache.set(staleResponseKey(r), rec.Header().Get("Content-Type"), rec.body.Bytes())
+		}
+	})
+}
+
+// breakerRecorder records the status and the body of a response.
+type breakerRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	overflow    bool // true if the body is too large to be kept
+}
+
+func (rec *breakerRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *breakerRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	if !rec.overflow {
+		if rec.body.Len()+len(b) > maxStaleResponseSize {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+const (
+	// Maximum number of responses kept in staleResponseCache.
+	maxStaleResponseEntries = 256
+
+	// Maximum size of a response kept in staleResponseCache.
+	maxStaleResponseSize = 256 << 10 // 256KB
+)
+
+// staleResponseCache holds the last successful responses of GET requests
+// so that they can be served while the circuit breaker is open.
+type staleResponseCache struct {
+	mu      sync.Mutex
+	entries map[string]*staleResponse // keyed by request URI
+}
+
+type staleResponse struct {
+	contentType string
+	body        []byte
+	fetchedAt   time.Time
+}
+
+func newStaleResponseCache() *staleResponseCache {
+	return &staleResponseCache{entries: make(map[string]*staleResponse)}
+}
+
+func (c *staleResponseCache) get(key string) (*staleResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	return e, ok
+}
+
+func (c *staleResponseCache) set(key, contentType string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxStaleResponseEntries {
+		// Evict an arbitrary entry to bound the memory usage.
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = &staleResponse{
+		contentType: contentType,
+		body:        append([]byte(nil), body...),
+		fetchedAt:   time.Now(),
+	}
+}
+
+// Values used for healthResponse.Status.
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+)
+
+type healthResponse struct {
+	// Status is "degraded" while the circuit breaker is not closed
+	// or while connected to a read-only replica.
+	Status string `json:"status"`
+	// Nil if the circuit breaker is disabled.
+	RedisCircuitBreaker *circuitBreakerState `json:"redis_circuit_breaker"`
+	// True if connected to a read-only replica.
+	RedisReadOnly bool `json:"redis_read_only"`
+	// Address of the master if connected to a read-only replica.
+	RedisMasterAddress string `json:"redis_master_address,omitempty"`
+}
+
+func newGetHealthHandlerFunc(breaker *circuitBreaker, replica *replicaDetector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := healthResponse{Status: healthOK}
+		resp.RedisReadOnly, resp.RedisMasterAddress = replica.state()
+		if resp.RedisReadOnly {
+			resp.Status = healthDegraded
+		}
+		if breaker.threshold > 0 {
+			resp.RedisCircuitBreaker = breaker.snapshot()
+			if resp.RedisCircuitBreaker.State != breakerClosed {
+				resp.Status = healthDegraded
+			}
+		}
+		writeResponseJSON(w, resp)
+	}
+}
@@ -0,0 +1,147 @@
+package asynqmon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// breakerStep is a request sent through the circuit breaker middleware.
+type breakerStep struct {
+	desc   string
+	method string
+	user   string // identity of the requester, anonymous if empty
+	status int    // status responded by redis-backed handler, if called
+	// Elapse the cooldown of the open breaker before the request.
+	cooledDown bool
+
+	wantStatus int
+	wantCalled bool
+	wantStale  bool
+	wantState  string // state after the request
+}
+
+func runBreakerSteps(t *testing.T, b *circuitBreaker, steps []breakerStep) {
+	t.Helper()
+	var status, calls int
+	h := b.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"call":%d}`, calls)
+	}))
+	for _, s := range steps {
+		if s.cooledDown {
+			b.mu.Lock()
+			b.openedAt = b.openedAt.Add(-b.cooldown)
+			b.mu.Unlock()
+		}
+		status = s.status
+		before := calls
+		r := httptest.NewRequest(s.method, "/api/queues", nil)
+		if s.user != "" {
+			r = r.WithContext(context.WithValue(r.Context(), apiTokenKey{}, newUserInfo(s.user, RoleViewer)))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != s.wantStatus {
+			t.Errorf("%s: responded with %d, want %d", s.desc, w.Code, s.wantStatus)
+		}
+		if called := calls > before; called != s.wantCalled {
+			t.Errorf("%s: handler called = %t, want %t", s.desc, called, s.wantCalled)
+		}
+		if stale := w.Header().Get("X-Asynqmon-Stale") == "true"; stale != s.wantStale {
+			t.Errorf("%s: stale = %t, want %t", s.desc, stale, s.wantStale)
+		}
+		if got := b.snapshot().State; got != s.wantState {
+			t.Errorf("%s: breaker state is %q, want %q", s.desc, got, s.wantState)
+		}
+	}
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	b := newCircuitBreaker(2, time.Minute, false)
+	runBreakerSteps(t, b, []breakerStep{
+		{desc: "success", method: "GET", status: 200, wantStatus: 200, wantCalled: true, wantState: breakerClosed},
+		{desc: "first failure", method: "GET", status: 503, wantStatus: 503, wantCalled: true, wantState: breakerClosed},
+		{desc: "success resets failures", method: "GET", status: 200, wantStatus: 200, wantCalled: true, wantState: breakerClosed},
+		{desc: "client error is not a failure", method: "GET", status: 404, wantStatus: 404, wantCalled: true, wantState: breakerClosed},
+		{desc: "failure after reset", method: "POST", status: 504, wantStatus: 504, wantCalled: true, wantState: breakerClosed},
+		{desc: "failure reaching threshold", method: "GET", status: 503, wantStatus: 503, wantCalled: true, wantState: breakerOpen},
+		{desc: "GET fails fast while open", method: "GET", status: 200, wantStatus: 503, wantCalled: false, wantState: breakerOpen},
+		{desc: "POST fails fast while open", method: "POST", status: 200, wantStatus: 503, wantCalled: false, wantState: breakerOpen},
+		{desc: "failed probe after cooldown", method: "GET", status: 503, cooledDown: true, wantStatus: 503, wantCalled: true, wantState: breakerOpen},
+		{desc: "fails fast after failed probe", method: "GET", status: 200, wantStatus: 503, wantCalled: false, wantState: breakerOpen},
+		{desc: "successful probe after cooldown", method: "GET", status: 200, cooledDown: true, wantStatus: 200, wantCalled: true, wantState: breakerClosed},
+		{desc: "success after close", method: "GET", status: 200, wantStatus: 200, wantCalled: true, wantState: breakerClosed},
+	})
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	b := newCircuitBreaker(1, time.Minute, false)
+	b.record("redis is down")
+	b.openedAt = time.Now().Add(-time.Minute)
+
+	if !b.allow() {
+		t.Fatalf("first request after cooldown was not allowed")
+	}
+	if got := b.snapshot().State; got != breakerHalfOpen {
+		t.Errorf("state while probing is %q, want %q", got, breakerHalfOpen)
+	}
+	if b.allow() {
+		t.Errorf("second request was allowed while the first request is probing")
+	}
+	b.record("")
+	if !b.allow() {
+		t.Errorf("request was not allowed after the probe succeeded")
+	}
+}
+
+func TestCircuitBreakerStaleResponses(t *testing.T) {
+	b := newCircuitBreaker(1, time.Minute, true)
+	runBreakerSteps(t, b, []breakerStep{
+		{desc: "alice gets a response", method: "GET", user: "alice", status: 200, wantStatus: 200, wantCalled: true, wantState: breakerClosed},
+		{desc: "redis fails", method: "GET", user: "bob", status: 503, wantStatus: 503, wantCalled: true, wantState: breakerOpen},
+		{desc: "alice gets the stale response of alice", method: "GET", user: "alice", wantStatus: 200, wantStale: true, wantState: breakerOpen},
+		{desc: "bob has no stale response", method: "GET", user: "bob", wantStatus: 503, wantState: breakerOpen},
+		{desc: "anonymous user has no stale response", method: "GET", wantStatus: 503, wantState: breakerOpen},
+		{desc: "POST is not served from cache", method: "POST", user: "alice", wantStatus: 503, wantState: breakerOpen},
+	})
+
+	// The stale response is the one fetched by alice.
+	r := httptest.NewRequest("GET", "/api/queues", nil)
+	r = r.WithContext(context.WithValue(r.Context(), apiTokenKey{}, newUserInfo("alice", RoleViewer)))
+	e, ok := b.cache.get(staleResponseKey(r))
+	if !ok {
+		t.Fatalf("stale response of alice is not cached")
+	}
+	if diff := cmp.Diff(`{"call":1}`, string(e.body)); diff != "" {
+		t.Errorf("stale response diff (-want,+got):\n%s", diff)
+	}
+}
+
+func TestCircuitBreakerWithoutStaleResponses(t *testing.T) {
+	b := newCircuitBreaker(1, time.Minute, false)
+	runBreakerSteps(t, b, []breakerStep{
+		{desc: "success", method: "GET", status: 200, wantStatus: 200, wantCalled: true, wantState: breakerClosed},
+		{desc: "redis fails", method: "GET", status: 503, wantStatus: 503, wantCalled: true, wantState: breakerOpen},
+		{desc: "no stale response", method: "GET", wantStatus: 503, wantState: breakerOpen},
+	})
+	if b.cache != nil {
+		t.Errorf("stale response cache is created while stale responses are not served")
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := newCircuitBreaker(0, time.Minute, true)
+	runBreakerSteps(t, b, []breakerStep{
+		{desc: "failure", method: "GET", status: 503, wantStatus: 503, wantCalled: true, wantState: breakerClosed},
+		{desc: "another failure", method: "GET", status: 503, wantStatus: 503, wantCalled: true, wantState: breakerClosed},
+		{desc: "success", method: "GET", status: 200, wantStatus: 200, wantCalled: true, wantState: breakerClosed},
+	})
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// ListAlertRules returns the alert rules configured with the options of the
+// deployment followed by the rules managed with the API.
+func (c *Client) ListAlertRules(ctx context.Context) ([]*AlertRule, error) {
+	var resp struct {
+		Rules []*AlertRule `json:"rules"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/alert_rules", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Rules, nil
+}
+
+// CreateAlertRule creates an alert rule managed with the API.
+// The returned rule has the default values of omitted fields.
+func (c *Client) CreateAlertRule(ctx context.Context, rule *AlertRule) (*AlertRule, error) {
+	var resp AlertRule
+	if err := c.do(ctx, http.MethodPost, "/alert_rules", nil, newAlertRuleRequest(rule), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// UpdateAlertRule replaces the alert rule with the same name.
+// Only rules managed with the API can be updated.
+func (c *Client) UpdateAlertRule(ctx context.Context, rule *AlertRule) (*AlertRule, error) {
+	var resp AlertRule
+	if err := c.do(ctx, http.MethodPost, "/alert_rules/"+escape(rule.Name)+":update", nil, newAlertRuleRequest(rule), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DeleteAlertRule deletes the alert rule with the given name.
+// Only rules managed with the API can be deleted.
+func (c *Client) DeleteAlertRule(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/alert_rules/"+escape(name), nil, nil, nil)
+}
+
+// alertRuleRequest is an AlertRule without the fields set by the server.
+type alertRuleRequest struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Queue      string  `json:"queue"`
+	ForSeconds int64   `json:"for_seconds"`
+	Severity   string  `json:"severity"`
+	Threshold  float64 `json:"threshold"`
+}
+
+func newAlertRuleRequest(rule *AlertRule) *alertRuleRequest {
+	return &alertRuleRequest{
+		Name:       rule.Name,
+		Type:       rule.Type,
+		Queue:      rule.Queue,
+		ForSeconds: rule.ForSeconds,
+		Severity:   rule.Severity,
+		Threshold:  rule.Threshold,
+	}
+}
@@ -0,0 +1,257 @@
+// Package client provides a Go client for the asynqmon REST API.
+//
+// Example:
+//
+//	c := client.New(client.Options{BaseURL: "http://localhost:8080/monitoring"})
+//	queues, err := c.ListQueues(ctx)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Options are used to configure Client.
+type Options struct {
+	// BaseURL is the URL of the asynqmon application including its root path
+	// (e.g. "http://localhost:8080/monitoring").
+	//
+	// This field is required.
+	BaseURL string
+
+	// HTTPClient is used to send requests.
+	//
+	// This field is optional. Default is http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Header specifies additional headers to send with every request (e.g. Authorization).
+	//
+	// This field is optional.
+	Header http.Header
+
+	// Token is the API token sent in the "Authorization: Bearer <token>" header.
+	//
+	// This field is optional. It is required if the server is configured with API tokens.
+	Token string
+}
+
+// Client is a client for the asynqmon REST API.
+// It is safe for concurrent use by multiple goroutines.
+type Client struct {
+	baseURL    string // the value should not have the trailing slash
+	httpClient *http.Client
+	header     http.Header
+	token      string
+}
+
+// New creates a Client with the given options.
+func New(opts Options) *Client {
+	if opts.BaseURL == "" {
+		panic("client.New: BaseURL field is required")
+	}
+	c := &Client{
+		baseURL:    strings.TrimSuffix(opts.BaseURL, "/"),
+		httpClient: opts.HTTPClient,
+		header:     opts.Header,
+		token:      opts.Token,
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	return c
+}
+
+// Error codes returned by the API.
+const (
+	ErrCodeBadRequest               = "bad_request"
+	ErrCodeNotFound                 = "not_found"
+	ErrCodeMethodNotAllowed         = "method_not_allowed"
+	ErrCodeQueueNotFound            = "queue_not_found"
+	ErrCodeQueueNotEmpty            = "queue_not_empty"
+	ErrCodeTaskNotFound             = "task_not_found"
+	ErrCodeTaskAlreadyActive        = "task_already_active"
+	ErrCodeTaskAlreadyPending       = "task_already_pending"
+	ErrCodeTaskAlreadyArchived      = "task_already_archived"
+	ErrCodeTaskStateConflict        = "task_state_conflict"
+	ErrCodeDuplicateTask            = "duplicate_task"
+	ErrCodeTaskIDConflict           = "task_id_conflict"
+	ErrCodeSchedulerEntryNotFound   = "scheduler_entry_not_found"
+	ErrCodeAPITokenNotFound         = "api_token_not_found"
+	ErrCodeAlertRuleNotFound        = "alert_rule_not_found"
+	ErrCodeAlertRuleExists          = "alert_rule_exists"
+	ErrCodeRedisUnavailable         = "redis_unavailable"
+	ErrCodeRedisTimeout             = "redis_timeout"
+	ErrCodeRedisReadOnly            = "redis_read_only"
+	ErrCodePrometheusUnavailable    = "prometheus_unavailable"
+	ErrCodeBulkThresholdExceeded    = "bulk_threshold_exceeded"
+	ErrCodeIdempotencyKeyInProgress = "idempotency_key_in_progress"
+	ErrCodeIdempotencyKeyReused     = "idempotency_key_reused"
+	ErrCodeUnauthorized             = "unauthorized"
+	ErrCodeForbidden                = "forbidden"
+	ErrCodeReadOnly                 = "read_only"
+	ErrCodeMaintenanceMode          = "maintenance_mode"
+	ErrCodeInternal                 = "internal"
+)
+
+// Error is returned when the API responds with a non-2xx status code.
+type Error struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Code is one of the ErrCode constants.
+	// Empty if the response did not include an error code.
+	Code string
+
+	// Message is the error message returned by the API.
+	Message string
+
+	// RequestID is the ID of the request assigned by the API.
+	// Empty if the response did not include a request ID.
+	RequestID string
+
+	// AffectedCount is the number of tasks the rejected bulk operation would affect.
+	// Only set if Code is ErrCodeBulkThresholdExceeded.
+	AffectedCount int
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("asynqmon: %d %s (%s): %s", e.StatusCode, http.StatusText(e.StatusCode), e.Code, e.Message)
+	}
+	return fmt.Sprintf("asynqmon: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsErrorCode reports whether err is an *Error with the given code.
+func IsErrorCode(err error, code string) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
+
+// problemDetails is the body of an error response.
+type problemDetails struct {
+	Detail        string `json:"detail"`
+	Code          string `json:"code"`
+	RequestID     string `json:"request_id"`
+	AffectedCount int    `json:"affected_count"`
+}
+
+// newError creates an Error from the response.
+func newError(resp *http.Response, body []byte) *Error {
+	e := &Error{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("X-Request-ID")}
+	var p problemDetails
+	if err := json.Unmarshal(body, &p); err != nil || p.Detail == "" {
+		// Not a problem+json response (e.g. from a proxy).
+		e.Message = strings.TrimSpace(string(body))
+		return e
+	}
+	e.Code, e.Message, e.AffectedCount = p.Code, p.Detail, p.AffectedCount
+	if p.RequestID != "" {
+		e.RequestID = p.RequestID
+	}
+	return e
+}
+
+// do sends a request to the API endpoint at path (relative to "/api").
+// If in is non-nil, it is sent as the JSON request body.
+// If out is non-nil, the JSON response body is decoded into it.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	resp, err := c.send(ctx, method, path, query, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	dec := json.NewDecoder(resp.Body)
+	// Keep large integers exact when decoding into interface{} values.
+	dec.UseNumber()
+	return dec.Decode(out)
+}
+
+// send sends a request as do does, and returns the response if it has a 2xx status code.
+// The caller must close the response body.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, in interface{}) (*http.Response, error) {
+	u := c.baseURL + "/api" + path
+	if force, _ := ctx.Value(forceKey{}).(bool); force && method != http.MethodGet {
+		query = cloneValues(query)
+		query.Set("force", "true")
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range c.header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key, ok := ctx.Value(idempotencyKey{}).(string); ok && method != http.MethodGet {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, newError(resp, msg)
+	}
+	return resp, nil
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a copy of ctx which makes the mutating request made with it
+// send the given idempotency key. A retried request with the same key is not processed
+// twice by the API; the response of the first request is returned instead.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+type forceKey struct{}
+
+// WithForce returns a copy of ctx which makes the bulk operation requested with it
+// proceed even if it affects more tasks than the threshold configured on the server.
+func WithForce(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceKey{}, true)
+}
+
+func cloneValues(v url.Values) url.Values {
+	out := make(url.Values, len(v))
+	for k, vs := range v {
+		out[k] = append([]string(nil), vs...)
+	}
+	return out
+}
+
+// escape escapes s so that it can be used as a path segment.
+func escape(s string) string {
+	return url.PathEscape(s)
+}
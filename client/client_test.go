@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClient(t *testing.T) {
+	var gotMethod, gotPath, gotQuery, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotQuery = r.Method, r.URL.Path, r.URL.RawQuery
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		switch r.URL.Path {
+		case "/monitoring/api/queues":
+			io.WriteString(w, `{"queues":[{"queue":"default","size":3,"paused":true}]}`)
+		case "/monitoring/api/queues/default/retry_tasks:batch_run":
+			io.WriteString(w, `{"pending_ids":["a"],"error_ids":["b"]}`)
+		case "/monitoring/api/queues/default/archived_tasks:delete_matching":
+			if gotBody == `{"task_type":"email:*"}` {
+				io.WriteString(w, `{"affected":2,"failed":0,"scanned":500,"cursor":"42"}`)
+			} else {
+				io.WriteString(w, `{"affected":1,"failed":0,"scanned":10,"cursor":""}`)
+			}
+		case "/monitoring/api/queues/default:pause":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"type":"about:blank","title":"Queue not found","status":404,"detail":"queue not found","code":"queue_not_found","request_id":"abc"}`)
+		}
+	}))
+	defer srv.Close()
+	c := New(Options{BaseURL: srv.URL + "/monitoring/"})
+	ctx := context.Background()
+
+	queues, err := c.ListQueues(ctx)
+	if err != nil {
+		t.Fatalf("ListQueues returned error: %v", err)
+	}
+	if len(queues) != 1 || queues[0].Queue != "default" || queues[0].Size != 3 || !queues[0].Paused {
+		t.Errorf("ListQueues returned %+v", queues[0])
+	}
+
+	res, err := c.BatchRunTasks(ctx, "default", TaskStateRetry, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("BatchRunTasks returned error: %v", err)
+	}
+	if diff := cmp.Diff(&BatchResult{SucceededIDs: []string{"a"}, FailedIDs: []string{"b"}}, res); diff != "" {
+		t.Errorf("BatchRunTasks returned %+v; (-want,+got)\n%s", res, diff)
+	}
+	if gotMethod != "POST" || gotBody != `{"task_ids":["a","b"]}` {
+		t.Errorf("BatchRunTasks sent %s %s", gotMethod, gotBody)
+	}
+
+	n, err := c.DeleteTasksByFilter(ctx, "default", TaskStateArchived, &TaskFilter{TaskType: "email:*"})
+	if err != nil {
+		t.Fatalf("DeleteTasksByFilter returned error: %v", err)
+	}
+	if n != 3 || gotBody != `{"task_type":"email:*","cursor":"42"}` {
+		t.Errorf("DeleteTasksByFilter returned %d and last sent %s; want 3 and the cursor of the first response", n, gotBody)
+	}
+
+	if err := c.PauseQueue(ctx, "default"); err != nil {
+		t.Errorf("PauseQueue returned error: %v", err)
+	}
+
+	if _, err := c.ListTasks(ctx, "default", TaskStatePending, &ListOptions{Page: 2, PageSize: 50}); err == nil {
+		t.Errorf("ListTasks returned nil error")
+	} else {
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "queue not found" || apiErr.RequestID != "abc" || !IsErrorCode(err, ErrCodeQueueNotFound) {
+			t.Errorf("ListTasks returned error %v; want 404 queue not found", err)
+		}
+	}
+	if gotPath != "/monitoring/api/queues/default/pending_tasks" || gotQuery != "page=2&size=50" {
+		t.Errorf("ListTasks sent request to %s?%s", gotPath, gotQuery)
+	}
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListQueues returns the current state of all queues.
+func (c *Client) ListQueues(ctx context.Context) ([]*Queue, error) {
+	var resp struct {
+		Queues []*Queue `json:"queues"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/queues", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Queues, nil
+}
+
+// GetQueue returns the current state and the recent history of the given queue.
+func (c *Client) GetQueue(ctx context.Context, qname string) (*QueueDetails, error) {
+	var resp QueueDetails
+	if err := c.do(ctx, http.MethodGet, "/queues/"+escape(qname), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DeleteQueue deletes the given queue. The queue must be empty.
+func (c *Client) DeleteQueue(ctx context.Context, qname string) error {
+	return c.do(ctx, http.MethodDelete, "/queues/"+escape(qname), nil, nil, nil)
+}
+
+// PauseQueue pauses processing of tasks in the given queue.
+func (c *Client) PauseQueue(ctx context.Context, qname string) error {
+	return c.do(ctx, http.MethodPost, "/queues/"+escape(qname)+":pause", nil, nil, nil)
+}
+
+// ResumeQueue resumes processing of tasks in the given queue.
+func (c *Client) ResumeQueue(ctx context.Context, qname string) error {
+	return c.do(ctx, http.MethodPost, "/queues/"+escape(qname)+":resume", nil, nil, nil)
+}
+
+// ListQueueStats returns the daily stats of the last 90 days, keyed by queue name.
+func (c *Client) ListQueueStats(ctx context.Context) (map[string][]*DailyStats, error) {
+	var resp struct {
+		Stats map[string][]*DailyStats `json:"stats"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/queue_stats", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Stats, nil
+}
+
+// ListGroups returns the groups of aggregating tasks in the given queue.
+func (c *Client) ListGroups(ctx context.Context, qname string) ([]*Group, error) {
+	var resp struct {
+		Groups []*Group `json:"groups"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/queues/"+escape(qname)+"/groups", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Groups, nil
+}
+
+// GetPayloadStats returns the payload sizes of up to sampleSize tasks in each state of the queue,
+// including the sizes of compressed payloads after decompression.
+// If sampleSize is zero, the server default is used.
+func (c *Client) GetPayloadStats(ctx context.Context, qname string, sampleSize int) (*PayloadStats, error) {
+	q := url.Values{}
+	if sampleSize > 0 {
+		q.Set("sample_size", strconv.Itoa(sampleSize))
+	}
+	var resp PayloadStats
+	if err := c.do(ctx, http.MethodGet, "/queues/"+escape(qname)+"/payload_stats", q, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// GetRedisLatency returns recent PING latencies and the output of LATENCY commands.
+func (c *Client) GetRedisLatency(ctx context.Context) (*RedisLatency, error) {
+	var resp RedisLatency
+	if err := c.do(ctx, http.MethodGet, "/redis_latency", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListRedisFailovers returns failovers reported by redis sentinels since the server started, newest first.
+// It returns an empty list if the server is not connected through redis sentinels.
+func (c *Client) ListRedisFailovers(ctx context.Context) ([]*RedisFailover, error) {
+	var resp struct {
+		Failovers []*RedisFailover `json:"failovers"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/redis_failovers", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Failovers, nil
+}
+
+// GetHealth returns the health of the server.
+func (c *Client) GetHealth(ctx context.Context) (*Health, error) {
+	var resp Health
+	if err := c.do(ctx, http.MethodGet, "/health", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
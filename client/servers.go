@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ListServers returns information about the running asynq servers.
+func (c *Client) ListServers(ctx context.Context) ([]*Server, error) {
+	var resp struct {
+		Servers []*Server `json:"servers"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/servers", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Servers, nil
+}
+
+// GetServerUtilization returns the worker utilization of servers sampled within the window.
+// If window is zero, the server default is used.
+func (c *Client) GetServerUtilization(ctx context.Context, window time.Duration) (*ServerUtilizationReport, error) {
+	q := url.Values{}
+	if window > 0 {
+		q.Set("window", window.String())
+	}
+	var resp ServerUtilizationReport
+	if err := c.do(ctx, http.MethodGet, "/servers/utilization", q, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListServerEvents returns up to limit events of servers joining and leaving between
+// start and end in chronological order. Zero values use the server defaults.
+func (c *Client) ListServerEvents(ctx context.Context, start, end time.Time, limit int) ([]*ServerEvent, error) {
+	q := url.Values{}
+	if !start.IsZero() {
+		q.Set("start", start.Format(time.RFC3339))
+	}
+	if !end.IsZero() {
+		q.Set("end", end.Format(time.RFC3339))
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var resp struct {
+		Events []*ServerEvent `json:"events"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/servers/history", q, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Events, nil
+}
+
+// ListSchedulerEntries returns the periodic tasks registered with schedulers.
+func (c *Client) ListSchedulerEntries(ctx context.Context) ([]*SchedulerEntry, error) {
+	var resp struct {
+		Entries []*SchedulerEntry `json:"entries"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/scheduler_entries", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Entries, nil
+}
+
+// RunSchedulerEntry enqueues the task of the given scheduler entry immediately and
+// returns the enqueued task. The schedule of the entry is not affected.
+func (c *Client) RunSchedulerEntry(ctx context.Context, entryID string) (*TaskInfo, error) {
+	var resp TaskInfo
+	if err := c.do(ctx, http.MethodPost, "/scheduler_entries/"+escape(entryID)+":run", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListSchedulerEnqueueEvents returns a page of enqueue events of the given scheduler entry.
+func (c *Client) ListSchedulerEnqueueEvents(ctx context.Context, entryID string, opts *ListOptions) ([]*SchedulerEnqueueEvent, error) {
+	var resp struct {
+		Events []*SchedulerEnqueueEvent `json:"events"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/scheduler_entries/"+escape(entryID)+"/enqueue_events", opts.query(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Events, nil
+}
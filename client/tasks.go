@@ -0,0 +1,519 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListOptions specifies the page of a list to return.
+type ListOptions struct {
+	// Page number starting from 1. Default is 1.
+	Page int
+	// Number of items per page. Default is 20.
+	PageSize int
+	// JSON names of the fields of the listed tasks to return (e.g. "type", "error_message").
+	// Other fields have zero values, and the payload is not formatted by the server.
+	// Default is all fields. Only used when listing tasks.
+	Fields []string
+	// Filter to list only the tasks matching it. The time range is not supported
+	// for active and aggregating tasks. Only used when listing tasks.
+	Filter *TaskFilter
+}
+
+func (o *ListOptions) query() url.Values {
+	q := url.Values{}
+	if o == nil {
+		return q
+	}
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PageSize > 0 {
+		q.Set("size", strconv.Itoa(o.PageSize))
+	}
+	if len(o.Fields) > 0 {
+		q.Set("fields", strings.Join(o.Fields, ","))
+	}
+	if f := o.Filter; f != nil {
+		if f.TaskType != "" {
+			q.Set("type", f.TaskType)
+		}
+		if f.PayloadContains != "" {
+			q.Set("payload_contains", f.PayloadContains)
+		}
+		if f.PayloadRegexp != "" {
+			q.Set("payload_regexp", f.PayloadRegexp)
+		}
+		if !f.After.IsZero() {
+			q.Set("after", f.After.Format(time.RFC3339))
+		}
+		if !f.Before.IsZero() {
+			q.Set("before", f.Before.Format(time.RFC3339))
+		}
+	}
+	return q
+}
+
+// tasksPath returns the path of the collection of tasks in the given state.
+// group is only used for aggregating tasks.
+func tasksPath(qname, group string, state TaskState) string {
+	p := "/queues/" + escape(qname)
+	if state == TaskStateAggregating {
+		p += "/groups/" + escape(group)
+	}
+	return p + "/" + string(state) + "_tasks"
+}
+
+// ListTasks returns a page of tasks in the given state.
+// Use ListAggregatingTasks to list aggregating tasks.
+func (c *Client) ListTasks(ctx context.Context, qname string, state TaskState, opts *ListOptions) (*TaskList, error) {
+	return c.listTasks(ctx, tasksPath(qname, "", state), opts)
+}
+
+// ListAggregatingTasks returns a page of aggregating tasks in the given group.
+func (c *Client) ListAggregatingTasks(ctx context.Context, qname, group string, opts *ListOptions) (*TaskList, error) {
+	return c.listTasks(ctx, tasksPath(qname, group, TaskStateAggregating), opts)
+}
+
+func (c *Client) listTasks(ctx context.Context, path string, opts *ListOptions) (*TaskList, error) {
+	var resp TaskList
+	if err := c.do(ctx, http.MethodGet, path, opts.query(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetTask returns the details of the given task.
+func (c *Client) GetTask(ctx context.Context, qname, id string) (*TaskInfo, error) {
+	var resp TaskInfo
+	if err := c.do(ctx, http.MethodGet, "/queues/"+escape(qname)+"/tasks/"+escape(id), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DeleteTask deletes the given task in the given state.
+// state must not be active or aggregating.
+func (c *Client) DeleteTask(ctx context.Context, qname string, state TaskState, id string) error {
+	return c.do(ctx, http.MethodDelete, tasksPath(qname, "", state)+"/"+escape(id), nil, nil, nil)
+}
+
+// RunTask moves the given task in the given state to pending state.
+// state must be scheduled, retry, or archived.
+func (c *Client) RunTask(ctx context.Context, qname string, state TaskState, id string) error {
+	return c.do(ctx, http.MethodPost, tasksPath(qname, "", state)+"/"+escape(id)+":run", nil, nil, nil)
+}
+
+// ArchiveTask moves the given task in the given state to archived state.
+// state must be pending, scheduled, or retry.
+func (c *Client) ArchiveTask(ctx context.Context, qname string, state TaskState, id string) error {
+	return c.do(ctx, http.MethodPost, tasksPath(qname, "", state)+"/"+escape(id)+":archive", nil, nil, nil)
+}
+
+// PrioritizeTask moves the given pending task to the front of its queue.
+func (c *Client) PrioritizeTask(ctx context.Context, qname, id string) error {
+	return c.do(ctx, http.MethodPost, tasksPath(qname, "", TaskStatePending)+"/"+escape(id)+":prioritize", nil, nil, nil)
+}
+
+// CancelTask sends a cancelation signal to the given active task.
+func (c *Client) CancelTask(ctx context.Context, qname, id string) error {
+	return c.do(ctx, http.MethodPost, tasksPath(qname, "", TaskStateActive)+"/"+escape(id)+":cancel", nil, nil, nil)
+}
+
+// DeleteAllTasks deletes all tasks in the given state and returns the number of tasks deleted.
+// state must not be active or aggregating.
+func (c *Client) DeleteAllTasks(ctx context.Context, qname string, state TaskState) (int, error) {
+	var resp struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := c.do(ctx, http.MethodDelete, tasksPath(qname, "", state)+":delete_all", nil, nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Deleted, nil
+}
+
+// RunAllTasks moves all tasks in the given state to pending state and returns the number of tasks moved.
+// state must be scheduled, retry, or archived.
+func (c *Client) RunAllTasks(ctx context.Context, qname string, state TaskState) (int, error) {
+	var resp struct {
+		Scheduled int `json:"scheduled"`
+	}
+	if err := c.do(ctx, http.MethodPost, tasksPath(qname, "", state)+":run_all", nil, nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Scheduled, nil
+}
+
+// ArchiveAllTasks moves all tasks in the given state to archived state and returns the number of tasks moved.
+// state must be pending, scheduled, or retry.
+func (c *Client) ArchiveAllTasks(ctx context.Context, qname string, state TaskState) (int, error) {
+	var resp struct {
+		Archived int `json:"archived"`
+	}
+	if err := c.do(ctx, http.MethodPost, tasksPath(qname, "", state)+":archive_all", nil, nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Archived, nil
+}
+
+// TaskFilter selects tasks by type, payload, and time. At least one of the fields must be set.
+type TaskFilter struct {
+	// Glob pattern the task type must match (e.g. "email:*").
+	TaskType string
+	// Substring the payload must contain, after decryption and decompression.
+	PayloadContains string
+	// Regular expression the payload must match, after decryption and decompression.
+	PayloadRegexp string
+	// Range of the time of the tasks in their state: the time pending tasks were enqueued,
+	// scheduled and retry tasks are scheduled to be processed, archived tasks were archived,
+	// and completed tasks were completed. Zero values leave the range unbounded.
+	After  time.Time
+	Before time.Time
+}
+
+type filterRequest struct {
+	TaskType        string `json:"task_type,omitempty"`
+	PayloadContains string `json:"payload_contains,omitempty"`
+	PayloadRegexp   string `json:"payload_regexp,omitempty"`
+	After           string `json:"after,omitempty"`
+	Before          string `json:"before,omitempty"`
+	Cursor          string `json:"cursor,omitempty"`
+}
+
+// tasksByFilter applies the operation to all tasks in the given state matching the filter,
+// continuing with the cursor returned by the server until all tasks are checked, and
+// returns the number of tasks affected (including if it fails midway).
+func (c *Client) tasksByFilter(ctx context.Context, qname string, state TaskState, op string, f *TaskFilter) (int, error) {
+	req := &filterRequest{TaskType: f.TaskType, PayloadContains: f.PayloadContains, PayloadRegexp: f.PayloadRegexp}
+	if !f.After.IsZero() {
+		req.After = f.After.Format(time.RFC3339)
+	}
+	if !f.Before.IsZero() {
+		req.Before = f.Before.Format(time.RFC3339)
+	}
+	var n int
+	for {
+		var resp struct {
+			Affected int    `json:"affected"`
+			Cursor   string `json:"cursor"`
+		}
+		if err := c.do(ctx, http.MethodPost, tasksPath(qname, "", state)+":"+op+"_matching", nil, req, &resp); err != nil {
+			return n, err
+		}
+		n += resp.Affected
+		if resp.Cursor == "" {
+			return n, nil
+		}
+		req.Cursor = resp.Cursor
+	}
+}
+
+// DeleteTasksByFilter deletes all tasks in the given state matching the filter and returns the number of tasks deleted.
+// state must not be active or aggregating.
+func (c *Client) DeleteTasksByFilter(ctx context.Context, qname string, state TaskState, f *TaskFilter) (int, error) {
+	return c.tasksByFilter(ctx, qname, state, "delete", f)
+}
+
+// RunTasksByFilter moves all tasks in the given state matching the filter to pending state and returns the number of tasks moved.
+// state must be scheduled, retry, or archived.
+func (c *Client) RunTasksByFilter(ctx context.Context, qname string, state TaskState, f *TaskFilter) (int, error) {
+	return c.tasksByFilter(ctx, qname, state, "run", f)
+}
+
+// ArchiveTasksByFilter moves all tasks in the given state matching the filter to archived state and returns the number of tasks moved.
+// state must be pending, scheduled, or retry.
+func (c *Client) ArchiveTasksByFilter(ctx context.Context, qname string, state TaskState, f *TaskFilter) (int, error) {
+	return c.tasksByFilter(ctx, qname, state, "archive", f)
+}
+
+// CancelAllTasks sends a cancelation signal to all active tasks in the given queue.
+func (c *Client) CancelAllTasks(ctx context.Context, qname string) error {
+	return c.do(ctx, http.MethodPost, tasksPath(qname, "", TaskStateActive)+":cancel_all", nil, nil, nil)
+}
+
+type batchRequest struct {
+	TaskIDs []string `json:"task_ids"`
+}
+
+// BatchDeleteTasks deletes the given tasks in the given state.
+func (c *Client) BatchDeleteTasks(ctx context.Context, qname string, state TaskState, ids []string) (*BatchResult, error) {
+	var resp struct {
+		DeletedIDs []string `json:"deleted_ids"`
+		FailedIDs  []string `json:"failed_ids"`
+	}
+	if err := c.do(ctx, http.MethodPost, tasksPath(qname, "", state)+":batch_delete", nil, &batchRequest{ids}, &resp); err != nil {
+		return nil, err
+	}
+	return &BatchResult{SucceededIDs: resp.DeletedIDs, FailedIDs: resp.FailedIDs}, nil
+}
+
+// BatchRunTasks moves the given tasks in the given state to pending state.
+func (c *Client) BatchRunTasks(ctx context.Context, qname string, state TaskState, ids []string) (*BatchResult, error) {
+	var resp struct {
+		PendingIDs []string `json:"pending_ids"`
+		ErrorIDs   []string `json:"error_ids"`
+	}
+	if err := c.do(ctx, http.MethodPost, tasksPath(qname, "", state)+":batch_run", nil, &batchRequest{ids}, &resp); err != nil {
+		return nil, err
+	}
+	return &BatchResult{SucceededIDs: resp.PendingIDs, FailedIDs: resp.ErrorIDs}, nil
+}
+
+// BatchArchiveTasks moves the given tasks in the given state to archived state.
+func (c *Client) BatchArchiveTasks(ctx context.Context, qname string, state TaskState, ids []string) (*BatchResult, error) {
+	var resp struct {
+		ArchivedIDs []string `json:"archived_ids"`
+		ErrorIDs    []string `json:"error_ids"`
+	}
+	if err := c.do(ctx, http.MethodPost, tasksPath(qname, "", state)+":batch_archive", nil, &batchRequest{ids}, &resp); err != nil {
+		return nil, err
+	}
+	return &BatchResult{SucceededIDs: resp.ArchivedIDs, FailedIDs: resp.ErrorIDs}, nil
+}
+
+// BatchCancelTasks sends a cancelation signal to the given active tasks.
+func (c *Client) BatchCancelTasks(ctx context.Context, qname string, ids []string) (*BatchResult, error) {
+	var resp struct {
+		CanceledIDs []string `json:"canceled_ids"`
+		ErrorIDs    []string `json:"error_ids"`
+	}
+	if err := c.do(ctx, http.MethodPost, tasksPath(qname, "", TaskStateActive)+":batch_cancel", nil, &batchRequest{ids}, &resp); err != nil {
+		return nil, err
+	}
+	return &BatchResult{SucceededIDs: resp.CanceledIDs, FailedIDs: resp.ErrorIDs}, nil
+}
+
+// ScheduleOptions specifies when to process tasks moved to the scheduled state.
+// Exactly one of the fields must be set.
+type ScheduleOptions struct {
+	// Time to process the tasks at.
+	ProcessAt time.Time
+	// Duration from now to process the tasks after.
+	ProcessIn time.Duration
+}
+
+type scheduleRequest struct {
+	TaskIDs   []string `json:"task_ids,omitempty"`
+	TaskType  string   `json:"task_type,omitempty"`
+	ProcessAt string   `json:"process_at,omitempty"`
+	ProcessIn string   `json:"process_in,omitempty"`
+}
+
+func (o *ScheduleOptions) request() *scheduleRequest {
+	var req scheduleRequest
+	if !o.ProcessAt.IsZero() {
+		req.ProcessAt = o.ProcessAt.Format(time.RFC3339)
+	}
+	if o.ProcessIn > 0 {
+		req.ProcessIn = o.ProcessIn.String()
+	}
+	return &req
+}
+
+// BatchSchedulePendingTasks moves the given pending tasks to scheduled state.
+func (c *Client) BatchSchedulePendingTasks(ctx context.Context, qname string, ids []string, opts ScheduleOptions) (*BatchResult, error) {
+	req := opts.request()
+	req.TaskIDs = ids
+	var resp struct {
+		ScheduledIDs []string `json:"scheduled_ids"`
+		ErrorIDs     []string `json:"error_ids"`
+	}
+	if err := c.do(ctx, http.MethodPost, tasksPath(qname, "", TaskStatePending)+":batch_schedule", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &BatchResult{SucceededIDs: resp.ScheduledIDs, FailedIDs: resp.ErrorIDs}, nil
+}
+
+// ScheduleAllPendingTasks moves all pending tasks in the given queue to scheduled state and
+// returns the number of tasks moved. If taskType is not empty, only tasks of the type are moved.
+func (c *Client) ScheduleAllPendingTasks(ctx context.Context, qname, taskType string, opts ScheduleOptions) (int, error) {
+	req := opts.request()
+	req.TaskType = taskType
+	var resp struct {
+		Scheduled int `json:"scheduled"`
+	}
+	if err := c.do(ctx, http.MethodPost, tasksPath(qname, "", TaskStatePending)+":schedule_all", nil, req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Scheduled, nil
+}
+
+// RescheduleTask changes the process time of the given task in the given state and
+// returns the new process time. state must be scheduled or retry.
+func (c *Client) RescheduleTask(ctx context.Context, qname string, state TaskState, id string, opts ScheduleOptions) (time.Time, error) {
+	var resp struct {
+		NextProcessAt time.Time `json:"next_process_at"`
+	}
+	if err := c.do(ctx, http.MethodPost, tasksPath(qname, "", state)+"/"+escape(id)+":reschedule", nil, opts.request(), &resp); err != nil {
+		return time.Time{}, err
+	}
+	return resp.NextProcessAt, nil
+}
+
+type moveRequest struct {
+	TaskIDs []string `json:"task_ids,omitempty"`
+	Queue   string   `json:"queue"`
+}
+
+// MoveTask moves the given task to the destination queue, keeping its ID, type, payload, and options.
+// The task must be pending, scheduled, retry, or aggregating.
+func (c *Client) MoveTask(ctx context.Context, qname, id, dst string) error {
+	return c.do(ctx, http.MethodPost, "/queues/"+escape(qname)+"/tasks/"+escape(id)+":move", nil, &moveRequest{Queue: dst}, nil)
+}
+
+// BatchMoveTasks moves the given tasks to the destination queue.
+func (c *Client) BatchMoveTasks(ctx context.Context, qname string, ids []string, dst string) (*BatchResult, error) {
+	var resp struct {
+		MovedIDs []string `json:"moved_ids"`
+		ErrorIDs []string `json:"error_ids"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/queues/"+escape(qname)+"/tasks:batch_move", nil, &moveRequest{TaskIDs: ids, Queue: dst}, &resp); err != nil {
+		return nil, err
+	}
+	return &BatchResult{SucceededIDs: resp.MovedIDs, FailedIDs: resp.ErrorIDs}, nil
+}
+
+// EnqueueTaskRequest specifies a task to enqueue with EnqueueTask.
+// Fields other than Type are optional.
+type EnqueueTaskRequest struct {
+	// Type of the task.
+	Type string
+	// Payload of the task, encoded as JSON. If nil, the task has no payload.
+	Payload interface{}
+	// ID of the task. Random ID is generated if empty.
+	TaskID string
+	// Maximum number of times the task is retried. Default is 25.
+	MaxRetry *int
+	// Duration the task can be processed before it is retried.
+	Timeout time.Duration
+	// Time by which the task must be processed.
+	Deadline time.Time
+	// Duration during which no other task with the same type and payload can be enqueued.
+	Unique time.Duration
+	// Duration the task is retained after it is processed successfully.
+	Retention time.Duration
+	// Group to aggregate the task into.
+	Group string
+	// Time to process the task at. At most one of ProcessAt and ProcessIn can be set.
+	// The task is processed immediately if neither is set.
+	ProcessAt time.Time
+	ProcessIn time.Duration
+}
+
+type enqueueRequest struct {
+	Type      string      `json:"type"`
+	Payload   interface{} `json:"payload,omitempty"`
+	TaskID    string      `json:"task_id,omitempty"`
+	MaxRetry  *int        `json:"max_retry,omitempty"`
+	Timeout   string      `json:"timeout,omitempty"`
+	Deadline  string      `json:"deadline,omitempty"`
+	Unique    string      `json:"unique,omitempty"`
+	Retention string      `json:"retention,omitempty"`
+	Group     string      `json:"group,omitempty"`
+	ProcessAt string      `json:"process_at,omitempty"`
+	ProcessIn string      `json:"process_in,omitempty"`
+}
+
+// EnqueueTask enqueues a task in the given queue and returns the enqueued task.
+// The payload is encrypted by the server if it is configured to do so.
+func (c *Client) EnqueueTask(ctx context.Context, qname string, task *EnqueueTaskRequest) (*TaskInfo, error) {
+	sched := ScheduleOptions{ProcessAt: task.ProcessAt, ProcessIn: task.ProcessIn}
+	req := &enqueueRequest{
+		Type:      task.Type,
+		Payload:   task.Payload,
+		TaskID:    task.TaskID,
+		MaxRetry:  task.MaxRetry,
+		Group:     task.Group,
+		ProcessAt: sched.request().ProcessAt,
+		ProcessIn: sched.request().ProcessIn,
+	}
+	if task.Timeout > 0 {
+		req.Timeout = task.Timeout.String()
+	}
+	if !task.Deadline.IsZero() {
+		req.Deadline = task.Deadline.Format(time.RFC3339)
+	}
+	if task.Unique > 0 {
+		req.Unique = task.Unique.String()
+	}
+	if task.Retention > 0 {
+		req.Retention = task.Retention.String()
+	}
+	var resp TaskInfo
+	if err := c.do(ctx, http.MethodPost, "/queues/"+escape(qname)+"/tasks", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ExportTasks calls fn with each task in the given state which matches the filter,
+// as they are streamed by the server. filter is optional. state must not be aggregating.
+// Export stops at the first error returned by fn.
+func (c *Client) ExportTasks(ctx context.Context, qname string, state TaskState, filter *TaskFilter, fn func(*ExportedTask) error) error {
+	q := (&ListOptions{Filter: filter}).query()
+	resp, err := c.send(ctx, http.MethodGet, "/queues/"+escape(qname)+"/"+string(state)+"/export", q, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var t ExportedTask
+		if err := dec.Decode(&t); err != nil {
+			return err
+		}
+		if err := fn(&t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportOptions specifies how ImportTasks enqueues tasks.
+type ImportOptions struct {
+	// Set DryRun to true to validate the tasks and check for duplicate IDs without enqueueing them.
+	DryRun bool
+	// Set NewIDs to true to enqueue the tasks with new IDs instead of their original IDs.
+	// By default, importing the same tasks twice does not duplicate them.
+	NewIDs bool
+}
+
+// ImportTasks enqueues the tasks, typically returned by ExportTasks, in the given queue.
+// At most 1000 tasks can be imported at once; split larger exports into batches.
+// Scheduled and retry tasks are scheduled at their original process time if it has not passed,
+// and other tasks are processed immediately.
+func (c *Client) ImportTasks(ctx context.Context, qname string, tasks []*ExportedTask, opts *ImportOptions) (*ImportResult, error) {
+	q := url.Values{}
+	if opts != nil && opts.DryRun {
+		q.Set("dry_run", "true")
+	}
+	if opts != nil && opts.NewIDs {
+		q.Set("new_ids", "true")
+	}
+	var resp ImportResult
+	if err := c.do(ctx, http.MethodPost, "/queues/"+escape(qname)+"/tasks:import", q, tasks, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListTaskPolicies returns the retry and timeout options tasks were enqueued with,
+// aggregated by task type. If qname is empty, tasks from all queues are sampled.
+func (c *Client) ListTaskPolicies(ctx context.Context, qname string) ([]*TaskPolicy, error) {
+	q := url.Values{}
+	if qname != "" {
+		q.Set("queue", qname)
+	}
+	var resp struct {
+		Policies []*TaskPolicy `json:"policies"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/task_policies", q, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Policies, nil
+}
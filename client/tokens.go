@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// ListAPITokens returns the API tokens managed with the API, oldest first.
+// Secret values are not included.
+func (c *Client) ListAPITokens(ctx context.Context) ([]*APIToken, error) {
+	var resp struct {
+		Tokens []*APIToken `json:"tokens"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/tokens", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tokens, nil
+}
+
+// CreateAPIToken creates an API token. The returned token includes its secret value,
+// which cannot be retrieved later.
+func (c *Client) CreateAPIToken(ctx context.Context, req *CreateAPITokenRequest) (*APIToken, error) {
+	var resp APIToken
+	if err := c.do(ctx, http.MethodPost, "/tokens", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// RotateAPIToken replaces the secret value of the API token with the given ID
+// and returns the token with its new secret value.
+func (c *Client) RotateAPIToken(ctx context.Context, id string) (*APIToken, error) {
+	var resp APIToken
+	if err := c.do(ctx, http.MethodPost, "/tokens/"+escape(id)+":rotate", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// RevokeAPIToken deletes the API token with the given ID.
+func (c *Client) RevokeAPIToken(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/tokens/"+escape(id), nil, nil, nil)
+}
@@ -0,0 +1,491 @@
+package client
+
+import "time"
+
+// Queue holds the state of a queue at a point in time.
+type Queue struct {
+	Queue           string `json:"queue"`
+	MemoryUsage     int64  `json:"memory_usage_bytes"`
+	Size            int    `json:"size"`
+	Groups          int    `json:"groups"`
+	LatencyMillisec int64  `json:"latency_msec"`
+	DisplayLatency  string `json:"display_latency"`
+
+	Active      int `json:"active"`
+	Pending     int `json:"pending"`
+	Aggregating int `json:"aggregating"`
+	Scheduled   int `json:"scheduled"`
+	Retry       int `json:"retry"`
+	Archived    int `json:"archived"`
+	Completed   int `json:"completed"`
+
+	Processed int `json:"processed"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+
+	Paused    bool      `json:"paused"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// Growth is nil if not enough samples have been collected yet.
+	Growth *QueueGrowth `json:"growth,omitempty"`
+
+	// Drain is nil if not enough samples have been collected yet.
+	Drain *QueueDrainEstimate `json:"drain,omitempty"`
+}
+
+// QueueGrowth holds the growth rates of a queue.
+type QueueGrowth struct {
+	// Trend is one of "filling", "draining", or "steady".
+	Trend string             `json:"trend"`
+	Rates []*QueueGrowthRate `json:"rates"`
+}
+
+// QueueGrowthRate holds the rates of a queue over a time window.
+type QueueGrowthRate struct {
+	Window             string  `json:"window"`
+	EnqueuedPerMinute  float64 `json:"enqueued_per_minute"`
+	ProcessedPerMinute float64 `json:"processed_per_minute"`
+	SizeDeltaPerMinute float64 `json:"size_delta_per_minute"`
+}
+
+// QueueDrainEstimate holds the estimated time for a queue to drain its pending tasks.
+type QueueDrainEstimate struct {
+	Pending            int     `json:"pending"`
+	ProcessedPerMinute float64 `json:"processed_per_minute"`
+	NetDrainPerMinute  float64 `json:"net_drain_per_minute"`
+	NetDrainStdDev     float64 `json:"net_drain_stddev"`
+	// DrainSeconds is nil if the backlog is not draining.
+	DrainSeconds    *float64 `json:"drain_seconds"`
+	DrainSecondsMin *float64 `json:"drain_seconds_min"`
+	DrainSecondsMax *float64 `json:"drain_seconds_max"`
+	DrainAt         string   `json:"drain_at"`
+	// Confidence is one of "high", "medium", or "low".
+	Confidence string `json:"confidence"`
+	Window     string `json:"window"`
+}
+
+// DailyStats holds the stats of a queue for a given day.
+type DailyStats struct {
+	Queue     string `json:"queue"`
+	Processed int    `json:"processed"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+	Date      string `json:"date"`
+}
+
+// QueueDetails holds the current state and the recent history of a queue.
+type QueueDetails struct {
+	Current *Queue        `json:"current"`
+	History []*DailyStats `json:"history"`
+}
+
+// Group holds the state of a group of aggregating tasks.
+type Group struct {
+	Group string `json:"group"`
+	Size  int    `json:"size"`
+}
+
+// TaskState is the state of a task.
+type TaskState string
+
+// Task states.
+const (
+	TaskStateActive      TaskState = "active"
+	TaskStatePending     TaskState = "pending"
+	TaskStateAggregating TaskState = "aggregating"
+	TaskStateScheduled   TaskState = "scheduled"
+	TaskStateRetry       TaskState = "retry"
+	TaskStateArchived    TaskState = "archived"
+	TaskStateCompleted   TaskState = "completed"
+)
+
+// Task is an item in a list of tasks.
+// Fields which do not apply to the state of the listed tasks have zero values.
+type Task struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Payload   string `json:"payload"`
+	Queue     string `json:"queue"`
+	MaxRetry  int    `json:"max_retry"`
+	Retried   int    `json:"retried"`
+	LastError string `json:"error_message"`
+
+	// Size of the payload in bytes as stored in redis, and after decompression.
+	// DecompressedPayloadSize is zero if the payload is not compressed.
+	PayloadSize             int `json:"payload_size_bytes"`
+	DecompressedPayloadSize int `json:"decompressed_payload_size_bytes"`
+
+	// Active tasks only.
+	// Value is either time formatted in RFC3339 format, or "-" if not available yet.
+	Started    string `json:"start_time"`
+	Deadline   string `json:"deadline"`
+	IsOrphaned bool   `json:"is_orphaned"`
+
+	// Aggregating tasks only.
+	Group string `json:"group"`
+
+	// Scheduled and retry tasks only.
+	NextProcessAt time.Time `json:"next_process_at"`
+
+	// Archived tasks only.
+	LastFailedAt time.Time `json:"last_failed_at"`
+
+	// Completed tasks only.
+	CompletedAt time.Time `json:"completed_at"`
+	Result      string    `json:"result"`
+	TTLSeconds  int64     `json:"ttl_seconds"`
+}
+
+// TaskList is a page of tasks along with the current state of the queue.
+type TaskList struct {
+	Tasks      []*Task     `json:"tasks"`
+	Stats      *Queue      `json:"stats"`
+	Pagination *Pagination `json:"pagination"`
+	// Search is set if the list is filtered with ListOptions.Filter.
+	Search *TaskSearch `json:"search"`
+}
+
+// TaskSearch describes the search of a list filtered with ListOptions.Filter.
+type TaskSearch struct {
+	// Number of tasks checked against the filter.
+	Scanned int `json:"scanned"`
+	// Truncated is true if the server stopped searching before the end of the list,
+	// so that tasks further in the list are not returned.
+	Truncated bool `json:"truncated"`
+}
+
+// Pagination describes a page of a list.
+// Pointer fields are nil if the value is not known or there is no such page.
+type Pagination struct {
+	Page       int  `json:"page"`
+	Size       int  `json:"size"`
+	Total      *int `json:"total"`
+	TotalPages *int `json:"total_pages"`
+	// Page numbers to set in ListOptions.Page to get the next and previous pages.
+	NextPage *int `json:"next_page"`
+	PrevPage *int `json:"prev_page"`
+}
+
+// TaskInfo holds the details of a task.
+// Time values are formatted in RFC3339 format, or empty if not applicable.
+type TaskInfo struct {
+	ID            string      `json:"id"`
+	Queue         string      `json:"queue"`
+	Type          string      `json:"type"`
+	Payload       string      `json:"payload"`
+	State         string      `json:"state"`
+	MaxRetry      int         `json:"max_retry"`
+	Retried       int         `json:"retried"`
+	LastError     string      `json:"error_message"`
+	LastFailedAt  string      `json:"last_failed_at"`
+	Timeout       int         `json:"timeout_seconds"`
+	Deadline      string      `json:"deadline"`
+	NextProcessAt string      `json:"next_process_at"`
+	CompletedAt   string      `json:"completed_at"`
+	Result        string      `json:"result"`
+	TTLSeconds    int64       `json:"ttl_seconds"`
+	Links         []*TaskLink `json:"links"`
+	TraceID       string      `json:"trace_id"`
+	TraceURL      string      `json:"trace_url"`
+
+	// Size of the payload in bytes as stored in redis, and after decompression.
+	// DecompressedPayloadSize is zero if the payload is not compressed.
+	PayloadSize             int `json:"payload_size_bytes"`
+	DecompressedPayloadSize int `json:"decompressed_payload_size_bytes"`
+}
+
+// ExportedTask is a task as exported by ExportTasks and imported by ImportTasks.
+// Payload and Result are the bytes stored in redis, which are not formatted or decrypted.
+type ExportedTask struct {
+	ID            string `json:"id"`
+	Type          string `json:"type"`
+	Payload       []byte `json:"payload"`
+	Queue         string `json:"queue"`
+	State         string `json:"state"`
+	MaxRetry      *int   `json:"max_retry"`
+	Retried       int    `json:"retried"`
+	LastError     string `json:"error_message"`
+	LastFailedAt  string `json:"last_failed_at"`
+	Timeout       int    `json:"timeout_seconds"`
+	Deadline      string `json:"deadline"`
+	NextProcessAt string `json:"next_process_at"`
+	CompletedAt   string `json:"completed_at"`
+	Retention     int    `json:"retention_seconds"`
+	Result        []byte `json:"result"`
+}
+
+// ImportResult is the result of ImportTasks.
+type ImportResult struct {
+	// Number of tasks enqueued, or which would be enqueued in a dry run.
+	Enqueued int `json:"enqueued"`
+	// Number of tasks skipped since a task with the same ID exists in the queue.
+	Duplicates int `json:"duplicates"`
+	// Number of invalid tasks and tasks which could not be enqueued.
+	Failed int `json:"failed"`
+	// Errors of the failed tasks. Only the first 100 errors are returned.
+	Errors []*ImportError `json:"errors"`
+	DryRun bool           `json:"dry_run"`
+}
+
+// ImportError is the error of a task which could not be imported.
+type ImportError struct {
+	// Position of the task in the imported tasks, starting from 0.
+	Index int    `json:"index"`
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// TaskLink is a link into an external system configured for a task type.
+type TaskLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// BatchResult is the result of a batch operation on tasks.
+type BatchResult struct {
+	// IDs of the tasks the operation succeeded for.
+	SucceededIDs []string
+	// IDs of the tasks the operation failed for.
+	FailedIDs []string
+}
+
+// Server holds information about a running asynq server.
+type Server struct {
+	ID             string         `json:"id"`
+	Host           string         `json:"host"`
+	PID            int            `json:"pid"`
+	Concurrency    int            `json:"concurrency"`
+	Queues         map[string]int `json:"queue_priorities"`
+	StrictPriority bool           `json:"strict_priority_enabled"`
+	Started        string         `json:"start_time"`
+	Status         string         `json:"status"`
+	ActiveWorkers  []*Worker      `json:"active_workers"`
+}
+
+// ServerEvent records a server joining ("join") or leaving ("leave").
+type ServerEvent struct {
+	Type        string         `json:"type"`
+	ServerID    string         `json:"server_id"`
+	Host        string         `json:"host"`
+	PID         int            `json:"pid"`
+	Concurrency int            `json:"concurrency"`
+	Queues      map[string]int `json:"queue_priorities"`
+	Time        string         `json:"time"`
+}
+
+// ServerUtilizationReport holds the worker utilization of servers over a time window.
+// Utilizations are percentages of workers which are busy.
+type ServerUtilizationReport struct {
+	Window      string               `json:"window"`
+	Utilization float64              `json:"utilization"`
+	Servers     []*ServerUtilization `json:"servers"`
+}
+
+// ServerUtilization holds the worker utilization of a server over a time window.
+type ServerUtilization struct {
+	ID              string                     `json:"id"`
+	Host            string                     `json:"host"`
+	PID             int                        `json:"pid"`
+	Concurrency     int                        `json:"concurrency"`
+	LastSeen        string                     `json:"last_seen"`
+	Utilization     float64                    `json:"utilization"`
+	AvgUtilization  float64                    `json:"avg_utilization"`
+	PeakUtilization float64                    `json:"peak_utilization"`
+	Samples         []*ServerUtilizationSample `json:"samples"`
+}
+
+// ServerUtilizationSample holds the number of busy workers of a server at a point in time.
+type ServerUtilizationSample struct {
+	Time          string  `json:"time"`
+	ActiveWorkers int     `json:"active_workers"`
+	Concurrency   int     `json:"concurrency"`
+	Utilization   float64 `json:"utilization"`
+}
+
+// Worker holds information about a task being processed by a server.
+type Worker struct {
+	TaskID      string `json:"task_id"`
+	Queue       string `json:"queue"`
+	TaskType    string `json:"task_type"`
+	TaskPayload string `json:"task_payload"`
+	Started     string `json:"start_time"`
+}
+
+// SchedulerEntry holds information about a periodic task registered with a scheduler.
+type SchedulerEntry struct {
+	ID            string   `json:"id"`
+	Spec          string   `json:"spec"`
+	TaskType      string   `json:"task_type"`
+	TaskPayload   string   `json:"task_payload"`
+	Options       []string `json:"options"`
+	NextEnqueueAt string   `json:"next_enqueue_at"`
+	// Empty if there were no previous enqueue events.
+	PrevEnqueueAt string `json:"prev_enqueue_at"`
+}
+
+// SchedulerEnqueueEvent holds information about a task enqueued by a scheduler.
+type SchedulerEnqueueEvent struct {
+	TaskID     string `json:"task_id"`
+	EnqueuedAt string `json:"enqueued_at"`
+}
+
+// TaskPolicy holds the retry and timeout options tasks of a type were enqueued with,
+// aggregated over a sample of tasks.
+type TaskPolicy struct {
+	TaskType       string              `json:"task_type"`
+	Queues         []string            `json:"queues"`
+	Sampled        int                 `json:"sampled"`
+	MaxRetry       []*PolicyValueCount `json:"max_retry"`
+	TimeoutSeconds []*PolicyValueCount `json:"timeout_seconds"`
+	WithDeadline   int                 `json:"with_deadline"`
+	Consistent     bool                `json:"consistent"`
+}
+
+// PolicyValueCount is the number of sampled tasks which have the value.
+type PolicyValueCount struct {
+	Value int `json:"value"`
+	Count int `json:"count"`
+}
+
+// RedisLatency holds recent PING latencies and the output of LATENCY commands.
+type RedisLatency struct {
+	PingSamples []*RedisPingSample `json:"ping_samples"`
+	// Nil if no PING succeeded yet.
+	PingStats *RedisPingStats      `json:"ping_stats"`
+	Events    []*RedisLatencyEvent `json:"events"`
+	Doctor    string               `json:"doctor"`
+	// Non-empty if LATENCY commands could not be run.
+	LatencyError string `json:"latency_error"`
+}
+
+// RedisPingSample is the result of a single PING sent by the server.
+type RedisPingSample struct {
+	Time          string  `json:"time"`
+	LatencyMillis float64 `json:"latency_ms"`
+	Error         string  `json:"error"`
+}
+
+// RedisPingStats summarizes the successful PING samples.
+type RedisPingStats struct {
+	MinMillis  float64 `json:"min_ms"`
+	AvgMillis  float64 `json:"avg_ms"`
+	MaxMillis  float64 `json:"max_ms"`
+	LastMillis float64 `json:"last_ms"`
+	Errors     int     `json:"errors"`
+}
+
+// RedisLatencyEvent is a latency event reported by redis latency monitor.
+type RedisLatencyEvent struct {
+	Event        string                    `json:"event"`
+	Time         string                    `json:"time"`
+	LatestMillis int64                     `json:"latest_ms"`
+	MaxMillis    int64                     `json:"max_ms"`
+	History      []*RedisLatencyEventPoint `json:"history"`
+}
+
+// RedisLatencyEventPoint is a latency spike of an event.
+type RedisLatencyEventPoint struct {
+	Time          string `json:"time"`
+	LatencyMillis int64  `json:"latency_ms"`
+}
+
+// RedisFailover is a failover of the redis master reported by a sentinel.
+type RedisFailover struct {
+	Time       string `json:"time"`
+	Master     string `json:"master"`
+	OldAddress string `json:"old_address"`
+	NewAddress string `json:"new_address"`
+}
+
+// Health holds the health of the server.
+type Health struct {
+	// Status is "ok" or "degraded".
+	Status string `json:"status"`
+	// Nil if the circuit breaker is disabled.
+	RedisCircuitBreaker *CircuitBreakerState `json:"redis_circuit_breaker"`
+	// True if the server is connected to a read-only replica.
+	RedisReadOnly      bool   `json:"redis_read_only"`
+	RedisMasterAddress string `json:"redis_master_address"`
+}
+
+// CircuitBreakerState holds the state of the circuit breaker for redis.
+type CircuitBreakerState struct {
+	// State is one of "closed", "open", or "half-open".
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	OpenedAt            string `json:"opened_at"`
+	LastError           string `json:"last_error"`
+}
+
+// APIToken is an API token managed with the API.
+type APIToken struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Times are in RFC3339 format.
+	CreatedAt string `json:"created_at"`
+	// Empty if the token does not expire.
+	ExpiresAt string `json:"expires_at"`
+	// Empty if the token has never been used.
+	LastUsedAt string `json:"last_used_at"`
+	Expired    bool   `json:"expired"`
+	// Token is the secret value of the token.
+	// Only set in the responses of CreateAPIToken and RotateAPIToken.
+	Token string `json:"token,omitempty"`
+}
+
+// CreateAPITokenRequest holds the parameters of a new API token.
+type CreateAPITokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is the time the token expires in RFC3339 format.
+	// The token does not expire if empty.
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// AlertRule is a condition asynqmon sends notifications about.
+// See the documentation of asynqmon.AlertRule for the types and thresholds of rules.
+type AlertRule struct {
+	// Name identifies the rule. Default is the type followed by the queue name, if any.
+	Name string `json:"name"`
+	// Type is one of "queue_size", "archived_tasks", "latency", "processing_silence",
+	// "server_disappeared", "anomaly", or "scheduler_enqueue_failures".
+	Type string `json:"type"`
+	// Queue is the name of the queue the rule applies to. Empty for all queues.
+	Queue string `json:"queue"`
+	// ForSeconds is how long the condition must hold before the alert fires.
+	ForSeconds int64 `json:"for_seconds"`
+	// Severity is one of "info", "warning", or "critical". Default is "critical".
+	Severity  string  `json:"severity"`
+	Threshold float64 `json:"threshold"`
+	// Managed is true if the rule is managed with the API, and false if it is
+	// configured with the options of the deployment. It is set by the server.
+	Managed bool `json:"managed"`
+}
+
+// PayloadStats holds payload sizes of a sample of tasks in a queue.
+type PayloadStats struct {
+	// Maximum number of tasks sampled in each state.
+	SampleSize int                 `json:"sample_size"`
+	Total      *PayloadSizeStats   `json:"total"`
+	Types      []*TypePayloadStats `json:"types"`
+}
+
+// PayloadSizeStats holds the sizes of sampled task payloads.
+// Sizes are in bytes as stored in redis unless noted otherwise.
+type PayloadSizeStats struct {
+	Count               int   `json:"count"`
+	CompressedCount     int   `json:"compressed_count"`
+	SizeBytes           int64 `json:"size_bytes"`
+	MaxSizeBytes        int   `json:"max_size_bytes"`
+	CompressedSizeBytes int64 `json:"compressed_size_bytes"`
+	// Total size of the compressed payloads after decompression.
+	DecompressedSizeBytes int64   `json:"decompressed_size_bytes"`
+	CompressionRatio      float64 `json:"compression_ratio"`
+}
+
+// TypePayloadStats holds the sizes of sampled task payloads of a task type.
+type TypePayloadStats struct {
+	Type string `json:"type"`
+	PayloadSizeStats
+}
@@ -0,0 +1,171 @@
+package asynqmon
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - Cluster to monitor several redis connections from one handler
+//   - http.Handler(s) for cluster related endpoints
+// ****************************************************************************
+
+// Cluster is a named redis connection monitored by the handler.
+type Cluster struct {
+	// Name identifies the cluster in the URL (e.g. "prod-us").
+	// It must be unique and consist of letters, digits, hyphens, and underscores.
+	Name string
+
+	// RedisConnOpt specifies the connection to a redis-server or redis-cluster.
+	RedisConnOpt asynq.RedisConnOpt
+}
+
+var clusterNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+func validateClusters(clusters []Cluster) error {
+	names := make(map[string]bool)
+	for _, c := range clusters {
+		if !clusterNameRegexp.MatchString(c.Name) {
+			return fmt.Errorf("invalid cluster name %q: must consist of letters, digits, hyphens, and underscores", c.Name)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate cluster name %q", c.Name)
+		}
+		names[c.Name] = true
+		if c.RedisConnOpt == nil {
+			return fmt.Errorf("cluster %q has no RedisConnOpt", c.Name)
+		}
+	}
+	return nil
+}
+
+// clusterContext is passed to the handler of each cluster when the handler
+// monitors multiple clusters.
+type clusterContext struct {
+	// Name of the cluster served by the handler.
+	name string
+	// Names of all clusters in the order they are configured.
+	names []string
+	// Root path of the handler of all clusters, without the trailing slash.
+	rootPath string
+	// Maintenance mode of the whole dashboard, shared by all clusters.
+	maintenance *maintenanceMode
+}
+
+// clusterPath returns the path of the cluster relative to the root path.
+func clusterPath(name string) string {
+	return "/clusters/" + name
+}
+
+// newMultiClusterHandler returns a HTTPHandler which serves each of opts.Clusters
+// with a HTTPHandler under "<RootPath>/clusters/<name>", and everything else with
+// the handler of the first cluster.
+func newMultiClusterHandler(opts Options) *HTTPHandler {
+	if opts.RedisConnOpt != nil {
+		panic("asynqmon.New: RedisConnOpt and Clusters fields cannot be set at the same time")
+	}
+	if err := validateClusters(opts.Clusters); err != nil {
+		panic(fmt.Sprintf("asynqmon.New: %v", err))
+	}
+	if opts.RootPath != "" && !strings.HasPrefix(opts.RootPath, "/") {
+		panic(fmt.Sprintf("asynqmon.New: RootPath must start with a slash"))
+	}
+	rootPath := strings.TrimSuffix(opts.RootPath, "/")
+
+	// Users signed in with the OIDC provider stay signed in across clusters.
+	if opts.OIDC != nil && opts.OIDC.SessionSecret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(fmt.Sprintf("asynqmon.New: %v", err))
+		}
+		oidc := *opts.OIDC
+		oidc.SessionSecret = hex.EncodeToString(secret)
+		opts.OIDC = &oidc
+	}
+
+	names := make([]string, len(opts.Clusters))
+	for i, c := range opts.Clusters {
+		names[i] = c.Name
+	}
+	// Maintenance mode applies to the whole dashboard, so it is stored in the redis of the first cluster.
+	rc, ok := opts.Clusters[0].RedisConnOpt.MakeRedisClient().(redis.UniversalClient)
+	if !ok {
+		panic(fmt.Sprintf("asynqmon.New: unsupported RedisConnOpt type %T", opts.Clusters[0].RedisConnOpt))
+	}
+	maintenance := newMaintenanceMode(rc)
+	router := mux.NewRouter()
+	var handlers []*HTTPHandler
+	closers := []func() error{rc.Close}
+	for _, c := range opts.Clusters {
+		copts := opts
+		copts.Clusters = nil
+		copts.RedisConnOpt = c.RedisConnOpt
+		copts.RootPath = rootPath + clusterPath(c.Name)
+		copts.DetectRootPath = false
+		copts.RootPathFunc = nil
+		h := newHandler(copts, &clusterContext{name: c.Name, names: names, rootPath: rootPath, maintenance: maintenance})
+		router.PathPrefix(copts.RootPath + "/").Handler(h.router)
+		router.Path(copts.RootPath).Handler(h.router)
+		handlers = append(handlers, h)
+		closers = append(closers, h.Close)
+	}
+	// Serve requests outside of the cluster paths with the first cluster.
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, rootPath+"/") && r.URL.Path != rootPath {
+			http.NotFound(w, r)
+			return
+		}
+		if rest := strings.TrimPrefix(r.URL.Path, rootPath); strings.HasPrefix(rest, "/clusters/") {
+			name := strings.SplitN(strings.TrimPrefix(rest, "/clusters/"), "/", 2)[0]
+			writeErrorResponse(w, r, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("cluster %q not found", name))
+			return
+		}
+		u := *r.URL
+		u.Path = rootPath + clusterPath(names[0]) + strings.TrimPrefix(r.URL.Path, rootPath)
+		u.RawPath = ""
+		r2 := r.Clone(r.Context())
+		r2.URL = &u
+		handlers[0].router.ServeHTTP(w, r2)
+	})
+
+	return &HTTPHandler{
+		router:   router,
+		closers:  closers,
+		rootPath: rootPath,
+
+		detectRootPath: opts.DetectRootPath,
+		rootPathFunc:   opts.RootPathFunc,
+	}
+}
+
+type clusterInfo struct {
+	Name string `json:"name"`
+	// Root path of the Web UI and the API of the cluster as seen by the browser.
+	Path string `json:"path"`
+}
+
+type listClustersResponse struct {
+	Clusters []*clusterInfo `json:"clusters"`
+	// Name of the cluster which served the request.
+	Current string `json:"current"`
+}
+
+func newListClustersHandlerFunc(clusters *clusterContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := listClustersResponse{Current: clusters.name}
+		root := externalRootPath(r, clusters.rootPath)
+		for _, name := range clusters.names {
+			resp.Clusters = append(resp.Clusters, &clusterInfo{Name: name, Path: root + clusterPath(name)})
+		}
+		writeResponseJSON(w, resp)
+	}
+}
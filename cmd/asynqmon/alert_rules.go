@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// alertRulesConfig holds the thresholds used to generate prometheus alerting rules.
+type alertRulesConfig struct {
+	GroupName       string
+	Queues          string // regular expression to match queue names
+	QueueSize       int
+	QueueLatency    time.Duration
+	ErrorRate       float64
+	ErrorRateWindow time.Duration
+	For             time.Duration
+}
+
+// parseAlertRulesFlags parses the command-line arguments of alert-rules subcommand.
+func parseAlertRulesFlags(progname string, args []string) (cfg *alertRulesConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var conf alertRulesConfig
+	flags.StringVar(&conf.GroupName, "group-name", "asynq", "name of the rule group")
+	flags.StringVar(&conf.Queues, "queues", "", "regular expression to match names of the queues to alert on (default all queues)")
+	flags.IntVar(&conf.QueueSize, "queue-size", 1000, "alert if the number of tasks in a queue exceeds this value")
+	flags.DurationVar(&conf.QueueLatency, "queue-latency", 5*time.Minute, "alert if the oldest pending task has been waiting longer than this duration")
+	flags.Float64Var(&conf.ErrorRate, "error-rate", 0.1, "alert if the ratio of failed tasks to processed tasks exceeds this value")
+	flags.DurationVar(&conf.ErrorRateWindow, "error-rate-window", 5*time.Minute, "time window used to compute the error rate")
+	flags.DurationVar(&conf.For, "for", 5*time.Minute, "duration a condition must hold before the alert fires")
+
+	err = flags.Parse(args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	if conf.ErrorRate < 0 || conf.ErrorRate > 1 {
+		return nil, buf.String(), fmt.Errorf("-error-rate must be between 0 and 1")
+	}
+	return &conf, buf.String(), nil
+}
+
+// promDuration formats d in prometheus duration format (e.g. "5m", "1h30m").
+func promDuration(d time.Duration) string {
+	if d <= 0 {
+		return "0s"
+	}
+	var b strings.Builder
+	for _, u := range []struct {
+		unit time.Duration
+		name string
+	}{{time.Hour, "h"}, {time.Minute, "m"}, {time.Second, "s"}} {
+		if n := d / u.unit; n > 0 {
+			fmt.Fprintf(&b, "%d%s", n, u.name)
+			d -= n * u.unit
+		}
+	}
+	if b.Len() == 0 {
+		return "1s"
+	}
+	return b.String()
+}
+
+// The metric names match those exported with --enable-metrics-exporter.
+var alertRulesTmpl = template.Must(template.New("rules").Funcs(template.FuncMap{
+	"duration": promDuration,
+	"quote":    strconv.Quote,
+	"seconds":  func(d time.Duration) string { return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) },
+	"float":    func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) },
+}).Parse(`# Prometheus alerting rules for asynq generated by "asynqmon alert-rules".
+# Requires metrics exported by asynqmon with --enable-metrics-exporter.
+groups:
+  - name: {{quote .GroupName}}
+    rules:
+      - alert: AsynqQueueBacklog
+        expr: asynq_queue_size{{.Selector}} > {{.QueueSize}}
+        for: {{duration .For}}
+        labels:
+          severity: warning
+        annotations:
+          summary: "Queue {{"{{"}} $labels.queue {{"}}"}} has a backlog"
+          description: "Queue {{"{{"}} $labels.queue {{"}}"}} has {{"{{"}} $value {{"}}"}} tasks (threshold: {{.QueueSize}})."
+      - alert: AsynqQueueLatencyHigh
+        expr: asynq_queue_latency_seconds{{.Selector}} > {{seconds .QueueLatency}}
+        for: {{duration .For}}
+        labels:
+          severity: warning
+        annotations:
+          summary: "Tasks in queue {{"{{"}} $labels.queue {{"}}"}} are waiting too long"
+          description: "The oldest pending task in queue {{"{{"}} $labels.queue {{"}}"}} has been waiting for {{"{{"}} $value | humanizeDuration {{"}}"}} (threshold: {{duration .QueueLatency}})."
+      - alert: AsynqHighErrorRate
+        expr: |
+          sum by (queue) (rate(asynq_tasks_failed_total{{.Selector}}[{{duration .ErrorRateWindow}}]))
+            / sum by (queue) (rate(asynq_tasks_processed_total{{.Selector}}[{{duration .ErrorRateWindow}}])) > {{float .ErrorRate}}
+        for: {{duration .For}}
+        labels:
+          severity: critical
+        annotations:
+          summary: "High error rate in queue {{"{{"}} $labels.queue {{"}}"}}"
+          description: "{{"{{"}} $value | humanizePercentage {{"}}"}} of tasks processed in queue {{"{{"}} $labels.queue {{"}}"}} failed (threshold: {{float .ErrorRate}})."
+      - alert: AsynqNoWorkers
+        expr: asynqmon_queue_servers{{.Selector}} == 0
+        for: {{duration .For}}
+        labels:
+          severity: critical
+        annotations:
+          summary: "No workers are processing queue {{"{{"}} $labels.queue {{"}}"}}"
+          description: "No asynq server with a live heartbeat processes tasks from queue {{"{{"}} $labels.queue {{"}}"}}."
+      - alert: AsynqMetricsMissing
+        expr: absent(asynq_queue_size)
+        for: {{duration .For}}
+        labels:
+          severity: warning
+        annotations:
+          summary: "Asynq metrics are missing"
+          description: "Prometheus is not receiving metrics from the asynqmon metrics exporter."
+`))
+
+// writeAlertRules writes prometheus alerting rules in YAML to w.
+func writeAlertRules(w io.Writer, cfg *alertRulesConfig) error {
+	selector := ""
+	if cfg.Queues != "" {
+		selector = fmt.Sprintf("{queue=~%s}", strconv.Quote(cfg.Queues))
+	}
+	return alertRulesTmpl.Execute(w, struct {
+		*alertRulesConfig
+		Selector string
+	}{cfg, selector})
+}
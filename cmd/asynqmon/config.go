@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Keys of the config file which hold rules instead of flag values.
+// The rules have the same fields as the rules in the files given with the
+// --alert-rules-file and --formatters-config flags.
+const (
+	configKeyAlertRules = "alert-rules"
+	configKeyFormatters = "formatters"
+)
+
+// applyConfigFile sets the flags to the values in the config file at path.
+// Keys of the file are flag names (e.g. "redis-addr"), and values are strings,
+// numbers, or booleans. Flags which can be repeated take a list of values.
+// "${VAR}" and "$VAR" in strings are replaced with the value of the environment variable,
+// and "$$" with a literal "$".
+//
+// Flags given on the command line or with environment variables take precedence over the file.
+// The format of the file (YAML or TOML) is determined by its extension.
+func applyConfigFile(flags *flag.FlagSet, conf *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %v", err)
+	}
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		_, err = toml.Decode(string(data), &values)
+	default:
+		return fmt.Errorf("config file %q must have .yaml, .yml, or .toml extension", path)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid config file: %v", err)
+	}
+
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v := expandConfigValue(values[key])
+		switch key {
+		case configKeyAlertRules:
+			if err := decodeConfigValue(v, &conf.AlertRules); err != nil {
+				return fmt.Errorf("invalid config file: %s: %v", key, err)
+			}
+			continue
+		case configKeyFormatters:
+			if err := decodeConfigValue(v, &conf.FormatterRules); err != nil {
+				return fmt.Errorf("invalid config file: %s: %v", key, err)
+			}
+			continue
+		case "config":
+			return fmt.Errorf("invalid config file: %s cannot be set in config file", key)
+		}
+		f := flags.Lookup(key)
+		if f == nil {
+			return fmt.Errorf("invalid config file: unknown option %q", key)
+		}
+		_, list := f.Value.(*stringListValue)
+		vals, err := configValueStrings(v, list)
+		if err != nil {
+			return fmt.Errorf("invalid config file: %s: %v", key, err)
+		}
+		if set[key] || os.Getenv(flagEnvVar(f)) != "" {
+			continue
+		}
+		for _, s := range vals {
+			if err := f.Value.Set(s); err != nil {
+				return fmt.Errorf("invalid config file: %s: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// flagEnvVar returns the name of the environment variable of the flag,
+// e.g. REDIS_ADDR for --redis-addr, and TASK_LINKS for the repeatable --task-link.
+func flagEnvVar(f *flag.Flag) string {
+	name := strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+	if _, ok := f.Value.(*stringListValue); ok {
+		name += "S"
+	}
+	return name
+}
+
+// expandConfigValue replaces environment variables in the strings of the value.
+func expandConfigValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return os.Expand(v, func(name string) string {
+			if name == "$" {
+				return "$"
+			}
+			return os.Getenv(name)
+		})
+	case []interface{}:
+		for i, e := range v {
+			v[i] = expandConfigValue(e)
+		}
+		return v
+	case []map[string]interface{}:
+		for _, e := range v {
+			expandConfigValue(e)
+		}
+		return v
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = expandConfigValue(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// configValueStrings returns the value as strings to set a flag to.
+// Lists are only allowed if list is true.
+func configValueStrings(v interface{}, list bool) ([]string, error) {
+	if vs, ok := v.([]interface{}); ok {
+		if !list {
+			return nil, fmt.Errorf("must be a single value, not a list")
+		}
+		var out []string
+		for _, e := range vs {
+			s, err := configValueString(e)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	}
+	s, err := configValueString(v)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+func configValueString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("must be a string, number, or boolean")
+}
+
+// decodeConfigValue decodes the value into out as if it was JSON,
+// so that the rules in the config file are decoded the same way as the rules in JSON files.
+func decodeConfigValue(v, out interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(out)
+}
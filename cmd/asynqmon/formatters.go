@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hibiken/asynqmon"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// formatterRuleConfig is a rule in the file given with the --formatters-config flag.
+//
+// Payload and Result are "json", "msgpack", or "protobuf:<full message name>"
+// (e.g. "protobuf:acme.email.v1.WelcomeEmail"). Empty value leaves the format unchanged.
+type formatterRuleConfig struct {
+	TaskType string `json:"task_type"`
+	Payload  string `json:"payload"`
+	Result   string `json:"result"`
+}
+
+// parseFormatterRules parses the formatter rules in JSON format read from r.
+// Protobuf messages are looked up in the descriptor sets at protoPaths.
+func parseFormatterRules(r io.Reader, protoPaths []string) ([]asynqmon.FormatterRule, error) {
+	var configs []formatterRuleConfig
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&configs); err != nil {
+		return nil, fmt.Errorf("invalid formatters config: %v", err)
+	}
+	return formatterRules(configs, protoPaths)
+}
+
+// formatterRules returns the formatter rules of the configs.
+// Protobuf messages are looked up in the descriptor sets at protoPaths.
+func formatterRules(configs []formatterRuleConfig, protoPaths []string) ([]asynqmon.FormatterRule, error) {
+	var files *protoregistry.Files // loaded when a rule refers to a protobuf message
+	formatter := func(format string) (asynqmon.Formatter, error) {
+		switch {
+		case format == "":
+			return nil, nil
+		case format == "json":
+			return asynqmon.JSONFormatter, nil
+		case format == "msgpack":
+			return asynqmon.MsgpackFormatter, nil
+		case strings.HasPrefix(format, "protobuf:"):
+			if len(protoPaths) == 0 {
+				return nil, fmt.Errorf("format %q requires --proto-descriptor-set flag", format)
+			}
+			if files == nil {
+				var err error
+				if files, err = asynqmon.LoadProtoDescriptorSets(protoPaths...); err != nil {
+					return nil, err
+				}
+			}
+			return asynqmon.NewProtobufFormatter(files, strings.TrimPrefix(format, "protobuf:"))
+		}
+		return nil, fmt.Errorf("unknown format %q: want json, msgpack, or protobuf:<message name>", format)
+	}
+	var rules []asynqmon.FormatterRule
+	for _, c := range configs {
+		pf, err := formatter(c.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("formatter rule for %q: %v", c.TaskType, err)
+		}
+		rf, err := formatter(c.Result)
+		if err != nil {
+			return nil, fmt.Errorf("formatter rule for %q: %v", c.TaskType, err)
+		}
+		rules = append(rules, asynqmon.FormatterRule{TaskType: c.TaskType, PayloadFormatter: pf, ResultFormatter: rf})
+	}
+	return rules, nil
+}
+
+// makeFormatterRules returns the formatter rules in the config file followed by the ones in the
+// file given with the --formatters-config flag.
+// Formatted payloads and results are truncated as configured by the --max-payload-length and
+// --max-result-length flags.
+func makeFormatterRules(cfg *Config) ([]asynqmon.FormatterRule, error) {
+	rules, err := formatterRules(cfg.FormatterRules, cfg.ProtoDescriptorSets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid formatters in config file: %v", err)
+	}
+	if cfg.FormattersConfig != "" {
+		f, err := os.Open(cfg.FormattersConfig)
+		if err != nil {
+			return nil, fmt.Errorf("could not open formatters config: %v", err)
+		}
+		defer f.Close()
+		fileRules, err := parseFormatterRules(f, cfg.ProtoDescriptorSets)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+	for i, r := range rules {
+		if pf := r.PayloadFormatter; pf != nil {
+			rules[i].PayloadFormatter = asynqmon.PayloadFormatterFunc(func(taskType string, payload []byte) string {
+				return truncate(pf.FormatPayload(taskType, payload), cfg.MaxPayloadLength)
+			})
+		}
+		if rf := r.ResultFormatter; rf != nil {
+			rules[i].ResultFormatter = asynqmon.ResultFormatterFunc(func(taskType string, result []byte) string {
+				return truncate(rf.FormatResult(taskType, result), cfg.MaxResultLength)
+			})
+		}
+	}
+	return rules, nil
+}
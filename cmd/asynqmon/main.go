@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -18,6 +21,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/cors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // Config holds configurations for the program provided via the command line.
@@ -25,23 +30,122 @@ type Config struct {
 	// Server port
 	Port int
 
+	// Enable HTTP/2 cleartext (h2c) on the listener
+	EnableH2C bool
+
 	// Redis connection options
 	RedisAddr         string
 	RedisDB           int
+	RedisUsername     string
 	RedisPassword     string
 	RedisTLS          string
 	RedisURL          string
 	RedisInsecureTLS  bool
+	RedisCACert       string
+	RedisClientCert   string
+	RedisClientKey    string
+	RedisURIs         []string
 	RedisClusterNodes string
 
 	// UI related configs
-	ReadOnly         bool
-	MaxPayloadLength int
-	MaxResultLength  int
+	ReadOnly          bool
+	DisableMetrics    bool
+	DisableSchedulers bool
+	DisableRedisInfo  bool
+	MaxPayloadLength  int
+	MaxResultLength   int
+
+	// Formatter related configs
+	FormattersConfig    string
+	ProtoDescriptorSets []string
 
 	// Prometheus related configs
 	EnableMetricsExporter bool
 	PrometheusServerAddr  string
+	PrometheusTimeout     time.Duration
+	PrometheusMaxRange    time.Duration
+	PrometheusMinStep     time.Duration
+
+	// OpenTelemetry related configs
+	OTLPEndpoint string
+
+	// Metrics history related configs
+	MetricsHistoryInterval  time.Duration
+	MetricsHistoryRetention time.Duration
+
+	// Links into external systems shown in task details view.
+	// Each value is in "pattern|label|url-template" format.
+	TaskLinks []string
+
+	// Trace ID extraction configs
+	TraceIDPath string
+	TraceURL    string
+
+	// API related configs
+	BulkOperationThreshold int
+	IdempotencyKeyTTL      time.Duration
+	LargeIntsAsStrings     bool
+
+	// API tokens in "name|token|scope1,scope2" format.
+	APITokens []string
+
+	// Users who sign in with basic authentication in "username|password|role" format.
+	BasicAuthUsers []string
+
+	// OIDC related configs
+	OIDCIssuerURL     string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string
+	OIDCUsernameClaim string
+	OIDCRolesClaim    string
+	OIDCAdmins        string
+	OIDCSessionSecret string
+
+	// Redis related configs
+	RedisTimeout            time.Duration
+	CircuitBreakerThreshold int
+	CircuitBreakerCooldown  time.Duration
+
+	// Cache related configs
+	QueueInfoCacheTTL     time.Duration
+	KeyspaceNotifications bool
+
+	// Live updates related configs
+	LiveUpdateInterval time.Duration
+	DisableLiveUpdates bool
+
+	// Audit related configs
+	AuditLog         string
+	AuditRedisStream bool
+	AuditActorHeader string
+
+	// Notification related configs
+	SlackWebhookURL     string
+	WebhookURL          string
+	PagerDutyRoutingKey string
+	SMTPAddr            string
+	SMTPUsername        string
+	SMTPPassword        string
+	SMTPFrom            string
+	SMTPTo              string
+
+	// Alert related configs
+	AlertProcessingSilence time.Duration
+	AlertServerDisappeared time.Duration
+	AlertAnomalyThreshold  float64
+	AlertQueueSize         int
+	AlertLatency           time.Duration
+	AlertArchivedTasks     bool
+	AlertEnqueueFailures   int
+	AlertRulesFile         string
+
+	// Path of YAML or TOML file with values of the other flags
+	ConfigFile string
+
+	// Alert rules and formatter rules given in the config file
+	AlertRules     []alertRuleConfig
+	FormatterRules []formatterRuleConfig
 
 	// Args are the positional (non-flag) command line arguments
 	Args []string
@@ -60,46 +164,145 @@ func parseFlags(progname string, args []string) (cfg *Config, output string, err
 
 	var conf Config
 	flags.IntVar(&conf.Port, "port", getEnvOrDefaultInt("PORT", 8080), "port number to use for web ui server")
+	flags.BoolVar(&conf.EnableH2C, "enable-h2c", getEnvOrDefaultBool("ENABLE_H2C", false), "serve HTTP/2 without TLS (h2c); use only in trusted networks")
 	flags.StringVar(&conf.RedisAddr, "redis-addr", getEnvDefaultString("REDIS_ADDR", "127.0.0.1:6379"), "address of redis server to connect to")
 	flags.IntVar(&conf.RedisDB, "redis-db", getEnvOrDefaultInt("REDIS_DB", 0), "redis database number")
+	flags.StringVar(&conf.RedisUsername, "redis-username", getEnvDefaultString("REDIS_USERNAME", ""), "username to use when connecting to redis server (redis 6 ACL)")
 	flags.StringVar(&conf.RedisPassword, "redis-password", getEnvDefaultString("REDIS_PASSWORD", ""), "password to use when connecting to redis server")
 	flags.StringVar(&conf.RedisTLS, "redis-tls", getEnvDefaultString("REDIS_TLS", ""), "server name for TLS validation used when connecting to redis server")
-	flags.StringVar(&conf.RedisURL, "redis-url", getEnvDefaultString("REDIS_URL", ""), "URL to redis server")
+	flags.StringVar(&conf.RedisURL, "redis-url", getEnvDefaultString("REDIS_URL", ""), "URL to redis server, sentinels, or cluster nodes")
 	flags.BoolVar(&conf.RedisInsecureTLS, "redis-insecure-tls", getEnvOrDefaultBool("REDIS_INSECURE_TLS", false), "disable TLS certificate host checks")
+	flags.StringVar(&conf.RedisCACert, "redis-ca-cert", getEnvDefaultString("REDIS_CA_CERT", ""), "path to PEM file of CA certificates to verify redis server certificate")
+	flags.StringVar(&conf.RedisClientCert, "redis-client-cert", getEnvDefaultString("REDIS_CLIENT_CERT", ""), "path to PEM file of client certificate for mutual TLS with redis server")
+	conf.RedisURIs = getEnvOrDefaultLines("REDIS_URIS", nil)
+	flags.Var((*stringListValue)(&conf.RedisURIs), "redis-uri", "named redis connection in \"name=uri\" format to monitor multiple clusters; overrides other redis connection flags (can be repeated)")
+	flags.StringVar(&conf.RedisClientKey, "redis-client-key", getEnvDefaultString("REDIS_CLIENT_KEY", ""), "path to PEM file of private key of client certificate")
 	flags.StringVar(&conf.RedisClusterNodes, "redis-cluster-nodes", getEnvDefaultString("REDIS_CLUSTER_NODES", ""), "comma separated list of host:port addresses of cluster nodes")
 	flags.IntVar(&conf.MaxPayloadLength, "max-payload-length", getEnvOrDefaultInt("MAX_PAYLOAD_LENGTH", 200), "maximum number of utf8 characters printed in the payload cell in the Web UI")
 	flags.IntVar(&conf.MaxResultLength, "max-result-length", getEnvOrDefaultInt("MAX_RESULT_LENGTH", 200), "maximum number of utf8 characters printed in the result cell in the Web UI")
+	flags.StringVar(&conf.FormattersConfig, "formatters-config", getEnvDefaultString("FORMATTERS_CONFIG", ""), "path of JSON file with rules selecting the format (json, msgpack, or protobuf:<message name>) of payloads and results by task type")
+	conf.ProtoDescriptorSets = getEnvOrDefaultLines("PROTO_DESCRIPTOR_SETS", nil)
+	flags.Var((*stringListValue)(&conf.ProtoDescriptorSets), "proto-descriptor-set", "path of protobuf descriptor set file generated by protoc --include_imports --descriptor_set_out (can be repeated)")
 	flags.BoolVar(&conf.EnableMetricsExporter, "enable-metrics-exporter", getEnvOrDefaultBool("ENABLE_METRICS_EXPORTER", false), "enable prometheus metrics exporter to expose queue metrics")
 	flags.StringVar(&conf.PrometheusServerAddr, "prometheus-addr", getEnvDefaultString("PROMETHEUS_ADDR", ""), "address of prometheus server to query time series")
-	flags.BoolVar(&conf.ReadOnly, "read-only", getEnvOrDefaultBool("READ_ONLY", false), "restrict to read-only mode")
+	flags.DurationVar(&conf.PrometheusTimeout, "prometheus-timeout", getEnvOrDefaultDuration("PROMETHEUS_TIMEOUT", 10*time.Second), "timeout for each query sent to prometheus server")
+	flags.DurationVar(&conf.PrometheusMaxRange, "prometheus-max-range", getEnvOrDefaultDuration("PROMETHEUS_MAX_RANGE", 0), "maximum time range of metrics to query from prometheus server (0 means no limit)")
+	flags.DurationVar(&conf.PrometheusMinStep, "prometheus-min-step", getEnvOrDefaultDuration("PROMETHEUS_MIN_STEP", 0), "minimum resolution step of range queries sent to prometheus server")
+	flags.StringVar(&conf.OTLPEndpoint, "otlp-endpoint", getEnvDefaultString("OTLP_ENDPOINT", ""), "URL of OTLP/HTTP collector to export OpenTelemetry traces and metrics to (e.g. http://localhost:4318)")
+	flags.DurationVar(&conf.MetricsHistoryInterval, "metrics-history-interval", getEnvOrDefaultDuration("METRICS_HISTORY_INTERVAL", 0), "interval to record queue stats in redis to chart them without prometheus (0 means disabled)")
+	flags.DurationVar(&conf.MetricsHistoryRetention, "metrics-history-retention", getEnvOrDefaultDuration("METRICS_HISTORY_RETENTION", 24*time.Hour), "duration to keep recorded queue stats")
+	conf.TaskLinks = getEnvOrDefaultLines("TASK_LINKS", nil)
+	flags.Var((*stringListValue)(&conf.TaskLinks), "task-link", "link shown in task details view in \"task-type-pattern|label|url-template\" format (can be repeated)")
+	flags.StringVar(&conf.TraceIDPath, "trace-id-path", getEnvDefaultString("TRACE_ID_PATH", ""), "JSONPath to extract trace ID from task payloads (e.g. $.metadata.trace_id)")
+	flags.StringVar(&conf.TraceURL, "trace-url", getEnvDefaultString("TRACE_URL", ""), "URL template to link trace ID to tracing backend (e.g. https://jaeger.example.com/trace/{{.TraceID}})")
+	flags.IntVar(&conf.BulkOperationThreshold, "bulk-operation-threshold", getEnvOrDefaultInt("BULK_OPERATION_THRESHOLD", 0), "maximum number of tasks a bulk operation can affect without force=true query parameter (0 means no limit)")
+	flags.DurationVar(&conf.IdempotencyKeyTTL, "idempotency-key-ttl", getEnvOrDefaultDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour), "duration to keep responses of API requests with Idempotency-Key header for replay")
+	flags.BoolVar(&conf.LargeIntsAsStrings, "large-ints-as-strings", getEnvOrDefaultBool("LARGE_INTS_AS_STRINGS", false), "emit integers larger than 2^53-1 as strings in API responses and JSON payloads")
+	conf.APITokens = getEnvOrDefaultLines("API_TOKENS", nil)
+	flags.Var((*stringListValue)(&conf.APITokens), "api-token", "API token in \"name|token|scope1,scope2\" format required to access the API (can be repeated)")
+	conf.BasicAuthUsers = getEnvOrDefaultLines("BASIC_AUTH_USERS", nil)
+	flags.Var((*stringListValue)(&conf.BasicAuthUsers), "basic-auth-user", "user who signs in with basic authentication in \"username|password|role\" format, where role is viewer or admin (can be repeated)")
+	flags.StringVar(&conf.OIDCIssuerURL, "oidc-issuer-url", getEnvDefaultString("OIDC_ISSUER_URL", ""), "URL of OpenID Connect provider to sign in with")
+	flags.StringVar(&conf.OIDCClientID, "oidc-client-id", getEnvDefaultString("OIDC_CLIENT_ID", ""), "client ID registered with OpenID Connect provider")
+	flags.StringVar(&conf.OIDCClientSecret, "oidc-client-secret", getEnvDefaultString("OIDC_CLIENT_SECRET", ""), "client secret registered with OpenID Connect provider")
+	flags.StringVar(&conf.OIDCRedirectURL, "oidc-redirect-url", getEnvDefaultString("OIDC_REDIRECT_URL", ""), "external URL of asynqmon followed by /auth/callback registered with OpenID Connect provider")
+	flags.StringVar(&conf.OIDCUsernameClaim, "oidc-username-claim", getEnvDefaultString("OIDC_USERNAME_CLAIM", "email"), "claim used as username of users signed in with OpenID Connect provider")
+	flags.StringVar(&conf.OIDCRolesClaim, "oidc-roles-claim", getEnvDefaultString("OIDC_ROLES_CLAIM", "groups"), "claim with groups or roles of users signed in with OpenID Connect provider")
+	flags.StringVar(&conf.OIDCAdmins, "oidc-admins", getEnvDefaultString("OIDC_ADMINS", ""), "comma separated list of usernames and groups granted admin role; other users are viewers")
+	flags.StringVar(&conf.OIDCSessionSecret, "oidc-session-secret", getEnvDefaultString("OIDC_SESSION_SECRET", ""), "key to sign session cookies; must be the same for all instances (random if empty)")
+	flags.DurationVar(&conf.RedisTimeout, "redis-timeout", getEnvOrDefaultDuration("REDIS_TIMEOUT", 5*time.Second), "maximum duration of each redis command (negative value disables the timeout)")
+	flags.IntVar(&conf.CircuitBreakerThreshold, "circuit-breaker-threshold", getEnvOrDefaultInt("CIRCUIT_BREAKER_THRESHOLD", 5), "number of consecutive redis failures after which API requests fail fast (negative value disables the circuit breaker)")
+	flags.DurationVar(&conf.CircuitBreakerCooldown, "circuit-breaker-cooldown", getEnvOrDefaultDuration("CIRCUIT_BREAKER_COOLDOWN", 30*time.Second), "duration to fail fast before checking if redis has recovered")
+	flags.DurationVar(&conf.QueueInfoCacheTTL, "queue-info-cache-ttl", getEnvOrDefaultDuration("QUEUE_INFO_CACHE_TTL", 0), "duration to cache queue stats on the server (0 disables caching)")
+	flags.StringVar(&conf.AuditLog, "audit-log", getEnvDefaultString("AUDIT_LOG", ""), "path of file to append audit entries of API requests which make changes to as JSON lines (\"-\" for stdout)")
+	flags.BoolVar(&conf.AuditRedisStream, "audit-redis-stream", getEnvOrDefaultBool("AUDIT_REDIS_STREAM", false), "keep audit entries in a redis stream instead of memory")
+	flags.StringVar(&conf.AuditActorHeader, "audit-actor-header", getEnvDefaultString("AUDIT_ACTOR_HEADER", ""), "request header identifying the actor of requests not authenticated by asynqmon (e.g. X-Forwarded-User)")
+	flags.DurationVar(&conf.LiveUpdateInterval, "live-update-interval", getEnvOrDefaultDuration("LIVE_UPDATE_INTERVAL", 3*time.Second), "interval between polls of redis for the live updates pushed to the web UI")
+	flags.BoolVar(&conf.DisableLiveUpdates, "disable-live-updates", getEnvOrDefaultBool("DISABLE_LIVE_UPDATES", false), "remove live updates stream and make the web UI poll the API instead")
+	flags.BoolVar(&conf.KeyspaceNotifications, "keyspace-notifications", getEnvOrDefaultBool("KEYSPACE_NOTIFICATIONS", false), "invalidate cached queue stats on redis keyspace notifications (requires notify-keyspace-events to be enabled in redis)")
+	flags.StringVar(&conf.SlackWebhookURL, "slack-webhook-url", getEnvDefaultString("SLACK_WEBHOOK_URL", ""), "URL of slack incoming webhook to send notifications to")
+	flags.StringVar(&conf.WebhookURL, "webhook-url", getEnvDefaultString("WEBHOOK_URL", ""), "URL to send notifications to as JSON")
+	flags.StringVar(&conf.PagerDutyRoutingKey, "pagerduty-routing-key", getEnvDefaultString("PAGERDUTY_ROUTING_KEY", ""), "integration key of pagerduty service to send notifications to")
+	flags.StringVar(&conf.SMTPAddr, "smtp-addr", getEnvDefaultString("SMTP_ADDR", ""), "address of SMTP server to send notification emails")
+	flags.StringVar(&conf.SMTPUsername, "smtp-username", getEnvDefaultString("SMTP_USERNAME", ""), "username to use when connecting to SMTP server")
+	flags.StringVar(&conf.SMTPPassword, "smtp-password", getEnvDefaultString("SMTP_PASSWORD", ""), "password to use when connecting to SMTP server")
+	flags.StringVar(&conf.SMTPFrom, "smtp-from", getEnvDefaultString("SMTP_FROM", ""), "sender address of notification emails")
+	flags.StringVar(&conf.SMTPTo, "smtp-to", getEnvDefaultString("SMTP_TO", ""), "comma separated list of recipient addresses of notification emails")
+	flags.DurationVar(&conf.AlertProcessingSilence, "alert-processing-silence", getEnvOrDefaultDuration("ALERT_PROCESSING_SILENCE", 0), "notify when a queue has pending tasks but none are processed for this duration (0 disables the alert)")
+	flags.DurationVar(&conf.AlertServerDisappeared, "alert-server-disappeared", getEnvOrDefaultDuration("ALERT_SERVER_DISAPPEARED", 0), "notify when a server stops heartbeating and is not replaced for this duration (0 disables the alert)")
+	flags.Float64Var(&conf.AlertAnomalyThreshold, "alert-anomaly-threshold", getEnvOrDefaultFloat("ALERT_ANOMALY_THRESHOLD", 0), "notify when the size, latency, or error rate of a queue is this many standard deviations above its recent average (0 disables the alert)")
+	flags.IntVar(&conf.AlertQueueSize, "alert-queue-size", getEnvOrDefaultInt("ALERT_QUEUE_SIZE", 0), "notify when a queue has more pending tasks than this value (0 disables the alert)")
+	flags.DurationVar(&conf.AlertLatency, "alert-latency", getEnvOrDefaultDuration("ALERT_LATENCY", 0), "notify when the oldest pending task of a queue has been waiting longer than this duration (0 disables the alert)")
+	flags.BoolVar(&conf.AlertArchivedTasks, "alert-archived-tasks", getEnvOrDefaultBool("ALERT_ARCHIVED_TASKS", false), "notify when a queue has archived (dead-letter) tasks")
+	flags.IntVar(&conf.AlertEnqueueFailures, "alert-enqueue-failures", getEnvOrDefaultInt("ALERT_ENQUEUE_FAILURES", 0), "notify when a periodic task missed this many enqueues in a row (0 disables the alert)")
+	flags.StringVar(&conf.AlertRulesFile, "alert-rules-file", getEnvDefaultString("ALERT_RULES_FILE", ""), "path of JSON file with alert rules to evaluate in addition to the ones given with the other alert flags")
+	flags.BoolVar(&conf.ReadOnly, "read-only", getEnvOrDefaultBool("READ_ONLY", false), "reject every API request which makes changes and hide the controls to make changes in the Web UI")
+	flags.BoolVar(&conf.DisableMetrics, "disable-metrics", getEnvOrDefaultBool("DISABLE_METRICS", false), "remove metrics view and its API endpoints")
+	flags.BoolVar(&conf.DisableSchedulers, "disable-schedulers", getEnvOrDefaultBool("DISABLE_SCHEDULERS", false), "remove schedulers view and its API endpoints")
+	flags.BoolVar(&conf.DisableRedisInfo, "disable-redis-info", getEnvOrDefaultBool("DISABLE_REDIS_INFO", false), "remove redis info view and its API endpoints")
+	flags.StringVar(&conf.ConfigFile, "config", getEnvDefaultString("CONFIG_FILE", ""), "path of YAML or TOML file with values of the other flags; flags and environment variables take precedence")
 
 	err = flags.Parse(args)
 	if err != nil {
 		return nil, buf.String(), err
 	}
+	if conf.ConfigFile != "" {
+		if err := applyConfigFile(flags, &conf, conf.ConfigFile); err != nil {
+			return nil, buf.String(), err
+		}
+	}
 	conf.Args = flags.Args()
 	return &conf, buf.String(), nil
 }
 
-func makeTLSConfig(cfg *Config) *tls.Config {
-	if cfg.RedisTLS == "" && !cfg.RedisInsecureTLS {
-		return nil
+// makeTLSConfig returns the TLS config given by the redis TLS flags applied to
+// a copy of base, which is the TLS config given by the redis URL if any.
+// It returns base if no TLS flag is given.
+func makeTLSConfig(cfg *Config, base *tls.Config) (*tls.Config, error) {
+	if cfg.RedisTLS == "" && !cfg.RedisInsecureTLS && cfg.RedisCACert == "" && cfg.RedisClientCert == "" && cfg.RedisClientKey == "" {
+		return base, nil
 	}
-	return &tls.Config{
-		ServerName:         cfg.RedisTLS,
-		InsecureSkipVerify: cfg.RedisInsecureTLS,
+	c := &tls.Config{}
+	if base != nil {
+		c = base.Clone()
 	}
+	if cfg.RedisTLS != "" {
+		c.ServerName = cfg.RedisTLS
+	}
+	if cfg.RedisInsecureTLS {
+		c.InsecureSkipVerify = true
+	}
+	if err := loadTLSFiles(c, cfg.RedisCACert, cfg.RedisClientCert, cfg.RedisClientKey); err != nil {
+		return nil, fmt.Errorf("invalid redis TLS flags: %v", err)
+	}
+	return c, nil
 }
 
 func makeRedisConnOpt(cfg *Config) (asynq.RedisConnOpt, error) {
 	// Connecting to redis-cluster
 	if len(cfg.RedisClusterNodes) > 0 {
+		tlsConfig, err := makeTLSConfig(cfg, nil)
+		if err != nil {
+			return nil, err
+		}
 		return asynq.RedisClusterClientOpt{
 			Addrs:     strings.Split(cfg.RedisClusterNodes, ","),
+			Username:  cfg.RedisUsername,
 			Password:  cfg.RedisPassword,
-			TLSConfig: makeTLSConfig(cfg),
+			TLSConfig: tlsConfig,
 		}, nil
 	}
+	if strings.HasPrefix(cfg.RedisURL, "redis-cluster") {
+		res, err := ParseRedisURI(cfg.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		connOpt := res.(asynq.RedisClusterClientOpt) // safe to type-assert
+		if connOpt.TLSConfig, err = makeTLSConfig(cfg, connOpt.TLSConfig); err != nil {
+			return nil, err
+		}
+		return connOpt, nil
+	}
 
 	// Connecting to redis-sentinels
 	if strings.HasPrefix(cfg.RedisURL, "redis-sentinel") {
@@ -108,7 +311,9 @@ func makeRedisConnOpt(cfg *Config) (asynq.RedisConnOpt, error) {
 			return nil, err
 		}
 		connOpt := res.(asynq.RedisFailoverClientOpt) // safe to type-assert
-		connOpt.TLSConfig = makeTLSConfig(cfg)
+		if connOpt.TLSConfig, err = makeTLSConfig(cfg, connOpt.TLSConfig); err != nil {
+			return nil, err
+		}
 		return connOpt, nil
 	}
 
@@ -123,15 +328,397 @@ func makeRedisConnOpt(cfg *Config) (asynq.RedisConnOpt, error) {
 	} else {
 		connOpt.Addr = cfg.RedisAddr
 		connOpt.DB = cfg.RedisDB
+		connOpt.Username = cfg.RedisUsername
 		connOpt.Password = cfg.RedisPassword
 	}
-	if connOpt.TLSConfig == nil {
-		connOpt.TLSConfig = makeTLSConfig(cfg)
+	tlsConfig, err := makeTLSConfig(cfg, connOpt.TLSConfig)
+	if err != nil {
+		return nil, err
 	}
+	connOpt.TLSConfig = tlsConfig
 	return connOpt, nil
 }
 
+// makeClusters returns the clusters given with the --redis-uri flags.
+// Each URI is used in place of --redis-url with the other redis flags, such as the TLS flags.
+func makeClusters(cfg *Config) ([]asynqmon.Cluster, error) {
+	var clusters []asynqmon.Cluster
+	for _, s := range cfg.RedisURIs {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid redis URI %q: want \"name=uri\"", s)
+		}
+		c := *cfg
+		c.RedisURL, c.RedisClusterNodes = parts[1], ""
+		connOpt, err := makeRedisConnOpt(&c)
+		if err != nil {
+			return nil, fmt.Errorf("redis URI of cluster %q: %v", parts[0], err)
+		}
+		clusters = append(clusters, asynqmon.Cluster{Name: parts[0], RedisConnOpt: connOpt})
+	}
+	return clusters, nil
+}
+
+// parseTaskLink parses a string in "pattern|label|url-template" format.
+func parseTaskLink(s string) (asynqmon.TaskLink, error) {
+	parts := strings.SplitN(s, "|", 3)
+	if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return asynqmon.TaskLink{}, fmt.Errorf("invalid task link %q: want \"task-type-pattern|label|url-template\"", s)
+	}
+	return asynqmon.TaskLink{TaskType: parts[0], Label: parts[1], URL: parts[2]}, nil
+}
+
+func makeTaskLinks(cfg *Config) ([]asynqmon.TaskLink, error) {
+	var links []asynqmon.TaskLink
+	for _, s := range cfg.TaskLinks {
+		l, err := parseTaskLink(s)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, nil
+}
+
+func parseAPIToken(s string) (asynqmon.APIToken, error) {
+	parts := strings.Split(s, "|")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return asynqmon.APIToken{}, fmt.Errorf("invalid API token for %q: want \"name|token|scope1,scope2\"", parts[0])
+	}
+	return asynqmon.APIToken{Name: parts[0], Token: parts[1], Scopes: strings.Split(parts[2], ",")}, nil
+}
+
+func makeAPITokens(cfg *Config) ([]asynqmon.APIToken, error) {
+	var tokens []asynqmon.APIToken
+	for _, s := range cfg.APITokens {
+		t, err := parseAPIToken(s)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+func parseUser(s string) (asynqmon.User, error) {
+	parts := strings.Split(s, "|")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return asynqmon.User{}, fmt.Errorf("invalid user %q: want \"username|password|role\"", parts[0])
+	}
+	return asynqmon.User{Username: parts[0], Password: parts[1], Role: parts[2]}, nil
+}
+
+func makeUsers(cfg *Config) ([]asynqmon.User, error) {
+	var users []asynqmon.User
+	for _, s := range cfg.BasicAuthUsers {
+		u, err := parseUser(s)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func makeOIDCOptions(cfg *Config) *asynqmon.OIDCOptions {
+	if cfg.OIDCIssuerURL == "" {
+		return nil
+	}
+	opts := &asynqmon.OIDCOptions{
+		IssuerURL:     cfg.OIDCIssuerURL,
+		ClientID:      cfg.OIDCClientID,
+		ClientSecret:  cfg.OIDCClientSecret,
+		RedirectURL:   cfg.OIDCRedirectURL,
+		UsernameClaim: cfg.OIDCUsernameClaim,
+		RolesClaim:    cfg.OIDCRolesClaim,
+		SessionSecret: cfg.OIDCSessionSecret,
+	}
+	if cfg.OIDCAdmins != "" {
+		opts.Admins = strings.Split(cfg.OIDCAdmins, ",")
+	}
+	return opts
+}
+
+// makeAuditSinks returns the sinks which record audit entries as configured by the --audit-log flag.
+func makeAuditSinks(cfg *Config) ([]asynqmon.AuditSink, error) {
+	switch cfg.AuditLog {
+	case "":
+		return nil, nil
+	case "-":
+		return []asynqmon.AuditSink{asynqmon.NewJSONAuditSink(os.Stdout)}, nil
+	}
+	f, err := os.OpenFile(cfg.AuditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open audit log: %v", err)
+	}
+	return []asynqmon.AuditSink{asynqmon.NewJSONAuditSink(f)}, nil
+}
+
+func makeNotifiers(cfg *Config) ([]asynqmon.Notifier, error) {
+	var notifiers []asynqmon.Notifier
+	if cfg.SlackWebhookURL != "" {
+		notifiers = append(notifiers, &asynqmon.SlackNotifier{WebhookURL: cfg.SlackWebhookURL})
+	}
+	if cfg.WebhookURL != "" {
+		notifiers = append(notifiers, &asynqmon.WebhookNotifier{URL: cfg.WebhookURL})
+	}
+	if cfg.PagerDutyRoutingKey != "" {
+		notifiers = append(notifiers, &asynqmon.PagerDutyNotifier{RoutingKey: cfg.PagerDutyRoutingKey})
+	}
+	if cfg.SMTPAddr != "" {
+		var to []string
+		for _, addr := range strings.Split(cfg.SMTPTo, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				to = append(to, addr)
+			}
+		}
+		if len(to) == 0 {
+			return nil, fmt.Errorf("smtp-to must specify at least one recipient when smtp-addr is set")
+		}
+		notifiers = append(notifiers, &asynqmon.EmailNotifier{
+			Addr:     cfg.SMTPAddr,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+			To:       to,
+		})
+	}
+	return notifiers, nil
+}
+
+// alertRuleConfig is a rule in the file given with the --alert-rules-file flag.
+type alertRuleConfig struct {
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Queue     string  `json:"queue"`
+	For       string  `json:"for"` // duration string (e.g. "5m")
+	Severity  string  `json:"severity"`
+	Threshold float64 `json:"threshold"`
+}
+
+// parseAlertRules parses the alert rules in JSON format read from r.
+// The rules are validated by asynqmon.New.
+func parseAlertRules(r io.Reader) ([]*asynqmon.AlertRule, error) {
+	var configs []alertRuleConfig
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&configs); err != nil {
+		return nil, fmt.Errorf("invalid alert rules file: %v", err)
+	}
+	rules, err := alertRules(configs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert rules file: %v", err)
+	}
+	return rules, nil
+}
+
+// alertRules returns the alert rules of the configs.
+func alertRules(configs []alertRuleConfig) ([]*asynqmon.AlertRule, error) {
+	var rules []*asynqmon.AlertRule
+	for _, c := range configs {
+		rule := &asynqmon.AlertRule{Name: c.Name, Type: c.Type, Queue: c.Queue, Severity: c.Severity, Threshold: c.Threshold}
+		if c.For != "" {
+			d, err := time.ParseDuration(c.For)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %v", c.Name, err)
+			}
+			rule.For = d
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func makeAlertRules(cfg *Config) ([]*asynqmon.AlertRule, error) {
+	var rules []*asynqmon.AlertRule
+	if cfg.AlertProcessingSilence > 0 {
+		rules = append(rules, &asynqmon.AlertRule{Type: asynqmon.AlertProcessingSilence, For: cfg.AlertProcessingSilence})
+	}
+	if cfg.AlertServerDisappeared > 0 {
+		rules = append(rules, &asynqmon.AlertRule{Type: asynqmon.AlertServerDisappeared, For: cfg.AlertServerDisappeared})
+	}
+	if cfg.AlertAnomalyThreshold > 0 {
+		rules = append(rules, &asynqmon.AlertRule{Type: asynqmon.AlertAnomaly, Threshold: cfg.AlertAnomalyThreshold})
+	}
+	if cfg.AlertQueueSize > 0 {
+		rules = append(rules, &asynqmon.AlertRule{Type: asynqmon.AlertQueueSize, Threshold: float64(cfg.AlertQueueSize)})
+	}
+	if cfg.AlertLatency > 0 {
+		rules = append(rules, &asynqmon.AlertRule{Type: asynqmon.AlertLatency, Threshold: cfg.AlertLatency.Seconds()})
+	}
+	if cfg.AlertArchivedTasks {
+		rules = append(rules, &asynqmon.AlertRule{Type: asynqmon.AlertArchivedTasks})
+	}
+	if cfg.AlertEnqueueFailures > 0 {
+		rules = append(rules, &asynqmon.AlertRule{Type: asynqmon.AlertSchedulerEnqueueFailures, Threshold: float64(cfg.AlertEnqueueFailures)})
+	}
+	if len(cfg.AlertRules) > 0 {
+		configRules, err := alertRules(cfg.AlertRules)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert rules in config file: %v", err)
+		}
+		rules = append(rules, configRules...)
+	}
+	if cfg.AlertRulesFile != "" {
+		f, err := os.Open(cfg.AlertRulesFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not open alert rules file: %v", err)
+		}
+		defer f.Close()
+		fileRules, err := parseAlertRules(f)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+	return rules, nil
+}
+
+// runAlertRules prints prometheus alerting rules to stdout.
+func runAlertRules(progname string, args []string) {
+	cfg, output, err := parseAlertRulesFlags(progname, args)
+	if err == flag.ErrHelp {
+		fmt.Println(output)
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Printf("error: %v\n", err)
+		fmt.Println(output)
+		os.Exit(1)
+	}
+	if err := writeAlertRules(os.Stdout, cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// makeOptions returns the options of the handler configured by cfg.
+func makeOptions(cfg *Config) (asynqmon.Options, error) {
+	clusters, err := makeClusters(cfg)
+	if err != nil {
+		return asynqmon.Options{}, err
+	}
+	if cfg.OTLPEndpoint != "" {
+		if _, err := parseOTLPEndpoint(cfg.OTLPEndpoint); err != nil {
+			return asynqmon.Options{}, err
+		}
+	}
+	var redisConnOpt asynq.RedisConnOpt
+	if len(clusters) == 0 {
+		if redisConnOpt, err = makeRedisConnOpt(cfg); err != nil {
+			return asynqmon.Options{}, err
+		}
+	}
+
+	taskLinks, err := makeTaskLinks(cfg)
+	if err != nil {
+		return asynqmon.Options{}, err
+	}
+
+	formatters, err := makeFormatterRules(cfg)
+	if err != nil {
+		return asynqmon.Options{}, err
+	}
+
+	apiTokens, err := makeAPITokens(cfg)
+	if err != nil {
+		return asynqmon.Options{}, err
+	}
+	users, err := makeUsers(cfg)
+	if err != nil {
+		return asynqmon.Options{}, err
+	}
+	auditSinks, err := makeAuditSinks(cfg)
+	if err != nil {
+		return asynqmon.Options{}, err
+	}
+	notifiers, err := makeNotifiers(cfg)
+	if err != nil {
+		return asynqmon.Options{}, err
+	}
+	alertRules, err := makeAlertRules(cfg)
+	if err != nil {
+		return asynqmon.Options{}, err
+	}
+
+	return asynqmon.Options{
+		RedisConnOpt:            redisConnOpt,
+		Clusters:                clusters,
+		PayloadFormatter:        asynqmon.PayloadFormatterFunc(payloadFormatterFunc(cfg)),
+		ResultFormatter:         asynqmon.ResultFormatterFunc(resultFormatterFunc(cfg)),
+		Formatters:              formatters,
+		PrometheusAddress:       cfg.PrometheusServerAddr,
+		PrometheusTimeout:       cfg.PrometheusTimeout,
+		PrometheusMaxRange:      cfg.PrometheusMaxRange,
+		PrometheusMinStep:       cfg.PrometheusMinStep,
+		MetricsHistoryInterval:  cfg.MetricsHistoryInterval,
+		MetricsHistoryRetention: cfg.MetricsHistoryRetention,
+		ReadOnly:                cfg.ReadOnly,
+		DisableMetrics:          cfg.DisableMetrics,
+		DisableSchedulers:       cfg.DisableSchedulers,
+		DisableRedisInfo:        cfg.DisableRedisInfo,
+		TaskLinks:               taskLinks,
+		TraceIDPath:             cfg.TraceIDPath,
+		TraceURL:                cfg.TraceURL,
+		IdempotencyKeyTTL:       cfg.IdempotencyKeyTTL,
+		BulkOperationThreshold:  cfg.BulkOperationThreshold,
+		LargeIntegersAsStrings:  cfg.LargeIntsAsStrings,
+		APITokens:               apiTokens,
+		Users:                   users,
+		OIDC:                    makeOIDCOptions(cfg),
+		RedisTimeout:            cfg.RedisTimeout,
+		CircuitBreakerThreshold: cfg.CircuitBreakerThreshold,
+		CircuitBreakerCooldown:  cfg.CircuitBreakerCooldown,
+		QueueInfoCacheTTL:       cfg.QueueInfoCacheTTL,
+		KeyspaceNotifications:   cfg.KeyspaceNotifications,
+		LiveUpdateInterval:      cfg.LiveUpdateInterval,
+		AuditSinks:              auditSinks,
+		AuditRedisStream:        cfg.AuditRedisStream,
+		AuditActorHeader:        cfg.AuditActorHeader,
+		DisableLiveUpdates:      cfg.DisableLiveUpdates,
+		Notifiers:               notifiers,
+		AlertRules:              alertRules,
+	}, nil
+}
+
+// runValidateConfig checks the configuration given with flags, environment variables,
+// and the config file without starting the server.
+// The path of the config file can also be given as the only positional argument.
+func runValidateConfig(progname string, args []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args = append([]string{"--config", args[0]}, args[1:]...)
+	}
+	cfg, output, err := parseFlags(progname, args)
+	if err == flag.ErrHelp {
+		fmt.Println(output)
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Printf("error: %v\n", err)
+		fmt.Println(output)
+		os.Exit(1)
+	}
+	if len(cfg.Args) > 0 {
+		fmt.Printf("error: unexpected arguments %q\n", cfg.Args)
+		os.Exit(1)
+	}
+	opts, err := makeOptions(cfg)
+	if err == nil {
+		err = opts.Validate()
+	}
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("configuration is valid")
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "alert-rules" {
+		runAlertRules(os.Args[0]+" alert-rules", os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "validate-config" {
+		runValidateConfig(os.Args[0]+" validate-config", os.Args[2:])
+		return
+	}
 	cfg, output, err := parseFlags(os.Args[0], os.Args[1:])
 	if err == flag.ErrHelp {
 		fmt.Println(output)
@@ -142,22 +729,26 @@ func main() {
 		os.Exit(1)
 	}
 
-	redisConnOpt, err := makeRedisConnOpt(cfg)
+	opts, err := makeOptions(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	h := asynqmon.New(asynqmon.Options{
-		RedisConnOpt:      redisConnOpt,
-		PayloadFormatter:  asynqmon.PayloadFormatterFunc(payloadFormatterFunc(cfg)),
-		ResultFormatter:   asynqmon.ResultFormatterFunc(resultFormatterFunc(cfg)),
-		PrometheusAddress: cfg.PrometheusServerAddr,
-		ReadOnly:          cfg.ReadOnly,
-	})
+	clusters, redisConnOpt := opts.Clusters, opts.RedisConnOpt
+	// Set up the global providers before the handler gets its tracer and meter from them.
+	if cfg.OTLPEndpoint != "" {
+		shutdown, err := setupTelemetry(context.Background(), cfg.OTLPEndpoint)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer shutdown(context.Background())
+	}
+	h := asynqmon.New(opts)
 	defer h.Close()
 
 	c := cors.New(cors.Options{
 		AllowedMethods: []string{"GET", "POST", "DELETE"},
+		AllowedHeaders: []string{"Origin", "Accept", "Content-Type", "X-Requested-With", "X-Request-ID", "Idempotency-Key", "Authorization"},
+		ExposedHeaders: []string{"X-Request-ID", "Idempotent-Replayed", "X-Asynqmon-Stale", "X-Asynqmon-Fetched-At", "Link"},
 	})
 	mux := http.NewServeMux()
 	mux.Handle("/", c.Handler(h))
@@ -165,10 +756,22 @@ func main() {
 		// Using NewPedanticRegistry here to test the implementation of Collectors and Metrics.
 		reg := prometheus.NewPedanticRegistry()
 
-		inspector := asynq.NewInspector(redisConnOpt)
-
+		if len(clusters) == 0 {
+			inspector := asynq.NewInspector(redisConnOpt)
+			reg.MustRegister(
+				metrics.NewQueueMetricsCollector(inspector),
+				&serversCollector{inspector: inspector},
+			)
+		}
+		// Metrics of each cluster have the "cluster" label with the name of the cluster.
+		for _, c := range clusters {
+			inspector := asynq.NewInspector(c.RedisConnOpt)
+			prometheus.WrapRegistererWith(prometheus.Labels{"cluster": c.Name}, reg).MustRegister(
+				metrics.NewQueueMetricsCollector(inspector),
+				&serversCollector{inspector: inspector},
+			)
+		}
 		reg.MustRegister(
-			metrics.NewQueueMetricsCollector(inspector),
 			// Add the standard process and go metrics to the registry
 			prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
 			prometheus.NewGoCollector(),
@@ -176,11 +779,18 @@ func main() {
 		mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
 	}
 
+	var handler http.Handler = mux
+	if cfg.EnableH2C {
+		// Serves both HTTP/1.1 and HTTP/2 cleartext (prior knowledge or upgrade) on the same port.
+		handler = h2c.NewHandler(mux, &http2.Server{})
+	}
+
+	// WriteTimeout is not set so that the live updates stream is not cut off.
+	// Redis commands are bounded by --redis-timeout instead.
 	srv := &http.Server{
-		Handler:      mux,
-		Addr:         fmt.Sprintf(":%d", cfg.Port),
-		WriteTimeout: 10 * time.Second,
-		ReadTimeout:  10 * time.Second,
+		Handler:     handler,
+		Addr:        fmt.Sprintf(":%d", cfg.Port),
+		ReadTimeout: 10 * time.Second,
 	}
 
 	fmt.Printf("Asynq Monitoring WebUI server is listening on port %d\n", cfg.Port)
@@ -230,6 +840,14 @@ func getEnvOrDefaultInt(key string, def int) int {
 	return v
 }
 
+func getEnvOrDefaultFloat(key string, def float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
 func getEnvOrDefaultBool(key string, def bool) bool {
 	v, err := strconv.ParseBool(os.Getenv(key))
 	if err != nil {
@@ -237,3 +855,41 @@ func getEnvOrDefaultBool(key string, def bool) bool {
 	}
 	return v
 }
+
+// getEnvOrDefaultLines returns the non-empty lines of the environment variable.
+func getEnvOrDefaultLines(key string, def []string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	var lines []string
+	for _, l := range strings.Split(v, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// stringListValue is a flag.Value which collects the values of a repeated flag.
+type stringListValue []string
+
+func (v *stringListValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return strings.Join(*v, ",")
+}
+
+func (v *stringListValue) Set(s string) error {
+	*v = append(*v, s)
+	return nil
+}
+
+func getEnvOrDefaultDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
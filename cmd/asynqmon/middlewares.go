@@ -35,6 +35,14 @@ func (w *responseRecorderWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Flush sends buffered data to the client so that streamed responses,
+// such as the live updates, are not held back.
+func (w *responseRecorderWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func loggingMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rw := &responseRecorderWriter{ResponseWriter: w}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// queueServersDesc describes the number of servers processing each queue.
+// Servers are listed from their heartbeats, so the value drops to zero
+// shortly after the last worker stops.
+var queueServersDesc = prometheus.NewDesc(
+	"asynqmon_queue_servers",
+	"Number of asynq servers with a live heartbeat which process tasks from the queue.",
+	[]string{"queue"}, nil,
+)
+
+// serversCollector exports the number of servers processing each queue.
+// It complements the queue metrics of asynq/x/metrics which has no server metrics.
+type serversCollector struct {
+	inspector *asynq.Inspector
+}
+
+func (c *serversCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *serversCollector) Collect(ch chan<- prometheus.Metric) {
+	qnames, err := c.inspector.Queues()
+	if err != nil {
+		log.Printf("error: could not collect server metrics: %v", err)
+		return
+	}
+	servers, err := c.inspector.Servers()
+	if err != nil {
+		log.Printf("error: could not collect server metrics: %v", err)
+		return
+	}
+	counts := make(map[string]int, len(qnames))
+	for _, qname := range qnames {
+		counts[qname] = 0
+	}
+	for _, srv := range servers {
+		for qname := range srv.Queues {
+			counts[qname]++
+		}
+	}
+	for qname, n := range counts {
+		ch <- prometheus.MustNewConstMetric(queueServersDesc, prometheus.GaugeValue, float64(n), qname)
+	}
+}
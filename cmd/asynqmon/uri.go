@@ -2,9 +2,11 @@ package main
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 
@@ -14,13 +16,26 @@ import (
 // ParseRedisURI parses redis uri string and returns RedisConnOpt if uri is valid.
 // It returns a non-nil error if uri cannot be parsed.
 //
-// Three URI schemes are supported, which are redis:, rediss:, redis-socket:, and redis-sentinel:.
+// Five URI schemes are supported, which are redis:, rediss:, redis-socket:, redis-sentinel:, and redis-cluster:.
 // Supported formats are:
 //
 //	redis://[:password@]host[:port][/dbnumber]
 //	rediss://[:password@]host[:port][/dbnumber]
 //	redis-socket://[:password@]path[?db=dbnumber]
 //	redis-sentinel://[:password@]host1[:port][,host2:[:port]][,hostN:[:port]][?master=masterName]
+//	redis-cluster://[[username]:password@]host1[:port][,host2:[:port]][,hostN:[:port]]
+//
+// All schemes accept the following query params to configure TLS:
+//
+//	tls=true                  connect with TLS (implied by rediss: and any of the params below)
+//	tls_server_name=name      server name to verify the certificate of the server against
+//	ca_cert=path              PEM file of the CA certificates to verify the certificate of the server
+//	client_cert=path          PEM file of the client certificate for mutual TLS
+//	client_key=path           PEM file of the private key of the client certificate
+//	skip_verify=true          disable verification of the certificate of the server
+//
+// For redis-sentinel:, the password in the URI is used to authenticate with the sentinels,
+// and the username and password query params are used to authenticate with the redis servers.
 func ParseRedisURI(uri string) (asynq.RedisConnOpt, error) {
 	u, err := url.Parse(uri)
 	if err != nil {
@@ -33,6 +48,8 @@ func ParseRedisURI(uri string) (asynq.RedisConnOpt, error) {
 		return parseRedisSocketURI(u)
 	case "redis-sentinel":
 		return parseRedisSentinelURI(u)
+	case "redis-cluster":
+		return parseRedisClusterURI(u)
 	default:
 		return nil, fmt.Errorf("asynq: unsupported uri scheme: %q", u.Scheme)
 	}
@@ -62,6 +79,10 @@ func parseRedisURI(u *url.URL) (asynq.RedisConnOpt, error) {
 		}
 		redisConnOpt.TLSConfig = &tls.Config{ServerName: h}
 	}
+	redisConnOpt.TLSConfig, err = parseTLSParams(u.Query(), redisConnOpt.TLSConfig)
+	if err != nil {
+		return nil, fmt.Errorf("asynq: could not parse redis uri: %v", err)
+	}
 
 	redisConnOpt.Addr = u.Host
 	redisConnOpt.Password = password
@@ -88,7 +109,11 @@ func parseRedisSocketURI(u *url.URL) (asynq.RedisConnOpt, error) {
 	if v, ok := u.User.Password(); ok {
 		password = v
 	}
-	return asynq.RedisClientOpt{Network: "unix", Addr: u.Path, DB: db, Password: password}, nil
+	tlsConfig, err := parseTLSParams(q, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", errPrefix, err)
+	}
+	return asynq.RedisClientOpt{Network: "unix", Addr: u.Path, DB: db, Password: password, TLSConfig: tlsConfig}, nil
 }
 
 func parseRedisSentinelURI(u *url.URL) (asynq.RedisConnOpt, error) {
@@ -110,6 +135,11 @@ func parseRedisSentinelURI(u *url.URL) (asynq.RedisConnOpt, error) {
 		}
 	}
 
+	tlsConfig, err := parseTLSParams(query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("asynq: could not parse redis sentinel uri: %v", err)
+	}
+
 	return asynq.RedisFailoverClientOpt{
 		MasterName:       query.Get("master"),
 		SentinelAddrs:    addrs,
@@ -117,5 +147,104 @@ func parseRedisSentinelURI(u *url.URL) (asynq.RedisConnOpt, error) {
 		Username:         query.Get("username"),
 		Password:         query.Get("password"),
 		DB:               db,
+		TLSConfig:        tlsConfig,
 	}, nil
 }
+
+func parseRedisClusterURI(u *url.URL) (asynq.RedisConnOpt, error) {
+	const errPrefix = "asynq: could not parse redis cluster uri"
+	if u.Host == "" {
+		return nil, fmt.Errorf("%s: no node address", errPrefix)
+	}
+	addrs := strings.Split(u.Host, ",")
+	query := u.Query()
+	opt := asynq.RedisClusterClientOpt{
+		Addrs:    addrs,
+		Username: query.Get("username"),
+		Password: query.Get("password"),
+	}
+	if u.User != nil {
+		if v := u.User.Username(); v != "" {
+			opt.Username = v
+		}
+		if v, ok := u.User.Password(); ok {
+			opt.Password = v
+		}
+	}
+	// Without tls_server_name, the server name is taken from the address of each node.
+	tlsConfig, err := parseTLSParams(query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", errPrefix, err)
+	}
+	opt.TLSConfig = tlsConfig
+	return opt, nil
+}
+
+// parseTLSParams returns the TLS config given by the TLS query params of a uri
+// applied to a copy of c. It returns c if no TLS query param is given and
+// returns an error if any of the certificate files cannot be loaded.
+func parseTLSParams(q url.Values, c *tls.Config) (*tls.Config, error) {
+	useTLS := c != nil
+	if v := q.Get("tls"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("query param `tls` should be a boolean")
+		}
+		useTLS = useTLS || b
+	}
+	var skipVerify bool
+	if v := q.Get("skip_verify"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("query param `skip_verify` should be a boolean")
+		}
+		skipVerify = b
+	}
+	serverName, caCert, clientCert, clientKey := q.Get("tls_server_name"), q.Get("ca_cert"), q.Get("client_cert"), q.Get("client_key")
+	if !useTLS && !skipVerify && serverName == "" && caCert == "" && clientCert == "" && clientKey == "" {
+		return nil, nil
+	}
+	if c == nil {
+		c = &tls.Config{}
+	} else {
+		c = c.Clone()
+	}
+	if serverName != "" {
+		c.ServerName = serverName
+	}
+	if skipVerify {
+		c.InsecureSkipVerify = true
+	}
+	if err := loadTLSFiles(c, caCert, clientCert, clientKey); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// loadTLSFiles loads the CA certificates and the client certificate from the
+// given PEM files into c. Empty paths are ignored, but the client certificate
+// and key must be given together.
+func loadTLSFiles(c *tls.Config, caCert, clientCert, clientKey string) error {
+	if caCert != "" {
+		data, err := os.ReadFile(caCert)
+		if err != nil {
+			return fmt.Errorf("could not read CA certificate file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no PEM encoded certificates found in CA certificate file %q", caCert)
+		}
+		c.RootCAs = pool
+	}
+	if (clientCert == "") != (clientKey == "") {
+		return fmt.Errorf("client certificate and client key must be given together")
+	}
+	if clientCert != "" {
+		cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return fmt.Errorf("could not load client certificate %q and key %q: %v", clientCert, clientKey, err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return nil
+}
@@ -26,6 +26,10 @@ func (f PayloadFormatterFunc) FormatPayload(taskType string, payload []byte) str
 	return f(taskType, payload)
 }
 
+// PayloadTransformFunc transforms the payload bytes of a task with the given typename
+// (e.g. decrypts or encrypts the payload).
+type PayloadTransformFunc func(taskType string, payload []byte) ([]byte, error)
+
 // ResultFormatter is used to convert result bytes to a string shown in the UI.
 type ResultFormatter interface {
 	// FormatResult takes the task's typename and result and returns a string representation of the result.
@@ -109,6 +113,12 @@ type queueStateSnapshot struct {
 	Paused bool `json:"paused"`
 	// Time when this snapshot was taken.
 	Timestamp time.Time `json:"timestamp"`
+	// Growth rates of the queue computed from recent samples.
+	// This field is omitted if the rates are not available.
+	Growth *queueGrowth `json:"growth,omitempty"`
+	// Estimated time to drain the pending tasks computed from recent samples.
+	// This field is omitted if the estimate is not available.
+	Drain *queueDrainEstimate `json:"drain,omitempty"`
 }
 
 func toQueueStateSnapshot(info *asynq.QueueInfo) *queueStateSnapshot {
@@ -169,6 +179,11 @@ type taskInfo struct {
 	Type string `json:"type"`
 	// Payload is the payload data of the task.
 	Payload string `json:"payload"`
+	// PayloadSize is the size of the payload in bytes as stored in redis.
+	PayloadSize int `json:"payload_size_bytes"`
+	// DecompressedPayloadSize is the size of the payload in bytes after decompression.
+	// Zero if the payload is not compressed.
+	DecompressedPayloadSize int `json:"decompressed_payload_size_bytes"`
 	// State indicates the task state.
 	State string `json:"state"`
 	// MaxRetry is the maximum number of times the task can be retried.
@@ -195,6 +210,14 @@ type taskInfo struct {
 	// TTL is the number of seconds the task has left to be retained in the queue.
 	// This is calculated by (CompletedAt + ResultTTL) - Now.
 	TTL int64 `json:"ttl_seconds"`
+	// Links are the links into external systems configured for the task type.
+	Links []*taskLink `json:"links"`
+	// TraceID is the trace ID extracted from the payload.
+	// Empty string if trace ID extraction is not configured or the payload has no trace ID.
+	TraceID string `json:"trace_id"`
+	// TraceURL is the link to the trace in the tracing backend.
+	// Empty string if not available.
+	TraceURL string `json:"trace_url"`
 }
 
 // taskTTL calculates TTL for the given task.
@@ -214,8 +237,8 @@ func formatTimeInRFC3339(t time.Time) string {
 	return t.Format(time.RFC3339)
 }
 
-func toTaskInfo(info *asynq.TaskInfo, pf PayloadFormatter, rf ResultFormatter) *taskInfo {
-	return &taskInfo{
+func toTaskInfo(info *asynq.TaskInfo, pf *taskPayloadFormatter, rf ResultFormatter) *taskInfo {
+	t := &taskInfo{
 		ID:            info.ID,
 		Queue:         info.Queue,
 		Type:          info.Type,
@@ -232,6 +255,8 @@ func toTaskInfo(info *asynq.TaskInfo, pf PayloadFormatter, rf ResultFormatter) *
 		Result:        rf.FormatResult("", info.Result),
 		TTL:           int64(taskTTL(info).Seconds()),
 	}
+	t.PayloadSize, t.DecompressedPayloadSize = len(info.Payload), pf.decompressedSize(info.Type, info.Payload)
+	return t
 }
 
 type baseTask struct {
@@ -242,6 +267,11 @@ type baseTask struct {
 	MaxRetry  int    `json:"max_retry"`
 	Retried   int    `json:"retried"`
 	LastError string `json:"error_message"`
+	// Size of the payload in bytes as stored in redis.
+	PayloadSize int `json:"payload_size_bytes"`
+	// Size of the payload in bytes after decompression.
+	// Zero if the payload is not compressed.
+	DecompressedPayloadSize int `json:"decompressed_payload_size_bytes"`
 }
 
 type activeTask struct {
@@ -264,7 +294,7 @@ type activeTask struct {
 	IsOrphaned bool `json:"is_orphaned"`
 }
 
-func toActiveTask(ti *asynq.TaskInfo, pf PayloadFormatter) *activeTask {
+func toActiveTask(ti *asynq.TaskInfo, pf *taskPayloadFormatter) *activeTask {
 	base := &baseTask{
 		ID:        ti.ID,
 		Type:      ti.Type,
@@ -274,10 +304,11 @@ func toActiveTask(ti *asynq.TaskInfo, pf PayloadFormatter) *activeTask {
 		Retried:   ti.Retried,
 		LastError: ti.LastErr,
 	}
+	base.PayloadSize, base.DecompressedPayloadSize = len(ti.Payload), pf.decompressedSize(ti.Type, ti.Payload)
 	return &activeTask{baseTask: base, IsOrphaned: ti.IsOrphaned}
 }
 
-func toActiveTasks(in []*asynq.TaskInfo, pf PayloadFormatter) []*activeTask {
+func toActiveTasks(in []*asynq.TaskInfo, pf *taskPayloadFormatter) []*activeTask {
 	out := make([]*activeTask, len(in))
 	for i, ti := range in {
 		out[i] = toActiveTask(ti, pf)
@@ -290,7 +321,7 @@ type pendingTask struct {
 	*baseTask
 }
 
-func toPendingTask(ti *asynq.TaskInfo, pf PayloadFormatter) *pendingTask {
+func toPendingTask(ti *asynq.TaskInfo, pf *taskPayloadFormatter) *pendingTask {
 	base := &baseTask{
 		ID:        ti.ID,
 		Type:      ti.Type,
@@ -300,12 +331,13 @@ func toPendingTask(ti *asynq.TaskInfo, pf PayloadFormatter) *pendingTask {
 		Retried:   ti.Retried,
 		LastError: ti.LastErr,
 	}
+	base.PayloadSize, base.DecompressedPayloadSize = len(ti.Payload), pf.decompressedSize(ti.Type, ti.Payload)
 	return &pendingTask{
 		baseTask: base,
 	}
 }
 
-func toPendingTasks(in []*asynq.TaskInfo, pf PayloadFormatter) []*pendingTask {
+func toPendingTasks(in []*asynq.TaskInfo, pf *taskPayloadFormatter) []*pendingTask {
 	out := make([]*pendingTask, len(in))
 	for i, ti := range in {
 		out[i] = toPendingTask(ti, pf)
@@ -318,7 +350,7 @@ type aggregatingTask struct {
 	Group string `json:"group"`
 }
 
-func toAggregatingTask(ti *asynq.TaskInfo, pf PayloadFormatter) *aggregatingTask {
+func toAggregatingTask(ti *asynq.TaskInfo, pf *taskPayloadFormatter) *aggregatingTask {
 	base := &baseTask{
 		ID:        ti.ID,
 		Type:      ti.Type,
@@ -328,13 +360,14 @@ func toAggregatingTask(ti *asynq.TaskInfo, pf PayloadFormatter) *aggregatingTask
 		Retried:   ti.Retried,
 		LastError: ti.LastErr,
 	}
+	base.PayloadSize, base.DecompressedPayloadSize = len(ti.Payload), pf.decompressedSize(ti.Type, ti.Payload)
 	return &aggregatingTask{
 		baseTask: base,
 		Group:    ti.Group,
 	}
 }
 
-func toAggregatingTasks(in []*asynq.TaskInfo, pf PayloadFormatter) []*aggregatingTask {
+func toAggregatingTasks(in []*asynq.TaskInfo, pf *taskPayloadFormatter) []*aggregatingTask {
 	out := make([]*aggregatingTask, len(in))
 	for i, ti := range in {
 		out[i] = toAggregatingTask(ti, pf)
@@ -347,7 +380,7 @@ type scheduledTask struct {
 	NextProcessAt time.Time `json:"next_process_at"`
 }
 
-func toScheduledTask(ti *asynq.TaskInfo, pf PayloadFormatter) *scheduledTask {
+func toScheduledTask(ti *asynq.TaskInfo, pf *taskPayloadFormatter) *scheduledTask {
 	base := &baseTask{
 		ID:        ti.ID,
 		Type:      ti.Type,
@@ -357,13 +390,14 @@ func toScheduledTask(ti *asynq.TaskInfo, pf PayloadFormatter) *scheduledTask {
 		Retried:   ti.Retried,
 		LastError: ti.LastErr,
 	}
+	base.PayloadSize, base.DecompressedPayloadSize = len(ti.Payload), pf.decompressedSize(ti.Type, ti.Payload)
 	return &scheduledTask{
 		baseTask:      base,
 		NextProcessAt: ti.NextProcessAt,
 	}
 }
 
-func toScheduledTasks(in []*asynq.TaskInfo, pf PayloadFormatter) []*scheduledTask {
+func toScheduledTasks(in []*asynq.TaskInfo, pf *taskPayloadFormatter) []*scheduledTask {
 	out := make([]*scheduledTask, len(in))
 	for i, ti := range in {
 		out[i] = toScheduledTask(ti, pf)
@@ -376,7 +410,7 @@ type retryTask struct {
 	NextProcessAt time.Time `json:"next_process_at"`
 }
 
-func toRetryTask(ti *asynq.TaskInfo, pf PayloadFormatter) *retryTask {
+func toRetryTask(ti *asynq.TaskInfo, pf *taskPayloadFormatter) *retryTask {
 	base := &baseTask{
 		ID:        ti.ID,
 		Type:      ti.Type,
@@ -386,13 +420,14 @@ func toRetryTask(ti *asynq.TaskInfo, pf PayloadFormatter) *retryTask {
 		Retried:   ti.Retried,
 		LastError: ti.LastErr,
 	}
+	base.PayloadSize, base.DecompressedPayloadSize = len(ti.Payload), pf.decompressedSize(ti.Type, ti.Payload)
 	return &retryTask{
 		baseTask:      base,
 		NextProcessAt: ti.NextProcessAt,
 	}
 }
 
-func toRetryTasks(in []*asynq.TaskInfo, pf PayloadFormatter) []*retryTask {
+func toRetryTasks(in []*asynq.TaskInfo, pf *taskPayloadFormatter) []*retryTask {
 	out := make([]*retryTask, len(in))
 	for i, ti := range in {
 		out[i] = toRetryTask(ti, pf)
@@ -405,7 +440,7 @@ type archivedTask struct {
 	LastFailedAt time.Time `json:"last_failed_at"`
 }
 
-func toArchivedTask(ti *asynq.TaskInfo, pf PayloadFormatter) *archivedTask {
+func toArchivedTask(ti *asynq.TaskInfo, pf *taskPayloadFormatter) *archivedTask {
 	base := &baseTask{
 		ID:        ti.ID,
 		Type:      ti.Type,
@@ -415,13 +450,14 @@ func toArchivedTask(ti *asynq.TaskInfo, pf PayloadFormatter) *archivedTask {
 		Retried:   ti.Retried,
 		LastError: ti.LastErr,
 	}
+	base.PayloadSize, base.DecompressedPayloadSize = len(ti.Payload), pf.decompressedSize(ti.Type, ti.Payload)
 	return &archivedTask{
 		baseTask:     base,
 		LastFailedAt: ti.LastFailedAt,
 	}
 }
 
-func toArchivedTasks(in []*asynq.TaskInfo, pf PayloadFormatter) []*archivedTask {
+func toArchivedTasks(in []*asynq.TaskInfo, pf *taskPayloadFormatter) []*archivedTask {
 	out := make([]*archivedTask, len(in))
 	for i, ti := range in {
 		out[i] = toArchivedTask(ti, pf)
@@ -437,7 +473,7 @@ type completedTask struct {
 	TTL int64 `json:"ttl_seconds"`
 }
 
-func toCompletedTask(ti *asynq.TaskInfo, pf PayloadFormatter, rf ResultFormatter) *completedTask {
+func toCompletedTask(ti *asynq.TaskInfo, pf *taskPayloadFormatter, rf ResultFormatter) *completedTask {
 	base := &baseTask{
 		ID:        ti.ID,
 		Type:      ti.Type,
@@ -447,6 +483,7 @@ func toCompletedTask(ti *asynq.TaskInfo, pf PayloadFormatter, rf ResultFormatter
 		Retried:   ti.Retried,
 		LastError: ti.LastErr,
 	}
+	base.PayloadSize, base.DecompressedPayloadSize = len(ti.Payload), pf.decompressedSize(ti.Type, ti.Payload)
 	return &completedTask{
 		baseTask:    base,
 		CompletedAt: ti.CompletedAt,
@@ -455,7 +492,7 @@ func toCompletedTask(ti *asynq.TaskInfo, pf PayloadFormatter, rf ResultFormatter
 	}
 }
 
-func toCompletedTasks(in []*asynq.TaskInfo, pf PayloadFormatter, rf ResultFormatter) []*completedTask {
+func toCompletedTasks(in []*asynq.TaskInfo, pf *taskPayloadFormatter, rf ResultFormatter) []*completedTask {
 	out := make([]*completedTask, len(in))
 	for i, ti := range in {
 		out[i] = toCompletedTask(ti, pf, rf)
@@ -0,0 +1,189 @@
+package asynqmon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - http.Handler(s) for enqueueing ad-hoc tasks
+//   - http.Handler(s) for running scheduler entries immediately
+// ****************************************************************************
+
+var errSchedulerEntryNotFound = errors.New("scheduler entry not found")
+
+type enqueueTaskRequest struct {
+	// Type of the task.
+	Type string `json:"type"`
+	// Payload of the task. The value is encoded as JSON.
+	// If omitted, the task has no payload.
+	Payload json.RawMessage `json:"payload"`
+
+	// The following fields are optional and correspond to asynq's task options.
+
+	// ID of the task. Random ID is generated if empty.
+	TaskID string `json:"task_id"`
+	// Maximum number of times the task is retried. Default is 25.
+	MaxRetry *int `json:"max_retry"`
+	// Duration (e.g. "30s") the task can be processed before it is retried.
+	Timeout string `json:"timeout"`
+	// Time in RFC3339 format by which the task must be processed.
+	Deadline string `json:"deadline"`
+	// Duration (e.g. "1h") during which no other task with the same type and payload can be enqueued.
+	Unique string `json:"unique"`
+	// Duration (e.g. "24h") the task is retained after it is processed successfully.
+	Retention string `json:"retention"`
+	// Group to aggregate the task into.
+	Group string `json:"group"`
+	// Time to process the task at. The task is processed immediately if neither is set.
+	processTime
+}
+
+// options returns the task options for the request, which enqueue the task in the given queue.
+func (req *enqueueTaskRequest) options(qname string, now time.Time) ([]asynq.Option, error) {
+	if err := validateIdentifier("type", req.Type); err != nil {
+		return nil, err
+	}
+	opts := []asynq.Option{asynq.Queue(qname)}
+	if req.TaskID != "" {
+		if err := validateIdentifier("task_id", req.TaskID); err != nil {
+			return nil, err
+		}
+		opts = append(opts, asynq.TaskID(req.TaskID))
+	}
+	if req.MaxRetry != nil {
+		if *req.MaxRetry < 0 {
+			return nil, errors.New("max_retry must not be negative")
+		}
+		opts = append(opts, asynq.MaxRetry(*req.MaxRetry))
+	}
+	for _, d := range []struct {
+		name   string
+		val    string
+		option func(time.Duration) asynq.Option
+	}{
+		{"timeout", req.Timeout, asynq.Timeout},
+		{"unique", req.Unique, asynq.Unique},
+		{"retention", req.Retention, asynq.Retention},
+	} {
+		if d.val == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.val)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("%s must be a positive duration (e.g. \"30m\")", d.name)
+		}
+		if d.name == "unique" && v < time.Second {
+			return nil, errors.New("unique must be at least 1s")
+		}
+		opts = append(opts, d.option(v))
+	}
+	if req.Deadline != "" {
+		t, err := time.Parse(time.RFC3339, req.Deadline)
+		if err != nil {
+			return nil, fmt.Errorf("deadline must be in RFC3339 format: %v", err)
+		}
+		opts = append(opts, asynq.Deadline(t))
+	}
+	if req.Group != "" {
+		if err := validateIdentifier("group", req.Group); err != nil {
+			return nil, err
+		}
+		opts = append(opts, asynq.Group(req.Group))
+	}
+	if req.ProcessAt != "" || req.ProcessIn != "" {
+		t, err := req.time(now)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, asynq.ProcessAt(t))
+	}
+	return opts, nil
+}
+
+// payload returns the payload of the task in compact JSON encoding, encrypted with encrypt if not nil.
+func (req *enqueueTaskRequest) payload(encrypt PayloadTransformFunc) ([]byte, error) {
+	var payload []byte
+	if len(req.Payload) > 0 && !bytes.Equal(req.Payload, []byte("null")) {
+		var b bytes.Buffer
+		if err := json.Compact(&b, req.Payload); err != nil {
+			return nil, err
+		}
+		payload = b.Bytes()
+	}
+	if encrypt == nil {
+		return payload, nil
+	}
+	encrypted, err := encrypt(req.Type, payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not encrypt payload: %v", err)
+	}
+	return encrypted, nil
+}
+
+// newEnqueueTaskHandlerFunc returns a handler which enqueues a task in the queue with the
+// type, payload, and options in the request body, e.g. to replay a job manually.
+// The queue is created if it does not exist.
+func newEnqueueTaskHandlerFunc(client *asynq.Client, encrypt PayloadTransformFunc, pf *taskPayloadFormatter, rf ResultFormatter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req enqueueTaskRequest
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		opts, err := req.options(mux.Vars(r)["qname"], time.Now())
+		if err != nil {
+			writeBadRequestError(w, r, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+		payload, err := req.payload(encrypt)
+		if err != nil {
+			writeBadRequestError(w, r, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+		info, err := client.EnqueueContext(r.Context(), asynq.NewTask(req.Type, payload), opts...)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		writeResponseJSON(w, toTaskInfo(info, pf, rf))
+	}
+}
+
+// newRunSchedulerEntryHandlerFunc returns a handler which enqueues the task of a scheduler
+// entry immediately with the options of the entry, without waiting for the next scheduled time.
+// The schedule of the entry is not affected.
+func newRunSchedulerEntryHandlerFunc(inspector *asynq.Inspector, client *asynq.Client, pf *taskPayloadFormatter, rf ResultFormatter, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entryID := mux.Vars(r)["entry_id"]
+		entry, err := findSchedulerEntry(inspector, entryID)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		qname := schedulerEntryQueue(entry)
+		if !qa.canView(r, qname) {
+			writeError(w, r, fmt.Errorf("%w: %q", errSchedulerEntryNotFound, entryID))
+			return
+		}
+		if !qa.checkMutate(w, r, qname) {
+			return
+		}
+		// The payload of the entry is used as is since it was registered by the scheduler.
+		info, err := client.EnqueueContext(r.Context(), entry.Task, entry.Opts...)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, toTaskInfo(info, pf, rf))
+	}
+}
@@ -0,0 +1,268 @@
+package asynqmon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - FormatterRule which selects formatters by task type
+//   - built-in formatters for JSON, protobuf, and msgpack encoded data
+// ****************************************************************************
+
+// FormatterRule selects the formatters used for tasks of the matching types.
+type FormatterRule struct {
+	// TaskType is a pattern matched against the task type using path.Match
+	// (e.g. "email:*"). Empty pattern matches every task type.
+	TaskType string
+
+	// PayloadFormatter is used to format payloads of the matching tasks.
+	//
+	// This field is optional. If nil, the next matching rule or Options.PayloadFormatter is used.
+	PayloadFormatter PayloadFormatter
+
+	// ResultFormatter is used to format results of the matching tasks.
+	//
+	// This field is optional. If nil, the next matching rule or Options.ResultFormatter is used.
+	ResultFormatter ResultFormatter
+}
+
+// validateFormatterRules reports an error if any of the task type patterns is malformed.
+func validateFormatterRules(rules []FormatterRule) error {
+	for _, r := range rules {
+		if _, err := path.Match(r.TaskType, ""); err != nil {
+			return fmt.Errorf("invalid task type pattern %q of formatter rule: %v", r.TaskType, err)
+		}
+	}
+	return nil
+}
+
+// matchTaskType reports whether the task type matches the pattern of a FormatterRule.
+func matchTaskType(pattern, taskType string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, taskType)
+	return ok
+}
+
+// payloadFormatterForRules returns a PayloadFormatter which formats payloads with the
+// formatter of the first matching rule, or with pf if no rule matches.
+func payloadFormatterForRules(rules []FormatterRule, pf PayloadFormatter) PayloadFormatter {
+	if len(rules) == 0 {
+		return pf
+	}
+	return PayloadFormatterFunc(func(taskType string, payload []byte) string {
+		for _, r := range rules {
+			if r.PayloadFormatter != nil && matchTaskType(r.TaskType, taskType) {
+				return r.PayloadFormatter.FormatPayload(taskType, payload)
+			}
+		}
+		return pf.FormatPayload(taskType, payload)
+	})
+}
+
+// resultFormatterForRules returns a ResultFormatter which formats results with the
+// formatter of the first matching rule, or with rf if no rule matches.
+func resultFormatterForRules(rules []FormatterRule, rf ResultFormatter) ResultFormatter {
+	if len(rules) == 0 {
+		return rf
+	}
+	return ResultFormatterFunc(func(taskType string, result []byte) string {
+		for _, r := range rules {
+			if r.ResultFormatter != nil && matchTaskType(r.TaskType, taskType) {
+				return r.ResultFormatter.FormatResult(taskType, result)
+			}
+		}
+		return rf.FormatResult(taskType, result)
+	})
+}
+
+// Formatter formats data encoded in a particular format as a string shown in the UI.
+// It can be used as both a PayloadFormatter and a ResultFormatter.
+type Formatter interface {
+	PayloadFormatter
+	ResultFormatter
+}
+
+// decodingFormatter is a Formatter which decodes data with the given function.
+// If the data cannot be decoded, the error is shown in place of the data.
+type decodingFormatter struct {
+	format string // name of the format for error messages
+	decode func(data []byte) (string, error)
+}
+
+func (f *decodingFormatter) FormatPayload(_ string, payload []byte) string {
+	s, err := f.decode(payload)
+	if err != nil {
+		return fmt.Sprintf("could not decode %s payload: %v", f.format, err)
+	}
+	return s
+}
+
+func (f *decodingFormatter) FormatResult(_ string, result []byte) string {
+	s, err := f.decode(result)
+	if err != nil {
+		return fmt.Sprintf("could not decode %s result: %v", f.format, err)
+	}
+	return s
+}
+
+// JSONFormatter formats JSON encoded data in compact form.
+// Data which is not valid JSON is formatted as DefaultPayloadFormatter does.
+var JSONFormatter Formatter = &decodingFormatter{
+	format: "json",
+	decode: func(data []byte) (string, error) {
+		var b bytes.Buffer
+		if err := json.Compact(&b, data); err != nil {
+			return DefaultPayloadFormatter.FormatPayload("", data), nil
+		}
+		return b.String(), nil
+	},
+}
+
+// MsgpackFormatter formats msgpack encoded data as JSON.
+// Map keys which are not strings are formatted with fmt.Sprint.
+var MsgpackFormatter Formatter = &decodingFormatter{
+	format: "msgpack",
+	decode: func(data []byte) (string, error) {
+		dec := msgpack.NewDecoder(bytes.NewReader(data))
+		// Decode maps with keys of any type rather than string keys only.
+		dec.SetMapDecoder(func(d *msgpack.Decoder) (interface{}, error) {
+			return d.DecodeUntypedMap()
+		})
+		v, err := dec.DecodeInterface()
+		if err != nil {
+			return "", err
+		}
+		b, err := json.Marshal(jsonCompatible(v))
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	},
+}
+
+// jsonCompatible converts maps with keys other than strings, which cannot be
+// encoded with encoding/json, to maps with string keys.
+func jsonCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonCompatible(e)
+		}
+		return m
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = jsonCompatible(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonCompatible(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// LoadProtoDescriptorSets reads the files at the given paths, each of which holds
+// a serialized google.protobuf.FileDescriptorSet (e.g. generated by
+// "protoc --include_imports --descriptor_set_out=<path>"), and returns the registry of the described files.
+func LoadProtoDescriptorSets(paths ...string) (*protoregistry.Files, error) {
+	var set descriptorpb.FileDescriptorSet
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var s descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("invalid descriptor set %q: %v", p, err)
+		}
+		// Files imported by several protos are included in each of their descriptor sets.
+		for _, fd := range s.File {
+			if !seen[fd.GetName()] {
+				seen[fd.GetName()] = true
+				set.File = append(set.File, fd)
+			}
+		}
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor sets: %v", err)
+	}
+	return files, nil
+}
+
+// NewProtobufFormatter returns a Formatter which decodes data as the protobuf message
+// with the given full name (e.g. "acme.email.v1.WelcomeEmail") found in files, and formats it as JSON.
+// Fields are named as in the proto files.
+func NewProtobufFormatter(files *protoregistry.Files, messageName string) (Formatter, error) {
+	d, err := files.FindDescriptorByName(protoreflect.FullName(messageName))
+	if err != nil {
+		return nil, fmt.Errorf("could not find protobuf message %q: %v", messageName, err)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a protobuf message", messageName)
+	}
+	// Resolve google.protobuf.Any fields with the messages in files.
+	types := dynamicTypes(files)
+	return &decodingFormatter{
+		format: "protobuf",
+		decode: func(data []byte) (string, error) {
+			msg := dynamicpb.NewMessage(md)
+			if err := (proto.UnmarshalOptions{Resolver: types}).Unmarshal(data, msg); err != nil {
+				return "", err
+			}
+			b, err := protojson.MarshalOptions{UseProtoNames: true, Resolver: types}.Marshal(msg)
+			if err != nil {
+				return "", err
+			}
+			// protojson randomly adds whitespace to its output, so make it stable.
+			var out bytes.Buffer
+			if err := json.Compact(&out, b); err != nil {
+				return "", err
+			}
+			return out.String(), nil
+		},
+	}, nil
+}
+
+// dynamicTypes returns the registry of dynamic types of the messages and extensions in files.
+func dynamicTypes(files *protoregistry.Files) *protoregistry.Types {
+	types := new(protoregistry.Types)
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		registerDynamicTypes(types, fd.Messages(), fd.Extensions())
+		return true
+	})
+	return types
+}
+
+func registerDynamicTypes(types *protoregistry.Types, msgs protoreflect.MessageDescriptors, exts protoreflect.ExtensionDescriptors) {
+	for i := 0; i < msgs.Len(); i++ {
+		md := msgs.Get(i)
+		types.RegisterMessage(dynamicpb.NewMessageType(md))
+		registerDynamicTypes(types, md.Messages(), md.Extensions())
+	}
+	for i := 0; i < exts.Len(); i++ {
+		types.RegisterExtension(dynamicpb.NewExtensionType(exts.Get(i)))
+	}
+}
@@ -2,13 +2,18 @@ package asynqmon
 
 import (
 	"embed"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/hibiken/asynq"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Options are used to configure HTTPHandler.
@@ -19,29 +24,298 @@ type Options struct {
 	// This field is optional. Default is "/".
 	RootPath string
 
+	// Set DetectRootPath to true to detect the URL path prefix the handler is mounted at
+	// from each request. This allows the handler to be mounted with http.StripPrefix
+	// (or a router that strips the mount point) without setting RootPath to the mount point.
+	//
+	// If RootPath is also set, it is treated as relative to the detected prefix.
+	DetectRootPath bool
+
+	// RootPathFunc returns the URL path prefix the handler is mounted at for each request
+	// (e.g. "/monitoring"). Use it with routers which do not strip the mount point from the
+	// URL path, such as chi's Mount and gorilla/mux subrouters; the prefix is removed from
+	// the URL path if the path starts with it.
+	//
+	// If RootPath is also set, it is treated as relative to the prefix.
+	//
+	// This field is optional. If set, DetectRootPath is ignored.
+	RootPathFunc func(r *http.Request) string
+
 	// RedisConnOpt specifies the connection to a redis-server or redis-cluster.
 	//
-	// This field is required.
+	// This field is required unless Clusters is set.
 	RedisConnOpt asynq.RedisConnOpt
 
+	// Clusters are named redis connections to monitor instead of RedisConnOpt.
+	// Each cluster is served under "<RootPath>/clusters/<name>" with all the other options,
+	// and the Web UI has a selector to switch between clusters.
+	// Requests outside of these paths (e.g. "<RootPath>/api/queues") are served by the first cluster.
+	//
+	// This field is optional. RedisConnOpt must not be set if Clusters is set.
+	Clusters []Cluster
+
 	// PayloadFormatter is used to convert payload bytes to string shown in the UI.
 	//
 	// This field is optional.
 	PayloadFormatter PayloadFormatter
 
+	// DecryptPayload is called with the payload of each task before it is formatted with
+	// PayloadFormatter, so that tasks with payloads encrypted by the producer can be shown in the UI.
+	// If it returns an error, the error is shown in place of the payload.
+	//
+	// This field is optional.
+	DecryptPayload PayloadTransformFunc
+
+	// EncryptPayload is the inverse of DecryptPayload. It is called with the payload of each task
+	// enqueued through the UI or the API before the task is written to redis.
+	//
+	// This field is optional.
+	EncryptPayload PayloadTransformFunc
+
+	// DecompressPayload is used to decompress task payloads to report their size after decompression.
+	// It should return an error if the payload is not compressed.
+	//
+	// This field is optional. By default, payloads compressed with gzip or zlib are detected.
+	DecompressPayload PayloadTransformFunc
+
 	// ResultFormatter is used to convert result bytes to string shown in the UI.
 	//
 	// This field is optional.
 	ResultFormatter ResultFormatter
 
+	// Formatters selects the formatters used for tasks by task type, so that tasks of
+	// different types can be encoded in different formats (e.g. JSON, protobuf, msgpack).
+	// The first matching rule is used. PayloadFormatter and ResultFormatter are used
+	// for tasks which match no rule.
+	//
+	// This field is optional.
+	Formatters []FormatterRule
+
+	// Set LargeIntegersAsStrings to true to emit integers which cannot be represented exactly
+	// as JavaScript numbers (greater than 2^53 - 1 in magnitude) as strings in API responses,
+	// including integers in JSON task payloads and results, so that 64-bit IDs are not
+	// rounded by the browser or other JSON clients.
+	LargeIntegersAsStrings bool
+
 	// PrometheusAddress specifies the address of the Prometheus to connect to.
 	//
 	// This field is optional. If this field is set, asynqmon will query the Prometheus server
 	// to get the time series data about queue metrics and show them in the web UI.
 	PrometheusAddress string
 
+	// PrometheusTimeout specifies the timeout for each query sent to the Prometheus server.
+	//
+	// This field is optional. Default is 10 seconds.
+	PrometheusTimeout time.Duration
+
+	// PrometheusMaxRange specifies the maximum time range of metrics that can be queried at once.
+	//
+	// This field is optional. If zero, the range is not limited.
+	PrometheusMaxRange time.Duration
+
+	// PrometheusMinStep specifies the minimum resolution step of range queries sent to the Prometheus server.
+	// Coarser steps make queries over long time ranges cheaper.
+	//
+	// This field is optional. If zero, the step is chosen based on the time range.
+	PrometheusMinStep time.Duration
+
 	// Set ReadOnly to true to restrict user to view-only mode.
+	// Every API request other than GET is rejected with 405 Method Not Allowed
+	// before it is authenticated or processed in any other way, regardless of
+	// the scopes of API tokens or roles of users, and the Web UI hides the controls to make changes.
 	ReadOnly bool
+
+	// Set DisableMetrics to true to remove the metrics view and its endpoints.
+	DisableMetrics bool
+
+	// MetricsHistoryInterval specifies how often the stats of every queue are recorded in redis,
+	// so that the metrics view charts them without a Prometheus server. Each sample is recorded
+	// by one asynqmon instance, so instances sharing the redis server share the same history.
+	//
+	// This field is optional. If zero, queue stats are not recorded. The minimum is 1 second.
+	MetricsHistoryInterval time.Duration
+
+	// MetricsHistoryRetention specifies how long recorded queue stats are kept in redis.
+	//
+	// This field is optional. Default is 24 hours.
+	MetricsHistoryRetention time.Duration
+
+	// Set DisableSchedulers to true to remove the schedulers view and its endpoints.
+	DisableSchedulers bool
+
+	// Set DisableRedisInfo to true to remove the redis info view and its endpoints.
+	DisableRedisInfo bool
+
+	// TaskLinks configures links into external systems (e.g. logs, traces)
+	// shown in the task details view.
+	//
+	// This field is optional.
+	TaskLinks []TaskLink
+
+	// TraceIDPath is a JSONPath expression (e.g. "$.metadata.trace_id") used to extract
+	// a trace ID from JSON task payloads. The trace ID is shown in the task details view.
+	//
+	// This field is optional.
+	TraceIDPath string
+
+	// TraceURL is a text/template string used to build a link to the trace in a tracing
+	// backend such as Jaeger or Tempo (e.g. "https://jaeger.example.com/trace/{{.TraceID}}").
+	// The template is executed with the fields .TraceID, .ID, .Queue, and .Type.
+	//
+	// This field is optional. It is only used if TraceIDPath is set.
+	TraceURL string
+
+	// BulkOperationThreshold specifies the maximum number of tasks a bulk operation
+	// (e.g. delete all, batch run) can affect. Requests for operations which would affect more
+	// tasks are rejected with 409 Conflict unless they have the force=true query parameter.
+	//
+	// This field is optional. If zero, bulk operations are not limited.
+	BulkOperationThreshold int
+
+	// IdempotencyKeyTTL specifies how long responses of requests with the Idempotency-Key header
+	// are kept in redis. Retried requests with the same key within this window get the stored
+	// response instead of being processed again.
+	//
+	// This field is optional. Default is 24 hours.
+	IdempotencyKeyTTL time.Duration
+
+	// APITokens are bearer tokens which grant access to the API.
+	// If set, every API request must have the "Authorization: Bearer <token>" header
+	// with one of the tokens, and the token must have the scope required by the endpoint.
+	// The Web UI asks for a token when the API responds with 401 Unauthorized.
+	//
+	// This field is optional. If empty, the API can be accessed without a token.
+	APITokens []APIToken
+
+	// Users are users who sign in to the Web UI and the API with HTTP basic authentication.
+	// If set, users need to sign in to view the Web UI, and users with RoleViewer
+	// are not allowed to make any changes.
+	//
+	// This field is optional.
+	Users []User
+
+	// OIDC configures sign in to the Web UI with an OpenID Connect provider.
+	// If set, users need to sign in to view the Web UI, and users with RoleViewer
+	// are not allowed to make any changes.
+	//
+	// This field is optional.
+	OIDC *OIDCOptions
+
+	// QueueAuthorizer is called with API requests to decide whether the requester can view
+	// the given queue with its tasks, and whether it can make changes to them (e.g. so that each team
+	// only sees and manages its own queues when the handler is embedded in another application).
+	// Requests for queues which cannot be viewed are responded with 404 Not Found as if the queue
+	// did not exist, and requests which make changes without mutate with 403 Forbidden.
+	// Queues which cannot be viewed are left out of the endpoints which report on every queue
+	// (e.g. the queue list, daily stats, servers and their history, scheduler entries, metrics, and live updates).
+	// Alert rules are changed only by requesters which can change their queue, which is empty
+	// for rules on every queue.
+	//
+	// This field is optional. If nil, every queue can be viewed and changed.
+	QueueAuthorizer func(r *http.Request, qname string) (view bool, mutate bool)
+
+	// QueueInfoCacheTTL specifies how long queue stats are cached by the server.
+	// Caching reduces the number of redis scans when many users have the dashboard open.
+	// Cached stats of a queue are invalidated when the queue is modified through the API.
+	//
+	// This field is optional. If zero, queue stats are not cached.
+	QueueInfoCacheTTL time.Duration
+
+	// Set KeyspaceNotifications to true to subscribe to redis keyspace notifications for
+	// asynq keys and invalidate cached queue stats as soon as the queue changes.
+	// This allows a longer QueueInfoCacheTTL without showing stale data.
+	// Redis must be configured to publish keyspace events (e.g. notify-keyspace-events "KA").
+	//
+	// This field is optional. It has no effect unless QueueInfoCacheTTL is set.
+	KeyspaceNotifications bool
+
+	// RedisTimeout specifies the maximum duration each redis command can take, including the
+	// commands issued by API requests which modify data and by background jobs.
+	// API requests whose commands exceed the timeout are responded with 504 Gateway Timeout.
+	// The timeout is set as the read and write timeouts of RedisClientOpt, RedisFailoverClientOpt
+	// and RedisClusterClientOpt, unless they are already shorter.
+	//
+	// This field is optional. Default is 5 seconds. Set to a negative value to disable the timeout.
+	RedisTimeout time.Duration
+
+	// CircuitBreakerThreshold specifies the number of consecutive redis failures after which
+	// API requests fail fast instead of waiting on redis. While the breaker is open, GET requests
+	// are responded with the last successful response to the same user or API token marked with
+	// the "X-Asynqmon-Stale: true" header, unless QueueAuthorizer is set.
+	//
+	// This field is optional. Default is 5. Set to a negative value to disable the circuit breaker.
+	CircuitBreakerThreshold int
+
+	// CircuitBreakerCooldown specifies how long the circuit breaker stays open
+	// before a request is let through to check if redis has recovered.
+	//
+	// This field is optional. Default is 30 seconds.
+	CircuitBreakerCooldown time.Duration
+
+	// Notifiers are used to send notifications to external channels (e.g. Slack, email).
+	//
+	// This field is optional.
+	Notifiers []Notifier
+
+	// AlertRules specifies conditions to send notifications about through Notifiers.
+	// Rules are evaluated every 30 seconds. More rules can be managed at runtime with
+	// the /api/alert_rules endpoints; those are stored in redis.
+	//
+	// This field is optional.
+	AlertRules []*AlertRule
+
+	// LiveUpdateInterval specifies how often queue stats and active tasks are polled from redis
+	// for the live updates stream, which pushes the changes to the Web UI with Server-Sent Events
+	// instead of each browser tab polling the API. Redis is polled once per interval
+	// regardless of the number of clients, and only while at least one client is connected.
+	//
+	// This field is optional. Default is 3 seconds.
+	LiveUpdateInterval time.Duration
+
+	// Set DisableLiveUpdates to true to remove the live updates stream. The Web UI polls the API instead.
+	// This is necessary if a proxy in front of the handler buffers responses.
+	DisableLiveUpdates bool
+
+	// AuditSinks receive an AuditEntry for every API request which makes changes,
+	// recording who deleted, archived, or ran which tasks and when.
+	//
+	// This field is optional. Recent entries are available from /api/audit regardless.
+	AuditSinks []AuditSink
+
+	// Set AuditRedisStream to true to keep audit entries in a redis stream ("asynqmon:audit"),
+	// so that /api/audit serves them across restarts and from every instance of asynqmon.
+	// Otherwise the last 1000 entries are kept in memory.
+	AuditRedisStream bool
+
+	// AuditActorHeader specifies the request header which identifies the actor of requests
+	// not authenticated by asynqmon, such as "X-Forwarded-User" set by an authenticating proxy.
+	//
+	// This field is optional.
+	AuditActorHeader string
+
+	// Panels are custom panels shown in the Plugins page of the Web UI,
+	// with data provided by the embedding application.
+	//
+	// This field is optional.
+	Panels []*Panel
+
+	// SchedulerLocation specifies the time zone used by the asynq.Scheduler(s) to
+	// interpret cron specs. The value should match asynq.SchedulerOpts.Location.
+	//
+	// This field is optional. Default is UTC.
+	SchedulerLocation *time.Location
+
+	// TracerProvider is used to record OpenTelemetry spans of API requests, and of the redis commands
+	// issued while serving them. Requests carrying a trace context understood by the global propagator
+	// (otel.GetTextMapPropagator) continue the trace of the caller.
+	//
+	// This field is optional. If nil, the global tracer provider (otel.GetTracerProvider) is used.
+	TracerProvider trace.TracerProvider
+
+	// MeterProvider is used to record OpenTelemetry metrics of the duration of API requests and redis commands.
+	//
+	// This field is optional. If nil, the global meter provider (otel.GetMeterProvider) is used.
+	MeterProvider metric.MeterProvider
 }
 
 // HTTPHandler is a http.Handler for asynqmon application.
@@ -49,22 +323,107 @@ type HTTPHandler struct {
 	router   *mux.Router
 	closers  []func() error
 	rootPath string // the value should not have the trailing slash
+
+	detectRootPath bool
+	rootPathFunc   func(r *http.Request) string
 }
 
 func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case h.rootPathFunc != nil:
+		r = withMountPrefix(r, h.rootPathFunc(r))
+	case h.detectRootPath:
+		r = withStrippedPrefix(r)
+	}
 	h.router.ServeHTTP(w, r)
 }
 
 // New creates a HTTPHandler with the given options.
 func New(opts Options) *HTTPHandler {
+	if len(opts.Clusters) > 0 {
+		return newMultiClusterHandler(opts)
+	}
+	return newHandler(opts, nil)
+}
+
+// Validate returns an error if the options are invalid, i.e. if New would panic with them.
+// It does not connect to redis or to the OpenID Connect provider.
+func (opts Options) Validate() error {
+	if len(opts.Clusters) > 0 {
+		if opts.RedisConnOpt != nil {
+			return errors.New("RedisConnOpt and Clusters fields cannot be set at the same time")
+		}
+		if err := validateClusters(opts.Clusters); err != nil {
+			return err
+		}
+	} else if opts.RedisConnOpt == nil {
+		return errors.New("RedisConnOpt field is required")
+	}
+	if opts.RootPath != "" && !strings.HasPrefix(opts.RootPath, "/") {
+		return errors.New("RootPath must start with a slash")
+	}
+	if opts.MetricsHistoryInterval < 0 || (opts.MetricsHistoryInterval > 0 && opts.MetricsHistoryInterval < minMetricsHistoryInterval) {
+		return fmt.Errorf("MetricsHistoryInterval must be at least %v", minMetricsHistoryInterval)
+	}
+	if opts.MetricsHistoryRetention < 0 {
+		return errors.New("MetricsHistoryRetention must not be negative")
+	}
+	if _, err := parseTaskLinks(opts.TaskLinks); err != nil {
+		return err
+	}
+	if err := validateFormatterRules(opts.Formatters); err != nil {
+		return err
+	}
+	if _, err := newTraceIDExtractor(opts.TraceIDPath, opts.TraceURL); err != nil {
+		return err
+	}
+	if err := validatePanels(opts.Panels); err != nil {
+		return err
+	}
+	if _, err := newAPIAuth(opts.APITokens); err != nil {
+		return err
+	}
+	if _, err := newUserAuth(opts.Users); err != nil {
+		return err
+	}
+	if opts.OIDC != nil {
+		if _, err := newOIDCProvider(*opts.OIDC, opts.RootPath); err != nil {
+			return err
+		}
+	}
+	// Validate copies of the rules since default values are set to them.
+	rules := make([]*AlertRule, len(opts.AlertRules))
+	for i, r := range opts.AlertRules {
+		rule := *r
+		rules[i] = &rule
+	}
+	return validateAlertRules(rules)
+}
+
+// newHandler creates a HTTPHandler for a single redis connection.
+// clusters is nil unless the handler serves one of multiple clusters.
+func newHandler(opts Options, clusters *clusterContext) *HTTPHandler {
 	if opts.RedisConnOpt == nil {
 		panic("asynqmon.New: RedisConnOpt field is required")
 	}
-	rc, ok := opts.RedisConnOpt.MakeRedisClient().(redis.UniversalClient)
+	if opts.RedisTimeout == 0 {
+		opts.RedisTimeout = defaultRedisTimeout
+	}
+	tel := newTelemetry(opts.TracerProvider, opts.MeterProvider)
+	connOpt := opts.RedisConnOpt
+	if opts.RedisTimeout > 0 {
+		connOpt = withRedisTimeout(connOpt, opts.RedisTimeout)
+	}
+	connOpt = tel.redisConnOpt(connOpt)
+	rc, ok := connOpt.MakeRedisClient().(redis.UniversalClient)
 	if !ok {
 		panic(fmt.Sprintf("asnyqmon.New: unsupported RedisConnOpt type %T", opts.RedisConnOpt))
 	}
-	i := asynq.NewInspector(opts.RedisConnOpt)
+	i := asynq.NewInspector(connOpt)
+	c := asynq.NewClient(connOpt)
+	sampler := newQueueStatsSampler(i, queueStatsSampleInterval)
+	history := newServerHistory(rc)
+	servers := newServerStatsSampler(i, history, serverStatsSampleInterval)
 
 	// Make sure that RootPath starts with a slash if provided.
 	if opts.RootPath != "" && !strings.HasPrefix(opts.RootPath, "/") {
@@ -73,10 +432,139 @@ func New(opts Options) *HTTPHandler {
 	// Remove tailing slash from RootPath.
 	opts.RootPath = strings.TrimSuffix(opts.RootPath, "/")
 
+	if opts.SchedulerLocation == nil {
+		opts.SchedulerLocation = time.UTC
+	}
+	if opts.PrometheusTimeout == 0 {
+		opts.PrometheusTimeout = defaultPrometheusTimeout
+	}
+	if opts.CircuitBreakerThreshold == 0 {
+		opts.CircuitBreakerThreshold = defaultCircuitBreakerThreshold
+	}
+	if opts.CircuitBreakerCooldown == 0 {
+		opts.CircuitBreakerCooldown = defaultCircuitBreakerCooldown
+	}
+	if opts.IdempotencyKeyTTL == 0 {
+		opts.IdempotencyKeyTTL = defaultIdempotencyKeyTTL
+	}
+	if opts.LiveUpdateInterval == 0 {
+		opts.LiveUpdateInterval = defaultLiveUpdateInterval
+	}
+	if opts.MetricsHistoryInterval < 0 || (opts.MetricsHistoryInterval > 0 && opts.MetricsHistoryInterval < minMetricsHistoryInterval) {
+		panic(fmt.Sprintf("asynqmon.New: MetricsHistoryInterval must be at least %v", minMetricsHistoryInterval))
+	}
+	if opts.MetricsHistoryRetention < 0 {
+		panic("asynqmon.New: MetricsHistoryRetention must not be negative")
+	}
+	if opts.MetricsHistoryRetention == 0 {
+		opts.MetricsHistoryRetention = defaultMetricsHistoryRetention
+	}
+	links, err := parseTaskLinks(opts.TaskLinks)
+	if err != nil {
+		panic(fmt.Sprintf("asynqmon.New: %v", err))
+	}
+	if err := validateFormatterRules(opts.Formatters); err != nil {
+		panic(fmt.Sprintf("asynqmon.New: %v", err))
+	}
+	tracer, err := newTraceIDExtractor(opts.TraceIDPath, opts.TraceURL)
+	if err != nil {
+		panic(fmt.Sprintf("asynqmon.New: %v", err))
+	}
+	if tracer != nil {
+		tracer.decrypt = opts.DecryptPayload
+	}
+	if err := validatePanels(opts.Panels); err != nil {
+		panic(fmt.Sprintf("asynqmon.New: %v", err))
+	}
+	var auth *apiAuth
+	if len(opts.APITokens) > 0 || len(opts.Users) > 0 || opts.OIDC != nil {
+		if auth, err = newAPIAuth(opts.APITokens); err != nil {
+			panic(fmt.Sprintf("asynqmon.New: %v", err))
+		}
+		if len(opts.Users) > 0 {
+			if auth.users, err = newUserAuth(opts.Users); err != nil {
+				panic(fmt.Sprintf("asynqmon.New: %v", err))
+			}
+		}
+		if opts.OIDC != nil {
+			// Sign in is shared by all clusters.
+			authRootPath := opts.RootPath
+			if clusters != nil {
+				authRootPath = clusters.rootPath
+			}
+			if auth.oidc, err = newOIDCProvider(*opts.OIDC, authRootPath); err != nil {
+				panic(fmt.Sprintf("asynqmon.New: %v", err))
+			}
+		}
+		auth.store = newAPITokenStore(rc)
+	}
+
+	sampler.start()
+	servers.start()
+	closers := []func() error{sampler.stop, servers.stop}
+
+	cache := newQueueInfoCache(i, opts.QueueInfoCacheTTL)
+	if opts.KeyspaceNotifications && opts.QueueInfoCacheTTL > 0 {
+		l, err := newKeyspaceListener(rc, cache)
+		if err != nil {
+			log.Printf("error: could not listen to keyspace notifications: %v", err)
+		} else {
+			closers = append(closers, l.close)
+		}
+	}
+
+	var failovers *failoverWatcher
+	if opt, ok := sentinelOpt(opts.RedisConnOpt); ok {
+		failovers = newFailoverWatcher(opt)
+		failovers.start()
+		closers = append(closers, failovers.stop)
+	}
+
+	var replica *replicaDetector
+	if c, ok := rc.(*redis.Client); ok {
+		replica = newReplicaDetector(c, replicaCheckInterval)
+		replica.start()
+		closers = append(closers, replica.stop)
+	}
+
+	if err := validateAlertRules(opts.AlertRules); err != nil {
+		panic(fmt.Sprintf("asynqmon.New: %v", err))
+	}
+	var clusterName string
+	if clusters != nil {
+		clusterName = clusters.name
+	}
+	alerts := newAlertEvaluator(i, opts.SchedulerLocation, newAlertRuleStore(rc), opts.AlertRules, opts.Notifiers, alertEvaluationInterval, clusterName)
+	alerts.start()
+	closers = append(closers, alerts.stop)
+
+	var latency *redisLatencyMonitor
+	if !opts.DisableRedisInfo {
+		latency = newRedisLatencyMonitor(rc, redisLatencySampleInterval)
+		latency.start()
+		closers = append(closers, latency.stop)
+	}
+
+	var metricsHistory *metricsHistory
+	if !opts.DisableMetrics && opts.MetricsHistoryInterval > 0 {
+		metricsHistory = newMetricsHistory(rc, i, opts.MetricsHistoryInterval, opts.MetricsHistoryRetention)
+		metricsHistory.start()
+		closers = append(closers, metricsHistory.stop)
+	}
+
+	var live *liveUpdates
+	if !opts.DisableLiveUpdates {
+		live = newLiveUpdates(i, cache, sampler, newTaskPayloadFormatter(opts), opts.LiveUpdateInterval, opts.LargeIntegersAsStrings)
+		closers = append(closers, live.stop)
+	}
+
 	return &HTTPHandler{
-		router:   muxRouter(opts, rc, i),
-		closers:  []func() error{rc.Close, i.Close},
+		router:   muxRouter(opts, rc, i, c, auth, cache, sampler, servers, history, latency, failovers, replica, alerts, metricsHistory, live, links, tracer, clusters, tel),
+		closers:  append(closers, rc.Close, i.Close, c.Close),
 		rootPath: opts.RootPath,
+
+		detectRootPath: opts.DetectRootPath,
+		rootPathFunc:   opts.RootPathFunc,
 	}
 }
 
@@ -92,6 +580,8 @@ func (h *HTTPHandler) Close() error {
 
 // RootPath returns the root URL path used for asynqmon application.
 // Returned path string does not have the trailing slash.
+//
+// If Options.DetectRootPath or Options.RootPathFunc is set, the returned path does not include the prefix.
 func (h *HTTPHandler) RootPath() string {
 	return h.rootPath
 }
@@ -99,81 +589,107 @@ func (h *HTTPHandler) RootPath() string {
 //go:embed ui/build/*
 var staticContents embed.FS
 
-func muxRouter(opts Options, rc redis.UniversalClient, inspector *asynq.Inspector) *mux.Router {
+func muxRouter(opts Options, rc redis.UniversalClient, inspector *asynq.Inspector, client *asynq.Client, auth *apiAuth, cache *queueInfoCache, sampler *queueStatsSampler, servers *serverStatsSampler, history *serverHistory, latency *redisLatencyMonitor, failovers *failoverWatcher, replica *replicaDetector, alerts *alertEvaluator, metricsHistory *metricsHistory, live *liveUpdates, links []*taskLinkTemplate, tracer *traceIDExtractor, clusters *clusterContext, tel *telemetry) *mux.Router {
 	router := mux.NewRouter().PathPrefix(opts.RootPath).Subrouter()
 
-	var payloadFmt PayloadFormatter = DefaultPayloadFormatter
-	if opts.PayloadFormatter != nil {
-		payloadFmt = opts.PayloadFormatter
-	}
+	payloadFmt := newTaskPayloadFormatter(opts)
 
 	var resultFmt ResultFormatter = DefaultResultFormatter
 	if opts.ResultFormatter != nil {
 		resultFmt = opts.ResultFormatter
 	}
+	resultFmt = resultFormatterForRules(opts.Formatters, resultFmt)
+	if opts.LargeIntegersAsStrings {
+		resultFmt = largeIntsResultFormatter(resultFmt)
+	}
 
 	api := router.PathPrefix("/api").Subrouter()
+	qa := newQueueAuthorizer(opts.QueueAuthorizer)
 
 	// Queue endpoints.
-	api.HandleFunc("/queues", newListQueuesHandlerFunc(inspector)).Methods("GET")
-	api.HandleFunc("/queues/{qname}", newGetQueueHandlerFunc(inspector)).Methods("GET")
+	api.HandleFunc("/queues", newListQueuesHandlerFunc(inspector, cache, sampler, qa)).Methods("GET")
+	api.HandleFunc("/queues/{qname}", newGetQueueHandlerFunc(inspector, cache)).Methods("GET")
 	api.HandleFunc("/queues/{qname}", newDeleteQueueHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}:pause", newPauseQueueHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}:resume", newResumeQueueHandlerFunc(inspector)).Methods("POST")
 
 	// Queue Historical Stats endpoint.
-	api.HandleFunc("/queue_stats", newListQueueStatsHandlerFunc(inspector)).Methods("GET")
+	api.HandleFunc("/queue_stats", newListQueueStatsHandlerFunc(inspector, qa)).Methods("GET")
+
+	// Payload size stats endpoint.
+	api.HandleFunc("/queues/{qname}/payload_stats", newGetPayloadStatsHandlerFunc(inspector, payloadFmt)).Methods("GET")
+
+	// Queue growth rate endpoint.
+	api.HandleFunc("/queue_growth", newListQueueGrowthHandlerFunc(inspector, sampler, qa)).Methods("GET")
+	api.HandleFunc("/queue_drain", newListQueueDrainHandlerFunc(inspector, sampler, qa)).Methods("GET")
+	api.HandleFunc("/queue_capacity_report", newGetCapacityReportHandlerFunc(inspector, sampler, qa)).Methods("GET")
 
 	// Task endpoints.
-	api.HandleFunc("/queues/{qname}/active_tasks", newListActiveTasksHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/active_tasks", newListActiveTasksHandlerFunc(inspector, rc, payloadFmt)).Methods("GET")
 	api.HandleFunc("/queues/{qname}/active_tasks/{task_id}:cancel", newCancelActiveTaskHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/active_tasks:cancel_all", newCancelAllActiveTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/active_tasks:batch_cancel", newBatchCancelActiveTasksHandlerFunc(inspector)).Methods("POST")
 
-	api.HandleFunc("/queues/{qname}/pending_tasks", newListPendingTasksHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/pending_tasks", newListPendingTasksHandlerFunc(inspector, rc, payloadFmt)).Methods("GET")
 	api.HandleFunc("/queues/{qname}/pending_tasks/{task_id}", newDeleteTaskHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/pending_tasks:delete_all", newDeleteAllPendingTasksHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/pending_tasks:batch_delete", newBatchDeleteTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/pending_tasks/{task_id}:archive", newArchiveTaskHandlerFunc(inspector)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/pending_tasks/{task_id}:prioritize", newPrioritizePendingTaskHandlerFunc(rc)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/pending_tasks:archive_all", newArchiveAllPendingTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/pending_tasks:batch_archive", newBatchArchiveTasksHandlerFunc(inspector)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/pending_tasks:schedule_all", newScheduleAllPendingTasksHandlerFunc(inspector, rc)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/pending_tasks:batch_schedule", newBatchSchedulePendingTasksHandlerFunc(rc)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/pending_tasks:delete_matching", newTasksByFilterHandlerFunc(inspector, rc, payloadFmt, "pending", "delete")).Methods("POST")
+	api.HandleFunc("/queues/{qname}/pending_tasks:archive_matching", newTasksByFilterHandlerFunc(inspector, rc, payloadFmt, "pending", "archive")).Methods("POST")
 
-	api.HandleFunc("/queues/{qname}/scheduled_tasks", newListScheduledTasksHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/scheduled_tasks", newListScheduledTasksHandlerFunc(inspector, rc, payloadFmt)).Methods("GET")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks/{task_id}", newDeleteTaskHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks:delete_all", newDeleteAllScheduledTasksHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks:batch_delete", newBatchDeleteTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks/{task_id}:run", newRunTaskHandlerFunc(inspector)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/scheduled_tasks/{task_id}:reschedule", newRescheduleTaskHandlerFunc(rc, "scheduled")).Methods("POST")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks:run_all", newRunAllScheduledTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks:batch_run", newBatchRunTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks/{task_id}:archive", newArchiveTaskHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks:archive_all", newArchiveAllScheduledTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks:batch_archive", newBatchArchiveTasksHandlerFunc(inspector)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/scheduled_tasks:delete_matching", newTasksByFilterHandlerFunc(inspector, rc, payloadFmt, "scheduled", "delete")).Methods("POST")
+	api.HandleFunc("/queues/{qname}/scheduled_tasks:run_matching", newTasksByFilterHandlerFunc(inspector, rc, payloadFmt, "scheduled", "run")).Methods("POST")
+	api.HandleFunc("/queues/{qname}/scheduled_tasks:archive_matching", newTasksByFilterHandlerFunc(inspector, rc, payloadFmt, "scheduled", "archive")).Methods("POST")
 
-	api.HandleFunc("/queues/{qname}/retry_tasks", newListRetryTasksHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/retry_tasks", newListRetryTasksHandlerFunc(inspector, rc, payloadFmt)).Methods("GET")
 	api.HandleFunc("/queues/{qname}/retry_tasks/{task_id}", newDeleteTaskHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/retry_tasks:delete_all", newDeleteAllRetryTasksHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/retry_tasks:batch_delete", newBatchDeleteTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/retry_tasks/{task_id}:run", newRunTaskHandlerFunc(inspector)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/retry_tasks/{task_id}:reschedule", newRescheduleTaskHandlerFunc(rc, "retry")).Methods("POST")
 	api.HandleFunc("/queues/{qname}/retry_tasks:run_all", newRunAllRetryTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/retry_tasks:batch_run", newBatchRunTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/retry_tasks/{task_id}:archive", newArchiveTaskHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/retry_tasks:archive_all", newArchiveAllRetryTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/retry_tasks:batch_archive", newBatchArchiveTasksHandlerFunc(inspector)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/retry_tasks:delete_matching", newTasksByFilterHandlerFunc(inspector, rc, payloadFmt, "retry", "delete")).Methods("POST")
+	api.HandleFunc("/queues/{qname}/retry_tasks:run_matching", newTasksByFilterHandlerFunc(inspector, rc, payloadFmt, "retry", "run")).Methods("POST")
+	api.HandleFunc("/queues/{qname}/retry_tasks:archive_matching", newTasksByFilterHandlerFunc(inspector, rc, payloadFmt, "retry", "archive")).Methods("POST")
 
-	api.HandleFunc("/queues/{qname}/archived_tasks", newListArchivedTasksHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/archived_tasks", newListArchivedTasksHandlerFunc(inspector, rc, payloadFmt)).Methods("GET")
 	api.HandleFunc("/queues/{qname}/archived_tasks/{task_id}", newDeleteTaskHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/archived_tasks:delete_all", newDeleteAllArchivedTasksHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/archived_tasks:batch_delete", newBatchDeleteTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/archived_tasks/{task_id}:run", newRunTaskHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/archived_tasks:run_all", newRunAllArchivedTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/archived_tasks:batch_run", newBatchRunTasksHandlerFunc(inspector)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/archived_tasks:delete_matching", newTasksByFilterHandlerFunc(inspector, rc, payloadFmt, "archived", "delete")).Methods("POST")
+	api.HandleFunc("/queues/{qname}/archived_tasks:run_matching", newTasksByFilterHandlerFunc(inspector, rc, payloadFmt, "archived", "run")).Methods("POST")
 
-	api.HandleFunc("/queues/{qname}/completed_tasks", newListCompletedTasksHandlerFunc(inspector, payloadFmt, resultFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/completed_tasks", newListCompletedTasksHandlerFunc(inspector, rc, payloadFmt, resultFmt)).Methods("GET")
 	api.HandleFunc("/queues/{qname}/completed_tasks/{task_id}", newDeleteTaskHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/completed_tasks:delete_all", newDeleteAllCompletedTasksHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/completed_tasks:batch_delete", newBatchDeleteTasksHandlerFunc(inspector)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/completed_tasks:delete_matching", newTasksByFilterHandlerFunc(inspector, rc, payloadFmt, "completed", "delete")).Methods("POST")
 
-	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks", newListAggregatingTasksHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks", newListAggregatingTasksHandlerFunc(inspector, rc, payloadFmt)).Methods("GET")
 	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks/{task_id}", newDeleteTaskHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks:delete_all", newDeleteAllAggregatingTasksHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks:batch_delete", newBatchDeleteTasksHandlerFunc(inspector)).Methods("POST")
@@ -184,43 +700,222 @@ func muxRouter(opts Options, rc redis.UniversalClient, inspector *asynq.Inspecto
 	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks:archive_all", newArchiveAllAggregatingTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks:batch_archive", newBatchArchiveTasksHandlerFunc(inspector)).Methods("POST")
 
-	api.HandleFunc("/queues/{qname}/tasks/{task_id}", newGetTaskHandlerFunc(inspector, payloadFmt, resultFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/tasks", newEnqueueTaskHandlerFunc(client, opts.EncryptPayload, payloadFmt, resultFmt)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/tasks/{task_id}", newGetTaskHandlerFunc(inspector, payloadFmt, resultFmt, links, tracer)).Methods("GET")
+	mover := &taskMover{inspector: inspector, client: client, rc: rc}
+	api.HandleFunc("/queues/{qname}/tasks/{task_id}:move", newMoveTaskHandlerFunc(mover, qa)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/tasks:batch_move", newBatchMoveTasksHandlerFunc(mover, qa)).Methods("POST")
+
+	// Task export and import endpoints.
+	api.HandleFunc("/queues/{qname}/{state:pending|active|scheduled|retry|archived|completed}/export", newExportTasksHandlerFunc(inspector, rc, payloadFmt)).Methods("GET").Name(streamingRouteName)
+	api.HandleFunc("/queues/{qname}/tasks:import", newImportTasksHandlerFunc(inspector, client)).Methods("POST")
+
+	// Task policy endpoint.
+	api.HandleFunc("/task_policies", newListTaskPoliciesHandlerFunc(inspector, qa)).Methods("GET")
 
 	// Groups endponts
 	api.HandleFunc("/queues/{qname}/groups", newListGroupsHandlerFunc(inspector)).Methods("GET")
 
 	// Servers endpoints.
-	api.HandleFunc("/servers", newListServersHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	api.HandleFunc("/servers", newListServersHandlerFunc(inspector, payloadFmt, qa)).Methods("GET")
+	api.HandleFunc("/servers/utilization", newListServerUtilizationHandlerFunc(servers)).Methods("GET").Name(nonRedisRouteName)
+	api.HandleFunc("/servers/history", newListServerHistoryHandlerFunc(history, qa)).Methods("GET")
 
 	// Scheduler Entry endpoints.
-	api.HandleFunc("/scheduler_entries", newListSchedulerEntriesHandlerFunc(inspector, payloadFmt)).Methods("GET")
-	api.HandleFunc("/scheduler_entries/{entry_id}/enqueue_events", newListSchedulerEnqueueEventsHandlerFunc(inspector)).Methods("GET")
+	if !opts.DisableSchedulers {
+		api.HandleFunc("/scheduler_entries", newListSchedulerEntriesHandlerFunc(inspector, payloadFmt, qa)).Methods("GET")
+		api.HandleFunc("/scheduler_entries/{entry_id}/enqueue_events", newListSchedulerEnqueueEventsHandlerFunc(inspector, qa)).Methods("GET")
+		api.HandleFunc("/scheduler_entries/{entry_id}:run", newRunSchedulerEntryHandlerFunc(inspector, client, payloadFmt, resultFmt, qa)).Methods("POST")
+		api.HandleFunc("/scheduler_enqueue_failures", newListSchedulerEnqueueFailuresHandlerFunc(inspector, opts.SchedulerLocation, qa)).Methods("GET")
+	}
+
+	// Redis info endpoints.
+	if !opts.DisableRedisInfo {
+		switch c := rc.(type) {
+		case *redis.ClusterClient:
+			api.HandleFunc("/redis_info", newRedisClusterInfoHandlerFunc(c, inspector, qa)).Methods("GET")
+		case *redis.Client:
+			api.HandleFunc("/redis_info", newRedisInfoHandlerFunc(c)).Methods("GET")
+		}
+		api.HandleFunc("/redis_latency", newGetRedisLatencyHandlerFunc(rc, latency)).Methods("GET")
+	}
+
+	// Redis failover endpoint.
+	api.HandleFunc("/redis_failovers", newListRedisFailoversHandlerFunc(failovers)).Methods("GET").Name(nonRedisRouteName)
+
+	// Notifier endpoints.
+	api.HandleFunc("/notifiers", newListNotifiersHandlerFunc(opts.Notifiers)).Methods("GET").Name(nonRedisRouteName)
+	api.HandleFunc("/notifiers:test", newTestNotifiersHandlerFunc(opts.Notifiers)).Methods("POST").Name(nonRedisRouteName)
+
+	// Alert endpoints.
+	api.HandleFunc("/alerts", newListAlertsHandlerFunc(alerts, qa)).Methods("GET")
+	api.HandleFunc("/alert_rules", newListAlertRulesHandlerFunc(alerts, qa)).Methods("GET")
+	api.HandleFunc("/alert_rules", newCreateAlertRuleHandlerFunc(alerts, qa)).Methods("POST")
+	api.HandleFunc("/alert_rules/{rule_name}:update", newUpdateAlertRuleHandlerFunc(alerts, qa)).Methods("POST")
+	api.HandleFunc("/alert_rules/{rule_name}", newDeleteAlertRuleHandlerFunc(alerts, qa)).Methods("DELETE")
 
-	// Redis info endpoint.
-	switch c := rc.(type) {
-	case *redis.ClusterClient:
-		api.HandleFunc("/redis_info", newRedisClusterInfoHandlerFunc(c, inspector)).Methods("GET")
-	case *redis.Client:
-		api.HandleFunc("/redis_info", newRedisInfoHandlerFunc(c)).Methods("GET")
+	// Maintenance mode endpoints.
+	maintenance := newMaintenanceMode(rc)
+	if clusters != nil {
+		maintenance = clusters.maintenance
+	}
+	api.HandleFunc("/maintenance", newGetMaintenanceModeHandlerFunc(maintenance)).Methods("GET").Name(maintenanceRouteName)
+	api.HandleFunc("/maintenance:enable", newEnableMaintenanceModeHandlerFunc(maintenance)).Methods("POST").Name(maintenanceRouteName)
+	api.HandleFunc("/maintenance:disable", newDisableMaintenanceModeHandlerFunc(maintenance)).Methods("POST").Name(maintenanceRouteName)
+
+	// Plugin endpoints.
+	api.HandleFunc("/plugins", newListPanelsHandlerFunc(opts.Panels)).Methods("GET").Name(nonRedisRouteName)
+	api.HandleFunc("/plugins/{name}", newGetPanelDataHandlerFunc(opts.Panels)).Methods("GET").Name(nonRedisRouteName)
+
+	// Settings export and import endpoints.
+	api.HandleFunc("/settings:export", newExportSettingsHandlerFunc(auth, alerts, qa)).Methods("GET")
+	api.HandleFunc("/settings:import", newImportSettingsHandlerFunc(auth, alerts, qa)).Methods("POST")
+
+	// API token endpoints.
+	// Tokens can only be managed once authentication is enabled.
+	if auth != nil {
+		api.HandleFunc("/tokens", newListAPITokensHandlerFunc(auth.store)).Methods("GET")
+		api.HandleFunc("/tokens", newCreateAPITokenHandlerFunc(auth.store)).Methods("POST")
+		api.HandleFunc("/tokens/{token_id}:rotate", newRotateAPITokenHandlerFunc(auth.store)).Methods("POST")
+		api.HandleFunc("/tokens/{token_id}", newRevokeAPITokenHandlerFunc(auth.store)).Methods("DELETE")
+	}
+
+	// Live updates endpoint.
+	if live != nil {
+		api.HandleFunc(liveUpdatesPath, newLiveUpdatesHandlerFunc(live, qa)).Methods("GET").Name(nonRedisRouteName)
+	}
+
+	// Audit endpoint.
+	var auditRC redis.UniversalClient
+	if opts.AuditRedisStream {
+		auditRC = rc
+	}
+	var cluster string
+	if clusters != nil {
+		cluster = clusters.name
+	}
+	audit := newAuditLog(opts.AuditSinks, auditRC, opts.AuditActorHeader, cluster)
+	auditRoute := api.HandleFunc("/audit", newListAuditEntriesHandlerFunc(audit, qa)).Methods("GET")
+	if auditRC == nil {
+		auditRoute.Name(nonRedisRouteName)
+	}
+
+	// Clusters endpoint.
+	if clusters != nil {
+		api.HandleFunc("/clusters", newListClustersHandlerFunc(clusters)).Methods("GET").Name(nonRedisRouteName)
 	}
 
+	// Health endpoint.
+	// QueueAuthorizer may decide based on anything in the request, so responses cannot be
+	// shared between requests and are not kept to serve while the breaker is open.
+	breaker := newCircuitBreaker(opts.CircuitBreakerThreshold, opts.CircuitBreakerCooldown, opts.QueueAuthorizer == nil)
+	api.HandleFunc("/health", newGetHealthHandlerFunc(breaker, replica)).Methods("GET").Name(nonRedisRouteName)
+
 	// Time series metrics endpoints.
-	api.HandleFunc("/metrics", newGetMetricsHandlerFunc(http.DefaultClient, opts.PrometheusAddress)).Methods("GET")
+	if !opts.DisableMetrics {
+		metricsClient := &http.Client{Timeout: opts.PrometheusTimeout}
+		limits := metricsLimits{maxRange: opts.PrometheusMaxRange, minStep: opts.PrometheusMinStep}
+		api.HandleFunc("/metrics", newGetMetricsHandlerFunc(metricsClient, opts.PrometheusAddress, limits, newMetricsCache(), qa)).Methods("GET").Name(nonRedisRouteName)
+	}
+	if metricsHistory != nil {
+		api.HandleFunc("/metrics/history", newGetMetricsHistoryHandlerFunc(metricsHistory, qa)).Methods("GET")
+		api.HandleFunc("/metrics/history/{qname}", newListQueueHistoryHandlerFunc(metricsHistory)).Methods("GET")
+	}
 
-	// Restrict APIs when running in read-only mode.
+	// Assign an ID to each request. This needs to be the first middleware
+	// so that the ID is available in error responses from other middlewares.
+	api.Use(withRequestID)
+	// Record spans and metrics of requests, including the ones rejected by other middlewares.
+	api.Use(tel.middleware)
+	// Restrict APIs when running in read-only mode. This comes before any other
+	// processing of the request so that no request which makes changes gets past it.
 	if opts.ReadOnly {
 		api.Use(restrictToReadOnly)
 	}
+	// Reject requests with invalid route parameters.
+	api.Use(validateRouteVars)
+	// Quote integers which JavaScript cannot represent exactly.
+	if opts.LargeIntegersAsStrings {
+		api.Use(quoteLargeIntsInResponse)
+	}
+	// Respond with an error instead of the UI for unknown API endpoints.
+	api.NotFoundHandler = withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeErrorResponse(w, r, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("no endpoint for %s %s", r.Method, r.URL.Path))
+	}))
+	api.MethodNotAllowedHandler = withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeErrorResponse(w, r, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, fmt.Sprintf("method %s is not allowed for %s", r.Method, r.URL.Path))
+	}))
+	// Require API tokens if configured.
+	if auth != nil {
+		api.Use(auth.middleware)
+	}
+	// Restrict access to queues. This comes after authentication so that
+	// the authorizer can identify the requester from the request context.
+	if qa != nil {
+		api.Use(qa.middleware)
+	}
+	// Record requests which make changes. This comes after authentication to know the actor.
+	api.Use(audit.middleware)
+	// Restrict APIs while maintenance mode is enabled at runtime.
+	api.Use(maintenance.middleware)
+	// Reject mutations while connected to a read-only replica.
+	api.Use(replica.middleware)
+	// Fail fast while redis is failing.
+	api.Use(breaker.middleware)
+	// Guard against unexpectedly large bulk operations.
+	bulk := &bulkGuard{inspector: inspector, rc: rc, pf: payloadFmt, threshold: opts.BulkOperationThreshold}
+	api.Use(bulk.middleware)
+	// Deduplicate retried requests with the Idempotency-Key header.
+	idempotency := &idempotencyStore{rc: rc, ttl: opts.IdempotencyKeyTTL}
+	api.Use(idempotency.middleware)
+	// Invalidate cached queue stats on mutations.
+	api.Use(cache.middleware)
 
 	// Everything else, route to uiAssetsHandler.
-	router.NotFoundHandler = &uiAssetsHandler{
-		rootPath:       opts.RootPath,
-		contents:       staticContents,
-		staticDirPath:  "ui/build",
-		indexFileName:  "index.html",
-		prometheusAddr: opts.PrometheusAddress,
-		readOnly:       opts.ReadOnly,
+	var disabledSections []string
+	prometheusAddr := opts.PrometheusAddress
+	if opts.DisableMetrics {
+		disabledSections = append(disabledSections, "metrics")
+		prometheusAddr = "" // hides the link to metrics view
+	}
+	if metricsHistory == nil {
+		disabledSections = append(disabledSections, "metrics_history")
+	}
+	if opts.DisableSchedulers {
+		disabledSections = append(disabledSections, "schedulers")
+	}
+	if opts.DisableRedisInfo {
+		disabledSections = append(disabledSections, "redis")
 	}
+	if len(opts.Panels) == 0 {
+		disabledSections = append(disabledSections, "plugins")
+	}
+	if opts.DisableLiveUpdates {
+		disabledSections = append(disabledSections, "live")
+	}
+	// Liveness and readiness probes. These are outside of the API so that
+	// they do not require authentication.
+	router.HandleFunc("/healthz", newLivenessHandlerFunc()).Methods("GET", "HEAD")
+	router.HandleFunc("/readyz", newReadinessHandlerFunc(rc)).Methods("GET", "HEAD")
+
+	// Sign in endpoints.
+	if auth != nil && auth.oidc != nil {
+		router.HandleFunc("/auth/login", newOIDCLoginHandlerFunc(auth.oidc)).Methods("GET")
+		router.HandleFunc(oidcCallbackPath, newOIDCCallbackHandlerFunc(auth.oidc)).Methods("GET")
+		router.HandleFunc("/auth/logout", newOIDCLogoutHandlerFunc(auth.oidc)).Methods("GET", "POST")
+	}
+
+	router.NotFoundHandler = auth.uiMiddleware(&uiAssetsHandler{
+		rootPath:         opts.RootPath,
+		contents:         staticContents,
+		staticDirPath:    "ui/build",
+		indexFileName:    "index.html",
+		prometheusAddr:   prometheusAddr,
+		readOnly:         opts.ReadOnly,
+		signOut:          auth != nil && auth.oidc != nil,
+		clusters:         clusters,
+		disabledSections: disabledSections,
+	})
 
 	return router
 }
@@ -229,7 +924,7 @@ func muxRouter(opts Options, rc redis.UniversalClient, inspector *asynq.Inspecto
 func restrictToReadOnly(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" && r.Method != "" {
-			http.Error(w, fmt.Sprintf("API Server is running in read-only mode: %s request is not allowed", r.Method), http.StatusMethodNotAllowed)
+			writeErrorResponse(w, r, http.StatusMethodNotAllowed, errCodeReadOnly, fmt.Sprintf("API Server is running in read-only mode: %s request is not allowed", r.Method))
 			return
 		}
 		h.ServeHTTP(w, r)
@@ -0,0 +1,48 @@
+package asynqmon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - http.Handler(s) for liveness and readiness probes
+// ****************************************************************************
+
+// Maximum duration the readiness probe waits for redis to respond to PING.
+const readinessTimeout = 2 * time.Second
+
+type probeResponse struct {
+	Status string `json:"status"`
+	// Round trip time of PING to redis in milliseconds. Only set by the readiness probe.
+	RedisLatencyMillisec *float64 `json:"redis_latency_ms,omitempty"`
+}
+
+// newLivenessHandlerFunc returns a handler which responds with 200 OK as long as the handler is serving requests.
+// It does not depend on redis, so that the process is not restarted while redis is unavailable.
+func newLivenessHandlerFunc() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeResponseJSON(w, probeResponse{Status: healthOK})
+	}
+}
+
+// newReadinessHandlerFunc returns a handler which responds with 200 OK if redis responds to PING
+// within readinessTimeout, and with 503 Service Unavailable otherwise.
+func newReadinessHandlerFunc(rc redis.UniversalClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+		start := time.Now()
+		if err := rc.Ping(ctx).Err(); err != nil {
+			writeErrorResponse(w, r, http.StatusServiceUnavailable, errCodeRedisUnavailable, fmt.Sprintf("redis did not respond to PING: %v", err))
+			return
+		}
+		latency := float64(time.Since(start).Microseconds()) / 1000
+		writeResponseJSON(w, probeResponse{Status: healthOK, RedisLatencyMillisec: &latency})
+	}
+}
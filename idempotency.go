@@ -0,0 +1,260 @@
+package asynqmon
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - idempotencyStore which deduplicates retried mutating API requests
+// ****************************************************************************
+
+// Header used by clients to specify the idempotency key of a request.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	// Default duration for which responses are kept to be replayed.
+	defaultIdempotencyKeyTTL = 24 * time.Hour
+
+	// Duration for which a key is locked while the request is being processed.
+	// The lock is extended while the request is processed, and expires in case
+	// the process dies before the response is stored.
+	idempotencyLockTTL = time.Minute
+
+	// Interval at which the lock is extended while the request is being processed.
+	idempotencyLockRefreshInterval = idempotencyLockTTL / 3
+
+	// Maximum length of an idempotency key.
+	maxIdempotencyKeyLen = 255
+
+	// Responses larger than this are not stored.
+	maxIdempotentResponseSize = 1 << 20
+
+	// Timeout for redis commands issued after the request is handled.
+	idempotencyStoreTimeout = 5 * time.Second
+)
+
+// idempotencyStore stores responses of mutating requests with an Idempotency-Key header
+// in redis so that a retried request gets the stored response instead of being processed twice.
+type idempotencyStore struct {
+	rc  redis.UniversalClient
+	ttl time.Duration
+}
+
+type idempotencyRecord struct {
+	// Fingerprint of the request which used the key.
+	Fingerprint string `json:"fingerprint"`
+	// Completed is false while the request is being processed.
+	Completed   bool   `json:"completed"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// idempotencyRedisKey returns the redis key of the idempotency key used by the
+// given identity, so that clients cannot get the responses to each other's requests.
+func idempotencyRedisKey(identity, key string) string {
+	sum := sha256.Sum256([]byte(identity + "\x00" + key))
+	return "asynqmon:idempotency:" + hex.EncodeToString(sum[:])
+}
+
+// requestIdentity returns the authenticated identity which made the request,
+// or empty string if the request is not authenticated.
+func requestIdentity(r *http.Request) string {
+	if info := apiTokenFromContext(r.Context()); info != nil {
+		return info.String()
+	}
+	return ""
+}
+
+// extendIdempotencyLockCmd extends the lock of a key as long as it is held by the request.
+//
+// KEYS[1] -> asynqmon:idempotency:<hash>
+// ARGV[1] -> lock value set by the request
+// ARGV[2] -> TTL of the lock in milliseconds
+var extendIdempotencyLockCmd = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// keepLocked extends the lock of the key until the returned function is called,
+// so that the lock does not expire while a long running request is being processed.
+func (s *idempotencyStore) keepLocked(rkey string, lock []byte) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(idempotencyLockRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+				err := extendIdempotencyLockCmd.Run(ctx, s.rc, []string{rkey}, string(lock), idempotencyLockTTL.Milliseconds()).Err()
+				cancel()
+				if err != nil {
+					log.Printf("error: could not extend lock of idempotency key: %v", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// requestFingerprint returns a digest of the method, path and body of the request.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// middleware deduplicates non-GET requests which have the Idempotency-Key header.
+//
+// The first request with a given key is processed and its response is stored.
+// Subsequent requests with the same key get the stored response with the
+// Idempotent-Replayed header set, as long as they are identical to the first request.
+func (s *idempotencyStore) middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || r.Method == "GET" || r.Method == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen || !isValidRequestID(key) {
+			writeBadRequestError(w, r, fmt.Sprintf("invalid %s header: must be at most %d printable ASCII characters", idempotencyKeyHeader, maxIdempotencyKeyLen))
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, requestBodyLimit(r)))
+		if err != nil {
+			writeBadRequestError(w, r, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(r, body)
+		rkey := idempotencyRedisKey(requestIdentity(r), key)
+
+		lock, err := json.Marshal(&idempotencyRecord{Fingerprint: fingerprint})
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		ok, err := s.rc.SetNX(r.Context(), rkey, lock, idempotencyLockTTL).Result()
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if !ok {
+			s.replay(w, r, rkey, fingerprint)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		stop := s.keepLocked(rkey, lock)
+		h.ServeHTTP(rec, r)
+		stop()
+
+		ctx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+		defer cancel()
+		if rec.status >= 500 || rec.overflow {
+			// Allow the request to be retried.
+			s.rc.Del(ctx, rkey)
+			return
+		}
+		data, err := json.Marshal(&idempotencyRecord{
+			Fingerprint: fingerprint,
+			Completed:   true,
+			Status:      rec.status,
+			ContentType: rec.Header().Get("Content-Type"),
+			Body:        rec.body.Bytes(),
+		})
+		if err == nil {
+			err = s.rc.Set(ctx, rkey, data, s.ttl).Err()
+		}
+		if err != nil {
+			s.rc.Del(ctx, rkey)
+		}
+	})
+}
+
+// replay writes the stored response for the key.
+func (s *idempotencyStore) replay(w http.ResponseWriter, r *http.Request, rkey, fingerprint string) {
+	data, err := s.rc.Get(r.Context(), rkey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		writeErrorResponse(w, r, http.StatusConflict, errCodeIdempotencyKeyInProgress,
+			"a request with the same idempotency key was just completed or abandoned, retry the request")
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var rec idempotencyRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	switch {
+	case rec.Fingerprint != fingerprint:
+		writeErrorResponse(w, r, http.StatusUnprocessableEntity, errCodeIdempotencyKeyReused,
+			"the idempotency key was already used for a different request")
+	case !rec.Completed:
+		writeErrorResponse(w, r, http.StatusConflict, errCodeIdempotencyKeyInProgress,
+			"a request with the same idempotency key is being processed")
+	default:
+		if rec.ContentType != "" {
+			w.Header().Set("Content-Type", rec.ContentType)
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(rec.Status)
+		w.Write(rec.Body)
+	}
+}
+
+// responseRecorder is a http.ResponseWriter which keeps a copy of the response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	overflow    bool // true if the body is too large to be kept
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	if !rec.overflow {
+		if rec.body.Len()+len(b) > maxIdempotentResponseSize {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.ResponseWriter.Write(b)
+}
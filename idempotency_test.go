@@ -0,0 +1,171 @@
+package asynqmon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestIdempotencyStore(t *testing.T) (*idempotencyStore, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rc.Close() })
+	return &idempotencyStore{rc: rc, ttl: time.Hour}, mr
+}
+
+// idempotentRequest sends a request with the idempotency key, as the given user if not empty.
+func idempotentRequest(h http.Handler, method, body, key, user string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/api/queues/default/tasks", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(idempotencyKeyHeader, key)
+	}
+	if user != "" {
+		r = r.WithContext(context.WithValue(r.Context(), apiTokenKey{}, newUserInfo(user, RoleAdmin)))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	s, _ := newTestIdempotencyStore(t)
+	var mu sync.Mutex
+	calls := 0
+	h := s.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"call":%d}`, n)
+	}))
+
+	tests := []struct {
+		desc         string
+		method, body string
+		key, user    string
+		wantStatus   int
+		wantBody     string // checked if not empty
+		wantReplayed bool
+		wantCalls    int
+	}{
+		{"first request", "POST", `{"type":"a"}`, "k1", "", http.StatusCreated, `{"call":1}`, false, 1},
+		{"retried request is replayed", "POST", `{"type":"a"}`, "k1", "", http.StatusCreated, `{"call":1}`, true, 1},
+		{"key reused with a different body", "POST", `{"type":"b"}`, "k1", "", http.StatusUnprocessableEntity, "", false, 1},
+		{"key reused with a different method", "DELETE", `{"type":"a"}`, "k1", "", http.StatusUnprocessableEntity, "", false, 1},
+		{"another key", "POST", `{"type":"a"}`, "k2", "", http.StatusCreated, `{"call":2}`, false, 2},
+		{"same key used by a user", "POST", `{"type":"a"}`, "k1", "alice", http.StatusCreated, `{"call":3}`, false, 3},
+		{"same key used by another user", "POST", `{"type":"a"}`, "k1", "bob", http.StatusCreated, `{"call":4}`, false, 4},
+		{"retried request of a user is replayed", "POST", `{"type":"a"}`, "k1", "alice", http.StatusCreated, `{"call":3}`, true, 4},
+		{"request without key", "POST", `{"type":"a"}`, "", "", http.StatusCreated, `{"call":5}`, false, 5},
+		{"request without key again", "POST", `{"type":"a"}`, "", "", http.StatusCreated, `{"call":6}`, false, 6},
+		{"GET request is not deduplicated", "GET", "", "k3", "", http.StatusCreated, `{"call":7}`, false, 7},
+		{"GET request is not deduplicated again", "GET", "", "k3", "", http.StatusCreated, `{"call":8}`, false, 8},
+		{"invalid key", "POST", `{"type":"a"}`, strings.Repeat("k", maxIdempotencyKeyLen+1), "", http.StatusBadRequest, "", false, 8},
+	}
+	for _, tc := range tests {
+		w := idempotentRequest(h, tc.method, tc.body, tc.key, tc.user)
+		if w.Code != tc.wantStatus {
+			t.Errorf("%s: responded with %d, want %d", tc.desc, w.Code, tc.wantStatus)
+		}
+		if tc.wantBody != "" && w.Body.String() != tc.wantBody {
+			t.Errorf("%s: responded with body %q, want %q", tc.desc, w.Body.String(), tc.wantBody)
+		}
+		if got := w.Header().Get("Idempotent-Replayed") == "true"; got != tc.wantReplayed {
+			t.Errorf("%s: replayed = %t, want %t", tc.desc, got, tc.wantReplayed)
+		}
+		mu.Lock()
+		if calls != tc.wantCalls {
+			t.Errorf("%s: handler called %d times, want %d", tc.desc, calls, tc.wantCalls)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestIdempotencyMiddlewareRequestInProgress(t *testing.T) {
+	s, _ := newTestIdempotencyStore(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	h := s.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	}))
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- idempotentRequest(h, "POST", "{}", "k", "") }()
+	<-started
+	if w := idempotentRequest(h, "POST", "{}", "k", ""); w.Code != http.StatusConflict {
+		t.Errorf("request while the first one is in progress responded with %d, want %d", w.Code, http.StatusConflict)
+	}
+	close(release)
+	if w := <-first; w.Code != http.StatusOK || w.Body.String() != "done" {
+		t.Errorf("first request responded with %d %q, want %d %q", w.Code, w.Body.String(), http.StatusOK, "done")
+	}
+	if w := idempotentRequest(h, "POST", "{}", "k", ""); w.Header().Get("Idempotent-Replayed") != "true" || w.Body.String() != "done" {
+		t.Errorf("request after the first one completed was not replayed: %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestIdempotencyMiddlewareServerError(t *testing.T) {
+	s, mr := newTestIdempotencyStore(t)
+	calls := 0
+	h := s.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			writeErrorResponse(w, r, http.StatusServiceUnavailable, errCodeRedisUnavailable, "redis is down")
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	if w := idempotentRequest(h, "POST", "{}", "k", ""); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("first request responded with %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("redis has keys %v after a server error, want the idempotency key to be deleted", keys)
+	}
+	// The request can be retried after a server error.
+	if w := idempotentRequest(h, "POST", "{}", "k", ""); w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("retried request responded with %d (replayed %q), want %d and not replayed", w.Code, w.Header().Get("Idempotent-Replayed"), http.StatusOK)
+	}
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
+
+func TestExtendIdempotencyLock(t *testing.T) {
+	s, mr := newTestIdempotencyStore(t)
+	ctx := context.Background()
+	rkey := idempotencyRedisKey("", "k")
+	if err := s.rc.Set(ctx, rkey, "lock", idempotencyLockTTL).Err(); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(idempotencyLockTTL - time.Second)
+
+	// The lock is not extended by another request.
+	if err := extendIdempotencyLockCmd.Run(ctx, s.rc, []string{rkey}, "other", idempotencyLockTTL.Milliseconds()).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := mr.TTL(rkey); ttl != time.Second {
+		t.Errorf("TTL after another request tried to extend the lock is %v, want %v", ttl, time.Second)
+	}
+	if err := extendIdempotencyLockCmd.Run(ctx, s.rc, []string{rkey}, "lock", idempotencyLockTTL.Milliseconds()).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := mr.TTL(rkey); ttl != idempotencyLockTTL {
+		t.Errorf("TTL after the lock was extended is %v, want %v", ttl, idempotencyLockTTL)
+	}
+	mr.FastForward(idempotencyLockTTL - time.Second)
+	if !mr.Exists(rkey) {
+		t.Errorf("lock expired after it was extended")
+	}
+}
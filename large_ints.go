@@ -0,0 +1,142 @@
+package asynqmon
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - quoteLargeInts which makes JSON integers above 2^53 safe for JavaScript
+//   - middleware which applies it to API responses
+// ****************************************************************************
+
+// Largest integer which can be represented exactly as a JavaScript number (2^53 - 1).
+const maxSafeInteger = 1<<53 - 1
+
+// quoteLargeInts returns the JSON text with integers which cannot be represented
+// exactly as JavaScript numbers replaced with strings (e.g. 9007199254740993 becomes
+// "9007199254740993"). Other numbers and the formatting of the text are unchanged.
+// The input is returned as is if it is not valid JSON.
+func quoteLargeInts(b []byte) []byte {
+	if !json.Valid(b) {
+		return b
+	}
+	var out []byte // allocated on the first large integer
+	last := 0      // end of the input copied to out
+	inString, escaped := false, false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '-' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(b) && isNumberChar(b[j]) {
+				j++
+			}
+			if isUnsafeInteger(b[i:j]) {
+				out = append(out, b[last:i]...)
+				out = append(out, '"')
+				out = append(out, b[i:j]...)
+				out = append(out, '"')
+				last = j
+			}
+			i = j - 1
+		}
+	}
+	if out == nil {
+		return b
+	}
+	return append(out, b[last:]...)
+}
+
+func isNumberChar(c byte) bool {
+	return (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E'
+}
+
+// isUnsafeInteger reports whether the number literal is an integer outside of the
+// range which can be represented exactly as a JavaScript number.
+func isUnsafeInteger(num []byte) bool {
+	if bytes.ContainsAny(num, ".eE") {
+		return false
+	}
+	n, err := strconv.ParseInt(string(num), 10, 64)
+	if err != nil {
+		return true // out of int64 range
+	}
+	return n > maxSafeInteger || n < -maxSafeInteger
+}
+
+// largeIntsPayloadFormatter quotes large integers in JSON payloads.
+// Payloads are shown as strings in API responses, so the middleware does not see them,
+// but the UI parses JSON payloads to pretty print them.
+func largeIntsPayloadFormatter(pf PayloadFormatter) PayloadFormatter {
+	return PayloadFormatterFunc(func(taskType string, payload []byte) string {
+		return string(quoteLargeInts([]byte(pf.FormatPayload(taskType, payload))))
+	})
+}
+
+// largeIntsResultFormatter quotes large integers in JSON results.
+func largeIntsResultFormatter(rf ResultFormatter) ResultFormatter {
+	return ResultFormatterFunc(func(taskType string, result []byte) string {
+		return string(quoteLargeInts([]byte(rf.FormatResult(taskType, result))))
+	})
+}
+
+// quoteLargeIntsInResponse is a middleware which quotes large integers in JSON responses.
+func quoteLargeIntsInResponse(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Events of the live updates stream are quoted as they are sent.
+		// Exported tasks have no large integers other than in payloads, which are base64 encoded.
+		if route := mux.CurrentRoute(r); route != nil && (isLiveUpdatesRoute(route) || route.GetName() == streamingRouteName) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		bw := &bufferedWriter{header: make(http.Header)}
+		h.ServeHTTP(bw, r)
+		for k, vs := range bw.header {
+			w.Header()[k] = vs
+		}
+		w.Header().Del("Content-Length") // the length changes if integers are quoted
+		if bw.status == 0 {
+			bw.status = http.StatusOK
+		}
+		w.WriteHeader(bw.status)
+		w.Write(quoteLargeInts(bw.body.Bytes()))
+	})
+}
+
+// bufferedWriter buffers the response so that it can be rewritten before it is sent.
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (bw *bufferedWriter) Header() http.Header { return bw.header }
+
+func (bw *bufferedWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.body.Write(b)
+}
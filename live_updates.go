@@ -0,0 +1,515 @@
+package asynqmon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - liveUpdates which polls redis on behalf of every client of the live updates stream
+//   - http.Handler(s) for live updates related endpoints
+// ****************************************************************************
+
+const (
+	// Default value for Options.LiveUpdateInterval.
+	defaultLiveUpdateInterval = 3 * time.Second
+
+	// Interval between polls of daily stats, which change slowly and are expensive to fetch.
+	liveDailyStatsInterval = time.Minute
+
+	// Number of days of daily stats sent with the queue_stats event, same as /api/queue_stats.
+	liveDailyStatsDays = 90
+
+	// Maximum number of active tasks of each queue sent with the active_tasks event.
+	maxLiveActiveTasks = 500
+
+	// Interval between comments sent to keep idle connections from being closed by proxies.
+	liveKeepAliveInterval = 30 * time.Second
+
+	// Number of events buffered for each client. Clients which fall further behind are disconnected,
+	// and receive the latest state when they reconnect.
+	liveClientBufferSize = 64
+)
+
+// Path of the live updates endpoint relative to the API root.
+const liveUpdatesPath = "/live"
+
+// liveEvent is an event sent to the clients of the live updates stream.
+type liveEvent struct {
+	name  string
+	data  []byte // JSON encoded
+	queue string // queue of active_tasks events
+}
+
+type liveClient struct {
+	events chan *liveEvent
+}
+
+// liveUpdates polls queue stats, daily stats, and active tasks from redis while
+// there is at least one client connected to the stream, and sends the changes
+// to every client. The cost on redis does not depend on the number of clients.
+type liveUpdates struct {
+	inspector *asynq.Inspector
+	cache     *queueInfoCache
+	sampler   *queueStatsSampler
+	pf        *taskPayloadFormatter
+	interval  time.Duration
+	largeInts bool // quote large integers in the event data
+
+	mu      sync.Mutex
+	clients map[*liveClient]struct{}
+	done    chan struct{} // closed to stop the poller; nil while the poller is not running
+	stopped bool
+
+	// Latest state sent to the clients, used to compute changes and to
+	// send the current state to new clients.
+	queues        []byte
+	queueStats    []byte
+	queueStatsAt  time.Time
+	activeTasks   map[string]map[string]json.RawMessage // keyed by queue name and task ID
+	activeTaskIDs map[string][]string                   // keyed by queue name, in the order listed by redis
+
+	wg sync.WaitGroup
+}
+
+func newLiveUpdates(inspector *asynq.Inspector, cache *queueInfoCache, sampler *queueStatsSampler, pf *taskPayloadFormatter, interval time.Duration, largeInts bool) *liveUpdates {
+	return &liveUpdates{
+		inspector: inspector,
+		cache:     cache,
+		sampler:   sampler,
+		pf:        pf,
+		interval:  interval,
+		largeInts: largeInts,
+		clients:   make(map[*liveClient]struct{}),
+	}
+}
+
+// subscribe registers a new client and sends the current state to it.
+// The poller is started if it is not running.
+func (l *liveUpdates) subscribe() *liveClient {
+	c := &liveClient{events: make(chan *liveEvent, liveClientBufferSize)}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped {
+		close(c.events)
+		return c
+	}
+	l.clients[c] = struct{}{}
+	if l.done == nil {
+		l.done = make(chan struct{})
+		l.wg.Add(1)
+		go l.run(l.done)
+		return c
+	}
+	for _, e := range l.snapshotLocked() {
+		l.sendLocked(c, e)
+	}
+	return c
+}
+
+// unsubscribe unregisters the client. The poller stops at its next tick if no client is left,
+// so that clients which reconnect right away do not restart it.
+func (l *liveUpdates) unsubscribe(c *liveClient) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.clients[c]; ok {
+		delete(l.clients, c)
+		close(c.events)
+	}
+}
+
+func (l *liveUpdates) run(done chan struct{}) {
+	defer l.wg.Done()
+	l.poll()
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			idle := len(l.clients) == 0
+			if idle {
+				// Forget the state so that the next client gets fresh data.
+				l.done = nil
+				l.queues, l.queueStats, l.activeTasks, l.activeTaskIDs = nil, nil, nil, nil
+				l.queueStatsAt = time.Time{}
+			}
+			l.mu.Unlock()
+			if idle {
+				return
+			}
+			l.poll()
+		}
+	}
+}
+
+// stop stops the poller and disconnects every client.
+func (l *liveUpdates) stop() error {
+	l.mu.Lock()
+	l.stopped = true
+	if l.done != nil {
+		close(l.done)
+		l.done = nil
+	}
+	for c := range l.clients {
+		delete(l.clients, c)
+		close(c.events)
+	}
+	l.mu.Unlock()
+	l.wg.Wait()
+	return nil
+}
+
+type liveQueuesEvent struct {
+	Queues []*queueStateSnapshot `json:"queues"`
+}
+
+type liveActiveTasksEvent struct {
+	Queue string `json:"queue"`
+	// Reset is true if Added holds every active task of the queue and the tasks known to the
+	// client should be replaced with them.
+	Reset bool `json:"reset"`
+	// Tasks which are new or have changed since the last event.
+	Added []json.RawMessage `json:"added"`
+	// IDs of the tasks which are no longer active.
+	Removed []string `json:"removed"`
+}
+
+func (l *liveUpdates) poll() {
+	qnames, err := l.inspector.Queues()
+	if err != nil {
+		log.Printf("error: could not poll queues for live updates: %v", err)
+		return
+	}
+	snapshots := make([]*queueStateSnapshot, 0, len(qnames))
+	for _, qname := range qnames {
+		qinfo, err := l.cache.get(qname)
+		if err != nil {
+			log.Printf("error: could not poll stats of queue %q for live updates: %v", qname, err)
+			return
+		}
+		s := toQueueStateSnapshot(qinfo)
+		s.Growth = l.sampler.growth(qname)
+		s.Drain = l.sampler.drain(qname)
+		snapshots = append(snapshots, s)
+	}
+	queues, err := l.encode(liveQueuesEvent{Queues: snapshots})
+	if err != nil {
+		log.Printf("error: could not encode queues for live updates: %v", err)
+		return
+	}
+
+	var queueStats []byte
+	l.mu.Lock()
+	pollStats := time.Since(l.queueStatsAt) >= liveDailyStatsInterval
+	l.mu.Unlock()
+	if pollStats {
+		resp := listQueueStatsResponse{Stats: make(map[string][]*dailyStats)}
+		for _, qname := range qnames {
+			stats, err := l.inspector.History(qname, liveDailyStatsDays)
+			if err != nil {
+				log.Printf("error: could not poll daily stats of queue %q for live updates: %v", qname, err)
+				return
+			}
+			resp.Stats[qname] = toDailyStatsList(stats)
+		}
+		if queueStats, err = l.encode(resp); err != nil {
+			log.Printf("error: could not encode daily stats for live updates: %v", err)
+			return
+		}
+	}
+
+	tasks, ids, err := l.pollActiveTasks(qnames)
+	if err != nil {
+		log.Printf("error: could not poll active tasks for live updates: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !bytes.Equal(queues, l.queues) {
+		l.queues = queues
+		l.broadcastLocked(&liveEvent{name: "queues", data: queues})
+	}
+	if pollStats {
+		l.queueStatsAt = time.Now()
+		if !bytes.Equal(queueStats, l.queueStats) {
+			l.queueStats = queueStats
+			l.broadcastLocked(&liveEvent{name: "queue_stats", data: queueStats})
+		}
+	}
+	// Send the changes of active tasks of each queue, including the queues which no longer exist.
+	for qname := range l.activeTasks {
+		if _, ok := tasks[qname]; !ok {
+			tasks[qname] = nil
+		}
+	}
+	for qname, cur := range tasks {
+		prev := l.activeTasks[qname]
+		e := liveActiveTasksEvent{Queue: qname, Added: make([]json.RawMessage, 0), Removed: make([]string, 0)}
+		for _, id := range ids[qname] {
+			if data := cur[id]; !bytes.Equal(data, prev[id]) {
+				e.Added = append(e.Added, data)
+			}
+		}
+		for _, id := range l.activeTaskIDs[qname] {
+			if _, ok := cur[id]; !ok {
+				e.Removed = append(e.Removed, id)
+			}
+		}
+		if len(e.Added) == 0 && len(e.Removed) == 0 {
+			continue
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("error: could not encode active tasks for live updates: %v", err)
+			continue
+		}
+		l.broadcastLocked(&liveEvent{name: "active_tasks", data: data, queue: qname})
+	}
+	for qname, cur := range tasks {
+		if len(cur) == 0 {
+			delete(tasks, qname)
+			delete(ids, qname)
+		}
+	}
+	l.activeTasks, l.activeTaskIDs = tasks, ids
+}
+
+// pollActiveTasks returns the encoded active tasks of each queue keyed by task ID,
+// and their IDs in the order listed by redis.
+func (l *liveUpdates) pollActiveTasks(qnames []string) (map[string]map[string]json.RawMessage, map[string][]string, error) {
+	servers, err := l.inspector.Servers()
+	if err != nil {
+		return nil, nil, err
+	}
+	// workers maps taskID to workerInfo.
+	workers := make(map[string]*asynq.WorkerInfo)
+	for _, srv := range servers {
+		for _, w := range srv.ActiveWorkers {
+			workers[w.TaskID] = w
+		}
+	}
+	tasks := make(map[string]map[string]json.RawMessage, len(qnames))
+	ids := make(map[string][]string, len(qnames))
+	for _, qname := range qnames {
+		infos, err := l.inspector.ListActiveTasks(qname, asynq.PageSize(maxLiveActiveTasks))
+		if err != nil {
+			return nil, nil, err
+		}
+		tasks[qname] = make(map[string]json.RawMessage, len(infos))
+		for _, t := range toActiveTasks(infos, l.pf) {
+			if w, ok := workers[t.ID]; ok {
+				t.Started = w.Started.Format(time.RFC3339)
+				t.Deadline = w.Deadline.Format(time.RFC3339)
+			} else {
+				t.Started = "-"
+				t.Deadline = "-"
+			}
+			data, err := l.encode(t)
+			if err != nil {
+				return nil, nil, err
+			}
+			tasks[qname][t.ID] = data
+			ids[qname] = append(ids[qname], t.ID)
+		}
+	}
+	return tasks, ids, nil
+}
+
+func (l *liveUpdates) encode(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if l.largeInts {
+		data = quoteLargeInts(data)
+	}
+	return data, nil
+}
+
+// snapshotLocked returns the events which send the current state to a new client.
+func (l *liveUpdates) snapshotLocked() []*liveEvent {
+	var events []*liveEvent
+	if l.queues != nil {
+		events = append(events, &liveEvent{name: "queues", data: l.queues})
+	}
+	if l.queueStats != nil {
+		events = append(events, &liveEvent{name: "queue_stats", data: l.queueStats})
+	}
+	for qname, tasks := range l.activeTasks {
+		e := liveActiveTasksEvent{Queue: qname, Reset: true, Added: make([]json.RawMessage, 0, len(tasks)), Removed: make([]string, 0)}
+		for _, id := range l.activeTaskIDs[qname] {
+			e.Added = append(e.Added, tasks[id])
+		}
+		if data, err := json.Marshal(e); err == nil {
+			events = append(events, &liveEvent{name: "active_tasks", data: data, queue: qname})
+		}
+	}
+	return events
+}
+
+func (l *liveUpdates) broadcastLocked(e *liveEvent) {
+	for c := range l.clients {
+		l.sendLocked(c, e)
+	}
+}
+
+// sendLocked sends the event to the client without blocking.
+// The client is disconnected if its buffer is full.
+func (l *liveUpdates) sendLocked(c *liveClient, e *liveEvent) {
+	select {
+	case c.events <- e:
+	default:
+		delete(l.clients, c)
+		close(c.events)
+	}
+}
+
+// isLiveUpdatesRoute reports whether the route is the live updates endpoint.
+func isLiveUpdatesRoute(route *mux.Route) bool {
+	tmpl, err := route.GetPathTemplate()
+	return err == nil && strings.HasSuffix(tmpl, "/api"+liveUpdatesPath)
+}
+
+// filterLiveEvent returns the event without the data of the queues for which visible returns false,
+// or nil if nothing is left to send.
+func filterLiveEvent(e *liveEvent, visible func(qname string) bool) (*liveEvent, error) {
+	switch e.name {
+	case "active_tasks":
+		if !visible(e.queue) {
+			return nil, nil
+		}
+		return e, nil
+	case "queues":
+		var v struct {
+			Queues []json.RawMessage `json:"queues"`
+		}
+		if err := json.Unmarshal(e.data, &v); err != nil {
+			return nil, err
+		}
+		queues := make([]json.RawMessage, 0, len(v.Queues))
+		for _, q := range v.Queues {
+			var s struct {
+				Queue string `json:"queue"`
+			}
+			if err := json.Unmarshal(q, &s); err != nil {
+				return nil, err
+			}
+			if visible(s.Queue) {
+				queues = append(queues, q)
+			}
+		}
+		v.Queues = queues
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return &liveEvent{name: e.name, data: data}, nil
+	case "queue_stats":
+		var v struct {
+			Stats map[string]json.RawMessage `json:"stats"`
+		}
+		if err := json.Unmarshal(e.data, &v); err != nil {
+			return nil, err
+		}
+		for qname := range v.Stats {
+			if !visible(qname) {
+				delete(v.Stats, qname)
+			}
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return &liveEvent{name: e.name, data: data}, nil
+	}
+	return e, nil
+}
+
+// newLiveUpdatesHandlerFunc returns a handler which streams live updates to the client
+// with Server-Sent Events. Each event has one of the following names:
+//   - queues: stats of every queue, in the same format as /api/queues
+//   - queue_stats: daily stats of every queue, in the same format as /api/queue_stats
+//   - active_tasks: changes of active tasks of a queue
+//
+// The current state is sent as soon as the client connects.
+// Queues which the client cannot view are left out of the events.
+func newLiveUpdatesHandlerFunc(live *liveUpdates, qa *queueAuthorizer) http.HandlerFunc {
+	// write writes the event to the client, reporting whether the client is still connected.
+	write := func(w http.ResponseWriter, r *http.Request, e *liveEvent) bool {
+		if qa != nil {
+			filtered, err := filterLiveEvent(e, func(qname string) bool { return qa.canView(r, qname) })
+			if err != nil {
+				log.Printf("error: could not filter %s event for live updates: %v", e.name, err)
+				return true
+			}
+			if filtered == nil {
+				return true
+			}
+			e = filtered
+		}
+		_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.name, e.data)
+		return err == nil
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeErrorResponse(w, r, http.StatusInternalServerError, errCodeInternal, "streaming is not supported by the server")
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// Ask nginx not to buffer the stream.
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		// Ask the client to reconnect shortly if the connection is closed.
+		fmt.Fprintf(w, "retry: %d\n\n", time.Second.Milliseconds())
+		flusher.Flush()
+
+		c := live.subscribe()
+		defer live.unsubscribe(c)
+		keepAlive := time.NewTicker(liveKeepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case e, ok := <-c.events:
+				if !ok {
+					return
+				}
+				if !write(w, r, e) {
+					return
+				}
+				// Send the events which are already queued before flushing.
+				for n := len(c.events); n > 0; n-- {
+					e, ok := <-c.events
+					if !ok {
+						break
+					}
+					write(w, r, e)
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package asynqmon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - maintenanceMode which restricts the API to read-only at runtime
+//   - http.Handler(s) for maintenance mode related endpoints
+// ****************************************************************************
+
+// Redis key of the maintenance mode state (JSON encoded maintenanceRecord).
+// The key does not exist while maintenance mode is disabled.
+const maintenanceKey = "asynqmon:maintenance"
+
+// maintenanceMode holds the runtime maintenance mode state.
+// While enabled, every mutating API request is rejected.
+//
+// The state is stored in redis so that it is shared by every asynqmon instance
+// and kept across restarts. With multiple clusters, one maintenanceMode stored
+// in the redis of the first cluster is shared by all of them.
+type maintenanceMode struct {
+	rc redis.UniversalClient
+
+	mu   sync.Mutex
+	last *maintenanceModeState // last state read from redis
+}
+
+func newMaintenanceMode(rc redis.UniversalClient) *maintenanceMode {
+	return &maintenanceMode{rc: rc, last: &maintenanceModeState{}}
+}
+
+// maintenanceRecord is the maintenance mode state as stored in redis.
+type maintenanceRecord struct {
+	Message string    `json:"message"`
+	Since   time.Time `json:"since"`
+}
+
+type maintenanceModeState struct {
+	Enabled bool `json:"enabled"`
+	// Message to show in the banner.
+	Message string `json:"message"`
+	// Time when maintenance mode was enabled in RFC3339 format.
+	// Empty string if maintenance mode is disabled.
+	Since string `json:"since"`
+}
+
+// record returns the state stored in redis, or nil if maintenance mode is disabled.
+func (m *maintenanceMode) record(ctx context.Context) (*maintenanceRecord, error) {
+	data, err := m.rc.Get(ctx, maintenanceKey).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec maintenanceRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("invalid maintenance mode state in redis: %v", err)
+	}
+	return &rec, nil
+}
+
+// state returns the current state from redis.
+func (m *maintenanceMode) state(ctx context.Context) (*maintenanceModeState, error) {
+	rec, err := m.record(ctx)
+	if err != nil {
+		return nil, err
+	}
+	st := &maintenanceModeState{}
+	if rec != nil {
+		st = &maintenanceModeState{Enabled: true, Message: rec.Message, Since: rec.Since.Format(time.RFC3339)}
+	}
+	m.mu.Lock()
+	m.last = st
+	m.mu.Unlock()
+	return st, nil
+}
+
+// isEnabled reports whether maintenance mode is enabled.
+// If redis cannot be reached (e.g. during a failover), the last known state is used.
+func (m *maintenanceMode) isEnabled(ctx context.Context) bool {
+	st, err := m.state(ctx)
+	if err != nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.last.Enabled
+	}
+	return st.Enabled
+}
+
+// enable enables maintenance mode with the message, keeping the time it was
+// first enabled if it is already enabled.
+func (m *maintenanceMode) enable(ctx context.Context, msg string) error {
+	old, err := m.record(ctx)
+	if err != nil {
+		return err
+	}
+	rec := maintenanceRecord{Message: msg, Since: time.Now().UTC()}
+	if old != nil {
+		rec.Since = old.Since
+	}
+	data, err := json.Marshal(&rec)
+	if err != nil {
+		return err
+	}
+	return m.rc.Set(ctx, maintenanceKey, data, 0).Err()
+}
+
+func (m *maintenanceMode) disable(ctx context.Context) error {
+	return m.rc.Del(ctx, maintenanceKey).Err()
+}
+
+// Name of the routes which are allowed while maintenance mode is enabled.
+const maintenanceRouteName = "maintenance"
+
+// middleware rejects mutating requests while maintenance mode is enabled.
+// Requests to the maintenance endpoints themselves are always allowed so that
+// maintenance mode can be turned off.
+func (m *maintenanceMode) middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() == maintenanceRouteName {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if r.Method != "GET" && r.Method != "" && m.isEnabled(r.Context()) {
+			writeErrorResponse(w, r, http.StatusServiceUnavailable, errCodeMaintenanceMode, fmt.Sprintf("API Server is in maintenance mode: %s request is not allowed", r.Method))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func newGetMaintenanceModeHandlerFunc(m *maintenanceMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		st, err := m.state(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, st)
+	}
+}
+
+type enableMaintenanceModeRequest struct {
+	Message string `json:"message"`
+}
+
+func newEnableMaintenanceModeHandlerFunc(m *maintenanceMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req enableMaintenanceModeRequest
+		// Request body is optional.
+		if r.ContentLength != 0 {
+			if err := decodeRequestBody(w, r, &req); err != nil {
+				writeBadRequestError(w, r, err.Error())
+				return
+			}
+		}
+		if err := m.enable(r.Context(), req.Message); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		st, err := m.state(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, st)
+	}
+}
+
+func newDisableMaintenanceModeHandlerFunc(m *maintenanceMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := m.disable(r.Context()); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, &maintenanceModeState{})
+	}
+}
@@ -0,0 +1,89 @@
+package asynqmon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMaintenanceModeSharedByInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	newInstance := func() *maintenanceMode {
+		rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { rc.Close() })
+		return newMaintenanceMode(rc)
+	}
+	a, b := newInstance(), newInstance()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	status := func(m *maintenanceMode, method string) int {
+		w := httptest.NewRecorder()
+		m.middleware(ok).ServeHTTP(w, httptest.NewRequest(method, "/api/queues/default:pause", nil))
+		return w.Code
+	}
+
+	w := httptest.NewRecorder()
+	newEnableMaintenanceModeHandlerFunc(a)(w, httptest.NewRequest("POST", "/api/maintenance:enable", strings.NewReader(`{"message":"failover"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("enable responded with %d: %s", w.Code, w.Body)
+	}
+	// Another instance, or the same instance after a restart, sees the state.
+	for _, m := range []*maintenanceMode{a, b, newInstance()} {
+		if got := status(m, "POST"); got != http.StatusServiceUnavailable {
+			t.Errorf("POST while maintenance mode is enabled responded with %d, want %d", got, http.StatusServiceUnavailable)
+		}
+		if got := status(m, "GET"); got != http.StatusOK {
+			t.Errorf("GET while maintenance mode is enabled responded with %d, want %d", got, http.StatusOK)
+		}
+	}
+	w = httptest.NewRecorder()
+	newGetMaintenanceModeHandlerFunc(b)(w, httptest.NewRequest("GET", "/api/maintenance", nil))
+	var st maintenanceModeState
+	if err := json.NewDecoder(w.Body).Decode(&st); err != nil {
+		t.Fatal(err)
+	}
+	if !st.Enabled || st.Message != "failover" || st.Since == "" {
+		t.Errorf("GET /api/maintenance returned %+v, want enabled with message %q", st, "failover")
+	}
+
+	// The last known state is used while redis is unavailable.
+	mr.SetError("LOADING")
+	if got := status(b, "POST"); got != http.StatusServiceUnavailable {
+		t.Errorf("POST while redis is unavailable responded with %d, want %d", got, http.StatusServiceUnavailable)
+	}
+	mr.SetError("")
+
+	newDisableMaintenanceModeHandlerFunc(b)(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/maintenance:disable", nil))
+	for _, m := range []*maintenanceMode{a, b} {
+		if got := status(m, "POST"); got != http.StatusOK {
+			t.Errorf("POST after maintenance mode is disabled responded with %d, want %d", got, http.StatusOK)
+		}
+	}
+}
+
+func TestMaintenanceModeSharedByClusters(t *testing.T) {
+	us, eu := miniredis.RunT(t), miniredis.RunT(t)
+	h := New(Options{Clusters: []Cluster{
+		{Name: "prod-us", RedisConnOpt: asynq.RedisClientOpt{Addr: us.Addr()}},
+		{Name: "prod-eu", RedisConnOpt: asynq.RedisClientOpt{Addr: eu.Addr()}},
+	}})
+	defer h.Close()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/clusters/prod-eu/api/maintenance:enable", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("enable responded with %d: %s", w.Code, w.Body)
+	}
+	for _, path := range []string{"/clusters/prod-us/api/queues/default:pause", "/clusters/prod-eu/api/queues/default:pause"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", path, nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("POST %s responded with %d, want %d", path, w.Code, http.StatusServiceUnavailable)
+		}
+	}
+}
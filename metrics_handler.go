@@ -1,13 +1,16 @@
 package asynqmon
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,6 +24,22 @@ type getMetricsResponse struct {
 	PendingTasksByQueue  *json.RawMessage `json:"pending_tasks_by_queue"`
 	RetryTasksByQueue    *json.RawMessage `json:"retry_tasks_by_queue"`
 	ArchivedTasksByQueue *json.RawMessage `json:"archived_tasks_by_queue"`
+
+	// Stale indicates that prometheus was unreachable and some of the
+	// metrics are served from the cache.
+	Stale bool `json:"stale"`
+	// FetchedAt is the time when the oldest metrics in the response were fetched
+	// from prometheus. This field is omitted if none of the metrics are stale.
+	FetchedAt *time.Time `json:"fetched_at,omitempty"`
+}
+
+// metricsLimits bounds the queries sent to prometheus.
+type metricsLimits struct {
+	// Maximum duration of a range query. Zero means no limit.
+	maxRange time.Duration
+
+	// Minimum resolution step of a range query. Zero means no limit.
+	minStep time.Duration
 }
 
 type metricsFetchOptions struct {
@@ -33,9 +52,12 @@ type metricsFetchOptions struct {
 	// Optional filter to speicify a list of queues to get metrics for.
 	// Empty list indicates no filter (i.e. get metrics for all queues).
 	queues []string
+
+	// Minimum step to use for the query.
+	minStep time.Duration
 }
 
-func newGetMetricsHandlerFunc(client *http.Client, prometheusAddr string) http.HandlerFunc {
+func newGetMetricsHandlerFunc(client *http.Client, prometheusAddr string, limits metricsLimits, cache *metricsCache, qa *queueAuthorizer) http.HandlerFunc {
 	// res is the result of calling a JSON API endpoint.
 	type res struct {
 		query string
@@ -63,9 +85,14 @@ func newGetMetricsHandlerFunc(client *http.Client, prometheusAddr string) http.H
 	return func(w http.ResponseWriter, r *http.Request) {
 		opts, err := extractMetricsFetchOptions(r)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("invalid query parameter: %v", err), http.StatusBadRequest)
+			writeBadRequestError(w, r, fmt.Sprintf("invalid query parameter: %v", err))
+			return
+		}
+		if limits.maxRange > 0 && opts.duration > limits.maxRange {
+			writeBadRequestError(w, r, fmt.Sprintf("invalid query parameter: duration must not exceed %v", limits.maxRange))
 			return
 		}
+		opts.minStep = limits.minStep
 		// List of queries (i.e. promQL) to send to prometheus server.
 		queries := []string{
 			promQLQueueSize,
@@ -85,35 +112,50 @@ func newGetMetricsHandlerFunc(client *http.Client, prometheusAddr string) http.H
 		for _, q := range queries {
 			go func(q string) {
 				url := buildPrometheusURL(prometheusAddr, q, opts)
-				msg, err := fetchPrometheusMetrics(client, url)
+				msg, err := fetchPrometheusMetrics(r.Context(), client, url)
 				ch <- res{q, msg, err}
 			}(q)
 		}
-		for r := range ch {
+		for result := range ch {
 			n--
-			if r.err != nil {
-				http.Error(w, fmt.Sprintf("failed to fetch %q: %v", r.query, r.err), http.StatusInternalServerError)
-				return
+			key := metricsCacheKey(result.query, opts)
+			if result.err != nil {
+				// Fall back to the last successful response if there's one.
+				cached, ok := cache.get(key)
+				if !ok {
+					writeErrorResponse(w, r, http.StatusBadGateway, errCodePrometheusUnavailable, fmt.Sprintf("failed to fetch %q: %v", result.query, result.err))
+					return
+				}
+				result.msg = cached.msg
+				resp.Stale = true
+				if resp.FetchedAt == nil || cached.fetchedAt.Before(*resp.FetchedAt) {
+					resp.FetchedAt = &cached.fetchedAt
+				}
+			} else {
+				cache.set(key, result.msg)
 			}
-			switch r.query {
+			if qa != nil {
+				result.msg = filterPrometheusSeries(result.msg, func(qname string) bool { return qa.canView(r, qname) })
+			}
+			switch result.query {
 			case promQLQueueSize:
-				resp.QueueSize = r.msg
+				resp.QueueSize = result.msg
 			case promQLQueueLatency:
-				resp.QueueLatency = r.msg
+				resp.QueueLatency = result.msg
 			case promQLMemUsage:
-				resp.QueueMemUsgApprox = r.msg
+				resp.QueueMemUsgApprox = result.msg
 			case promQLProcessedTasks:
-				resp.ProcessedPerSecond = r.msg
+				resp.ProcessedPerSecond = result.msg
 			case promQLFailedTasks:
-				resp.FailedPerSecond = r.msg
+				resp.FailedPerSecond = result.msg
 			case promQLErrorRate:
-				resp.ErrorRate = r.msg
+				resp.ErrorRate = result.msg
 			case promQLPendingTasks:
-				resp.PendingTasksByQueue = r.msg
+				resp.PendingTasksByQueue = result.msg
 			case promQLRetryTasks:
-				resp.RetryTasksByQueue = r.msg
+				resp.RetryTasksByQueue = result.msg
 			case promQLArchivedTasks:
-				resp.ArchivedTasksByQueue = r.msg
+				resp.ArchivedTasksByQueue = result.msg
 			}
 			if n == 0 {
 				break // fetched all metrics
@@ -121,18 +163,64 @@ func newGetMetricsHandlerFunc(client *http.Client, prometheusAddr string) http.H
 		}
 		bytes, err := json.Marshal(resp)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("failed to marshal response into JSON: %v", err), http.StatusInternalServerError)
+			writeErrorResponse(w, r, http.StatusInternalServerError, errCodeInternal, fmt.Sprintf("failed to marshal response into JSON: %v", err))
 			return
 		}
 		if _, err := w.Write(bytes); err != nil {
-			http.Error(w, fmt.Sprintf("failed to write to response: %v", err), http.StatusInternalServerError)
+			writeErrorResponse(w, r, http.StatusInternalServerError, errCodeInternal, fmt.Sprintf("failed to write to response: %v", err))
 			return
 		}
 	}
 }
 
+// filterPrometheusSeries removes the series of queues for which visible returns false from
+// the response of a prometheus query. Series without the queue label are kept.
+// The response is returned as is if it is not a successful query result.
+func filterPrometheusSeries(msg *json.RawMessage, visible func(qname string) bool) *json.RawMessage {
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(*msg, &resp); err != nil {
+		return msg
+	}
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(resp["data"], &data); err != nil {
+		return msg
+	}
+	var result []json.RawMessage
+	if err := json.Unmarshal(data["result"], &result); err != nil {
+		return msg
+	}
+	series := make([]json.RawMessage, 0, len(result))
+	for _, s := range result {
+		var v struct {
+			Metric map[string]string `json:"metric"`
+		}
+		if err := json.Unmarshal(s, &v); err != nil {
+			return msg
+		}
+		if qname, ok := v.Metric["queue"]; !ok || visible(qname) {
+			series = append(series, s)
+		}
+	}
+	var err error
+	if data["result"], err = json.Marshal(series); err != nil {
+		return msg
+	}
+	if resp["data"], err = json.Marshal(data); err != nil {
+		return msg
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return msg
+	}
+	filtered := json.RawMessage(b)
+	return &filtered
+}
+
 const prometheusAPIPath = "/api/v1/query_range"
 
+// Timeout for each request sent to prometheus.
+const defaultPrometheusTimeout = 10 * time.Second
+
 func extractMetricsFetchOptions(r *http.Request) (*metricsFetchOptions, error) {
 	opts := &metricsFetchOptions{
 		duration: 60 * time.Minute,
@@ -141,20 +229,25 @@ func extractMetricsFetchOptions(r *http.Request) (*metricsFetchOptions, error) {
 	q := r.URL.Query()
 	if d := q.Get("duration"); d != "" {
 		val, err := strconv.Atoi(d)
-		if err != nil {
+		if err != nil || val <= 0 {
 			return nil, fmt.Errorf("invalid value provided for duration: %q", d)
 		}
 		opts.duration = time.Duration(val) * time.Second
 	}
 	if t := q.Get("endtime"); t != "" {
 		val, err := strconv.Atoi(t)
-		if err != nil {
+		if err != nil || val <= 0 {
 			return nil, fmt.Errorf("invalid value provided for end_time: %q", t)
 		}
 		opts.endTime = time.Unix(int64(val), 0)
 	}
 	if qs := q.Get("queues"); qs != "" {
 		opts.queues = strings.Split(qs, ",")
+		for _, qname := range opts.queues {
+			if err := validateIdentifier("queue name", qname); err != nil {
+				return nil, fmt.Errorf("invalid value provided for queues: %v", err)
+			}
+		}
 	}
 	return opts, nil
 }
@@ -173,6 +266,8 @@ func buildPrometheusURL(baseAddr, promQL string, opts *metricsFetchOptions) stri
 	return b.String()
 }
 
+var promQLStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
 func applyQueueFilter(promQL string, qnames []string) string {
 	if len(qnames) == 0 {
 		return strings.ReplaceAll(promQL, "QUEUE_FILTER", "")
@@ -183,18 +278,28 @@ func applyQueueFilter(promQL string, qnames []string) string {
 		if i != 0 {
 			b.WriteString("|")
 		}
-		b.WriteString(q)
+		// Match the queue name literally, and escape it for the PromQL string.
+		b.WriteString(promQLStringEscaper.Replace(regexp.QuoteMeta(q)))
 	}
 	b.WriteByte('"')
 	return strings.ReplaceAll(promQL, "QUEUE_FILTER", b.String())
 }
 
-func fetchPrometheusMetrics(client *http.Client, url string) (*json.RawMessage, error) {
-	resp, err := client.Get(url)
+func fetchPrometheusMetrics(ctx context.Context, client *http.Client, url string) (*json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	// Prometheus responds with 4xx for invalid queries, which is passed to the client as is.
+	// 5xx indicates that prometheus (or a proxy in front of it) is unavailable.
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("prometheus responded with status %d", resp.StatusCode)
+	}
 	bytes, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -206,6 +311,13 @@ func fetchPrometheusMetrics(client *http.Client, url string) (*json.RawMessage,
 // Returns step to use given the fetch options.
 // In general, the longer the duration, longer the each step.
 func step(opts *metricsFetchOptions) time.Duration {
+	if d := defaultStep(opts); d > opts.minStep {
+		return d
+	}
+	return opts.minStep
+}
+
+func defaultStep(opts *metricsFetchOptions) time.Duration {
 	if opts.duration <= 6*time.Hour {
 		// maximum number of data points to return: 6h / 10s = 2160
 		return 10 * time.Second
@@ -228,3 +340,48 @@ func step(opts *metricsFetchOptions) time.Duration {
 func unixTimeString(t time.Time) string {
 	return strconv.Itoa(int(t.Unix()))
 }
+
+// Maximum number of responses kept in metricsCache.
+const maxMetricsCacheEntries = 512
+
+// metricsCache holds the last successful responses from prometheus
+// so that they can be served while prometheus is unreachable.
+type metricsCache struct {
+	mu      sync.Mutex
+	entries map[string]*metricsCacheEntry
+}
+
+type metricsCacheEntry struct {
+	msg       *json.RawMessage
+	fetchedAt time.Time
+}
+
+func newMetricsCache() *metricsCache {
+	return &metricsCache{entries: make(map[string]*metricsCacheEntry)}
+}
+
+func (c *metricsCache) get(key string) (*metricsCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	return e, ok
+}
+
+func (c *metricsCache) set(key string, msg *json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxMetricsCacheEntries {
+		// Evict an arbitrary entry to bound the memory usage.
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = &metricsCacheEntry{msg: msg, fetchedAt: time.Now()}
+}
+
+// metricsCacheKey returns the cache key for the given query.
+// End time is not part of the key since it changes on every poll.
+func metricsCacheKey(promQL string, opts *metricsFetchOptions) string {
+	return fmt.Sprintf("%s|%d|%s", promQL, int(opts.duration.Seconds()), strings.Join(opts.queues, ","))
+}
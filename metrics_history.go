@@ -0,0 +1,372 @@
+package asynqmon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - metricsHistory which records queue stats in redis at a fixed interval
+//   - http.Handler(s) for metrics history related endpoints
+// ****************************************************************************
+
+// Redis keys used by metricsHistory. They share a hash tag so that they are
+// on the same node on redis cluster.
+const (
+	// Set of names of the queues with recorded samples.
+	metricsHistoryQueuesKey = "asynqmon:{metrics_history}:queues"
+	// Prefix of the keys which ensure that each sample is recorded by one instance.
+	metricsHistoryLockKeyPrefix = "asynqmon:{metrics_history}:lock:"
+)
+
+// metricsHistoryQueueKey returns the key of the sorted set of JSON encoded
+// historySample of the queue scored by time in unix milliseconds.
+func metricsHistoryQueueKey(qname string) string {
+	return "asynqmon:{metrics_history}:queue:" + qname
+}
+
+const (
+	// Default duration recorded samples are kept.
+	defaultMetricsHistoryRetention = 24 * time.Hour
+
+	// Minimum interval between samples.
+	minMetricsHistoryInterval = time.Second
+)
+
+// historySample is a point-in-time snapshot of a queue's stats.
+type historySample struct {
+	Time            time.Time `json:"timestamp"`
+	Size            int       `json:"size"`
+	Pending         int       `json:"pending"`
+	Active          int       `json:"active"`
+	Scheduled       int       `json:"scheduled"`
+	Retry           int       `json:"retry"`
+	Archived        int       `json:"archived"`
+	Completed       int       `json:"completed"`
+	ProcessedTotal  int       `json:"processed_total"`
+	FailedTotal     int       `json:"failed_total"`
+	LatencyMillisec int64     `json:"latency_msec"`
+	MemoryUsage     int64     `json:"memory_usage_bytes"`
+}
+
+// metricsHistory periodically records the stats of every queue in redis so that
+// they can be charted without Prometheus. The history survives restarts and is shared
+// by every asynqmon instance; each sample is recorded by one of the instances.
+type metricsHistory struct {
+	rc        redis.UniversalClient
+	inspector *asynq.Inspector
+	interval  time.Duration
+	retention time.Duration
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newMetricsHistory(rc redis.UniversalClient, inspector *asynq.Inspector, interval, retention time.Duration) *metricsHistory {
+	return &metricsHistory{
+		rc:        rc,
+		inspector: inspector,
+		interval:  interval,
+		retention: retention,
+		done:      make(chan struct{}),
+	}
+}
+
+func (h *metricsHistory) start() {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.done:
+				return
+			case now := <-ticker.C:
+				if err := h.record(now); err != nil {
+					log.Printf("error: could not record queue stats history: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (h *metricsHistory) stop() error {
+	close(h.done)
+	h.wg.Wait()
+	return nil
+}
+
+// record records the stats of every queue unless another instance has recorded them
+// in the same interval.
+func (h *metricsHistory) record(now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.interval)
+	defer cancel()
+	bucket := now.Truncate(h.interval).UnixNano() / int64(h.interval)
+	ok, err := h.rc.SetNX(ctx, metricsHistoryLockKeyPrefix+strconv.FormatInt(bucket, 10), 1, 2*h.interval).Result()
+	if err != nil || !ok {
+		return err
+	}
+	qnames, err := h.inspector.Queues()
+	if err != nil {
+		return err
+	}
+	samples := make([]*historySample, 0, len(qnames))
+	for _, qname := range qnames {
+		info, err := h.inspector.GetQueueInfo(qname)
+		if err != nil {
+			return err
+		}
+		samples = append(samples, &historySample{
+			Time:            now.UTC(),
+			Size:            info.Size,
+			Pending:         info.Pending,
+			Active:          info.Active,
+			Scheduled:       info.Scheduled,
+			Retry:           info.Retry,
+			Archived:        info.Archived,
+			Completed:       info.Completed,
+			ProcessedTotal:  info.ProcessedTotal,
+			FailedTotal:     info.FailedTotal,
+			LatencyMillisec: info.Latency.Milliseconds(),
+			MemoryUsage:     info.MemoryUsage,
+		})
+	}
+	cutoff := strconv.FormatInt(unixMilli(now.Add(-h.retention)), 10)
+	_, err = h.rc.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, s := range samples {
+			data, err := json.Marshal(s)
+			if err != nil {
+				return err
+			}
+			key := metricsHistoryQueueKey(qnames[i])
+			pipe.ZAdd(ctx, key, redis.Z{Score: float64(unixMilli(now)), Member: data})
+			pipe.ZRemRangeByScore(ctx, key, "-inf", cutoff)
+			pipe.Expire(ctx, key, h.retention)
+			pipe.SAdd(ctx, metricsHistoryQueuesKey, qnames[i])
+		}
+		pipe.Expire(ctx, metricsHistoryQueuesKey, h.retention)
+		return nil
+	})
+	return err
+}
+
+// list returns the samples of the given queues, or every queue with recorded samples
+// if qnames is empty, between start and end in chronological order.
+func (h *metricsHistory) list(ctx context.Context, qnames []string, start, end time.Time) (map[string][]*historySample, error) {
+	if len(qnames) == 0 {
+		var err error
+		if qnames, err = h.rc.SMembers(ctx, metricsHistoryQueuesKey).Result(); err != nil {
+			return nil, err
+		}
+	}
+	cmds := make([]*redis.StringSliceCmd, len(qnames))
+	_, err := h.rc.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, qname := range qnames {
+			cmds[i] = pipe.ZRangeByScore(ctx, metricsHistoryQueueKey(qname), &redis.ZRangeBy{
+				Min: strconv.FormatInt(unixMilli(start), 10),
+				Max: strconv.FormatInt(unixMilli(end), 10),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string][]*historySample, len(qnames))
+	for i, qname := range qnames {
+		samples := make([]*historySample, 0, len(cmds[i].Val()))
+		for _, data := range cmds[i].Val() {
+			var s historySample
+			if err := json.Unmarshal([]byte(data), &s); err != nil {
+				return nil, err
+			}
+			samples = append(samples, &s)
+		}
+		if len(samples) > 0 {
+			res[qname] = samples
+		}
+	}
+	return res, nil
+}
+
+// downsample returns the last sample in each step, so that charts of long
+// time ranges have a bounded number of points.
+func downsample(samples []*historySample, step time.Duration) []*historySample {
+	var out []*historySample
+	for _, s := range samples {
+		if n := len(out); n > 0 && out[n-1].Time.Truncate(step).Equal(s.Time.Truncate(step)) {
+			out[n-1] = s
+		} else {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Prometheus range query response, so that the Web UI charts the history
+// the same way as metrics queried from Prometheus.
+type promMatrixResponse struct {
+	Status string         `json:"status"`
+	Data   promMatrixData `json:"data"`
+}
+
+type promMatrixData struct {
+	ResultType string        `json:"resultType"`
+	Result     []*promSeries `json:"result"`
+}
+
+type promSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values [][2]interface{}  `json:"values"` // [unix time in seconds, value as string]
+}
+
+// historySeries builds the series of a metric of each queue. value returns the value
+// of the metric at sample i of the queue, or false if the value is not available.
+func historySeries(history map[string][]*historySample, labels map[string]string, value func(samples []*historySample, i int) (float64, bool)) *json.RawMessage {
+	resp := promMatrixResponse{Status: "success", Data: promMatrixData{ResultType: "matrix", Result: make([]*promSeries, 0, len(history))}}
+	for qname, samples := range history {
+		series := &promSeries{Metric: map[string]string{"queue": qname}}
+		for k, v := range labels {
+			series.Metric[k] = v
+		}
+		for i, s := range samples {
+			if v, ok := value(samples, i); ok {
+				t := float64(s.Time.UnixNano()) / float64(time.Second)
+				series.Values = append(series.Values, [2]interface{}{t, strconv.FormatFloat(v, 'f', -1, 64)})
+			}
+		}
+		if len(series.Values) > 0 {
+			resp.Data.Result = append(resp.Data.Result, series)
+		}
+	}
+	data, _ := json.Marshal(resp) // cannot fail
+	msg := json.RawMessage(data)
+	return &msg
+}
+
+// gauge returns a value function for the metric returned by f.
+func gauge(f func(s *historySample) float64) func([]*historySample, int) (float64, bool) {
+	return func(samples []*historySample, i int) (float64, bool) {
+		return f(samples[i]), true
+	}
+}
+
+// perSecond returns a value function for the rate of increase of the counter returned by f
+// since the previous sample.
+func perSecond(f func(s *historySample) int) func([]*historySample, int) (float64, bool) {
+	return func(samples []*historySample, i int) (float64, bool) {
+		if i == 0 {
+			return 0, false
+		}
+		prev, cur := samples[i-1], samples[i]
+		d, secs := f(cur)-f(prev), cur.Time.Sub(prev.Time).Seconds()
+		if d < 0 || secs <= 0 {
+			return 0, false // stats were reset
+		}
+		return float64(d) / secs, true
+	}
+}
+
+// historyErrorRate is the value function for the ratio of failed tasks to processed tasks
+// since the previous sample.
+func historyErrorRate(samples []*historySample, i int) (float64, bool) {
+	if i == 0 {
+		return 0, false
+	}
+	prev, cur := samples[i-1], samples[i]
+	processed, failed := cur.ProcessedTotal-prev.ProcessedTotal, cur.FailedTotal-prev.FailedTotal
+	if processed <= 0 || failed < 0 {
+		return 0, false
+	}
+	return float64(failed) / float64(processed), true
+}
+
+// newGetMetricsHistoryHandlerFunc returns a handler which responds with the recorded
+// queue stats in the same format as the metrics endpoint, which queries Prometheus.
+// It takes the same query parameters: duration (seconds), endtime (unix time), and queues.
+func newGetMetricsHistoryHandlerFunc(h *metricsHistory, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts, err := extractMetricsFetchOptions(r)
+		if err != nil {
+			writeBadRequestError(w, r, fmt.Sprintf("invalid query parameter: %v", err))
+			return
+		}
+		opts.minStep = h.interval
+		history, err := h.list(r.Context(), opts.queues, opts.endTime.Add(-opts.duration), opts.endTime)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		step := step(opts)
+		for qname, samples := range history {
+			if !qa.canView(r, qname) {
+				delete(history, qname)
+				continue
+			}
+			history[qname] = downsample(samples, step)
+		}
+		writeResponseJSON(w, getMetricsResponse{
+			QueueSize:            historySeries(history, nil, gauge(func(s *historySample) float64 { return float64(s.Size) })),
+			QueueLatency:         historySeries(history, nil, gauge(func(s *historySample) float64 { return float64(s.LatencyMillisec) / 1000 })),
+			QueueMemUsgApprox:    historySeries(history, nil, gauge(func(s *historySample) float64 { return float64(s.MemoryUsage) })),
+			ProcessedPerSecond:   historySeries(history, nil, perSecond(func(s *historySample) int { return s.ProcessedTotal })),
+			FailedPerSecond:      historySeries(history, nil, perSecond(func(s *historySample) int { return s.FailedTotal })),
+			ErrorRate:            historySeries(history, nil, historyErrorRate),
+			PendingTasksByQueue:  historySeries(history, map[string]string{"state": "pending"}, gauge(func(s *historySample) float64 { return float64(s.Pending) })),
+			RetryTasksByQueue:    historySeries(history, map[string]string{"state": "retry"}, gauge(func(s *historySample) float64 { return float64(s.Retry) })),
+			ArchivedTasksByQueue: historySeries(history, map[string]string{"state": "archived"}, gauge(func(s *historySample) float64 { return float64(s.Archived) })),
+		})
+	}
+}
+
+type listQueueHistoryResponse struct {
+	IntervalSeconds int64            `json:"interval_seconds"`
+	Samples         []*historySample `json:"samples"`
+}
+
+// newListQueueHistoryHandlerFunc returns a handler which lists the recorded stats of a queue
+// in chronological order. The optional start and end parameters (RFC3339) bound the time
+// of the samples; by default, every sample kept is returned.
+func newListQueueHistoryHandlerFunc(h *metricsHistory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qname := mux.Vars(r)["qname"]
+		q := r.URL.Query()
+		now := time.Now()
+		start, end := now.Add(-h.retention), now
+		for _, p := range []struct {
+			name string
+			t    *time.Time
+		}{{"start", &start}, {"end", &end}} {
+			if v := q.Get(p.name); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					writeBadRequestError(w, r, fmt.Sprintf("%s must be a time in RFC3339 format", p.name))
+					return
+				}
+				*p.t = t
+			}
+		}
+		history, err := h.list(r.Context(), []string{qname}, start, end)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		samples := history[qname]
+		if samples == nil {
+			samples = make([]*historySample, 0) // avoid null in json output
+		}
+		writeResponseJSON(w, listQueueHistoryResponse{IntervalSeconds: int64(h.interval.Seconds()), Samples: samples})
+	}
+}
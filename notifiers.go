@@ -0,0 +1,361 @@
+package asynqmon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - Notifier interface and its implementations
+//   - http.Handler(s) for notifier related endpoints
+// ****************************************************************************
+
+// Notification is a message sent through a Notifier.
+type Notification struct {
+	// Title is a short summary of the notification.
+	Title string
+
+	// Message is the body of the notification.
+	Message string
+
+	// Severity is one of "info", "warning", or "critical".
+	Severity string
+
+	// Time when the notification was created.
+	Time time.Time
+
+	// Key identifies the alert the notification is about. The notifications sent when
+	// an alert fires and when it resolves have the same key.
+	//
+	// Empty if the notification is not about an alert.
+	Key string
+
+	// Resolved is true if the notification reports that the alert with Key has resolved.
+	Resolved bool
+}
+
+// Severity levels used for Notification.Severity.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Notifier sends notifications to an external channel (e.g. Slack, email).
+type Notifier interface {
+	// Name returns the name used to identify the notifier in the UI.
+	Name() string
+
+	// Notify sends the given notification.
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// Timeout used by the built-in notifiers if the context has no deadline.
+const defaultNotifyTimeout = 10 * time.Second
+
+// postJSON sends data as a JSON body to the given url and returns an error if
+// the response status is not 2xx.
+func postJSON(ctx context.Context, client *http.Client, url string, data interface{}, header http.Header) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = &http.Client{Timeout: defaultNotifyTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected response status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// WebhookNotifier sends notifications as JSON to an HTTP endpoint.
+//
+// The request body has the following shape:
+//
+//	{"title": "...", "message": "...", "severity": "...", "time": "2006-01-02T15:04:05Z"}
+type WebhookNotifier struct {
+	// URL to send POST requests to.
+	URL string
+
+	// Header specifies additional headers to send (e.g. Authorization).
+	//
+	// This field is optional.
+	Header http.Header
+
+	// Client used to send the requests.
+	//
+	// This field is optional.
+	Client *http.Client
+}
+
+func (n *WebhookNotifier) Name() string { return "webhook" }
+
+func (n *WebhookNotifier) Notify(ctx context.Context, notif *Notification) error {
+	data := map[string]interface{}{
+		"title":    notif.Title,
+		"message":  notif.Message,
+		"severity": notif.Severity,
+		"time":     notif.Time.Format(time.RFC3339),
+	}
+	return postJSON(ctx, n.Client, n.URL, data, n.Header)
+}
+
+// SlackNotifier sends notifications to Slack using an incoming webhook.
+type SlackNotifier struct {
+	// WebhookURL is the URL of the Slack incoming webhook.
+	WebhookURL string
+
+	// Channel overrides the default channel of the webhook.
+	//
+	// This field is optional.
+	Channel string
+
+	// Client used to send the requests.
+	//
+	// This field is optional.
+	Client *http.Client
+}
+
+func (n *SlackNotifier) Name() string { return "slack" }
+
+func (n *SlackNotifier) Notify(ctx context.Context, notif *Notification) error {
+	emoji := ":information_source:"
+	switch notif.Severity {
+	case SeverityWarning:
+		emoji = ":warning:"
+	case SeverityCritical:
+		emoji = ":rotating_light:"
+	}
+	data := map[string]interface{}{
+		"text": fmt.Sprintf("%s *%s*\n%s", emoji, notif.Title, notif.Message),
+	}
+	if n.Channel != "" {
+		data["channel"] = n.Channel
+	}
+	return postJSON(ctx, n.Client, n.WebhookURL, data, nil)
+}
+
+// PagerDutyNotifier triggers PagerDuty incidents using the Events API v2.
+// The incident of an alert is resolved when the alert resolves.
+type PagerDutyNotifier struct {
+	// RoutingKey is the integration key of the PagerDuty service.
+	RoutingKey string
+
+	// EventsURL is the URL of the Events API.
+	//
+	// This field is optional. Default is "https://events.pagerduty.com/v2/enqueue".
+	EventsURL string
+
+	// Client used to send the requests.
+	//
+	// This field is optional.
+	Client *http.Client
+}
+
+func (n *PagerDutyNotifier) Name() string { return "pagerduty" }
+
+func (n *PagerDutyNotifier) Notify(ctx context.Context, notif *Notification) error {
+	url := n.EventsURL
+	if url == "" {
+		url = "https://events.pagerduty.com/v2/enqueue"
+	}
+	// PagerDuty accepts "critical", "error", "warning", or "info".
+	severity := notif.Severity
+	switch severity {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		severity = "error"
+	}
+	data := map[string]interface{}{
+		"routing_key":  n.RoutingKey,
+		"event_action": "trigger",
+		"payload": map[string]interface{}{
+			"summary":        notif.Title,
+			"source":         "asynqmon",
+			"severity":       severity,
+			"timestamp":      notif.Time.Format(time.RFC3339),
+			"custom_details": map[string]string{"message": notif.Message},
+		},
+	}
+	if notif.Key != "" {
+		data["dedup_key"] = notif.Key
+		if notif.Resolved {
+			data["event_action"] = "resolve"
+		}
+	}
+	return postJSON(ctx, n.Client, url, data, nil)
+}
+
+// notifyTest triggers the test notification and resolves it right away,
+// so that the test does not leave an open incident paging whoever is on call.
+func (n *PagerDutyNotifier) notifyTest(ctx context.Context, notif *Notification) error {
+	trigger := *notif
+	trigger.Key = fmt.Sprintf("asynqmon/test/%d", notif.Time.UnixNano())
+	if err := n.Notify(ctx, &trigger); err != nil {
+		return err
+	}
+	resolve := trigger
+	resolve.Resolved = true
+	return n.Notify(ctx, &resolve)
+}
+
+// EmailNotifier sends notifications by email using SMTP.
+type EmailNotifier struct {
+	// Addr is the address of the SMTP server in "host:port" format.
+	Addr string
+
+	// Username and Password are used for PLAIN authentication.
+	// Authentication is skipped if Username is empty.
+	Username string
+	Password string
+
+	// From is the sender address.
+	From string
+
+	// To is the list of recipient addresses.
+	To []string
+}
+
+func (n *EmailNotifier) Name() string { return "email" }
+
+func (n *EmailNotifier) Notify(ctx context.Context, notif *Notification) error {
+	var auth smtp.Auth
+	if n.Username != "" {
+		host := n.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", n.Username, n.Password, host)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", encodeSubject(fmt.Sprintf("[asynqmon][%s] %s", notif.Severity, notif.Title)))
+	fmt.Fprintf(&b, "Date: %s\r\n", notif.Time.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(notif.Message)
+	b.WriteString("\r\n")
+
+	// smtp.SendMail does not accept a context, so run it in a goroutine
+	// to honor the deadline.
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(n.Addr, auth, n.From, n.To, []byte(b.String()))
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// encodeSubject returns s as the value of the Subject header.
+// Line breaks are removed so that s cannot add headers, and non-ASCII
+// characters are encoded as specified by RFC 2047.
+func encodeSubject(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' {
+			return ' '
+		}
+		return r
+	}, s)
+	return mime.QEncoding.Encode("utf-8", s)
+}
+
+// testNotifier is implemented by notifiers which send test notifications
+// differently from other notifications.
+type testNotifier interface {
+	notifyTest(ctx context.Context, n *Notification) error
+}
+
+type notifierInfo struct {
+	Name string `json:"name"`
+}
+
+type listNotifiersResponse struct {
+	Notifiers []*notifierInfo `json:"notifiers"`
+}
+
+func newListNotifiersHandlerFunc(notifiers []Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := listNotifiersResponse{Notifiers: make([]*notifierInfo, len(notifiers))}
+		for i, n := range notifiers {
+			resp.Notifiers[i] = &notifierInfo{Name: n.Name()}
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+type notifyResult struct {
+	Name string `json:"name"`
+	OK   bool   `json:"ok"`
+	// Error message if the notification could not be delivered.
+	Error string `json:"error,omitempty"`
+}
+
+type testNotifiersResponse struct {
+	Results []*notifyResult `json:"results"`
+}
+
+// newTestNotifiersHandlerFunc returns a handler which sends a test notification
+// through each configured notifier and reports the delivery result of each.
+func newTestNotifiersHandlerFunc(notifiers []Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		notif := &Notification{
+			Title:    "Test notification from asynqmon",
+			Message:  "This is a test message to verify that notifications from asynqmon can be delivered.",
+			Severity: SeverityInfo,
+			Time:     time.Now(),
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), defaultNotifyTimeout)
+		defer cancel()
+		resp := testNotifiersResponse{Results: make([]*notifyResult, len(notifiers))}
+		done := make(chan struct{}, len(notifiers))
+		for i, n := range notifiers {
+			go func(i int, n Notifier) {
+				res := &notifyResult{Name: n.Name(), OK: true}
+				notify := n.Notify
+				if tn, ok := n.(testNotifier); ok {
+					notify = tn.notifyTest
+				}
+				if err := notify(ctx, notif); err != nil {
+					res.OK = false
+					res.Error = err.Error()
+				}
+				resp.Results[i] = res
+				done <- struct{}{}
+			}(i, n)
+		}
+		for range notifiers {
+			<-done
+		}
+		writeResponseJSON(w, resp)
+	}
+}
@@ -0,0 +1,141 @@
+package asynqmon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPagerDutyNotifier(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	st := &alertState{
+		rule:    &AlertRule{Name: "backlog", Severity: SeverityCritical},
+		subject: "queue default",
+		message: "Queue default has 2000 pending tasks.",
+		since:   now.Add(-time.Minute),
+		firedAt: now,
+	}
+	tests := []struct {
+		desc  string
+		notif *Notification
+		want  map[string]interface{}
+	}{
+		{
+			desc:  "alert fired",
+			notif: st.notification(now),
+			want: map[string]interface{}{
+				"routing_key":  "key",
+				"event_action": "trigger",
+				"dedup_key":    "asynqmon/backlog/queue default",
+				"payload": map[string]interface{}{
+					"summary":        "[backlog] queue default",
+					"source":         "asynqmon",
+					"severity":       "critical",
+					"timestamp":      "2024-01-01T12:00:00Z",
+					"custom_details": map[string]interface{}{"message": "Queue default has 2000 pending tasks. The condition has held for 1m0s."},
+				},
+			},
+		},
+		{
+			desc:  "alert resolved",
+			notif: st.resolvedNotification(now.Add(time.Hour)),
+			want: map[string]interface{}{
+				"routing_key":  "key",
+				"event_action": "resolve",
+				"dedup_key":    "asynqmon/backlog/queue default",
+				"payload": map[string]interface{}{
+					"summary":        "Resolved: [backlog] queue default",
+					"source":         "asynqmon",
+					"severity":       "info",
+					"timestamp":      "2024-01-01T13:00:00Z",
+					"custom_details": map[string]interface{}{"message": "The alert fired at 2024-01-01T12:00:00Z has resolved."},
+				},
+			},
+		},
+		{
+			desc:  "notification without key",
+			notif: &Notification{Title: "hello", Message: "world", Severity: "unknown", Time: now},
+			want: map[string]interface{}{
+				"routing_key":  "key",
+				"event_action": "trigger",
+				"payload": map[string]interface{}{
+					"summary":        "hello",
+					"source":         "asynqmon",
+					"severity":       "error",
+					"timestamp":      "2024-01-01T12:00:00Z",
+					"custom_details": map[string]interface{}{"message": "world"},
+				},
+			},
+		},
+	}
+	for _, tc := range tests {
+		var got map[string]interface{}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("%s: could not decode request body: %v", tc.desc, err)
+			}
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		n := &PagerDutyNotifier{RoutingKey: "key", EventsURL: srv.URL}
+		if err := n.Notify(context.Background(), tc.notif); err != nil {
+			t.Errorf("%s: Notify returned error: %v", tc.desc, err)
+		}
+		srv.Close()
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%s: Notify sent diff (-want,+got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+func TestTestNotifiersHandler(t *testing.T) {
+	var pagerDutyEvents, webhookEvents []map[string]interface{}
+	record := func(events *[]map[string]interface{}) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var data map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+				t.Errorf("could not decode request body: %v", err)
+			}
+			*events = append(*events, data)
+		}))
+	}
+	pagerDuty := record(&pagerDutyEvents)
+	defer pagerDuty.Close()
+	webhook := record(&webhookEvents)
+	defer webhook.Close()
+
+	h := newTestNotifiersHandlerFunc([]Notifier{
+		&PagerDutyNotifier{RoutingKey: "key", EventsURL: pagerDuty.URL},
+		&WebhookNotifier{URL: webhook.URL},
+	})
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("POST", "/api/notifiers:test", nil))
+
+	var resp testNotifiersResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	want := testNotifiersResponse{Results: []*notifyResult{{Name: "pagerduty", OK: true}, {Name: "webhook", OK: true}}}
+	if diff := cmp.Diff(want, resp); diff != "" {
+		t.Errorf("test notifiers response diff (-want,+got):\n%s", diff)
+	}
+
+	// The PagerDuty incident is resolved right after it is triggered.
+	if len(pagerDutyEvents) != 2 {
+		t.Fatalf("PagerDuty received %d events, want 2", len(pagerDutyEvents))
+	}
+	trigger, resolve := pagerDutyEvents[0], pagerDutyEvents[1]
+	if trigger["event_action"] != "trigger" || resolve["event_action"] != "resolve" {
+		t.Errorf("PagerDuty received event actions %v and %v, want trigger and resolve", trigger["event_action"], resolve["event_action"])
+	}
+	if key := trigger["dedup_key"]; key == nil || key == "" || key != resolve["dedup_key"] {
+		t.Errorf("PagerDuty received dedup keys %v and %v, want the same non-empty key", trigger["dedup_key"], resolve["dedup_key"])
+	}
+	if len(webhookEvents) != 1 {
+		t.Errorf("webhook received %d events, want 1", len(webhookEvents))
+	}
+}
@@ -0,0 +1,503 @@
+package asynqmon
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - OIDCOptions to sign in to the Web UI with an OpenID Connect provider
+//   - oidcProvider which implements the authorization code flow and sessions
+//   - http.Handler(s) for sign in related endpoints
+// ****************************************************************************
+
+// OIDCOptions configures sign in with an OpenID Connect provider (e.g. Google, Okta, Keycloak).
+// Users are redirected to the provider to sign in, and are granted RoleAdmin if their
+// username or one of their roles is listed in Admins, and RoleViewer otherwise.
+type OIDCOptions struct {
+	// IssuerURL is the URL of the provider (e.g. "https://accounts.google.com").
+	// Endpoints of the provider are discovered from "<IssuerURL>/.well-known/openid-configuration".
+	IssuerURL string
+
+	// ClientID and ClientSecret are the credentials of asynqmon registered with the provider.
+	ClientID     string
+	ClientSecret string
+
+	// RedirectURL is the URL the provider redirects users to after they sign in,
+	// which is the external URL of asynqmon followed by "/auth/callback"
+	// (e.g. "https://example.com/monitoring/auth/callback").
+	// It must be registered with the provider.
+	RedirectURL string
+
+	// Scopes are the scopes requested from the provider.
+	//
+	// This field is optional. Default is "openid", "profile", and "email".
+	Scopes []string
+
+	// UsernameClaim is the claim used as the username.
+	// If it is "email", users whose email is not verified by the provider
+	// (i.e. the "email_verified" claim is not true) cannot sign in.
+	//
+	// This field is optional. Default is "email".
+	UsernameClaim string
+
+	// RolesClaim is the claim with the groups or roles of the user.
+	//
+	// This field is optional. Default is "groups".
+	RolesClaim string
+
+	// Admins are the usernames and the values of RolesClaim which are granted RoleAdmin.
+	//
+	// This field is optional. If empty, all users are granted RoleViewer.
+	Admins []string
+
+	// SessionSecret is the key used to sign session cookies.
+	// All instances of asynqmon behind a load balancer must use the same key.
+	//
+	// This field is optional. If empty, a random key is generated and users
+	// need to sign in again when asynqmon restarts.
+	SessionSecret string
+
+	// SessionTTL specifies how long users stay signed in.
+	//
+	// This field is optional. Default is 12 hours.
+	SessionTTL time.Duration
+}
+
+const (
+	defaultOIDCSessionTTL    = 12 * time.Hour
+	defaultOIDCUsernameClaim = "email"
+	defaultOIDCRolesClaim    = "groups"
+
+	// Maximum duration between redirecting users to the provider and the callback.
+	oidcLoginTimeout = 10 * time.Minute
+
+	oidcSessionCookieName = "asynqmon_session"
+	oidcStateCookieName   = "asynqmon_oidc_state"
+
+	// Path of the callback relative to the root path.
+	oidcCallbackPath = "/auth/callback"
+)
+
+// oidcDiscovery holds the endpoints of the provider from its discovery document.
+type oidcDiscovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// oidcProvider signs in users with an OpenID Connect provider and keeps them
+// signed in with session cookies signed with HMAC-SHA256.
+type oidcProvider struct {
+	opts     OIDCOptions
+	admins   map[string]bool
+	secret   []byte
+	rootPath string
+	client   *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery // nil until discovered
+}
+
+func newOIDCProvider(opts OIDCOptions, rootPath string) (*oidcProvider, error) {
+	if opts.IssuerURL == "" || opts.ClientID == "" {
+		return nil, fmt.Errorf("OIDC issuer URL and client ID are required")
+	}
+	u, err := url.Parse(opts.RedirectURL)
+	if err != nil || !u.IsAbs() || !strings.HasSuffix(u.Path, oidcCallbackPath) {
+		return nil, fmt.Errorf("OIDC redirect URL %q must be an absolute URL ending with %q", opts.RedirectURL, oidcCallbackPath)
+	}
+	if len(opts.Scopes) == 0 {
+		opts.Scopes = []string{"openid", "profile", "email"}
+	}
+	if opts.UsernameClaim == "" {
+		opts.UsernameClaim = defaultOIDCUsernameClaim
+	}
+	if opts.RolesClaim == "" {
+		opts.RolesClaim = defaultOIDCRolesClaim
+	}
+	if opts.SessionTTL == 0 {
+		opts.SessionTTL = defaultOIDCSessionTTL
+	}
+	p := &oidcProvider{
+		opts:     opts,
+		admins:   make(map[string]bool, len(opts.Admins)),
+		secret:   []byte(opts.SessionSecret),
+		rootPath: rootPath,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, v := range opts.Admins {
+		p.admins[v] = true
+	}
+	if len(p.secret) == 0 {
+		p.secret = make([]byte, 32)
+		if _, err := rand.Read(p.secret); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// discover returns the endpoints of the provider.
+// The discovery document is fetched on first use so that asynqmon starts even
+// if the provider is unavailable, and fetched again if it fails.
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	var d oidcDiscovery
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(p.opts.IssuerURL, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.doJSON(req, &d); err != nil {
+		return nil, fmt.Errorf("could not discover OIDC provider: %v", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != strings.TrimSuffix(p.opts.IssuerURL, "/") {
+		return nil, fmt.Errorf("could not discover OIDC provider: issuer %q does not match %q", d.Issuer, p.opts.IssuerURL)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.UserinfoEndpoint == "" {
+		return nil, fmt.Errorf("could not discover OIDC provider: authorization, token, and userinfo endpoints are required")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// doJSON sends the request and decodes the JSON response into v.
+func (p *oidcProvider) doJSON(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s responded with status %d: %s", req.Method, req.URL.Redacted(), resp.StatusCode, msg)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// sign returns v encoded as JSON and signed with the secret of the provider.
+func (p *oidcProvider) sign(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(b)
+	return base64.RawURLEncoding.EncodeToString(b) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verify decodes s signed by sign into v.
+func (p *oidcProvider) verify(s string, v interface{}) error {
+	parts := strings.Split(s, ".")
+	if len(parts) != 2 {
+		return errors.New("malformed value")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(b)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return errors.New("invalid signature")
+	}
+	return json.Unmarshal(b, v)
+}
+
+// cookiePath returns the path of the cookies set by the provider.
+func (p *oidcProvider) cookiePath(r *http.Request) string {
+	return externalRootPath(r, p.rootPath) + "/"
+}
+
+func (p *oidcProvider) setCookie(w http.ResponseWriter, r *http.Request, name, value string, ttl time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     p.cookiePath(r),
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(p.opts.RedirectURL, "https:"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (p *oidcProvider) clearCookie(w http.ResponseWriter, r *http.Request, name string) {
+	http.SetCookie(w, &http.Cookie{Name: name, Path: p.cookiePath(r), MaxAge: -1, HttpOnly: true})
+}
+
+type oidcSession struct {
+	Username  string `json:"u"`
+	Role      string `json:"r"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// session returns the user signed in with the session cookie of the request,
+// or nil if the request has no valid session cookie.
+func (p *oidcProvider) session(r *http.Request) *apiTokenInfo {
+	c, err := r.Cookie(oidcSessionCookieName)
+	if err != nil {
+		return nil
+	}
+	var s oidcSession
+	if err := p.verify(c.Value, &s); err != nil || time.Now().Unix() >= s.ExpiresAt {
+		return nil
+	}
+	if _, ok := roleScopes[s.Role]; !ok {
+		return nil
+	}
+	return newUserInfo(s.Username, s.Role)
+}
+
+// loginURL returns the URL to sign in and return to the URL of the request.
+func (p *oidcProvider) loginURL(r *http.Request) string {
+	redirect := externalRootPath(r, "") + r.URL.RequestURI()
+	return externalRootPath(r, p.rootPath) + "/auth/login?redirect=" + url.QueryEscape(redirect)
+}
+
+// role returns the role granted to the user with the given username and userinfo claims.
+func (p *oidcProvider) role(username string, claims map[string]interface{}) string {
+	if p.admins[username] {
+		return RoleAdmin
+	}
+	switch v := claims[p.opts.RolesClaim].(type) {
+	case string:
+		if p.admins[v] {
+			return RoleAdmin
+		}
+	case []interface{}:
+		for _, x := range v {
+			if s, ok := x.(string); ok && p.admins[s] {
+				return RoleAdmin
+			}
+		}
+	}
+	return RoleViewer
+}
+
+// errEmailNotVerified indicates that the email used as the username is not verified by the provider.
+var errEmailNotVerified = errors.New("email is not verified")
+
+// username returns the username of the user with the given userinfo claims.
+// The subject is used if the user has no UsernameClaim.
+func (p *oidcProvider) username(claims map[string]interface{}) (string, error) {
+	if username, _ := claims[p.opts.UsernameClaim].(string); username != "" {
+		// Some providers let users set an email address without verifying it,
+		// which would let anyone sign in with the address of an admin.
+		if p.opts.UsernameClaim == "email" && !isTrueClaim(claims["email_verified"]) {
+			return "", fmt.Errorf("%w: %q", errEmailNotVerified, username)
+		}
+		return username, nil
+	}
+	if sub, _ := claims["sub"].(string); sub != "" {
+		return sub, nil
+	}
+	return "", fmt.Errorf("userinfo has no %q claim", p.opts.UsernameClaim)
+}
+
+// isTrueClaim reports whether the value of a boolean claim is true.
+// Some providers send boolean claims as strings.
+func isTrueClaim(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+type oidcLoginState struct {
+	State     string `json:"s"`
+	Verifier  string `json:"v"`
+	Redirect  string `json:"r"`
+	ExpiresAt int64  `json:"e"`
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newOIDCLoginHandlerFunc returns a handler which redirects users to the provider to sign in.
+// Users are redirected to the path in the redirect parameter once signed in.
+func newOIDCLoginHandlerFunc(p *oidcProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d, err := p.discover(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		redirect := r.URL.Query().Get("redirect")
+		// Only allow paths on this host to prevent open redirects.
+		if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+			redirect = externalRootPath(r, p.rootPath) + "/"
+		}
+		st := oidcLoginState{Redirect: redirect, ExpiresAt: time.Now().Add(oidcLoginTimeout).Unix()}
+		if st.State, err = randomString(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if st.Verifier, err = randomString(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		v, err := p.sign(st)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		p.setCookie(w, r, oidcStateCookieName, v, oidcLoginTimeout)
+
+		challenge := sha256.Sum256([]byte(st.Verifier))
+		q := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {p.opts.ClientID},
+			"redirect_uri":          {p.opts.RedirectURL},
+			"scope":                 {strings.Join(p.opts.Scopes, " ")},
+			"state":                 {st.State},
+			"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+			"code_challenge_method": {"S256"},
+		}
+		sep := "?"
+		if strings.Contains(d.AuthorizationEndpoint, "?") {
+			sep = "&"
+		}
+		http.Redirect(w, r, d.AuthorizationEndpoint+sep+q.Encode(), http.StatusFound)
+	}
+}
+
+// exchange exchanges the authorization code for the claims of the user from the userinfo endpoint.
+func (p *oidcProvider) exchange(ctx context.Context, code, verifier string) (map[string]interface{}, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.opts.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	// Authenticate with client_secret_basic unless the provider only supports client_secret_post.
+	post := len(d.TokenAuthMethods) > 0
+	for _, m := range d.TokenAuthMethods {
+		if m == "client_secret_basic" {
+			post = false
+		}
+	}
+	if post {
+		form.Set("client_id", p.opts.ClientID)
+		form.Set("client_secret", p.opts.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if !post {
+		req.SetBasicAuth(url.QueryEscape(p.opts.ClientID), url.QueryEscape(p.opts.ClientSecret))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := p.doJSON(req, &token); err != nil {
+		return nil, fmt.Errorf("could not exchange authorization code: %v", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("could not exchange authorization code: no access token in response")
+	}
+
+	req, err = http.NewRequestWithContext(ctx, "GET", d.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	var claims map[string]interface{}
+	if err := p.doJSON(req, &claims); err != nil {
+		return nil, fmt.Errorf("could not get userinfo: %v", err)
+	}
+	return claims, nil
+}
+
+// newOIDCCallbackHandlerFunc returns a handler which signs in users redirected back from the provider.
+func newOIDCCallbackHandlerFunc(p *oidcProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			http.Error(w, fmt.Sprintf("sign in failed: %s %s", e, q.Get("error_description")), http.StatusUnauthorized)
+			return
+		}
+		c, err := r.Cookie(oidcStateCookieName)
+		if err != nil {
+			http.Error(w, "sign in failed: no sign in in progress", http.StatusBadRequest)
+			return
+		}
+		p.clearCookie(w, r, oidcStateCookieName)
+		var st oidcLoginState
+		if err := p.verify(c.Value, &st); err != nil || time.Now().Unix() >= st.ExpiresAt ||
+			!hmac.Equal([]byte(st.State), []byte(q.Get("state"))) {
+			http.Error(w, "sign in failed: invalid or expired state", http.StatusBadRequest)
+			return
+		}
+		claims, err := p.exchange(r.Context(), q.Get("code"), st.Verifier)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("sign in failed: %v", err), http.StatusBadGateway)
+			return
+		}
+		username, err := p.username(claims)
+		if err != nil {
+			status := http.StatusBadGateway
+			if errors.Is(err, errEmailNotVerified) {
+				status = http.StatusForbidden
+			}
+			http.Error(w, fmt.Sprintf("sign in failed: %v", err), status)
+			return
+		}
+		v, err := p.sign(oidcSession{
+			Username:  username,
+			Role:      p.role(username, claims),
+			ExpiresAt: time.Now().Add(p.opts.SessionTTL).Unix(),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		p.setCookie(w, r, oidcSessionCookieName, v, p.opts.SessionTTL)
+		http.Redirect(w, r, st.Redirect, http.StatusFound)
+	}
+}
+
+// newOIDCLogoutHandlerFunc returns a handler which signs out users.
+func newOIDCLogoutHandlerFunc(p *oidcProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p.clearCookie(w, r, oidcSessionCookieName)
+		http.Redirect(w, r, externalRootPath(r, p.rootPath)+"/", http.StatusFound)
+	}
+}
@@ -0,0 +1,136 @@
+package asynqmon
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestOIDCProvider(t *testing.T, opts OIDCOptions) *oidcProvider {
+	t.Helper()
+	opts.IssuerURL = "https://idp.example.com"
+	opts.ClientID = "asynqmon"
+	opts.RedirectURL = "https://asynqmon.example.com/auth/callback"
+	p, err := newOIDCProvider(opts, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestOIDCUsername(t *testing.T) {
+	tests := []struct {
+		desc          string
+		usernameClaim string
+		claims        map[string]interface{}
+		want          string
+		wantErr       error // nil if any error is expected when want is empty
+	}{
+		{
+			desc:   "verified email",
+			claims: map[string]interface{}{"sub": "1", "email": "alice@example.com", "email_verified": true},
+			want:   "alice@example.com",
+		},
+		{
+			desc:   "verified email as string",
+			claims: map[string]interface{}{"sub": "1", "email": "alice@example.com", "email_verified": "true"},
+			want:   "alice@example.com",
+		},
+		{
+			desc:    "unverified email",
+			claims:  map[string]interface{}{"sub": "1", "email": "alice@example.com", "email_verified": false},
+			wantErr: errEmailNotVerified,
+		},
+		{
+			desc:    "email without email_verified",
+			claims:  map[string]interface{}{"sub": "1", "email": "alice@example.com"},
+			wantErr: errEmailNotVerified,
+		},
+		{
+			desc:   "no email",
+			claims: map[string]interface{}{"sub": "1"},
+			want:   "1",
+		},
+		{
+			desc:          "other claim does not need verification",
+			usernameClaim: "preferred_username",
+			claims:        map[string]interface{}{"sub": "1", "preferred_username": "alice"},
+			want:          "alice",
+		},
+		{
+			desc:   "no username",
+			claims: map[string]interface{}{},
+		},
+	}
+	for _, tc := range tests {
+		p := newTestOIDCProvider(t, OIDCOptions{UsernameClaim: tc.usernameClaim})
+		got, err := p.username(tc.claims)
+		if tc.want != "" {
+			if err != nil || got != tc.want {
+				t.Errorf("%s: username = %q, %v; want %q", tc.desc, got, err, tc.want)
+			}
+			continue
+		}
+		if err == nil || (tc.wantErr != nil && !errors.Is(err, tc.wantErr)) {
+			t.Errorf("%s: username = %q, %v; want error %v", tc.desc, got, err, tc.wantErr)
+		}
+	}
+}
+
+func TestOIDCRole(t *testing.T) {
+	p := newTestOIDCProvider(t, OIDCOptions{Admins: []string{"alice@example.com", "platform"}})
+	tests := []struct {
+		username string
+		claims   map[string]interface{}
+		want     string
+	}{
+		{"alice@example.com", nil, RoleAdmin},
+		{"bob@example.com", map[string]interface{}{"groups": []interface{}{"dev", "platform"}}, RoleAdmin},
+		{"bob@example.com", map[string]interface{}{"groups": "platform"}, RoleAdmin},
+		{"bob@example.com", map[string]interface{}{"groups": []interface{}{"dev"}}, RoleViewer},
+		{"bob@example.com", nil, RoleViewer},
+	}
+	for _, tc := range tests {
+		if got := p.role(tc.username, tc.claims); got != tc.want {
+			t.Errorf("role(%q, %v) = %q, want %q", tc.username, tc.claims, got, tc.want)
+		}
+	}
+}
+
+func TestOIDCSession(t *testing.T) {
+	p := newTestOIDCProvider(t, OIDCOptions{SessionSecret: "s3cr3t"})
+	other := newTestOIDCProvider(t, OIDCOptions{SessionSecret: "other"})
+	valid, err := p.sign(oidcSession{Username: "alice", Role: RoleAdmin, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := p.sign(oidcSession{Username: "alice", Role: RoleAdmin, ExpiresAt: time.Now().Add(-time.Hour).Unix()})
+	unknownRole, _ := p.sign(oidcSession{Username: "alice", Role: "owner", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	forged, _ := other.sign(oidcSession{Username: "alice", Role: RoleAdmin, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+
+	tests := []struct {
+		desc   string
+		cookie string
+		want   bool
+	}{
+		{"valid session", valid, true},
+		{"expired session", expired, false},
+		{"unknown role", unknownRole, false},
+		{"signed with other secret", forged, false},
+		{"tampered session", valid[1:], false},
+		{"malformed session", "garbage", false},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.AddCookie(&http.Cookie{Name: oidcSessionCookieName, Value: tc.cookie})
+		info := p.session(r)
+		if got := info != nil; got != tc.want {
+			t.Errorf("%s: session = %v, want session %t", tc.desc, info, tc.want)
+		}
+		if info != nil && (info.name != "alice" || info.role != RoleAdmin) {
+			t.Errorf("%s: session = %v, want admin alice", tc.desc, info)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package asynqmon
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - pagination which describes a page of a paginated list
+//   - helper to set the Link header of paginated responses
+// ****************************************************************************
+
+// pagination is included in paginated list responses so that clients can
+// paginate without inferring the number of pages from queue stats.
+type pagination struct {
+	// Page number starting from 1.
+	Page int `json:"page"`
+	// Number of items per page.
+	Size int `json:"size"`
+	// Total number of items in the list.
+	// Null if the total is not known.
+	Total *int `json:"total"`
+	// Total number of pages.
+	// Null if the total is not known.
+	TotalPages *int `json:"total_pages"`
+	// Page number to pass in the page parameter to get the next and previous pages.
+	// Null if there is no such page.
+	NextPage *int `json:"next_page"`
+	PrevPage *int `json:"prev_page"`
+}
+
+// newPagination returns the pagination of the page with the given number and size,
+// which has count items. total is the number of items in the list, or -1 if not known,
+// in which case a full page is assumed to have a next page.
+func newPagination(pageNum, pageSize, total, count int) *pagination {
+	p := &pagination{Page: pageNum, Size: pageSize}
+	hasNext := count == pageSize
+	if total >= 0 {
+		totalPages := (total + pageSize - 1) / pageSize
+		p.Total, p.TotalPages = &total, &totalPages
+		hasNext = pageNum < totalPages
+	}
+	if hasNext {
+		next := pageNum + 1
+		p.NextPage = &next
+	}
+	if pageNum > 1 {
+		prev := pageNum - 1
+		if p.TotalPages != nil && prev > *p.TotalPages {
+			// Past the end of the list; point back to the last page.
+			prev = *p.TotalPages
+		}
+		if prev >= 1 {
+			p.PrevPage = &prev
+		}
+	}
+	return p
+}
+
+// setLinkHeader sets the Link header (RFC 5988) of a paginated response with
+// the URLs of the first, previous, next, and last pages. The URLs are relative
+// to the request URL and keep its other query parameters.
+func setLinkHeader(w http.ResponseWriter, r *http.Request, p *pagination) {
+	u, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		u = r.URL
+	}
+	pageURL := func(n int) string {
+		q := u.Query()
+		q.Set("page", strconv.Itoa(n))
+		q.Set("size", strconv.Itoa(p.Size))
+		return u.Path + "?" + q.Encode()
+	}
+	links := []string{fmt.Sprintf("<%s>; rel=\"first\"", pageURL(1))}
+	if p.PrevPage != nil {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"prev\"", pageURL(*p.PrevPage)))
+	}
+	if p.NextPage != nil {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"next\"", pageURL(*p.NextPage)))
+	}
+	if p.TotalPages != nil && *p.TotalPages > 0 {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"last\"", pageURL(*p.TotalPages)))
+	}
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
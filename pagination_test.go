@@ -0,0 +1,141 @@
+package asynqmon
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestNewPagination(t *testing.T) {
+	tests := []struct {
+		desc                          string
+		pageNum, pageSize, total, cnt int
+		want                          *pagination
+	}{
+		{
+			desc:    "first page",
+			pageNum: 1, pageSize: 10, total: 25, cnt: 10,
+			want: &pagination{Page: 1, Size: 10, Total: intPtr(25), TotalPages: intPtr(3), NextPage: intPtr(2)},
+		},
+		{
+			desc:    "middle page",
+			pageNum: 2, pageSize: 10, total: 25, cnt: 10,
+			want: &pagination{Page: 2, Size: 10, Total: intPtr(25), TotalPages: intPtr(3), NextPage: intPtr(3), PrevPage: intPtr(1)},
+		},
+		{
+			desc:    "last partial page",
+			pageNum: 3, pageSize: 10, total: 25, cnt: 5,
+			want: &pagination{Page: 3, Size: 10, Total: intPtr(25), TotalPages: intPtr(3), PrevPage: intPtr(2)},
+		},
+		{
+			desc:    "last full page",
+			pageNum: 2, pageSize: 10, total: 20, cnt: 10,
+			want: &pagination{Page: 2, Size: 10, Total: intPtr(20), TotalPages: intPtr(2), PrevPage: intPtr(1)},
+		},
+		{
+			desc:    "single page",
+			pageNum: 1, pageSize: 10, total: 3, cnt: 3,
+			want: &pagination{Page: 1, Size: 10, Total: intPtr(3), TotalPages: intPtr(1)},
+		},
+		{
+			desc:    "empty list",
+			pageNum: 1, pageSize: 10, total: 0, cnt: 0,
+			want: &pagination{Page: 1, Size: 10, Total: intPtr(0), TotalPages: intPtr(0)},
+		},
+		{
+			desc:    "page past the end points back to the last page",
+			pageNum: 7, pageSize: 10, total: 25, cnt: 0,
+			want: &pagination{Page: 7, Size: 10, Total: intPtr(25), TotalPages: intPtr(3), PrevPage: intPtr(3)},
+		},
+		{
+			desc:    "page right after the last page",
+			pageNum: 4, pageSize: 10, total: 25, cnt: 0,
+			want: &pagination{Page: 4, Size: 10, Total: intPtr(25), TotalPages: intPtr(3), PrevPage: intPtr(3)},
+		},
+		{
+			desc:    "page past the end of an empty list",
+			pageNum: 3, pageSize: 10, total: 0, cnt: 0,
+			want: &pagination{Page: 3, Size: 10, Total: intPtr(0), TotalPages: intPtr(0)},
+		},
+		{
+			desc:    "unknown total with full page",
+			pageNum: 2, pageSize: 10, total: -1, cnt: 10,
+			want: &pagination{Page: 2, Size: 10, NextPage: intPtr(3), PrevPage: intPtr(1)},
+		},
+		{
+			desc:    "unknown total with partial page",
+			pageNum: 2, pageSize: 10, total: -1, cnt: 4,
+			want: &pagination{Page: 2, Size: 10, PrevPage: intPtr(1)},
+		},
+		{
+			desc:    "unknown total with empty page",
+			pageNum: 1, pageSize: 10, total: -1, cnt: 0,
+			want: &pagination{Page: 1, Size: 10},
+		},
+	}
+	for _, tc := range tests {
+		got := newPagination(tc.pageNum, tc.pageSize, tc.total, tc.cnt)
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%s: newPagination(%d, %d, %d, %d) returned diff (-want,+got):\n%s",
+				tc.desc, tc.pageNum, tc.pageSize, tc.total, tc.cnt, diff)
+		}
+	}
+}
+
+func TestSetLinkHeader(t *testing.T) {
+	tests := []struct {
+		desc string
+		uri  string
+		p    *pagination
+		want string
+	}{
+		{
+			desc: "middle page",
+			uri:  "/api/queues/default/pending_tasks?page=2&size=10",
+			p:    newPagination(2, 10, 25, 10),
+			want: `</api/queues/default/pending_tasks?page=1&size=10>; rel="first", ` +
+				`</api/queues/default/pending_tasks?page=1&size=10>; rel="prev", ` +
+				`</api/queues/default/pending_tasks?page=3&size=10>; rel="next", ` +
+				`</api/queues/default/pending_tasks?page=3&size=10>; rel="last"`,
+		},
+		{
+			desc: "last page keeps other parameters",
+			uri:  "/api/queues/default/archived_tasks?fields=id&page=3&size=10",
+			p:    newPagination(3, 10, 25, 5),
+			want: `</api/queues/default/archived_tasks?fields=id&page=1&size=10>; rel="first", ` +
+				`</api/queues/default/archived_tasks?fields=id&page=2&size=10>; rel="prev", ` +
+				`</api/queues/default/archived_tasks?fields=id&page=3&size=10>; rel="last"`,
+		},
+		{
+			desc: "page past the end",
+			uri:  "/api/queues/default/pending_tasks?page=9",
+			p:    newPagination(9, 20, 25, 0),
+			want: `</api/queues/default/pending_tasks?page=1&size=20>; rel="first", ` +
+				`</api/queues/default/pending_tasks?page=2&size=20>; rel="prev", ` +
+				`</api/queues/default/pending_tasks?page=2&size=20>; rel="last"`,
+		},
+		{
+			desc: "empty list has no last page",
+			uri:  "/api/queues/default/pending_tasks",
+			p:    newPagination(1, 20, 0, 0),
+			want: `</api/queues/default/pending_tasks?page=1&size=20>; rel="first"`,
+		},
+		{
+			desc: "unknown total",
+			uri:  "/api/queues/default/completed_tasks?page=1&size=5",
+			p:    newPagination(1, 5, -1, 5),
+			want: `</api/queues/default/completed_tasks?page=1&size=5>; rel="first", ` +
+				`</api/queues/default/completed_tasks?page=2&size=5>; rel="next"`,
+		},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		setLinkHeader(w, httptest.NewRequest("GET", tc.uri, nil), tc.p)
+		if diff := cmp.Diff(tc.want, w.Header().Get("Link")); diff != "" {
+			t.Errorf("%s: setLinkHeader set diff (-want,+got):\n%s", tc.desc, diff)
+		}
+	}
+}
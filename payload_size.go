@@ -0,0 +1,264 @@
+package asynqmon
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - taskPayloadFormatter which formats task payloads and measures their size
+//   - http.Handler(s) for payload size stats
+// ****************************************************************************
+
+// Maximum number of bytes read when decompressing a payload to measure its size.
+// Larger payloads are reported with this size.
+const maxDecompressedPayloadSize = 64 << 20
+
+// taskPayloadFormatter formats task payloads shown in the UI.
+// Payloads are decrypted with Options.DecryptPayload before they are formatted, measured, or matched.
+type taskPayloadFormatter struct {
+	pf         PayloadFormatter
+	decrypt    PayloadTransformFunc // may be nil
+	decompress PayloadTransformFunc // may be nil
+
+	// Set by forFields to skip work for fields which are not selected.
+	skipPayload          bool
+	skipDecompressedSize bool
+}
+
+// newTaskPayloadFormatter returns the taskPayloadFormatter configured by opts.
+func newTaskPayloadFormatter(opts Options) *taskPayloadFormatter {
+	var pf PayloadFormatter = DefaultPayloadFormatter
+	if opts.PayloadFormatter != nil {
+		pf = opts.PayloadFormatter
+	}
+	pf = payloadFormatterForRules(opts.Formatters, pf)
+	if opts.LargeIntegersAsStrings {
+		pf = largeIntsPayloadFormatter(pf)
+	}
+	return &taskPayloadFormatter{
+		pf:         pf,
+		decrypt:    opts.DecryptPayload,
+		decompress: opts.DecompressPayload,
+	}
+}
+
+// forFields returns a formatter which skips formatting and measuring payloads
+// if the corresponding fields are not selected.
+func (f *taskPayloadFormatter) forFields(fs fieldSet) *taskPayloadFormatter {
+	g := *f
+	g.skipPayload = !fs.has("payload")
+	g.skipDecompressedSize = !fs.has("decompressed_payload_size_bytes")
+	return &g
+}
+
+func (f *taskPayloadFormatter) decrypted(taskType string, payload []byte) ([]byte, error) {
+	if f.decrypt == nil {
+		return payload, nil
+	}
+	return f.decrypt(taskType, payload)
+}
+
+func (f *taskPayloadFormatter) FormatPayload(taskType string, payload []byte) string {
+	if f.skipPayload {
+		return ""
+	}
+	b, err := f.decrypted(taskType, payload)
+	if err != nil {
+		return "could not decrypt payload: " + err.Error()
+	}
+	return f.pf.FormatPayload(taskType, b)
+}
+
+// decompressedSize returns the size of the payload after decompression,
+// or zero if the payload is not compressed.
+func (f *taskPayloadFormatter) decompressedSize(taskType string, payload []byte) int {
+	if f.skipDecompressedSize {
+		return 0
+	}
+	b, err := f.decrypted(taskType, payload)
+	if err != nil {
+		return 0
+	}
+	if f.decompress != nil {
+		out, err := f.decompress(taskType, b)
+		if err != nil {
+			return 0
+		}
+		return len(out)
+	}
+	return builtinDecompressedSize(b)
+}
+
+// decoded returns the payload after decryption and decompression, to match it against
+// task filters. Payloads which cannot be decrypted or decompressed are returned as is.
+func (f *taskPayloadFormatter) decoded(taskType string, payload []byte) []byte {
+	b, err := f.decrypted(taskType, payload)
+	if err != nil {
+		return payload
+	}
+	if f.decompress != nil {
+		out, err := f.decompress(taskType, b)
+		if err != nil {
+			return b
+		}
+		return out
+	}
+	r := builtinDecompressReader(b)
+	if r == nil {
+		return b
+	}
+	out, err := io.ReadAll(io.LimitReader(r, maxDecompressedPayloadSize))
+	if err != nil {
+		return b // not compressed data after all
+	}
+	return out
+}
+
+// builtinDecompressReader returns a reader of the gzip or zlib compressed data
+// after decompression, or nil if the data is not compressed in either format.
+func builtinDecompressReader(b []byte) io.Reader {
+	var r io.Reader
+	var err error
+	switch {
+	case len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b:
+		r, err = gzip.NewReader(bytes.NewReader(b))
+	case len(b) >= 2 && b[0]&0x0f == 8 && (int(b[0])<<8|int(b[1]))%31 == 0:
+		r, err = zlib.NewReader(bytes.NewReader(b))
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	return r
+}
+
+// builtinDecompressedSize returns the size of the gzip or zlib compressed data
+// after decompression, or zero if the data is not compressed in either format.
+func builtinDecompressedSize(b []byte) int {
+	r := builtinDecompressReader(b)
+	if r == nil {
+		return 0
+	}
+	n, err := io.Copy(io.Discard, io.LimitReader(r, maxDecompressedPayloadSize))
+	if err != nil {
+		return 0 // not compressed data after all
+	}
+	return int(n)
+}
+
+// Default and maximum number of tasks sampled in each state for payload stats.
+const (
+	defaultPayloadStatsSampleSize = 100
+	maxPayloadStatsSampleSize     = 1000
+)
+
+type payloadSizeStats struct {
+	// Number of sampled tasks.
+	Count int `json:"count"`
+	// Number of sampled tasks with compressed payloads.
+	CompressedCount int `json:"compressed_count"`
+	// Total size of the payloads in bytes as stored in redis.
+	SizeBytes int64 `json:"size_bytes"`
+	// Largest payload in bytes as stored in redis.
+	MaxSizeBytes int `json:"max_size_bytes"`
+	// Total size of the compressed payloads in bytes as stored in redis.
+	CompressedSizeBytes int64 `json:"compressed_size_bytes"`
+	// Total size of the compressed payloads in bytes after decompression.
+	DecompressedSizeBytes int64 `json:"decompressed_size_bytes"`
+	// Ratio of DecompressedSizeBytes to CompressedSizeBytes.
+	// Zero if there are no compressed payloads.
+	CompressionRatio float64 `json:"compression_ratio"`
+}
+
+func (s *payloadSizeStats) add(size, decompressedSize int) {
+	s.Count++
+	s.SizeBytes += int64(size)
+	if size > s.MaxSizeBytes {
+		s.MaxSizeBytes = size
+	}
+	if decompressedSize > 0 {
+		s.CompressedCount++
+		s.CompressedSizeBytes += int64(size)
+		s.DecompressedSizeBytes += int64(decompressedSize)
+		if s.CompressedSizeBytes > 0 {
+			s.CompressionRatio = float64(s.DecompressedSizeBytes) / float64(s.CompressedSizeBytes)
+		}
+	}
+}
+
+type taskTypePayloadSizeStats struct {
+	Type string `json:"type"`
+	*payloadSizeStats
+}
+
+type getPayloadStatsResponse struct {
+	// Maximum number of tasks sampled in each state.
+	SampleSize int               `json:"sample_size"`
+	Total      *payloadSizeStats `json:"total"`
+	// Stats by task type, in descending order of payload size.
+	Types []*taskTypePayloadSizeStats `json:"types"`
+}
+
+func newGetPayloadStatsHandlerFunc(inspector *asynq.Inspector, pf *taskPayloadFormatter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qname := mux.Vars(r)["qname"]
+		sampleSize := defaultPayloadStatsSampleSize
+		if s := r.URL.Query().Get("sample_size"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > maxPayloadStatsSampleSize {
+				writeBadRequestError(w, r, "sample_size must be an integer between 1 and "+strconv.Itoa(maxPayloadStatsSampleSize))
+				return
+			}
+			sampleSize = n
+		}
+		listFuncs := []func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error){
+			inspector.ListActiveTasks,
+			inspector.ListPendingTasks,
+			inspector.ListScheduledTasks,
+			inspector.ListRetryTasks,
+			inspector.ListArchivedTasks,
+			inspector.ListCompletedTasks,
+		}
+		resp := getPayloadStatsResponse{SampleSize: sampleSize, Total: &payloadSizeStats{}}
+		byType := make(map[string]*payloadSizeStats)
+		for _, list := range listFuncs {
+			tasks, err := list(qname, asynq.PageSize(sampleSize))
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			for _, t := range tasks {
+				size, decompressed := len(t.Payload), pf.decompressedSize(t.Type, t.Payload)
+				resp.Total.add(size, decompressed)
+				s, ok := byType[t.Type]
+				if !ok {
+					s = &payloadSizeStats{}
+					byType[t.Type] = s
+				}
+				s.add(size, decompressed)
+			}
+		}
+		resp.Types = make([]*taskTypePayloadSizeStats, 0, len(byType))
+		for typ, s := range byType {
+			resp.Types = append(resp.Types, &taskTypePayloadSizeStats{Type: typ, payloadSizeStats: s})
+		}
+		sort.Slice(resp.Types, func(i, j int) bool {
+			if resp.Types[i].SizeBytes == resp.Types[j].SizeBytes {
+				return resp.Types[i].Type < resp.Types[j].Type
+			}
+			return resp.Types[i].SizeBytes > resp.Types[j].SizeBytes
+		})
+		writeResponseJSON(w, resp)
+	}
+}
@@ -0,0 +1,168 @@
+package asynqmon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/gorilla/mux"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - Panel and DataProvider to add custom panels to the Web UI
+//   - http.Handler(s) for plugin related endpoints
+// ****************************************************************************
+
+// Types of panels used for Panel.Type.
+const (
+	// PanelTable shows the data as a table with a column for each of PanelData.Columns.
+	PanelTable = "table"
+
+	// PanelLineChart shows the data as a line chart. The first column is the
+	// x-axis (e.g. time) and each of the other columns is a line.
+	PanelLineChart = "line_chart"
+)
+
+// Panel is a custom panel shown in the Plugins page of the Web UI.
+// Its data is served under /api/plugins/{name}.
+type Panel struct {
+	// Name identifies the panel in the URL.
+	// It must be unique and consist of letters, digits, hyphens, and underscores.
+	Name string
+
+	// Title is shown above the panel.
+	//
+	// This field is optional. Default is the name.
+	Title string
+
+	// Type is the type of the panel (e.g. PanelTable).
+	//
+	// This field is optional. Default is PanelTable.
+	Type string
+
+	// Provider provides the data of the panel.
+	Provider DataProvider
+}
+
+// PanelData is the data of a panel.
+type PanelData struct {
+	// Columns are the names of the columns.
+	Columns []string
+
+	// Rows are the values of each row, in the order of Columns.
+	// Values must be encodable as JSON. For PanelLineChart, values other than
+	// those of the first column must be numbers.
+	Rows [][]interface{}
+}
+
+// DataProvider provides the data of a Panel.
+type DataProvider interface {
+	// Data returns the data of the panel.
+	// params are the query parameters of the request made by the Web UI or an API client.
+	Data(ctx context.Context, params url.Values) (*PanelData, error)
+}
+
+// DataProviderFunc is an adapter to allow the use of ordinary functions as a DataProvider.
+// If f is a function with the appropriate signature, DataProviderFunc(f) is a DataProvider that calls f.
+type DataProviderFunc func(context.Context, url.Values) (*PanelData, error)
+
+// Data calls fn(ctx, params)
+func (fn DataProviderFunc) Data(ctx context.Context, params url.Values) (*PanelData, error) {
+	return fn(ctx, params)
+}
+
+var panelNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// validatePanels sets the default values of the panels and returns an error
+// if any of the panels is invalid.
+func validatePanels(panels []*Panel) error {
+	names := make(map[string]bool)
+	for _, p := range panels {
+		if !panelNameRegexp.MatchString(p.Name) {
+			return fmt.Errorf("invalid panel name %q: must consist of letters, digits, hyphens, and underscores", p.Name)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate panel name %q", p.Name)
+		}
+		names[p.Name] = true
+		if p.Provider == nil {
+			return fmt.Errorf("panel %q has no provider", p.Name)
+		}
+		switch p.Type {
+		case "":
+			p.Type = PanelTable
+		case PanelTable, PanelLineChart:
+		default:
+			return fmt.Errorf("panel %q has unknown type %q", p.Name, p.Type)
+		}
+		if p.Title == "" {
+			p.Title = p.Name
+		}
+	}
+	return nil
+}
+
+type panelInfo struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	Type  string `json:"type"`
+}
+
+type listPanelsResponse struct {
+	Panels []*panelInfo `json:"panels"`
+}
+
+func newListPanelsHandlerFunc(panels []*Panel) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := listPanelsResponse{Panels: make([]*panelInfo, 0, len(panels))}
+		for _, p := range panels {
+			resp.Panels = append(resp.Panels, &panelInfo{Name: p.Name, Title: p.Title, Type: p.Type})
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+type getPanelDataResponse struct {
+	panelInfo
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// newGetPanelDataHandlerFunc returns a handler which responds with the data of
+// the panel named in the URL, passing the query parameters to its provider.
+func newGetPanelDataHandlerFunc(panels []*Panel) http.HandlerFunc {
+	byName := make(map[string]*Panel, len(panels))
+	for _, p := range panels {
+		byName[p.Name] = p
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		p, ok := byName[name]
+		if !ok {
+			writeErrorResponse(w, r, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("panel %q not found", name))
+			return
+		}
+		data, err := p.Provider.Data(r.Context(), r.URL.Query())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		resp := getPanelDataResponse{
+			panelInfo: panelInfo{Name: p.Name, Title: p.Title, Type: p.Type},
+			Columns:   make([]string, 0),
+			Rows:      make([][]interface{}, 0),
+		}
+		if data != nil {
+			if data.Columns != nil {
+				resp.Columns = data.Columns
+			}
+			if data.Rows != nil {
+				resp.Rows = data.Rows
+			}
+		}
+		writeResponseJSON(w, resp)
+	}
+}
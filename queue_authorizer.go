@@ -0,0 +1,147 @@
+package asynqmon
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - queueAuthorizer which restricts access to queues with Options.QueueAuthorizer
+// ****************************************************************************
+
+// queueAuthorizer decides which queues the requester can view and change.
+// A nil *queueAuthorizer allows every queue.
+type queueAuthorizer struct {
+	authorize func(r *http.Request, qname string) (view bool, mutate bool)
+}
+
+// newQueueAuthorizer returns a queueAuthorizer which calls fn, or nil if fn is nil.
+func newQueueAuthorizer(fn func(r *http.Request, qname string) (view bool, mutate bool)) *queueAuthorizer {
+	if fn == nil {
+		return nil
+	}
+	return &queueAuthorizer{authorize: fn}
+}
+
+// canView reports whether the requester can view the queue.
+func (a *queueAuthorizer) canView(r *http.Request, qname string) bool {
+	if a == nil {
+		return true
+	}
+	view, _ := a.authorize(r, qname)
+	return view
+}
+
+// canMutate reports whether the requester can make changes to the queue.
+func (a *queueAuthorizer) canMutate(r *http.Request, qname string) bool {
+	if a == nil {
+		return true
+	}
+	view, mutate := a.authorize(r, qname)
+	return view && mutate
+}
+
+// checkView reports whether the requester can view the queue.
+// If not, it responds as if the queue did not exist so that the name of the queue is not leaked.
+func (a *queueAuthorizer) checkView(w http.ResponseWriter, r *http.Request, qname string) bool {
+	if !a.canView(r, qname) {
+		writeError(w, r, fmt.Errorf("%w: %q", asynq.ErrQueueNotFound, qname))
+		return false
+	}
+	return true
+}
+
+// checkMutate reports whether the requester can make changes to the queue, and responds with an error if not.
+func (a *queueAuthorizer) checkMutate(w http.ResponseWriter, r *http.Request, qname string) bool {
+	if a == nil {
+		return true
+	}
+	view, mutate := a.authorize(r, qname)
+	if !view {
+		writeError(w, r, fmt.Errorf("%w: %q", asynq.ErrQueueNotFound, qname))
+		return false
+	}
+	if !mutate {
+		writeErrorResponse(w, r, http.StatusForbidden, errCodeForbidden, fmt.Sprintf("not allowed to make changes to queue %q", qname))
+		return false
+	}
+	return true
+}
+
+// queues returns the names of the queues the requester can view.
+func (a *queueAuthorizer) queues(r *http.Request, inspector *asynq.Inspector) ([]string, error) {
+	qnames, err := inspector.Queues()
+	if err != nil || a == nil {
+		return qnames, err
+	}
+	visible := make([]string, 0, len(qnames))
+	for _, qname := range qnames {
+		if a.canView(r, qname) {
+			visible = append(visible, qname)
+		}
+	}
+	return visible, nil
+}
+
+// filterServers removes the queues which the requester cannot view from the servers,
+// along with the workers processing tasks of those queues.
+func (a *queueAuthorizer) filterServers(r *http.Request, srvs []*serverInfo) {
+	if a == nil {
+		return
+	}
+	for _, srv := range srvs {
+		srv.Queues = a.filterQueuePriorities(r, srv.Queues)
+		workers := make([]*workerInfo, 0, len(srv.ActiveWorkers))
+		for _, w := range srv.ActiveWorkers {
+			if a.canView(r, w.Queue) {
+				workers = append(workers, w)
+			}
+		}
+		srv.ActiveWorkers = workers
+	}
+}
+
+// filterServerEvents removes the queues which the requester cannot view from the server history events.
+func (a *queueAuthorizer) filterServerEvents(r *http.Request, events []*serverEvent) {
+	if a == nil {
+		return
+	}
+	for _, ev := range events {
+		ev.Queues = a.filterQueuePriorities(r, ev.Queues)
+	}
+}
+
+// filterQueuePriorities returns the priorities of the queues which the requester can view.
+func (a *queueAuthorizer) filterQueuePriorities(r *http.Request, priorities map[string]int) map[string]int {
+	queues := make(map[string]int, len(priorities))
+	for qname, priority := range priorities {
+		if a.canView(r, qname) {
+			queues[qname] = priority
+		}
+	}
+	return queues
+}
+
+// middleware restricts requests to endpoints of a queue (i.e. routes with the qname variable).
+// GET requests require view, and other requests require mutate.
+func (a *queueAuthorizer) middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		qname, ok := mux.Vars(r)["qname"]
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if r.Method == "GET" {
+			if !a.checkView(w, r, qname) {
+				return
+			}
+		} else if !a.checkMutate(w, r, qname) {
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,263 @@
+package asynqmon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// teamAuthorizer lets the team in the X-Team header view and change the queues prefixed
+// with the name of the team, and view the queues prefixed with "shared:".
+// Requests without the header can view and change every queue.
+func teamAuthorizer() *queueAuthorizer {
+	return newQueueAuthorizer(func(r *http.Request, qname string) (view bool, mutate bool) {
+		team := r.Header.Get("X-Team")
+		if team == "" {
+			return true, true
+		}
+		owned := strings.HasPrefix(qname, team+":")
+		return owned || strings.HasPrefix(qname, "shared:"), owned
+	})
+}
+
+func newTeamRequest(method, target, team, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if team != "" {
+		r.Header.Set("X-Team", team)
+	}
+	return r
+}
+
+func TestQueueAuthorizerMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(teamAuthorizer().middleware)
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router.HandleFunc("/api/queues/{qname}", ok).Methods("GET")
+	router.HandleFunc("/api/queues/{qname}:pause", ok).Methods("POST")
+	router.HandleFunc("/api/servers", ok).Methods("GET")
+
+	tests := []struct {
+		desc       string
+		method     string
+		path       string
+		team       string
+		wantStatus int
+	}{
+		{"view own queue", "GET", "/api/queues/a:jobs", "a", 200},
+		{"change own queue", "POST", "/api/queues/a:jobs:pause", "a", 200},
+		{"view shared queue", "GET", "/api/queues/shared:jobs", "a", 200},
+		{"change shared queue", "POST", "/api/queues/shared:jobs:pause", "a", 403},
+		{"view queue of another team", "GET", "/api/queues/b:jobs", "a", 404},
+		{"change queue of another team", "POST", "/api/queues/b:jobs:pause", "a", 404},
+		{"request without team", "POST", "/api/queues/b:jobs:pause", "", 200},
+		{"route without queue", "GET", "/api/servers", "a", 200},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newTeamRequest(tc.method, tc.path, tc.team, ""))
+		if w.Code != tc.wantStatus {
+			t.Errorf("%s: %s %s responded with %d, want %d", tc.desc, tc.method, tc.path, w.Code, tc.wantStatus)
+		}
+	}
+}
+
+// newAuthorizerAlertEvaluator returns an evaluator with configured and managed rules
+// and alerts of the queues of teams a and b.
+func newAuthorizerAlertEvaluator(t *testing.T) *alertEvaluator {
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rc.Close() })
+	store := newAlertRuleStore(rc)
+	ctx := context.Background()
+
+	configured := []*AlertRule{
+		{Name: "a-size", Type: AlertQueueSize, Queue: "a:jobs", Severity: SeverityWarning, Threshold: 100},
+		{Name: "b-size", Type: AlertQueueSize, Queue: "b:jobs", Severity: SeverityWarning, Threshold: 100},
+		{Name: "silence", Type: AlertProcessingSilence, Severity: SeverityCritical},
+	}
+	for _, rec := range []*alertRuleRecord{
+		{Name: "a-latency", Type: AlertLatency, Queue: "a:jobs", Severity: SeverityWarning, Threshold: 60},
+		{Name: "b-latency", Type: AlertLatency, Queue: "b:jobs", Severity: SeverityWarning, Threshold: 60},
+		{Name: "shared-latency", Type: AlertLatency, Queue: "shared:jobs", Severity: SeverityWarning, Threshold: 60},
+	} {
+		if err := store.put(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alerts := []*alertRecord{
+		{alertInfo: alertInfo{Rule: "a-size", Type: AlertQueueSize, Subject: "a:jobs", State: "firing"}, Queue: "a:jobs"},
+		{alertInfo: alertInfo{Rule: "b-size", Type: AlertQueueSize, Subject: "b:jobs", State: "firing"}, Queue: "b:jobs"},
+		{alertInfo: alertInfo{Rule: "silence", Type: AlertProcessingSilence, Subject: "shared:jobs", State: "pending"}, Queue: "shared:jobs"},
+		{alertInfo: alertInfo{Rule: "silence", Type: AlertProcessingSilence, Subject: "b:jobs", State: "pending"}, Queue: "b:jobs"},
+	}
+	if err := store.putAlerts(ctx, alerts, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	return newAlertEvaluator(nil, time.UTC, store, configured, nil, 30*time.Second, "")
+}
+
+func alertRuleNames(rules []*alertRuleInfo) []string {
+	names := make([]string, len(rules))
+	for i, rule := range rules {
+		names[i] = rule.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestQueueAuthorizerAlerts(t *testing.T) {
+	alerts := newAuthorizerAlertEvaluator(t)
+	qa := teamAuthorizer()
+
+	tests := []struct {
+		team       string
+		wantRules  []string
+		wantAlerts []string // rule and subject of the alerts
+	}{
+		{
+			team:       "a",
+			wantRules:  []string{"a-latency", "a-size", "shared-latency", "silence"},
+			wantAlerts: []string{"a-size/a:jobs", "silence/shared:jobs"},
+		},
+		{
+			team:       "b",
+			wantRules:  []string{"b-latency", "b-size", "shared-latency", "silence"},
+			wantAlerts: []string{"b-size/b:jobs", "silence/b:jobs", "silence/shared:jobs"},
+		},
+		{
+			team:       "c",
+			wantRules:  []string{"shared-latency", "silence"},
+			wantAlerts: []string{"silence/shared:jobs"},
+		},
+		{
+			team:       "",
+			wantRules:  []string{"a-latency", "a-size", "b-latency", "b-size", "shared-latency", "silence"},
+			wantAlerts: []string{"a-size/a:jobs", "b-size/b:jobs", "silence/b:jobs", "silence/shared:jobs"},
+		},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		newListAlertRulesHandlerFunc(alerts, qa)(w, newTeamRequest("GET", "/api/alert_rules", tc.team, ""))
+		var rulesResp listAlertRulesResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &rulesResp); err != nil {
+			t.Fatalf("team %q: %v: %s", tc.team, err, w.Body)
+		}
+		if diff := cmp.Diff(tc.wantRules, alertRuleNames(rulesResp.Rules)); diff != "" {
+			t.Errorf("team %q: GET /api/alert_rules returned rules diff (-want,+got):\n%s", tc.team, diff)
+		}
+
+		w = httptest.NewRecorder()
+		newListAlertsHandlerFunc(alerts, qa)(w, newTeamRequest("GET", "/api/alerts", tc.team, ""))
+		var alertsResp listAlertsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &alertsResp); err != nil {
+			t.Fatalf("team %q: %v: %s", tc.team, err, w.Body)
+		}
+		if diff := cmp.Diff(tc.wantRules, alertRuleNames(alertsResp.Rules)); diff != "" {
+			t.Errorf("team %q: GET /api/alerts returned rules diff (-want,+got):\n%s", tc.team, diff)
+		}
+		got := make([]string, len(alertsResp.Alerts))
+		for i, a := range alertsResp.Alerts {
+			got[i] = a.Rule + "/" + a.Subject
+		}
+		if diff := cmp.Diff(tc.wantAlerts, got); diff != "" {
+			t.Errorf("team %q: GET /api/alerts returned alerts diff (-want,+got):\n%s", tc.team, diff)
+		}
+	}
+}
+
+func TestQueueAuthorizerServerHistory(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rc.Close()
+	h := newServerHistory(rc)
+	now := time.Now()
+	srvs := []*asynq.ServerInfo{
+		{ID: "s1", Host: "host-1", Started: now.Add(-2 * time.Minute), Queues: map[string]int{"a:jobs": 2, "b:jobs": 1, "shared:jobs": 1}},
+		{ID: "s2", Host: "host-2", Started: now.Add(-time.Minute), Queues: map[string]int{"b:jobs": 1}},
+	}
+	if err := h.record(context.Background(), srvs, now); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		team string
+		want []map[string]int // queues of the events of s1 and s2
+	}{
+		{"a", []map[string]int{{"a:jobs": 2, "shared:jobs": 1}, {}}},
+		{"b", []map[string]int{{"b:jobs": 1, "shared:jobs": 1}, {"b:jobs": 1}}},
+		{"", []map[string]int{{"a:jobs": 2, "b:jobs": 1, "shared:jobs": 1}, {"b:jobs": 1}}},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		newListServerHistoryHandlerFunc(h, teamAuthorizer())(w, newTeamRequest("GET", "/api/servers/history", tc.team, ""))
+		var resp listServerHistoryResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("team %q: %v: %s", tc.team, err, w.Body)
+		}
+		got := make([]map[string]int, len(resp.Events))
+		for i, ev := range resp.Events {
+			got[i] = ev.Queues
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("team %q: GET /api/servers/history returned queues diff (-want,+got):\n%s", tc.team, diff)
+		}
+	}
+}
+
+func TestQueueAuthorizerSettings(t *testing.T) {
+	alerts := newAuthorizerAlertEvaluator(t)
+	qa := teamAuthorizer()
+
+	w := httptest.NewRecorder()
+	newExportSettingsHandlerFunc(nil, alerts, qa)(w, newTeamRequest("GET", "/api/settings:export", "a", ""))
+	var doc settingsDocument
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	if diff := cmp.Diff([]string{"a-latency", "a-size", "shared-latency", "silence"}, alertRuleNames(doc.AlertRules)); diff != "" {
+		t.Errorf("GET /api/settings:export returned rules diff (-want,+got):\n%s", diff)
+	}
+
+	// Rules of queues which team a cannot change are not imported.
+	body := `{"version":1,"alert_rules":[
+		{"name":"a-new","type":"queue_size","queue":"a:jobs","severity":"warning","threshold":10,"managed":true},
+		{"name":"b-latency","type":"latency","queue":"b:jobs","severity":"critical","threshold":1,"managed":true},
+		{"name":"shared-new","type":"queue_size","queue":"shared:jobs","severity":"warning","threshold":10,"managed":true},
+		{"name":"all-new","type":"queue_size","severity":"warning","threshold":10,"managed":true}
+	]}`
+	w = httptest.NewRecorder()
+	newImportSettingsHandlerFunc(nil, alerts, qa)(w, newTeamRequest("POST", "/api/settings:import", "a", body))
+	var resp importSettingsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	want := importSettingsResponse{
+		ImportedAlertRules: 1,
+		Ignored: []string{
+			`alert_rules: not allowed to make changes to queue "b:jobs" of alert rule "b-latency"`,
+			`alert_rules: not allowed to make changes to queue "shared:jobs" of alert rule "shared-new"`,
+			`alert_rules: not allowed to make changes to queue "" of alert rule "all-new"`,
+		},
+	}
+	if diff := cmp.Diff(want, resp); diff != "" {
+		t.Errorf("POST /api/settings:import returned diff (-want,+got):\n%s", diff)
+	}
+	rec, err := alerts.store.get(context.Background(), "b-latency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Severity != SeverityWarning {
+		t.Errorf("rule b-latency of team b was replaced by the import of team a")
+	}
+}
@@ -0,0 +1,167 @@
+package asynqmon
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - estimation of the time for a queue to drain its backlog
+//   - http.Handler(s) for queue drain related endpoints
+// ****************************************************************************
+
+// Window of queue stats samples used to estimate drain times.
+const queueDrainWindow = 15 * time.Minute
+
+// Values used for queueDrainEstimate.Confidence.
+const (
+	queueDrainConfidenceHigh   = "high"
+	queueDrainConfidenceMedium = "medium"
+	queueDrainConfidenceLow    = "low"
+)
+
+type queueDrainEstimate struct {
+	// Number of pending tasks in the latest sample.
+	Pending int `json:"pending"`
+	// Number of tasks processed per minute.
+	ProcessedPerMinute float64 `json:"processed_per_minute"`
+	// Decrease in the number of pending tasks per minute, which is the processing
+	// throughput minus the rate at which tasks are added. Negative if the backlog grows.
+	NetDrainPerMinute float64 `json:"net_drain_per_minute"`
+	// Standard deviation of the net drain rate between samples.
+	NetDrainStdDev float64 `json:"net_drain_stddev"`
+	// Estimated number of seconds until there are no pending tasks at the net drain rate.
+	// Null if the backlog is not draining. Zero if there are no pending tasks.
+	DrainSeconds *float64 `json:"drain_seconds"`
+	// Range of the estimate using the net drain rate plus and minus one standard deviation.
+	// DrainSecondsMax is null if the backlog may not drain at the lower rate.
+	DrainSecondsMin *float64 `json:"drain_seconds_min"`
+	DrainSecondsMax *float64 `json:"drain_seconds_max"`
+	// Time the backlog is estimated to clear in RFC3339 format, or empty string if not draining.
+	DrainAt string `json:"drain_at"`
+	// One of "high", "medium", or "low" based on the variance of the net drain rate
+	// and the number of samples.
+	Confidence string `json:"confidence"`
+	// Window of the samples used for the estimate (e.g. "15m0s").
+	Window string `json:"window"`
+}
+
+// estimateQueueDrain estimates the time for the queue to drain its pending tasks
+// from samples within the window. samples must be ordered by time.
+// It returns nil if there are fewer than two samples in the window.
+func estimateQueueDrain(samples []*queueSample, window time.Duration) *queueDrainEstimate {
+	if len(samples) == 0 {
+		return nil
+	}
+	latest := samples[len(samples)-1]
+	i := 0
+	for i < len(samples) && latest.Time.Sub(samples[i].Time) > window {
+		i++
+	}
+	samples = samples[i:]
+	if len(samples) < 2 {
+		return nil
+	}
+	// Net drain rate (per minute) of each interval between samples.
+	rates := make([]float64, 0, len(samples)-1)
+	var processed int
+	for j := 1; j < len(samples); j++ {
+		prev, cur := samples[j-1], samples[j]
+		mins := cur.Time.Sub(prev.Time).Minutes()
+		if mins <= 0 {
+			continue
+		}
+		n := cur.Processed - prev.Processed
+		if n < 0 {
+			// Daily counter was reset at midnight (UTC).
+			n = cur.Processed
+		}
+		processed += n
+		rates = append(rates, float64(prev.Pending-cur.Pending)/mins)
+	}
+	mins := latest.Time.Sub(samples[0].Time).Minutes()
+	if len(rates) == 0 || mins <= 0 {
+		return nil
+	}
+	var mean, variance float64
+	for _, r := range rates {
+		mean += r
+	}
+	mean /= float64(len(rates))
+	for _, r := range rates {
+		variance += (r - mean) * (r - mean)
+	}
+	if len(rates) > 1 {
+		variance /= float64(len(rates) - 1)
+	}
+	stddev := math.Sqrt(variance)
+
+	e := &queueDrainEstimate{
+		Pending:            latest.Pending,
+		ProcessedPerMinute: float64(processed) / mins,
+		NetDrainPerMinute:  mean,
+		NetDrainStdDev:     stddev,
+		Window:             window.String(),
+	}
+	secs := func(rate float64) *float64 {
+		if latest.Pending == 0 {
+			zero := 0.0
+			return &zero
+		}
+		if rate <= 0 {
+			return nil
+		}
+		s := float64(latest.Pending) / rate * 60
+		return &s
+	}
+	e.DrainSeconds = secs(mean)
+	e.DrainSecondsMin = secs(mean + stddev)
+	e.DrainSecondsMax = secs(mean - stddev)
+	if e.DrainSeconds != nil {
+		e.DrainAt = latest.Time.Add(time.Duration(*e.DrainSeconds * float64(time.Second))).Format(time.RFC3339)
+	}
+
+	// Coefficient of variation of the net drain rate.
+	cv := math.Inf(1)
+	if mean != 0 {
+		cv = stddev / math.Abs(mean)
+	}
+	switch {
+	case len(rates) >= 10 && cv < 0.25:
+		e.Confidence = queueDrainConfidenceHigh
+	case len(rates) >= 4 && cv < 0.75:
+		e.Confidence = queueDrainConfidenceMedium
+	default:
+		e.Confidence = queueDrainConfidenceLow
+	}
+	return e
+}
+
+// drain returns the drain time estimate for the given queue.
+// It returns nil if not enough samples have been collected yet.
+func (s *queueStatsSampler) drain(qname string) *queueDrainEstimate {
+	return estimateQueueDrain(s.recentSamples(qname), queueDrainWindow)
+}
+
+type listQueueDrainResponse struct {
+	Drain map[string]*queueDrainEstimate `json:"drain"`
+}
+
+func newListQueueDrainHandlerFunc(inspector *asynq.Inspector, sampler *queueStatsSampler, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qnames, err := qa.queues(r, inspector)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		resp := listQueueDrainResponse{Drain: make(map[string]*queueDrainEstimate)}
+		for _, qname := range qnames {
+			resp.Drain[qname] = sampler.drain(qname)
+		}
+		writeResponseJSON(w, resp)
+	}
+}
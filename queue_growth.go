@@ -0,0 +1,235 @@
+package asynqmon
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - queueStatsSampler which keeps recent queue stats in memory
+//   - http.Handler(s) for queue growth rate related endpoints
+// ****************************************************************************
+
+const (
+	// Interval between queue stats samples.
+	queueStatsSampleInterval = 15 * time.Second
+
+	// Samples older than this are dropped.
+	queueStatsRetention = 16 * time.Minute
+)
+
+// Windows used to compute growth rates.
+var queueGrowthWindows = []time.Duration{1 * time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// queueSample is a point-in-time snapshot of a queue's counters.
+type queueSample struct {
+	Size      int
+	Pending   int
+	Active    int
+	Processed int
+	Failed    int
+	Time      time.Time
+}
+
+// queueStatsSampler periodically samples stats of every queue and keeps
+// the recent samples in memory so that rates of change can be computed
+// without an external time series database.
+type queueStatsSampler struct {
+	inspector *asynq.Inspector
+	interval  time.Duration
+
+	mu      sync.Mutex
+	samples map[string][]*queueSample // keyed by queue name, ordered by time
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newQueueStatsSampler(inspector *asynq.Inspector, interval time.Duration) *queueStatsSampler {
+	return &queueStatsSampler{
+		inspector: inspector,
+		interval:  interval,
+		samples:   make(map[string][]*queueSample),
+		done:      make(chan struct{}),
+	}
+}
+
+func (s *queueStatsSampler) start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.sample()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.sample()
+			}
+		}
+	}()
+}
+
+func (s *queueStatsSampler) stop() error {
+	close(s.done)
+	s.wg.Wait()
+	return nil
+}
+
+func (s *queueStatsSampler) sample() {
+	qnames, err := s.inspector.Queues()
+	if err != nil {
+		log.Printf("error: could not sample queue stats: %v", err)
+		return
+	}
+	now := time.Now()
+	current := make(map[string]*queueSample, len(qnames))
+	for _, qname := range qnames {
+		info, err := s.inspector.GetQueueInfo(qname)
+		if err != nil {
+			log.Printf("error: could not sample stats for queue %q: %v", qname, err)
+			continue
+		}
+		current[qname] = &queueSample{
+			Size:      info.Size,
+			Pending:   info.Pending,
+			Active:    info.Active,
+			Processed: info.Processed,
+			Failed:    info.Failed,
+			Time:      now,
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for qname, sample := range current {
+		s.samples[qname] = append(s.samples[qname], sample)
+	}
+	// Drop old samples and forget about queues which no longer exist.
+	for qname, xs := range s.samples {
+		i := 0
+		for i < len(xs) && now.Sub(xs[i].Time) > queueStatsRetention {
+			i++
+		}
+		if i == len(xs) {
+			delete(s.samples, qname)
+			continue
+		}
+		s.samples[qname] = xs[i:]
+	}
+}
+
+// recentSamples returns a copy of the samples for the given queue.
+func (s *queueStatsSampler) recentSamples(qname string) []*queueSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*queueSample(nil), s.samples[qname]...)
+}
+
+// growth returns the growth rates for the given queue.
+// It returns nil if not enough samples have been collected yet.
+func (s *queueStatsSampler) growth(qname string) *queueGrowth {
+	return computeQueueGrowth(s.recentSamples(qname), queueGrowthWindows)
+}
+
+type queueGrowthRate struct {
+	// Window used to compute the rates (e.g. "5m0s").
+	Window string `json:"window"`
+	// Approximate number of tasks added to the queue per minute.
+	EnqueuedPerMinute float64 `json:"enqueued_per_minute"`
+	// Number of tasks processed per minute.
+	ProcessedPerMinute float64 `json:"processed_per_minute"`
+	// Change in queue size per minute; positive if the queue is filling up.
+	SizeDeltaPerMinute float64 `json:"size_delta_per_minute"`
+}
+
+// Values used for queueGrowth.Trend.
+const (
+	queueTrendFilling  = "filling"
+	queueTrendDraining = "draining"
+	queueTrendSteady   = "steady"
+)
+
+type queueGrowth struct {
+	// Trend is one of "filling", "draining", or "steady" based on the longest window available.
+	Trend string             `json:"trend"`
+	Rates []*queueGrowthRate `json:"rates"`
+}
+
+// computeQueueGrowth computes growth rates over each of the given windows.
+// samples must be ordered by time. It returns nil if there are fewer than two samples.
+func computeQueueGrowth(samples []*queueSample, windows []time.Duration) *queueGrowth {
+	if len(samples) < 2 {
+		return nil
+	}
+	latest := samples[len(samples)-1]
+	g := &queueGrowth{Trend: queueTrendSteady, Rates: make([]*queueGrowthRate, 0, len(windows))}
+	for _, w := range windows {
+		// Find the oldest sample within the window.
+		var base *queueSample
+		for _, s := range samples[:len(samples)-1] {
+			if latest.Time.Sub(s.Time) <= w {
+				base = s
+				break
+			}
+		}
+		if base == nil {
+			continue
+		}
+		mins := latest.Time.Sub(base.Time).Minutes()
+		if mins <= 0 {
+			continue
+		}
+		processed := latest.Processed - base.Processed
+		if processed < 0 {
+			// Daily counter was reset at midnight (UTC).
+			processed = latest.Processed
+		}
+		sizeDelta := latest.Size - base.Size
+		enqueued := sizeDelta + processed
+		if enqueued < 0 {
+			enqueued = 0
+		}
+		g.Rates = append(g.Rates, &queueGrowthRate{
+			Window:             w.String(),
+			EnqueuedPerMinute:  float64(enqueued) / mins,
+			ProcessedPerMinute: float64(processed) / mins,
+			SizeDeltaPerMinute: float64(sizeDelta) / mins,
+		})
+	}
+	if len(g.Rates) == 0 {
+		return nil
+	}
+	switch d := g.Rates[len(g.Rates)-1].SizeDeltaPerMinute; {
+	case d > 0:
+		g.Trend = queueTrendFilling
+	case d < 0:
+		g.Trend = queueTrendDraining
+	}
+	return g
+}
+
+type listQueueGrowthResponse struct {
+	Growth map[string]*queueGrowth `json:"growth"`
+}
+
+func newListQueueGrowthHandlerFunc(inspector *asynq.Inspector, sampler *queueStatsSampler, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qnames, err := qa.queues(r, inspector)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		resp := listQueueGrowthResponse{Growth: make(map[string]*queueGrowth)}
+		for _, qname := range qnames {
+			resp.Growth[qname] = sampler.growth(qname)
+		}
+		writeResponseJSON(w, resp)
+	}
+}
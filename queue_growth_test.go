@@ -0,0 +1,107 @@
+package asynqmon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestComputeQueueGrowth(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	sample := func(offset time.Duration, size, processed int) *queueSample {
+		return &queueSample{Size: size, Processed: processed, Time: t0.Add(offset)}
+	}
+	windows := []time.Duration{time.Minute, 5 * time.Minute}
+
+	tests := []struct {
+		desc    string
+		samples []*queueSample
+		windows []time.Duration
+		want    *queueGrowth
+	}{
+		{
+			desc:    "no samples",
+			windows: windows,
+			want:    nil,
+		},
+		{
+			desc:    "single sample",
+			samples: []*queueSample{sample(0, 10, 0)},
+			windows: windows,
+			want:    nil,
+		},
+		{
+			desc:    "filling",
+			samples: []*queueSample{sample(0, 10, 0), sample(time.Minute, 40, 20)},
+			windows: windows,
+			want: &queueGrowth{Trend: queueTrendFilling, Rates: []*queueGrowthRate{
+				{Window: "1m0s", EnqueuedPerMinute: 50, ProcessedPerMinute: 20, SizeDeltaPerMinute: 30},
+				{Window: "5m0s", EnqueuedPerMinute: 50, ProcessedPerMinute: 20, SizeDeltaPerMinute: 30},
+			}},
+		},
+		{
+			desc:    "draining over the longest window",
+			samples: []*queueSample{sample(0, 100, 0), sample(4*time.Minute, 40, 60), sample(5*time.Minute, 45, 60)},
+			windows: windows,
+			want: &queueGrowth{Trend: queueTrendDraining, Rates: []*queueGrowthRate{
+				{Window: "1m0s", EnqueuedPerMinute: 5, ProcessedPerMinute: 0, SizeDeltaPerMinute: 5},
+				{Window: "5m0s", EnqueuedPerMinute: 1, ProcessedPerMinute: 12, SizeDeltaPerMinute: -11},
+			}},
+		},
+		{
+			desc:    "steady",
+			samples: []*queueSample{sample(0, 10, 0), sample(time.Minute, 10, 30)},
+			windows: windows[:1],
+			want: &queueGrowth{Trend: queueTrendSteady, Rates: []*queueGrowthRate{
+				{Window: "1m0s", EnqueuedPerMinute: 30, ProcessedPerMinute: 30, SizeDeltaPerMinute: 0},
+			}},
+		},
+		{
+			desc:    "tasks deleted without processing",
+			samples: []*queueSample{sample(0, 100, 0), sample(time.Minute, 40, 0)},
+			windows: windows[:1],
+			want: &queueGrowth{Trend: queueTrendDraining, Rates: []*queueGrowthRate{
+				{Window: "1m0s", EnqueuedPerMinute: 0, ProcessedPerMinute: 0, SizeDeltaPerMinute: -60},
+			}},
+		},
+		{
+			desc:    "daily processed counter reset",
+			samples: []*queueSample{sample(0, 10, 500), sample(time.Minute, 10, 20)},
+			windows: windows[:1],
+			want: &queueGrowth{Trend: queueTrendSteady, Rates: []*queueGrowthRate{
+				{Window: "1m0s", EnqueuedPerMinute: 20, ProcessedPerMinute: 20, SizeDeltaPerMinute: 0},
+			}},
+		},
+		{
+			desc:    "samples older than every window",
+			samples: []*queueSample{sample(0, 10, 0), sample(10*time.Minute, 20, 0)},
+			windows: windows,
+			want:    nil,
+		},
+		{
+			desc:    "samples at the same time",
+			samples: []*queueSample{sample(0, 10, 0), sample(0, 20, 0)},
+			windows: windows,
+			want:    nil,
+		},
+		{
+			desc:    "samples out of order",
+			samples: []*queueSample{sample(time.Minute, 10, 0), sample(0, 20, 0)},
+			windows: windows,
+			want:    nil,
+		},
+		{
+			desc:    "zero and negative windows",
+			samples: []*queueSample{sample(0, 10, 0), sample(time.Minute, 20, 0)},
+			windows: []time.Duration{0, -time.Minute},
+			want:    nil,
+		},
+	}
+	for _, tc := range tests {
+		got := computeQueueGrowth(tc.samples, tc.windows)
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%s: computeQueueGrowth returned diff (-want,+got):\n%s", tc.desc, diff)
+		}
+	}
+}
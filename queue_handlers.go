@@ -2,7 +2,6 @@ package asynqmon
 
 import (
 	"encoding/json"
-	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -15,37 +14,48 @@ import (
 //   - http.Handler(s) for queue related endpoints
 // ****************************************************************************
 
-func newListQueuesHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+func newListQueuesHandlerFunc(inspector *asynq.Inspector, cache *queueInfoCache, sampler *queueStatsSampler, qa *queueAuthorizer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		qnames, err := inspector.Queues()
+		fields, err := parseFields(r, queueStateSnapshot{}, "queue")
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		qnames, err := qa.queues(r, inspector)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 		snapshots := make([]*queueStateSnapshot, len(qnames))
 		for i, qname := range qnames {
-			qinfo, err := inspector.GetQueueInfo(qname)
+			qinfo, err := cache.get(qname)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeError(w, r, err)
 				return
 			}
 			snapshots[i] = toQueueStateSnapshot(qinfo)
+			snapshots[i].Growth = sampler.growth(qname)
+			snapshots[i].Drain = sampler.drain(qname)
+		}
+		selected, err := selectFields(snapshots, fields)
+		if err != nil {
+			writeError(w, r, err)
+			return
 		}
-		payload := map[string]interface{}{"queues": snapshots}
+		payload := map[string]interface{}{"queues": selected}
 		json.NewEncoder(w).Encode(payload)
 	}
 }
 
-func newGetQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+func newGetQueueHandlerFunc(inspector *asynq.Inspector, cache *queueInfoCache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		qname := vars["qname"]
 
 		payload := make(map[string]interface{})
-		qinfo, err := inspector.GetQueueInfo(qname)
+		qinfo, err := cache.get(qname)
 		if err != nil {
-			// TODO: Check for queue not found error.
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		payload["current"] = toQueueStateSnapshot(qinfo)
@@ -53,7 +63,7 @@ func newGetQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 		// TODO: make this n a variable
 		data, err := inspector.History(qname, 10)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		var dailyStats []*dailyStats
@@ -70,15 +80,8 @@ func newDeleteQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 		vars := mux.Vars(r)
 		qname := vars["qname"]
 		if err := inspector.DeleteQueue(qname, false); err != nil {
-			if errors.Is(err, asynq.ErrQueueNotFound) {
-				http.Error(w, err.Error(), http.StatusNotFound)
-				return
-			}
-			if errors.Is(err, asynq.ErrQueueNotEmpty) {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			// Responds with 404 if the queue is not found, and 400 if the queue is not empty.
+			writeError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -90,7 +93,7 @@ func newPauseQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 		vars := mux.Vars(r)
 		qname := vars["qname"]
 		if err := inspector.PauseQueue(qname); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -102,7 +105,7 @@ func newResumeQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 		vars := mux.Vars(r)
 		qname := vars["qname"]
 		if err := inspector.UnpauseQueue(qname); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -113,11 +116,11 @@ type listQueueStatsResponse struct {
 	Stats map[string][]*dailyStats `json:"stats"`
 }
 
-func newListQueueStatsHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+func newListQueueStatsHandlerFunc(inspector *asynq.Inspector, qa *queueAuthorizer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		qnames, err := inspector.Queues()
+		qnames, err := qa.queues(r, inspector)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		resp := listQueueStatsResponse{Stats: make(map[string][]*dailyStats)}
@@ -125,13 +128,13 @@ func newListQueueStatsHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 		for _, qname := range qnames {
 			stats, err := inspector.History(qname, numdays)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeError(w, r, err)
 				return
 			}
 			resp.Stats[qname] = toDailyStatsList(stats)
 		}
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 	}
@@ -0,0 +1,207 @@
+package asynqmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - queueInfoCache which caches queue stats in memory
+//   - keyspaceListener which invalidates the cache on redis keyspace notifications
+// ****************************************************************************
+
+// queueInfoCache caches the results of Inspector.GetQueueInfo for a short
+// duration so that concurrent dashboards polling the server do not each
+// scan redis. Entries are invalidated when the queue is mutated through
+// the API or when keyspace notifications report a change to the queue.
+//
+// A cache with zero ttl does not cache anything.
+type queueInfoCache struct {
+	inspector *asynq.Inspector
+	ttl       time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*queueInfoCacheEntry // keyed by queue name
+}
+
+type queueInfoCacheEntry struct {
+	info      *asynq.QueueInfo
+	fetchedAt time.Time
+}
+
+func newQueueInfoCache(inspector *asynq.Inspector, ttl time.Duration) *queueInfoCache {
+	return &queueInfoCache{
+		inspector: inspector,
+		ttl:       ttl,
+		entries:   make(map[string]*queueInfoCacheEntry),
+	}
+}
+
+// get returns the cached info of the given queue, or fetches it from redis
+// if the entry is missing or expired.
+func (c *queueInfoCache) get(qname string) (*asynq.QueueInfo, error) {
+	if c.ttl <= 0 {
+		return c.inspector.GetQueueInfo(qname)
+	}
+	c.mu.Lock()
+	e, ok := c.entries[qname]
+	c.mu.Unlock()
+	if ok && time.Since(e.fetchedAt) < c.ttl {
+		return e.info, nil
+	}
+	fetchedAt := time.Now()
+	info, err := c.inspector.GetQueueInfo(qname)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Do not overwrite an entry fetched after this one started.
+	if cur, ok := c.entries[qname]; !ok || cur.fetchedAt.Before(fetchedAt) {
+		c.entries[qname] = &queueInfoCacheEntry{info: info, fetchedAt: fetchedAt}
+	}
+	return info, nil
+}
+
+func (c *queueInfoCache) invalidate(qname string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, qname)
+}
+
+func (c *queueInfoCache) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*queueInfoCacheEntry)
+}
+
+// middleware invalidates the cached info of the queue targeted by
+// a mutating request, so that the change is visible immediately.
+func (c *queueInfoCache) middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+		if c.ttl <= 0 || r.Method == "GET" || r.Method == "" {
+			return
+		}
+		if qname, ok := mux.Vars(r)["qname"]; ok {
+			c.invalidate(qname)
+		}
+	})
+}
+
+// Prefix of the keys used by asynq, followed by the queue name in braces.
+const asynqKeyPrefix = "asynq:{"
+
+// Key of the set holding all queue names.
+const asynqAllQueuesKey = "asynq:queues"
+
+// keyspaceListener subscribes to redis keyspace notifications of asynq keys
+// and invalidates the corresponding cache entries.
+//
+// Redis must be configured to publish keyspace events
+// (e.g. "CONFIG SET notify-keyspace-events KA").
+type keyspaceListener struct {
+	cache   *queueInfoCache
+	pubsubs []*redis.PubSub
+	wg      sync.WaitGroup
+}
+
+// newKeyspaceListener subscribes to keyspace notifications of asynq keys.
+// When connected to redis cluster, it subscribes to every master node since
+// keyspace notifications are not propagated across the cluster.
+func newKeyspaceListener(rc redis.UniversalClient, cache *queueInfoCache) (*keyspaceListener, error) {
+	ctx := context.Background()
+	l := &keyspaceListener{cache: cache}
+	switch c := rc.(type) {
+	case *redis.Client:
+		checkKeyspaceEventsConfig(ctx, c)
+		pattern := fmt.Sprintf("__keyspace@%d__:asynq:*", c.Options().DB)
+		l.pubsubs = append(l.pubsubs, c.PSubscribe(ctx, pattern))
+	case *redis.ClusterClient:
+		var mu sync.Mutex
+		err := c.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			checkKeyspaceEventsConfig(ctx, node)
+			ps := node.PSubscribe(ctx, "__keyspace@0__:asynq:*")
+			mu.Lock()
+			defer mu.Unlock()
+			l.pubsubs = append(l.pubsubs, ps)
+			return nil
+		})
+		if err != nil {
+			l.close()
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("keyspace notifications are not supported for %T", rc)
+	}
+	for _, ps := range l.pubsubs {
+		// Wait for the subscription to be confirmed so that errors surface early.
+		if _, err := ps.Receive(ctx); err != nil {
+			l.close()
+			return nil, fmt.Errorf("could not subscribe to keyspace notifications: %v", err)
+		}
+	}
+	for _, ps := range l.pubsubs {
+		l.wg.Add(1)
+		go func(ps *redis.PubSub) {
+			defer l.wg.Done()
+			for msg := range ps.Channel() {
+				l.handle(msg.Channel)
+			}
+		}(ps)
+	}
+	return l, nil
+}
+
+// checkKeyspaceEventsConfig logs a warning if redis is not configured to
+// publish keyspace events. Errors are ignored since CONFIG may be disabled.
+func checkKeyspaceEventsConfig(ctx context.Context, c *redis.Client) {
+	res, err := c.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return
+	}
+	if v := res["notify-keyspace-events"]; !strings.Contains(v, "K") {
+		log.Printf("warning: keyspace notifications are not enabled on redis %s (notify-keyspace-events=%q); cached queue stats are invalidated only by TTL", c.Options().Addr, v)
+	}
+}
+
+// handle invalidates the cache entry of the queue the changed key belongs to.
+func (l *keyspaceListener) handle(channel string) {
+	i := strings.Index(channel, "__:")
+	if i < 0 {
+		return
+	}
+	key := channel[i+len("__:"):]
+	if key == asynqAllQueuesKey {
+		l.cache.invalidateAll()
+		return
+	}
+	if !strings.HasPrefix(key, asynqKeyPrefix) {
+		return
+	}
+	key = key[len(asynqKeyPrefix):]
+	if j := strings.Index(key, "}"); j >= 0 {
+		l.cache.invalidate(key[:j])
+	}
+}
+
+func (l *keyspaceListener) close() error {
+	var firstErr error
+	for _, ps := range l.pubsubs {
+		if err := ps.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	l.wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,275 @@
+package asynqmon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - redisLatencyMonitor which keeps recent PING latencies in memory
+//   - http.Handler(s) for redis latency related endpoints
+// ****************************************************************************
+
+const (
+	// Interval between PING latency samples.
+	redisLatencySampleInterval = 10 * time.Second
+
+	// Samples older than this are dropped.
+	redisLatencyRetention = 1 * time.Hour
+
+	// Timeout for a single PING.
+	redisLatencyPingTimeout = 5 * time.Second
+)
+
+// redisLatencySample is the result of a single PING.
+type redisLatencySample struct {
+	Time    time.Time
+	Latency time.Duration
+	Err     error
+}
+
+// redisLatencyMonitor periodically measures the round trip time of PING
+// commands sent to redis and keeps the recent samples in memory.
+//
+// When connected to redis cluster, PING is sent to a single node.
+type redisLatencyMonitor struct {
+	client   redis.UniversalClient
+	interval time.Duration
+
+	mu      sync.Mutex
+	samples []*redisLatencySample // ordered by time
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newRedisLatencyMonitor(client redis.UniversalClient, interval time.Duration) *redisLatencyMonitor {
+	return &redisLatencyMonitor{
+		client:   client,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+func (m *redisLatencyMonitor) start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.sample()
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.done:
+				return
+			case <-ticker.C:
+				m.sample()
+			}
+		}
+	}()
+}
+
+func (m *redisLatencyMonitor) stop() error {
+	close(m.done)
+	m.wg.Wait()
+	return nil
+}
+
+func (m *redisLatencyMonitor) sample() {
+	ctx, cancel := context.WithTimeout(context.Background(), redisLatencyPingTimeout)
+	defer cancel()
+	start := time.Now()
+	err := m.client.Ping(ctx).Err()
+	m.add(&redisLatencySample{Time: start, Latency: time.Since(start), Err: err})
+}
+
+func (m *redisLatencyMonitor) add(s *redisLatencySample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples = append(m.samples, s)
+	i := 0
+	for i < len(m.samples) && s.Time.Sub(m.samples[i].Time) > redisLatencyRetention {
+		i++
+	}
+	m.samples = m.samples[i:]
+}
+
+// recentSamples returns a copy of the samples.
+func (m *redisLatencyMonitor) recentSamples() []*redisLatencySample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*redisLatencySample(nil), m.samples...)
+}
+
+type redisPingSample struct {
+	// Time when PING was sent in RFC3339 format.
+	Time string `json:"time"`
+	// Round trip time in milliseconds.
+	LatencyMillis float64 `json:"latency_ms"`
+	// Error message if PING failed.
+	Error string `json:"error,omitempty"`
+}
+
+type redisPingStats struct {
+	// Stats are computed over successful samples only.
+	MinMillis  float64 `json:"min_ms"`
+	AvgMillis  float64 `json:"avg_ms"`
+	MaxMillis  float64 `json:"max_ms"`
+	LastMillis float64 `json:"last_ms"`
+	// Number of failed PINGs.
+	Errors int `json:"errors"`
+}
+
+// redisLatencyEvent is an entry of LATENCY LATEST command output
+// along with the history of the event.
+type redisLatencyEvent struct {
+	Event string `json:"event"`
+	// Time of the latest spike in RFC3339 format.
+	Time         string                    `json:"time"`
+	LatestMillis int64                     `json:"latest_ms"`
+	MaxMillis    int64                     `json:"max_ms"`
+	History      []*redisLatencyEventPoint `json:"history"`
+}
+
+type redisLatencyEventPoint struct {
+	// Time of the spike in RFC3339 format.
+	Time          string `json:"time"`
+	LatencyMillis int64  `json:"latency_ms"`
+}
+
+type redisLatencyResponse struct {
+	PingSamples []*redisPingSample `json:"ping_samples"`
+	// Nil if no PING succeeded yet.
+	PingStats *redisPingStats `json:"ping_stats"`
+
+	// Output of LATENCY LATEST, LATENCY HISTORY and LATENCY DOCTOR commands.
+	// Events is empty unless latency-monitor-threshold is configured in redis.
+	Events []*redisLatencyEvent `json:"events"`
+	Doctor string               `json:"doctor"`
+	// Error message if LATENCY commands could not be run
+	// (e.g. the commands are disabled by the redis provider).
+	LatencyError string `json:"latency_error,omitempty"`
+}
+
+func computeRedisPingStats(samples []*redisLatencySample) *redisPingStats {
+	var (
+		stats redisPingStats
+		total float64
+		n     int
+	)
+	for _, s := range samples {
+		if s.Err != nil {
+			stats.Errors++
+			continue
+		}
+		ms := durationMillis(s.Latency)
+		if n == 0 || ms < stats.MinMillis {
+			stats.MinMillis = ms
+		}
+		if ms > stats.MaxMillis {
+			stats.MaxMillis = ms
+		}
+		stats.LastMillis = ms
+		total += ms
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	stats.AvgMillis = total / float64(n)
+	return &stats
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// latencyEvents runs LATENCY LATEST and LATENCY HISTORY for each reported event.
+func latencyEvents(ctx context.Context, client redis.UniversalClient) ([]*redisLatencyEvent, error) {
+	res, err := client.Do(ctx, "LATENCY", "LATEST").Slice()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*redisLatencyEvent, 0, len(res))
+	for _, x := range res {
+		// Each entry is [event-name, unix-time, latest-ms, max-ms].
+		entry, ok := x.([]interface{})
+		if !ok || len(entry) < 4 {
+			return nil, fmt.Errorf("unexpected LATENCY LATEST entry: %v", x)
+		}
+		name, _ := entry[0].(string)
+		ts, _ := entry[1].(int64)
+		latestMs, _ := entry[2].(int64)
+		maxMs, _ := entry[3].(int64)
+		e := &redisLatencyEvent{
+			Event:        name,
+			Time:         time.Unix(ts, 0).UTC().Format(time.RFC3339),
+			LatestMillis: latestMs,
+			MaxMillis:    maxMs,
+			History:      []*redisLatencyEventPoint{},
+		}
+		hist, err := client.Do(ctx, "LATENCY", "HISTORY", name).Slice()
+		if err != nil {
+			return nil, err
+		}
+		for _, y := range hist {
+			// Each point is [unix-time, latency-ms].
+			p, ok := y.([]interface{})
+			if !ok || len(p) < 2 {
+				continue
+			}
+			ts, _ := p[0].(int64)
+			ms, _ := p[1].(int64)
+			e.History = append(e.History, &redisLatencyEventPoint{
+				Time:          time.Unix(ts, 0).UTC().Format(time.RFC3339),
+				LatencyMillis: ms,
+			})
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func newGetRedisLatencyHandlerFunc(client redis.UniversalClient, monitor *redisLatencyMonitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		samples := monitor.recentSamples()
+		resp := redisLatencyResponse{
+			PingSamples: make([]*redisPingSample, len(samples)),
+			PingStats:   computeRedisPingStats(samples),
+			Events:      []*redisLatencyEvent{},
+		}
+		for i, s := range samples {
+			ps := &redisPingSample{
+				Time:          s.Time.UTC().Format(time.RFC3339),
+				LatencyMillis: durationMillis(s.Latency),
+			}
+			if s.Err != nil {
+				ps.Error = s.Err.Error()
+			}
+			resp.PingSamples[i] = ps
+		}
+
+		// LATENCY commands are often disabled by managed redis providers,
+		// so report the error instead of failing the request.
+		ctx := r.Context()
+		events, err := latencyEvents(ctx, client)
+		if err != nil {
+			resp.LatencyError = err.Error()
+			writeResponseJSON(w, resp)
+			return
+		}
+		resp.Events = events
+		doctor, err := client.Do(ctx, "LATENCY", "DOCTOR").Text()
+		if err != nil {
+			resp.LatencyError = err.Error()
+		}
+		resp.Doctor = doctor
+		writeResponseJSON(w, resp)
+	}
+}
@@ -0,0 +1,71 @@
+package asynqmon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - withRedisTimeout which bounds the time each redis command can take
+// ****************************************************************************
+
+// Default value for Options.RedisTimeout.
+const defaultRedisTimeout = 5 * time.Second
+
+// Name of the routes which do not talk to redis.
+// These routes are not subject to the circuit breaker.
+const nonRedisRouteName = "non-redis"
+
+// Name of the routes which stream their responses as they read from redis.
+const streamingRouteName = "streaming"
+
+// withRedisTimeout returns a copy of opt whose redis clients fail a command with a
+// timeout error if redis does not reply within the timeout.
+//
+// The timeout is set as the read and write timeouts of the connections, so it applies
+// to every command, including the ones issued by the Inspector and the Client of asynq
+// which do not accept a context, and the handler stops waiting on a stuck command.
+// API requests which fail with the error are responded with 504 Gateway Timeout
+// (see classifyError).
+//
+// A timeout already configured in opt is kept if it is shorter.
+func withRedisTimeout(opt asynq.RedisConnOpt, timeout time.Duration) asynq.RedisConnOpt {
+	switch o := opt.(type) {
+	case asynq.RedisClientOpt:
+		o.ReadTimeout, o.WriteTimeout = minTimeout(o.ReadTimeout, timeout), minTimeout(o.WriteTimeout, timeout)
+		return o
+	case asynq.RedisFailoverClientOpt:
+		o.ReadTimeout, o.WriteTimeout = minTimeout(o.ReadTimeout, timeout), minTimeout(o.WriteTimeout, timeout)
+		return o
+	case asynq.RedisClusterClientOpt:
+		o.ReadTimeout, o.WriteTimeout = minTimeout(o.ReadTimeout, timeout), minTimeout(o.WriteTimeout, timeout)
+		return o
+	}
+	// Other implementations configure their own timeouts.
+	return opt
+}
+
+// minTimeout returns the shorter of the configured timeout d and the timeout.
+// A zero or negative d is the default or no timeout, which are not shorter.
+func minTimeout(d, timeout time.Duration) time.Duration {
+	if d > 0 && d < timeout {
+		return d
+	}
+	return timeout
+}
+
+// isRedisTimeout reports whether err indicates that a redis command timed out.
+func isRedisTimeout(err error) bool {
+	var netErr net.Error
+	if (errors.As(err, &netErr) && netErr.Timeout()) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	// Some errors are wrapped with %v by asynq.
+	return strings.Contains(err.Error(), "i/o timeout")
+}
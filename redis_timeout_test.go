@@ -0,0 +1,101 @@
+package asynqmon
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// newStuckRedis starts a server which accepts redis connections but never replies to
+// commands other than HELLO, like a redis server blocked by a slow script.
+func newStuckRedis(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					// Let the client fall back to RESP2 without waiting.
+					if strings.EqualFold(strings.TrimSpace(line), "hello") {
+						conn.Write([]byte("-ERR unknown command 'HELLO'\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestRedisTimeoutInspector(t *testing.T) {
+	addr := newStuckRedis(t)
+	inspector := asynq.NewInspector(withRedisTimeout(asynq.RedisClientOpt{Addr: addr}, 100*time.Millisecond))
+	defer inspector.Close()
+
+	tests := []struct {
+		desc string
+		op   func() error
+	}{
+		{"GetQueueInfo", func() error { _, err := inspector.GetQueueInfo("default"); return err }},
+		{"DeleteTask", func() error { return inspector.DeleteTask("default", "task1") }},
+		{"ArchiveTask", func() error { return inspector.ArchiveTask("default", "task1") }},
+		{"RunTask", func() error { return inspector.RunTask("default", "task1") }},
+		{"PauseQueue", func() error { return inspector.PauseQueue("default") }},
+	}
+	for _, tc := range tests {
+		start := time.Now()
+		err := tc.op()
+		if err == nil || !isRedisTimeout(err) {
+			t.Errorf("%s returned error %v, want a redis timeout", tc.desc, err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%s returned after %v, want the command to be canceled at the timeout", tc.desc, elapsed)
+		}
+	}
+}
+
+func TestRedisTimeoutMutatingRequest(t *testing.T) {
+	addr := newStuckRedis(t)
+	h := New(Options{
+		RedisConnOpt:            asynq.RedisClientOpt{Addr: addr},
+		RedisTimeout:            100 * time.Millisecond,
+		CircuitBreakerThreshold: -1,
+	})
+	defer h.Close()
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/queues/default/pending_tasks/task1", nil))
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("DELETE request returned after %v, want the request to fail at the timeout", elapsed)
+	}
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("DELETE request responded with %d, want %d: %s", w.Code, http.StatusGatewayTimeout, w.Body)
+	}
+	var resp problemDetails
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != errCodeRedisTimeout {
+		t.Errorf("DELETE request responded with error code %q, want %q", resp.Code, errCodeRedisTimeout)
+	}
+}
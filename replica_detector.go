@@ -0,0 +1,135 @@
+package asynqmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - replicaDetector which disables mutations while connected to a read-only replica
+// ****************************************************************************
+
+// Interval between replication role checks.
+const replicaCheckInterval = 30 * time.Second
+
+// replicaDetector periodically checks the replication role of the connected
+// redis server. While connected to a read-only replica (e.g. after a failover
+// misconfiguration), mutating API requests are rejected up front instead of
+// failing with READONLY errors from redis.
+//
+// It is not used with redis cluster, since the cluster client sends writes to masters.
+type replicaDetector struct {
+	client   *redis.Client
+	interval time.Duration
+
+	mu         sync.RWMutex
+	readOnly   bool
+	masterAddr string // address of the master the replica follows
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newReplicaDetector(client *redis.Client, interval time.Duration) *replicaDetector {
+	return &replicaDetector{
+		client:   client,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+func (d *replicaDetector) start() {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.check()
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.done:
+				return
+			case <-ticker.C:
+				d.check()
+			}
+		}
+	}()
+}
+
+func (d *replicaDetector) stop() error {
+	close(d.done)
+	d.wg.Wait()
+	return nil
+}
+
+func (d *replicaDetector) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := d.client.Info(ctx, "replication").Result()
+	if err != nil {
+		// Keep the last known state; redis errors are reported by the API handlers.
+		return
+	}
+	info := parseRedisInfo(res)
+	readOnly := info["role"] == "slave" && info["slave_read_only"] != "0"
+	masterAddr := ""
+	if readOnly {
+		masterAddr = net.JoinHostPort(info["master_host"], info["master_port"])
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if readOnly != d.readOnly {
+		if readOnly {
+			log.Printf("warning: connected to read-only replica of %s; mutating API requests are disabled", masterAddr)
+		} else {
+			log.Printf("info: no longer connected to a read-only replica; mutating API requests are enabled")
+		}
+	}
+	d.readOnly = readOnly
+	d.masterAddr = masterAddr
+}
+
+// state reports whether the connected redis server is a read-only replica,
+// and the address of its master if so. It is safe to call on a nil detector.
+func (d *replicaDetector) state() (readOnly bool, masterAddr string) {
+	if d == nil {
+		return false, ""
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.readOnly, d.masterAddr
+}
+
+// middleware rejects mutating requests while connected to a read-only replica.
+func (d *replicaDetector) middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil && (route.GetName() == nonRedisRouteName || route.GetName() == maintenanceRouteName) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if r.Method != "GET" && r.Method != "" {
+			if readOnly, masterAddr := d.state(); readOnly {
+				writeErrorResponse(w, r, http.StatusServiceUnavailable, errCodeRedisReadOnly,
+					fmt.Sprintf("connected to a read-only redis replica of %s: %s request is not allowed", masterAddr, r.Method))
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// isRedisReadOnly reports whether err is a READONLY error returned by a replica.
+func isRedisReadOnly(err error) bool {
+	// asynq wraps some errors with %v, so check the message.
+	return strings.Contains(err.Error(), "READONLY")
+}
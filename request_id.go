@@ -0,0 +1,63 @@
+package asynqmon
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - middleware which assigns an ID to each API request
+// ****************************************************************************
+
+// Header used to receive and send request IDs.
+const requestIDHeader = "X-Request-ID"
+
+// Maximum length of a request ID accepted from the client.
+const maxRequestIDLen = 128
+
+type requestIDKey struct{}
+
+// withRequestID is a middleware which assigns an ID to each request.
+// The ID is taken from the X-Request-ID request header if present (e.g. set by a proxy),
+// otherwise a random ID is generated. The ID is sent back in the X-Request-ID response header.
+func withRequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !isValidRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestIDFromContext returns the request ID stored in ctx, or an empty string if none.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// isValidRequestID reports whether id is non-empty, not too long and only contains
+// printable ASCII characters, so that it's safe to echo back.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package asynqmon
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - helpers to detect the URL path prefix the handler is mounted at
+// ****************************************************************************
+
+type strippedPrefixKey struct{}
+
+// withStrippedPrefix returns a shallow copy of r with the URL path prefix that was
+// removed before the request reached the handler (e.g. by http.StripPrefix) stored
+// in its context. The URL path of the returned request always starts with a slash.
+func withStrippedPrefix(r *http.Request) *http.Request {
+	return withPrefix(r, strippedPrefix(r))
+}
+
+// withMountPrefix returns a shallow copy of r with the URL path prefix the handler is
+// mounted at stored in its context. The prefix is removed from the URL path if the path
+// starts with it, since routers such as chi's Mount and gorilla/mux subrouters pass the
+// full path to the handler.
+func withMountPrefix(r *http.Request, prefix string) *http.Request {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" && (r.URL.Path == prefix || strings.HasPrefix(r.URL.Path, prefix+"/")) {
+		u := *r.URL
+		u.Path = strings.TrimPrefix(u.Path, prefix)
+		u.RawPath = ""
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = &u
+		r = r2
+	}
+	return withPrefix(r, prefix)
+}
+
+// withPrefix returns a shallow copy of r with the URL path prefix stored in its context.
+// The URL path of the returned request always starts with a slash.
+func withPrefix(r *http.Request, prefix string) *http.Request {
+	r2 := r.WithContext(context.WithValue(r.Context(), strippedPrefixKey{}, prefix))
+	if !strings.HasPrefix(r2.URL.Path, "/") {
+		u := *r.URL
+		u.Path = "/" + u.Path
+		u.RawPath = ""
+		r2.URL = &u
+	}
+	return r2
+}
+
+// strippedPrefix compares the original request URI with the URL path of r and
+// returns the prefix which was removed from the path, without the trailing slash.
+// It returns an empty string if no prefix was removed.
+func strippedPrefix(r *http.Request) string {
+	u, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil || !strings.HasSuffix(u.Path, r.URL.Path) {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(u.Path, r.URL.Path), "/")
+}
+
+// externalRootPath returns the root path of the application as seen by the browser.
+func externalRootPath(r *http.Request, rootPath string) string {
+	prefix, _ := r.Context().Value(strippedPrefixKey{}).(string)
+	return prefix + rootPath
+}
@@ -0,0 +1,101 @@
+package asynqmon
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+)
+
+type mountPointKey struct{}
+
+// withMountPoint mounts h at prefix without stripping it from the URL path, and stores
+// the mount point in the request context, as chi's Mount does with its routing context.
+func withMountPoint(prefix string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, prefix+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), mountPointKey{}, prefix)))
+	})
+}
+
+func TestMountingStyles(t *testing.T) {
+	mr := miniredis.RunT(t)
+	newHandler := func(opts Options) *HTTPHandler {
+		opts.RedisConnOpt = asynq.RedisClientOpt{Addr: mr.Addr()}
+		h := New(opts)
+		t.Cleanup(func() { h.Close() })
+		return h
+	}
+	fixedRootPath := func(prefix string) func(*http.Request) string {
+		return func(*http.Request) string { return prefix }
+	}
+
+	tests := []struct {
+		desc    string
+		handler http.Handler
+		// Root path of the Web UI as seen by the browser.
+		rootPath string
+	}{
+		{
+			desc:     "http.StripPrefix with DetectRootPath",
+			handler:  http.StripPrefix("/monitoring", newHandler(Options{DetectRootPath: true})),
+			rootPath: "/monitoring",
+		},
+		{
+			desc:     "http.StripPrefix with RootPathFunc",
+			handler:  http.StripPrefix("/monitoring", newHandler(Options{RootPathFunc: fixedRootPath("/monitoring")})),
+			rootPath: "/monitoring",
+		},
+		{
+			desc: "gorilla/mux subrouter with RootPathFunc",
+			handler: func() http.Handler {
+				r := mux.NewRouter()
+				r.PathPrefix("/monitoring/").Handler(newHandler(Options{RootPathFunc: fixedRootPath("/monitoring/")}))
+				return r
+			}(),
+			rootPath: "/monitoring",
+		},
+		{
+			desc: "chi style mount with RootPathFunc reading the request context",
+			handler: withMountPoint("/monitoring", newHandler(Options{RootPathFunc: func(r *http.Request) string {
+				prefix, _ := r.Context().Value(mountPointKey{}).(string)
+				return prefix
+			}})),
+			rootPath: "/monitoring",
+		},
+		{
+			desc: "gorilla/mux subrouter with RootPathFunc and RootPath",
+			handler: func() http.Handler {
+				r := mux.NewRouter()
+				r.PathPrefix("/admin/").Handler(newHandler(Options{RootPath: "/asynqmon", RootPathFunc: fixedRootPath("/admin")}))
+				return r
+			}(),
+			rootPath: "/admin/asynqmon",
+		},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		tc.handler.ServeHTTP(w, httptest.NewRequest("GET", tc.rootPath+"/", nil))
+		body, _ := io.ReadAll(w.Body)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: GET %s/ responded with %d", tc.desc, tc.rootPath, w.Code)
+		} else if want := `href="` + tc.rootPath + `/favicon.ico"`; !strings.Contains(string(body), want) {
+			t.Errorf("%s: index file does not contain %s", tc.desc, want)
+		}
+
+		w = httptest.NewRecorder()
+		tc.handler.ServeHTTP(w, httptest.NewRequest("GET", tc.rootPath+"/api/maintenance", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: GET %s/api/maintenance responded with %d: %s", tc.desc, tc.rootPath, w.Code, w.Body)
+		}
+	}
+}
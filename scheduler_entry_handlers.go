@@ -2,9 +2,15 @@ package asynqmon
 
 import (
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/robfig/cron/v3"
 
 	"github.com/hibiken/asynq"
 )
@@ -14,22 +20,68 @@ import (
 //   - http.Handler(s) for scheduler entry related endpoints
 // ****************************************************************************
 
-func newListSchedulerEntriesHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+// schedulerEntryQueue returns the name of the queue the scheduler entry enqueues tasks in.
+func schedulerEntryQueue(e *asynq.SchedulerEntry) string {
+	qname := "default"
+	for _, o := range e.Opts {
+		if o.Type() == asynq.QueueOpt {
+			if v, ok := o.Value().(string); ok {
+				qname = v
+			}
+		}
+	}
+	return qname
+}
+
+// findSchedulerEntry returns the scheduler entry with the given ID,
+// or an error wrapping errSchedulerEntryNotFound if there is no such entry.
+func findSchedulerEntry(inspector *asynq.Inspector, entryID string) (*asynq.SchedulerEntry, error) {
+	entries, err := inspector.SchedulerEntries()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.ID == entryID {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", errSchedulerEntryNotFound, entryID)
+}
+
+func newListSchedulerEntriesHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter, qa *queueAuthorizer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		fields, err := parseFields(r, schedulerEntry{}, "id")
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
 		entries, err := inspector.SchedulerEntries()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
+		if qa != nil {
+			visible := make([]*asynq.SchedulerEntry, 0, len(entries))
+			for _, e := range entries {
+				if qa.canView(r, schedulerEntryQueue(e)) {
+					visible = append(visible, e)
+				}
+			}
+			entries = visible
+		}
 		payload := make(map[string]interface{})
 		if len(entries) == 0 {
 			// avoid nil for the entries field in json output.
 			payload["entries"] = make([]*schedulerEntry, 0)
 		} else {
-			payload["entries"] = toSchedulerEntries(entries, pf)
+			payload["entries"], err = selectFields(toSchedulerEntries(entries, pf), fields)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
 		}
 		if err := json.NewEncoder(w).Encode(payload); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 	}
@@ -37,24 +89,201 @@ func newListSchedulerEntriesHandlerFunc(inspector *asynq.Inspector, pf PayloadFo
 
 type listSchedulerEnqueueEventsResponse struct {
 	Events []*schedulerEnqueueEvent `json:"events"`
+	// Total is not known since asynq does not report the number of events.
+	Pagination *pagination `json:"pagination"`
 }
 
-func newListSchedulerEnqueueEventsHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+func newListSchedulerEnqueueEventsHandlerFunc(inspector *asynq.Inspector, qa *queueAuthorizer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		entryID := mux.Vars(r)["entry_id"]
-		pageSize, pageNum := getPageOptions(r)
+		pageSize, pageNum, err := getPageOptions(r)
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if qa != nil {
+			// Events of entries which no longer exist are not shown since their queue is unknown.
+			entry, err := findSchedulerEntry(inspector, entryID)
+			if err == nil && !qa.canView(r, schedulerEntryQueue(entry)) {
+				err = fmt.Errorf("%w: %q", errSchedulerEntryNotFound, entryID)
+			}
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
 		events, err := inspector.ListSchedulerEnqueueEvents(
 			entryID, asynq.PageSize(pageSize), asynq.Page(pageNum))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		resp := listSchedulerEnqueueEventsResponse{
-			Events: toSchedulerEnqueueEvents(events),
+			Events:     toSchedulerEnqueueEvents(events),
+			Pagination: newPagination(pageNum, pageSize, -1, len(events)),
 		}
+		setLinkHeader(w, r, resp.Pagination)
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 	}
 }
+
+// Maximum number of enqueue events inspected per scheduler entry.
+// Note that asynq only retains the most recent 1000 events for each entry.
+const maxSchedulerEnqueueEvents = 1000
+
+// Maximum number of expected enqueue times checked per scheduler entry.
+const maxSchedulerEnqueueSlots = 10000
+
+type schedulerEnqueueFailure struct {
+	EntryID  string `json:"entry_id"`
+	Spec     string `json:"spec"`
+	TaskType string `json:"task_type"`
+	// Number of expected enqueues which have no matching enqueue event.
+	MissedCount int `json:"missed_count"`
+	// Number of most recent expected enqueues missed in a row.
+	// Zero if the last expected enqueue succeeded.
+	ConsecutiveMissed int `json:"consecutive_missed"`
+	// Time of the last missed enqueue in RFC3339 format.
+	// Empty string if no enqueues were missed.
+	LastMissedAt string `json:"last_missed_at"`
+	// Times of the most recent missed enqueues in RFC3339 format (up to 10).
+	RecentMissedAt []string `json:"recent_missed_at"`
+
+	// Queue the entry enqueues tasks to, used to evaluate alert rules.
+	queue string
+}
+
+type listSchedulerEnqueueFailuresResponse struct {
+	Failures []*schedulerEnqueueFailure `json:"failures"`
+	// Total number of missed enqueues across all entries.
+	Total int `json:"total"`
+}
+
+// newListSchedulerEnqueueFailuresHandlerFunc returns a handler which reports
+// enqueues that were expected according to each entry's cron spec but are
+// missing from its enqueue event history.
+//
+// Asynq only records successful enqueues, so a gap in the history is how a
+// failed enqueue (e.g. due to an error from redis) shows up.
+//
+// Optional query params:
+// `duration`: specifies the number of seconds to look back (default 24 hours)
+func newListSchedulerEnqueueFailuresHandlerFunc(inspector *asynq.Inspector, loc *time.Location, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		duration := 24 * time.Hour
+		if d := r.URL.Query().Get("duration"); d != "" {
+			val, err := strconv.Atoi(d)
+			if err != nil || val <= 0 {
+				writeBadRequestError(w, r, fmt.Sprintf("invalid value provided for duration: %q", d))
+				return
+			}
+			duration = time.Duration(val) * time.Second
+		}
+		visible := func(qname string) bool { return qa.canView(r, qname) }
+		failures, err := listSchedulerEnqueueFailures(inspector, loc, time.Now().Add(-duration), visible)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		resp := listSchedulerEnqueueFailuresResponse{Failures: failures}
+		for _, f := range failures {
+			resp.Total += f.MissedCount
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+// listSchedulerEnqueueFailures returns the enqueue failures since the given time
+// for every scheduler entry which missed at least one enqueue, limited to the entries
+// of the queues for which visible returns true.
+func listSchedulerEnqueueFailures(inspector *asynq.Inspector, loc *time.Location, since time.Time, visible func(qname string) bool) ([]*schedulerEnqueueFailure, error) {
+	entries, err := inspector.SchedulerEntries()
+	if err != nil {
+		return nil, err
+	}
+	failures := make([]*schedulerEnqueueFailure, 0)
+	for _, e := range entries {
+		if !visible(schedulerEntryQueue(e)) {
+			continue
+		}
+		events, err := inspector.ListSchedulerEnqueueEvents(e.ID, asynq.PageSize(maxSchedulerEnqueueEvents))
+		if err != nil {
+			return nil, err
+		}
+		missed, consecutive, err := findMissedEnqueues(e.Spec, loc, events, since, time.Now())
+		if err != nil {
+			log.Printf("error: could not check enqueue history of scheduler entry %q: %v", e.ID, err)
+			continue
+		}
+		if len(missed) == 0 {
+			continue
+		}
+		f := &schedulerEnqueueFailure{
+			EntryID:           e.ID,
+			Spec:              e.Spec,
+			TaskType:          e.Task.Type(),
+			MissedCount:       len(missed),
+			ConsecutiveMissed: consecutive,
+			LastMissedAt:      missed[len(missed)-1].Format(time.RFC3339),
+			RecentMissedAt:    make([]string, 0),
+			queue:             schedulerEntryQueue(e),
+		}
+		for i := len(missed) - 1; i >= 0 && len(f.RecentMissedAt) < 10; i-- {
+			f.RecentMissedAt = append(f.RecentMissedAt, missed[i].Format(time.RFC3339))
+		}
+		failures = append(failures, f)
+	}
+	return failures, nil
+}
+
+// findMissedEnqueues compares the enqueue times expected by the given cron spec with
+// the recorded enqueue events, and returns the expected times in [since, now) which
+// have no matching event (in ascending order) along with the number of trailing misses.
+//
+// The oldest recorded event is used as the starting point since there is no way to
+// tell whether the scheduler was running before that.
+func findMissedEnqueues(spec string, loc *time.Location, events []*asynq.SchedulerEnqueueEvent, since, now time.Time) (missed []time.Time, consecutive int, err error) {
+	if len(events) == 0 {
+		return nil, 0, nil
+	}
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, 0, err
+	}
+	times := make([]time.Time, len(events))
+	for i, e := range events {
+		times[i] = e.EnqueuedAt
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	// Allow some lag between the expected time and the time the event was recorded.
+	anchor := times[0]
+	tolerance := sched.Next(anchor.In(loc)).Sub(anchor) / 2
+	if tolerance > time.Minute || tolerance <= 0 {
+		tolerance = time.Minute
+	}
+
+	j := 1 // index into times; times[0] is the anchor
+	t := sched.Next(anchor.In(loc))
+	for n := 0; n < maxSchedulerEnqueueSlots && !t.IsZero() && t.Add(tolerance).Before(now); n++ {
+		// Skip events recorded before the expected time window.
+		for j < len(times) && times[j].Before(t.Add(-tolerance)) {
+			j++
+		}
+		found := j < len(times) && times[j].Before(t.Add(tolerance))
+		if found {
+			j++
+			consecutive = 0
+		} else {
+			consecutive++
+			if !t.Before(since) {
+				missed = append(missed, t)
+			}
+		}
+		t = sched.Next(t)
+	}
+	return missed, consecutive, nil
+}
@@ -0,0 +1,180 @@
+package asynqmon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hibiken/asynq"
+)
+
+func TestFindMissedEnqueues(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database is not available: %v", err)
+	}
+	utc := func(s string) time.Time {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	events := func(ts ...string) []*asynq.SchedulerEnqueueEvent {
+		var evs []*asynq.SchedulerEnqueueEvent
+		for _, s := range ts {
+			evs = append(evs, &asynq.SchedulerEnqueueEvent{TaskID: s, EnqueuedAt: utc(s)})
+		}
+		return evs
+	}
+
+	tests := []struct {
+		desc            string
+		spec            string
+		loc             *time.Location
+		events          []*asynq.SchedulerEnqueueEvent
+		since, now      string
+		wantMissed      []string
+		wantConsecutive int
+	}{
+		{
+			desc:   "no events",
+			spec:   "@every 1h",
+			loc:    time.UTC,
+			events: nil,
+			since:  "2024-01-01T00:00:00Z",
+			now:    "2024-01-01T12:00:00Z",
+		},
+		{
+			desc:   "every enqueue recorded",
+			spec:   "0 * * * *",
+			loc:    time.UTC,
+			events: events("2024-01-01T00:00:00Z", "2024-01-01T01:00:00Z", "2024-01-01T02:00:00Z"),
+			since:  "2024-01-01T00:00:00Z",
+			now:    "2024-01-01T02:30:00Z",
+		},
+		{
+			desc:   "events recorded with some lag",
+			spec:   "0 * * * *",
+			loc:    time.UTC,
+			events: events("2024-01-01T00:00:00Z", "2024-01-01T01:00:40Z", "2024-01-01T02:00:05Z"),
+			since:  "2024-01-01T00:00:00Z",
+			now:    "2024-01-01T02:30:00Z",
+		},
+		{
+			desc:            "missed in the middle",
+			spec:            "0 * * * *",
+			loc:             time.UTC,
+			events:          events("2024-01-01T00:00:00Z", "2024-01-01T02:00:00Z"),
+			since:           "2024-01-01T00:00:00Z",
+			now:             "2024-01-01T02:30:00Z",
+			wantMissed:      []string{"2024-01-01T01:00:00Z"},
+			wantConsecutive: 0,
+		},
+		{
+			desc:            "trailing misses",
+			spec:            "0 * * * *",
+			loc:             time.UTC,
+			events:          events("2024-01-01T00:00:00Z", "2024-01-01T01:00:00Z"),
+			since:           "2024-01-01T00:00:00Z",
+			now:             "2024-01-01T03:30:00Z",
+			wantMissed:      []string{"2024-01-01T02:00:00Z", "2024-01-01T03:00:00Z"},
+			wantConsecutive: 2,
+		},
+		{
+			desc:            "misses before since are counted but not listed",
+			spec:            "0 * * * *",
+			loc:             time.UTC,
+			events:          events("2024-01-01T00:00:00Z"),
+			since:           "2024-01-01T02:00:00Z",
+			now:             "2024-01-01T03:30:00Z",
+			wantMissed:      []string{"2024-01-01T02:00:00Z", "2024-01-01T03:00:00Z"},
+			wantConsecutive: 3,
+		},
+		{
+			desc:   "expected time within the tolerance of now",
+			spec:   "0 * * * *",
+			loc:    time.UTC,
+			events: events("2024-01-01T00:00:00Z"),
+			since:  "2024-01-01T00:00:00Z",
+			now:    "2024-01-01T01:00:30Z",
+		},
+		{
+			desc:   "events out of order",
+			spec:   "0 * * * *",
+			loc:    time.UTC,
+			events: events("2024-01-01T02:00:00Z", "2024-01-01T00:00:00Z", "2024-01-01T01:00:00Z"),
+			since:  "2024-01-01T00:00:00Z",
+			now:    "2024-01-01T02:30:00Z",
+		},
+		{
+			desc:   "schedule in the location of the scheduler",
+			spec:   "0 9 * * *",
+			loc:    berlin,
+			events: events("2024-01-01T08:00:00Z", "2024-01-02T08:00:00Z", "2024-01-03T08:00:00Z"),
+			since:  "2024-01-01T00:00:00Z",
+			now:    "2024-01-03T12:00:00Z",
+		},
+		{
+			desc:            "schedule in the wrong location",
+			spec:            "0 9 * * *",
+			loc:             time.UTC,
+			events:          events("2024-01-01T08:00:00Z", "2024-01-02T08:00:00Z"),
+			since:           "2024-01-01T00:00:00Z",
+			now:             "2024-01-02T12:00:00Z",
+			wantMissed:      []string{"2024-01-01T09:00:00Z", "2024-01-02T09:00:00Z"},
+			wantConsecutive: 2,
+		},
+		{
+			desc:   "schedule across the start of daylight saving time",
+			spec:   "0 9 * * *",
+			loc:    berlin,
+			events: events("2024-03-30T08:00:00Z", "2024-03-31T07:00:00Z", "2024-04-01T07:00:00Z"),
+			since:  "2024-03-30T00:00:00Z",
+			now:    "2024-04-01T12:00:00Z",
+		},
+		{
+			desc:   "schedule across the end of daylight saving time",
+			spec:   "0 9 * * *",
+			loc:    berlin,
+			events: events("2024-10-26T07:00:00Z", "2024-10-27T08:00:00Z", "2024-10-28T08:00:00Z"),
+			since:  "2024-10-26T00:00:00Z",
+			now:    "2024-10-28T12:00:00Z",
+		},
+		{
+			desc:            "time zone in the spec",
+			spec:            "CRON_TZ=Europe/Berlin 0 9 * * *",
+			loc:             time.UTC,
+			events:          events("2024-01-01T08:00:00Z"),
+			since:           "2024-01-01T00:00:00Z",
+			now:             "2024-01-02T12:00:00Z",
+			wantMissed:      []string{"2024-01-02T09:00:00+01:00"},
+			wantConsecutive: 1,
+		},
+	}
+	for _, tc := range tests {
+		missed, consecutive, err := findMissedEnqueues(tc.spec, tc.loc, tc.events, utc(tc.since), utc(tc.now))
+		if err != nil {
+			t.Errorf("%s: findMissedEnqueues returned error: %v", tc.desc, err)
+			continue
+		}
+		var got []string
+		for _, m := range missed {
+			got = append(got, m.In(time.UTC).Format(time.RFC3339))
+		}
+		var want []string
+		for _, s := range tc.wantMissed {
+			want = append(want, utc(s).In(time.UTC).Format(time.RFC3339))
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("%s: findMissedEnqueues returned missed diff (-want,+got):\n%s", tc.desc, diff)
+		}
+		if consecutive != tc.wantConsecutive {
+			t.Errorf("%s: findMissedEnqueues returned %d consecutive misses, want %d", tc.desc, consecutive, tc.wantConsecutive)
+		}
+	}
+
+	if _, _, err := findMissedEnqueues("not a spec", time.UTC, events("2024-01-01T00:00:00Z"), time.Time{}, time.Now()); err == nil {
+		t.Errorf("findMissedEnqueues with an invalid spec returned no error")
+	}
+}
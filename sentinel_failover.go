@@ -0,0 +1,178 @@
+package asynqmon
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - failoverWatcher which records failovers reported by redis sentinels
+//   - http.Handler(s) for redis failover related endpoints
+// ****************************************************************************
+
+const (
+	// Maximum number of failover events kept in memory.
+	maxFailoverEvents = 50
+
+	// Sentinels publish the same failover independently; events for the same
+	// master and address reported within this window are recorded once.
+	failoverDedupWindow = time.Minute
+)
+
+// failoverEvent is a +switch-master event published by a sentinel.
+type failoverEvent struct {
+	Time    time.Time
+	Master  string
+	OldAddr string
+	NewAddr string
+}
+
+// failoverWatcher subscribes to +switch-master events of every sentinel
+// and keeps the recent failovers of the monitored master in memory.
+type failoverWatcher struct {
+	masterName string
+	sentinels  []*redis.SentinelClient
+	pubsubs    []*redis.PubSub
+
+	mu     sync.Mutex
+	events []*failoverEvent // ordered by time
+
+	wg sync.WaitGroup
+}
+
+// sentinelOpt returns the failover options if opt connects through redis sentinels.
+func sentinelOpt(opt asynq.RedisConnOpt) (*asynq.RedisFailoverClientOpt, bool) {
+	switch o := opt.(type) {
+	case asynq.RedisFailoverClientOpt:
+		return &o, true
+	case *asynq.RedisFailoverClientOpt:
+		return o, o != nil
+	}
+	return nil, false
+}
+
+func newFailoverWatcher(opt *asynq.RedisFailoverClientOpt) *failoverWatcher {
+	w := &failoverWatcher{masterName: opt.MasterName}
+	for _, addr := range opt.SentinelAddrs {
+		c := redis.NewSentinelClient(&redis.Options{
+			Addr:      addr,
+			Password:  opt.SentinelPassword,
+			TLSConfig: opt.TLSConfig,
+		})
+		w.sentinels = append(w.sentinels, c)
+		// PubSub reconnects on its own if the sentinel goes away.
+		w.pubsubs = append(w.pubsubs, c.Subscribe(context.Background(), "+switch-master"))
+	}
+	return w
+}
+
+func (w *failoverWatcher) start() {
+	for _, ps := range w.pubsubs {
+		w.wg.Add(1)
+		go func(ps *redis.PubSub) {
+			defer w.wg.Done()
+			for msg := range ps.Channel() {
+				if e, ok := parseSwitchMaster(msg.Payload); ok && e.Master == w.masterName {
+					w.record(e)
+				}
+			}
+		}(ps)
+	}
+}
+
+func (w *failoverWatcher) stop() error {
+	for _, ps := range w.pubsubs {
+		ps.Close()
+	}
+	w.wg.Wait()
+	for _, c := range w.sentinels {
+		c.Close()
+	}
+	return nil
+}
+
+// parseSwitchMaster parses the payload of +switch-master message which has
+// the format "<master-name> <old-ip> <old-port> <new-ip> <new-port>".
+func parseSwitchMaster(payload string) (*failoverEvent, bool) {
+	fields := strings.Fields(payload)
+	if len(fields) != 5 {
+		return nil, false
+	}
+	return &failoverEvent{
+		Time:    time.Now(),
+		Master:  fields[0],
+		OldAddr: net.JoinHostPort(fields[1], fields[2]),
+		NewAddr: net.JoinHostPort(fields[3], fields[4]),
+	}, true
+}
+
+func (w *failoverWatcher) record(e *failoverEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if n := len(w.events); n > 0 {
+		last := w.events[n-1]
+		if last.NewAddr == e.NewAddr && e.Time.Sub(last.Time) < failoverDedupWindow {
+			return
+		}
+	}
+	log.Printf("warning: redis sentinel reported failover of master %q from %s to %s", e.Master, e.OldAddr, e.NewAddr)
+	w.events = append(w.events, e)
+	if len(w.events) > maxFailoverEvents {
+		w.events = w.events[len(w.events)-maxFailoverEvents:]
+	}
+}
+
+// recentEvents returns a copy of the recorded events, newest first.
+func (w *failoverWatcher) recentEvents() []*failoverEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	res := make([]*failoverEvent, len(w.events))
+	for i, e := range w.events {
+		res[len(res)-1-i] = e
+	}
+	return res
+}
+
+type redisFailover struct {
+	// Time when the failover was reported in RFC3339 format.
+	Time       string `json:"time"`
+	Master     string `json:"master"`
+	OldAddress string `json:"old_address"`
+	NewAddress string `json:"new_address"`
+}
+
+type listRedisFailoversResponse struct {
+	// Sentinel is false if asynqmon is not connected through redis sentinels.
+	Sentinel bool `json:"sentinel"`
+	// Failovers observed since asynqmon started, newest first.
+	Failovers []*redisFailover `json:"failovers"`
+}
+
+// newListRedisFailoversHandlerFunc returns a handler listing recent failovers.
+// watcher may be nil if asynqmon is not connected through redis sentinels.
+func newListRedisFailoversHandlerFunc(watcher *failoverWatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := listRedisFailoversResponse{Failovers: []*redisFailover{}}
+		if watcher != nil {
+			resp.Sentinel = true
+			for _, e := range watcher.recentEvents() {
+				resp.Failovers = append(resp.Failovers, &redisFailover{
+					Time:       e.Time.UTC().Format(time.RFC3339),
+					Master:     e.Master,
+					OldAddress: e.OldAddr,
+					NewAddress: e.NewAddr,
+				})
+			}
+		}
+		writeResponseJSON(w, resp)
+	}
+}
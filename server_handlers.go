@@ -13,21 +13,34 @@ import (
 // ****************************************************************************
 
 type listServersResponse struct {
-	Servers []*serverInfo `json:"servers"`
+	// []*serverInfo, or items with only the fields selected with the fields parameter.
+	Servers interface{} `json:"servers"`
 }
 
-func newListServersHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+func newListServersHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter, qa *queueAuthorizer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		fields, err := parseFields(r, serverInfo{}, "id")
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
 		srvs, err := inspector.Servers()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
+			return
+		}
+		infos := toServerInfoList(srvs, pf)
+		qa.filterServers(r, infos)
+		selected, err := selectFields(infos, fields)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 		resp := listServersResponse{
-			Servers: toServerInfoList(srvs, pf),
+			Servers: selected,
 		}
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 	}
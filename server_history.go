@@ -0,0 +1,219 @@
+package asynqmon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - serverHistory which records servers joining and leaving in redis
+//   - http.Handler(s) for server history related endpoints
+// ****************************************************************************
+
+// Redis keys used by serverHistory. They share a hash tag so that they are
+// on the same node on redis cluster.
+const (
+	// Hash of server ID to JSON encoded serverEvent of the servers known to be running.
+	serverHistoryKnownKey = "asynqmon:{server_history}:known"
+	// Sorted set of JSON encoded serverEvent scored by time in unix milliseconds.
+	serverHistoryEventsKey = "asynqmon:{server_history}:events"
+)
+
+const (
+	// Events older than this are dropped.
+	serverHistoryRetention = 7 * 24 * time.Hour
+
+	// Maximum number of events kept.
+	serverHistoryMaxEvents = 10000
+
+	// Timeout for recording events after each sample of servers.
+	serverHistoryRecordTimeout = 5 * time.Second
+
+	// Default and maximum number of events returned by the API.
+	defaultServerHistoryLimit = 1000
+	maxServerHistoryLimit     = serverHistoryMaxEvents
+)
+
+// Types of server events.
+const (
+	serverEventJoin  = "join"
+	serverEventLeave = "leave"
+)
+
+type serverEvent struct {
+	Type        string         `json:"type"`
+	ServerID    string         `json:"server_id"`
+	Host        string         `json:"host"`
+	PID         int            `json:"pid"`
+	Concurrency int            `json:"concurrency"`
+	Queues      map[string]int `json:"queue_priorities"`
+	// Time of the event in RFC3339 format. For join events, the time the server
+	// started. For leave events, the time the server was first seen missing.
+	Time string `json:"time"`
+}
+
+// serverHistory records servers joining and leaving in redis so that the history
+// survives restarts and is shared by every asynqmon instance.
+// Each event is recorded once even if several instances observe it.
+type serverHistory struct {
+	rc redis.UniversalClient
+}
+
+func newServerHistory(rc redis.UniversalClient) *serverHistory {
+	return &serverHistory{rc: rc}
+}
+
+// record records the servers in srvs which were not running at the previous call,
+// and the servers which were running but are not in srvs.
+func (h *serverHistory) record(ctx context.Context, srvs []*asynq.ServerInfo, now time.Time) error {
+	known, err := h.rc.HGetAll(ctx, serverHistoryKnownKey).Result()
+	if err != nil {
+		return err
+	}
+	running := make(map[string]bool, len(srvs))
+	for _, srv := range srvs {
+		running[srv.ID] = true
+		if _, ok := known[srv.ID]; ok {
+			continue
+		}
+		ev := &serverEvent{
+			Type:        serverEventJoin,
+			ServerID:    srv.ID,
+			Host:        srv.Host,
+			PID:         srv.PID,
+			Concurrency: srv.Concurrency,
+			Queues:      srv.Queues,
+			Time:        srv.Started.Format(time.RFC3339),
+		}
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		// Only the instance which adds the server to the known servers records the event.
+		ok, err := h.rc.HSetNX(ctx, serverHistoryKnownKey, srv.ID, data).Result()
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := h.add(ctx, ev, srv.Started); err != nil {
+				return err
+			}
+		}
+	}
+	for id, data := range known {
+		if running[id] {
+			continue
+		}
+		n, err := h.rc.HDel(ctx, serverHistoryKnownKey, id).Result()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			continue // recorded by another instance
+		}
+		var ev serverEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return err
+		}
+		ev.Type = serverEventLeave
+		ev.Time = now.Format(time.RFC3339)
+		if err := h.add(ctx, &ev, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add adds the event to the history and drops old events.
+func (h *serverHistory) add(ctx context.Context, ev *serverEvent, t time.Time) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = h.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, serverHistoryEventsKey, redis.Z{Score: float64(unixMilli(t)), Member: data})
+		pipe.ZRemRangeByScore(ctx, serverHistoryEventsKey, "-inf", strconv.FormatInt(unixMilli(t.Add(-serverHistoryRetention)), 10))
+		pipe.ZRemRangeByRank(ctx, serverHistoryEventsKey, 0, -serverHistoryMaxEvents-1)
+		return nil
+	})
+	return err
+}
+
+// unixMilli returns t as the number of milliseconds since the unix epoch.
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// list returns up to limit events between start and end in chronological order.
+func (h *serverHistory) list(ctx context.Context, start, end time.Time, limit int) ([]*serverEvent, error) {
+	res, err := h.rc.ZRangeByScore(ctx, serverHistoryEventsKey, &redis.ZRangeBy{
+		Min:   strconv.FormatInt(unixMilli(start), 10),
+		Max:   strconv.FormatInt(unixMilli(end), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*serverEvent, 0, len(res))
+	for _, data := range res {
+		var ev serverEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return nil, err
+		}
+		events = append(events, &ev)
+	}
+	return events, nil
+}
+
+type listServerHistoryResponse struct {
+	Events []*serverEvent `json:"events"`
+}
+
+// newListServerHistoryHandlerFunc returns a handler which lists servers joining
+// and leaving in chronological order. The optional start and end parameters
+// (RFC3339) bound the time of the events, and limit bounds their number.
+// Queues which the requester cannot view are left out of the events.
+func newListServerHistoryHandlerFunc(h *serverHistory, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		now := time.Now()
+		start, end := now.Add(-serverHistoryRetention), now
+		for _, p := range []struct {
+			name string
+			t    *time.Time
+		}{{"start", &start}, {"end", &end}} {
+			if v := q.Get(p.name); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					writeBadRequestError(w, r, fmt.Sprintf("%s must be a time in RFC3339 format", p.name))
+					return
+				}
+				*p.t = t
+			}
+		}
+		limit := defaultServerHistoryLimit
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > maxServerHistoryLimit {
+				writeBadRequestError(w, r, fmt.Sprintf("limit must be a number between 1 and %d", maxServerHistoryLimit))
+				return
+			}
+			limit = n
+		}
+		events, err := h.list(r.Context(), start, end, limit)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		qa.filterServerEvents(r, events)
+		writeResponseJSON(w, listServerHistoryResponse{Events: events})
+	}
+}
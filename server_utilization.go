@@ -0,0 +1,249 @@
+package asynqmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - serverStatsSampler which keeps recent worker utilization of servers in memory
+//     and records servers joining and leaving with serverHistory
+//   - http.Handler(s) for server utilization related endpoints
+// ****************************************************************************
+
+const (
+	// Interval between server stats samples.
+	serverStatsSampleInterval = 15 * time.Second
+
+	// Samples older than this are dropped.
+	serverStatsRetention = time.Hour
+)
+
+// serverSample is a point-in-time snapshot of a server's workers.
+type serverSample struct {
+	ActiveWorkers int
+	Concurrency   int
+	Time          time.Time
+}
+
+// utilization returns the percentage of the server's workers which are busy.
+func (s *serverSample) utilization() float64 {
+	if s.Concurrency <= 0 {
+		return 0
+	}
+	return 100 * float64(s.ActiveWorkers) / float64(s.Concurrency)
+}
+
+// sampledServer is a server with its recent samples.
+type sampledServer struct {
+	info    *asynq.ServerInfo // as of the latest sample
+	samples []*serverSample   // ordered by time
+}
+
+// serverStatsSampler periodically samples the number of active workers of every
+// server so that utilization over time can be shown without an external time series database.
+type serverStatsSampler struct {
+	inspector *asynq.Inspector
+	interval  time.Duration
+	history   *serverHistory // records servers joining and leaving if not nil
+
+	mu      sync.Mutex
+	servers map[string]*sampledServer // keyed by server ID
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newServerStatsSampler(inspector *asynq.Inspector, history *serverHistory, interval time.Duration) *serverStatsSampler {
+	return &serverStatsSampler{
+		inspector: inspector,
+		interval:  interval,
+		history:   history,
+		servers:   make(map[string]*sampledServer),
+		done:      make(chan struct{}),
+	}
+}
+
+func (s *serverStatsSampler) start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.sample()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.sample()
+			}
+		}
+	}()
+}
+
+func (s *serverStatsSampler) stop() error {
+	close(s.done)
+	s.wg.Wait()
+	return nil
+}
+
+func (s *serverStatsSampler) sample() {
+	srvs, err := s.inspector.Servers()
+	if err != nil {
+		log.Printf("error: could not sample server stats: %v", err)
+		return
+	}
+	now := time.Now()
+	if s.history != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), serverHistoryRecordTimeout)
+		if err := s.history.record(ctx, srvs, now); err != nil {
+			log.Printf("error: could not record server history: %v", err)
+		}
+		cancel()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, srv := range srvs {
+		ss, ok := s.servers[srv.ID]
+		if !ok {
+			ss = &sampledServer{}
+			s.servers[srv.ID] = ss
+		}
+		ss.info = srv
+		ss.samples = append(ss.samples, &serverSample{
+			ActiveWorkers: len(srv.ActiveWorkers),
+			Concurrency:   srv.Concurrency,
+			Time:          now,
+		})
+	}
+	// Drop old samples and forget about servers which have been gone for a while.
+	for id, ss := range s.servers {
+		i := 0
+		for i < len(ss.samples) && now.Sub(ss.samples[i].Time) > serverStatsRetention {
+			i++
+		}
+		if i == len(ss.samples) {
+			delete(s.servers, id)
+			continue
+		}
+		ss.samples = ss.samples[i:]
+	}
+}
+
+type serverUtilizationSample struct {
+	// Time of the sample in RFC3339 format.
+	Time          string  `json:"time"`
+	ActiveWorkers int     `json:"active_workers"`
+	Concurrency   int     `json:"concurrency"`
+	Utilization   float64 `json:"utilization"`
+}
+
+type serverUtilization struct {
+	ID          string `json:"id"`
+	Host        string `json:"host"`
+	PID         int    `json:"pid"`
+	Concurrency int    `json:"concurrency"`
+	// Time of the latest sample in RFC3339 format.
+	// Older than the sample interval if the server stopped heartbeating.
+	LastSeen string `json:"last_seen"`
+	// Percentage of workers which were busy in the latest sample.
+	Utilization float64 `json:"utilization"`
+	// Average and peak percentage of busy workers over the window.
+	AvgUtilization  float64                    `json:"avg_utilization"`
+	PeakUtilization float64                    `json:"peak_utilization"`
+	Samples         []*serverUtilizationSample `json:"samples"`
+}
+
+type listServerUtilizationResponse struct {
+	// Window the samples are taken from (e.g. "1h0m0s").
+	Window string `json:"window"`
+	// Percentage of workers of all servers which were busy in the latest samples.
+	Utilization float64              `json:"utilization"`
+	Servers     []*serverUtilization `json:"servers"`
+}
+
+// utilization returns the utilization of the servers sampled within the window.
+func (s *serverStatsSampler) utilization(window time.Duration, now time.Time) *listServerUtilizationResponse {
+	resp := &listServerUtilizationResponse{
+		Window:  window.String(),
+		Servers: make([]*serverUtilization, 0),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var active, concurrency int
+	for _, ss := range s.servers {
+		u := &serverUtilization{
+			ID:          ss.info.ID,
+			Host:        ss.info.Host,
+			PID:         ss.info.PID,
+			Concurrency: ss.info.Concurrency,
+			Samples:     make([]*serverUtilizationSample, 0),
+		}
+		var sum float64
+		for _, x := range ss.samples {
+			if now.Sub(x.Time) > window {
+				continue
+			}
+			util := x.utilization()
+			sum += util
+			if util > u.PeakUtilization {
+				u.PeakUtilization = util
+			}
+			u.Samples = append(u.Samples, &serverUtilizationSample{
+				Time:          x.Time.Format(time.RFC3339),
+				ActiveWorkers: x.ActiveWorkers,
+				Concurrency:   x.Concurrency,
+				Utilization:   util,
+			})
+		}
+		if len(u.Samples) == 0 {
+			continue
+		}
+		latest := ss.samples[len(ss.samples)-1]
+		u.LastSeen = latest.Time.Format(time.RFC3339)
+		u.Utilization = latest.utilization()
+		u.AvgUtilization = sum / float64(len(u.Samples))
+		if now.Sub(latest.Time) <= 2*serverStatsSampleInterval {
+			active += latest.ActiveWorkers
+			concurrency += latest.Concurrency
+		}
+		resp.Servers = append(resp.Servers, u)
+	}
+	if concurrency > 0 {
+		resp.Utilization = 100 * float64(active) / float64(concurrency)
+	}
+	sort.Slice(resp.Servers, func(i, j int) bool {
+		if resp.Servers[i].Host == resp.Servers[j].Host {
+			return resp.Servers[i].PID < resp.Servers[j].PID
+		}
+		return resp.Servers[i].Host < resp.Servers[j].Host
+	})
+	return resp
+}
+
+// newListServerUtilizationHandlerFunc returns a handler which lists the worker
+// utilization of servers over the window given in the window parameter (e.g. "15m").
+// Default is the whole retention period.
+func newListServerUtilizationHandlerFunc(sampler *serverStatsSampler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		window := serverStatsRetention
+		if v := r.URL.Query().Get("window"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 || d > serverStatsRetention {
+				writeBadRequestError(w, r, fmt.Sprintf("window must be a positive duration up to %v", serverStatsRetention))
+				return
+			}
+			window = d
+		}
+		writeResponseJSON(w, sampler.utilization(window, time.Now()))
+	}
+}
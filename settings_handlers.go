@@ -0,0 +1,172 @@
+package asynqmon
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - settingsDocument which holds the state managed by asynqmon
+//   - http.Handler(s) for settings export and import endpoints
+// ****************************************************************************
+
+// Version of the settings document format.
+const settingsDocumentVersion = 1
+
+// settingsDocument holds the state managed by asynqmon so that it can be
+// reproduced in another deployment.
+type settingsDocument struct {
+	Version int `json:"version"`
+	// Time the document was exported in RFC3339 format.
+	ExportedAt string `json:"exported_at"`
+	// API tokens created through the API. Only the hashes of the secrets are
+	// included, so imported tokens keep working with the same secrets.
+	// Null if API tokens are not enabled.
+	APITokens []*apiTokenRecord `json:"api_tokens"`
+	// Alert rules. Rules managed with the API are created or replaced on import;
+	// rules configured with Options.AlertRules are for reference and ignored on import.
+	AlertRules []*alertRuleInfo `json:"alert_rules"`
+	// Settings of the Web UI (e.g. polling interval and theme).
+	// The server does not interpret them; the Web UI adds them on export and applies them on import.
+	UI json.RawMessage `json:"ui,omitempty"`
+}
+
+// newExportSettingsHandlerFunc returns a handler which exports the settings document.
+// Alert rules of queues which the requester cannot view are left out.
+func newExportSettingsHandlerFunc(auth *apiAuth, alerts *alertEvaluator, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules, err := alerts.listRules(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		doc := settingsDocument{
+			Version:    settingsDocumentVersion,
+			ExportedAt: time.Now().Format(time.RFC3339),
+			AlertRules: filterAlertRules(rules, func(qname string) bool { return qa.canView(r, qname) }),
+		}
+		if auth != nil {
+			recs, _, err := auth.store.list(r.Context())
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			sort.Slice(recs, func(i, j int) bool { return recs[i].CreatedAt.Before(recs[j].CreatedAt) })
+			doc.APITokens = recs
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"asynqmon-settings-%s.json\"", time.Now().Format("2006-01-02")))
+		writeResponseJSON(w, doc)
+	}
+}
+
+// validateImportedToken returns an error if the token is invalid or grants
+// more access than the token of the caller.
+func validateImportedToken(rec *apiTokenRecord, caller *apiTokenInfo) error {
+	if rec.ID == "" {
+		return fmt.Errorf("API token must have an id")
+	}
+	if name := strings.TrimSpace(rec.Name); name == "" || len(name) > maxAPITokenNameLen {
+		return fmt.Errorf("API token %s must have a name of up to %d characters", rec.ID, maxAPITokenNameLen)
+	}
+	if b, err := hex.DecodeString(rec.Hash); err != nil || len(b) != 32 {
+		return fmt.Errorf("API token %s must have a SHA-256 hash in hex", rec.ID)
+	}
+	if len(rec.Scopes) == 0 {
+		return fmt.Errorf("API token %s must have scopes", rec.ID)
+	}
+	for _, s := range rec.Scopes {
+		sc, err := parseScope(s)
+		if err != nil {
+			return fmt.Errorf("API token %s: %v", rec.ID, err)
+		}
+		if caller != nil && !caller.allows(sc) {
+			return fmt.Errorf("API token %s: scope %q exceeds the scopes of %s", rec.ID, s, caller)
+		}
+	}
+	return nil
+}
+
+type importSettingsResponse struct {
+	// Number of API tokens created or replaced.
+	ImportedAPITokens int `json:"imported_api_tokens"`
+	// Number of alert rules created or replaced.
+	ImportedAlertRules int `json:"imported_alert_rules"`
+	// Parts of the document which were not imported and why.
+	Ignored []string `json:"ignored"`
+}
+
+// newImportSettingsHandlerFunc returns a handler which imports a document
+// exported by newExportSettingsHandlerFunc. API tokens with the same IDs and alert rules
+// with the same names are replaced. The document is validated as a whole before anything is imported.
+// Alert rules of queues which the requester cannot make changes to are ignored.
+func newImportSettingsHandlerFunc(auth *apiAuth, alerts *alertEvaluator, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var doc settingsDocument
+		if err := decodeRequestBody(w, r, &doc); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if doc.Version != settingsDocumentVersion {
+			writeBadRequestError(w, r, fmt.Sprintf("invalid request body: unsupported version %d", doc.Version))
+			return
+		}
+		resp := importSettingsResponse{Ignored: make([]string, 0)}
+		var rules []*alertRuleRecord
+		for _, info := range doc.AlertRules {
+			if !info.Managed || alerts.isConfigured(info.Name) {
+				resp.Ignored = append(resp.Ignored, fmt.Sprintf("alert_rules: alert rule %q is configured with options of the deployment", info.Name))
+				continue
+			}
+			if !qa.canMutate(r, info.Queue) {
+				resp.Ignored = append(resp.Ignored, fmt.Sprintf("alert_rules: not allowed to make changes to queue %q of alert rule %q", info.Queue, info.Name))
+				continue
+			}
+			req := alertRuleRequest{
+				Name:       info.Name,
+				Type:       info.Type,
+				Queue:      info.Queue,
+				ForSeconds: info.ForSeconds,
+				Severity:   info.Severity,
+				Threshold:  info.Threshold,
+			}
+			rec, err := req.record()
+			if err != nil {
+				writeBadRequestError(w, r, fmt.Sprintf("%v (alert rule %q)", err, info.Name))
+				return
+			}
+			rules = append(rules, rec)
+		}
+		if len(doc.APITokens) > 0 && auth == nil {
+			resp.Ignored = append(resp.Ignored, "api_tokens: API tokens are not enabled")
+			doc.APITokens = nil
+		}
+		caller := apiTokenFromContext(r.Context())
+		for _, rec := range doc.APITokens {
+			if err := validateImportedToken(rec, caller); err != nil {
+				writeBadRequestError(w, r, fmt.Sprintf("invalid request body: %v", err))
+				return
+			}
+		}
+		for _, rec := range doc.APITokens {
+			if err := auth.store.put(r.Context(), rec); err != nil {
+				writeError(w, r, err)
+				return
+			}
+			resp.ImportedAPITokens++
+		}
+		for _, rec := range rules {
+			if err := alerts.store.put(r.Context(), rec); err != nil {
+				writeError(w, r, err)
+				return
+			}
+			resp.ImportedAlertRules++
+		}
+		writeResponseJSON(w, resp)
+	}
+}
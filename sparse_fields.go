@@ -0,0 +1,121 @@
+package asynqmon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - fieldSet which selects the fields of list items with the "fields" query parameter
+// ****************************************************************************
+
+// fieldSet is a set of JSON field names of list items requested with the
+// "fields" query parameter (e.g. "?fields=id,type,error_message").
+// A nil fieldSet selects all fields.
+type fieldSet map[string]bool
+
+// parseFields returns the fields requested with the "fields" query parameter,
+// or nil if the parameter is not set. It returns an error if a field is not
+// a JSON field of the item type. The key field is always included so that
+// clients can identify the items.
+func parseFields(r *http.Request, item interface{}, key string) (fieldSet, error) {
+	s := r.URL.Query().Get("fields")
+	if s == "" {
+		return nil, nil
+	}
+	valid := jsonFieldNames(reflect.TypeOf(item))
+	fs := make(fieldSet)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !valid[name] {
+			names := make([]string, 0, len(valid))
+			for n := range valid {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown field %q in fields parameter; must be one of %s", name, strings.Join(names, ", "))
+		}
+		fs[name] = true
+	}
+	fs[key] = true
+	return fs, nil
+}
+
+// jsonFieldNames returns the names of the JSON fields of the struct type t,
+// including the fields of embedded structs.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.Anonymous && tag == "" {
+			for n := range jsonFieldNames(f.Type) {
+				names[n] = true
+			}
+			continue
+		}
+		if tag == "-" || f.PkgPath != "" {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		names[tag] = true
+	}
+	return names
+}
+
+// has reports whether the field is selected.
+func (fs fieldSet) has(name string) bool {
+	return fs == nil || fs[name]
+}
+
+// selectFields returns the items of the slice with only the selected fields.
+// The slice is returned as is if fs is nil.
+func selectFields(items interface{}, fs fieldSet) (interface{}, error) {
+	if fs == nil {
+		return items, nil
+	}
+	v := reflect.ValueOf(items)
+	out := make([]map[string]json.RawMessage, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		b, err := json.Marshal(v.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+		for k := range m {
+			if !fs[k] {
+				delete(m, k)
+			}
+		}
+		out[i] = m
+	}
+	return out, nil
+}
+
+// emptyResultFormatter is used in place of ResultFormatter when results are not selected.
+var emptyResultFormatter = ResultFormatterFunc(func(string, []byte) string { return "" })
+
+// resultFormatterForFields returns rf, or a formatter which skips formatting if
+// the result field is not selected.
+func resultFormatterForFields(rf ResultFormatter, fs fieldSet) ResultFormatter {
+	if fs.has("result") {
+		return rf
+	}
+	return emptyResultFormatter
+}
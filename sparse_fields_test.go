@@ -0,0 +1,137 @@
+package asynqmon
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type sparseFieldsBase struct {
+	ID    string `json:"id"`
+	Queue string `json:"queue"`
+}
+
+type sparseFieldsItem struct {
+	sparseFieldsBase
+	Type       string                 `json:"type"`
+	Payload    map[string]interface{} `json:"payload"`
+	Retried    int                    `json:"retried,omitempty"`
+	Internal   string                 `json:"-"`
+	NoTag      string
+	unexported string
+}
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		desc    string
+		query   string
+		want    fieldSet
+		wantErr bool
+	}{
+		{"no fields parameter", "", nil, false},
+		{"empty fields parameter", "fields=", nil, false},
+		{"selected fields and key", "fields=type,payload", fieldSet{"id": true, "type": true, "payload": true}, false},
+		{"key only", "fields=id", fieldSet{"id": true}, false},
+		{"field of embedded struct", "fields=queue", fieldSet{"id": true, "queue": true}, false},
+		{"field with omitempty", "fields=retried", fieldSet{"id": true, "retried": true}, false},
+		{"field without tag", "fields=NoTag", fieldSet{"id": true, "NoTag": true}, false},
+		{"spaces and empty names", "fields=%20type%20,,", fieldSet{"id": true, "type": true}, false},
+		{"only commas", "fields=,", fieldSet{"id": true}, false},
+		{"unknown field", "fields=type,foo", nil, true},
+		{"nested field", "fields=payload.user_id", nil, true},
+		{"embedded struct name", "fields=sparseFieldsBase", nil, true},
+		{"field excluded from JSON", "fields=Internal", nil, true},
+		{"unexported field", "fields=unexported", nil, true},
+		{"field names are case sensitive", "fields=Type", nil, true},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest("GET", "/api/queues/default/pending_tasks?"+tc.query, nil)
+		got, err := parseFields(r, &sparseFieldsItem{}, "id")
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: parseFields returned error %v, want error %t", tc.desc, err, tc.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%s: parseFields returned diff (-want,+got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	items := []*sparseFieldsItem{
+		{
+			sparseFieldsBase: sparseFieldsBase{ID: "a", Queue: "default"},
+			Type:             "email:send",
+			Payload:          map[string]interface{}{"user_id": 1, "nested": map[string]interface{}{"x": true}},
+			Retried:          2,
+		},
+		{
+			sparseFieldsBase: sparseFieldsBase{ID: "b", Queue: "default"},
+			Type:             "email:send",
+		},
+	}
+	tests := []struct {
+		desc string
+		fs   fieldSet
+		want string
+	}{
+		{
+			desc: "all fields",
+			fs:   nil,
+			want: `[{"id":"a","queue":"default","type":"email:send","payload":{"nested":{"x":true},"user_id":1},"retried":2,"NoTag":""},` +
+				`{"id":"b","queue":"default","type":"email:send","payload":null,"NoTag":""}]`,
+		},
+		{
+			desc: "nested values are kept as a whole",
+			fs:   fieldSet{"id": true, "payload": true},
+			want: `[{"id":"a","payload":{"nested":{"x":true},"user_id":1}},{"id":"b","payload":null}]`,
+		},
+		{
+			desc: "fields of embedded struct",
+			fs:   fieldSet{"id": true, "queue": true},
+			want: `[{"id":"a","queue":"default"},{"id":"b","queue":"default"}]`,
+		},
+		{
+			desc: "omitted empty field",
+			fs:   fieldSet{"id": true, "retried": true},
+			want: `[{"id":"a","retried":2},{"id":"b"}]`,
+		},
+		{
+			desc: "field which is not in JSON",
+			fs:   fieldSet{"id": true, "unknown": true},
+			want: `[{"id":"a"},{"id":"b"}]`,
+		},
+	}
+	for _, tc := range tests {
+		got, err := selectFields(items, tc.fs)
+		if err != nil {
+			t.Errorf("%s: selectFields returned error: %v", tc.desc, err)
+			continue
+		}
+		b, err := json.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var gotJSON, wantJSON interface{}
+		if err := json.Unmarshal(b, &gotJSON); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tc.want), &wantJSON); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(wantJSON, gotJSON); diff != "" {
+			t.Errorf("%s: selectFields returned diff (-want,+got):\n%s", tc.desc, diff)
+		}
+	}
+
+	// Empty lists stay empty lists.
+	got, err := selectFields([]*sparseFieldsItem{}, fieldSet{"id": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]map[string]json.RawMessage{}, got); diff != "" {
+		t.Errorf("selectFields of an empty list returned diff (-want,+got):\n%s", diff)
+	}
+}
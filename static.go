@@ -21,6 +21,13 @@ type uiAssetsHandler struct {
 	indexFileName  string
 	prometheusAddr string
 	readOnly       bool
+	// Whether users can sign out (i.e. they sign in with an OIDC provider).
+	signOut bool
+	// Clusters to show in the cluster selector; nil if there is only one.
+	clusters *clusterContext
+
+	// Names of the UI sections to hide (e.g. "metrics", "schedulers", "redis").
+	disabledSections []string
 }
 
 // ServeHTTP inspects the URL path to locate a file within the static dir
@@ -42,7 +49,7 @@ func (h *uiAssetsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	path = strings.TrimPrefix(path, h.rootPath)
 
-	if code, err := h.serveFile(w, path); err != nil {
+	if code, err := h.serveFile(w, r, path, externalRootPath(r, h.rootPath)); err != nil {
 		http.Error(w, err.Error(), code)
 		return
 	}
@@ -52,7 +59,9 @@ func (h *uiAssetsHandler) indexFilePath() string {
 	return filepath.Join(h.staticDirPath, h.indexFileName)
 }
 
-func (h *uiAssetsHandler) renderIndexFile(w http.ResponseWriter) error {
+// renderIndexFile renders the index file with the root path as seen by the browser.
+// Users who sign in are shown in view-only mode unless they are allowed to make changes.
+func (h *uiAssetsHandler) renderIndexFile(w http.ResponseWriter, r *http.Request, rootPath string) error {
 	// Note: Replace the default delimiter ("{{") with a custom one
 	// since webpack escapes the '{' character when it compiles the index.html file.
 	// See the "homepage" field in package.json.
@@ -61,13 +70,32 @@ func (h *uiAssetsHandler) renderIndexFile(w http.ResponseWriter) error {
 		return err
 	}
 	data := struct {
-		RootPath       string
-		PrometheusAddr string
-		ReadOnly       bool
+		RootPath         string
+		PrometheusAddr   string
+		ReadOnly         bool
+		DisabledSections string
+		Username         string
+		SignOutPath      string
+		Cluster          string
+		Clusters         string
+		ClustersRootPath string
 	}{
-		RootPath:       h.rootPath,
-		PrometheusAddr: h.prometheusAddr,
-		ReadOnly:       h.readOnly,
+		RootPath:         rootPath,
+		PrometheusAddr:   h.prometheusAddr,
+		ReadOnly:         h.readOnly,
+		DisabledSections: strings.Join(h.disabledSections, ","),
+	}
+	if h.clusters != nil {
+		data.Cluster = h.clusters.name
+		data.Clusters = strings.Join(h.clusters.names, ",")
+		data.ClustersRootPath = externalRootPath(r, h.clusters.rootPath)
+	}
+	if user := apiTokenFromContext(r.Context()); user != nil {
+		data.Username = user.name
+		data.ReadOnly = data.ReadOnly || !user.allows(scope{group: scopeGroupAll, level: scopeAccessLevels["write"]})
+		if h.signOut {
+			data.SignOutPath = rootPath + "/auth/logout"
+		}
 	}
 	return tmpl.Execute(w, data)
 }
@@ -78,9 +106,9 @@ func (h *uiAssetsHandler) renderIndexFile(w http.ResponseWriter) error {
 // and serves if a file is found.
 // If a requested file is not found in the filesystem, it serves the index file to
 // make sure when user refreshes the page in SPA things still work.
-func (h *uiAssetsHandler) serveFile(w http.ResponseWriter, path string) (code int, err error) {
+func (h *uiAssetsHandler) serveFile(w http.ResponseWriter, r *http.Request, path, rootPath string) (code int, err error) {
 	if path == "/" || path == "" {
-		if err := h.renderIndexFile(w); err != nil {
+		if err := h.renderIndexFile(w, r, rootPath); err != nil {
 			return http.StatusInternalServerError, err
 		}
 		return http.StatusOK, nil
@@ -91,7 +119,7 @@ func (h *uiAssetsHandler) serveFile(w http.ResponseWriter, path string) (code in
 		// If path is error (e.g. file not exist, path is a directory), serve index file.
 		var pathErr *fs.PathError
 		if errors.As(err, &pathErr) {
-			if err := h.renderIndexFile(w); err != nil {
+			if err := h.renderIndexFile(w, r, rootPath); err != nil {
 				return http.StatusInternalServerError, err
 			}
 			return http.StatusOK, nil
@@ -0,0 +1,375 @@
+package asynqmon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - exportedTask which is the format of tasks in export files
+//   - http.Handler(s) for exporting tasks as JSON or NDJSON
+//   - http.Handler(s) for importing tasks from export files
+// ****************************************************************************
+
+// Maximum number of tasks in a request to import tasks.
+// Clients split larger files into batches so that each request completes within the redis timeout.
+const maxImportBatchSize = 1000
+
+// Maximum size in bytes of the body of a request to import tasks.
+const maxImportRequestBodySize = 10000000
+
+// Maximum number of errors in the response to a request to import tasks.
+const maxImportErrors = 100
+
+// exportedTask is a task in an export file.
+// Payloads and results are exported as stored in redis (base64 encoded), so that
+// tasks can be imported as is regardless of their encoding or encryption.
+type exportedTask struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Payload  []byte `json:"payload"`
+	Queue    string `json:"queue"`
+	State    string `json:"state"`
+	MaxRetry *int   `json:"max_retry"`
+	Retried  int    `json:"retried"`
+	LastErr  string `json:"error_message"`
+	// Times are in RFC3339 format, or empty if not applicable.
+	LastFailedAt  string `json:"last_failed_at"`
+	Timeout       int    `json:"timeout_seconds"`
+	Deadline      string `json:"deadline"`
+	NextProcessAt string `json:"next_process_at"`
+	CompletedAt   string `json:"completed_at"`
+	Retention     int    `json:"retention_seconds"`
+	Result        []byte `json:"result"`
+}
+
+func toExportedTask(info *asynq.TaskInfo) *exportedTask {
+	maxRetry := info.MaxRetry
+	return &exportedTask{
+		ID:            info.ID,
+		Type:          info.Type,
+		Payload:       info.Payload,
+		Queue:         info.Queue,
+		State:         info.State.String(),
+		MaxRetry:      &maxRetry,
+		Retried:       info.Retried,
+		LastErr:       info.LastErr,
+		LastFailedAt:  formatTimeInRFC3339(info.LastFailedAt),
+		Timeout:       int(info.Timeout.Seconds()),
+		Deadline:      formatTimeInRFC3339(info.Deadline),
+		NextProcessAt: formatTimeInRFC3339(info.NextProcessAt),
+		CompletedAt:   formatTimeInRFC3339(info.CompletedAt),
+		Retention:     int(info.Retention.Seconds()),
+		Result:        info.Result,
+	}
+}
+
+// options returns the options to enqueue the task in the given queue, with its original
+// ID unless newID is true. Scheduled and retry tasks whose process time has not passed are
+// scheduled at the same time, and other tasks are processed immediately.
+// The retry count and the error of the task cannot be restored.
+func (t *exportedTask) options(qname string, newID bool, now time.Time) ([]asynq.Option, error) {
+	if err := validateIdentifier("type", t.Type); err != nil {
+		return nil, err
+	}
+	opts := []asynq.Option{asynq.Queue(qname)}
+	if t.ID != "" && !newID {
+		if err := validateIdentifier("id", t.ID); err != nil {
+			return nil, err
+		}
+		opts = append(opts, asynq.TaskID(t.ID))
+	}
+	if t.MaxRetry != nil {
+		if *t.MaxRetry < 0 {
+			return nil, errors.New("max_retry must not be negative")
+		}
+		opts = append(opts, asynq.MaxRetry(*t.MaxRetry))
+	}
+	if t.Timeout < 0 || t.Retention < 0 {
+		return nil, errors.New("timeout_seconds and retention_seconds must not be negative")
+	}
+	if t.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(time.Duration(t.Timeout)*time.Second))
+	}
+	if t.Retention > 0 {
+		opts = append(opts, asynq.Retention(time.Duration(t.Retention)*time.Second))
+	}
+	if t.Deadline != "" {
+		d, err := time.Parse(time.RFC3339, t.Deadline)
+		if err != nil {
+			return nil, fmt.Errorf("deadline must be in RFC3339 format: %v", err)
+		}
+		opts = append(opts, asynq.Deadline(d))
+	}
+	if (t.State == "scheduled" || t.State == "retry") && t.NextProcessAt != "" {
+		at, err := time.Parse(time.RFC3339, t.NextProcessAt)
+		if err != nil {
+			return nil, fmt.Errorf("next_process_at must be in RFC3339 format: %v", err)
+		}
+		if at.After(now) {
+			opts = append(opts, asynq.ProcessAt(at))
+		}
+	}
+	return opts, nil
+}
+
+// exportTaskLister returns the taskLister of the tasks in the given state.
+func exportTaskLister(inspector *asynq.Inspector, qname, state string) taskLister {
+	return func(opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+		switch state {
+		case "pending":
+			return inspector.ListPendingTasks(qname, opts...)
+		case "active":
+			return inspector.ListActiveTasks(qname, opts...)
+		case "scheduled":
+			return inspector.ListScheduledTasks(qname, opts...)
+		case "retry":
+			return inspector.ListRetryTasks(qname, opts...)
+		case "archived":
+			return inspector.ListArchivedTasks(qname, opts...)
+		case "completed":
+			return inspector.ListCompletedTasks(qname, opts...)
+		}
+		return nil, fmt.Errorf("cannot export %s tasks", state)
+	}
+}
+
+// newExportTasksHandlerFunc returns a handler which streams every task in the state as
+// NDJSON (one exportedTask per line), or as a JSON array with the format=json query parameter.
+// Tasks can be filtered with the same search query parameters as the task lists.
+//
+// Tasks are read one page at a time while they are written, so tasks which move
+// between states during the export may be missed or exported twice.
+func newExportTasksHandlerFunc(inspector *asynq.Inspector, rc redis.UniversalClient, pf *taskPayloadFormatter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		qname, state := vars["qname"], vars["state"]
+		format := r.URL.Query().Get("format")
+		switch format {
+		case "":
+			format = "ndjson"
+		case "ndjson", "json":
+		default:
+			writeBadRequestError(w, r, fmt.Sprintf("invalid query parameter: format must be ndjson or json, got %q", format))
+			return
+		}
+		search, err := parseTaskSearch(r, state, pf)
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if err := checkQueueExists(r.Context(), rc, qname); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		list := exportTaskLister(inspector, qname, state)
+		// Read the first page before writing the headers so that errors get an error response.
+		tasks, err := list(asynq.PageSize(maxPageSize), asynq.Page(1))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		if format == "json" {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-%s-tasks.%s", qname, state, format)))
+		flusher, _ := w.(http.Flusher)
+		bw := bufio.NewWriter(w)
+		enc := json.NewEncoder(bw)
+		var n int // number of exported tasks
+		if format == "json" {
+			bw.WriteString("[")
+		}
+		for page := 1; ; page++ {
+			if page > 1 {
+				if tasks, err = list(asynq.PageSize(maxPageSize), asynq.Page(page)); err != nil {
+					// The status has been sent, so the client only sees a truncated response.
+					log.Printf("error: could not export %s tasks in queue %q: %v", state, qname, err)
+					break
+				}
+			}
+			times := make([]time.Time, len(tasks))
+			if search != nil && search.hasTimeRange() {
+				if times, err = taskStateTimes(r.Context(), rc, qname, state, tasks); err != nil {
+					log.Printf("error: could not export %s tasks in queue %q: %v", state, qname, err)
+					break
+				}
+			}
+			for i, t := range tasks {
+				if search != nil && !search.match(t, times[i]) {
+					continue
+				}
+				if format == "json" && n > 0 {
+					bw.WriteString(",")
+				}
+				if err := enc.Encode(toExportedTask(t)); err != nil {
+					log.Printf("error: could not export task with id %q: %v", t.ID, err)
+					continue
+				}
+				n++
+			}
+			bw.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+			if len(tasks) < maxPageSize || r.Context().Err() != nil {
+				break
+			}
+		}
+		if format == "json" {
+			bw.WriteString("]\n")
+		}
+		bw.Flush()
+	}
+}
+
+type importTasksResponse struct {
+	// Number of tasks enqueued, or which would be enqueued in a dry run.
+	Enqueued int `json:"enqueued"`
+	// Number of tasks skipped since a task with the same ID exists in the queue
+	// (or, for unique tasks, a duplicate task exists).
+	Duplicates int `json:"duplicates"`
+	// Number of invalid tasks and tasks which could not be enqueued.
+	Failed int `json:"failed"`
+	// Errors of the failed tasks, up to maxImportErrors of them.
+	Errors []*importTaskError `json:"errors"`
+	// Indicates that no task was enqueued since the request was a dry run.
+	DryRun bool `json:"dry_run"`
+}
+
+type importTaskError struct {
+	// Position of the task in the request body, starting from 0.
+	Index int    `json:"index"`
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+func (resp *importTasksResponse) fail(index int, id string, err error) {
+	resp.Failed++
+	if len(resp.Errors) < maxImportErrors {
+		resp.Errors = append(resp.Errors, &importTaskError{Index: index, ID: id, Error: err.Error()})
+	}
+}
+
+// decodeExportedTasks decodes the tasks in the export file read from r,
+// which is either NDJSON or a JSON array.
+func decodeExportedTasks(r io.Reader) ([]*exportedTask, error) {
+	br := bufio.NewReader(r)
+	var array bool
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return nil, errors.New("body must not be empty")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			array = b[0] == '['
+			break
+		}
+		br.ReadByte()
+	}
+	dec := json.NewDecoder(br)
+	dec.DisallowUnknownFields()
+	if array {
+		dec.Token() // [
+	}
+	var tasks []*exportedTask
+	for dec.More() {
+		if len(tasks) == maxImportBatchSize {
+			return nil, fmt.Errorf("body must not have more than %d tasks; split the file into batches", maxImportBatchSize)
+		}
+		var t exportedTask
+		if err := dec.Decode(&t); err != nil {
+			return nil, fmt.Errorf("task at index %d: %v", len(tasks), err)
+		}
+		tasks = append(tasks, &t)
+	}
+	if array {
+		if _, err := dec.Token(); err != nil { // ]
+			return nil, err
+		}
+		if dec.More() {
+			return nil, errors.New("body must contain a single JSON array")
+		}
+	}
+	return tasks, nil
+}
+
+// newImportTasksHandlerFunc returns a handler which enqueues the tasks in the request body,
+// which is a batch of tasks in the format exported by newExportTasksHandlerFunc, in the queue.
+// Tasks keep their IDs unless the new_ids=true query parameter is given, so importing the same
+// file twice does not duplicate tasks. With the dry_run=true query parameter, the tasks are
+// validated and checked for duplicate IDs without being enqueued.
+func newImportTasksHandlerFunc(inspector *asynq.Inspector, client *asynq.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qname := mux.Vars(r)["qname"]
+		q := r.URL.Query()
+		var dryRun, newIDs bool
+		for name, v := range map[string]*bool{"dry_run": &dryRun, "new_ids": &newIDs} {
+			if s := q.Get(name); s != "" {
+				b, err := strconv.ParseBool(s)
+				if err != nil {
+					writeBadRequestError(w, r, fmt.Sprintf("invalid query parameter: %s must be a boolean, got %q", name, s))
+					return
+				}
+				*v = b
+			}
+		}
+		tasks, err := decodeExportedTasks(http.MaxBytesReader(w, r.Body, maxImportRequestBodySize))
+		if err != nil {
+			writeBadRequestError(w, r, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+
+		// Enqueue the whole batch even if the client goes away, so that a retried
+		// request reports the tasks which were already enqueued as duplicates.
+		ctx := context.Background()
+		resp := importTasksResponse{DryRun: dryRun, Errors: make([]*importTaskError, 0)}
+		now := time.Now()
+		for i, t := range tasks {
+			opts, err := t.options(qname, newIDs, now)
+			if err != nil {
+				resp.fail(i, t.ID, err)
+				continue
+			}
+			if dryRun {
+				if t.ID != "" && !newIDs {
+					if _, err := inspector.GetTaskInfo(qname, t.ID); err == nil {
+						resp.Duplicates++
+						continue
+					}
+				}
+				resp.Enqueued++
+				continue
+			}
+			_, err = client.EnqueueContext(ctx, asynq.NewTask(t.Type, t.Payload), opts...)
+			switch {
+			case errors.Is(err, asynq.ErrTaskIDConflict), errors.Is(err, asynq.ErrDuplicateTask):
+				resp.Duplicates++
+			case err != nil:
+				resp.fail(i, t.ID, err)
+			default:
+				resp.Enqueued++
+			}
+		}
+		writeResponseJSON(w, resp)
+	}
+}
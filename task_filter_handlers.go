@@ -0,0 +1,460 @@
+package asynqmon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - taskFilter to select tasks by type, payload, and time
+//   - searchTasks to filter the task lists with query parameters
+//   - http.Handler(s) for deleting, archiving, and running tasks matching a filter
+// ****************************************************************************
+
+// Number of tasks checked against the filter in a request.
+// Clients pass the cursor in the response to the next request until it is empty,
+// so that each request completes within the redis timeout regardless of the queue size.
+const filterScanBatchSize = 500
+
+type tasksByFilterRequest struct {
+	// Glob pattern the task type must match (e.g. "email:*"). See path.Match for the syntax.
+	TaskType string `json:"task_type"`
+
+	// Substring the payload must contain, after decryption and decompression.
+	PayloadContains string `json:"payload_contains"`
+
+	// Regular expression the payload must match, after decryption and decompression.
+	PayloadRegexp string `json:"payload_regexp"`
+
+	// Time range in RFC3339 format of the time of the task in its state (see taskStateTimes).
+	// Either bound can be omitted.
+	After  string `json:"after"`
+	Before string `json:"before"`
+
+	// Cursor returned by the previous request, or empty to start from the beginning.
+	Cursor string `json:"cursor"`
+}
+
+type tasksByFilterResponse struct {
+	// Number of tasks matching the filter which were deleted, archived, or run.
+	Affected int `json:"affected"`
+	// Number of tasks matching the filter which the operation failed for.
+	Failed int `json:"failed"`
+	// Number of tasks checked against the filter.
+	Scanned int `json:"scanned"`
+	// Cursor to pass to the next request, or empty if all tasks were checked.
+	Cursor string `json:"cursor"`
+}
+
+// taskFilter selects tasks. Zero fields match every task.
+type taskFilter struct {
+	taskType        string
+	payloadContains []byte
+	payloadRegexp   *regexp.Regexp
+	after, before   time.Time
+
+	// Decrypts and decompresses payloads before they are matched.
+	pf *taskPayloadFormatter
+}
+
+// newTaskFilter returns the filter with the given task type pattern, payload substring and
+// regular expression, and time range in RFC3339 format, or nil if all of them are empty.
+// Payloads are decrypted and decompressed with pf before they are matched.
+func newTaskFilter(pf *taskPayloadFormatter, taskType, payloadContains, payloadRegexp, after, before string) (*taskFilter, error) {
+	if taskType == "" && payloadContains == "" && payloadRegexp == "" && after == "" && before == "" {
+		return nil, nil
+	}
+	f := &taskFilter{taskType: taskType, pf: pf}
+	if _, err := path.Match(taskType, ""); err != nil {
+		return nil, fmt.Errorf("task type %q is not a valid glob pattern: %v", taskType, err)
+	}
+	if payloadContains != "" {
+		f.payloadContains = []byte(payloadContains)
+	}
+	if payloadRegexp != "" {
+		re, err := regexp.Compile(payloadRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("payload regexp is not a valid regular expression: %v", err)
+		}
+		f.payloadRegexp = re
+	}
+	var err error
+	if after != "" {
+		if f.after, err = time.Parse(time.RFC3339, after); err != nil {
+			return nil, fmt.Errorf("after must be in RFC3339 format: %v", err)
+		}
+	}
+	if before != "" {
+		if f.before, err = time.Parse(time.RFC3339, before); err != nil {
+			return nil, fmt.Errorf("before must be in RFC3339 format: %v", err)
+		}
+	}
+	if !f.after.IsZero() && !f.before.IsZero() && !f.after.Before(f.before) {
+		return nil, errors.New("after must be earlier than before")
+	}
+	return f, nil
+}
+
+func (req *tasksByFilterRequest) filter(pf *taskPayloadFormatter) (*taskFilter, error) {
+	f, err := newTaskFilter(pf, req.TaskType, req.PayloadContains, req.PayloadRegexp, req.After, req.Before)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request body: %v", err)
+	}
+	if f == nil {
+		return nil, errors.New("invalid request body: at least one of task_type, payload_contains, payload_regexp, after, and before is required")
+	}
+	return f, nil
+}
+
+func (f *taskFilter) hasTimeRange() bool {
+	return !f.after.IsZero() || !f.before.IsZero()
+}
+
+// match reports whether the task matches the filter. t is the time of the task in its state.
+func (f *taskFilter) match(info *asynq.TaskInfo, t time.Time) bool {
+	if f.taskType != "" {
+		if ok, _ := path.Match(f.taskType, info.Type); !ok {
+			return false
+		}
+	}
+	if f.payloadContains != nil || f.payloadRegexp != nil {
+		payload := f.pf.decoded(info.Type, info.Payload)
+		if f.payloadContains != nil && !bytes.Contains(payload, f.payloadContains) {
+			return false
+		}
+		if f.payloadRegexp != nil && !f.payloadRegexp.Match(payload) {
+			return false
+		}
+	}
+	if f.hasTimeRange() {
+		// asynq does not record the time for some tasks (e.g. tasks which were
+		// pending before asynq started recording it), so do not match them.
+		if t.IsZero() || (!f.after.IsZero() && t.Before(f.after)) || (!f.before.IsZero() && !t.Before(f.before)) {
+			return false
+		}
+	}
+	return true
+}
+
+// taskScan is a batch of tasks in a state returned by scanTasks.
+type taskScan struct {
+	ids []string
+	// Cursor of the next batch, given that the tasks in this batch which matched the filter
+	// were moved out of the state. Empty if this is the last batch.
+	next func(moved int) string
+}
+
+// scanTasks returns the batch of tasks in the given state at the cursor.
+//
+// Pending tasks are read from the list by offset, which is adjusted by the number of
+// tasks moved out of the list. Tasks in other states are read from the sorted sets with ZSCAN,
+// which returns every task which stays in the set during the scan at least once.
+func scanTasks(ctx context.Context, rc redis.UniversalClient, qname, state string, cursor uint64) (*taskScan, error) {
+	if state == "pending" {
+		ids, err := rc.LRange(ctx, asynqPendingKey(qname), int64(cursor), int64(cursor)+filterScanBatchSize-1).Result()
+		if err != nil {
+			return nil, err
+		}
+		return &taskScan{ids: ids, next: func(moved int) string {
+			if len(ids) < filterScanBatchSize {
+				return ""
+			}
+			return strconv.FormatUint(cursor+uint64(len(ids)-moved), 10)
+		}}, nil
+	}
+
+	var key string
+	switch state {
+	case "scheduled":
+		key = asynqScheduledKey(qname)
+	case "retry":
+		key = asynqRetryKey(qname)
+	case "archived":
+		key = asynqArchivedKey(qname)
+	case "completed":
+		key = asynqCompletedKey(qname)
+	default:
+		return nil, fmt.Errorf("cannot scan %s tasks", state)
+	}
+	res, next, err := rc.ZScan(ctx, key, cursor, "", filterScanBatchSize).Result()
+	if err != nil {
+		return nil, err
+	}
+	// ZSCAN returns members and scores alternately.
+	scan := &taskScan{}
+	for i := 0; i < len(res); i += 2 {
+		scan.ids = append(scan.ids, res[i])
+	}
+	scan.next = func(int) string {
+		if next == 0 {
+			return ""
+		}
+		return strconv.FormatUint(next, 10)
+	}
+	return scan, nil
+}
+
+// taskStateTimes returns the time of each of the tasks in the given state which the time range
+// of a filter applies to, or the zero time if it is not known:
+//   - pending: time the task was enqueued, or moved to the pending state
+//   - scheduled and retry: time the task is scheduled to be processed
+//   - archived: time the task was archived
+//   - completed: time the task was completed
+//
+// asynq does not keep the time tasks were originally enqueued.
+func taskStateTimes(ctx context.Context, rc redis.UniversalClient, qname, state string, tasks []*asynq.TaskInfo) ([]time.Time, error) {
+	times := make([]time.Time, len(tasks))
+	switch state {
+	case "pending", "archived":
+		pipe := rc.Pipeline()
+		cmds := make([]redis.Cmder, len(tasks))
+		for i, t := range tasks {
+			if state == "pending" {
+				cmds[i] = pipe.HGet(ctx, asynqTaskKey(qname, t.ID), "pending_since")
+			} else {
+				cmds[i] = pipe.ZScore(ctx, asynqArchivedKey(qname), t.ID)
+			}
+		}
+		if len(tasks) > 0 {
+			// Tasks may have been moved out of the state in the meantime.
+			if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+				return nil, err
+			}
+		}
+		for i, cmd := range cmds {
+			switch cmd := cmd.(type) {
+			case *redis.StringCmd:
+				if ns, err := cmd.Int64(); err == nil {
+					times[i] = time.Unix(0, ns)
+				}
+			case *redis.FloatCmd:
+				if sec, err := cmd.Result(); err == nil {
+					times[i] = time.Unix(int64(sec), 0)
+				}
+			}
+		}
+	case "scheduled", "retry":
+		for i, t := range tasks {
+			times[i] = t.NextProcessAt
+		}
+	case "completed":
+		for i, t := range tasks {
+			times[i] = t.CompletedAt
+		}
+	}
+	return times, nil
+}
+
+// applyTaskOp applies the operation ("delete", "archive", or "run") to the task.
+func applyTaskOp(inspector *asynq.Inspector, op, qname, id string) error {
+	switch op {
+	case "delete":
+		return inspector.DeleteTask(qname, id)
+	case "archive":
+		return inspector.ArchiveTask(qname, id)
+	case "run":
+		return inspector.RunTask(qname, id)
+	}
+	return fmt.Errorf("unknown operation %q", op)
+}
+
+// newTasksByFilterHandlerFunc returns a handler which applies the operation ("delete", "archive", or "run")
+// to the tasks in the given state matching the filter in the request body, one batch of tasks per request.
+func newTasksByFilterHandlerFunc(inspector *asynq.Inspector, rc redis.UniversalClient, pf *taskPayloadFormatter, state, op string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req tasksByFilterRequest
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		f, err := req.filter(pf)
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		var cursor uint64
+		if req.Cursor != "" {
+			if cursor, err = strconv.ParseUint(req.Cursor, 10, 64); err != nil {
+				writeBadRequestError(w, r, fmt.Sprintf("invalid request body: invalid cursor %q", req.Cursor))
+				return
+			}
+		}
+
+		qname := mux.Vars(r)["qname"]
+		if err := checkQueueExists(r.Context(), rc, qname); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		scan, err := scanTasks(r.Context(), rc, qname, state, cursor)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		var tasks []*asynq.TaskInfo
+		for _, id := range scan.ids {
+			info, err := inspector.GetTaskInfo(qname, id)
+			if err != nil || info.State.String() != state {
+				// The task may have been processed or deleted in the meantime.
+				continue
+			}
+			tasks = append(tasks, info)
+		}
+		times := make([]time.Time, len(tasks))
+		if f.hasTimeRange() {
+			if times, err = taskStateTimes(r.Context(), rc, qname, state, tasks); err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
+		resp := tasksByFilterResponse{Scanned: len(scan.ids)}
+		for i, info := range tasks {
+			if !f.match(info, times[i]) {
+				continue
+			}
+			if err := applyTaskOp(inspector, op, qname, info.ID); err != nil {
+				log.Printf("error: could not %s task with id %q: %v", op, info.ID, err)
+				resp.Failed++
+				continue
+			}
+			resp.Affected++
+		}
+		resp.Cursor = scan.next(resp.Affected)
+		writeResponseJSON(w, resp)
+	}
+}
+
+// Maximum number of tasks checked against the search query parameters of a task list request.
+const maxTaskSearchScan = 100000
+
+// taskLister lists a page of tasks, e.g. with Inspector.ListPendingTasks.
+type taskLister func(opts ...asynq.ListOption) ([]*asynq.TaskInfo, error)
+
+// parseTaskSearch returns the filter specified with the search query parameters
+// (type, payload_contains, payload_regexp, after, and before) of a request to list
+// tasks in the given state, or nil if there are none.
+func parseTaskSearch(r *http.Request, state string, pf *taskPayloadFormatter) (*taskFilter, error) {
+	q := r.URL.Query()
+	f, err := newTaskFilter(pf, q.Get("type"), q.Get("payload_contains"), q.Get("payload_regexp"), q.Get("after"), q.Get("before"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid query parameter: %v", err)
+	}
+	if f != nil && f.hasTimeRange() && (state == "active" || state == "aggregating") {
+		return nil, fmt.Errorf("invalid query parameter: after and before are not supported for %s tasks", state)
+	}
+	return f, nil
+}
+
+// taskSearchResult is included in task list responses if the list is filtered
+// with the search query parameters.
+type taskSearchResult struct {
+	// Number of tasks checked against the filter.
+	Scanned int `json:"scanned"`
+	// Indicates that the search stopped after checking maxTaskSearchScan tasks,
+	// so tasks further in the list are not returned.
+	Truncated bool `json:"truncated"`
+
+	// Number of tasks matching the filter, or -1 if the search stopped after
+	// finding the requested page.
+	total int
+}
+
+// scanMatchingTasks calls fn with each task in the given state matching the filter, in order,
+// until fn returns false or maxTaskSearchScan tasks are checked. It returns the number of tasks checked,
+// and whether it stopped before checking every task.
+func scanMatchingTasks(ctx context.Context, rc redis.UniversalClient, qname, state string, f *taskFilter, list taskLister, fn func(*asynq.TaskInfo) bool) (scanned int, truncated bool, err error) {
+	for page := 1; ; page++ {
+		tasks, err := list(asynq.PageSize(maxPageSize), asynq.Page(page))
+		if err != nil {
+			return scanned, false, err
+		}
+		times := make([]time.Time, len(tasks))
+		if f.hasTimeRange() {
+			if times, err = taskStateTimes(ctx, rc, qname, state, tasks); err != nil {
+				return scanned, false, err
+			}
+		}
+		for i, t := range tasks {
+			scanned++
+			if f.match(t, times[i]) && !fn(t) {
+				return scanned, true, nil
+			}
+		}
+		if len(tasks) < maxPageSize {
+			return scanned, false, nil
+		}
+		if scanned >= maxTaskSearchScan {
+			return scanned, true, nil
+		}
+	}
+}
+
+// searchTasks returns the page of the tasks in the given state matching the filter.
+// It lists every task with list, and stops once it finds the requested page and
+// whether there is a next page.
+func searchTasks(ctx context.Context, rc redis.UniversalClient, qname, state string, f *taskFilter, list taskLister, pageSize, pageNum int) ([]*asynq.TaskInfo, *taskSearchResult, error) {
+	skip := (pageNum - 1) * pageSize
+	matches := make([]*asynq.TaskInfo, 0, pageSize)
+	var n int // number of tasks matching the filter
+	scanned, truncated, err := scanMatchingTasks(ctx, rc, qname, state, f, list, func(t *asynq.TaskInfo) bool {
+		n++
+		if n > skip+pageSize {
+			return false
+		}
+		if n > skip {
+			matches = append(matches, t)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	res := &taskSearchResult{Scanned: scanned, total: n}
+	if n > skip+pageSize {
+		// Stopped after finding the task following the page.
+		res.total = -1
+	} else {
+		res.Truncated = truncated
+	}
+	return matches, res, nil
+}
+
+// countMatchingTasks returns the number of tasks in the given state matching the filter.
+// complete is false if it stopped after checking maxTaskSearchScan tasks.
+func countMatchingTasks(ctx context.Context, rc redis.UniversalClient, qname, state string, f *taskFilter, list taskLister) (n int, complete bool, err error) {
+	_, truncated, err := scanMatchingTasks(ctx, rc, qname, state, f, list, func(*asynq.TaskInfo) bool {
+		n++
+		return true
+	})
+	return n, !truncated, err
+}
+
+// totalOr returns the number of tasks matching the filter, or n if the list is not filtered.
+func (res *taskSearchResult) totalOr(n int) int {
+	if res == nil {
+		return n
+	}
+	return res.total
+}
+
+// listTasks returns the page of tasks listed with list, or of the tasks matching
+// the filter if not nil.
+func listTasks(ctx context.Context, rc redis.UniversalClient, qname, state string, f *taskFilter, list taskLister, pageSize, pageNum int) ([]*asynq.TaskInfo, *taskSearchResult, error) {
+	if f != nil {
+		return searchTasks(ctx, rc, qname, state, f, list, pageSize, pageNum)
+	}
+	tasks, err := list(asynq.PageSize(pageSize), asynq.Page(pageNum))
+	return tasks, nil, err
+}
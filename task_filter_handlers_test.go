@@ -0,0 +1,464 @@
+package asynqmon
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+)
+
+func TestNewTaskFilter(t *testing.T) {
+	tests := []struct {
+		desc                                                    string
+		taskType, payloadContains, payloadRegexp, after, before string
+		wantNil                                                 bool
+		wantErr                                                 bool
+	}{
+		{desc: "no conditions", wantNil: true},
+		{desc: "task type", taskType: "email:*"},
+		{desc: "time range", after: "2024-01-01T00:00:00Z", before: "2024-01-02T00:00:00Z"},
+		{desc: "invalid glob pattern", taskType: "email:[", wantErr: true},
+		{desc: "invalid regexp", payloadRegexp: "user_id\":(", wantErr: true},
+		{desc: "after not in RFC3339", after: "2024-01-01", wantErr: true},
+		{desc: "before not in RFC3339", before: "yesterday", wantErr: true},
+		{desc: "after equal to before", after: "2024-01-01T00:00:00Z", before: "2024-01-01T00:00:00Z", wantErr: true},
+		{desc: "after later than before", after: "2024-01-02T00:00:00Z", before: "2024-01-01T00:00:00Z", wantErr: true},
+	}
+	for _, tc := range tests {
+		f, err := newTaskFilter(&taskPayloadFormatter{}, tc.taskType, tc.payloadContains, tc.payloadRegexp, tc.after, tc.before)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: newTaskFilter returned error %v, want error %t", tc.desc, err, tc.wantErr)
+			continue
+		}
+		if !tc.wantErr && (f == nil) != tc.wantNil {
+			t.Errorf("%s: newTaskFilter returned %v, want nil %t", tc.desc, f, tc.wantNil)
+		}
+	}
+}
+
+func TestTaskFilterMatch(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	email := &asynq.TaskInfo{Type: "email:send", Payload: []byte(`{"user_id":42,"to":"alice@example.com"}`)}
+	report := &asynq.TaskInfo{Type: "report:generate", Payload: []byte(`{"user_id":7}`)}
+	tests := []struct {
+		desc                                                    string
+		taskType, payloadContains, payloadRegexp, after, before string
+		info                                                    *asynq.TaskInfo
+		t                                                       time.Time
+		want                                                    bool
+	}{
+		{desc: "exact task type", taskType: "email:send", info: email, want: true},
+		{desc: "task type glob", taskType: "email:*", info: email, want: true},
+		{desc: "task type glob of other type", taskType: "email:*", info: report, want: false},
+		{desc: "glob does not cross the separator", taskType: "*", info: email, want: true},
+		{desc: "task type is not a substring match", taskType: "email", info: email, want: false},
+		{desc: "payload contains", payloadContains: `"user_id":42`, info: email, want: true},
+		{desc: "payload does not contain", payloadContains: `"user_id":42`, info: report, want: false},
+		{desc: "payload contains is case sensitive", payloadContains: "Alice", info: email, want: false},
+		{desc: "payload regexp", payloadRegexp: `"user_id":\d{2}\b`, info: email, want: true},
+		{desc: "payload regexp not matching", payloadRegexp: `"user_id":\d{2}\b`, info: report, want: false},
+		{desc: "all conditions", taskType: "email:*", payloadContains: "example.com", payloadRegexp: `^\{`,
+			after: "2024-01-01T00:00:00Z", before: "2024-01-02T00:00:00Z", info: email, t: day.Add(time.Hour), want: true},
+		{desc: "one condition not met", taskType: "report:*", payloadContains: "example.com", info: email, want: false},
+		{desc: "after is inclusive", after: "2024-01-01T00:00:00Z", info: email, t: day, want: true},
+		{desc: "earlier than after", after: "2024-01-01T00:00:00Z", info: email, t: day.Add(-time.Second), want: false},
+		{desc: "before is exclusive", before: "2024-01-01T00:00:00Z", info: email, t: day, want: false},
+		{desc: "earlier than before", before: "2024-01-01T00:00:00Z", info: email, t: day.Add(-time.Second), want: true},
+		{desc: "unknown time does not match time range", after: "2024-01-01T00:00:00Z", info: email, want: false},
+		{desc: "unknown time matches filter without time range", taskType: "email:send", info: email, want: true},
+	}
+	for _, tc := range tests {
+		f, err := newTaskFilter(&taskPayloadFormatter{}, tc.taskType, tc.payloadContains, tc.payloadRegexp, tc.after, tc.before)
+		if err != nil {
+			t.Fatalf("%s: newTaskFilter returned error: %v", tc.desc, err)
+		}
+		if got := f.match(tc.info, tc.t); got != tc.want {
+			t.Errorf("%s: match(%s, %v) = %t, want %t", tc.desc, tc.info.Type, tc.t, got, tc.want)
+		}
+	}
+}
+
+// fakeTaskLister returns a taskLister of the given tasks.
+func fakeTaskLister(tasks []*asynq.TaskInfo) (list taskLister, calls *int) {
+	calls = new(int)
+	return func(opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+		*calls++
+		// The option types of asynq are not exported.
+		size, page := 30, 1
+		for _, opt := range opts {
+			switch fmt.Sprintf("%T", opt) {
+			case "asynq.pageSizeOpt":
+				size = int(reflect.ValueOf(opt).Int())
+			case "asynq.pageNumOpt":
+				page = int(reflect.ValueOf(opt).Int())
+			}
+		}
+		start := (page - 1) * size
+		if start >= len(tasks) {
+			return nil, nil
+		}
+		end := start + size
+		if end > len(tasks) {
+			end = len(tasks)
+		}
+		return tasks[start:end], nil
+	}, calls
+}
+
+// completedTasks returns n completed tasks whose IDs are their indexes, of type "match"
+// if matches(i), and "other" otherwise.
+func completedTasks(n int, matches func(i int) bool) []*asynq.TaskInfo {
+	tasks := make([]*asynq.TaskInfo, n)
+	for i := range tasks {
+		typename := "other"
+		if matches(i) {
+			typename = "match"
+		}
+		tasks[i] = &asynq.TaskInfo{ID: fmt.Sprint(i), Type: typename, CompletedAt: time.Unix(int64(i), 0)}
+	}
+	return tasks
+}
+
+func taskIDs(tasks []*asynq.TaskInfo) []string {
+	ids := make([]string, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+// everyThirdID returns the IDs of every third task of completedTasks.
+func everyThirdID(n int) []string {
+	var ids []string
+	for i := 0; i < n; i += 3 {
+		ids = append(ids, fmt.Sprint(i))
+	}
+	return ids
+}
+
+func TestSearchTasks(t *testing.T) {
+	everyThird := func(i int) bool { return i%3 == 0 }
+	tests := []struct {
+		desc              string
+		tasks             []*asynq.TaskInfo
+		after             string // filters by completion time if set, and by type otherwise
+		pageSize, pageNum int
+		wantIDs           []string
+		wantResult        taskSearchResult
+		wantListCalls     int
+	}{
+		{
+			desc:     "first page stops at the next match",
+			tasks:    completedTasks(10, everyThird),
+			pageSize: 2, pageNum: 1,
+			wantIDs:       []string{"0", "3"},
+			wantResult:    taskSearchResult{Scanned: 7, total: -1},
+			wantListCalls: 1,
+		},
+		{
+			desc:     "last page has the total",
+			tasks:    completedTasks(10, everyThird),
+			pageSize: 3, pageNum: 2,
+			wantIDs:       []string{"9"},
+			wantResult:    taskSearchResult{Scanned: 10, total: 4},
+			wantListCalls: 1,
+		},
+		{
+			desc:     "full last page",
+			tasks:    completedTasks(10, everyThird),
+			pageSize: 2, pageNum: 2,
+			wantIDs:       []string{"6", "9"},
+			wantResult:    taskSearchResult{Scanned: 10, total: 4},
+			wantListCalls: 1,
+		},
+		{
+			desc:     "page past the end",
+			tasks:    completedTasks(10, everyThird),
+			pageSize: 2, pageNum: 5,
+			wantIDs:       []string{},
+			wantResult:    taskSearchResult{Scanned: 10, total: 4},
+			wantListCalls: 1,
+		},
+		{
+			desc:     "no matches",
+			tasks:    completedTasks(10, func(int) bool { return false }),
+			pageSize: 2, pageNum: 1,
+			wantIDs:       []string{},
+			wantResult:    taskSearchResult{Scanned: 10, total: 0},
+			wantListCalls: 1,
+		},
+		{
+			desc:     "matches across list pages",
+			tasks:    completedTasks(2*maxPageSize+10, func(i int) bool { return i == 5 || i == maxPageSize+5 || i == 2*maxPageSize+5 }),
+			pageSize: 1, pageNum: 2,
+			wantIDs:       []string{fmt.Sprint(maxPageSize + 5)},
+			wantResult:    taskSearchResult{Scanned: 2*maxPageSize + 6, total: -1},
+			wantListCalls: 3,
+		},
+		{
+			desc:     "list of a multiple of the list page size",
+			tasks:    completedTasks(maxPageSize, everyThird),
+			pageSize: 1000, pageNum: 1,
+			wantIDs:       everyThirdID(maxPageSize),
+			wantResult:    taskSearchResult{Scanned: maxPageSize, total: 334},
+			wantListCalls: 2,
+		},
+		{
+			desc:     "time range",
+			tasks:    completedTasks(10, everyThird),
+			after:    time.Unix(4, 0).UTC().Format(time.RFC3339),
+			pageSize: 3, pageNum: 1,
+			wantIDs:       []string{"4", "5", "6"},
+			wantResult:    taskSearchResult{Scanned: 8, total: -1},
+			wantListCalls: 1,
+		},
+		{
+			desc:     "search stops after maxTaskSearchScan tasks",
+			tasks:    completedTasks(maxTaskSearchScan+maxPageSize, func(i int) bool { return i == 1 || i == maxTaskSearchScan+1 }),
+			pageSize: 10, pageNum: 1,
+			wantIDs:       []string{"1"},
+			wantResult:    taskSearchResult{Scanned: maxTaskSearchScan, Truncated: true, total: 1},
+			wantListCalls: maxTaskSearchScan / maxPageSize,
+		},
+	}
+	for _, tc := range tests {
+		taskType := "match"
+		if tc.after != "" {
+			taskType = ""
+		}
+		f, err := newTaskFilter(&taskPayloadFormatter{}, taskType, "", "", tc.after, "")
+		if err != nil {
+			t.Fatalf("%s: newTaskFilter returned error: %v", tc.desc, err)
+		}
+		list, calls := fakeTaskLister(tc.tasks)
+		// Times of completed tasks are read from the task info, not from redis.
+		got, res, err := searchTasks(nil, nil, "default", "completed", f, list, tc.pageSize, tc.pageNum)
+		if err != nil {
+			t.Errorf("%s: searchTasks returned error: %v", tc.desc, err)
+			continue
+		}
+		if diff := cmp.Diff(tc.wantIDs, taskIDs(got)); diff != "" {
+			t.Errorf("%s: searchTasks returned tasks diff (-want,+got):\n%s", tc.desc, diff)
+		}
+		if diff := cmp.Diff(tc.wantResult, *res, cmp.AllowUnexported(taskSearchResult{})); diff != "" {
+			t.Errorf("%s: searchTasks returned result diff (-want,+got):\n%s", tc.desc, diff)
+		}
+		if *calls != tc.wantListCalls {
+			t.Errorf("%s: searchTasks listed %d pages, want %d", tc.desc, *calls, tc.wantListCalls)
+		}
+	}
+}
+
+func TestCountMatchingTasks(t *testing.T) {
+	f, err := newTaskFilter(&taskPayloadFormatter{}, "match", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		desc         string
+		tasks        []*asynq.TaskInfo
+		wantN        int
+		wantComplete bool
+	}{
+		{"empty state", nil, 0, true},
+		{"every task checked", completedTasks(2*maxPageSize+1, func(i int) bool { return i%2 == 0 }), maxPageSize + 1, true},
+		{"too many tasks to check", completedTasks(maxTaskSearchScan+1, func(i int) bool { return i < 5 }), 5, false},
+	}
+	for _, tc := range tests {
+		list, _ := fakeTaskLister(tc.tasks)
+		n, complete, err := countMatchingTasks(nil, nil, "default", "completed", f, list)
+		if err != nil {
+			t.Errorf("%s: countMatchingTasks returned error: %v", tc.desc, err)
+			continue
+		}
+		if n != tc.wantN || complete != tc.wantComplete {
+			t.Errorf("%s: countMatchingTasks = %d, %t; want %d, %t", tc.desc, n, complete, tc.wantN, tc.wantComplete)
+		}
+	}
+}
+
+func TestTasksByFilterCursor(t *testing.T) {
+	tests := []struct {
+		desc         string
+		state        string
+		n            int // number of tasks, of which every other task matches
+		wantRequests int
+	}{
+		{"pending tasks in a single batch", "pending", 10, 1},
+		// The offset of the next batch skips the tasks remaining in the list.
+		{"pending tasks in several batches", "pending", 3*filterScanBatchSize + 7, 4},
+		// miniredis returns every member of the sorted set for the first ZSCAN.
+		{"archived tasks", "archived", 3*filterScanBatchSize + 7, 1},
+	}
+	for _, tc := range tests {
+		e := newTaskMoveEnv(t)
+		for i := 0; i < tc.n; i++ {
+			typename := "report:generate"
+			if i%2 == 0 {
+				typename = "email:send"
+			}
+			id := fmt.Sprintf("task%d", i)
+			if _, err := e.client.Enqueue(asynq.NewTask(typename, nil), asynq.TaskID(id)); err != nil {
+				t.Fatal(err)
+			}
+			if tc.state != "pending" {
+				e.setState("default", id, "pending", tc.state)
+			}
+		}
+		h := newTasksByFilterHandlerFunc(e.inspector, e.rc, &taskPayloadFormatter{}, tc.state, "delete")
+
+		var cursor string
+		var affected, requests int
+		for {
+			requests++
+			if requests > tc.n {
+				t.Fatalf("%s: cursor did not reach the end after %d requests", tc.desc, requests)
+			}
+			body := fmt.Sprintf(`{"task_type":"email:*","cursor":%q}`, cursor)
+			r := httptest.NewRequest("POST", "/api/queues/default/"+tc.state+"_tasks:delete_matching", strings.NewReader(body))
+			r = mux.SetURLVars(r, map[string]string{"qname": "default"})
+			w := httptest.NewRecorder()
+			h(w, r)
+			if w.Code != 200 {
+				t.Fatalf("%s: responded with %d: %s", tc.desc, w.Code, w.Body)
+			}
+			var resp tasksByFilterResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Failed != 0 {
+				t.Errorf("%s: failed for %d tasks", tc.desc, resp.Failed)
+			}
+			affected += resp.Affected
+			if cursor = resp.Cursor; cursor == "" {
+				break
+			}
+		}
+		if requests != tc.wantRequests {
+			t.Errorf("%s: sent %d requests, want %d", tc.desc, requests, tc.wantRequests)
+		}
+		if want := (tc.n + 1) / 2; affected != want {
+			t.Errorf("%s: deleted %d tasks, want %d", tc.desc, affected, want)
+		}
+		for i := 0; i < tc.n; i++ {
+			exists := e.mr.Exists(asynqTaskKey("default", fmt.Sprintf("task%d", i)))
+			if want := i%2 == 1; exists != want {
+				t.Errorf("%s: task%d exists = %t, want %t", tc.desc, i, exists, want)
+			}
+		}
+	}
+
+	// Invalid cursors are rejected.
+	e := newTaskMoveEnv(t)
+	e.enqueue("default", "task1")
+	h := newTasksByFilterHandlerFunc(e.inspector, e.rc, &taskPayloadFormatter{}, "pending", "delete")
+	for _, cursor := range []string{"-1", "abc", "1.5"} {
+		r := httptest.NewRequest("POST", "/api/queues/default/pending_tasks:delete_matching",
+			strings.NewReader(fmt.Sprintf(`{"task_type":"*","cursor":%q}`, cursor)))
+		r = mux.SetURLVars(r, map[string]string{"qname": "default"})
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != 400 {
+			t.Errorf("request with cursor %q responded with %d, want 400", cursor, w.Code)
+		}
+	}
+}
+
+func TestTaskFilterMatchDecodedPayload(t *testing.T) {
+	const payload = `{"user_id":42}`
+	gzipped := func(s string) []byte {
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		w.Write([]byte(s))
+		w.Close()
+		return b.Bytes()
+	}
+	zlibbed := func(s string) []byte {
+		var b bytes.Buffer
+		w := zlib.NewWriter(&b)
+		w.Write([]byte(s))
+		w.Close()
+		return b.Bytes()
+	}
+	// Encrypts the payload by reversing the bytes of payloads of "secret:*" tasks.
+	reverse := func(b []byte) []byte {
+		out := make([]byte, len(b))
+		for i, c := range b {
+			out[len(b)-1-i] = c
+		}
+		return out
+	}
+	decrypt := func(taskType string, b []byte) ([]byte, error) {
+		if !strings.HasPrefix(taskType, "secret:") {
+			return nil, errors.New("not encrypted")
+		}
+		return reverse(b), nil
+	}
+	// Decompresses payloads compressed by repeating each byte.
+	decompress := func(taskType string, b []byte) ([]byte, error) {
+		if len(b)%2 != 0 {
+			return nil, errors.New("not compressed")
+		}
+		out := make([]byte, len(b)/2)
+		for i := range out {
+			out[i] = b[2*i]
+		}
+		return out, nil
+	}
+	doubled := func(s string) []byte {
+		var out []byte
+		for _, c := range []byte(s) {
+			out = append(out, c, c)
+		}
+		return out
+	}
+
+	tests := []struct {
+		desc     string
+		pf       *taskPayloadFormatter
+		taskType string
+		payload  []byte
+		want     bool
+	}{
+		{"plain payload", &taskPayloadFormatter{}, "email:send", []byte(payload), true},
+		{"gzip compressed payload", &taskPayloadFormatter{}, "email:send", gzipped(payload), true},
+		{"zlib compressed payload", &taskPayloadFormatter{}, "email:send", zlibbed(payload), true},
+		{"payload which looks compressed", &taskPayloadFormatter{}, "email:send", append([]byte{0x1f, 0x8b}, payload...), true},
+		{"encrypted payload", &taskPayloadFormatter{decrypt: decrypt}, "secret:send", reverse([]byte(payload)), true},
+		{"encrypted payload without decryption", &taskPayloadFormatter{}, "secret:send", reverse([]byte(payload)), false},
+		{"payload which cannot be decrypted is matched as is", &taskPayloadFormatter{decrypt: decrypt}, "email:send", []byte(payload), true},
+		{"encrypted gzip compressed payload", &taskPayloadFormatter{decrypt: decrypt}, "secret:send", reverse(gzipped(payload)), true},
+		{"payload decompressed with DecompressPayload", &taskPayloadFormatter{decompress: decompress}, "email:send", doubled(payload), true},
+		{"gzip is not used with DecompressPayload", &taskPayloadFormatter{decompress: decompress}, "email:send", gzipped(payload + " "), false},
+		{"payload which DecompressPayload fails for is matched as is", &taskPayloadFormatter{decompress: decompress}, "email:send", []byte(payload + " "), true},
+		{"encrypted payload decompressed with DecompressPayload", &taskPayloadFormatter{decrypt: decrypt, decompress: decompress},
+			"secret:send", reverse(doubled(payload)), true},
+	}
+	for _, tc := range tests {
+		for _, cond := range []struct{ contains, regexp string }{
+			{contains: `"user_id":42`},
+			{regexp: `"user_id":\d+\}\s?$`},
+		} {
+			f, err := newTaskFilter(tc.pf, "", cond.contains, cond.regexp, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			info := &asynq.TaskInfo{Type: tc.taskType, Payload: tc.payload}
+			if got := f.match(info, time.Time{}); got != tc.want {
+				t.Errorf("%s: match with payload_contains %q and payload_regexp %q = %t, want %t",
+					tc.desc, cond.contains, cond.regexp, got, tc.want)
+			}
+		}
+	}
+}
@@ -2,16 +2,15 @@ package asynqmon
 
 import (
 	"encoding/json"
-	"errors"
 	"log"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
 )
 
 // ****************************************************************************
@@ -20,30 +19,48 @@ import (
 // ****************************************************************************
 
 type listActiveTasksResponse struct {
-	Tasks []*activeTask       `json:"tasks"`
-	Stats *queueStateSnapshot `json:"stats"`
+	// []*activeTask, or items with only the fields selected with the fields parameter.
+	Tasks      interface{}         `json:"tasks"`
+	Stats      *queueStateSnapshot `json:"stats"`
+	Pagination *pagination         `json:"pagination"`
+	Search     *taskSearchResult   `json:"search,omitempty"`
 }
 
-func newListActiveTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+func newListActiveTasksHandlerFunc(inspector *asynq.Inspector, rc redis.UniversalClient, pf *taskPayloadFormatter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		qname := vars["qname"]
-		pageSize, pageNum := getPageOptions(r)
+		pageSize, pageNum, err := getPageOptions(r)
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		fields, err := parseFields(r, activeTask{}, "id")
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
 
-		tasks, err := inspector.ListActiveTasks(
-			qname, asynq.PageSize(pageSize), asynq.Page(pageNum))
+		search, err := parseTaskSearch(r, "active", pf)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		tasks, result, err := listTasks(r.Context(), rc, qname, "active", search, func(opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+			return inspector.ListActiveTasks(qname, opts...)
+		}, pageSize, pageNum)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		servers, err := inspector.Servers()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		// m maps taskID to workerInfo.
@@ -55,7 +72,7 @@ func newListActiveTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatt
 				}
 			}
 		}
-		activeTasks := toActiveTasks(tasks, pf)
+		activeTasks := toActiveTasks(tasks, pf.forFields(fields))
 		for _, t := range activeTasks {
 			workerInfo, ok := m[t.ID]
 			if ok {
@@ -67,10 +84,18 @@ func newListActiveTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatt
 			}
 		}
 
+		selected, err := selectFields(activeTasks, fields)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 		resp := listActiveTasksResponse{
-			Tasks: activeTasks,
-			Stats: toQueueStateSnapshot(qinfo),
+			Tasks:      selected,
+			Stats:      toQueueStateSnapshot(qinfo),
+			Pagination: newPagination(pageNum, pageSize, result.totalOr(qinfo.Active), len(tasks)),
+			Search:     result,
 		}
+		setLinkHeader(w, r, resp.Pagination)
 		writeResponseJSON(w, resp)
 	}
 }
@@ -79,7 +104,7 @@ func newCancelActiveTaskHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := mux.Vars(r)["task_id"]
 		if err := inspector.CancelProcessing(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -94,12 +119,12 @@ func newCancelAllActiveTasksHandlerFunc(inspector *asynq.Inspector) http.Handler
 		for {
 			tasks, err := inspector.ListActiveTasks(qname, asynq.Page(page), asynq.PageSize(batchSize))
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeError(w, r, err)
 				return
 			}
 			for _, t := range tasks {
 				if err := inspector.CancelProcessing(t.ID); err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
+					writeError(w, r, err)
 					return
 				}
 			}
@@ -123,13 +148,13 @@ type batchCancelTasksResponse struct {
 
 func newBatchCancelActiveTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
-		dec := json.NewDecoder(r.Body)
-		dec.DisallowUnknownFields()
-
 		var req batchCancelTasksRequest
-		if err := dec.Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if err := validateTaskIDs(req.TaskIDs); err != nil {
+			writeBadRequestError(w, r, err.Error())
 			return
 		}
 
@@ -150,20 +175,35 @@ func newBatchCancelActiveTasksHandlerFunc(inspector *asynq.Inspector) http.Handl
 	}
 }
 
-func newListPendingTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+func newListPendingTasksHandlerFunc(inspector *asynq.Inspector, rc redis.UniversalClient, pf *taskPayloadFormatter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		qname := vars["qname"]
-		pageSize, pageNum := getPageOptions(r)
-		tasks, err := inspector.ListPendingTasks(
-			qname, asynq.PageSize(pageSize), asynq.Page(pageNum))
+		pageSize, pageNum, err := getPageOptions(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		fields, err := parseFields(r, pendingTask{}, "id")
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		search, err := parseTaskSearch(r, "pending", pf)
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		tasks, result, err := listTasks(r.Context(), rc, qname, "pending", search, func(opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+			return inspector.ListPendingTasks(qname, opts...)
+		}, pageSize, pageNum)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		payload := make(map[string]interface{})
@@ -171,27 +211,52 @@ func newListPendingTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormat
 			// avoid nil for the tasks field in json output.
 			payload["tasks"] = make([]*pendingTask, 0)
 		} else {
-			payload["tasks"] = toPendingTasks(tasks, pf)
+			payload["tasks"], err = selectFields(toPendingTasks(tasks, pf.forFields(fields)), fields)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
 		}
+		if result != nil {
+			payload["search"] = result
+		}
+		pg := newPagination(pageNum, pageSize, result.totalOr(qinfo.Pending), len(tasks))
+		setLinkHeader(w, r, pg)
 		payload["stats"] = toQueueStateSnapshot(qinfo)
+		payload["pagination"] = pg
 		writeResponseJSON(w, payload)
 	}
 }
 
-func newListScheduledTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+func newListScheduledTasksHandlerFunc(inspector *asynq.Inspector, rc redis.UniversalClient, pf *taskPayloadFormatter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		qname := vars["qname"]
-		pageSize, pageNum := getPageOptions(r)
-		tasks, err := inspector.ListScheduledTasks(
-			qname, asynq.PageSize(pageSize), asynq.Page(pageNum))
+		pageSize, pageNum, err := getPageOptions(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		fields, err := parseFields(r, scheduledTask{}, "id")
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		search, err := parseTaskSearch(r, "scheduled", pf)
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		tasks, result, err := listTasks(r.Context(), rc, qname, "scheduled", search, func(opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+			return inspector.ListScheduledTasks(qname, opts...)
+		}, pageSize, pageNum)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		payload := make(map[string]interface{})
@@ -199,27 +264,52 @@ func newListScheduledTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadForm
 			// avoid nil for the tasks field in json output.
 			payload["tasks"] = make([]*scheduledTask, 0)
 		} else {
-			payload["tasks"] = toScheduledTasks(tasks, pf)
+			payload["tasks"], err = selectFields(toScheduledTasks(tasks, pf.forFields(fields)), fields)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
 		}
+		if result != nil {
+			payload["search"] = result
+		}
+		pg := newPagination(pageNum, pageSize, result.totalOr(qinfo.Scheduled), len(tasks))
+		setLinkHeader(w, r, pg)
 		payload["stats"] = toQueueStateSnapshot(qinfo)
+		payload["pagination"] = pg
 		writeResponseJSON(w, payload)
 	}
 }
 
-func newListRetryTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+func newListRetryTasksHandlerFunc(inspector *asynq.Inspector, rc redis.UniversalClient, pf *taskPayloadFormatter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		qname := vars["qname"]
-		pageSize, pageNum := getPageOptions(r)
-		tasks, err := inspector.ListRetryTasks(
-			qname, asynq.PageSize(pageSize), asynq.Page(pageNum))
+		pageSize, pageNum, err := getPageOptions(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		fields, err := parseFields(r, retryTask{}, "id")
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		search, err := parseTaskSearch(r, "retry", pf)
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		tasks, result, err := listTasks(r.Context(), rc, qname, "retry", search, func(opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+			return inspector.ListRetryTasks(qname, opts...)
+		}, pageSize, pageNum)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		payload := make(map[string]interface{})
@@ -227,27 +317,52 @@ func newListRetryTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatte
 			// avoid nil for the tasks field in json output.
 			payload["tasks"] = make([]*retryTask, 0)
 		} else {
-			payload["tasks"] = toRetryTasks(tasks, pf)
+			payload["tasks"], err = selectFields(toRetryTasks(tasks, pf.forFields(fields)), fields)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
+		if result != nil {
+			payload["search"] = result
 		}
+		pg := newPagination(pageNum, pageSize, result.totalOr(qinfo.Retry), len(tasks))
+		setLinkHeader(w, r, pg)
 		payload["stats"] = toQueueStateSnapshot(qinfo)
+		payload["pagination"] = pg
 		writeResponseJSON(w, payload)
 	}
 }
 
-func newListArchivedTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+func newListArchivedTasksHandlerFunc(inspector *asynq.Inspector, rc redis.UniversalClient, pf *taskPayloadFormatter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		qname := vars["qname"]
-		pageSize, pageNum := getPageOptions(r)
-		tasks, err := inspector.ListArchivedTasks(
-			qname, asynq.PageSize(pageSize), asynq.Page(pageNum))
+		pageSize, pageNum, err := getPageOptions(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		fields, err := parseFields(r, archivedTask{}, "id")
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		search, err := parseTaskSearch(r, "archived", pf)
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		tasks, result, err := listTasks(r.Context(), rc, qname, "archived", search, func(opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+			return inspector.ListArchivedTasks(qname, opts...)
+		}, pageSize, pageNum)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		payload := make(map[string]interface{})
@@ -255,26 +370,52 @@ func newListArchivedTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadForma
 			// avoid nil for the tasks field in json output.
 			payload["tasks"] = make([]*archivedTask, 0)
 		} else {
-			payload["tasks"] = toArchivedTasks(tasks, pf)
+			payload["tasks"], err = selectFields(toArchivedTasks(tasks, pf.forFields(fields)), fields)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
+		if result != nil {
+			payload["search"] = result
 		}
+		pg := newPagination(pageNum, pageSize, result.totalOr(qinfo.Archived), len(tasks))
+		setLinkHeader(w, r, pg)
 		payload["stats"] = toQueueStateSnapshot(qinfo)
+		payload["pagination"] = pg
 		writeResponseJSON(w, payload)
 	}
 }
 
-func newListCompletedTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter, rf ResultFormatter) http.HandlerFunc {
+func newListCompletedTasksHandlerFunc(inspector *asynq.Inspector, rc redis.UniversalClient, pf *taskPayloadFormatter, rf ResultFormatter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		qname := vars["qname"]
-		pageSize, pageNum := getPageOptions(r)
-		tasks, err := inspector.ListCompletedTasks(qname, asynq.PageSize(pageSize), asynq.Page(pageNum))
+		pageSize, pageNum, err := getPageOptions(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		fields, err := parseFields(r, completedTask{}, "id")
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		search, err := parseTaskSearch(r, "completed", pf)
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		tasks, result, err := listTasks(r.Context(), rc, qname, "completed", search, func(opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+			return inspector.ListCompletedTasks(qname, opts...)
+		}, pageSize, pageNum)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		payload := make(map[string]interface{})
@@ -282,33 +423,58 @@ func newListCompletedTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadForm
 			// avoid nil for the tasks field in json output.
 			payload["tasks"] = make([]*completedTask, 0)
 		} else {
-			payload["tasks"] = toCompletedTasks(tasks, pf, rf)
+			payload["tasks"], err = selectFields(toCompletedTasks(tasks, pf.forFields(fields), resultFormatterForFields(rf, fields)), fields)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
+		if result != nil {
+			payload["search"] = result
 		}
+		pg := newPagination(pageNum, pageSize, result.totalOr(qinfo.Completed), len(tasks))
+		setLinkHeader(w, r, pg)
 		payload["stats"] = toQueueStateSnapshot(qinfo)
+		payload["pagination"] = pg
 		writeResponseJSON(w, payload)
 	}
 }
 
-func newListAggregatingTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+func newListAggregatingTasksHandlerFunc(inspector *asynq.Inspector, rc redis.UniversalClient, pf *taskPayloadFormatter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		qname := vars["qname"]
 		gname := vars["gname"]
-		pageSize, pageNum := getPageOptions(r)
-		tasks, err := inspector.ListAggregatingTasks(
-			qname, gname, asynq.PageSize(pageSize), asynq.Page(pageNum))
+		pageSize, pageNum, err := getPageOptions(r)
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		fields, err := parseFields(r, aggregatingTask{}, "id")
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		search, err := parseTaskSearch(r, "aggregating", pf)
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		tasks, result, err := listTasks(r.Context(), rc, qname, "aggregating", search, func(opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+			return inspector.ListAggregatingTasks(qname, gname, opts...)
+		}, pageSize, pageNum)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		groups, err := inspector.Groups(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		payload := make(map[string]interface{})
@@ -316,10 +482,26 @@ func newListAggregatingTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFo
 			// avoid nil for the tasks field in json output.
 			payload["tasks"] = make([]*aggregatingTask, 0)
 		} else {
-			payload["tasks"] = toAggregatingTasks(tasks, pf)
+			payload["tasks"], err = selectFields(toAggregatingTasks(tasks, pf.forFields(fields)), fields)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
 		}
+		total := 0
+		for _, g := range groups {
+			if g.Group == gname {
+				total = g.Size
+			}
+		}
+		if result != nil {
+			payload["search"] = result
+		}
+		pg := newPagination(pageNum, pageSize, result.totalOr(total), len(tasks))
+		setLinkHeader(w, r, pg)
 		payload["stats"] = toQueueStateSnapshot(qinfo)
 		payload["groups"] = toGroupInfos(groups)
+		payload["pagination"] = pg
 		writeResponseJSON(w, payload)
 	}
 }
@@ -329,12 +511,11 @@ func newDeleteTaskHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 		vars := mux.Vars(r)
 		qname, taskid := vars["qname"], vars["task_id"]
 		if qname == "" || taskid == "" {
-			http.Error(w, "route parameters should not be empty", http.StatusBadRequest)
+			writeBadRequestError(w, r, "route parameters should not be empty")
 			return
 		}
 		if err := inspector.DeleteTask(qname, taskid); err != nil {
-			// TODO: Handle task not found error and return 404
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -346,12 +527,11 @@ func newRunTaskHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 		vars := mux.Vars(r)
 		qname, taskid := vars["qname"], vars["task_id"]
 		if qname == "" || taskid == "" {
-			http.Error(w, "route parameters should not be empty", http.StatusBadRequest)
+			writeBadRequestError(w, r, "route parameters should not be empty")
 			return
 		}
 		if err := inspector.RunTask(qname, taskid); err != nil {
-			// TODO: Handle task not found error and return 404
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -363,12 +543,11 @@ func newArchiveTaskHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 		vars := mux.Vars(r)
 		qname, taskid := vars["qname"], vars["task_id"]
 		if qname == "" || taskid == "" {
-			http.Error(w, "route parameters should not be empty", http.StatusBadRequest)
+			writeBadRequestError(w, r, "route parameters should not be empty")
 			return
 		}
 		if err := inspector.ArchiveTask(qname, taskid); err != nil {
-			// TODO: Handle task not found error and return 404
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -385,7 +564,7 @@ func newDeleteAllPendingTasksHandlerFunc(inspector *asynq.Inspector) http.Handle
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.DeleteAllPendingTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, deleteAllTasksResponse{n})
@@ -398,7 +577,7 @@ func newDeleteAllAggregatingTasksHandlerFunc(inspector *asynq.Inspector) http.Ha
 		qname, gname := vars["qname"], vars["gname"]
 		n, err := inspector.DeleteAllAggregatingTasks(qname, gname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, deleteAllTasksResponse{n})
@@ -410,7 +589,7 @@ func newDeleteAllScheduledTasksHandlerFunc(inspector *asynq.Inspector) http.Hand
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.DeleteAllScheduledTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, deleteAllTasksResponse{n})
@@ -422,7 +601,7 @@ func newDeleteAllRetryTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerF
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.DeleteAllRetryTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, deleteAllTasksResponse{n})
@@ -434,7 +613,7 @@ func newDeleteAllArchivedTasksHandlerFunc(inspector *asynq.Inspector) http.Handl
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.DeleteAllArchivedTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, deleteAllTasksResponse{n})
@@ -446,7 +625,7 @@ func newDeleteAllCompletedTasksHandlerFunc(inspector *asynq.Inspector) http.Hand
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.DeleteAllCompletedTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, deleteAllTasksResponse{n})
@@ -463,7 +642,7 @@ func newRunAllScheduledTasksHandlerFunc(inspector *asynq.Inspector) http.Handler
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.RunAllScheduledTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, runAllTasksResponse{n})
@@ -475,7 +654,7 @@ func newRunAllRetryTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.RunAllRetryTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, runAllTasksResponse{n})
@@ -487,7 +666,7 @@ func newRunAllArchivedTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerF
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.RunAllArchivedTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, runAllTasksResponse{n})
@@ -500,7 +679,7 @@ func newRunAllAggregatingTasksHandlerFunc(inspector *asynq.Inspector) http.Handl
 		qname, gname := vars["qname"], vars["gname"]
 		n, err := inspector.RunAllAggregatingTasks(qname, gname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, runAllTasksResponse{n})
@@ -523,7 +702,7 @@ func newArchiveAllPendingTasksHandlerFunc(inspector *asynq.Inspector) http.Handl
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.ArchiveAllPendingTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, archiveAllTasksResponse{n})
@@ -536,7 +715,7 @@ func newArchiveAllAggregatingTasksHandlerFunc(inspector *asynq.Inspector) http.H
 		qname, gname := vars["qname"], vars["gname"]
 		n, err := inspector.ArchiveAllAggregatingTasks(qname, gname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, archiveAllTasksResponse{n})
@@ -548,7 +727,7 @@ func newArchiveAllScheduledTasksHandlerFunc(inspector *asynq.Inspector) http.Han
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.ArchiveAllScheduledTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, archiveAllTasksResponse{n})
@@ -560,7 +739,7 @@ func newArchiveAllRetryTasksHandlerFunc(inspector *asynq.Inspector) http.Handler
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.ArchiveAllRetryTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, archiveAllTasksResponse{n})
@@ -588,15 +767,26 @@ type batchDeleteTasksResponse struct {
 // Allow up to 1MB in size.
 const maxRequestBodySize = 1000000
 
+// requestBodyLimit returns the maximum body size in bytes of the request,
+// for middleware which reads the body before the handler.
+func requestBodyLimit(r *http.Request) int64 {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil && strings.HasSuffix(tmpl, ":import") {
+			return maxImportRequestBodySize
+		}
+	}
+	return maxRequestBodySize
+}
+
 func newBatchDeleteTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
-		dec := json.NewDecoder(r.Body)
-		dec.DisallowUnknownFields()
-
 		var req batchDeleteTasksRequest
-		if err := dec.Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if err := validateTaskIDs(req.TaskIDs); err != nil {
+			writeBadRequestError(w, r, err.Error())
 			return
 		}
 
@@ -631,13 +821,13 @@ type batchRunTasksResponse struct {
 
 func newBatchRunTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
-		dec := json.NewDecoder(r.Body)
-		dec.DisallowUnknownFields()
-
 		var req batchRunTasksRequest
-		if err := dec.Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if err := validateTaskIDs(req.TaskIDs); err != nil {
+			writeBadRequestError(w, r, err.Error())
 			return
 		}
 
@@ -672,13 +862,13 @@ type batchArchiveTasksResponse struct {
 
 func newBatchArchiveTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
-		dec := json.NewDecoder(r.Body)
-		dec.DisallowUnknownFields()
-
 		var req batchArchiveTasksRequest
-		if err := dec.Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if err := validateTaskIDs(req.TaskIDs); err != nil {
+			writeBadRequestError(w, r, err.Error())
 			return
 		}
 
@@ -700,48 +890,30 @@ func newBatchArchiveTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFun
 	}
 }
 
-// getPageOptions read page size and number from the request url if set,
-// otherwise it returns the default value.
-func getPageOptions(r *http.Request) (pageSize, pageNum int) {
-	pageSize = 20 // default page size
-	pageNum = 1   // default page num
-	q := r.URL.Query()
-	if s := q.Get("size"); s != "" {
-		if n, err := strconv.Atoi(s); err == nil {
-			pageSize = n
-		}
-	}
-	if s := q.Get("page"); s != "" {
-		if n, err := strconv.Atoi(s); err == nil {
-			pageNum = n
-		}
-	}
-	return pageSize, pageNum
-}
-
-func newGetTaskHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter, rf ResultFormatter) http.HandlerFunc {
+func newGetTaskHandlerFunc(inspector *asynq.Inspector, pf *taskPayloadFormatter, rf ResultFormatter, links []*taskLinkTemplate, tracer *traceIDExtractor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		qname, taskid := vars["qname"], vars["task_id"]
 		if qname == "" {
-			http.Error(w, "queue name cannot be empty", http.StatusBadRequest)
+			writeBadRequestError(w, r, "queue name cannot be empty")
 			return
 		}
 		if taskid == "" {
-			http.Error(w, "task_id cannot be empty", http.StatusBadRequest)
+			writeBadRequestError(w, r, "task_id cannot be empty")
 			return
 		}
 
 		info, err := inspector.GetTaskInfo(qname, taskid)
-		switch {
-		case errors.Is(err, asynq.ErrQueueNotFound), errors.Is(err, asynq.ErrTaskNotFound):
-			http.Error(w, strings.TrimPrefix(err.Error(), "asynq: "), http.StatusNotFound)
-			return
-		case err != nil:
-			http.Error(w, strings.TrimPrefix(err.Error(), "asynq: "), http.StatusInternalServerError)
+		if err != nil {
+			// Responds with 404 if the queue or the task is not found.
+			status, code := classifyError(err)
+			writeErrorResponse(w, r, status, code, strings.TrimPrefix(err.Error(), "asynq: "))
 			return
 		}
 
-		writeResponseJSON(w, toTaskInfo(info, pf, rf))
+		resp := toTaskInfo(info, pf, rf)
+		resp.Links = buildTaskLinks(links, info)
+		resp.TraceID, resp.TraceURL = tracer.extract(info)
+		writeResponseJSON(w, resp)
 	}
 }
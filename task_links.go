@@ -0,0 +1,87 @@
+package asynqmon
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"text/template"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - TaskLink which configures links shown in the task details view
+// ****************************************************************************
+
+// TaskLink configures a link into an external system (e.g. logs, traces)
+// shown in the task details view.
+type TaskLink struct {
+	// TaskType is a pattern matched against the task type using path.Match
+	// (e.g. "email:*"). Empty pattern matches every task type.
+	TaskType string
+
+	// Label is the text shown on the link (e.g. "View logs").
+	Label string
+
+	// URL is a text/template string used to build the link.
+	// The template is executed with the following fields:
+	//
+	//	.ID, .Queue, .Type, .State
+	//
+	// Use the urlquery function to escape values placed in query parameters
+	// (e.g. "https://logs.example.com/search?q={{.ID | urlquery}}").
+	URL string
+}
+
+// taskLinkTemplate is a parsed TaskLink.
+type taskLinkTemplate struct {
+	taskType string
+	label    string
+	url      *template.Template
+}
+
+// parseTaskLinks parses the URL templates of the given links.
+func parseTaskLinks(links []TaskLink) ([]*taskLinkTemplate, error) {
+	var out []*taskLinkTemplate
+	for _, l := range links {
+		if _, err := path.Match(l.TaskType, ""); err != nil {
+			return nil, fmt.Errorf("invalid task type pattern %q: %v", l.TaskType, err)
+		}
+		tmpl, err := template.New(l.Label).Option("missingkey=error").Parse(l.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL template for link %q: %v", l.Label, err)
+		}
+		out = append(out, &taskLinkTemplate{taskType: l.TaskType, label: l.Label, url: tmpl})
+	}
+	return out, nil
+}
+
+type taskLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// buildTaskLinks returns the links which apply to the given task.
+func buildTaskLinks(tmpls []*taskLinkTemplate, info *asynq.TaskInfo) []*taskLink {
+	data := struct {
+		ID    string
+		Queue string
+		Type  string
+		State string
+	}{info.ID, info.Queue, info.Type, info.State.String()}
+	links := make([]*taskLink, 0) // avoid null in json output
+	for _, t := range tmpls {
+		if t.taskType != "" {
+			if ok, _ := path.Match(t.taskType, info.Type); !ok {
+				continue
+			}
+		}
+		var b bytes.Buffer
+		if err := t.url.Execute(&b, data); err != nil {
+			continue
+		}
+		links = append(links, &taskLink{Label: t.label, URL: b.String()})
+	}
+	return links
+}
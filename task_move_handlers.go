@@ -0,0 +1,789 @@
+package asynqmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - helpers to move tasks between states by updating asynq's redis keys
+//   - http.Handler(s) for moving pending tasks to the scheduled state
+//   - http.Handler(s) for moving a pending task to the front of its queue
+//   - http.Handler(s) for changing the process time of scheduled and retry tasks
+//   - http.Handler(s) for moving tasks to another queue
+// ****************************************************************************
+
+// asynq.Inspector cannot move tasks to the scheduled state, reorder pending tasks,
+// or change the process time of tasks, so the handlers in this file update the redis keys used by asynq directly. The keys and the
+// fields of the task hash must be kept in sync with asynq's internal/base package.
+
+func asynqTaskKey(qname, id string) string {
+	return asynqKeyPrefix + qname + "}:t:" + id
+}
+
+func asynqPendingKey(qname string) string {
+	return asynqKeyPrefix + qname + "}:pending"
+}
+
+func asynqScheduledKey(qname string) string {
+	return asynqKeyPrefix + qname + "}:scheduled"
+}
+
+func asynqRetryKey(qname string) string {
+	return asynqKeyPrefix + qname + "}:retry"
+}
+
+func asynqArchivedKey(qname string) string {
+	return asynqKeyPrefix + qname + "}:archived"
+}
+
+func asynqCompletedKey(qname string) string {
+	return asynqKeyPrefix + qname + "}:completed"
+}
+
+func asynqGroupKey(qname, group string) string {
+	return asynqKeyPrefix + qname + "}:g:" + group
+}
+
+func asynqAllGroupsKey(qname string) string {
+	return asynqKeyPrefix + qname + "}:groups"
+}
+
+// taskStateError is returned when a task is not in a state the operation applies to.
+type taskStateError struct {
+	id    string
+	state string
+	want  []string
+}
+
+func (e *taskStateError) Error() string {
+	return fmt.Sprintf("task %q is in %s state, want %s state", e.id, e.state, strings.Join(e.want, " or "))
+}
+
+// checkQueueExists returns asynq.ErrQueueNotFound if the queue does not exist.
+func checkQueueExists(ctx context.Context, rc redis.UniversalClient, qname string) error {
+	ok, err := rc.SIsMember(ctx, asynqAllQueuesKey, qname).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %q", asynq.ErrQueueNotFound, qname)
+	}
+	return nil
+}
+
+// runTaskScript runs a script which returns 1 on success, 0 if the task does
+// not exist, or the state of the task if the task is not in one of the states
+// the script applies to.
+func runTaskScript(ctx context.Context, rc redis.UniversalClient, script *redis.Script, qname, id string, want []string, keys []string, args ...interface{}) error {
+	res, err := script.Run(ctx, rc, keys, args...).Result()
+	if err != nil {
+		return err
+	}
+	switch v := res.(type) {
+	case int64:
+		if v == 1 {
+			return nil
+		}
+		return fmt.Errorf("%w: id=%q in queue %q", asynq.ErrTaskNotFound, id, qname)
+	case string:
+		return &taskStateError{id: id, state: v, want: want}
+	}
+	return fmt.Errorf("unexpected return value from lua script: %v", res)
+}
+
+// schedulePendingTaskCmd moves a pending task to the scheduled state.
+//
+// KEYS[1] -> asynq:{<qname>}:t:<task_id>
+// KEYS[2] -> asynq:{<qname>}:pending
+// KEYS[3] -> asynq:{<qname>}:scheduled
+// -------
+// ARGV[1] -> task ID
+// ARGV[2] -> process_at time in Unix time
+var schedulePendingTaskCmd = redis.NewScript(`
+local state = redis.call("HGET", KEYS[1], "state")
+if not state then
+	return 0
+end
+if state ~= "pending" then
+	return state
+end
+if redis.call("LREM", KEYS[2], 0, ARGV[1]) == 0 then
+	return 0
+end
+redis.call("ZADD", KEYS[3], ARGV[2], ARGV[1])
+redis.call("HSET", KEYS[1], "state", "scheduled")
+redis.call("HDEL", KEYS[1], "pending_since")
+return 1
+`)
+
+// schedulePendingTask moves the pending task to the scheduled state to be processed at the given time.
+func schedulePendingTask(ctx context.Context, rc redis.UniversalClient, qname, id string, processAt time.Time) error {
+	keys := []string{asynqTaskKey(qname, id), asynqPendingKey(qname), asynqScheduledKey(qname)}
+	return runTaskScript(ctx, rc, schedulePendingTaskCmd, qname, id, []string{"pending"}, keys, id, processAt.Unix())
+}
+
+// Number of tasks moved to the scheduled state by each run of schedulePendingTasksCmd.
+// LREM scans the whole pending list for each task, so the batches are kept small
+// to avoid blocking redis on large queues.
+const schedulePendingBatchSize = 100
+
+// schedulePendingTasksCmd moves a batch of pending tasks to the scheduled state,
+// skipping the tasks which are no longer pending, and returns the number of tasks moved.
+//
+// KEYS[1] -> asynq:{<qname>}:pending
+// KEYS[2] -> asynq:{<qname>}:scheduled
+// KEYS[3:] -> asynq:{<qname>}:t:<task_id> of each task
+// -------
+// ARGV[1] -> process_at time in Unix time
+// ARGV[2:] -> task IDs in the same order as the task keys
+var schedulePendingTasksCmd = redis.NewScript(`
+local n = 0
+for i = 3, #KEYS do
+	local id = ARGV[i-1]
+	if redis.call("HGET", KEYS[i], "state") == "pending" and redis.call("LREM", KEYS[1], 0, id) > 0 then
+		redis.call("ZADD", KEYS[2], ARGV[1], id)
+		redis.call("HSET", KEYS[i], "state", "scheduled")
+		redis.call("HDEL", KEYS[i], "pending_since")
+		n = n + 1
+	end
+end
+return n
+`)
+
+// schedulePendingTasks moves the pending tasks with the given IDs to the scheduled state
+// in batches, and returns the number of tasks moved. Tasks which are no longer pending
+// (e.g. processed or deleted in the meantime) are skipped.
+func schedulePendingTasks(ctx context.Context, rc redis.UniversalClient, qname string, ids []string, processAt time.Time) (int, error) {
+	n := 0
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > schedulePendingBatchSize {
+			batch = batch[:schedulePendingBatchSize]
+		}
+		ids = ids[len(batch):]
+		keys := make([]string, 0, len(batch)+2)
+		keys = append(keys, asynqPendingKey(qname), asynqScheduledKey(qname))
+		args := make([]interface{}, 0, len(batch)+1)
+		args = append(args, processAt.Unix())
+		for _, id := range batch {
+			keys = append(keys, asynqTaskKey(qname, id))
+			args = append(args, id)
+		}
+		moved, err := schedulePendingTasksCmd.Run(ctx, rc, keys, args...).Int()
+		if err != nil {
+			return n, err
+		}
+		n += moved
+	}
+	return n, nil
+}
+
+// prioritizePendingTaskCmd moves a pending task to the front of the pending list.
+// Tasks are enqueued with LPUSH and dequeued with RPOPLPUSH, so the front is the tail of the list.
+//
+// KEYS[1] -> asynq:{<qname>}:t:<task_id>
+// KEYS[2] -> asynq:{<qname>}:pending
+// -------
+// ARGV[1] -> task ID
+var prioritizePendingTaskCmd = redis.NewScript(`
+local state = redis.call("HGET", KEYS[1], "state")
+if not state then
+	return 0
+end
+if state ~= "pending" then
+	return state
+end
+if redis.call("LREM", KEYS[2], 0, ARGV[1]) == 0 then
+	return 0
+end
+redis.call("RPUSH", KEYS[2], ARGV[1])
+return 1
+`)
+
+// newPrioritizePendingTaskHandlerFunc returns a handler which moves a pending task to
+// the front of its queue, so that it is processed next.
+func newPrioritizePendingTaskHandlerFunc(rc redis.UniversalClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		qname, taskid := vars["qname"], vars["task_id"]
+		if err := checkQueueExists(r.Context(), rc, qname); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		keys := []string{asynqTaskKey(qname, taskid), asynqPendingKey(qname)}
+		if err := runTaskScript(r.Context(), rc, prioritizePendingTaskCmd, qname, taskid, []string{"pending"}, keys, taskid); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// processTime is the time to process tasks at, specified in request bodies
+// either as an absolute time or relative to now.
+type processTime struct {
+	// Time in RFC3339 format.
+	ProcessAt string `json:"process_at"`
+	// Duration from now (e.g. "30m", "2h").
+	ProcessIn string `json:"process_in"`
+}
+
+// time returns the time to process tasks at.
+// It returns an error if not exactly one of the fields is set, or the time is not in the future.
+func (p *processTime) time(now time.Time) (time.Time, error) {
+	var t time.Time
+	switch {
+	case p.ProcessAt != "" && p.ProcessIn != "":
+		return time.Time{}, fmt.Errorf("only one of process_at and process_in can be set")
+	case p.ProcessAt != "":
+		var err error
+		t, err = time.Parse(time.RFC3339, p.ProcessAt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("process_at must be in RFC3339 format: %v", err)
+		}
+	case p.ProcessIn != "":
+		d, err := time.ParseDuration(p.ProcessIn)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("process_in must be a duration (e.g. \"30m\"): %v", err)
+		}
+		t = now.Add(d)
+	default:
+		return time.Time{}, fmt.Errorf("process_at or process_in is required")
+	}
+	if !t.After(now) {
+		return time.Time{}, fmt.Errorf("process time must be in the future")
+	}
+	return t, nil
+}
+
+type batchScheduleTasksRequest struct {
+	TaskIDs []string `json:"task_ids"`
+	processTime
+}
+
+type batchScheduleTasksResponse struct {
+	// task ids that were successfully moved to the scheduled state.
+	ScheduledIDs []string `json:"scheduled_ids"`
+	// task ids that were not able to move to the scheduled state.
+	ErrorIDs []string `json:"error_ids"`
+	// Time the tasks are scheduled to be processed at in RFC3339 format.
+	ProcessAt string `json:"process_at"`
+}
+
+func newBatchSchedulePendingTasksHandlerFunc(rc redis.UniversalClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req batchScheduleTasksRequest
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if err := validateTaskIDs(req.TaskIDs); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		processAt, err := req.time(time.Now())
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+
+		qname := mux.Vars(r)["qname"]
+		if err := checkQueueExists(r.Context(), rc, qname); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		resp := batchScheduleTasksResponse{
+			// avoid null in the json response
+			ScheduledIDs: make([]string, 0),
+			ErrorIDs:     make([]string, 0),
+			ProcessAt:    processAt.Format(time.RFC3339),
+		}
+		for _, taskid := range req.TaskIDs {
+			if err := schedulePendingTask(r.Context(), rc, qname, taskid, processAt); err != nil {
+				log.Printf("error: could not schedule task with id %q: %v", taskid, err)
+				resp.ErrorIDs = append(resp.ErrorIDs, taskid)
+			} else {
+				resp.ScheduledIDs = append(resp.ScheduledIDs, taskid)
+			}
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+type scheduleAllPendingTasksRequest struct {
+	// If set, only pending tasks of the type are scheduled.
+	TaskType string `json:"task_type"`
+	processTime
+}
+
+type scheduleAllPendingTasksResponse struct {
+	// Number of tasks moved to the scheduled state.
+	Scheduled int `json:"scheduled"`
+	// Time the tasks are scheduled to be processed at in RFC3339 format.
+	ProcessAt string `json:"process_at"`
+}
+
+// newScheduleAllPendingTasksHandlerFunc returns a handler which moves all pending tasks
+// (or pending tasks of a type) in the queue to the scheduled state, e.g. to hold off
+// processing during an outage of a downstream service.
+func newScheduleAllPendingTasksHandlerFunc(inspector *asynq.Inspector, rc redis.UniversalClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req scheduleAllPendingTasksRequest
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		processAt, err := req.time(time.Now())
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+
+		qname := mux.Vars(r)["qname"]
+		// Collect the IDs first since moving tasks shifts the pages of the pending list.
+		var ids []string
+		for page := 1; ; page++ {
+			tasks, err := inspector.ListPendingTasks(qname, asynq.PageSize(maxPageSize), asynq.Page(page))
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			for _, t := range tasks {
+				if req.TaskType == "" || t.Type == req.TaskType {
+					ids = append(ids, t.ID)
+				}
+			}
+			if len(tasks) < maxPageSize {
+				break
+			}
+		}
+		// Keep moving tasks if the client goes away, so that the queue is not left
+		// half scheduled. Each command is bounded by the read timeout of the redis client.
+		n, err := schedulePendingTasks(context.Background(), rc, qname, ids, processAt)
+		if err != nil {
+			writeError(w, r, fmt.Errorf("scheduled %d of %d tasks before failing: %w", n, len(ids), err))
+			return
+		}
+		writeResponseJSON(w, scheduleAllPendingTasksResponse{Scheduled: n, ProcessAt: processAt.Format(time.RFC3339)})
+	}
+}
+
+// rescheduleTaskCmd changes the process time of a task in a sorted set
+// (i.e. a scheduled or retry task).
+//
+// KEYS[1] -> asynq:{<qname>}:t:<task_id>
+// KEYS[2] -> asynq:{<qname>}:<state>
+// -------
+// ARGV[1] -> task ID
+// ARGV[2] -> process_at time in Unix time
+// ARGV[3] -> state of the task
+var rescheduleTaskCmd = redis.NewScript(`
+local state = redis.call("HGET", KEYS[1], "state")
+if not state then
+	return 0
+end
+if state ~= ARGV[3] then
+	return state
+end
+if not redis.call("ZSCORE", KEYS[2], ARGV[1]) then
+	return 0
+end
+redis.call("ZADD", KEYS[2], ARGV[2], ARGV[1])
+return 1
+`)
+
+// rescheduleTask changes the process time of the task in the given state, which must be
+// scheduled or retry. The task keeps its ID, retry count, and last error.
+func rescheduleTask(ctx context.Context, rc redis.UniversalClient, qname, state, id string, processAt time.Time) error {
+	var zset string
+	switch state {
+	case "scheduled":
+		zset = asynqScheduledKey(qname)
+	case "retry":
+		zset = asynqRetryKey(qname)
+	default:
+		return fmt.Errorf("cannot reschedule %s tasks", state)
+	}
+	keys := []string{asynqTaskKey(qname, id), zset}
+	return runTaskScript(ctx, rc, rescheduleTaskCmd, qname, id, []string{state}, keys, id, processAt.Unix(), state)
+}
+
+type rescheduleTaskResponse struct {
+	ID string `json:"id"`
+	// Time the task is scheduled to be processed at in RFC3339 format.
+	NextProcessAt string `json:"next_process_at"`
+}
+
+// newRescheduleTaskHandlerFunc returns a handler which changes the process time of
+// a task in the given state (scheduled or retry), to run it earlier or push it later.
+func newRescheduleTaskHandlerFunc(rc redis.UniversalClient, state string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req processTime
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		processAt, err := req.time(time.Now())
+		if err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		vars := mux.Vars(r)
+		qname, taskid := vars["qname"], vars["task_id"]
+		if err := checkQueueExists(r.Context(), rc, qname); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if err := rescheduleTask(r.Context(), rc, qname, state, taskid, processAt); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, rescheduleTaskResponse{ID: taskid, NextProcessAt: processAt.Format(time.RFC3339)})
+	}
+}
+
+// Field numbers of asynq's TaskMessage protobuf (see asynq's internal/proto package).
+const (
+	taskMessageQueueField        = 4
+	taskMessageRetriedField      = 6
+	taskMessageErrorMsgField     = 7
+	taskMessageUniqueKeyField    = 10
+	taskMessageLastFailedAtField = 11
+)
+
+// movedTaskMessage returns the encoded task message msg with the queue set to dst.
+// The unique key is removed since the uniqueness lock belongs to the source queue.
+// If resetRetries is true, the retry count and the last error are removed.
+func movedTaskMessage(msg []byte, dst string, resetRetries bool) ([]byte, error) {
+	out := make([]byte, 0, len(msg)+len(dst)+2)
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return nil, fmt.Errorf("cannot decode task message: %v", protowire.ParseError(n))
+		}
+		m := protowire.ConsumeFieldValue(num, typ, msg[n:])
+		if m < 0 {
+			return nil, fmt.Errorf("cannot decode task message: %v", protowire.ParseError(m))
+		}
+		field := msg[:n+m]
+		msg = msg[n+m:]
+		switch num {
+		case taskMessageQueueField, taskMessageUniqueKeyField:
+			continue
+		case taskMessageRetriedField, taskMessageErrorMsgField, taskMessageLastFailedAtField:
+			if resetRetries {
+				continue
+			}
+		}
+		out = append(out, field...)
+	}
+	out = protowire.AppendTag(out, taskMessageQueueField, protowire.BytesType)
+	return protowire.AppendString(out, dst), nil
+}
+
+// moveTaskCmd moves a task to another queue by replacing the task hash and its entry in
+// the list or sorted set of its state, so that the task is never in both queues.
+// Scheduled, retry, and aggregating tasks keep their score.
+//
+// KEYS[1] -> asynq:{<qname>}:t:<task_id>
+// KEYS[2] -> asynq:{<dst>}:t:<task_id>
+// KEYS[3] -> list or sorted set of the task in the source queue (e.g. asynq:{<qname>}:pending)
+// KEYS[4] -> list or sorted set of the task in the destination queue
+// KEYS[5] -> asynq:{<qname>}:groups
+// KEYS[6] -> asynq:{<dst>}:groups
+// KEYS[7] -> asynq:queues
+// -------
+// ARGV[1] -> task ID
+// ARGV[2] -> state of the task
+// ARGV[3] -> encoded task message read from the source queue
+// ARGV[4] -> encoded task message for the destination queue
+// ARGV[5] -> state of the task in the destination queue
+// ARGV[6] -> destination queue name
+// ARGV[7] -> group of the task, if aggregating
+// ARGV[8] -> current time in Unix nanoseconds
+//
+// Returns 1 if the task is moved, 0 if the task does not exist, -1 if a task with the same ID
+// exists in the destination queue, -2 if the task message changed since it was read, or the
+// state of the task if it changed since it was read.
+var moveTaskCmd = redis.NewScript(`
+local state, msg, pending_since = unpack(redis.call("HMGET", KEYS[1], "state", "msg", "pending_since"))
+if not state then
+	return 0
+end
+if state ~= ARGV[2] then
+	return state
+end
+if msg ~= ARGV[3] then
+	return -2
+end
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	return -1
+end
+local score
+if state == "pending" then
+	if redis.call("LREM", KEYS[3], 0, ARGV[1]) == 0 then
+		return 0
+	end
+else
+	score = redis.call("ZSCORE", KEYS[3], ARGV[1])
+	if not score then
+		return 0
+	end
+	redis.call("ZREM", KEYS[3], ARGV[1])
+	if state == "aggregating" and redis.call("ZCARD", KEYS[3]) == 0 then
+		redis.call("SREM", KEYS[5], ARGV[7])
+	end
+end
+local unique_key = redis.call("HGET", KEYS[1], "unique_key")
+if unique_key and unique_key ~= "" and redis.call("GET", unique_key) == ARGV[1] then
+	redis.call("DEL", unique_key)
+end
+redis.call("DEL", KEYS[1])
+redis.call("HSET", KEYS[2], "msg", ARGV[4], "state", ARGV[5])
+if ARGV[5] == "pending" then
+	redis.call("HSET", KEYS[2], "pending_since", pending_since or ARGV[8])
+	redis.call("LPUSH", KEYS[4], ARGV[1])
+else
+	redis.call("ZADD", KEYS[4], score, ARGV[1])
+	if ARGV[5] == "aggregating" then
+		redis.call("HSET", KEYS[2], "group", ARGV[7])
+		redis.call("SADD", KEYS[6], ARGV[7])
+	end
+end
+redis.call("SADD", KEYS[7], ARGV[6])
+return 1
+`)
+
+// Number of times moveTask reads the task again if it changes while being moved.
+const moveTaskAttempts = 3
+
+// moveTask moves the task to the destination queue with the same ID, type, payload,
+// and options, and returns the state of the task in the destination queue.
+//
+// Pending, scheduled, and aggregating tasks keep their state. Retry tasks are
+// scheduled to be processed at their next retry time, and their retry count is reset.
+// Active, archived, and completed tasks cannot be moved.
+func moveTask(ctx context.Context, rc redis.UniversalClient, qname, id, dst string) (string, error) {
+	if err := checkQueueExists(ctx, rc, qname); err != nil {
+		return "", err
+	}
+	for i := 0; i < moveTaskAttempts; i++ {
+		vals, err := rc.HMGet(ctx, asynqTaskKey(qname, id), "state", "msg", "group").Result()
+		if err != nil {
+			return "", err
+		}
+		state, _ := vals[0].(string)
+		msg, _ := vals[1].(string)
+		group, _ := vals[2].(string)
+		if state == "" {
+			return "", fmt.Errorf("%w: id=%q in queue %q", asynq.ErrTaskNotFound, id, qname)
+		}
+		var src, dstKey, dstState string
+		switch state {
+		case "pending":
+			src, dstKey, dstState = asynqPendingKey(qname), asynqPendingKey(dst), "pending"
+		case "scheduled":
+			src, dstKey, dstState = asynqScheduledKey(qname), asynqScheduledKey(dst), "scheduled"
+		case "retry":
+			src, dstKey, dstState = asynqRetryKey(qname), asynqScheduledKey(dst), "scheduled"
+		case "aggregating":
+			src, dstKey, dstState = asynqGroupKey(qname, group), asynqGroupKey(dst, group), "aggregating"
+		default:
+			return "", &taskStateError{id: id, state: state, want: []string{"pending", "scheduled", "retry", "aggregating"}}
+		}
+		moved, err := movedTaskMessage([]byte(msg), dst, state == "retry")
+		if err != nil {
+			return "", err
+		}
+		keys := []string{
+			asynqTaskKey(qname, id), asynqTaskKey(dst, id), src, dstKey,
+			asynqAllGroupsKey(qname), asynqAllGroupsKey(dst), asynqAllQueuesKey,
+		}
+		res, err := moveTaskCmd.Run(ctx, rc, keys, id, state, msg, moved, dstState, dst, group, time.Now().UnixNano()).Result()
+		if err != nil {
+			return "", err
+		}
+		switch v := res.(type) {
+		case int64:
+			switch v {
+			case 1:
+				return dstState, nil
+			case 0:
+				return "", fmt.Errorf("%w: id=%q in queue %q", asynq.ErrTaskNotFound, id, qname)
+			case -1:
+				return "", fmt.Errorf("%w: id=%q in queue %q", asynq.ErrTaskIDConflict, id, dst)
+			case -2:
+				continue // the task changed (e.g. it was retried), read it again
+			}
+		case string:
+			// The task changed state since it was read, read it again.
+			continue
+		}
+		return "", fmt.Errorf("unexpected return value from lua script: %v", res)
+	}
+	return "", fmt.Errorf("task %q kept changing while moving it to queue %q", id, dst)
+}
+
+// moveTaskAcrossSlots moves the task like moveTask on a redis cluster, where the keys of
+// two queues are in different hash slots and cannot be updated by one script. It enqueues
+// a copy of the task and deletes the original, so the task is left in both queues if the
+// process stops in between.
+func moveTaskAcrossSlots(inspector *asynq.Inspector, client *asynq.Client, qname, id, dst string) (string, error) {
+	info, err := inspector.GetTaskInfo(qname, id)
+	if err != nil {
+		return "", err
+	}
+	opts := []asynq.Option{
+		asynq.Queue(dst),
+		asynq.TaskID(info.ID),
+		asynq.MaxRetry(info.MaxRetry),
+		asynq.Retention(info.Retention),
+	}
+	if info.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(info.Timeout))
+	}
+	if !info.Deadline.IsZero() {
+		opts = append(opts, asynq.Deadline(info.Deadline))
+	}
+	switch info.State {
+	case asynq.TaskStatePending:
+	case asynq.TaskStateScheduled, asynq.TaskStateRetry:
+		opts = append(opts, asynq.ProcessAt(info.NextProcessAt))
+	case asynq.TaskStateAggregating:
+		opts = append(opts, asynq.Group(info.Group))
+	default:
+		return "", &taskStateError{id: id, state: info.State.String(), want: []string{"pending", "scheduled", "retry", "aggregating"}}
+	}
+	moved, err := client.Enqueue(asynq.NewTask(info.Type, info.Payload), opts...)
+	if err != nil {
+		return "", err
+	}
+	if err := inspector.DeleteTask(qname, id); err != nil {
+		// Do not leave the task in both queues.
+		if derr := inspector.DeleteTask(dst, id); derr != nil {
+			log.Printf("error: could not delete task with id %q from queue %q after failing to move it: %v", id, dst, derr)
+		}
+		return "", err
+	}
+	return moved.State.String(), nil
+}
+
+// taskMover moves tasks between queues with moveTask, or with moveTaskAcrossSlots on a redis cluster.
+type taskMover struct {
+	inspector *asynq.Inspector
+	client    *asynq.Client
+	rc        redis.UniversalClient
+}
+
+func (m *taskMover) move(ctx context.Context, qname, id, dst string) (string, error) {
+	if _, ok := m.rc.(*redis.ClusterClient); ok {
+		return moveTaskAcrossSlots(m.inspector, m.client, qname, id, dst)
+	}
+	return moveTask(ctx, m.rc, qname, id, dst)
+}
+
+type moveTaskRequest struct {
+	// Name of the queue to move the task to.
+	Queue string `json:"queue"`
+}
+
+func (req *moveTaskRequest) validate(qname string) error {
+	if err := validateIdentifier("queue", req.Queue); err != nil {
+		return err
+	}
+	if req.Queue == qname {
+		return fmt.Errorf("task is already in queue %q", qname)
+	}
+	return nil
+}
+
+type moveTaskResponse struct {
+	ID    string `json:"id"`
+	Queue string `json:"queue"`
+	State string `json:"state"`
+}
+
+func newMoveTaskHandlerFunc(mover *taskMover, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		qname, taskid := vars["qname"], vars["task_id"]
+		var req moveTaskRequest
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if err := req.validate(qname); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if !qa.checkMutate(w, r, req.Queue) {
+			return
+		}
+		state, err := mover.move(r.Context(), qname, taskid, req.Queue)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, moveTaskResponse{ID: taskid, Queue: req.Queue, State: state})
+	}
+}
+
+type batchMoveTasksRequest struct {
+	TaskIDs []string `json:"task_ids"`
+	moveTaskRequest
+}
+
+type batchMoveTasksResponse struct {
+	// task ids that were successfully moved to the destination queue.
+	MovedIDs []string `json:"moved_ids"`
+	// task ids that were not able to move to the destination queue.
+	ErrorIDs []string `json:"error_ids"`
+}
+
+func newBatchMoveTasksHandlerFunc(mover *taskMover, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qname := mux.Vars(r)["qname"]
+		var req batchMoveTasksRequest
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if err := validateTaskIDs(req.TaskIDs); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if err := req.validate(qname); err != nil {
+			writeBadRequestError(w, r, err.Error())
+			return
+		}
+		if !qa.checkMutate(w, r, req.Queue) {
+			return
+		}
+
+		resp := batchMoveTasksResponse{
+			// avoid null in the json response
+			MovedIDs: make([]string, 0),
+			ErrorIDs: make([]string, 0),
+		}
+		for _, taskid := range req.TaskIDs {
+			if _, err := mover.move(r.Context(), qname, taskid, req.Queue); err != nil {
+				log.Printf("error: could not move task with id %q to queue %q: %v", taskid, req.Queue, err)
+				resp.ErrorIDs = append(resp.ErrorIDs, taskid)
+			} else {
+				resp.MovedIDs = append(resp.MovedIDs, taskid)
+			}
+		}
+		writeResponseJSON(w, resp)
+	}
+}
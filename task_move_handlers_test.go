@@ -0,0 +1,411 @@
+package asynqmon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// taskMoveEnv is an asynq setup on miniredis to test the scripts updating asynq's keys.
+type taskMoveEnv struct {
+	t         *testing.T
+	mr        *miniredis.Miniredis
+	rc        *redis.Client
+	client    *asynq.Client
+	inspector *asynq.Inspector
+}
+
+func newTaskMoveEnv(t *testing.T) *taskMoveEnv {
+	mr := miniredis.RunT(t)
+	opt := asynq.RedisClientOpt{Addr: mr.Addr()}
+	e := &taskMoveEnv{
+		t:         t,
+		mr:        mr,
+		rc:        redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		client:    asynq.NewClient(opt),
+		inspector: asynq.NewInspector(opt),
+	}
+	t.Cleanup(func() {
+		e.rc.Close()
+		e.client.Close()
+		e.inspector.Close()
+	})
+	return e
+}
+
+func (e *taskMoveEnv) enqueue(qname, id string, opts ...asynq.Option) {
+	e.t.Helper()
+	opts = append(opts, asynq.Queue(qname), asynq.TaskID(id))
+	if _, err := e.client.Enqueue(asynq.NewTask("email:send", []byte(`{"user_id":42}`)), opts...); err != nil {
+		e.t.Fatalf("could not enqueue task %q: %v", id, err)
+	}
+}
+
+// setState moves the task from the list or sorted set of its state to the one of the given state,
+// as asynq does when it processes the task.
+func (e *taskMoveEnv) setState(qname, id, from, to string) {
+	e.t.Helper()
+	ctx := context.Background()
+	key := asynqKeyPrefix + qname + "}:"
+	if from == "pending" {
+		e.rc.LRem(ctx, key+from, 0, id)
+	} else {
+		e.rc.ZRem(ctx, key+from, id)
+	}
+	if to == "active" {
+		e.rc.LPush(ctx, key+to, id)
+	} else {
+		e.rc.ZAdd(ctx, key+to, redis.Z{Score: float64(time.Now().Add(time.Hour).Unix()), Member: id})
+	}
+	e.rc.HSet(ctx, asynqTaskKey(qname, id), "state", to)
+}
+
+// failTask records a failure in the message of the task, as asynq does when it retries the task.
+func (e *taskMoveEnv) failTask(qname, id string, retried int, errMsg string) {
+	e.t.Helper()
+	ctx := context.Background()
+	msg, err := e.rc.HGet(ctx, asynqTaskKey(qname, id), "msg").Bytes()
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	msg = protowire.AppendTag(msg, taskMessageRetriedField, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, uint64(retried))
+	msg = protowire.AppendTag(msg, taskMessageErrorMsgField, protowire.BytesType)
+	msg = protowire.AppendString(msg, errMsg)
+	e.rc.HSet(ctx, asynqTaskKey(qname, id), "msg", msg)
+}
+
+func (e *taskMoveEnv) list(key string) []string {
+	e.t.Helper()
+	if !e.mr.Exists(key) {
+		return nil
+	}
+	l, err := e.mr.List(key)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return l
+}
+
+func (e *taskMoveEnv) zset(key string) []string {
+	e.t.Helper()
+	if !e.mr.Exists(key) {
+		return nil
+	}
+	m, err := e.mr.ZMembers(key)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return m
+}
+
+func TestSchedulePendingTask(t *testing.T) {
+	e := newTaskMoveEnv(t)
+	ctx := context.Background()
+	e.enqueue("default", "pending1")
+	e.enqueue("default", "pending2")
+	e.enqueue("default", "scheduled1", asynq.ProcessIn(time.Hour))
+	processAt := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		desc      string
+		id        string
+		wantErr   error // checked with errors.Is, or errors.As for *taskStateError
+		wantState bool
+	}{
+		{desc: "pending task", id: "pending1"},
+		{desc: "task already scheduled", id: "pending1", wantState: true},
+		{desc: "scheduled task", id: "scheduled1", wantState: true},
+		{desc: "missing task", id: "missing", wantErr: asynq.ErrTaskNotFound},
+	}
+	for _, tc := range tests {
+		err := schedulePendingTask(ctx, e.rc, "default", tc.id, processAt)
+		switch {
+		case tc.wantState:
+			if !errors.As(err, new(*taskStateError)) {
+				t.Errorf("%s: schedulePendingTask returned error %v, want task state error", tc.desc, err)
+			}
+		case tc.wantErr != nil:
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("%s: schedulePendingTask returned error %v, want %v", tc.desc, err, tc.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%s: schedulePendingTask returned error: %v", tc.desc, err)
+		}
+	}
+
+	if diff := cmp.Diff([]string{"pending2"}, e.list(asynqPendingKey("default"))); diff != "" {
+		t.Errorf("pending list diff (-want,+got):\n%s", diff)
+	}
+	info, err := e.inspector.GetTaskInfo("default", "pending1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State != asynq.TaskStateScheduled || !info.NextProcessAt.Equal(processAt) {
+		t.Errorf("scheduled task is in %v state to be processed at %v, want scheduled state at %v", info.State, info.NextProcessAt, processAt)
+	}
+	if e.mr.HGet(asynqTaskKey("default", "pending1"), "pending_since") != "" {
+		t.Errorf("scheduled task still has pending_since field")
+	}
+}
+
+func TestSchedulePendingTasks(t *testing.T) {
+	e := newTaskMoveEnv(t)
+	ids := []string{"scheduled1"}
+	for i := 0; i < schedulePendingBatchSize+10; i++ {
+		id := "pending" + string(rune('a'+i/26)) + string(rune('a'+i%26))
+		e.enqueue("default", id)
+		ids = append(ids, id)
+	}
+	e.enqueue("default", "scheduled1", asynq.ProcessIn(time.Hour))
+	e.enqueue("default", "untouched")
+	ids = append(ids, "missing")
+
+	n, err := schedulePendingTasks(context.Background(), e.rc, "default", ids, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := schedulePendingBatchSize + 10; n != want {
+		t.Errorf("schedulePendingTasks moved %d tasks, want %d", n, want)
+	}
+	if diff := cmp.Diff([]string{"untouched"}, e.list(asynqPendingKey("default"))); diff != "" {
+		t.Errorf("pending list diff (-want,+got):\n%s", diff)
+	}
+	if got, want := len(e.zset(asynqScheduledKey("default"))), schedulePendingBatchSize+11; got != want {
+		t.Errorf("scheduled set has %d tasks, want %d", got, want)
+	}
+}
+
+func TestPrioritizePendingTask(t *testing.T) {
+	e := newTaskMoveEnv(t)
+	for _, id := range []string{"a", "b", "c"} {
+		e.enqueue("default", id)
+	}
+	e.enqueue("default", "scheduled1", asynq.ProcessIn(time.Hour))
+	// Tasks are dequeued from the tail of the list.
+	if diff := cmp.Diff([]string{"c", "b", "a"}, e.list(asynqPendingKey("default"))); diff != "" {
+		t.Fatalf("pending list diff before prioritizing (-want,+got):\n%s", diff)
+	}
+
+	run := func(id string) error {
+		keys := []string{asynqTaskKey("default", id), asynqPendingKey("default")}
+		return runTaskScript(context.Background(), e.rc, prioritizePendingTaskCmd, "default", id, []string{"pending"}, keys, id)
+	}
+	if err := run("c"); err != nil {
+		t.Fatalf("prioritizing pending task returned error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"b", "a", "c"}, e.list(asynqPendingKey("default"))); diff != "" {
+		t.Errorf("pending list diff (-want,+got):\n%s", diff)
+	}
+	// The task is dequeued next.
+	if got := e.rc.RPop(context.Background(), asynqPendingKey("default")).Val(); got != "c" {
+		t.Errorf("task dequeued next is %q, want %q", got, "c")
+	}
+	if err := run("scheduled1"); !errors.As(err, new(*taskStateError)) {
+		t.Errorf("prioritizing scheduled task returned error %v, want task state error", err)
+	}
+	if err := run("missing"); !errors.Is(err, asynq.ErrTaskNotFound) {
+		t.Errorf("prioritizing missing task returned error %v, want %v", err, asynq.ErrTaskNotFound)
+	}
+}
+
+func TestRescheduleTask(t *testing.T) {
+	e := newTaskMoveEnv(t)
+	ctx := context.Background()
+	e.enqueue("default", "scheduled1", asynq.ProcessIn(time.Hour))
+	e.enqueue("default", "retry1", asynq.ProcessIn(time.Hour))
+	e.setState("default", "retry1", "scheduled", "retry")
+	e.failTask("default", "retry1", 3, "boom")
+	e.enqueue("default", "pending1")
+	processAt := time.Now().Add(time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		desc      string
+		state, id string
+		wantErr   bool
+	}{
+		{"scheduled task", "scheduled", "scheduled1", false},
+		{"retry task", "retry", "retry1", false},
+		{"scheduled task as retry task", "retry", "scheduled1", true},
+		{"pending task", "scheduled", "pending1", true},
+		{"pending state", "pending", "pending1", true},
+		{"missing task", "scheduled", "missing", true},
+	}
+	for _, tc := range tests {
+		err := rescheduleTask(ctx, e.rc, "default", tc.state, tc.id, processAt)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: rescheduleTask returned error %v, want error %t", tc.desc, err, tc.wantErr)
+		}
+	}
+
+	for _, id := range []string{"scheduled1", "retry1"} {
+		info, err := e.inspector.GetTaskInfo("default", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.NextProcessAt.Equal(processAt) {
+			t.Errorf("task %q is processed at %v, want %v", id, info.NextProcessAt, processAt)
+		}
+	}
+	// Retry tasks keep their retry count and last error.
+	info, err := e.inspector.GetTaskInfo("default", "retry1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State != asynq.TaskStateRetry || info.Retried != 3 || info.LastErr != "boom" {
+		t.Errorf("rescheduled retry task is in %v state with %d retries and error %q, want retry state with 3 retries and error %q",
+			info.State, info.Retried, info.LastErr, "boom")
+	}
+}
+
+func TestMoveTask(t *testing.T) {
+	e := newTaskMoveEnv(t)
+	ctx := context.Background()
+	e.enqueue("default", "pending1", asynq.MaxRetry(7), asynq.Timeout(time.Minute))
+	e.enqueue("default", "scheduled1", asynq.ProcessIn(time.Hour))
+	e.enqueue("default", "retry1", asynq.ProcessIn(time.Hour))
+	e.setState("default", "retry1", "scheduled", "retry")
+	e.failTask("default", "retry1", 3, "boom")
+	e.enqueue("default", "aggregating1", asynq.Group("emails"))
+	e.enqueue("default", "unique1", asynq.Unique(time.Hour))
+	e.enqueue("default", "active1")
+	e.setState("default", "active1", "pending", "active")
+	e.enqueue("default", "conflict1")
+	e.enqueue("critical", "conflict1")
+
+	tests := []struct {
+		desc      string
+		id        string
+		wantState string
+		wantErr   bool
+	}{
+		{desc: "pending task", id: "pending1", wantState: "pending"},
+		{desc: "scheduled task", id: "scheduled1", wantState: "scheduled"},
+		{desc: "retry task is scheduled", id: "retry1", wantState: "scheduled"},
+		{desc: "aggregating task", id: "aggregating1", wantState: "aggregating"},
+		{desc: "unique task", id: "unique1", wantState: "pending"},
+		{desc: "active task", id: "active1", wantErr: true},
+		{desc: "task with the same ID in the destination queue", id: "conflict1", wantErr: true},
+		{desc: "missing task", id: "missing", wantErr: true},
+	}
+	for _, tc := range tests {
+		before, _ := e.inspector.GetTaskInfo("default", tc.id)
+		state, err := moveTask(ctx, e.rc, "default", tc.id, "critical")
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: moveTask returned no error", tc.desc)
+			}
+			// The task is left in the source queue.
+			if before != nil {
+				if _, err := e.inspector.GetTaskInfo("default", tc.id); err != nil {
+					t.Errorf("%s: task is not in the source queue after failing to move it: %v", tc.desc, err)
+				}
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: moveTask returned error: %v", tc.desc, err)
+			continue
+		}
+		if state != tc.wantState {
+			t.Errorf("%s: moveTask returned state %q, want %q", tc.desc, state, tc.wantState)
+		}
+		if e.mr.Exists(asynqTaskKey("default", tc.id)) {
+			t.Errorf("%s: task is still in the source queue", tc.desc)
+		}
+		after, err := e.inspector.GetTaskInfo("critical", tc.id)
+		if err != nil {
+			t.Errorf("%s: task is not in the destination queue: %v", tc.desc, err)
+			continue
+		}
+		if after.Queue != "critical" || after.State.String() != tc.wantState || after.Type != before.Type ||
+			string(after.Payload) != string(before.Payload) || after.MaxRetry != before.MaxRetry || after.Timeout != before.Timeout {
+			t.Errorf("%s: moved task is %+v, want %+v in queue critical in %s state", tc.desc, after, before, tc.wantState)
+		}
+		if tc.wantState != "pending" && !after.NextProcessAt.Equal(before.NextProcessAt) {
+			t.Errorf("%s: moved task is processed at %v, want %v", tc.desc, after.NextProcessAt, before.NextProcessAt)
+		}
+	}
+
+	if diff := cmp.Diff([]string{"conflict1"}, e.list(asynqPendingKey("default"))); diff != "" {
+		t.Errorf("pending list of the source queue diff (-want,+got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"unique1", "pending1", "conflict1"}, e.list(asynqPendingKey("critical"))); diff != "" {
+		t.Errorf("pending list of the destination queue diff (-want,+got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"retry1", "scheduled1"}, e.zset(asynqScheduledKey("critical"))); diff != "" {
+		t.Errorf("scheduled set of the destination queue diff (-want,+got):\n%s", diff)
+	}
+	if got := e.zset(asynqRetryKey("default")); len(got) != 0 {
+		t.Errorf("retry set of the source queue has tasks %v", got)
+	}
+	if diff := cmp.Diff([]string{"aggregating1"}, e.zset(asynqGroupKey("critical", "emails"))); diff != "" {
+		t.Errorf("group of the destination queue diff (-want,+got):\n%s", diff)
+	}
+	if e.mr.Exists(asynqGroupKey("default", "emails")) {
+		t.Errorf("empty group is left in the source queue")
+	}
+	if ok, _ := e.mr.SIsMember(asynqAllGroupsKey("default"), "emails"); ok {
+		t.Errorf("empty group is left in the groups of the source queue")
+	}
+	if ok, _ := e.mr.SIsMember(asynqAllGroupsKey("critical"), "emails"); !ok {
+		t.Errorf("group is not in the groups of the destination queue")
+	}
+
+	// The retry count and the last error of retry tasks are reset.
+	info, err := e.inspector.GetTaskInfo("critical", "retry1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Retried != 0 || info.LastErr != "" {
+		t.Errorf("moved retry task has %d retries and error %q, want none", info.Retried, info.LastErr)
+	}
+	// The uniqueness lock of the source queue is released.
+	for _, k := range e.mr.Keys() {
+		if strings.HasPrefix(k, asynqKeyPrefix+"default}:unique:") {
+			t.Errorf("uniqueness lock %q is left after the task is moved", k)
+		}
+	}
+}
+
+func TestMovedTaskMessage(t *testing.T) {
+	e := newTaskMoveEnv(t)
+	e.enqueue("default", "retry1", asynq.ProcessIn(time.Hour), asynq.Unique(time.Hour))
+	e.failTask("default", "retry1", 2, "boom")
+	msg := []byte(e.mr.HGet(asynqTaskKey("default", "retry1"), "msg"))
+
+	fields := func(b []byte) map[protowire.Number]int {
+		m := make(map[protowire.Number]int)
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			m[num]++
+			b = b[n+protowire.ConsumeFieldValue(num, typ, b[n:]):]
+		}
+		return m
+	}
+	for _, resetRetries := range []bool{false, true} {
+		got, err := movedTaskMessage(msg, "critical", resetRetries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := fields(got)
+		if f[taskMessageQueueField] != 1 || f[taskMessageUniqueKeyField] != 0 {
+			t.Errorf("resetRetries=%t: moved message has %d queue and %d unique key fields, want 1 and 0",
+				resetRetries, f[taskMessageQueueField], f[taskMessageUniqueKeyField])
+		}
+		if kept := f[taskMessageRetriedField] > 0 && f[taskMessageErrorMsgField] > 0; kept == resetRetries {
+			t.Errorf("resetRetries=%t: retry fields kept = %t", resetRetries, kept)
+		}
+	}
+	if _, err := movedTaskMessage([]byte{0xff}, "critical", false); err == nil {
+		t.Errorf("movedTaskMessage of an invalid message returned no error")
+	}
+}
@@ -0,0 +1,161 @@
+package asynqmon
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - http.Handler(s) for task policy related endpoints
+// ****************************************************************************
+
+// Number of tasks sampled from each state of each queue to compute task policies.
+const taskPolicySampleSize = 100
+
+// policyValueCount is the number of sampled tasks which have the value.
+type policyValueCount struct {
+	Value int `json:"value"`
+	Count int `json:"count"`
+}
+
+type taskPolicy struct {
+	TaskType string `json:"task_type"`
+	// Queues in which tasks of the type were found.
+	Queues []string `json:"queues"`
+	// Number of tasks sampled.
+	Sampled int `json:"sampled"`
+	// Distribution of max retry values, sorted by count in descending order.
+	MaxRetry []*policyValueCount `json:"max_retry"`
+	// Distribution of timeout values in seconds (0 means no timeout), sorted by count in descending order.
+	TimeoutSeconds []*policyValueCount `json:"timeout_seconds"`
+	// Number of sampled tasks which have a deadline.
+	WithDeadline int `json:"with_deadline"`
+	// Consistent is true if all sampled tasks have the same max retry and timeout
+	// and either all or none have a deadline.
+	Consistent bool `json:"consistent"`
+}
+
+type listTaskPoliciesResponse struct {
+	Policies []*taskPolicy `json:"policies"`
+}
+
+// newListTaskPoliciesHandlerFunc returns a handler which reports the retry and timeout
+// options tasks were enqueued with, aggregated by task type over a sample of tasks.
+//
+// Optional query params:
+// `queue`: limits the sample to the given queue
+func newListTaskPoliciesHandlerFunc(inspector *asynq.Inspector, qa *queueAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var qnames []string
+		if qname := r.URL.Query().Get("queue"); qname != "" {
+			if err := validateIdentifier("queue name", qname); err != nil {
+				writeBadRequestError(w, r, fmt.Sprintf("invalid query parameter: %v", err))
+				return
+			}
+			if !qa.checkView(w, r, qname) {
+				return
+			}
+			qnames = []string{qname}
+		} else {
+			var err error
+			if qnames, err = qa.queues(r, inspector); err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
+		var tasks []*asynq.TaskInfo
+		for _, qname := range qnames {
+			sample, err := sampleTasks(inspector, qname, taskPolicySampleSize)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			tasks = append(tasks, sample...)
+		}
+		writeResponseJSON(w, listTaskPoliciesResponse{Policies: computeTaskPolicies(tasks)})
+	}
+}
+
+// sampleTasks returns up to n tasks from each state of the given queue.
+func sampleTasks(inspector *asynq.Inspector, qname string, n int) ([]*asynq.TaskInfo, error) {
+	listFuncs := []func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error){
+		inspector.ListActiveTasks,
+		inspector.ListPendingTasks,
+		inspector.ListScheduledTasks,
+		inspector.ListRetryTasks,
+		inspector.ListArchivedTasks,
+		inspector.ListCompletedTasks,
+	}
+	var out []*asynq.TaskInfo
+	for _, list := range listFuncs {
+		tasks, err := list(qname, asynq.PageSize(n))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, tasks...)
+	}
+	return out, nil
+}
+
+func computeTaskPolicies(tasks []*asynq.TaskInfo) []*taskPolicy {
+	type agg struct {
+		queues       map[string]bool
+		sampled      int
+		maxRetry     map[int]int
+		timeout      map[int]int
+		withDeadline int
+	}
+	byType := make(map[string]*agg)
+	for _, t := range tasks {
+		a, ok := byType[t.Type]
+		if !ok {
+			a = &agg{queues: make(map[string]bool), maxRetry: make(map[int]int), timeout: make(map[int]int)}
+			byType[t.Type] = a
+		}
+		a.queues[t.Queue] = true
+		a.sampled++
+		a.maxRetry[t.MaxRetry]++
+		a.timeout[int(t.Timeout/time.Second)]++
+		if !t.Deadline.IsZero() {
+			a.withDeadline++
+		}
+	}
+	policies := make([]*taskPolicy, 0, len(byType))
+	for typename, a := range byType {
+		p := &taskPolicy{
+			TaskType:       typename,
+			Sampled:        a.sampled,
+			MaxRetry:       toPolicyValueCounts(a.maxRetry),
+			TimeoutSeconds: toPolicyValueCounts(a.timeout),
+			WithDeadline:   a.withDeadline,
+		}
+		for qname := range a.queues {
+			p.Queues = append(p.Queues, qname)
+		}
+		sort.Strings(p.Queues)
+		p.Consistent = len(p.MaxRetry) == 1 && len(p.TimeoutSeconds) == 1 &&
+			(a.withDeadline == 0 || a.withDeadline == a.sampled)
+		policies = append(policies, p)
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].TaskType < policies[j].TaskType })
+	return policies
+}
+
+func toPolicyValueCounts(m map[int]int) []*policyValueCount {
+	out := make([]*policyValueCount, 0, len(m))
+	for v, n := range m {
+		out = append(out, &policyValueCount{Value: v, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Value < out[j].Value
+	})
+	return out
+}
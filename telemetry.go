@@ -0,0 +1,171 @@
+package asynqmon
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - telemetry which records OpenTelemetry spans and metrics of API requests and redis commands
+// ****************************************************************************
+
+// Name of the instrumentation scope of the spans and metrics recorded by the handler.
+const instrumentationName = "github.com/hibiken/asynqmon"
+
+// telemetry records a span and the duration of each API request, and of each redis command
+// issued by the handler.
+type telemetry struct {
+	tracer          trace.Tracer
+	requestDuration metric.Float64Histogram
+	redisDuration   metric.Float64Histogram
+}
+
+// newTelemetry returns a telemetry which records to the given providers,
+// or to the global providers if nil.
+func newTelemetry(tp trace.TracerProvider, mp metric.MeterProvider) *telemetry {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+	t := &telemetry{tracer: tp.Tracer(instrumentationName)}
+	var err error
+	t.requestDuration, err = meter.Float64Histogram("http.server.request.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of API requests."))
+	if err != nil {
+		otel.Handle(err)
+		t.requestDuration = noop.Float64Histogram{}
+	}
+	t.redisDuration, err = meter.Float64Histogram("asynqmon.redis.command.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of redis commands, including the commands issued by asynq.Inspector."))
+	if err != nil {
+		otel.Handle(err)
+		t.redisDuration = noop.Float64Histogram{}
+	}
+	return t
+}
+
+// middleware records a span and the duration of each API request.
+// The span continues the trace of the caller if the request carries a trace context
+// understood by the global propagator.
+func (t *telemetry) middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if cur := mux.CurrentRoute(r); cur != nil {
+			if tmpl, err := cur.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := t.tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("asynqmon.request_id", requestIDFromContext(ctx)),
+			))
+		defer span.End()
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+		t.requestDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", rec.status),
+		))
+	})
+}
+
+// redisConnOpt returns a RedisConnOpt which makes clients with the redis hook of the telemetry,
+// so that the commands issued by asynq.Inspector and asynq.Client are recorded.
+func (t *telemetry) redisConnOpt(opt asynq.RedisConnOpt) asynq.RedisConnOpt {
+	return instrumentedRedisConnOpt{RedisConnOpt: opt, hook: &redisHook{t: t}}
+}
+
+type instrumentedRedisConnOpt struct {
+	asynq.RedisConnOpt
+	hook redis.Hook
+}
+
+func (opt instrumentedRedisConnOpt) MakeRedisClient() interface{} {
+	c := opt.RedisConnOpt.MakeRedisClient()
+	if rc, ok := c.(redis.UniversalClient); ok {
+		rc.AddHook(opt.hook)
+	}
+	return c
+}
+
+// redisHook records the duration of each redis command, and a span for the command
+// if it is issued in the context of a span (e.g. of an API request).
+//
+// Inspector methods do not accept a context, so the commands they issue are not
+// recorded as spans; they are covered by the span of the API request and the duration metric.
+// Spans are not recorded for commands without a parent span either way, so that
+// the background polling of redis does not produce a trace every few seconds.
+type redisHook struct {
+	t *telemetry
+}
+
+func (h *redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		return h.record(ctx, cmd.Name(), func(ctx context.Context) error { return next(ctx, cmd) })
+	}
+}
+
+func (h *redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		return h.record(ctx, "pipeline", func(ctx context.Context) error { return next(ctx, cmds) })
+	}
+}
+
+func (h *redisHook) record(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	var span trace.Span
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		ctx, span = h.t.tracer.Start(ctx, "redis "+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", "redis"),
+				attribute.String("db.operation", operation),
+			))
+		defer span.End()
+	}
+	start := time.Now()
+	err := fn(ctx)
+	// redis.Nil reports a missing key, which is not a failure.
+	failed := err != nil && err != redis.Nil
+	if failed && span != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	h.t.redisDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("db.operation", operation),
+		attribute.Bool("error", failed),
+	))
+	return err
+}
@@ -0,0 +1,168 @@
+package asynqmon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - traceIDExtractor which extracts trace IDs from task payloads
+// ****************************************************************************
+
+// traceIDExtractor extracts a trace ID from JSON task payloads and builds a link
+// to the tracing backend (e.g. Jaeger, Tempo).
+type traceIDExtractor struct {
+	path    []jsonPathSegment
+	url     *template.Template   // may be nil
+	decrypt PayloadTransformFunc // may be nil
+}
+
+// newTraceIDExtractor returns a traceIDExtractor for the given JSONPath and URL template.
+// It returns nil if path is empty.
+func newTraceIDExtractor(path, urlTmpl string) (*traceIDExtractor, error) {
+	if path == "" {
+		return nil, nil
+	}
+	segs, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	e := &traceIDExtractor{path: segs}
+	if urlTmpl != "" {
+		e.url, err = template.New("trace_url").Option("missingkey=error").Parse(urlTmpl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace URL template: %v", err)
+		}
+	}
+	return e, nil
+}
+
+// extract returns the trace ID and the link to the tracing backend for the given task.
+// Empty strings are returned if the payload has no trace ID.
+func (e *traceIDExtractor) extract(info *asynq.TaskInfo) (traceID, url string) {
+	if e == nil {
+		return "", ""
+	}
+	payload := info.Payload
+	if e.decrypt != nil {
+		var err error
+		if payload, err = e.decrypt(info.Type, payload); err != nil {
+			return "", ""
+		}
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return "", "" // not a JSON payload
+	}
+	v, ok := lookupJSONPath(v, e.path)
+	if !ok {
+		return "", ""
+	}
+	switch x := v.(type) {
+	case string:
+		traceID = x
+	case json.Number:
+		traceID = x.String()
+	default:
+		return "", ""
+	}
+	if traceID == "" || e.url == nil {
+		return traceID, ""
+	}
+	data := struct {
+		TraceID string
+		ID      string
+		Queue   string
+		Type    string
+	}{traceID, info.ID, info.Queue, info.Type}
+	var b bytes.Buffer
+	if err := e.url.Execute(&b, data); err != nil {
+		return traceID, ""
+	}
+	return traceID, b.String()
+}
+
+// jsonPathSegment is either an object key or an array index.
+type jsonPathSegment struct {
+	key   string
+	index int
+	isKey bool
+}
+
+// parseJSONPath parses a subset of JSONPath which consists of the root ($) followed by
+// dot notation keys (.key), bracket notation keys (['key']), and array indices ([0]).
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	errorf := func(format string, args ...interface{}) error {
+		return fmt.Errorf("invalid JSONPath %q: %s", path, fmt.Sprintf(format, args...))
+	}
+	if !strings.HasPrefix(path, "$") {
+		return nil, errorf("must start with $")
+	}
+	var segs []jsonPathSegment
+	s := path[1:]
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			i := strings.IndexAny(s, ".[")
+			if i < 0 {
+				i = len(s)
+			}
+			if i == 0 {
+				return nil, errorf("empty key")
+			}
+			segs = append(segs, jsonPathSegment{key: s[:i], isKey: true})
+			s = s[i:]
+		case '[':
+			i := strings.IndexByte(s, ']')
+			if i < 0 {
+				return nil, errorf("missing ]")
+			}
+			inner := s[1:i]
+			s = s[i+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segs = append(segs, jsonPathSegment{key: inner[1 : len(inner)-1], isKey: true})
+				continue
+			}
+			n, err := strconv.Atoi(inner)
+			if err != nil || n < 0 {
+				return nil, errorf("invalid index %q", inner)
+			}
+			segs = append(segs, jsonPathSegment{index: n})
+		default:
+			return nil, errorf("unexpected character %q", s[0])
+		}
+	}
+	return segs, nil
+}
+
+// lookupJSONPath returns the value at the given path in v, which is a decoded JSON value.
+func lookupJSONPath(v interface{}, path []jsonPathSegment) (interface{}, bool) {
+	for _, seg := range path {
+		if seg.isKey {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if v, ok = m[seg.key]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		a, ok := v.([]interface{})
+		if !ok || seg.index >= len(a) {
+			return nil, false
+		}
+		v = a[seg.index]
+	}
+	return v, true
+}
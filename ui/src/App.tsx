@@ -1,4 +1,4 @@
-import React from "react";
+import React, { useEffect } from "react";
 import { connect, ConnectedProps } from "react-redux";
 import clsx from "clsx";
 import { BrowserRouter as Router, Switch, Route } from "react-router-dom";
@@ -13,6 +13,10 @@ import ListItemText from "@material-ui/core/ListItemText";
 import Snackbar from "@material-ui/core/Snackbar";
 import SnackbarContent from "@material-ui/core/SnackbarContent";
 import IconButton from "@material-ui/core/IconButton";
+import Tooltip from "@material-ui/core/Tooltip";
+import Typography from "@material-ui/core/Typography";
+import Select from "@material-ui/core/Select";
+import MenuItem from "@material-ui/core/MenuItem";
 import Slide from "@material-ui/core/Slide";
 import { TransitionProps } from "@material-ui/core/transitions";
 import MenuIcon from "@material-ui/icons/Menu";
@@ -22,14 +26,22 @@ import SettingsIcon from "@material-ui/icons/Settings";
 import ScheduleIcon from "@material-ui/icons/Schedule";
 import FeedbackIcon from "@material-ui/icons/Feedback";
 import TimelineIcon from "@material-ui/icons/Timeline";
+import ExtensionIcon from "@material-ui/icons/Extension";
 import DoubleArrowIcon from "@material-ui/icons/DoubleArrow";
 import CloseIcon from "@material-ui/icons/Close";
+import ExitToAppIcon from "@material-ui/icons/ExitToApp";
 import { AppState } from "./store";
 import { paths as getPaths } from "./paths";
+import { clusterURL, isSectionEnabled } from "./parseFlags";
 import { isDarkTheme, useTheme } from "./theme";
 import { closeSnackbar } from "./actions/snackbarActions";
 import { toggleDrawer } from "./actions/settingsActions";
+import { connectLiveUpdates } from "./actions/liveActions";
+import { hasAPIToken } from "./api";
 import ListItemLink from "./components/ListItemLink";
+import MaintenanceBanner from "./components/MaintenanceBanner";
+import FailoverBanner from "./components/FailoverBanner";
+import RedisHealthBanner from "./components/RedisHealthBanner";
 import SchedulersView from "./views/SchedulersView";
 import DashboardView from "./views/DashboardView";
 import TasksView from "./views/TasksView";
@@ -38,6 +50,7 @@ import SettingsView from "./views/SettingsView";
 import ServersView from "./views/ServersView";
 import RedisInfoView from "./views/RedisInfoView";
 import MetricsView from "./views/MetricsView";
+import PluginsView from "./views/PluginsView";
 import PageNotFoundView from "./views/PageNotFoundView";
 import { ReactComponent as Logo } from "./images/logo-color.svg";
 import { ReactComponent as LogoDarkTheme } from "./images/logo-white.svg";
@@ -55,6 +68,16 @@ const useStyles = (theme: Theme) =>
     toolbar: {
       paddingRight: 24, // keep right padding when drawer closed
     },
+    clusterSelect: {
+      marginLeft: theme.spacing(2),
+      minWidth: 140,
+    },
+    user: {
+      marginLeft: "auto",
+      display: "flex",
+      alignItems: "center",
+      color: theme.palette.text.secondary,
+    },
     toolbarIcon: {
       display: "flex",
       alignItems: "center",
@@ -143,6 +166,7 @@ function mapStateToProps(state: AppState) {
 const mapDispatchToProps = {
   closeSnackbar,
   toggleDrawer,
+  connectLiveUpdates,
 };
 
 const connector = connect(mapStateToProps, mapDispatchToProps);
@@ -155,6 +179,17 @@ function App(props: ConnectedProps<typeof connector>) {
   const theme = useTheme(props.themePreference);
   const classes = useStyles(theme)();
   const paths = getPaths();
+  const { connectLiveUpdates } = props;
+
+  useEffect(() => {
+    // EventSource cannot send the API token, so views keep polling the API
+    // if the user has entered one.
+    if (!isSectionEnabled("live") || hasAPIToken()) {
+      return;
+    }
+    return connectLiveUpdates();
+  }, [connectLiveUpdates]);
+
   return (
     <ThemeProvider theme={theme}>
       <Router>
@@ -179,6 +214,40 @@ function App(props: ConnectedProps<typeof connector>) {
               ) : (
                 <Logo width={200} height={48} />
               )}
+              {window.CLUSTERS.length > 1 && (
+                <Select
+                  className={classes.clusterSelect}
+                  variant="outlined"
+                  margin="dense"
+                  value={window.CLUSTER}
+                  onChange={(e) => {
+                    window.location.href = clusterURL(e.target.value as string);
+                  }}
+                  inputProps={{ "aria-label": "cluster" }}
+                >
+                  {window.CLUSTERS.map((name) => (
+                    <MenuItem key={name} value={name}>
+                      {name}
+                    </MenuItem>
+                  ))}
+                </Select>
+              )}
+              {window.USERNAME !== "" && (
+                <div className={classes.user}>
+                  <Typography variant="body2">{window.USERNAME}</Typography>
+                  {window.SIGN_OUT_PATH !== "" && (
+                    <Tooltip title="Sign out">
+                      <IconButton
+                        color="inherit"
+                        aria-label="sign out"
+                        href={window.SIGN_OUT_PATH}
+                      >
+                        <ExitToAppIcon />
+                      </IconButton>
+                    </Tooltip>
+                  )}
+                </div>
+              )}
             </Toolbar>
           </AppBar>
           <div className={classes.mainContainer}>
@@ -231,23 +300,35 @@ function App(props: ConnectedProps<typeof connector>) {
                       primary="Servers"
                       icon={<DoubleArrowIcon />}
                     />
-                    <ListItemLink
-                      to={paths.SCHEDULERS}
-                      primary="Schedulers"
-                      icon={<ScheduleIcon />}
-                    />
-                    <ListItemLink
-                      to={paths.REDIS}
-                      primary="Redis"
-                      icon={<LayersIcon />}
-                    />
-                    {window.PROMETHEUS_SERVER_ADDRESS && (
+                    {isSectionEnabled("schedulers") && (
+                      <ListItemLink
+                        to={paths.SCHEDULERS}
+                        primary="Schedulers"
+                        icon={<ScheduleIcon />}
+                      />
+                    )}
+                    {isSectionEnabled("redis") && (
+                      <ListItemLink
+                        to={paths.REDIS}
+                        primary="Redis"
+                        icon={<LayersIcon />}
+                      />
+                    )}
+                    {(window.PROMETHEUS_SERVER_ADDRESS ||
+                      isSectionEnabled("metrics_history")) && (
                       <ListItemLink
                         to={paths.QUEUE_METRICS}
                         primary="Metrics"
                         icon={<TimelineIcon />}
                       />
                     )}
+                    {isSectionEnabled("plugins") && (
+                      <ListItemLink
+                        to={paths.PLUGINS}
+                        primary="Plugins"
+                        icon={<ExtensionIcon />}
+                      />
+                    )}
                   </div>
                 </List>
                 <List>
@@ -273,6 +354,9 @@ function App(props: ConnectedProps<typeof connector>) {
             </Drawer>
             <main className={classes.content}>
               <div className={classes.contentWrapper}>
+                <MaintenanceBanner />
+                <FailoverBanner />
+                <RedisHealthBanner />
                 <Switch>
                   <Route exact path={paths.TASK_DETAILS}>
                     <TaskDetailsView />
@@ -280,24 +364,35 @@ function App(props: ConnectedProps<typeof connector>) {
                   <Route exact path={paths.QUEUE_DETAILS}>
                     <TasksView />
                   </Route>
-                  <Route exact path={paths.SCHEDULERS}>
-                    <SchedulersView />
-                  </Route>
+                  {isSectionEnabled("schedulers") && (
+                    <Route exact path={paths.SCHEDULERS}>
+                      <SchedulersView />
+                    </Route>
+                  )}
                   <Route exact path={paths.SERVERS}>
                     <ServersView />
                   </Route>
-                  <Route exact path={paths.REDIS}>
-                    <RedisInfoView />
-                  </Route>
+                  {isSectionEnabled("redis") && (
+                    <Route exact path={paths.REDIS}>
+                      <RedisInfoView />
+                    </Route>
+                  )}
                   <Route exact path={paths.SETTINGS}>
                     <SettingsView />
                   </Route>
                   <Route exact path={paths.HOME}>
                     <DashboardView />
                   </Route>
-                  <Route exact path={paths.QUEUE_METRICS}>
-                    <MetricsView />
-                  </Route>
+                  {isSectionEnabled("metrics") && (
+                    <Route exact path={paths.QUEUE_METRICS}>
+                      <MetricsView />
+                    </Route>
+                  )}
+                  {isSectionEnabled("plugins") && (
+                    <Route exact path={paths.PLUGINS}>
+                      <PluginsView />
+                    </Route>
+                  )}
                   <Route path="*">
                     <PageNotFoundView />
                   </Route>
@@ -0,0 +1,79 @@
+import { Dispatch } from "redux";
+import {
+  ActiveTasksEvent,
+  ListQueuesResponse,
+  ListQueueStatsResponse,
+  liveUpdatesURL,
+} from "../api";
+import { LIST_QUEUES_SUCCESS, QueuesActionTypes } from "./queuesActions";
+import {
+  LIST_QUEUE_STATS_SUCCESS,
+  QueueStatsActionTypes,
+} from "./queueStatsActions";
+
+// List of live updates related action types.
+export const LIVE_UPDATES_CONNECTED = "LIVE_UPDATES_CONNECTED";
+export const LIVE_UPDATES_DISCONNECTED = "LIVE_UPDATES_DISCONNECTED";
+export const LIVE_ACTIVE_TASKS_UPDATED = "LIVE_ACTIVE_TASKS_UPDATED";
+
+interface LiveUpdatesConnectedAction {
+  type: typeof LIVE_UPDATES_CONNECTED;
+}
+
+interface LiveUpdatesDisconnectedAction {
+  type: typeof LIVE_UPDATES_DISCONNECTED;
+}
+
+interface LiveActiveTasksUpdatedAction {
+  type: typeof LIVE_ACTIVE_TASKS_UPDATED;
+  payload: ActiveTasksEvent;
+}
+
+// Union of all live updates related action types.
+export type LiveActionTypes =
+  | LiveUpdatesConnectedAction
+  | LiveUpdatesDisconnectedAction
+  | LiveActiveTasksUpdatedAction;
+
+// connectLiveUpdates subscribes to the live updates stream and dispatches
+// the received updates. It returns a function which closes the stream.
+//
+// Views poll the API while the stream is not connected, e.g. if the server
+// does not support live updates or the stream is closed by a proxy.
+export function connectLiveUpdates() {
+  return (
+    dispatch: Dispatch<
+      LiveActionTypes | QueuesActionTypes | QueueStatsActionTypes
+    >
+  ) => {
+    const source = new EventSource(liveUpdatesURL(), {
+      withCredentials: true,
+    });
+    source.onopen = () => dispatch({ type: LIVE_UPDATES_CONNECTED });
+    source.onerror = () => {
+      // EventSource reconnects by itself unless the server responded with an error.
+      if (source.readyState === EventSource.CLOSED) {
+        console.error("connectLiveUpdates: stream was closed by the server");
+      }
+      dispatch({ type: LIVE_UPDATES_DISCONNECTED });
+    };
+    source.addEventListener("queues", (e) => {
+      const payload: ListQueuesResponse = JSON.parse((e as MessageEvent).data);
+      dispatch({ type: LIST_QUEUES_SUCCESS, payload });
+    });
+    source.addEventListener("queue_stats", (e) => {
+      const payload: ListQueueStatsResponse = JSON.parse(
+        (e as MessageEvent).data
+      );
+      dispatch({ type: LIST_QUEUE_STATS_SUCCESS, payload });
+    });
+    source.addEventListener("active_tasks", (e) => {
+      const payload: ActiveTasksEvent = JSON.parse((e as MessageEvent).data);
+      dispatch({ type: LIVE_ACTIVE_TASKS_UPDATED, payload });
+    });
+    return () => {
+      source.close();
+      dispatch({ type: LIVE_UPDATES_DISCONNECTED });
+    };
+  };
+}
@@ -1,4 +1,4 @@
-import { ThemePreference } from "../reducers/settingsReducer";
+import { SettingsState, ThemePreference } from "../reducers/settingsReducer";
 import { DailyStatsKey } from "../views/DashboardView";
 // List of settings related action types.
 export const POLL_INTERVAL_CHANGE = "POLL_INTERVAL_CHANGE";
@@ -6,6 +6,7 @@ export const THEME_PREFERENCE_CHANGE = "THEME_PREFERENCE_CHANGE";
 export const TOGGLE_DRAWER = "TOGGLE_DRAWER";
 export const TASK_ROWS_PER_PAGE_CHANGE = "TASK_ROWS_PER_PAGE_CHANGE";
 export const DAILY_STATS_KEY_CHANGE = "DAILY_STATS_KEY_CHANGE";
+export const SETTINGS_RESTORE = "SETTINGS_RESTORE";
 
 interface PollIntervalChangeAction {
   type: typeof POLL_INTERVAL_CHANGE;
@@ -31,13 +32,19 @@ interface DailyStatsKeyChange {
   value: DailyStatsKey;
 }
 
+interface SettingsRestore {
+  type: typeof SETTINGS_RESTORE;
+  value: Partial<SettingsState>;
+}
+
 // Union of all settings related action types.
 export type SettingsActionTypes =
   | PollIntervalChangeAction
   | ThemePreferenceChangeAction
   | ToggleDrawerAction
   | TaskRowsPerPageChange
-  | DailyStatsKeyChange;
+  | DailyStatsKeyChange
+  | SettingsRestore;
 
 export function pollIntervalChange(value: number) {
   return {
@@ -70,3 +77,11 @@ export function dailyStatsKeyChange(value: DailyStatsKey) {
     value,
   }
 }
+
+// Restores settings imported from a settings document.
+export function settingsRestore(value: Partial<SettingsState>) {
+  return {
+    type: SETTINGS_RESTORE,
+    value,
+  };
+}
@@ -36,6 +36,7 @@ import {
   listCompletedTasks,
   listAggregatingTasks,
   PaginationOptions,
+  TaskSearchOptions,
   runAllArchivedTasks,
   runAllRetryTasks,
   runAllScheduledTasks,
@@ -48,6 +49,7 @@ import {
   archivePendingTask,
   batchArchivePendingTasks,
   archiveAllPendingTasks,
+  prioritizePendingTask,
   TaskInfo,
   getTaskInfo,
   deleteAllAggregatingTasks,
@@ -60,12 +62,18 @@ import {
   runAggregatingTask,
   archiveAggregatingTask,
   ListAggregatingTasksResponse,
+  enqueueTask,
+  EnqueueTaskRequest,
+  TaskInfo,
 } from "../api";
 import { Dispatch } from "redux";
 import { toErrorString, toErrorStringWithHttpStatus } from "../utils";
 
 // List of tasks related action types.
 export const GET_TASK_INFO_BEGIN = "GET_TASK_INFO_BEGIN";
+export const ENQUEUE_TASK_BEGIN = "ENQUEUE_TASK_BEGIN";
+export const ENQUEUE_TASK_SUCCESS = "ENQUEUE_TASK_SUCCESS";
+export const ENQUEUE_TASK_ERROR = "ENQUEUE_TASK_ERROR";
 export const GET_TASK_INFO_SUCCESS = "GET_TASK_INFO_SUCCESS";
 export const GET_TASK_INFO_ERROR = "GET_TASK_INFO_ERROR";
 export const LIST_ACTIVE_TASKS_BEGIN = "LIST_ACTIVE_TASKS_BEGIN";
@@ -117,6 +125,10 @@ export const DELETE_PENDING_TASK_ERROR = "DELETE_PENDING_TASK_ERROR";
 export const ARCHIVE_PENDING_TASK_BEGIN = "ARCHIVE_PENDING_TASK_BEGIN";
 export const ARCHIVE_PENDING_TASK_SUCCESS = "ARCHIVE_PENDING_TASK_SUCCESS";
 export const ARCHIVE_PENDING_TASK_ERROR = "ARCHIVE_PENDING_TASK_ERROR";
+export const PRIORITIZE_PENDING_TASK_BEGIN = "PRIORITIZE_PENDING_TASK_BEGIN";
+export const PRIORITIZE_PENDING_TASK_SUCCESS =
+  "PRIORITIZE_PENDING_TASK_SUCCESS";
+export const PRIORITIZE_PENDING_TASK_ERROR = "PRIORITIZE_PENDING_TASK_ERROR";
 export const DELETE_SCHEDULED_TASK_BEGIN = "DELETE_SCHEDULED_TASK_BEGIN";
 export const DELETE_SCHEDULED_TASK_SUCCESS = "DELETE_SCHEDULED_TASK_SUCCESS";
 export const DELETE_SCHEDULED_TASK_ERROR = "DELETE_SCHEDULED_TASK_ERROR";
@@ -557,6 +569,25 @@ interface ArchivePendingTaskErrorAction {
   error: string;
 }
 
+interface PrioritizePendingTaskBeginAction {
+  type: typeof PRIORITIZE_PENDING_TASK_BEGIN;
+  queue: string;
+  taskId: string;
+}
+
+interface PrioritizePendingTaskSuccessAction {
+  type: typeof PRIORITIZE_PENDING_TASK_SUCCESS;
+  queue: string;
+  taskId: string;
+}
+
+interface PrioritizePendingTaskErrorAction {
+  type: typeof PRIORITIZE_PENDING_TASK_ERROR;
+  queue: string;
+  taskId: string;
+  error: string;
+}
+
 interface BatchArchivePendingTasksBeginAction {
   type: typeof BATCH_ARCHIVE_PENDING_TASKS_BEGIN;
   queue: string;
@@ -1265,6 +1296,23 @@ interface DeleteAllAggregatingTasksErrorAction {
   error: string;
 }
 
+interface EnqueueTaskBeginAction {
+  type: typeof ENQUEUE_TASK_BEGIN;
+  queue: string;
+}
+
+interface EnqueueTaskSuccessAction {
+  type: typeof ENQUEUE_TASK_SUCCESS;
+  queue: string;
+  payload: TaskInfo;
+}
+
+interface EnqueueTaskErrorAction {
+  type: typeof ENQUEUE_TASK_ERROR;
+  queue: string;
+  error: string;
+}
+
 // Union of all tasks related action types.
 export type TasksActionTypes =
   | GetTaskInfoBeginAction
@@ -1312,6 +1360,9 @@ export type TasksActionTypes =
   | ArchivePendingTaskBeginAction
   | ArchivePendingTaskSuccessAction
   | ArchivePendingTaskErrorAction
+  | PrioritizePendingTaskBeginAction
+  | PrioritizePendingTaskSuccessAction
+  | PrioritizePendingTaskErrorAction
   | BatchArchivePendingTasksBeginAction
   | BatchArchivePendingTasksSuccessAction
   | BatchArchivePendingTasksErrorAction
@@ -1425,7 +1476,10 @@ export type TasksActionTypes =
   | RunAggregatingTaskErrorAction
   | ArchiveAggregatingTaskBeginAction
   | ArchiveAggregatingTaskSuccessAction
-  | ArchiveAggregatingTaskErrorAction;
+  | ArchiveAggregatingTaskErrorAction
+  | EnqueueTaskBeginAction
+  | EnqueueTaskSuccessAction
+  | EnqueueTaskErrorAction;
 
 export function getTaskInfoAsync(qname: string, id: string) {
   return async (dispatch: Dispatch<TasksActionTypes>) => {
@@ -1448,12 +1502,13 @@ export function getTaskInfoAsync(qname: string, id: string) {
 
 export function listActiveTasksAsync(
   qname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ) {
   return async (dispatch: Dispatch<TasksActionTypes>) => {
     dispatch({ type: LIST_ACTIVE_TASKS_BEGIN, queue: qname });
     try {
-      const response = await listActiveTasks(qname, pageOpts);
+      const response = await listActiveTasks(qname, pageOpts, search);
       dispatch({
         type: LIST_ACTIVE_TASKS_SUCCESS,
         queue: qname,
@@ -1475,12 +1530,13 @@ export function listActiveTasksAsync(
 
 export function listPendingTasksAsync(
   qname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ) {
   return async (dispatch: Dispatch<TasksActionTypes>) => {
     dispatch({ type: LIST_PENDING_TASKS_BEGIN, queue: qname });
     try {
-      const response = await listPendingTasks(qname, pageOpts);
+      const response = await listPendingTasks(qname, pageOpts, search);
       dispatch({
         type: LIST_PENDING_TASKS_SUCCESS,
         queue: qname,
@@ -1502,12 +1558,13 @@ export function listPendingTasksAsync(
 
 export function listScheduledTasksAsync(
   qname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ) {
   return async (dispatch: Dispatch<TasksActionTypes>) => {
     dispatch({ type: LIST_SCHEDULED_TASKS_BEGIN, queue: qname });
     try {
-      const response = await listScheduledTasks(qname, pageOpts);
+      const response = await listScheduledTasks(qname, pageOpts, search);
       dispatch({
         type: LIST_SCHEDULED_TASKS_SUCCESS,
         queue: qname,
@@ -1529,12 +1586,13 @@ export function listScheduledTasksAsync(
 
 export function listRetryTasksAsync(
   qname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ) {
   return async (dispatch: Dispatch<TasksActionTypes>) => {
     dispatch({ type: LIST_RETRY_TASKS_BEGIN, queue: qname });
     try {
-      const response = await listRetryTasks(qname, pageOpts);
+      const response = await listRetryTasks(qname, pageOpts, search);
       dispatch({
         type: LIST_RETRY_TASKS_SUCCESS,
         queue: qname,
@@ -1556,12 +1614,13 @@ export function listRetryTasksAsync(
 
 export function listArchivedTasksAsync(
   qname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ) {
   return async (dispatch: Dispatch<TasksActionTypes>) => {
     dispatch({ type: LIST_ARCHIVED_TASKS_BEGIN, queue: qname });
     try {
-      const response = await listArchivedTasks(qname, pageOpts);
+      const response = await listArchivedTasks(qname, pageOpts, search);
       dispatch({
         type: LIST_ARCHIVED_TASKS_SUCCESS,
         queue: qname,
@@ -1583,12 +1642,13 @@ export function listArchivedTasksAsync(
 
 export function listCompletedTasksAsync(
   qname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ) {
   return async (dispatch: Dispatch<TasksActionTypes>) => {
     try {
       dispatch({ type: LIST_COMPLETED_TASKS_BEGIN, queue: qname });
-      const response = await listCompletedTasks(qname, pageOpts);
+      const response = await listCompletedTasks(qname, pageOpts, search);
       dispatch({
         type: LIST_COMPLETED_TASKS_SUCCESS,
         queue: qname,
@@ -1611,7 +1671,8 @@ export function listCompletedTasksAsync(
 export function listAggregatingTasksAsync(
   qname: string,
   gname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ) {
   return async (dispatch: Dispatch<TasksActionTypes>) => {
     try {
@@ -1620,7 +1681,12 @@ export function listAggregatingTasksAsync(
         queue: qname,
         group: gname,
       });
-      const response = await listAggregatingTasks(qname, gname, pageOpts);
+      const response = await listAggregatingTasks(
+        qname,
+        gname,
+        pageOpts,
+        search
+      );
       dispatch({
         type: LIST_AGGREGATING_TASKS_SUCCESS,
         queue: qname,
@@ -1768,6 +1834,27 @@ export function archivePendingTaskAsync(queue: string, taskId: string) {
   };
 }
 
+export function prioritizePendingTaskAsync(queue: string, taskId: string) {
+  return async (dispatch: Dispatch<TasksActionTypes>) => {
+    dispatch({ type: PRIORITIZE_PENDING_TASK_BEGIN, queue, taskId });
+    try {
+      await prioritizePendingTask(queue, taskId);
+      dispatch({ type: PRIORITIZE_PENDING_TASK_SUCCESS, queue, taskId });
+    } catch (error) {
+      console.error(
+        "prioritizePendingTaskAsync: ",
+        toErrorStringWithHttpStatus(error)
+      );
+      dispatch({
+        type: PRIORITIZE_PENDING_TASK_ERROR,
+        error: toErrorString(error),
+        queue,
+        taskId,
+      });
+    }
+  };
+}
+
 export function archiveScheduledTaskAsync(queue: string, taskId: string) {
   return async (dispatch: Dispatch<TasksActionTypes>) => {
     dispatch({ type: ARCHIVE_SCHEDULED_TASK_BEGIN, queue, taskId });
@@ -2730,3 +2817,20 @@ export function deleteAllCompletedTasksAsync(queue: string) {
     }
   };
 }
+
+export function enqueueTaskAsync(queue: string, req: EnqueueTaskRequest) {
+  return async (dispatch: Dispatch<TasksActionTypes>) => {
+    dispatch({ type: ENQUEUE_TASK_BEGIN, queue });
+    try {
+      const response = await enqueueTask(queue, req);
+      dispatch({ type: ENQUEUE_TASK_SUCCESS, queue, payload: response });
+    } catch (error) {
+      console.error("enqueueTaskAsync: ", toErrorStringWithHttpStatus(error));
+      dispatch({
+        type: ENQUEUE_TASK_ERROR,
+        error: toErrorString(error),
+        queue,
+      });
+    }
+  };
+}
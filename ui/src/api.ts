@@ -9,6 +9,84 @@ const getBaseUrl = () =>
     ? `${window.ROOT_PATH}/api`
     : `http://localhost:8080${window.ROOT_PATH}/api`;
 
+// Key of the local storage item holding the API token.
+const API_TOKEN_KEY = "asynqmon:api-token";
+
+// hasAPIToken reports whether the user has entered an API token.
+export function hasAPIToken(): boolean {
+  return !!window.localStorage.getItem(API_TOKEN_KEY);
+}
+
+// Send the API token if the user has entered one.
+// X-Requested-With tells the server that the request is not forged by another
+// site, which is required for changes made by users signed in with a password or session.
+axios.interceptors.request.use((config) => {
+  config.headers = { ...config.headers, "X-Requested-With": "XMLHttpRequest" };
+  const token = window.localStorage.getItem(API_TOKEN_KEY);
+  if (token) {
+    config.headers = { ...config.headers, Authorization: `Bearer ${token}` };
+  }
+  return config;
+});
+
+// The server responds with 401 if it requires an API token.
+// Ask the user for a token and retry the request with it.
+axios.interceptors.response.use(undefined, (error) => {
+  const { config, response } = error;
+  if (response?.status !== 401 || response.data?.code !== "unauthorized") {
+    return Promise.reject(error);
+  }
+  const current = window.localStorage.getItem(API_TOKEN_KEY);
+  // Another request may have already prompted for a new token.
+  if (current && config.headers?.Authorization !== `Bearer ${current}`) {
+    return axios(config);
+  }
+  const token = window.prompt(
+    current
+      ? "The API token is invalid. Enter a valid API token:"
+      : "This server requires an API token. Enter your API token:"
+  );
+  if (!token) {
+    return Promise.reject(error);
+  }
+  window.localStorage.setItem(API_TOKEN_KEY, token);
+  return axios(config);
+});
+
+// Bulk operations which affect more tasks than the threshold configured on the
+// server are rejected with 409. Ask the user to confirm and retry with force=true.
+axios.interceptors.response.use(undefined, (error) => {
+  const { config, response } = error;
+  if (
+    response?.status === 409 &&
+    response.data?.code === "bulk_threshold_exceeded" &&
+    !config.params?.force &&
+    window.confirm(
+      `This operation affects ${response.data.affected_count} tasks. Do you want to continue?`
+    )
+  ) {
+    return axios({ ...config, params: { ...config.params, force: true } });
+  }
+  return Promise.reject(error);
+});
+
+// liveUpdatesURL returns the URL of the stream of live updates sent with Server-Sent Events.
+export function liveUpdatesURL(): string {
+  return `${getBaseUrl()}/live`;
+}
+
+// ActiveTasksEvent is the data of the "active_tasks" event of the live updates stream.
+export interface ActiveTasksEvent {
+  queue: string;
+  reset: boolean; // true if added holds every active task of the queue
+  added: TaskInfo[]; // tasks which are new or have changed
+  removed: string[]; // IDs of tasks which are no longer active
+}
+
+export interface ListTaskPoliciesResponse {
+  policies: TaskPolicy[];
+}
+
 export interface ListQueuesResponse {
   queues: Queue[];
 }
@@ -16,12 +94,14 @@ export interface ListQueuesResponse {
 export interface ListTasksResponse {
   tasks: TaskInfo[];
   stats: Queue;
+  pagination: Pagination;
 }
 
 export interface ListAggregatingTasksResponse {
   tasks: TaskInfo[];
   stats: Queue;
   groups: GroupInfo[];
+  pagination: Pagination;
 }
 
 export interface ListServersResponse {
@@ -34,6 +114,98 @@ export interface ListSchedulerEntriesResponse {
 
 export interface ListSchedulerEnqueueEventsResponse {
   events: SchedulerEnqueueEvent[];
+  pagination: Pagination;
+}
+
+// Pagination of a list response.
+// Fields are null if the value is not known or there is no such page.
+export interface Pagination {
+  page: number;
+  size: number;
+  total: number | null;
+  total_pages: number | null;
+  next_page: number | null;
+  prev_page: number | null;
+}
+
+export interface ListSchedulerEnqueueFailuresResponse {
+  failures: SchedulerEnqueueFailure[];
+  total: number;
+}
+
+export interface ListNotifiersResponse {
+  notifiers: { name: string }[];
+}
+
+export interface TestNotifiersResponse {
+  results: NotifyResult[];
+}
+
+export interface NotifyResult {
+  name: string;
+  ok: boolean;
+  error?: string; // present if ok === false
+}
+
+// Document with the state managed by asynqmon.
+export interface SettingsDocument {
+  version: number;
+  exported_at: string;
+  api_tokens: object[] | null; // null if API tokens are not enabled
+  alert_rules: object[];
+  ui?: object; // Web UI settings; added by the Web UI
+}
+
+export interface ImportSettingsResponse {
+  imported_api_tokens: number;
+  ignored: string[];
+}
+
+export interface PanelInfo {
+  name: string;
+  title: string;
+  type: "table" | "line_chart";
+}
+
+export interface ListPanelsResponse {
+  panels: PanelInfo[];
+}
+
+export interface PanelDataResponse extends PanelInfo {
+  columns: string[];
+  rows: any[][];
+}
+
+export interface MaintenanceModeState {
+  enabled: boolean;
+  message: string;
+  since: string; // empty string if enabled === false
+}
+
+export interface HealthResponse {
+  status: "ok" | "degraded";
+  redis_circuit_breaker: CircuitBreakerState | null; // null if disabled
+  redis_read_only: boolean; // true if connected to a read-only replica
+  redis_master_address?: string; // set if redis_read_only === true
+}
+
+export interface CircuitBreakerState {
+  state: "closed" | "open" | "half-open";
+  consecutive_failures: number;
+  opened_at: string; // empty string if state === "closed"
+  last_error: string;
+}
+
+export interface ListRedisFailoversResponse {
+  sentinel: boolean; // false if not connected through redis sentinels
+  failovers: RedisFailover[]; // newest first
+}
+
+export interface RedisFailover {
+  time: string;
+  master: string;
+  old_address: string;
+  new_address: string;
 }
 
 export interface BatchCancelTasksResponse {
@@ -56,6 +228,39 @@ export interface BatchArchiveTasksResponse {
   error_ids: string[];
 }
 
+export interface BatchScheduleTasksResponse {
+  scheduled_ids: string[];
+  error_ids: string[];
+  process_at: string;
+}
+
+export interface ScheduleAllTasksResponse {
+  scheduled: number;
+  process_at: string;
+}
+
+export interface RescheduleTaskResponse {
+  id: string;
+  next_process_at: string;
+}
+
+export interface MoveTaskResponse {
+  id: string;
+  queue: string;
+  state: string;
+}
+
+export interface BatchMoveTasksResponse {
+  moved_ids: string[];
+  error_ids: string[];
+}
+
+// Time to process tasks at. Exactly one of the fields must be set.
+export interface ProcessTime {
+  process_at?: string; // in RFC3339 format
+  process_in?: string; // duration from now (e.g. "30m")
+}
+
 export interface DeleteAllTasksResponse {
   deleted: number;
 }
@@ -88,6 +293,36 @@ export interface RedisInfoResponse {
   queue_locations: QueueLocation[] | null;
 }
 
+export interface RedisLatencyResponse {
+  ping_samples: RedisPingSample[];
+  ping_stats: RedisPingStats | null; // null if no PING succeeded yet
+  events: RedisLatencyEvent[];
+  doctor: string;
+  latency_error?: string; // set if LATENCY commands could not be run
+}
+
+export interface RedisPingSample {
+  time: string;
+  latency_ms: number;
+  error?: string;
+}
+
+export interface RedisPingStats {
+  min_ms: number;
+  avg_ms: number;
+  max_ms: number;
+  last_ms: number;
+  errors: number;
+}
+
+export interface RedisLatencyEvent {
+  event: string;
+  time: string;
+  latest_ms: number;
+  max_ms: number;
+  history: { time: string; latency_ms: number }[];
+}
+
 // Describes location of a queue in cluster.
 export interface QueueLocation {
   queue: string; // queue name
@@ -105,6 +340,8 @@ export interface MetricsResponse {
   pending_tasks_by_queue: PrometheusMetricsResponse;
   retry_tasks_by_queue: PrometheusMetricsResponse;
   archived_tasks_by_queue: PrometheusMetricsResponse;
+  stale: boolean; // true if prometheus was unreachable and cached data is served
+  fetched_at?: string; // present if stale === true
 }
 
 export interface PrometheusMetricsResponse {
@@ -292,6 +529,33 @@ export interface Queue {
   processed: number;
   failed: number;
   timestamp: string;
+  growth?: QueueGrowth; // present if enough samples have been collected
+  drain?: QueueDrainEstimate; // present if enough samples have been collected
+}
+
+export interface QueueGrowth {
+  trend: "filling" | "draining" | "steady";
+  rates: QueueGrowthRate[];
+}
+
+export interface QueueGrowthRate {
+  window: string;
+  enqueued_per_minute: number;
+  processed_per_minute: number;
+  size_delta_per_minute: number;
+}
+
+export interface QueueDrainEstimate {
+  pending: number;
+  processed_per_minute: number;
+  net_drain_per_minute: number;
+  net_drain_stddev: number;
+  drain_seconds: number | null; // null if not draining
+  drain_seconds_min: number | null;
+  drain_seconds_max: number | null;
+  drain_at: string;
+  confidence: "high" | "medium" | "low";
+  window: string;
 }
 
 export interface DailyStat {
@@ -306,6 +570,8 @@ export interface TaskInfo {
   queue: string;
   type: string;
   payload: string;
+  payload_size_bytes: number;
+  decompressed_payload_size_bytes: number; // 0 if the payload is not compressed
   state: string;
   start_time: string; // Only applies to task.state == 'active'
   max_retry: number;
@@ -320,6 +586,24 @@ export interface TaskInfo {
   result: string;
   ttl_seconds: number;
   is_orphaned: boolean; // Only applies to task.state == 'active'
+  links?: TaskLink[]; // Only set in task details
+  trace_id?: string; // Only set in task details
+  trace_url?: string; // Only set in task details
+}
+
+export interface TaskLink {
+  label: string;
+  url: string;
+}
+
+export interface SchedulerEnqueueFailure {
+  entry_id: string;
+  spec: string;
+  task_type: string;
+  missed_count: number;
+  consecutive_missed: number;
+  last_missed_at: string;
+  recent_missed_at: string[];
 }
 
 export interface ServerInfo {
@@ -364,6 +648,12 @@ export interface PaginationOptions extends Record<string, number | undefined> {
   page?: number; // page number (1 being the first page)
 }
 
+// Filter applied by the server to list only the matching tasks.
+export interface TaskSearchOptions {
+  type?: string; // glob pattern the task type must match (e.g. "email:*")
+  payload_contains?: string; // substring the payload must contain
+}
+
 export async function listQueues(): Promise<ListQueuesResponse> {
   const resp = await axios({
     method: "get",
@@ -421,14 +711,40 @@ export async function getTaskInfo(
   return resp.data;
 }
 
+// Fields of the tasks rendered by the task tables.
+// Other fields (e.g. payload sizes) are omitted from the polled list responses.
+const baseTaskFields = [
+  "id",
+  "type",
+  "payload",
+  "queue",
+  "max_retry",
+  "retried",
+  "error_message",
+];
+const taskTableFields: { [state: string]: string } = {
+  active: [...baseTaskFields, "start_time", "deadline", "is_orphaned"].join(","),
+  pending: baseTaskFields.join(","),
+  aggregating: [...baseTaskFields, "group"].join(","),
+  scheduled: [...baseTaskFields, "next_process_at"].join(","),
+  retry: [...baseTaskFields, "next_process_at"].join(","),
+  archived: [...baseTaskFields, "last_failed_at"].join(","),
+  completed: [...baseTaskFields, "completed_at", "result", "ttl_seconds"].join(
+    ","
+  ),
+};
+
 export async function listActiveTasks(
   qname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ): Promise<ListTasksResponse> {
   let url = `${getBaseUrl()}/queues/${qname}/active_tasks`;
-  if (pageOpts) {
-    url += `?${queryString.stringify(pageOpts)}`;
-  }
+  url += `?${queryString.stringify({
+    ...pageOpts,
+    ...search,
+    fields: taskTableFields.active,
+  })}`;
   const resp = await axios({
     method: "get",
     url,
@@ -469,12 +785,15 @@ export async function batchCancelActiveTasks(
 
 export async function listPendingTasks(
   qname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ): Promise<ListTasksResponse> {
   let url = `${getBaseUrl()}/queues/${qname}/pending_tasks`;
-  if (pageOpts) {
-    url += `?${queryString.stringify(pageOpts)}`;
-  }
+  url += `?${queryString.stringify({
+    ...pageOpts,
+    ...search,
+    fields: taskTableFields.pending,
+  })}`;
   const resp = await axios({
     method: "get",
     url,
@@ -484,12 +803,15 @@ export async function listPendingTasks(
 
 export async function listScheduledTasks(
   qname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ): Promise<ListTasksResponse> {
   let url = `${getBaseUrl()}/queues/${qname}/scheduled_tasks`;
-  if (pageOpts) {
-    url += `?${queryString.stringify(pageOpts)}`;
-  }
+  url += `?${queryString.stringify({
+    ...pageOpts,
+    ...search,
+    fields: taskTableFields.scheduled,
+  })}`;
   const resp = await axios({
     method: "get",
     url,
@@ -499,12 +821,15 @@ export async function listScheduledTasks(
 
 export async function listRetryTasks(
   qname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ): Promise<ListTasksResponse> {
   let url = `${getBaseUrl()}/queues/${qname}/retry_tasks`;
-  if (pageOpts) {
-    url += `?${queryString.stringify(pageOpts)}`;
-  }
+  url += `?${queryString.stringify({
+    ...pageOpts,
+    ...search,
+    fields: taskTableFields.retry,
+  })}`;
   const resp = await axios({
     method: "get",
     url,
@@ -514,12 +839,15 @@ export async function listRetryTasks(
 
 export async function listArchivedTasks(
   qname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ): Promise<ListTasksResponse> {
   let url = `${getBaseUrl()}/queues/${qname}/archived_tasks`;
-  if (pageOpts) {
-    url += `?${queryString.stringify(pageOpts)}`;
-  }
+  url += `?${queryString.stringify({
+    ...pageOpts,
+    ...search,
+    fields: taskTableFields.archived,
+  })}`;
   const resp = await axios({
     method: "get",
     url,
@@ -529,12 +857,15 @@ export async function listArchivedTasks(
 
 export async function listCompletedTasks(
   qname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ): Promise<ListTasksResponse> {
   let url = `${getBaseUrl()}/queues/${qname}/completed_tasks`;
-  if (pageOpts) {
-    url += `?${queryString.stringify(pageOpts)}`;
-  }
+  url += `?${queryString.stringify({
+    ...pageOpts,
+    ...search,
+    fields: taskTableFields.completed,
+  })}`;
   const resp = await axios({
     method: "get",
     url,
@@ -545,12 +876,15 @@ export async function listCompletedTasks(
 export async function listAggregatingTasks(
   qname: string,
   gname: string,
-  pageOpts?: PaginationOptions
+  pageOpts?: PaginationOptions,
+  search?: TaskSearchOptions
 ): Promise<ListAggregatingTasksResponse> {
   let url = `${getBaseUrl()}/queues/${qname}/groups/${gname}/aggregating_tasks`;
-  if (pageOpts) {
-    url += `?${queryString.stringify(pageOpts)}`;
-  }
+  url += `?${queryString.stringify({
+    ...pageOpts,
+    ...search,
+    fields: taskTableFields.aggregating,
+  })}`;
   const resp = await axios({
     method: "get",
     url,
@@ -568,6 +902,16 @@ export async function archivePendingTask(
   });
 }
 
+export async function prioritizePendingTask(
+  qname: string,
+  taskId: string
+): Promise<void> {
+  await axios({
+    method: "post",
+    url: `${getBaseUrl()}/queues/${qname}/pending_tasks/${taskId}:prioritize`,
+  });
+}
+
 export async function batchArchivePendingTasks(
   qname: string,
   taskIds: string[]
@@ -589,6 +933,151 @@ export async function archiveAllPendingTasks(qname: string): Promise<void> {
   });
 }
 
+export async function batchSchedulePendingTasks(
+  qname: string,
+  taskIds: string[],
+  processTime: ProcessTime
+): Promise<BatchScheduleTasksResponse> {
+  const resp = await axios({
+    method: "post",
+    url: `${getBaseUrl()}/queues/${qname}/pending_tasks:batch_schedule`,
+    data: {
+      task_ids: taskIds,
+      ...processTime,
+    },
+  });
+  return resp.data;
+}
+
+export async function rescheduleTask(
+  qname: string,
+  taskState: "scheduled" | "retry",
+  taskId: string,
+  processTime: ProcessTime
+): Promise<RescheduleTaskResponse> {
+  const resp = await axios({
+    method: "post",
+    url: `${getBaseUrl()}/queues/${qname}/${taskState}_tasks/${taskId}:reschedule`,
+    data: processTime,
+  });
+  return resp.data;
+}
+
+export async function moveTask(
+  qname: string,
+  taskId: string,
+  destQueue: string
+): Promise<MoveTaskResponse> {
+  const resp = await axios({
+    method: "post",
+    url: `${getBaseUrl()}/queues/${qname}/tasks/${taskId}:move`,
+    data: { queue: destQueue },
+  });
+  return resp.data;
+}
+
+export async function batchMoveTasks(
+  qname: string,
+  taskIds: string[],
+  destQueue: string
+): Promise<BatchMoveTasksResponse> {
+  const resp = await axios({
+    method: "post",
+    url: `${getBaseUrl()}/queues/${qname}/tasks:batch_move`,
+    data: { task_ids: taskIds, queue: destQueue },
+  });
+  return resp.data;
+}
+
+export interface EnqueueTaskRequest extends ProcessTime {
+  type: string;
+  payload?: any; // encoded as JSON
+  task_id?: string;
+  max_retry?: number;
+  timeout?: string; // duration (e.g. "30s")
+  deadline?: string; // in RFC3339 format
+  unique?: string; // duration (e.g. "1h")
+  retention?: string; // duration (e.g. "24h")
+  group?: string;
+}
+
+export async function enqueueTask(
+  qname: string,
+  req: EnqueueTaskRequest
+): Promise<TaskInfo> {
+  const resp = await axios({
+    method: "post",
+    url: `${getBaseUrl()}/queues/${qname}/tasks`,
+    data: req,
+  });
+  return resp.data;
+}
+
+// Task as exported by exportTasks. Payload and result are base64 encoded.
+export interface ExportedTask {
+  id: string;
+  type: string;
+  payload: string;
+  queue: string;
+  state: string;
+  max_retry?: number;
+  [field: string]: any;
+}
+
+export interface ImportTasksResponse {
+  enqueued: number;
+  duplicates: number;
+  failed: number;
+  errors: { index: number; id: string; error: string }[];
+  dry_run: boolean;
+}
+
+// Maximum number of tasks sent in a request to import tasks.
+export const importTasksBatchSize = 100;
+
+// exportTasks returns the tasks in the given state as an NDJSON file.
+export async function exportTasks(qname: string, state: string): Promise<Blob> {
+  const resp = await axios({
+    method: "get",
+    url: `${getBaseUrl()}/queues/${qname}/${state}/export`,
+    responseType: "blob",
+  });
+  return resp.data;
+}
+
+export async function importTasks(
+  qname: string,
+  tasks: ExportedTask[],
+  opts: { dryRun: boolean; newIds: boolean }
+): Promise<ImportTasksResponse> {
+  const resp = await axios({
+    method: "post",
+    url: `${getBaseUrl()}/queues/${qname}/tasks:import`,
+    data: tasks,
+    params: {
+      dry_run: opts.dryRun || undefined,
+      new_ids: opts.newIds || undefined,
+    },
+  });
+  return resp.data;
+}
+
+export async function scheduleAllPendingTasks(
+  qname: string,
+  processTime: ProcessTime,
+  taskType?: string
+): Promise<ScheduleAllTasksResponse> {
+  const resp = await axios({
+    method: "post",
+    url: `${getBaseUrl()}/queues/${qname}/pending_tasks:schedule_all`,
+    data: {
+      task_type: taskType,
+      ...processTime,
+    },
+  });
+  return resp.data;
+}
+
 export async function deletePendingTask(
   qname: string,
   taskId: string
@@ -1051,6 +1540,122 @@ export async function listSchedulerEnqueueEvents(
   return resp.data;
 }
 
+export async function runSchedulerEntry(entryId: string): Promise<TaskInfo> {
+  const resp = await axios({
+    method: "post",
+    url: `${getBaseUrl()}/scheduler_entries/${entryId}:run`,
+  });
+  return resp.data;
+}
+
+export async function listSchedulerEnqueueFailures(
+  durationSec?: number
+): Promise<ListSchedulerEnqueueFailuresResponse> {
+  let url = `${getBaseUrl()}/scheduler_enqueue_failures`;
+  if (durationSec) {
+    url += `?duration=${durationSec}`;
+  }
+  const resp = await axios({
+    method: "get",
+    url,
+  });
+  return resp.data;
+}
+
+export async function listNotifiers(): Promise<ListNotifiersResponse> {
+  const resp = await axios({
+    method: "get",
+    url: `${getBaseUrl()}/notifiers`,
+  });
+  return resp.data;
+}
+
+export async function testNotifiers(): Promise<TestNotifiersResponse> {
+  const resp = await axios({
+    method: "post",
+    url: `${getBaseUrl()}/notifiers:test`,
+  });
+  return resp.data;
+}
+
+export async function exportSettings(): Promise<SettingsDocument> {
+  const resp = await axios({
+    method: "get",
+    url: `${getBaseUrl()}/settings:export`,
+  });
+  return resp.data;
+}
+
+export async function importSettings(
+  doc: SettingsDocument
+): Promise<ImportSettingsResponse> {
+  const resp = await axios({
+    method: "post",
+    url: `${getBaseUrl()}/settings:import`,
+    data: doc,
+  });
+  return resp.data;
+}
+
+export async function listPanels(): Promise<ListPanelsResponse> {
+  const resp = await axios({
+    method: "get",
+    url: `${getBaseUrl()}/plugins`,
+  });
+  return resp.data;
+}
+
+export async function getPanelData(name: string): Promise<PanelDataResponse> {
+  const resp = await axios({
+    method: "get",
+    url: `${getBaseUrl()}/plugins/${encodeURIComponent(name)}`,
+  });
+  return resp.data;
+}
+
+export async function getHealth(): Promise<HealthResponse> {
+  const resp = await axios({
+    method: "get",
+    url: `${getBaseUrl()}/health`,
+  });
+  return resp.data;
+}
+
+export async function listRedisFailovers(): Promise<ListRedisFailoversResponse> {
+  const resp = await axios({
+    method: "get",
+    url: `${getBaseUrl()}/redis_failovers`,
+  });
+  return resp.data;
+}
+
+export async function getMaintenanceMode(): Promise<MaintenanceModeState> {
+  const resp = await axios({
+    method: "get",
+    url: `${getBaseUrl()}/maintenance`,
+  });
+  return resp.data;
+}
+
+export async function enableMaintenanceMode(
+  message: string
+): Promise<MaintenanceModeState> {
+  const resp = await axios({
+    method: "post",
+    url: `${getBaseUrl()}/maintenance:enable`,
+    data: { message },
+  });
+  return resp.data;
+}
+
+export async function disableMaintenanceMode(): Promise<MaintenanceModeState> {
+  const resp = await axios({
+    method: "post",
+    url: `${getBaseUrl()}/maintenance:disable`,
+  });
+  return resp.data;
+}
+
 export async function getRedisInfo(): Promise<RedisInfoResponse> {
   const resp = await axios({
     method: "get",
@@ -1059,6 +1664,40 @@ export async function getRedisInfo(): Promise<RedisInfoResponse> {
   return resp.data;
 }
 
+export async function getRedisLatency(): Promise<RedisLatencyResponse> {
+  const resp = await axios({
+    method: "get",
+    url: `${getBaseUrl()}/redis_latency`,
+  });
+  return resp.data;
+}
+
+export interface PayloadSizeStats {
+  count: number;
+  compressed_count: number;
+  size_bytes: number;
+  max_size_bytes: number;
+  compressed_size_bytes: number;
+  decompressed_size_bytes: number;
+  compression_ratio: number;
+}
+
+export interface GetPayloadStatsResponse {
+  sample_size: number;
+  total: PayloadSizeStats;
+  types: (PayloadSizeStats & { type: string })[];
+}
+
+export async function getPayloadStats(
+  qname: string
+): Promise<GetPayloadStatsResponse> {
+  const resp = await axios({
+    method: "get",
+    url: `${getBaseUrl()}/queues/${qname}/payload_stats`,
+  });
+  return resp.data;
+}
+
 interface MetricsEndpointParams {
   endtime: number;
   duration: number;
@@ -1077,9 +1716,40 @@ export async function getMetrics(
   if (queues && queues.length > 0) {
     params.queues = queues.join(",");
   }
+  // Chart the queue stats recorded by asynqmon if Prometheus is not configured.
+  const path = window.PROMETHEUS_SERVER_ADDRESS ? "metrics" : "metrics/history";
   const resp = await axios({
     method: "get",
-    url: `${getBaseUrl()}/metrics?${queryString.stringify(params)}`,
+    url: `${getBaseUrl()}/${path}?${queryString.stringify(params)}`,
+  });
+  return resp.data;
+}
+
+export interface PolicyValueCount {
+  value: number;
+  count: number;
+}
+
+export interface TaskPolicy {
+  task_type: string;
+  queues: string[];
+  sampled: number;
+  max_retry: PolicyValueCount[];
+  timeout_seconds: PolicyValueCount[];
+  with_deadline: number;
+  consistent: boolean;
+}
+
+export async function listTaskPolicies(
+  qname?: string
+): Promise<ListTaskPoliciesResponse> {
+  let url = `${getBaseUrl()}/task_policies`;
+  if (qname) {
+    url += `?${queryString.stringify({ queue: qname })}`;
+  }
+  const resp = await axios({
+    method: "get",
+    url,
   });
   return resp.data;
 }
@@ -23,13 +23,19 @@ import { durationBefore, prettifyPayload, timeAgo, uuidPrefix } from "../utils";
 import SyntaxHighlighter from "./SyntaxHighlighter";
 import TasksTable, { RowProps, useRowStyles } from "./TasksTable";
 
-function mapStateToProps(state: AppState) {
+function mapStateToProps(state: AppState, ownProps: Props) {
+  // Active tasks are pushed by the live updates stream while it is connected.
+  const live = state.live.connected;
   return {
-    loading: state.tasks.activeTasks.loading,
-    error: state.tasks.activeTasks.error,
-    tasks: state.tasks.activeTasks.data,
+    live,
+    loading: live ? false : state.tasks.activeTasks.loading,
+    error: live ? "" : state.tasks.activeTasks.error,
+    tasks: live
+      ? state.live.activeTasks[ownProps.queue] || []
+      : state.tasks.activeTasks.data,
     batchActionPending: state.tasks.activeTasks.batchActionPending,
     allActionPending: state.tasks.activeTasks.allActionPending,
+    pagination: state.tasks.activeTasks.pagination,
     pollInterval: state.settings.pollInterval,
     pageSize: state.settings.taskRowsPerPage,
   };
@@ -24,7 +24,7 @@ import {
   runAggregatingTaskAsync,
   runAllAggregatingTasksAsync,
 } from "../actions/tasksActions";
-import { PaginationOptions } from "../api";
+import { PaginationOptions, TaskSearchOptions } from "../api";
 import { taskDetailsPath } from "../paths";
 import { AppState } from "../store";
 import { TableColumn } from "../types/table";
@@ -38,6 +38,7 @@ function mapStateToProps(state: AppState) {
     groupsError: state.groups.error,
     loading: state.tasks.aggregatingTasks.loading,
     allActionPending: state.tasks.aggregatingTasks.allActionPending,
+    pagination: state.tasks.aggregatingTasks.pagination,
     batchActionPending: state.tasks.aggregatingTasks.batchActionPending,
     error: state.tasks.aggregatingTasks.error,
     group: state.tasks.aggregatingTasks.group,
@@ -181,8 +182,11 @@ function Row(props: RowProps) {
 }
 
 function AggregatingTasksTable(props: Props & ReduxProps) {
-  const listTasks = (qname: string, pgn?: PaginationOptions) =>
-    props.listAggregatingTasksAsync(qname, props.selectedGroup, pgn);
+  const listTasks = (
+    qname: string,
+    pgn?: PaginationOptions,
+    search?: TaskSearchOptions
+  ) => props.listAggregatingTasksAsync(qname, props.selectedGroup, pgn, search);
 
   const deleteAllTasks = (qname: string) =>
     props.deleteAllAggregatingTasksAsync(qname, props.selectedGroup);
@@ -219,6 +223,7 @@ function AggregatingTasksTable(props: Props & ReduxProps) {
     <TasksTable
       queue={props.queue}
       totalTaskCount={props.totalTaskCount}
+      pagination={props.pagination}
       taskState="aggregating"
       loading={props.loading}
       error={props.error}
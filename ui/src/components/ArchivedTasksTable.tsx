@@ -34,6 +34,7 @@ function mapStateToProps(state: AppState) {
     tasks: state.tasks.archivedTasks.data,
     batchActionPending: state.tasks.archivedTasks.batchActionPending,
     allActionPending: state.tasks.archivedTasks.allActionPending,
+    pagination: state.tasks.archivedTasks.pagination,
     pollInterval: state.settings.pollInterval,
     pageSize: state.settings.taskRowsPerPage,
   };
@@ -36,6 +36,7 @@ function mapStateToProps(state: AppState) {
     tasks: state.tasks.completedTasks.data,
     batchActionPending: state.tasks.completedTasks.batchActionPending,
     allActionPending: state.tasks.completedTasks.allActionPending,
+    pagination: state.tasks.completedTasks.pagination,
     pollInterval: state.settings.pollInterval,
     pageSize: state.settings.taskRowsPerPage,
   };
@@ -0,0 +1,158 @@
+import React, { useState } from "react";
+import { connect, ConnectedProps } from "react-redux";
+import { makeStyles } from "@material-ui/core/styles";
+import Button from "@material-ui/core/Button";
+import Dialog from "@material-ui/core/Dialog";
+import DialogActions from "@material-ui/core/DialogActions";
+import DialogContent from "@material-ui/core/DialogContent";
+import DialogTitle from "@material-ui/core/DialogTitle";
+import Grid from "@material-ui/core/Grid";
+import TextField from "@material-ui/core/TextField";
+import { EnqueueTaskRequest } from "../api";
+import { enqueueTaskAsync } from "../actions/tasksActions";
+
+const useStyles = makeStyles((theme) => ({
+  payload: {
+    fontFamily: "monospace",
+  },
+}));
+
+const connector = connect(null, { enqueueTaskAsync });
+
+interface Props {
+  queue: string; // queue to enqueue the task in
+  open: boolean;
+  onClose: () => void;
+}
+
+// EnqueueTaskDialog is a form to enqueue an ad-hoc task,
+// e.g. to replay a job manually during incident response.
+function EnqueueTaskDialog(props: Props & ConnectedProps<typeof connector>) {
+  const classes = useStyles();
+  const [taskType, setTaskType] = useState("");
+  const [payload, setPayload] = useState("");
+  const [taskId, setTaskId] = useState("");
+  const [maxRetry, setMaxRetry] = useState("");
+  const [taskTimeout, setTaskTimeout] = useState("");
+  const [processIn, setProcessIn] = useState("");
+
+  let payloadError = "";
+  let parsedPayload: any = undefined;
+  if (payload.trim() !== "") {
+    try {
+      parsedPayload = JSON.parse(payload);
+    } catch (error) {
+      payloadError = "Payload must be valid JSON";
+    }
+  }
+  const maxRetryError =
+    maxRetry !== "" && !/^\d+$/.test(maxRetry)
+      ? "Max retry must be a non-negative integer"
+      : "";
+  const canSubmit =
+    taskType.trim() !== "" && payloadError === "" && maxRetryError === "";
+
+  const handleEnqueueClick = () => {
+    const req: EnqueueTaskRequest = { type: taskType.trim() };
+    if (parsedPayload !== undefined) req.payload = parsedPayload;
+    if (taskId.trim() !== "") req.task_id = taskId.trim();
+    if (maxRetry !== "") req.max_retry = parseInt(maxRetry, 10);
+    if (taskTimeout.trim() !== "") req.timeout = taskTimeout.trim();
+    if (processIn.trim() !== "") req.process_in = processIn.trim();
+    props.enqueueTaskAsync(props.queue, req);
+    props.onClose();
+  };
+
+  return (
+    <Dialog
+      open={props.open}
+      onClose={props.onClose}
+      aria-labelledby="enqueue-task-dialog-title"
+      fullWidth
+      maxWidth="sm"
+    >
+      <DialogTitle id="enqueue-task-dialog-title">
+        Enqueue a task in "{props.queue}"
+      </DialogTitle>
+      <DialogContent>
+        <Grid container spacing={2}>
+          <Grid item xs={12}>
+            <TextField
+              label="Type"
+              value={taskType}
+              onChange={(e) => setTaskType(e.target.value)}
+              required
+              fullWidth
+              autoFocus
+            />
+          </Grid>
+          <Grid item xs={12}>
+            <TextField
+              label="Payload (JSON)"
+              value={payload}
+              onChange={(e) => setPayload(e.target.value)}
+              error={payloadError !== ""}
+              helperText={payloadError}
+              multiline
+              rows={6}
+              fullWidth
+              variant="outlined"
+              InputProps={{ className: classes.payload }}
+            />
+          </Grid>
+          <Grid item xs={6}>
+            <TextField
+              label="Task ID"
+              value={taskId}
+              onChange={(e) => setTaskId(e.target.value)}
+              helperText="Random ID if empty"
+              fullWidth
+            />
+          </Grid>
+          <Grid item xs={6}>
+            <TextField
+              label="Max Retry"
+              value={maxRetry}
+              onChange={(e) => setMaxRetry(e.target.value)}
+              error={maxRetryError !== ""}
+              helperText={maxRetryError || "Default is 25"}
+              fullWidth
+            />
+          </Grid>
+          <Grid item xs={6}>
+            <TextField
+              label="Timeout"
+              value={taskTimeout}
+              onChange={(e) => setTaskTimeout(e.target.value)}
+              helperText='Duration such as "30s" or "5m"'
+              fullWidth
+            />
+          </Grid>
+          <Grid item xs={6}>
+            <TextField
+              label="Process In"
+              value={processIn}
+              onChange={(e) => setProcessIn(e.target.value)}
+              helperText="Processed immediately if empty"
+              fullWidth
+            />
+          </Grid>
+        </Grid>
+      </DialogContent>
+      <DialogActions>
+        <Button onClick={props.onClose} color="primary">
+          Cancel
+        </Button>
+        <Button
+          onClick={handleEnqueueClick}
+          disabled={!canSubmit}
+          color="primary"
+        >
+          Enqueue
+        </Button>
+      </DialogActions>
+    </Dialog>
+  );
+}
+
+export default connector(EnqueueTaskDialog);
@@ -0,0 +1,52 @@
+import React, { useCallback, useState } from "react";
+import { makeStyles } from "@material-ui/core/styles";
+import Alert from "@material-ui/lab/Alert";
+import AlertTitle from "@material-ui/lab/AlertTitle";
+import { listRedisFailovers, RedisFailover } from "../api";
+import { usePolling } from "../hooks";
+import { timeAgo } from "../utils";
+
+const useStyles = makeStyles((theme) => ({
+  banner: {
+    margin: theme.spacing(2),
+  },
+}));
+
+// Polling interval in seconds.
+const POLL_INTERVAL = 30;
+
+// Failovers older than this (in milliseconds) are not shown.
+const RECENT_FAILOVER_WINDOW = 60 * 60 * 1000;
+
+// FailoverBanner shows a banner if redis sentinels reported a failover recently.
+export default function FailoverBanner() {
+  const classes = useStyles();
+  const [failover, setFailover] = useState<RedisFailover | null>(null);
+  const fetchFailovers = useCallback(() => {
+    listRedisFailovers()
+      .then((resp) => {
+        const latest = resp.failovers.length > 0 ? resp.failovers[0] : null;
+        if (
+          latest &&
+          Date.now() - Date.parse(latest.time) < RECENT_FAILOVER_WINDOW
+        ) {
+          setFailover(latest);
+        } else {
+          setFailover(null);
+        }
+      })
+      .catch(() => setFailover(null));
+  }, []);
+  usePolling(fetchFailovers, POLL_INTERVAL);
+
+  if (!failover) {
+    return null;
+  }
+  return (
+    <Alert severity="warning" className={classes.banner}>
+      <AlertTitle>Redis failover</AlertTitle>
+      Redis master "{failover.master}" was switched from{" "}
+      {failover.old_address} to {failover.new_address} {timeAgo(failover.time)}.
+    </Alert>
+  );
+}
@@ -0,0 +1,181 @@
+import React, { useState } from "react";
+import Button from "@material-ui/core/Button";
+import Checkbox from "@material-ui/core/Checkbox";
+import Dialog from "@material-ui/core/Dialog";
+import DialogActions from "@material-ui/core/DialogActions";
+import DialogContent from "@material-ui/core/DialogContent";
+import DialogContentText from "@material-ui/core/DialogContentText";
+import DialogTitle from "@material-ui/core/DialogTitle";
+import FormControlLabel from "@material-ui/core/FormControlLabel";
+import LinearProgress from "@material-ui/core/LinearProgress";
+import Typography from "@material-ui/core/Typography";
+import {
+  ExportedTask,
+  ImportTasksResponse,
+  importTasks,
+  importTasksBatchSize,
+} from "../api";
+import { toErrorString } from "../utils";
+
+interface Props {
+  queue: string; // queue to import the tasks in
+  open: boolean;
+  onClose: () => void;
+}
+
+// parseExportFile returns the tasks in an export file, which is either
+// NDJSON (one task per line) or a JSON array.
+function parseExportFile(text: string): ExportedTask[] {
+  const trimmed = text.trim();
+  if (trimmed.startsWith("[")) {
+    return JSON.parse(trimmed);
+  }
+  return trimmed
+    .split("\n")
+    .filter((line) => line.trim() !== "")
+    .map((line) => JSON.parse(line));
+}
+
+// ImportTasksDialog enqueues the tasks in a file exported from a queue,
+// sending them in batches so that each request stays small.
+function ImportTasksDialog(props: Props) {
+  const [file, setFile] = useState<File | null>(null);
+  const [dryRun, setDryRun] = useState(true);
+  const [newIds, setNewIds] = useState(false);
+  const [progress, setProgress] = useState<number | null>(null);
+  const [result, setResult] = useState<ImportTasksResponse | null>(null);
+  const [error, setError] = useState("");
+
+  const handleClose = () => {
+    setFile(null);
+    setProgress(null);
+    setResult(null);
+    setError("");
+    props.onClose();
+  };
+
+  const handleImportClick = async () => {
+    if (!file) {
+      return;
+    }
+    setResult(null);
+    setError("");
+    let tasks: ExportedTask[];
+    try {
+      tasks = parseExportFile(await file.text());
+    } catch (error) {
+      setError("The file is not a valid export file");
+      return;
+    }
+    const total: ImportTasksResponse = {
+      enqueued: 0,
+      duplicates: 0,
+      failed: 0,
+      errors: [],
+      dry_run: dryRun,
+    };
+    try {
+      for (let i = 0; i < tasks.length; i += importTasksBatchSize) {
+        setProgress((i / tasks.length) * 100);
+        const resp = await importTasks(
+          props.queue,
+          tasks.slice(i, i + importTasksBatchSize),
+          { dryRun, newIds }
+        );
+        total.enqueued += resp.enqueued;
+        total.duplicates += resp.duplicates;
+        total.failed += resp.failed;
+        total.errors.push(
+          ...resp.errors.map((e) => ({ ...e, index: e.index + i }))
+        );
+      }
+    } catch (error) {
+      setError(toErrorString(error));
+    }
+    setProgress(null);
+    setResult(total);
+  };
+
+  return (
+    <Dialog
+      open={props.open}
+      onClose={handleClose}
+      aria-labelledby="import-tasks-dialog-title"
+      fullWidth
+      maxWidth="sm"
+    >
+      <DialogTitle id="import-tasks-dialog-title">
+        Import tasks into "{props.queue}"
+      </DialogTitle>
+      <DialogContent>
+        <DialogContentText>
+          Select a file exported from a queue. Tasks keep their IDs unless
+          "Assign new IDs" is checked, so importing a file twice does not
+          duplicate tasks.
+        </DialogContentText>
+        <input
+          type="file"
+          accept=".ndjson,.json,application/json,application/x-ndjson"
+          onChange={(e) => setFile(e.target.files?.[0] ?? null)}
+        />
+        <div>
+          <FormControlLabel
+            control={
+              <Checkbox
+                checked={dryRun}
+                onChange={(e) => setDryRun(e.target.checked)}
+                color="primary"
+              />
+            }
+            label="Dry run (validate without enqueueing)"
+          />
+          <FormControlLabel
+            control={
+              <Checkbox
+                checked={newIds}
+                onChange={(e) => setNewIds(e.target.checked)}
+                color="primary"
+              />
+            }
+            label="Assign new IDs"
+          />
+        </div>
+        {progress !== null && (
+          <LinearProgress variant="determinate" value={progress} />
+        )}
+        {result && (
+          <Typography variant="body2" color="textSecondary">
+            {result.dry_run ? "Would enqueue" : "Enqueued"} {result.enqueued}{" "}
+            task(s); {result.duplicates} duplicate(s) skipped; {result.failed}{" "}
+            failed.
+          </Typography>
+        )}
+        {result?.errors.slice(0, 10).map((e) => (
+          <Typography key={e.index} variant="body2" color="error">
+            Task {e.index + 1}
+            {e.id && ` (${e.id})`}: {e.error}
+          </Typography>
+        ))}
+        {error && (
+          <Typography variant="body2" color="error">
+            {error}
+          </Typography>
+        )}
+      </DialogContent>
+      <DialogActions>
+        <Button onClick={handleClose} color="primary">
+          Close
+        </Button>
+        <Button
+          onClick={handleImportClick}
+          disabled={!file || progress !== null}
+          color="primary"
+        >
+          {dryRun ? "Validate" : "Import"}
+        </Button>
+      </DialogActions>
+    </Dialog>
+  );
+}
+
+export default ImportTasksDialog;
@@ -0,0 +1,38 @@
+import React, { useCallback, useState } from "react";
+import { makeStyles } from "@material-ui/core/styles";
+import Alert from "@material-ui/lab/Alert";
+import AlertTitle from "@material-ui/lab/AlertTitle";
+import { getMaintenanceMode, MaintenanceModeState } from "../api";
+import { usePolling } from "../hooks";
+
+const useStyles = makeStyles((theme) => ({
+  banner: {
+    margin: theme.spacing(2),
+  },
+}));
+
+// Polling interval in seconds.
+const POLL_INTERVAL = 10;
+
+// MaintenanceBanner shows a banner while the server is in maintenance mode.
+export default function MaintenanceBanner() {
+  const classes = useStyles();
+  const [state, setState] = useState<MaintenanceModeState | null>(null);
+  const fetchState = useCallback(() => {
+    getMaintenanceMode()
+      .then(setState)
+      .catch(() => setState(null));
+  }, []);
+  usePolling(fetchState, POLL_INTERVAL);
+
+  if (!state || !state.enabled) {
+    return null;
+  }
+  return (
+    <Alert severity="warning" className={classes.banner}>
+      <AlertTitle>Maintenance mode</AlertTitle>
+      {state.message ||
+        "Asynqmon is in maintenance mode. Actions are disabled until the maintenance is over."}
+    </Alert>
+  );
+}
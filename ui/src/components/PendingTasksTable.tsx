@@ -34,6 +34,7 @@ function mapStateToProps(state: AppState) {
     tasks: state.tasks.pendingTasks.data,
     batchActionPending: state.tasks.pendingTasks.batchActionPending,
     allActionPending: state.tasks.pendingTasks.allActionPending,
+    pagination: state.tasks.pendingTasks.pagination,
     pollInterval: state.settings.pollInterval,
     pageSize: state.settings.taskRowsPerPage,
   };
@@ -20,7 +20,7 @@ import { Queue } from "../api";
 import { queueDetailsPath } from "../paths";
 import { SortDirection, SortableTableColumn } from "../types/table";
 import prettyBytes from "pretty-bytes";
-import { percentage } from "../utils";
+import { durationFromSeconds, percentage, stringifyDuration } from "../utils";
 
 const useStyles = makeStyles((theme) => ({
   table: {
@@ -79,6 +79,7 @@ const colConfigs: SortableTableColumn<SortBy>[] = [
     sortBy: SortBy.Latency,
     align: "right",
   },
+  { label: "Trend", key: "trend", sortBy: SortBy.None, align: "right" },
   {
     label: "Processed",
     key: "processed",
@@ -268,6 +269,45 @@ const useRowStyles = makeStyles((theme) => ({
   },
 }));
 
+function QueueTrend(props: { queue: Queue }) {
+  const classes = useRowStyles();
+  const { growth, drain } = props.queue;
+  if (!growth || growth.rates.length === 0) {
+    return <span>-</span>;
+  }
+  const rate = growth.rates[growth.rates.length - 1];
+  let title = `${rate.enqueued_per_minute.toFixed(
+    1
+  )} enqueued/min vs ${rate.processed_per_minute.toFixed(
+    1
+  )} processed/min (last ${rate.window})`;
+  if (drain && drain.drain_seconds !== null && drain.pending > 0) {
+    title += `, backlog clears in ~${stringifyDuration(
+      durationFromSeconds(Math.round(drain.drain_seconds))
+    )} (${drain.confidence} confidence)`;
+  }
+  switch (growth.trend) {
+    case "filling":
+      return (
+        <Tooltip title={title}>
+          <span className={classes.textRed}>filling up</span>
+        </Tooltip>
+      );
+    case "draining":
+      return (
+        <Tooltip title={title}>
+          <span className={classes.textGreen}>draining</span>
+        </Tooltip>
+      );
+    default:
+      return (
+        <Tooltip title={title}>
+          <span>steady</span>
+        </Tooltip>
+      );
+  }
+}
+
 interface RowProps {
   queue: QueueWithMetadata;
   onPauseClick: () => void;
@@ -300,6 +340,9 @@ function Row(props: RowProps) {
       <TableCell align="right">{q.size}</TableCell>
       <TableCell align="right">{prettyBytes(q.memory_usage_bytes)}</TableCell>
       <TableCell align="right">{q.display_latency}</TableCell>
+      <TableCell align="right">
+        <QueueTrend queue={q} />
+      </TableCell>
       <TableCell align="right">{q.processed}</TableCell>
       <TableCell align="right">{q.failed}</TableCell>
       <TableCell align="right">{percentage(q.failed, q.processed)}</TableCell>
@@ -0,0 +1,54 @@
+import React, { useCallback, useState } from "react";
+import { makeStyles } from "@material-ui/core/styles";
+import Alert from "@material-ui/lab/Alert";
+import AlertTitle from "@material-ui/lab/AlertTitle";
+import { getHealth, HealthResponse } from "../api";
+import { usePolling } from "../hooks";
+import { timeAgo } from "../utils";
+
+const useStyles = makeStyles((theme) => ({
+  banner: {
+    margin: theme.spacing(2),
+  },
+}));
+
+// Polling interval in seconds.
+const POLL_INTERVAL = 10;
+
+// RedisHealthBanner shows a banner while the server is failing fast
+// because redis is failing, or while connected to a read-only replica.
+export default function RedisHealthBanner() {
+  const classes = useStyles();
+  const [health, setHealth] = useState<HealthResponse | null>(null);
+  const fetchHealth = useCallback(() => {
+    getHealth()
+      .then(setHealth)
+      .catch(() => setHealth(null));
+  }, []);
+  usePolling(fetchHealth, POLL_INTERVAL);
+
+  if (!health) {
+    return null;
+  }
+  if (health.redis_read_only) {
+    return (
+      <Alert severity="warning" className={classes.banner}>
+        <AlertTitle>Connected to a read-only replica</AlertTitle>
+        Asynqmon is connected to a read-only replica of{" "}
+        {health.redis_master_address}. Actions are disabled until asynqmon is
+        connected to the master. Check the redis connection settings.
+      </Alert>
+    );
+  }
+  const breaker = health.redis_circuit_breaker;
+  if (!breaker || breaker.state === "closed") {
+    return null;
+  }
+  return (
+    <Alert severity="error" className={classes.banner}>
+      <AlertTitle>Redis is failing</AlertTitle>
+      Requests to redis have been failing since {timeAgo(breaker.opened_at)}.
+      Data shown may be stale and actions may fail until redis recovers.
+    </Alert>
+  );
+}
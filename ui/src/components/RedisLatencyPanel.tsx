@@ -0,0 +1,129 @@
+import React, { useCallback, useState } from "react";
+import { useTheme } from "@material-ui/core/styles";
+import Grid from "@material-ui/core/Grid";
+import Typography from "@material-ui/core/Typography";
+import Link from "@material-ui/core/Link";
+import Alert from "@material-ui/lab/Alert";
+import {
+  LineChart,
+  Line,
+  XAxis,
+  YAxis,
+  CartesianGrid,
+  Tooltip,
+  ResponsiveContainer,
+} from "recharts";
+import SyntaxHighlighter from "./SyntaxHighlighter";
+import { getRedisLatency, RedisLatencyResponse } from "../api";
+import { usePolling } from "../hooks";
+
+interface Props {
+  pollInterval: number;
+}
+
+interface ChartData {
+  timestamp: number;
+  latency?: number;
+}
+
+function formatMillis(ms: number): string {
+  return `${ms.toFixed(2)} ms`;
+}
+
+// RedisLatencyPanel charts the PING latency measured by the server and shows
+// the output of LATENCY commands so that slowness can be attributed to redis.
+export default function RedisLatencyPanel(props: Props) {
+  const theme = useTheme();
+  const [data, setData] = useState<RedisLatencyResponse | null>(null);
+
+  const fetchLatency = useCallback(() => {
+    getRedisLatency()
+      .then(setData)
+      .catch(() => setData(null));
+  }, []);
+  usePolling(fetchLatency, props.pollInterval);
+
+  if (!data) {
+    return null;
+  }
+  const chartData: ChartData[] = data.ping_samples.map((s) => ({
+    timestamp: Date.parse(s.time) / 1000,
+    // Leave a gap in the chart for failed PINGs.
+    latency: s.error ? undefined : s.latency_ms,
+  }));
+  const stats = data.ping_stats;
+  return (
+    <>
+      <Grid item xs={12}>
+        <Typography variant="h6" color="textSecondary">
+          PING Latency
+        </Typography>
+        {stats && (
+          <Typography variant="subtitle2" color="textSecondary">
+            Last: {formatMillis(stats.last_ms)} / Avg:{" "}
+            {formatMillis(stats.avg_ms)} / Min: {formatMillis(stats.min_ms)} /
+            Max: {formatMillis(stats.max_ms)}
+            {stats.errors > 0 && ` / Failed: ${stats.errors}`}
+          </Typography>
+        )}
+        <ResponsiveContainer height={200}>
+          <LineChart data={chartData}>
+            <CartesianGrid strokeDasharray="3 3" />
+            <XAxis
+              minTickGap={10}
+              dataKey="timestamp"
+              domain={["dataMin", "dataMax"]}
+              tickFormatter={(timestamp: number) =>
+                new Date(timestamp * 1000).toLocaleTimeString()
+              }
+              type="number"
+              scale="time"
+              stroke={theme.palette.text.secondary}
+            />
+            <YAxis
+              tickFormatter={(ms: number) => `${ms}ms`}
+              stroke={theme.palette.text.secondary}
+            />
+            <Tooltip
+              labelFormatter={(timestamp: number) =>
+                new Date(timestamp * 1000).toLocaleTimeString()
+              }
+              formatter={(ms: number) => formatMillis(ms)}
+            />
+            <Line
+              type="monotone"
+              dataKey="latency"
+              name="PING"
+              stroke="#2085ec"
+              dot={false}
+              isAnimationActive={false}
+            />
+          </LineChart>
+        </ResponsiveContainer>
+      </Grid>
+      <Grid item xs={12}>
+        <Typography variant="h6" color="textSecondary">
+          <Link href="https://redis.io/commands/latency-doctor" target="_">
+            LATENCY DOCTOR
+          </Link>{" "}
+          Command Output
+        </Typography>
+        {data.latency_error ? (
+          <Alert severity="info">
+            LATENCY commands are not available: {data.latency_error}
+          </Alert>
+        ) : (
+          <>
+            {data.events.map((e) => (
+              <Typography key={e.event} variant="body2" color="textSecondary">
+                {e.event}: latest {e.latest_ms} ms, max {e.max_ms} ms (
+                {e.history.length} spikes)
+              </Typography>
+            ))}
+            <SyntaxHighlighter language="yaml">{data.doctor}</SyntaxHighlighter>
+          </>
+        )}
+      </Grid>
+    </>
+  );
+}
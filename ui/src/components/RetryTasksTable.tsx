@@ -38,6 +38,7 @@ function mapStateToProps(state: AppState) {
     tasks: state.tasks.retryTasks.data,
     batchActionPending: state.tasks.retryTasks.batchActionPending,
     allActionPending: state.tasks.retryTasks.allActionPending,
+    pagination: state.tasks.retryTasks.pagination,
     pollInterval: state.settings.pollInterval,
     pageSize: state.settings.taskRowsPerPage,
   };
@@ -38,6 +38,7 @@ function mapStateToProps(state: AppState) {
     tasks: state.tasks.scheduledTasks.data,
     batchActionPending: state.tasks.scheduledTasks.batchActionPending,
     allActionPending: state.tasks.scheduledTasks.allActionPending,
+    pagination: state.tasks.scheduledTasks.pagination,
     pollInterval: state.settings.pollInterval,
     pageSize: state.settings.taskRowsPerPage,
   };
@@ -1,4 +1,5 @@
 import React, { useState } from "react";
+import { connect, ConnectedProps } from "react-redux";
 import clsx from "clsx";
 import { makeStyles } from "@material-ui/core/styles";
 import IconButton from "@material-ui/core/IconButton";
@@ -12,6 +13,7 @@ import Modal from "@material-ui/core/Modal";
 import Typography from "@material-ui/core/Typography";
 import Tooltip from "@material-ui/core/Tooltip";
 import HistoryIcon from "@material-ui/icons/History";
+import PlayArrowIcon from "@material-ui/icons/PlayArrow";
 import Alert from "@material-ui/lab/Alert";
 import AlertTitle from "@material-ui/lab/AlertTitle";
 import { SortDirection, SortableTableColumn } from "../types/table";
@@ -20,6 +22,7 @@ import SyntaxHighlighter from "./SyntaxHighlighter";
 import { SchedulerEntry } from "../api";
 import { timeAgo, durationBefore, prettifyPayload } from "../utils";
 import SchedulerEnqueueEventsTable from "./SchedulerEnqueueEventsTable";
+import { runSchedulerEntryAsync } from "../actions/schedulerEntriesActions";
 
 const useStyles = makeStyles((theme) => ({
   table: {
@@ -125,11 +128,15 @@ function sortEntries(
   return copy;
 }
 
+const connector = connect(null, { runSchedulerEntryAsync });
+
 interface Props {
   entries: SchedulerEntry[];
 }
 
-export default function SchedulerEntriesTable(props: Props) {
+function SchedulerEntriesTable(
+  props: Props & ConnectedProps<typeof connector>
+) {
   const classes = useStyles();
   const [sortBy, setSortBy] = useState<SortBy>(SortBy.EntryId);
   const [sortDir, setSortDir] = useState<SortDirection>(SortDirection.Asc);
@@ -230,6 +237,7 @@ export default function SchedulerEntriesTable(props: Props) {
                 entry={entry}
                 isLastRow={idx === props.entries.length - 1}
                 onShowHistoryClick={() => setActiveEntryId(entry.id)}
+                onRunClick={() => props.runSchedulerEntryAsync(entry.id)}
               />
             ))}
           </TableBody>
@@ -255,6 +263,7 @@ interface RowProps {
   entry: SchedulerEntry;
   isLastRow: boolean;
   onShowHistoryClick: () => void;
+  onRunClick: () => void;
 }
 
 const useRowStyles = makeStyles((theme) => ({
@@ -312,7 +321,20 @@ function Row(props: RowProps) {
             <HistoryIcon />
           </IconButton>
         </Tooltip>
+        {!window.READ_ONLY && (
+          <Tooltip title="Run Now">
+            <IconButton
+              aria-label="run now"
+              size="small"
+              onClick={props.onRunClick}
+            >
+              <PlayArrowIcon />
+            </IconButton>
+          </Tooltip>
+        )}
       </TableCell>
     </TableRow>
   );
 }
+
+export default connector(SchedulerEntriesTable);
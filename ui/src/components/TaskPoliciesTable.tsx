@@ -0,0 +1,88 @@
+import React, { useEffect, useState } from "react";
+import { makeStyles } from "@material-ui/core/styles";
+import Table from "@material-ui/core/Table";
+import TableBody from "@material-ui/core/TableBody";
+import TableCell from "@material-ui/core/TableCell";
+import TableContainer from "@material-ui/core/TableContainer";
+import TableHead from "@material-ui/core/TableHead";
+import TableRow from "@material-ui/core/TableRow";
+import Typography from "@material-ui/core/Typography";
+import { listTaskPolicies, PolicyValueCount, TaskPolicy } from "../api";
+import { durationFromSeconds, stringifyDuration } from "../utils";
+
+const useStyles = makeStyles((theme) => ({
+  table: {
+    minWidth: 650,
+  },
+}));
+
+function formatTimeout(seconds: number): string {
+  return seconds === 0
+    ? "none"
+    : stringifyDuration(durationFromSeconds(seconds));
+}
+
+function formatValueCounts(
+  values: PolicyValueCount[],
+  format: (v: number) => string
+): string {
+  if (values.length === 1) {
+    return format(values[0].value);
+  }
+  return values.map((v) => `${format(v.value)} (${v.count})`).join(", ");
+}
+
+// TaskPoliciesTable shows the retry and timeout options tasks were enqueued with,
+// aggregated by task type over a sample of tasks.
+export default function TaskPoliciesTable() {
+  const classes = useStyles();
+  const [policies, setPolicies] = useState<TaskPolicy[]>([]);
+
+  useEffect(() => {
+    listTaskPolicies()
+      .then((resp) => setPolicies(resp.policies))
+      .catch(() => setPolicies([]));
+  }, []);
+
+  if (policies.length === 0) {
+    return (
+      <Typography color="textSecondary">
+        No tasks to sample policies from
+      </Typography>
+    );
+  }
+  return (
+    <TableContainer>
+      <Table className={classes.table} aria-label="task policies table">
+        <TableHead>
+          <TableRow>
+            <TableCell>Task Type</TableCell>
+            <TableCell>Queues</TableCell>
+            <TableCell>Max Retry</TableCell>
+            <TableCell>Timeout</TableCell>
+            <TableCell>With Deadline</TableCell>
+            <TableCell>Sampled</TableCell>
+          </TableRow>
+        </TableHead>
+        <TableBody>
+          {policies.map((p) => (
+            <TableRow key={p.task_type}>
+              <TableCell component="th" scope="row">
+                {p.task_type}
+              </TableCell>
+              <TableCell>{p.queues.join(", ")}</TableCell>
+              <TableCell>
+                {formatValueCounts(p.max_retry, (v) => v.toString())}
+              </TableCell>
+              <TableCell>
+                {formatValueCounts(p.timeout_seconds, formatTimeout)}
+              </TableCell>
+              <TableCell>{p.with_deadline}</TableCell>
+              <TableCell>{p.sampled}</TableCell>
+            </TableRow>
+          ))}
+        </TableBody>
+      </Table>
+    </TableContainer>
+  );
+}
@@ -0,0 +1,71 @@
+import React, { useState } from "react";
+import { makeStyles } from "@material-ui/core/styles";
+import TextField from "@material-ui/core/TextField";
+import InputAdornment from "@material-ui/core/InputAdornment";
+import SearchIcon from "@material-ui/icons/Search";
+import { TaskSearchOptions } from "../api";
+
+const useStyles = makeStyles((theme) => ({
+  form: {
+    display: "flex",
+    alignItems: "center",
+    padding: theme.spacing(1),
+    "& > *": {
+      marginRight: theme.spacing(2),
+    },
+  },
+}));
+
+interface Props {
+  // Called with the search options when the form is submitted,
+  // or undefined if both fields are empty.
+  onSearch: (search?: TaskSearchOptions) => void;
+}
+
+// TaskSearchBar lets users filter the tasks listed by the server
+// by task type and payload.
+export default function TaskSearchBar(props: Props) {
+  const classes = useStyles();
+  const [type, setType] = useState("");
+  const [payload, setPayload] = useState("");
+
+  const handleSubmit = (event: React.FormEvent<HTMLFormElement>) => {
+    event.preventDefault();
+    const search: TaskSearchOptions = {};
+    if (type.trim() !== "") {
+      search.type = type.trim();
+    }
+    if (payload !== "") {
+      search.payload_contains = payload;
+    }
+    props.onSearch(Object.keys(search).length > 0 ? search : undefined);
+  };
+
+  return (
+    <form className={classes.form} onSubmit={handleSubmit}>
+      <TextField
+        size="small"
+        placeholder="Task type (e.g. email:*)"
+        value={type}
+        onChange={(e) => setType(e.target.value)}
+        InputProps={{
+          startAdornment: (
+            <InputAdornment position="start">
+              <SearchIcon fontSize="small" />
+            </InputAdornment>
+          ),
+        }}
+        inputProps={{ "aria-label": "search by task type" }}
+      />
+      <TextField
+        size="small"
+        placeholder="Payload contains"
+        value={payload}
+        onChange={(e) => setPayload(e.target.value)}
+        inputProps={{ "aria-label": "search by payload" }}
+      />
+      {/* Submit the form with the enter key. */}
+      <input type="submit" hidden />
+    </form>
+  );
+}
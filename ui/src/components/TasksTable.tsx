@@ -21,10 +21,11 @@ import TablePaginationActions, {
   rowsPerPageOptions,
 } from "./TablePaginationActions";
 import TableActions from "./TableActions";
+import TaskSearchBar from "./TaskSearchBar";
 import { usePolling } from "../hooks";
 import { TaskInfoExtended } from "../reducers/tasksReducer";
 import { TableColumn } from "../types/table";
-import { PaginationOptions } from "../api";
+import { Pagination, PaginationOptions, TaskSearchOptions } from "../api";
 import { TaskState } from "../types/taskState";
 
 const useStyles = makeStyles((theme) => ({
@@ -46,6 +47,7 @@ const useStyles = makeStyles((theme) => ({
 interface Props {
   queue: string; // name of the queue.
   totalTaskCount: number; // totoal number of tasks in the given state.
+  pagination?: Pagination; // pagination of the last listed page.
   taskState: TaskState;
   loading: boolean;
   error: string;
@@ -55,9 +57,16 @@ interface Props {
   pollInterval: number;
   pageSize: number;
   columns: TableColumn[];
+  // Set live to true if tasks holds every task pushed by the live updates stream
+  // instead of the listed page. The table stops polling and pages the tasks itself.
+  live?: boolean;
 
   // actions
-  listTasks: (qname: string, pgn: PaginationOptions) => void;
+  listTasks: (
+    qname: string,
+    pgn: PaginationOptions,
+    search?: TaskSearchOptions
+  ) => void;
   batchDeleteTasks?: (qname: string, taskIds: string[]) => Promise<void>;
   batchRunTasks?: (qname: string, taskIds: string[]) => Promise<void>;
   batchArchiveTasks?: (qname: string, taskIds: string[]) => Promise<void>;
@@ -81,6 +90,13 @@ export default function TasksTable(props: Props) {
   const [page, setPage] = useState(0);
   const [selectedIds, setSelectedIds] = useState<string[]>([]);
   const [activeTaskId, setActiveTaskId] = useState<string>("");
+  // Filter applied by the server, set with the search bar.
+  const [search, setSearch] = useState<TaskSearchOptions | undefined>();
+
+  // Tasks shown in the current page.
+  const tasks = props.live
+    ? props.tasks.slice(page * pageSize, (page + 1) * pageSize)
+    : props.tasks;
 
   const handlePageChange = (
     event: React.MouseEvent<HTMLButtonElement> | null,
@@ -96,9 +112,15 @@ export default function TasksTable(props: Props) {
     setPage(0);
   };
 
+  const handleSearch = (newSearch?: TaskSearchOptions) => {
+    setSearch(newSearch);
+    setPage(0);
+    setSelectedIds([]);
+  };
+
   const handleSelectAllClick = (event: React.ChangeEvent<HTMLInputElement>) => {
     if (event.target.checked) {
-      const newSelected = props.tasks.map((t) => t.id);
+      const newSelected = tasks.map((t) => t.id);
       setSelectedIds(newSelected);
     } else {
       setSelectedIds([]);
@@ -188,36 +210,57 @@ export default function TasksTable(props: Props) {
 
   const fetchData = useCallback(() => {
     const pageOpts = { page: page + 1, size: pageSize };
-    listTasks(queue, pageOpts);
-  }, [page, pageSize, queue, listTasks]);
+    listTasks(queue, pageOpts, search);
+  }, [page, pageSize, queue, listTasks, search]);
 
-  usePolling(fetchData, pollInterval);
+  usePolling(fetchData, pollInterval, props.live);
 
+  // Live tables hold every task, so searching the listed tasks on the server does not apply.
+  const searchBar = !props.live && <TaskSearchBar onSearch={handleSearch} />;
   if (props.error.length > 0) {
     return (
-      <Alert severity="error" className={classes.alert}>
-        <AlertTitle>Error</AlertTitle>
-        {props.error}
-      </Alert>
+      <div>
+        {searchBar}
+        <Alert severity="error" className={classes.alert}>
+          <AlertTitle>Error</AlertTitle>
+          {props.error}
+        </Alert>
+      </div>
     );
   }
   if (props.tasks.length === 0) {
     return (
-      <Alert severity="info" className={classes.alert}>
-        <AlertTitle>Info</AlertTitle>
-        {props.taskState === "aggregating" ? (
-          <div>Selected group is empty.</div>
-        ) : (
-          <div>No {props.taskState} tasks at this time.</div>
-        )}
-      </Alert>
+      <div>
+        {searchBar}
+        <Alert severity="info" className={classes.alert}>
+          <AlertTitle>Info</AlertTitle>
+          {search ? (
+            <div>No {props.taskState} tasks match the search.</div>
+          ) : props.taskState === "aggregating" ? (
+            <div>Selected group is empty.</div>
+          ) : (
+            <div>No {props.taskState} tasks at this time.</div>
+          )}
+        </Alert>
+      </div>
     );
   }
 
-  const rowCount = props.tasks.length;
+  // The server does not count every match of a search if it finds the page first.
+  let count = props.pagination?.total ?? props.totalTaskCount;
+  if (props.live) {
+    count = props.tasks.length;
+  } else if (search && props.pagination?.total == null) {
+    count = props.pagination?.next_page
+      ? (page + 2) * pageSize
+      : page * pageSize + tasks.length;
+  }
+
+  const rowCount = tasks.length;
   const numSelected = selectedIds.length;
   return (
     <div>
+      {searchBar}
       {!window.READ_ONLY && (
         <TableActions
           showIconButtons={numSelected > 0}
@@ -268,7 +311,7 @@ export default function TasksTable(props: Props) {
             </TableRow>
           </TableHead>
           <TableBody>
-            {props.tasks.map((task) => {
+            {tasks.map((task) => {
               return props.renderRow({
                 key: task.id,
                 task: task,
@@ -304,7 +347,7 @@ export default function TasksTable(props: Props) {
               <TablePagination
                 rowsPerPageOptions={rowsPerPageOptions}
                 colSpan={props.columns.length + 1}
-                count={props.totalTaskCount}
+                count={count}
                 rowsPerPage={pageSize}
                 page={page}
                 SelectProps={{
@@ -4,6 +4,12 @@ interface Window {
   FLAG_ROOT_PATH: string;
   FLAG_PROMETHEUS_SERVER_ADDRESS: string;
   FLAG_READ_ONLY: string;
+  FLAG_DISABLED_SECTIONS: string;
+  FLAG_USERNAME: string;
+  FLAG_SIGN_OUT_PATH: string;
+  FLAG_CLUSTER: string;
+  FLAG_CLUSTERS: string;
+  FLAG_CLUSTERS_ROOT_PATH: string;
 
   // Root URL path for asynqmon app.
   // ROOT_PATH should not have the tailing slash.
@@ -15,4 +21,22 @@ interface Window {
 
   // If true, app hides buttons/links to make non-GET requests to the API server.
   READ_ONLY: boolean;
+
+  // Names of the sections disabled by the server (e.g. "schedulers", "redis").
+  DISABLED_SECTIONS: string[];
+
+  // Name of the signed in user, or empty string if users do not sign in.
+  USERNAME: string;
+
+  // Path to sign out, or empty string if users cannot sign out.
+  SIGN_OUT_PATH: string;
+
+  // Name of the cluster being monitored, or empty string if the server monitors a single cluster.
+  CLUSTER: string;
+
+  // Names of all clusters monitored by the server.
+  CLUSTERS: string[];
+
+  // Root path under which each cluster is served at "/clusters/<name>".
+  CLUSTERS_ROOT_PATH: string;
 }
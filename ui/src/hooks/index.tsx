@@ -3,12 +3,20 @@ import { useLocation } from "react-router-dom";
 
 // usePolling repeatedly calls doFn with a fix time delay specified
 // by interval (in millisecond).
-export function usePolling(doFn: () => void, interval: number) {
+// Polling stops while paused is true, e.g. while the data is pushed by the live updates stream.
+export function usePolling(
+  doFn: () => void,
+  interval: number,
+  paused: boolean = false
+) {
   useEffect(() => {
+    if (paused) {
+      return;
+    }
     doFn();
     const id = setInterval(doFn, interval * 1000);
     return () => clearInterval(id);
-  }, [interval, doFn]);
+  }, [interval, doFn, paused]);
 }
 
 // useQuery gets the URL search params from the current URL.
@@ -40,4 +40,53 @@ export default function parseFlagsUnderWindow() {
   } else {
     window.READ_ONLY = window.FLAG_READ_ONLY === "true";
   }
+
+  // DISABLED_SECTIONS
+  if (window.FLAG_DISABLED_SECTIONS === undefined) {
+    console.log("DISABLED_SECTIONS is not defined. Falling back to empty list");
+    window.DISABLED_SECTIONS = [];
+  } else if (window.FLAG_DISABLED_SECTIONS.startsWith(goTmplActionPrefix)) {
+    console.log(
+      "DISABLED_SECTIONS was not evaluated by the server. Falling back to empty list"
+    );
+    window.DISABLED_SECTIONS = [];
+  } else {
+    window.DISABLED_SECTIONS = window.FLAG_DISABLED_SECTIONS.split(",").filter(
+      (s) => s !== ""
+    );
+  }
+
+  // USERNAME and SIGN_OUT_PATH
+  window.USERNAME = parseStringFlag(window.FLAG_USERNAME);
+  window.SIGN_OUT_PATH = parseStringFlag(window.FLAG_SIGN_OUT_PATH);
+
+  // CLUSTER, CLUSTERS, and CLUSTERS_ROOT_PATH
+  window.CLUSTER = parseStringFlag(window.FLAG_CLUSTER);
+  window.CLUSTERS = parseStringFlag(window.FLAG_CLUSTERS)
+    .split(",")
+    .filter((s) => s !== "");
+  window.CLUSTERS_ROOT_PATH = parseStringFlag(window.FLAG_CLUSTERS_ROOT_PATH);
+}
+
+// parseStringFlag returns the value of an optional string flag, or empty string
+// if the flag is not defined or was not evaluated by the server.
+function parseStringFlag(value: string | undefined): string {
+  if (value === undefined || value.startsWith(goTmplActionPrefix)) {
+    return "";
+  }
+  return value;
+}
+
+// clusterURL returns the URL of the current page in the cluster with the given name.
+export function clusterURL(name: string): string {
+  let path = window.location.pathname;
+  if (path.startsWith(window.ROOT_PATH)) {
+    path = path.slice(window.ROOT_PATH.length);
+  }
+  return `${window.CLUSTERS_ROOT_PATH}/clusters/${name}${path || "/"}`;
+}
+
+// isSectionEnabled reports whether the section with the given name is enabled by the server.
+export function isSectionEnabled(name: string): boolean {
+  return !window.DISABLED_SECTIONS.includes(name);
 }
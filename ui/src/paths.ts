@@ -7,6 +7,7 @@ export const paths = () => ({
   REDIS: `${window.ROOT_PATH}/redis`,
   TASK_DETAILS: `${window.ROOT_PATH}/queues/:qname/tasks/:taskId`,
   QUEUE_METRICS: `${window.ROOT_PATH}/q/metrics`,
+  PLUGINS: `${window.ROOT_PATH}/plugins`,
 });
 
 /**************************************************************
@@ -0,0 +1,126 @@
+import {
+  LiveActionTypes,
+  LIVE_ACTIVE_TASKS_UPDATED,
+  LIVE_UPDATES_CONNECTED,
+  LIVE_UPDATES_DISCONNECTED,
+} from "../actions/liveActions";
+import {
+  BATCH_CANCEL_ACTIVE_TASKS_BEGIN,
+  BATCH_CANCEL_ACTIVE_TASKS_ERROR,
+  BATCH_CANCEL_ACTIVE_TASKS_SUCCESS,
+  CANCEL_ACTIVE_TASK_BEGIN,
+  CANCEL_ACTIVE_TASK_ERROR,
+  CANCEL_ACTIVE_TASK_SUCCESS,
+  TasksActionTypes,
+} from "../actions/tasksActions";
+import { TaskInfoExtended } from "./tasksReducer";
+
+interface LiveState {
+  // Indicates that the live updates stream is connected.
+  // Views stop polling the API while the stream is connected.
+  connected: boolean;
+
+  // Active tasks of each queue received from the stream.
+  activeTasks: { [qname: string]: TaskInfoExtended[] };
+}
+
+const initialState: LiveState = {
+  connected: false,
+  activeTasks: {},
+};
+
+// updateTasks returns the state with fn applied to the active tasks of the queue.
+function updateTasks(
+  state: LiveState,
+  qname: string,
+  fn: (task: TaskInfoExtended) => TaskInfoExtended
+): LiveState {
+  const tasks = state.activeTasks[qname];
+  if (!tasks) {
+    return state;
+  }
+  return {
+    ...state,
+    activeTasks: { ...state.activeTasks, [qname]: tasks.map(fn) },
+  };
+}
+
+function liveReducer(
+  state = initialState,
+  action: LiveActionTypes | TasksActionTypes
+): LiveState {
+  switch (action.type) {
+    case LIVE_UPDATES_CONNECTED:
+      // The server sends every active task again when the stream is (re)connected.
+      return { connected: true, activeTasks: {} };
+
+    case LIVE_UPDATES_DISCONNECTED:
+      return { ...state, connected: false };
+
+    case LIVE_ACTIVE_TASKS_UPDATED: {
+      const { queue, reset, added, removed } = action.payload;
+      const current = reset ? [] : state.activeTasks[queue] || [];
+      // Replace changed tasks in place, keeping the state of pending requests,
+      // and append new ones.
+      const updated = current
+        .filter((t) => !removed.includes(t.id))
+        .map((t) => {
+          const task = added.find((a) => a.id === t.id);
+          return task ? { ...t, ...task } : t;
+        });
+      for (const task of added) {
+        if (!updated.some((t) => t.id === task.id)) {
+          updated.push({ ...task, requestPending: false, canceling: false });
+        }
+      }
+      const activeTasks = { ...state.activeTasks, [queue]: updated };
+      if (updated.length === 0) {
+        delete activeTasks[queue];
+      }
+      return { ...state, activeTasks };
+    }
+
+    case CANCEL_ACTIVE_TASK_BEGIN:
+      return updateTasks(state, action.queue, (t) =>
+        t.id === action.taskId ? { ...t, requestPending: true } : t
+      );
+
+    case CANCEL_ACTIVE_TASK_SUCCESS:
+      return updateTasks(state, action.queue, (t) =>
+        t.id === action.taskId
+          ? { ...t, requestPending: false, canceling: true }
+          : t
+      );
+
+    case CANCEL_ACTIVE_TASK_ERROR:
+      return updateTasks(state, action.queue, (t) =>
+        t.id === action.taskId ? { ...t, requestPending: false } : t
+      );
+
+    case BATCH_CANCEL_ACTIVE_TASKS_BEGIN:
+      return updateTasks(state, action.queue, (t) =>
+        action.taskIds.includes(t.id) ? { ...t, requestPending: true } : t
+      );
+
+    case BATCH_CANCEL_ACTIVE_TASKS_SUCCESS:
+      return updateTasks(state, action.queue, (t) => {
+        if (action.payload.canceled_ids.includes(t.id)) {
+          return { ...t, requestPending: false, canceling: true };
+        }
+        if (action.payload.error_ids.includes(t.id)) {
+          return { ...t, requestPending: false };
+        }
+        return t;
+      });
+
+    case BATCH_CANCEL_ACTIVE_TASKS_ERROR:
+      return updateTasks(state, action.queue, (t) =>
+        action.taskIds.includes(t.id) ? { ...t, requestPending: false } : t
+      );
+
+    default:
+      return state;
+  }
+}
+
+export default liveReducer;
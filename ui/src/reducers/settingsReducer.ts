@@ -1,6 +1,7 @@
 import {
   DAILY_STATS_KEY_CHANGE,
   POLL_INTERVAL_CHANGE,
+  SETTINGS_RESTORE,
   SettingsActionTypes,
   TASK_ROWS_PER_PAGE_CHANGE,
   THEME_PREFERENCE_CHANGE,
@@ -75,6 +76,12 @@ function settingsReducer(
         dailyStatsChartType: action.value,
       }
 
+    case SETTINGS_RESTORE:
+      return {
+        ...state,
+        ...action.value,
+      };
+
     default:
       return state;
   }
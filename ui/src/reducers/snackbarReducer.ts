@@ -31,6 +31,8 @@ import {
   RUN_SCHEDULED_TASK_SUCCESS,
   TasksActionTypes,
   ARCHIVE_PENDING_TASK_SUCCESS,
+  PRIORITIZE_PENDING_TASK_SUCCESS,
+  PRIORITIZE_PENDING_TASK_ERROR,
   DELETE_PENDING_TASK_SUCCESS,
   BATCH_ARCHIVE_PENDING_TASKS_SUCCESS,
   BATCH_DELETE_PENDING_TASKS_SUCCESS,
@@ -48,7 +50,14 @@ import {
   RUN_ALL_AGGREGATING_TASKS_SUCCESS,
   ARCHIVE_ALL_AGGREGATING_TASKS_SUCCESS,
   DELETE_ALL_AGGREGATING_TASKS_SUCCESS,
+  ENQUEUE_TASK_SUCCESS,
+  ENQUEUE_TASK_ERROR,
 } from "../actions/tasksActions";
+import {
+  RUN_SCHEDULER_ENTRY_SUCCESS,
+  RUN_SCHEDULER_ENTRY_ERROR,
+  SchedulerEntriesActionTypes,
+} from "../actions/schedulerEntriesActions";
 
 interface SnackbarState {
   isOpen: boolean;
@@ -62,7 +71,10 @@ const initialState: SnackbarState = {
 
 function snackbarReducer(
   state = initialState,
-  action: TasksActionTypes | SnackbarActionTypes
+  action:
+    | TasksActionTypes
+    | SchedulerEntriesActionTypes
+    | SnackbarActionTypes
 ): SnackbarState {
   switch (action.type) {
     case CLOSE_SNACKBAR:
@@ -119,6 +131,42 @@ function snackbarReducer(
         message: `Pending task is now archived`,
       };
 
+    case ENQUEUE_TASK_SUCCESS:
+      return {
+        isOpen: true,
+        message: `Task ${action.payload.id} is enqueued in ${action.payload.state} state`,
+      };
+
+    case ENQUEUE_TASK_ERROR:
+      return {
+        isOpen: true,
+        message: `Could not enqueue task: ${action.error}`,
+      };
+
+    case RUN_SCHEDULER_ENTRY_SUCCESS:
+      return {
+        isOpen: true,
+        message: `Task ${action.payload.id} of scheduler entry is enqueued`,
+      };
+
+    case RUN_SCHEDULER_ENTRY_ERROR:
+      return {
+        isOpen: true,
+        message: `Could not run scheduler entry: ${action.error}`,
+      };
+
+    case PRIORITIZE_PENDING_TASK_SUCCESS:
+      return {
+        isOpen: true,
+        message: `Pending task is moved to the front of the queue`,
+      };
+
+    case PRIORITIZE_PENDING_TASK_ERROR:
+      return {
+        isOpen: true,
+        message: `Could not prioritize task: ${action.error}`,
+      };
+
     case ARCHIVE_SCHEDULED_TASK_SUCCESS:
       return {
         isOpen: true,
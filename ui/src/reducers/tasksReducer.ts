@@ -160,7 +160,7 @@ import {
   ARCHIVE_AGGREGATING_TASK_SUCCESS,
   DELETE_AGGREGATING_TASK_SUCCESS,
 } from "../actions/tasksActions";
-import { TaskInfo } from "../api";
+import { Pagination, TaskInfo } from "../api";
 
 export interface TaskInfoExtended extends TaskInfo {
   // Indicates that a request has been sent for this
@@ -181,6 +181,7 @@ interface TasksState {
     allActionPending: boolean;
     error: string;
     data: TaskInfoExtended[];
+    pagination?: Pagination;
   };
   pendingTasks: {
     loading: boolean;
@@ -188,6 +189,7 @@ interface TasksState {
     allActionPending: boolean;
     error: string;
     data: TaskInfoExtended[];
+    pagination?: Pagination;
   };
   scheduledTasks: {
     loading: boolean;
@@ -195,6 +197,7 @@ interface TasksState {
     allActionPending: boolean;
     error: string;
     data: TaskInfoExtended[];
+    pagination?: Pagination;
   };
   retryTasks: {
     loading: boolean;
@@ -202,6 +205,7 @@ interface TasksState {
     allActionPending: boolean;
     error: string;
     data: TaskInfoExtended[];
+    pagination?: Pagination;
   };
   archivedTasks: {
     loading: boolean;
@@ -209,6 +213,7 @@ interface TasksState {
     allActionPending: boolean;
     error: string;
     data: TaskInfoExtended[];
+    pagination?: Pagination;
   };
   completedTasks: {
     loading: boolean;
@@ -216,6 +221,7 @@ interface TasksState {
     allActionPending: boolean;
     error: string;
     data: TaskInfoExtended[];
+    pagination?: Pagination;
   };
   aggregatingTasks: {
     group: string;
@@ -224,6 +230,7 @@ interface TasksState {
     allActionPending: boolean;
     error: string;
     data: TaskInfoExtended[];
+    pagination?: Pagination;
   };
   taskInfo: {
     loading: boolean;
@@ -338,6 +345,7 @@ function tasksReducer(
           ...state.activeTasks,
           loading: false,
           error: "",
+          pagination: action.payload.pagination,
           data: action.payload.tasks.map((task) => ({
             ...task,
             canceling: false,
@@ -373,6 +381,7 @@ function tasksReducer(
           ...state.pendingTasks,
           loading: false,
           error: "",
+          pagination: action.payload.pagination,
           data: action.payload.tasks.map((task) => ({
             ...task,
             requestPending: false,
@@ -407,6 +416,7 @@ function tasksReducer(
           ...state.scheduledTasks,
           loading: false,
           error: "",
+          pagination: action.payload.pagination,
           data: action.payload.tasks.map((task) => ({
             ...task,
             requestPending: false,
@@ -441,6 +451,7 @@ function tasksReducer(
           ...state.retryTasks,
           loading: false,
           error: "",
+          pagination: action.payload.pagination,
           data: action.payload.tasks.map((task) => ({
             ...task,
             requestPending: false,
@@ -475,6 +486,7 @@ function tasksReducer(
           ...state.archivedTasks,
           loading: false,
           error: "",
+          pagination: action.payload.pagination,
           data: action.payload.tasks.map((task) => ({
             ...task,
             requestPending: false,
@@ -509,6 +521,7 @@ function tasksReducer(
           ...state.completedTasks,
           loading: false,
           error: "",
+          pagination: action.payload.pagination,
           data: action.payload.tasks.map((task) => ({
             ...task,
             requestPending: false,
@@ -545,6 +558,7 @@ function tasksReducer(
           group: action.group,
           loading: false,
           error: "",
+          pagination: action.payload.pagination,
           data: action.payload.tasks.map((task) => ({
             ...task,
             requestPending: false,
@@ -1,21 +1,46 @@
 import { AxiosError } from "axios";
 
+// APIError is the body of an error response from the API.
+// See https://www.rfc-editor.org/rfc/rfc7807 for the format.
+export interface APIError {
+  type: string;
+  title: string;
+  status: number;
+  detail: string;
+  instance: string;
+  // Stable error code (e.g. "queue_not_found").
+  code: string;
+  request_id: string;
+}
+
+// errorMessage returns the error message from the error response body.
+function errorMessage(data: APIError | string): string {
+  if (data && typeof data === "object" && typeof data.detail === "string") {
+    return data.detail;
+  }
+  return data as string;
+}
+
 // toErrorStringWithHttpStatus returns a string representaion of axios error with HTTP status.
-export function toErrorStringWithHttpStatus(error: AxiosError<string>): string {
+export function toErrorStringWithHttpStatus(
+  error: AxiosError<APIError | string>
+): string {
   const { response } = error;
   if (!response) {
     return "error: no error response data available";
   }
-  return `${response.status} (${response.statusText}): ${response.data}`;
+  return `${response.status} (${response.statusText}): ${errorMessage(
+    response.data
+  )}`;
 }
 
 // toErrorString returns a string representaion of axios error.
-export function toErrorString(error: AxiosError<string>): string {
+export function toErrorString(error: AxiosError<APIError | string>): string {
   const { response } = error;
   if (!response) {
     return "Unknown error occurred. See the logs for details.";
   }
-  return response.data;
+  return errorMessage(response.data);
 }
 
 interface Duration {
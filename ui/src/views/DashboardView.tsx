@@ -24,6 +24,7 @@ import Tooltip from "../components/Tooltip";
 import SplitButton from "../components/SplitButton";
 import { usePolling } from "../hooks";
 import DailyStatsChart from "../components/DailyStatsChart";
+import TaskPoliciesTable from "../components/TaskPoliciesTable";
 
 const useStyles = makeStyles((theme) => ({
   container: {
@@ -72,6 +73,7 @@ function mapStateToProps(state: AppState) {
     })),
     error: state.queues.error,
     pollInterval: state.settings.pollInterval,
+    live: state.live.connected,
     queueStats: state.queueStats.data,
     dailyStatsKey: state.settings.dailyStatsChartType,
   };
@@ -100,20 +102,24 @@ function DashboardView(props: Props) {
     queues,
     listQueueStatsAsync,
     dailyStatsKey,
+    live,
   } = props;
   const classes = useStyles();
 
-  usePolling(listQueuesAsync, pollInterval);
+  usePolling(listQueuesAsync, pollInterval, live);
 
   // Refetch queue stats if a queue is added or deleted.
+  // The live updates stream sends queue stats by itself.
   const qnames = queues
     .map((q) => q.queue)
     .sort()
     .join(",");
 
   useEffect(() => {
-    listQueueStatsAsync();
-  }, [listQueueStatsAsync, qnames]);
+    if (!live) {
+      listQueueStatsAsync();
+    }
+  }, [listQueueStatsAsync, qnames, live]);
 
   const processedStats = queues.map((q) => ({
     queue: q.queue,
@@ -245,6 +251,27 @@ function DashboardView(props: Props) {
             />
           </Paper>
         </Grid>
+
+        <Grid item xs={12} className={classes.tableContainer}>
+          <Paper className={classes.paper} variant="outlined">
+            <div className={classes.chartHeader}>
+              <div className={classes.chartHeaderTitle}>
+                <Typography variant="h6">Task Policies</Typography>
+                <Tooltip
+                  title={
+                    <div>
+                      Retry and timeout options tasks were enqueued with, based
+                      on a sample of tasks in each queue
+                    </div>
+                  }
+                >
+                  <InfoIcon fontSize="small" className={classes.infoIcon} />
+                </Tooltip>
+              </div>
+            </div>
+            <TaskPoliciesTable />
+          </Paper>
+        </Grid>
       </Grid>
     </Container>
   );
@@ -8,6 +8,7 @@ import Grid from "@material-ui/core/Grid";
 import Typography from "@material-ui/core/Typography";
 import WarningIcon from "@material-ui/icons/Warning";
 import InfoIcon from "@material-ui/icons/Info";
+import Alert from "@material-ui/lab/Alert";
 import prettyBytes from "pretty-bytes";
 import { getMetricsAsync } from "../actions/metricsActions";
 import { listQueuesAsync } from "../actions/queuesActions";
@@ -171,6 +172,17 @@ function MetricsView(props: Props) {
         />
       </div>
       <Grid container spacing={3}>
+        {data?.stale && (
+          <Grid item xs={12}>
+            <Alert severity="warning">
+              Prometheus is unreachable. Showing metrics fetched at{" "}
+              {data.fetched_at
+                ? new Date(data.fetched_at).toLocaleString()
+                : "an earlier time"}
+              .
+            </Alert>
+          </Grid>
+        )}
         {data?.tasks_processed_per_second && (
           <Grid item xs={12}>
             <ChartRow
@@ -0,0 +1,200 @@
+import React, { useCallback, useEffect, useState } from "react";
+import { connect, ConnectedProps } from "react-redux";
+import Container from "@material-ui/core/Container";
+import { makeStyles, useTheme } from "@material-ui/core/styles";
+import Grid from "@material-ui/core/Grid";
+import Paper from "@material-ui/core/Paper";
+import Typography from "@material-ui/core/Typography";
+import Table from "@material-ui/core/Table";
+import TableBody from "@material-ui/core/TableBody";
+import TableCell from "@material-ui/core/TableCell";
+import TableContainer from "@material-ui/core/TableContainer";
+import TableHead from "@material-ui/core/TableHead";
+import TableRow from "@material-ui/core/TableRow";
+import Alert from "@material-ui/lab/Alert";
+import {
+  LineChart,
+  Line,
+  XAxis,
+  YAxis,
+  CartesianGrid,
+  Tooltip,
+  Legend,
+  ResponsiveContainer,
+} from "recharts";
+import { AppState } from "../store";
+import { usePolling } from "../hooks";
+import {
+  getPanelData,
+  listPanels,
+  PanelDataResponse,
+  PanelInfo,
+} from "../api";
+import { toErrorString } from "../utils";
+
+const useStyles = makeStyles((theme) => ({
+  container: {
+    paddingTop: theme.spacing(4),
+    paddingBottom: theme.spacing(4),
+  },
+  paper: {
+    padding: theme.spacing(2),
+    display: "flex",
+    overflow: "auto",
+    flexDirection: "column",
+  },
+  heading: {
+    marginBottom: theme.spacing(1),
+  },
+}));
+
+// Colors of the lines of line chart panels.
+const lineColors = ["#2085ec", "#72b4eb", "#0a417a", "#8464a0", "#cea9bc"];
+
+function mapStateToProps(state: AppState) {
+  return {
+    pollInterval: state.settings.pollInterval,
+  };
+}
+
+const connector = connect(mapStateToProps);
+
+type Props = ConnectedProps<typeof connector>;
+
+// PluginsView shows the custom panels provided by the application embedding asynqmon.
+function PluginsView(props: Props) {
+  const classes = useStyles();
+  const [panels, setPanels] = useState<PanelInfo[]>([]);
+  const [error, setError] = useState("");
+
+  useEffect(() => {
+    listPanels()
+      .then((resp) => setPanels(resp.panels))
+      .catch((err) => setError(toErrorString(err)));
+  }, []);
+
+  return (
+    <Container maxWidth="lg" className={classes.container}>
+      <Grid container spacing={3}>
+        {error !== "" && (
+          <Grid item xs={12}>
+            <Alert severity="error">{error}</Alert>
+          </Grid>
+        )}
+        {panels.map((p) => (
+          <Grid item xs={12} key={p.name}>
+            <Paper className={classes.paper} variant="outlined">
+              <Typography variant="h6" className={classes.heading}>
+                {p.title}
+              </Typography>
+              <PanelContent panel={p} pollInterval={props.pollInterval} />
+            </Paper>
+          </Grid>
+        ))}
+      </Grid>
+    </Container>
+  );
+}
+
+function PanelContent(props: { panel: PanelInfo; pollInterval: number }) {
+  const { name } = props.panel;
+  const [data, setData] = useState<PanelDataResponse | null>(null);
+  const [error, setError] = useState("");
+
+  const fetchData = useCallback(() => {
+    getPanelData(name)
+      .then((resp) => {
+        setData(resp);
+        setError("");
+      })
+      .catch((err) => setError(toErrorString(err)));
+  }, [name]);
+  usePolling(fetchData, props.pollInterval);
+
+  if (error !== "") {
+    return <Alert severity="error">{error}</Alert>;
+  }
+  if (!data) {
+    return null;
+  }
+  if (data.rows.length === 0) {
+    return (
+      <Typography variant="body2" color="textSecondary">
+        No data
+      </Typography>
+    );
+  }
+  return data.type === "line_chart" ? (
+    <PanelLineChart data={data} />
+  ) : (
+    <PanelTable data={data} />
+  );
+}
+
+function PanelTable(props: { data: PanelDataResponse }) {
+  const { columns, rows } = props.data;
+  return (
+    <TableContainer>
+      <Table size="small">
+        <TableHead>
+          <TableRow>
+            {columns.map((c) => (
+              <TableCell key={c}>{c}</TableCell>
+            ))}
+          </TableRow>
+        </TableHead>
+        <TableBody>
+          {rows.map((row, i) => (
+            <TableRow key={i}>
+              {row.map((v, j) => (
+                <TableCell key={j}>
+                  {typeof v === "object" && v !== null
+                    ? JSON.stringify(v)
+                    : String(v)}
+                </TableCell>
+              ))}
+            </TableRow>
+          ))}
+        </TableBody>
+      </Table>
+    </TableContainer>
+  );
+}
+
+function PanelLineChart(props: { data: PanelDataResponse }) {
+  const theme = useTheme();
+  const { columns, rows } = props.data;
+  const [x, ...lines] = columns;
+  const chartData = rows.map((row) => {
+    const point: { [key: string]: any } = {};
+    columns.forEach((c, i) => (point[c] = row[i]));
+    return point;
+  });
+  return (
+    <ResponsiveContainer height={300}>
+      <LineChart data={chartData}>
+        <CartesianGrid strokeDasharray="3 3" />
+        <XAxis
+          dataKey={x}
+          minTickGap={10}
+          stroke={theme.palette.text.secondary}
+        />
+        <YAxis stroke={theme.palette.text.secondary} />
+        <Tooltip />
+        <Legend />
+        {lines.map((c, i) => (
+          <Line
+            key={c}
+            type="monotone"
+            dataKey={c}
+            stroke={lineColors[i % lineColors.length]}
+            dot={false}
+            isAnimationActive={false}
+          />
+        ))}
+      </LineChart>
+    </ResponsiveContainer>
+  );
+}
+
+export default connector(PluginsView);
@@ -15,6 +15,7 @@ import { AppState } from "../store";
 import { timeAgoUnix } from "../utils";
 import { RedisInfo } from "../api";
 import QueueLocationTable from "../components/QueueLocationTable";
+import RedisLatencyPanel from "../components/RedisLatencyPanel";
 import Link from "@material-ui/core/Link";
 
 const useStyles = makeStyles((theme) => ({
@@ -107,6 +108,7 @@ function RedisInfoView(props: Props) {
             {redisInfo && !redisClusterEnabled && (
               <RedisMetricCards redisInfo={redisInfo} />
             )}
+            <RedisLatencyPanel pollInterval={pollInterval} />
             {redisInfoRaw && (
               <>
                 <Grid item xs={6}>
@@ -6,12 +6,26 @@ import Grid from "@material-ui/core/Grid";
 import Paper from "@material-ui/core/Paper";
 import Typography from "@material-ui/core/Typography";
 import Slider from "@material-ui/core/Slider";
-import { pollIntervalChange, selectTheme } from "../actions/settingsActions";
+import {
+  pollIntervalChange,
+  selectTheme,
+  settingsRestore,
+} from "../actions/settingsActions";
 import { AppState } from "../store";
 import FormControl from "@material-ui/core/FormControl/FormControl";
 import Select from "@material-ui/core/Select";
 import MenuItem from "@material-ui/core/MenuItem";
-import { ThemePreference } from "../reducers/settingsReducer";
+import Button from "@material-ui/core/Button";
+import { SettingsState, ThemePreference } from "../reducers/settingsReducer";
+import {
+  listNotifiers,
+  testNotifiers,
+  NotifyResult,
+  exportSettings,
+  importSettings,
+  SettingsDocument,
+} from "../api";
+import { toErrorString } from "../utils";
 
 const useStyles = makeStyles((theme) => ({
   container: {
@@ -43,10 +57,11 @@ function mapStateToProps(state: AppState) {
   return {
     pollInterval: state.settings.pollInterval,
     themePreference: state.settings.themePreference,
+    settings: state.settings,
   };
 }
 
-const mapDispatchToProps = { pollIntervalChange, selectTheme };
+const mapDispatchToProps = { pollIntervalChange, selectTheme, settingsRestore };
 
 const connector = connect(mapStateToProps, mapDispatchToProps);
 
@@ -127,9 +142,180 @@ function SettingsView(props: PropsFromRedux) {
           </Paper>
         </Grid>
         <Grid item xs={5} />
+
+        {!window.READ_ONLY && (
+          <React.Fragment>
+            <Grid item xs={1} />
+            <Grid item xs={6}>
+              <NotifiersPaper className={classes.paper} />
+            </Grid>
+            <Grid item xs={5} />
+
+            <Grid item xs={1} />
+            <Grid item xs={6}>
+              <SettingsTransferPaper
+                className={classes.paper}
+                settings={props.settings}
+                onRestore={props.settingsRestore}
+              />
+            </Grid>
+            <Grid item xs={5} />
+          </React.Fragment>
+        )}
       </Grid>
     </Container>
   );
 }
 
+function NotifiersPaper(props: { className: string }) {
+  const [names, setNames] = useState<string[]>([]);
+  const [results, setResults] = useState<NotifyResult[] | null>(null);
+  const [sending, setSending] = useState(false);
+
+  React.useEffect(() => {
+    listNotifiers()
+      .then((resp) => setNames(resp.notifiers.map((n) => n.name)))
+      .catch(() => setNames([]));
+  }, []);
+
+  const handleTestClick = async () => {
+    setSending(true);
+    try {
+      const resp = await testNotifiers();
+      setResults(resp.results);
+    } catch (error) {
+      setResults(null);
+    }
+    setSending(false);
+  };
+
+  return (
+    <Paper className={props.className} variant="outlined">
+      <Typography color="textPrimary">Notifications</Typography>
+      <Typography gutterBottom color="textSecondary" variant="subtitle1">
+        {names.length === 0
+          ? "No notification channels are configured"
+          : `Configured channels: ${names.join(", ")}`}
+      </Typography>
+      {results &&
+        results.map((r) => (
+          <Typography
+            key={r.name}
+            color={r.ok ? "textSecondary" : "error"}
+            variant="body2"
+          >
+            {r.name}: {r.ok ? "delivered" : `failed (${r.error})`}
+          </Typography>
+        ))}
+      <div>
+        <Button
+          variant="outlined"
+          color="primary"
+          disabled={names.length === 0 || sending}
+          onClick={handleTestClick}
+        >
+          Send test notification
+        </Button>
+      </div>
+    </Paper>
+  );
+}
+
+function SettingsTransferPaper(props: {
+  className: string;
+  settings: SettingsState;
+  onRestore: (value: Partial<SettingsState>) => void;
+}) {
+  const fileInput = React.useRef<HTMLInputElement>(null);
+  const [status, setStatus] = useState<{ error: boolean; message: string }>();
+
+  const handleExportClick = async () => {
+    try {
+      const doc = await exportSettings();
+      doc.ui = props.settings;
+      const blob = new Blob([JSON.stringify(doc, null, 2)], {
+        type: "application/json",
+      });
+      const a = document.createElement("a");
+      a.href = URL.createObjectURL(blob);
+      a.download = `asynqmon-settings-${doc.exported_at.slice(0, 10)}.json`;
+      a.click();
+      URL.revokeObjectURL(a.href);
+      setStatus(undefined);
+    } catch (error) {
+      setStatus({ error: true, message: toErrorString(error) });
+    }
+  };
+
+  const handleFileChange = async (
+    event: React.ChangeEvent<HTMLInputElement>
+  ) => {
+    const file = event.target.files?.[0];
+    event.target.value = "";
+    if (!file) {
+      return;
+    }
+    try {
+      const doc: SettingsDocument = JSON.parse(await file.text());
+      const resp = await importSettings(doc);
+      if (doc.ui) {
+        props.onRestore(doc.ui as Partial<SettingsState>);
+      }
+      let message = `Imported ${resp.imported_api_tokens} API token(s)`;
+      if (doc.ui) {
+        message += " and the Web UI settings";
+      }
+      if (resp.ignored.length > 0) {
+        message += `; ignored ${resp.ignored.join("; ")}`;
+      }
+      setStatus({ error: false, message });
+    } catch (error) {
+      setStatus({
+        error: true,
+        message:
+          error instanceof SyntaxError
+            ? "The file is not a valid settings document"
+            : toErrorString(error),
+      });
+    }
+  };
+
+  return (
+    <Paper className={props.className} variant="outlined">
+      <Typography color="textPrimary">Export and Import</Typography>
+      <Typography gutterBottom color="textSecondary" variant="subtitle1">
+        Reproduce these settings and API tokens in another environment
+      </Typography>
+      {status && (
+        <Typography
+          gutterBottom
+          color={status.error ? "error" : "textSecondary"}
+          variant="body2"
+        >
+          {status.message}
+        </Typography>
+      )}
+      <div>
+        <Button variant="outlined" color="primary" onClick={handleExportClick}>
+          Export
+        </Button>{" "}
+        <Button
+          variant="outlined"
+          color="primary"
+          onClick={() => fileInput.current?.click()}
+        >
+          Import
+        </Button>
+        <input
+          ref={fileInput}
+          type="file"
+          accept="application/json"
+          hidden
+          onChange={handleFileChange}
+        />
+      </div>
+    </Paper>
+  );
+}
+
 export default connector(SettingsView);
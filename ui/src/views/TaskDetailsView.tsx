@@ -7,13 +7,18 @@ import Grid from "@material-ui/core/Grid";
 import Paper from "@material-ui/core/Paper";
 import Typography from "@material-ui/core/Typography";
 import Button from "@material-ui/core/Button";
+import Link from "@material-ui/core/Link";
 import Alert from "@material-ui/lab/Alert";
 import AlertTitle from "@material-ui/lab/AlertTitle";
 import ArrowBackIcon from "@material-ui/icons/ArrowBack";
+import prettyBytes from "pretty-bytes";
 import { useParams } from "react-router-dom";
 import QueueBreadCrumb from "../components/QueueBreadcrumb";
 import { AppState } from "../store";
-import { getTaskInfoAsync } from "../actions/tasksActions";
+import {
+  getTaskInfoAsync,
+  prioritizePendingTaskAsync,
+} from "../actions/tasksActions";
 import { TaskDetailsRouteParams } from "../paths";
 import { usePolling } from "../hooks";
 import { listQueuesAsync } from "../actions/queuesActions";
@@ -33,6 +38,7 @@ function mapStateToProps(state: AppState) {
 const connector = connect(mapStateToProps, {
   getTaskInfoAsync,
   listQueuesAsync,
+  prioritizePendingTaskAsync,
 });
 
 const useStyles = makeStyles((theme) => ({
@@ -218,6 +224,29 @@ function TaskDetailsView(props: Props) {
                   )}
                 </Typography>
               </div>
+              {taskInfo?.trace_id && (
+                <div className={classes.infoRow}>
+                  <Typography
+                    variant="subtitle2"
+                    className={classes.infoKeyCell}
+                  >
+                    Trace ID:{" "}
+                  </Typography>
+                  <Typography className={classes.infoValueCell}>
+                    {taskInfo.trace_url ? (
+                      <Link
+                        href={taskInfo.trace_url}
+                        target="_blank"
+                        rel="noopener noreferrer"
+                      >
+                        {taskInfo.trace_id}
+                      </Link>
+                    ) : (
+                      taskInfo.trace_id
+                    )}
+                  </Typography>
+                </div>
+              )}
               <div className={classes.infoRow}>
                 <Typography variant="subtitle2" className={classes.infoKeyCell}>
                   Payload:{" "}
@@ -233,6 +262,18 @@ function TaskDetailsView(props: Props) {
                   )}
                 </div>
               </div>
+              <div className={classes.infoRow}>
+                <Typography variant="subtitle2" className={classes.infoKeyCell}>
+                  Payload Size:{" "}
+                </Typography>
+                <Typography className={classes.infoValueCell}>
+                  {taskInfo ? prettyBytes(taskInfo.payload_size_bytes) : "-"}
+                  {taskInfo && taskInfo.decompressed_payload_size_bytes > 0 &&
+                    ` (${prettyBytes(
+                      taskInfo.decompressed_payload_size_bytes
+                    )} decompressed)`}
+                </Typography>
+              </div>
               {
                 /* Completed Task Only */ taskInfo?.state === "completed" && (
                   <>
@@ -295,6 +336,29 @@ function TaskDetailsView(props: Props) {
             >
               Go Back
             </Button>
+            {taskInfo?.state === "pending" && !window.READ_ONLY && (
+              <Button
+                color="primary"
+                onClick={() =>
+                  props
+                    .prioritizePendingTaskAsync(qname, taskId)
+                    .then(fetchTaskInfo)
+                }
+              >
+                Move to Front
+              </Button>
+            )}
+            {taskInfo?.links?.map((link) => (
+              <Button
+                key={link.label}
+                color="primary"
+                href={link.url}
+                target="_blank"
+                rel="noopener noreferrer"
+              >
+                {link.label}
+              </Button>
+            ))}
           </div>
         </Grid>
       </Grid>
@@ -1,13 +1,21 @@
-import React, { useEffect } from "react";
+import React, { useEffect, useState } from "react";
 import { connect, ConnectedProps } from "react-redux";
 import { makeStyles } from "@material-ui/core/styles";
 import Container from "@material-ui/core/Container";
 import Grid from "@material-ui/core/Grid";
+import Button from "@material-ui/core/Button";
+import AddIcon from "@material-ui/icons/Add";
+import GetAppIcon from "@material-ui/icons/GetApp";
+import PublishIcon from "@material-ui/icons/Publish";
 import TasksTableContainer from "../components/TasksTableContainer";
 import QueueInfoBanner from "../components/QueueInfoBanner";
 import QueueBreadCrumb from "../components/QueueBreadcrumb";
+import EnqueueTaskDialog from "../components/EnqueueTaskDialog";
+import ImportTasksDialog from "../components/ImportTasksDialog";
 import { useParams } from "react-router-dom";
 import { listQueuesAsync } from "../actions/queuesActions";
+import { exportTasks } from "../api";
+import { toErrorString } from "../utils";
 import { AppState } from "../store";
 import { QueueDetailsRouteParams } from "../paths";
 import { useQuery } from "../hooks";
@@ -26,6 +34,9 @@ const useStyles = makeStyles((theme) => ({
   },
   breadcrumbs: {
     marginBottom: theme.spacing(2),
+    display: "flex",
+    justifyContent: "space-between",
+    alignItems: "center",
   },
   banner: {
     marginBottom: theme.spacing(2),
@@ -55,6 +66,21 @@ function TasksView(props: ConnectedProps<typeof connector>) {
     selected = defaultStatus;
   }
   const { listQueuesAsync } = props;
+  const [enqueueDialogOpen, setEnqueueDialogOpen] = useState(false);
+  const [importDialogOpen, setImportDialogOpen] = useState(false);
+
+  const handleExportClick = async (state: string) => {
+    try {
+      const blob = await exportTasks(qname, state);
+      const a = document.createElement("a");
+      a.href = URL.createObjectURL(blob);
+      a.download = `${qname}-${state}-tasks.ndjson`;
+      a.click();
+      URL.revokeObjectURL(a.href);
+    } catch (error) {
+      window.alert(`Could not export tasks: ${toErrorString(error)}`);
+    }
+  };
 
   useEffect(() => {
     listQueuesAsync();
@@ -65,6 +91,35 @@ function TasksView(props: ConnectedProps<typeof connector>) {
       <Grid container spacing={0} className={classes.container}>
         <Grid item xs={12} className={classes.breadcrumbs}>
           <QueueBreadCrumb queues={props.queues} queueName={qname} />
+          <div>
+            {selected !== "aggregating" && (
+              <Button
+                color="primary"
+                startIcon={<GetAppIcon />}
+                onClick={() => handleExportClick(selected as string)}
+              >
+                Export {selected}
+              </Button>
+            )}
+            {!window.READ_ONLY && (
+              <>
+                <Button
+                  color="primary"
+                  startIcon={<PublishIcon />}
+                  onClick={() => setImportDialogOpen(true)}
+                >
+                  Import
+                </Button>
+                <Button
+                  color="primary"
+                  startIcon={<AddIcon />}
+                  onClick={() => setEnqueueDialogOpen(true)}
+                >
+                  Enqueue Task
+                </Button>
+              </>
+            )}
+          </div>
         </Grid>
         <Grid item xs={12} className={classes.banner}>
           <QueueInfoBanner qname={qname} />
@@ -73,6 +128,16 @@ function TasksView(props: ConnectedProps<typeof connector>) {
           <TasksTableContainer queue={qname} selected={selected} />
         </Grid>
       </Grid>
+      <EnqueueTaskDialog
+        queue={qname}
+        open={enqueueDialogOpen}
+        onClose={() => setEnqueueDialogOpen(false)}
+      />
+      <ImportTasksDialog
+        queue={qname}
+        open={importDialogOpen}
+        onClose={() => setImportDialogOpen(false)}
+      />
     </Container>
   );
 }
@@ -0,0 +1,104 @@
+package asynqmon
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - User and roles which restrict what users signed in to the Web UI can do
+//   - userAuth which authenticates users with HTTP basic authentication
+// ****************************************************************************
+
+// Roles of users.
+const (
+	// RoleViewer can view everything but cannot make any changes.
+	RoleViewer = "viewer"
+
+	// RoleAdmin can view and change everything.
+	RoleAdmin = "admin"
+)
+
+// roleScopes are the scopes granted to users with each role.
+var roleScopes = map[string][]scope{
+	RoleViewer: {{group: scopeGroupAll, level: scopeAccessLevels["read"]}},
+	RoleAdmin:  {{group: scopeGroupAll, level: scopeAccessLevels["admin"]}},
+}
+
+// User is a user who signs in with HTTP basic authentication.
+type User struct {
+	// Username of the user.
+	Username string
+
+	// Password of the user.
+	Password string
+
+	// Role of the user, which is either RoleViewer or RoleAdmin.
+	//
+	// This field is optional. Default is RoleViewer.
+	Role string
+}
+
+// newUserInfo returns the apiTokenInfo used for a user with the given role.
+func newUserInfo(username, role string) *apiTokenInfo {
+	return &apiTokenInfo{name: username, role: role, scopes: roleScopes[role]}
+}
+
+type userCredentials struct {
+	passwordHash [sha256.Size]byte
+	info         *apiTokenInfo
+}
+
+// userAuth authenticates users with HTTP basic authentication.
+type userAuth struct {
+	users map[string]*userCredentials // keyed by username
+}
+
+func newUserAuth(users []User) (*userAuth, error) {
+	a := &userAuth{users: make(map[string]*userCredentials, len(users))}
+	for _, u := range users {
+		if u.Username == "" {
+			return nil, fmt.Errorf("user must have a username")
+		}
+		if u.Password == "" {
+			return nil, fmt.Errorf("user %q has empty password", u.Username)
+		}
+		if _, ok := a.users[u.Username]; ok {
+			return nil, fmt.Errorf("duplicate user %q", u.Username)
+		}
+		role := u.Role
+		if role == "" {
+			role = RoleViewer
+		}
+		if _, ok := roleScopes[role]; !ok {
+			return nil, fmt.Errorf("user %q has unknown role %q; must be either %q or %q", u.Username, u.Role, RoleViewer, RoleAdmin)
+		}
+		a.users[u.Username] = &userCredentials{
+			passwordHash: sha256.Sum256([]byte(u.Password)),
+			info:         newUserInfo(u.Username, role),
+		}
+	}
+	return a, nil
+}
+
+// authenticate returns the user with the credentials of the request, or nil if
+// the request has no or invalid credentials.
+func (a *userAuth) authenticate(r *http.Request) *apiTokenInfo {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil
+	}
+	u, ok := a.users[username]
+	if !ok {
+		return nil
+	}
+	// Compare hashes so that the comparison takes the same time regardless of the length of the password.
+	hash := sha256.Sum256([]byte(password))
+	if subtle.ConstantTimeCompare(hash[:], u.passwordHash[:]) != 1 {
+		return nil
+	}
+	return u.info
+}
@@ -0,0 +1,63 @@
+package asynqmon
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewUserAuth(t *testing.T) {
+	tests := []struct {
+		desc    string
+		users   []User
+		wantErr bool
+	}{
+		{"valid users", []User{{Username: "alice", Password: "a", Role: RoleAdmin}, {Username: "bob", Password: "b"}}, false},
+		{"no username", []User{{Password: "a"}}, true},
+		{"no password", []User{{Username: "alice"}}, true},
+		{"duplicate user", []User{{Username: "alice", Password: "a"}, {Username: "alice", Password: "b"}}, true},
+		{"unknown role", []User{{Username: "alice", Password: "a", Role: "owner"}}, true},
+	}
+	for _, tc := range tests {
+		if _, err := newUserAuth(tc.users); (err != nil) != tc.wantErr {
+			t.Errorf("%s: newUserAuth returned error %v, want error %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestUserAuthAuthenticate(t *testing.T) {
+	a, err := newUserAuth([]User{{Username: "alice", Password: "s3cr3t", Role: RoleAdmin}, {Username: "bob", Password: "p4ssw0rd"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		username, password string
+		wantRole           string // empty if the user is not authenticated
+	}{
+		{"alice", "s3cr3t", RoleAdmin},
+		{"bob", "p4ssw0rd", RoleViewer},
+		{"alice", "p4ssw0rd", ""},
+		{"alice", "", ""},
+		{"carol", "s3cr3t", ""},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest("GET", "/api/queues", nil)
+		r.SetBasicAuth(tc.username, tc.password)
+		info := a.authenticate(r)
+		var got string
+		if info != nil {
+			got = info.role
+		}
+		if got != tc.wantRole {
+			t.Errorf("authenticate(%q, %q) returned role %q, want %q", tc.username, tc.password, got, tc.wantRole)
+		}
+	}
+
+	if info := a.authenticate(httptest.NewRequest("GET", "/api/queues", nil)); info != nil {
+		t.Errorf("authenticate without credentials returned %v, want nil", info)
+	}
+	// Viewers can read everything but not make changes.
+	viewer := newUserInfo("bob", RoleViewer)
+	if !viewer.allows(requiredScope("/api/queues", "GET")) || viewer.allows(requiredScope("/api/queues/{qname}:pause", "POST")) {
+		t.Errorf("viewer has unexpected scopes: %v", viewer.scopes)
+	}
+}
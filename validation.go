@@ -0,0 +1,136 @@
+package asynqmon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gorilla/mux"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - helpers to validate request parameters
+//   - middleware which validates route parameters
+// ****************************************************************************
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 1000
+
+	// Maximum length of queue names, group names, task IDs, and scheduler entry IDs.
+	maxIdentifierLen = 512
+
+	// Maximum number of task IDs in a batch request.
+	maxBatchSize = 1000
+)
+
+// getPageOptions reads page size and number from the request url if set,
+// otherwise it returns the default value.
+// It returns an error if the values are not valid.
+func getPageOptions(r *http.Request) (pageSize, pageNum int, err error) {
+	pageSize = defaultPageSize
+	pageNum = 1
+	q := r.URL.Query()
+	if s := q.Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxPageSize {
+			return 0, 0, fmt.Errorf("invalid query parameter: size must be an integer between 1 and %d, got %q", maxPageSize, s)
+		}
+		pageSize = n
+	}
+	if s := q.Get("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return 0, 0, fmt.Errorf("invalid query parameter: page must be a positive integer, got %q", s)
+		}
+		pageNum = n
+	}
+	return pageSize, pageNum, nil
+}
+
+// validateIdentifier returns an error if s is not a valid queue name, group name,
+// task ID, or scheduler entry ID. name is used in the error message.
+func validateIdentifier(name, s string) error {
+	if strings.TrimSpace(s) == "" {
+		return fmt.Errorf("%s must not be empty", name)
+	}
+	if len(s) > maxIdentifierLen {
+		return fmt.Errorf("%s must not be longer than %d bytes", name, maxIdentifierLen)
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%s must not contain control characters", name)
+		}
+	}
+	return nil
+}
+
+// Names of the route parameters used in error messages.
+var routeVarNames = map[string]string{
+	"qname":     "queue name",
+	"gname":     "group name",
+	"task_id":   "task ID",
+	"entry_id":  "scheduler entry ID",
+	"token_id":  "API token ID",
+	"rule_name": "alert rule name",
+}
+
+// validateRouteVars is a middleware which rejects requests with invalid route parameters.
+func validateRouteVars(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for key, val := range mux.Vars(r) {
+			name, ok := routeVarNames[key]
+			if !ok {
+				continue
+			}
+			if err := validateIdentifier(name, val); err != nil {
+				writeBadRequestError(w, r, fmt.Sprintf("invalid path parameter: %v", err))
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// decodeRequestBody decodes the JSON request body into v.
+// It returns an error if the body is empty, too large, has unknown fields,
+// or has data after the JSON value. Numbers decoded into interface{} values are json.Number.
+func decodeRequestBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	// Keep large integers in arbitrary JSON values (e.g. task payloads) exact.
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("invalid request body: body must not be empty")
+		}
+		return fmt.Errorf("invalid request body: %v", err)
+	}
+	if dec.More() {
+		return errors.New("invalid request body: body must contain a single JSON object")
+	}
+	return nil
+}
+
+// validateTaskIDs returns an error if ids is not a valid list of task IDs for a batch request.
+func validateTaskIDs(ids []string) error {
+	if len(ids) == 0 {
+		return errors.New("invalid request body: task_ids must not be empty")
+	}
+	if len(ids) > maxBatchSize {
+		return fmt.Errorf("invalid request body: task_ids must not have more than %d elements", maxBatchSize)
+	}
+	for _, id := range ids {
+		if err := validateIdentifier("task ID", id); err != nil {
+			return fmt.Errorf("invalid request body: %v", err)
+		}
+	}
+	return nil
+}